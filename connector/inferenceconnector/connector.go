@@ -0,0 +1,41 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package inferenceconnector // import "github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/processor"
+)
+
+// inferenceConnector runs inference on the metrics of its input pipeline and
+// emits only the inferred metrics into its output pipelines
+type inferenceConnector struct {
+	processor processor.Metrics
+}
+
+// Start starts the wrapped processor, connecting to the inference server
+func (c *inferenceConnector) Start(ctx context.Context, host component.Host) error {
+	return c.processor.Start(ctx, host)
+}
+
+// Shutdown stops the wrapped processor
+func (c *inferenceConnector) Shutdown(ctx context.Context) error {
+	return c.processor.Shutdown(ctx)
+}
+
+// Capabilities reports that the connector mutates data, as the processor
+// appends its results to the consumed metrics
+func (c *inferenceConnector) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+// ConsumeMetrics runs inference on a batch. The processor removes the consumed
+// metrics itself, so only the ones it produced are passed on.
+func (c *inferenceConnector) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	return c.processor.ConsumeMetrics(ctx, md)
+}
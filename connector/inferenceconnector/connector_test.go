@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package inferenceconnector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector/internal/metadata"
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// newSettings returns connector settings for tests
+func newSettings() connector.Settings {
+	return connector.Settings{
+		ID:                component.NewIDWithName(metadata.Type, "outputs"),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+		BuildInfo:         component.NewDefaultBuildInfo(),
+	}
+}
+
+// newGaugeBatch creates a batch with one gauge per name in a single scope
+func newGaugeBatch(value float64, names ...string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	for _, name := range names {
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(value)
+	}
+	return md
+}
+
+func TestFactory(t *testing.T) {
+	factory := NewFactory()
+	assert.Equal(t, "inference", factory.Type().String())
+	assert.Equal(t, metadata.MetricsToMetricsStability, factory.MetricsToMetricsStability())
+	require.NoError(t, componenttest.CheckConfigStruct(factory.CreateDefaultConfig()))
+}
+
+func TestConnectorEmitsOnlyOutputs(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Rules = []metricsinferenceprocessor.Rule{
+		{
+			ModelName:     "smoother",
+			Inputs:        []string{"cpu.utilization"},
+			OutputPattern: "smoothed.{input}",
+			Backend:       "local",
+			Local:         &metricsinferenceprocessor.LocalConfig{Function: "ewma", Alpha: 0.5},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	conn, err := factory.CreateMetricsToMetrics(context.Background(), newSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, conn.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, conn.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, conn.ConsumeMetrics(context.Background(), newGaugeBatch(10, "cpu.utilization", "memory.usage")))
+
+	// Batches without inference results are not passed on
+	require.NoError(t, conn.ConsumeMetrics(context.Background(), newGaugeBatch(10, "memory.usage")))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	md := sink.AllMetrics()[0]
+	require.Equal(t, 1, md.MetricCount())
+	metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, "smoothed.cpu.utilization", metric.Name())
+	assert.Equal(t, 10.0, metric.Gauge().DataPoints().At(0).DoubleValue())
+}
+
+// failingClient is an inference client whose inferences all fail
+type failingClient struct {
+	pb.GRPCInferenceServiceClient // Calls not overridden are not expected
+}
+
+func (c *failingClient) ServerLive(context.Context, *pb.ServerLiveRequest, ...grpc.CallOption) (*pb.ServerLiveResponse, error) {
+	return &pb.ServerLiveResponse{Live: true}, nil
+}
+
+func (c *failingClient) ServerMetadata(context.Context, *pb.ServerMetadataRequest, ...grpc.CallOption) (*pb.ServerMetadataResponse, error) {
+	return &pb.ServerMetadataResponse{Name: "failing"}, nil
+}
+
+func (c *failingClient) ModelMetadata(_ context.Context, req *pb.ModelMetadataRequest, _ ...grpc.CallOption) (*pb.ModelMetadataResponse, error) {
+	return &pb.ModelMetadataResponse{Name: req.Name}, nil
+}
+
+func (c *failingClient) ModelInfer(context.Context, *pb.ModelInferRequest, ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	return nil, status.Error(codes.Unavailable, "model unavailable")
+}
+
+func TestConnectorDropInputsKeepsOtherOutputs(t *testing.T) {
+	factory := NewFactory(metricsinferenceprocessor.WithClient(&failingClient{}))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Rules = []metricsinferenceprocessor.Rule{
+		metricsinferenceprocessor.NewRule("scorer",
+			metricsinferenceprocessor.WithInputs("memory.usage"),
+			metricsinferenceprocessor.WithOutputs(metricsinferenceprocessor.OutputSpec{Name: "score"}),
+			metricsinferenceprocessor.WithRuleOnError("drop_inputs")),
+		{
+			ModelName:     "smoother",
+			Inputs:        []string{"cpu.utilization"},
+			OutputPattern: "smoothed.{input}",
+			Backend:       "local",
+			Local:         &metricsinferenceprocessor.LocalConfig{Function: "ewma", Alpha: 0.5},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	conn, err := factory.CreateMetricsToMetrics(context.Background(), newSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, conn.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, conn.Shutdown(context.Background()))
+	}()
+
+	// The scorer's input, which comes first in the scope, is dropped, leaving
+	// the other rule's output in place
+	require.NoError(t, conn.ConsumeMetrics(context.Background(), newGaugeBatch(10, "memory.usage", "cpu.utilization")))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	md := sink.AllMetrics()[0]
+	require.Equal(t, 1, md.MetricCount())
+	metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, "smoothed.cpu.utilization", metric.Name())
+	assert.Equal(t, 10.0, metric.Gauge().DataPoints().At(0).DoubleValue())
+}
@@ -0,0 +1,12 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:generate mdatagen metadata.yaml
+
+// Package inferenceconnector provides a connector that runs the metrics inference
+// processor on the metrics of one pipeline and emits only the inferred metrics
+// into another pipeline.
+//
+// This connector is experimental and subject to change or removal without notice.
+// It is not recommended for production use.
+package inferenceconnector // import "github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector"
@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package inferenceconnector // import "github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector"
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/processor"
+
+	"github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector/internal/metadata"
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor"
+)
+
+// Config is the configuration of the connector. It is the configuration of the
+// metrics inference processor, so rules can move between the two unchanged.
+type Config = metricsinferenceprocessor.Config
+
+// NewFactory returns a new factory for the Inference connector. Options customize
+// the inference as they do for the metrics inference processor, whose default
+// configuration is the connector's.
+func NewFactory(opts ...metricsinferenceprocessor.FactoryOption) connector.Factory {
+	return connector.NewFactory(
+		metadata.Type,
+		metricsinferenceprocessor.NewFactory(opts...).CreateDefaultConfig,
+		connector.WithMetricsToMetrics(metricsToMetricsCreator(opts), metadata.MetricsToMetricsStability),
+	)
+}
+
+// metricsToMetricsCreator returns the function creating connectors that emit the
+// inferred metrics of the metrics they consume, with the given processor options.
+func metricsToMetricsCreator(opts []metricsinferenceprocessor.FactoryOption) connector.CreateMetricsToMetricsFunc {
+	return func(
+		ctx context.Context,
+		set connector.Settings,
+		cfg component.Config,
+		nextConsumer consumer.Metrics,
+	) (connector.Metrics, error) {
+		return createMetricsToMetrics(ctx, set, cfg, nextConsumer, opts...)
+	}
+}
+
+// createMetricsToMetrics creates a connector that emits the inferred metrics of
+// the metrics it consumes.
+func createMetricsToMetrics(
+	ctx context.Context,
+	set connector.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+	opts ...metricsinferenceprocessor.FactoryOption,
+) (connector.Metrics, error) {
+	connCfg, ok := cfg.(*Config)
+	if !ok {
+		return nil, fmt.Errorf("configuration parsing error")
+	}
+
+	// The inference itself is done by the processor, set to pass on its results
+	// without the metrics they were inferred from
+	procCfg := *connCfg
+	procCfg.Emit = metricsinferenceprocessor.EmitOutputsOnly
+	factory := metricsinferenceprocessor.NewFactory(opts...)
+	proc, err := factory.CreateMetrics(ctx, processor.Settings{
+		ID:                component.NewIDWithName(factory.Type(), set.ID.Name()),
+		TelemetrySettings: set.TelemetrySettings,
+		BuildInfo:         set.BuildInfo,
+	}, &procCfg, nextConsumer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inference connector: %w", err)
+	}
+	return &inferenceConnector{processor: proc}, nil
+}
@@ -8,3 +8,5 @@ go 1.23.4
 // Replace references to modules that are in this repository with their relative paths
 // so that we always build with current (latest) version of the source code.
 replace github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor => ./processor/metricsinferenceprocessor
+
+replace github.com/rbellamy/opentelemetry-inference/connector/inferenceconnector => ./connector/inferenceconnector
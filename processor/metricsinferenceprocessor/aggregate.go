@@ -0,0 +1,196 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// validateRuleAggregate checks a rule's aggregation
+func validateRuleAggregate(rule Rule) error {
+	cfg := rule.Aggregate
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Function == "" {
+		return errors.New("missing required field \"function\"")
+	}
+	if err := validateAggregate(cfg.Function); err != nil {
+		return err
+	}
+	for _, key := range cfg.GroupBy {
+		if key == "" {
+			return errors.New("group_by keys must not be empty")
+		}
+	}
+
+	switch {
+	case len(rule.Inputs) == 0:
+		return errors.New("aggregate requires inputs")
+	case len(rule.Outputs) > 1:
+		return fmt.Errorf("aggregate produces a single output, got %d", len(rule.Outputs))
+	case rule.Backend != "" || rule.Synthetic != nil:
+		return errors.New("aggregate cannot be combined with a backend or synthetic")
+	case rule.ModelSelector != nil:
+		return errors.New("aggregate cannot be combined with model_selector")
+	case rule.Sequence.Enabled:
+		return errors.New("aggregate does not support sequences")
+	case rule.Trigger.Mode == triggerModeInterval:
+		return errors.New("aggregate cannot be combined with an interval trigger")
+	case rule.ExpandBy != "":
+		return errors.New("aggregate cannot be combined with expand_by")
+	case rule.Mode == ruleModeShadow || rule.Challenger != nil:
+		return errors.New("aggregate cannot be combined with shadow mode or a challenger")
+	}
+	return nil
+}
+
+// aggregation combines the values of a rule's inputs into one composite metric,
+// without inference
+type aggregation struct {
+	function string
+	groupBy  []string
+}
+
+// newAggregation converts a rule's aggregation, or returns nil when the rule infers
+func newAggregation(cfg *AggregateConfig) *aggregation {
+	if cfg == nil {
+		return nil
+	}
+	return &aggregation{function: cfg.Function, groupBy: cfg.GroupBy}
+}
+
+// aggregateGroup is the values combined into one output data point
+type aggregateGroup struct {
+	attributes pcommon.Map
+	values     []float64
+	timestamp  pcommon.Timestamp // Latest timestamp of the values
+}
+
+// groupKey returns the key of the group a data point belongs to, and the
+// attributes of that group. Attributes copied from an input by an earlier rule
+// are namespaced by that input, so a key is also found as "<input>.<key>".
+func (a *aggregation) groupKey(attrs pcommon.Map) (string, pcommon.Map) {
+	group := pcommon.NewMap()
+	for _, key := range a.groupBy {
+		if value, ok := lookupGroupAttribute(attrs, key); ok {
+			value.CopyTo(group.PutEmpty(key))
+		}
+	}
+	return attributeSetKey(group), group
+}
+
+// lookupGroupAttribute finds an attribute by key, or by a key namespaced with an
+// input name
+func lookupGroupAttribute(attrs pcommon.Map, key string) (pcommon.Value, bool) {
+	if value, ok := attrs.Get(key); ok {
+		return value, true
+	}
+	var found pcommon.Value
+	ok := false
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if strings.HasSuffix(k, "."+key) {
+			found, ok = v, true
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// applyAggregation adds the output of an aggregation rule to every resource of
+// the batch holding its inputs: one data point per group of the input data
+// points, combining their values, at the latest of their timestamps so values
+// produced by different rules do not skew apart.
+func (mp *metricsinferenceprocessor) applyAggregation(md pmetric.Metrics, resources []resourceMetricIndex, ruleIdx int, budget *outputBudget) {
+	rule := mp.rules[ruleIdx]
+	for _, part := range splitResources(resources, rule) {
+		ruleCtx := mp.collectRuleInputs(part, ruleIdx, nil)
+		if len(ruleCtx.inputs) == 0 {
+			mp.logLimiter.Warn(ruleIdx, "No input metrics found for aggregation rule",
+				zap.Int("rule_index", ruleIdx),
+				zap.Strings("expected_inputs", rule.inputs))
+			continue
+		}
+		ruleCtx.budget = budget
+
+		groups := make(map[string]*aggregateGroup)
+		for _, input := range rule.inputs {
+			metric, exists := ruleCtx.inputs[input]
+			if !exists {
+				continue
+			}
+			dps, ok := numberDataPoints(metric)
+			if !ok {
+				continue
+			}
+			for i := 0; i < dps.Len(); i++ {
+				dp := dps.At(i)
+				if dp.Flags().NoRecordedValue() {
+					continue
+				}
+				key, attributes := rule.aggregate.groupKey(dp.Attributes())
+				group, exists := groups[key]
+				if !exists {
+					group = &aggregateGroup{attributes: attributes}
+					groups[key] = group
+				}
+				group.values = append(group.values, dataPointValue(dp))
+				group.timestamp = max(group.timestamp, dp.Timestamp())
+			}
+		}
+		if len(groups) == 0 {
+			continue
+		}
+
+		sm, err := mp.outputScopeMetrics(md, ruleCtx)
+		if err != nil {
+			mp.logLimiter.Error(ruleIdx, "Failed to add aggregation output",
+				zap.Int("rule_index", ruleIdx),
+				zap.Error(err))
+			continue
+		}
+		first := sm.Metrics().Len()
+		output := rule.outputs[0]
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(mp.outputMetricName(&rule, 0, output, ""))
+		metric.SetUnit(output.unit)
+		if output.description != "" {
+			metric.SetDescription(mp.outputDescription(&rule, 0, output, ""))
+		} else {
+			metric.SetDescription(fmt.Sprintf("%s of %s", rule.aggregate.function, strings.Join(rule.inputs, ", ")))
+		}
+
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dps := metric.SetEmptyGauge().DataPoints()
+		for _, key := range keys {
+			group := groups[key]
+			dp := dps.AppendEmpty()
+			group.attributes.CopyTo(dp.Attributes())
+			if rule.inferenceLabels && rule.modelName != "" {
+				putModelLabels(dp.Attributes(), &rule)
+			}
+			if rule.route != "" {
+				dp.Attributes().PutStr(labelRoute, rule.route)
+			}
+			dp.SetTimestamp(group.timestamp)
+			dp.SetDoubleValue(aggregateValues(group.values, rule.aggregate.function))
+		}
+
+		mp.sanitizeOutputs(sm.Metrics(), first, ruleIdx, rule.modelName)
+		mp.limitOutputs(sm.Metrics(), first, outputResource(md, ruleCtx), ruleCtx)
+		mp.recordOutputSeries(md, ruleCtx, sm, first)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAggregationRule(t *testing.T) {
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{
+			{
+				ModelName:     "load",
+				Inputs:        []string{"requests", "errors"},
+				OutputPattern: "load",
+				Backend:       backendBuiltin,
+				Builtin:       &BuiltinConfig{Operation: operationAdd},
+			},
+			{
+				// Combines the output of the rule above with one of its inputs
+				Inputs:        []string{"load", "errors"},
+				OutputPattern: "host.load.max",
+				Aggregate:     &AggregateConfig{Function: aggregateMax, GroupBy: []string{"host.name"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), hostBatch(0, 2)))
+	md := sink.AllMetrics()[0]
+	metric := findMetricByName(md, "host.load.max")
+	require.Equal(t, "max of load, errors", metric.Description())
+
+	// One data point per host, grouped by the namespaced attribute of the chained output
+	dps := metric.Gauge().DataPoints()
+	require.Equal(t, 3, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		assert.Equal(t, map[string]any{"host.name": []string{"host-0", "host-1", "host-2"}[i]}, dps.At(i).Attributes().AsRaw())
+		assert.Equal(t, float64(2*i), dps.At(i).DoubleValue())
+	}
+}
+
+func TestAggregationRuleWithoutGroupBy(t *testing.T) {
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{{
+			Inputs:    []string{"requests", "errors"},
+			Aggregate: &AggregateConfig{Function: aggregateSum},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+	require.Equal(t, "sum", processor.rules[0].outputs[0].name)
+
+	// All values of the resource are combined into one data point
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), hostBatch(1, 3)))
+	md := sink.AllMetrics()[0]
+	require.Equal(t, 3, md.MetricCount())
+	metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(2)
+	require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+	assert.Equal(t, 12.0, metric.Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, 0, metric.Gauge().DataPoints().At(0).Attributes().Len())
+}
+
+func TestValidateRuleAggregate(t *testing.T) {
+	rule := func(modify func(*Rule)) Rule {
+		rule := Rule{
+			Inputs:    []string{"cpu.score", "memory.score"},
+			Aggregate: &AggregateConfig{Function: aggregateMax, GroupBy: []string{"host.name"}},
+		}
+		modify(&rule)
+		return rule
+	}
+
+	assert.NoError(t, validateRuleAggregate(rule(func(*Rule) {})))
+	assert.NoError(t, validateRuleAggregate(Rule{ModelName: "scorer"}))
+
+	tests := []struct {
+		name     string
+		modify   func(*Rule)
+		expected string
+	}{
+		{"missing function", func(r *Rule) { r.Aggregate.Function = "" }, "missing required field \"function\""},
+		{"unknown function", func(r *Rule) { r.Aggregate.Function = "median" }, "invalid aggregate \"median\""},
+		{"empty group_by key", func(r *Rule) { r.Aggregate.GroupBy = []string{""} }, "group_by keys must not be empty"},
+		{"no inputs", func(r *Rule) { r.Inputs = nil }, "aggregate requires inputs"},
+		{"several outputs", func(r *Rule) { r.Outputs = []OutputSpec{{Name: "a"}, {Name: "b"}} }, "single output"},
+		{"backend", func(r *Rule) { r.Backend = backendBuiltin }, "backend or synthetic"},
+		{"interval trigger", func(r *Rule) { r.Trigger.Mode = triggerModeInterval }, "interval trigger"},
+		{"shadow", func(r *Rule) { r.Mode = ruleModeShadow }, "shadow mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateRuleAggregate(rule(tt.modify)), tt.expected)
+		})
+	}
+
+	// Aggregation rules need no model
+	cfg := &Config{Rules: []Rule{rule(func(*Rule) {})}}
+	assert.NoError(t, cfg.Validate())
+}
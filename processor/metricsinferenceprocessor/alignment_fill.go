@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Fill policies for inputs that lack a data point within the timestamp tolerance
+const (
+	alignmentFillDrop    = "drop"   // skip the timestamp (default)
+	alignmentFillForward = "ffill"  // repeat the input's previous data point
+	alignmentFillLinear  = "linear" // interpolate between the input's neighbouring data points
+)
+
+// validateAlignmentFill checks the fill policy of timestamp alignment
+func validateAlignmentFill(fill string) error {
+	switch fill {
+	case "", alignmentFillDrop, alignmentFillForward, alignmentFillLinear:
+		return nil
+	default:
+		return fmt.Errorf("invalid fill %q (must be 'drop', 'ffill', or 'linear')", fill)
+	}
+}
+
+// fillAlignedGroup completes a group of aligned data points with the inputs it lacks,
+// reporting whether every input could be filled. series holds the data points of
+// each input sorted by timestamp.
+func fillAlignedGroup(group map[string]dataPoint, timestamp uint64, series map[string][]dataPoint, fill string) bool {
+	if fill != alignmentFillForward && fill != alignmentFillLinear {
+		return false
+	}
+	filled := make(map[string]dataPoint, len(series))
+	for name, dataPoints := range series {
+		if _, ok := group[name]; ok {
+			continue
+		}
+		// Index of the first data point after the group timestamp
+		next := sort.Search(len(dataPoints), func(i int) bool {
+			return uint64(dataPoints[i].Timestamp()) > timestamp
+		})
+		if next == 0 {
+			// Nothing to fill from before the group
+			return false
+		}
+		previous := dataPoints[next-1]
+		if fill == alignmentFillForward {
+			filled[name] = previous
+			continue
+		}
+		if next == len(dataPoints) {
+			// Interpolation needs a data point on either side
+			return false
+		}
+		filled[name] = interpolateDataPoint(previous, dataPoints[next], timestamp)
+	}
+	for name, dp := range filled {
+		group[name] = dp
+	}
+	return true
+}
+
+// interpolateDataPoint returns the linear interpolation of two gauge or sum data
+// points at a timestamp between them. Other data point types cannot be
+// interpolated, so the previous data point is repeated.
+func interpolateDataPoint(previous, next dataPoint, timestamp uint64) dataPoint {
+	from, ok := previous.(pmetric.NumberDataPoint)
+	if !ok {
+		return previous
+	}
+	to, ok := next.(pmetric.NumberDataPoint)
+	if !ok {
+		return previous
+	}
+	start, end := uint64(from.Timestamp()), uint64(to.Timestamp())
+	fraction := 0.0
+	if end > start {
+		fraction = float64(timestamp-start) / float64(end-start)
+	}
+
+	dp := pmetric.NewNumberDataPoint()
+	from.Attributes().CopyTo(dp.Attributes())
+	dp.SetStartTimestamp(from.StartTimestamp())
+	dp.SetTimestamp(pcommon.Timestamp(timestamp))
+	value := dataPointValue(from)
+	dp.SetDoubleValue(value + (dataPointValue(to)-value)*fraction)
+	return dp
+}
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// alignedValues returns the gauge values of aligned data points
+func alignedValues(dataPoints []dataPoint) []float64 {
+	values := make([]float64, len(dataPoints))
+	for i, dp := range dataPoints {
+		values[i] = dataPointValue(dp.(pmetric.NumberDataPoint))
+	}
+	return values
+}
+
+func TestAlignmentFill(t *testing.T) {
+	// memory is scraped half a second after cpu, so no timestamps align within tolerance
+	metrics := pmetric.NewMetricSlice()
+	start := time.Unix(1700000000, 0)
+	windowSeries(metrics, "cpu", start, 0, 10, 20)
+	windowSeries(metrics, "memory", start.Add(500*time.Millisecond), 100, 200, 300)
+	inputs := map[string]pmetric.Metric{"cpu": metrics.At(0), "memory": metrics.At(1)}
+
+	tests := []struct {
+		name   string
+		fill   string
+		cpu    []float64
+		memory []float64
+	}{
+		{name: "default"},
+		{name: "drop", fill: alignmentFillDrop},
+		// The first timestamp has no earlier memory data point to fill from
+		{name: "ffill", fill: alignmentFillForward, cpu: []float64{0, 10, 10, 20, 20}, memory: []float64{100, 100, 200, 200, 300}},
+		// The last timestamp has no later cpu data point to interpolate towards
+		{name: "linear", fill: alignmentFillLinear, cpu: []float64{5, 10, 15, 20}, memory: []float64{100, 150, 200, 250}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := &metricsinferenceprocessor{config: &Config{DataHandling: DataHandlingConfig{
+				Mode:               "all",
+				AlignTimestamps:    true,
+				TimestampTolerance: 100,
+				Fill:               tt.fill,
+			}}}
+			aligned, err := mp.alignDataPointsByTimestamp(inputs)
+			require.NoError(t, err)
+			if tt.cpu == nil {
+				// Incomplete timestamps are skipped
+				assert.Empty(t, aligned)
+				return
+			}
+			assert.Equal(t, tt.cpu, alignedValues(aligned["cpu"]))
+			assert.Equal(t, tt.memory, alignedValues(aligned["memory"]))
+		})
+	}
+}
+
+func TestValidateAlignmentFill(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x", "y"}}},
+	}
+	for _, fill := range []string{"", "drop", "ffill", "linear"} {
+		cfg.DataHandling.Fill = fill
+		assert.NoError(t, cfg.Validate())
+	}
+	cfg.DataHandling.Fill = "bfill"
+	assert.ErrorContains(t, cfg.Validate(), `invalid fill "bfill"`)
+}
@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// attachToInputs writes the values of an output, the metrics from metrics[first:],
+// as attributes named after their metric on the input data points of the batch
+// each value was inferred from: the data points of its matched group, or every
+// input data point for a single value. The data points a rule infers from may
+// be filtered or transformed copies, so the batch's data points are found by
+// metric name, attributes and timestamp. Inputs aggregated across attributes
+// have no such data point and are left as they are.
+func attachToInputs(context *modelContext, metrics pmetric.MetricSlice, first int) {
+	if !context.hasContext {
+		return
+	}
+	batch := indexBatchDataPoints(context.resourceMetrics)
+
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		dps, ok := numberDataPoints(metric)
+		if !ok {
+			continue
+		}
+		for row := 0; row < dps.Len(); row++ {
+			value := dps.At(row)
+			for inputIdx, inputName := range context.rule.inputs {
+				for _, source := range rowInputDataPoints(context, inputName, row, dps.Len()) {
+					key := inputDataPointKey(context.rule.inputSelectors[inputIdx], source)
+					for _, target := range batch[key] {
+						putNumberValue(target.Attributes(), metric.Name(), value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// rowInputDataPoints returns the data points of an input a row of output values
+// was inferred from
+func rowInputDataPoints(context *modelContext, inputName string, row, rows int) []dataPoint {
+	if len(context.matchedDataPoints) > 0 {
+		if row >= len(context.matchedDataPoints) {
+			return nil
+		}
+		if dp, exists := context.matchedDataPoints[row].dataPoints[inputName]; exists {
+			return []dataPoint{dp}
+		}
+		return nil
+	}
+	dataPoints := context.inputDataPoints[inputName]
+	switch {
+	case rows == 1:
+		return dataPoints
+	case len(dataPoints) == rows:
+		return dataPoints[row : row+1]
+	}
+	return nil
+}
+
+// indexBatchDataPoints indexes the data points of a resource by metric name,
+// attributes and timestamp
+func indexBatchDataPoints(rm pmetric.ResourceMetrics) map[string][]dataPoint {
+	index := make(map[string][]dataPoint)
+	for i := 0; i < rm.ScopeMetrics().Len(); i++ {
+		metrics := rm.ScopeMetrics().At(i).Metrics()
+		for j := 0; j < metrics.Len(); j++ {
+			metric := metrics.At(j)
+			for _, dp := range extractDataPoints(metric) {
+				key := dataPointKey(metric.Name(), dp.Attributes(), dp.Timestamp())
+				index[key] = append(index[key], dp)
+			}
+		}
+	}
+	return index
+}
+
+// inputDataPointKey returns the index key of the batch data point an input data
+// point was derived from. Data points merged by a name pattern carry their
+// source metric as an attribute the batch's data point does not have.
+func inputDataPointKey(selector *labelSelector, dp dataPoint) string {
+	if selector == nil || selector.namePattern == nil {
+		name := ""
+		if selector != nil {
+			name = selector.metricName
+		}
+		return dataPointKey(name, dp.Attributes(), dp.Timestamp())
+	}
+	source, _ := dp.Attributes().Get(sourceMetricAttr)
+	attrs := pcommon.NewMap()
+	dp.Attributes().CopyTo(attrs)
+	attrs.Remove(sourceMetricAttr)
+	return dataPointKey(source.AsString(), attrs, dp.Timestamp())
+}
+
+// dataPointKey identifies a data point by its metric name, attributes and timestamp
+func dataPointKey(name string, attrs pcommon.Map, timestamp pcommon.Timestamp) string {
+	return name + "\x00" + attributeSetKey(attrs) + "\x00" + strconv.FormatUint(uint64(timestamp), 10)
+}
+
+// putNumberValue sets an attribute to the value of a number data point
+func putNumberValue(attrs pcommon.Map, key string, dp pmetric.NumberDataPoint) {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		attrs.PutInt(key, dp.IntValue())
+		return
+	}
+	attrs.PutDouble(key, dp.DoubleValue())
+}
@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// startAttachingProcessor starts a processor with one rule attaching its
+// "anomaly.score" output to its inputs, answered with the given scores
+func startAttachingProcessor(t *testing.T, inputs []string, scores ...float64) (*metricsinferenceprocessor, *consumertest.MetricsSink) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("detector", &pb.ModelInferResponse{
+			ModelName: "detector",
+			Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+				Name:     "score",
+				Datatype: "FP64",
+				Shape:    []int64{int64(len(scores))},
+				Contents: &pb.InferTensorContents{Fp64Contents: scores},
+			}},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "detector",
+			Inputs:        inputs,
+			OutputPattern: "anomaly.{output}",
+			Outputs:       []OutputSpec{{Name: "score", AttachToInput: true}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor, sink
+}
+
+func TestAttachToInputMatchedGroups(t *testing.T) {
+	processor, sink := startAttachingProcessor(t, []string{"cpu", "memory"}, 0.9, 0.1)
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "cpu", DataPoints: []testutil.TestDataPoint{
+			{Value: 0.5, Attributes: map[string]string{"host.name": "a"}},
+			{Value: 0.7, Attributes: map[string]string{"host.name": "b"}},
+		}},
+		{MetricName: "memory", DataPoints: []testutil.TestDataPoint{
+			{Value: 50, Attributes: map[string]string{"host.name": "a"}},
+			{Value: 70, Attributes: map[string]string{"host.name": "b"}},
+		}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+	output := sink.AllMetrics()[0]
+
+	// The output is still emitted, and each host's inputs carry its score
+	scores := map[string]float64{}
+	dps := findMetricByName(output, "anomaly.score").Gauge().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		host, _ := dps.At(i).Attributes().Get("cpu.host.name")
+		scores[host.Str()] = dps.At(i).DoubleValue()
+	}
+	assert.ElementsMatch(t, []float64{0.9, 0.1}, []float64{scores["a"], scores["b"]})
+
+	for _, name := range []string{"cpu", "memory"} {
+		inputs := findMetricByName(output, name).Gauge().DataPoints()
+		for i := 0; i < inputs.Len(); i++ {
+			host, _ := inputs.At(i).Attributes().Get("host.name")
+			score, exists := inputs.At(i).Attributes().Get("anomaly.score")
+			require.True(t, exists, "%s of host %s", name, host.Str())
+			assert.Equal(t, scores[host.Str()], score.Double())
+		}
+	}
+}
+
+func TestAttachToInputSelectedDataPoints(t *testing.T) {
+	processor, sink := startAttachingProcessor(t, []string{`cpu{state="busy"}`}, 0.93)
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "cpu", DataPoints: []testutil.TestDataPoint{
+			{Value: 0.5, Attributes: map[string]string{"state": "busy"}},
+			{Value: 0.4, Attributes: map[string]string{"state": "idle"}},
+		}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// Only the selected data point is enriched
+	inputs := findMetricByName(sink.AllMetrics()[0], "cpu").Gauge().DataPoints()
+	enriched := map[string]bool{}
+	for i := 0; i < inputs.Len(); i++ {
+		state, _ := inputs.At(i).Attributes().Get("state")
+		score, exists := inputs.At(i).Attributes().Get("anomaly.score")
+		enriched[state.Str()] = exists
+		if exists {
+			assert.Equal(t, 0.93, score.Double())
+		}
+	}
+	assert.Equal(t, map[string]bool{"busy": true, "idle": false}, enriched)
+}
+
+func TestInputDataPointKey(t *testing.T) {
+	selector, err := parseLabelSelector(`{__name__=~"system\\.cpu\\..*"}`)
+	require.NoError(t, err)
+
+	merged := pmetric.NewNumberDataPoint()
+	merged.SetTimestamp(1000)
+	merged.Attributes().PutStr("cpu", "0")
+	merged.Attributes().PutStr(sourceMetricAttr, "system.cpu.time")
+
+	original := pmetric.NewNumberDataPoint()
+	original.SetTimestamp(1000)
+	original.Attributes().PutStr("cpu", "0")
+	assert.Equal(t, dataPointKey("system.cpu.time", original.Attributes(), 1000), inputDataPointKey(selector, merged))
+}
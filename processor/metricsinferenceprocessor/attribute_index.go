@@ -0,0 +1,374 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// attributeIndexIdleBatches is the number of consecutive batches an attribute set
+// may be absent before its slot is released. Keeping slots for a few batches avoids
+// churn when series are briefly missing from a batch.
+const attributeIndexIdleBatches = 10
+
+// attributeIndexTTL is how long the attribute group index of a rule for a
+// resource no longer holding its inputs is kept
+const attributeIndexTTL = time.Hour
+
+// attributeGroupSlot is a known attribute set within an attributeGroupIndex
+type attributeGroupSlot struct {
+	hash     uint64
+	key      string      // Canonical key from attributeSetKey, used for ordering
+	attrs    pcommon.Map // Copy of the attribute set, used to resolve hash collisions
+	pos      int         // Position in the per-batch scratch slices
+	lastSeen uint64      // Batch generation in which the set was last observed
+}
+
+// attributeGroupIndex incrementally maps attribute-set hashes to group slots for a rule.
+// Canonical string keys are only built when a new attribute set appears, so steady-state
+// batches avoid rebuilding keys and maps for every data point.
+type attributeGroupIndex struct {
+	mu         sync.Mutex
+	slots      map[uint64][]*attributeGroupSlot // Hash -> slots (more than one only on collision)
+	ordered    []*attributeGroupSlot            // Slots sorted by key, rebuilt when membership changes
+	freePos    []int                            // Released scratch positions available for reuse
+	nextPos    int
+	generation uint64
+	dirty      bool
+	skipped    int       // Cartesian combinations left out by the group limit, until taken
+	lastUsed   time.Time // Last match for the resource, guarded by the processor's attributeIndexLock
+}
+
+// newAttributeGroupIndex creates an empty attribute group index
+func newAttributeGroupIndex() *attributeGroupIndex {
+	return &attributeGroupIndex{
+		slots: make(map[uint64][]*attributeGroupSlot),
+	}
+}
+
+// attributeSetHash computes an order-independent hash of an attribute set.
+// Values are hashed by their string form to match attributeSetKey semantics.
+func attributeSetHash(attrs pcommon.Map) uint64 {
+	var sum uint64
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		h := fnvAddString(fnvOffset64, k)
+		h = fnvAddString(h, "\x00")
+		sum += fnvAddString(h, v.AsString())
+		return true
+	})
+	return sum
+}
+
+// FNV-1a parameters, inlined to avoid allocating a hasher per attribute
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// fnvAddString folds s into an FNV-1a hash
+func fnvAddString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// attributeSetsMatch reports whether two attribute sets are equal by string values
+func attributeSetsMatch(a, b pcommon.Map) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	match := true
+	a.Range(func(k string, v pcommon.Value) bool {
+		other, exists := b.Get(k)
+		if !exists || other.AsString() != v.AsString() {
+			match = false
+		}
+		return match
+	})
+	return match
+}
+
+// slotFor returns the slot for an attribute set, creating it if the set is new.
+// The caller must hold idx.mu.
+func (idx *attributeGroupIndex) slotFor(attrs pcommon.Map) *attributeGroupSlot {
+	hash := attributeSetHash(attrs)
+	for _, slot := range idx.slots[hash] {
+		if attributeSetsMatch(slot.attrs, attrs) {
+			return slot
+		}
+	}
+
+	slot := &attributeGroupSlot{
+		hash:  hash,
+		key:   attributeSetKey(attrs),
+		attrs: pcommon.NewMap(),
+	}
+	attrs.CopyTo(slot.attrs)
+
+	if n := len(idx.freePos); n > 0 {
+		slot.pos = idx.freePos[n-1]
+		idx.freePos = idx.freePos[:n-1]
+	} else {
+		slot.pos = idx.nextPos
+		idx.nextPos++
+	}
+
+	idx.slots[hash] = append(idx.slots[hash], slot)
+	idx.dirty = true
+	return slot
+}
+
+// releaseIdleSlots drops slots whose attribute sets have not been seen recently.
+// The caller must hold idx.mu.
+func (idx *attributeGroupIndex) releaseIdleSlots() {
+	for hash, slots := range idx.slots {
+		kept := slots[:0]
+		for _, slot := range slots {
+			if idx.generation-slot.lastSeen >= attributeIndexIdleBatches {
+				idx.freePos = append(idx.freePos, slot.pos)
+				idx.dirty = true
+				continue
+			}
+			kept = append(kept, slot)
+		}
+		if len(kept) == 0 {
+			delete(idx.slots, hash)
+		} else {
+			idx.slots[hash] = kept
+		}
+	}
+
+	if idx.dirty {
+		idx.ordered = idx.ordered[:0]
+		for _, slots := range idx.slots {
+			idx.ordered = append(idx.ordered, slots...)
+		}
+		sort.Slice(idx.ordered, func(i, j int) bool {
+			return idx.ordered[i].key < idx.ordered[j].key
+		})
+		idx.dirty = false
+	}
+}
+
+// inputSlots holds the first data point of each attribute set of one input in a batch
+type inputSlots struct {
+	name       string
+	dataPoints []dataPoint // Indexed by slot position
+	present    []bool
+	first      *attributeGroupSlot // First slot observed, used for broadcast
+	groupCount int
+}
+
+// has reports whether the input has a data point with the slot's attribute set
+func (in *inputSlots) has(slot *attributeGroupSlot) bool {
+	return slot.pos < len(in.present) && in.present[slot.pos]
+}
+
+// match groups the data points of a rule's inputs by attribute set using the rule's
+// matching strategy. With broadcast semantics, it produces the same groups, in the
+// same order, as matchDataPointsByAttributes.
+func (idx *attributeGroupIndex) match(inputs map[string]pmetric.Metric, rule internalRule) []dataPointGroup {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.generation++
+
+	// Step 1: Assign each data point to its slot, keeping the first data point per slot
+	var perInput []*inputSlots
+	for _, inputName := range rule.inputs {
+		metric, exists := inputs[inputName]
+		if !exists {
+			continue
+		}
+		in := &inputSlots{name: inputName}
+		for _, dp := range extractDataPoints(metric) {
+			slot := idx.slotFor(dp.Attributes())
+			slot.lastSeen = idx.generation
+			for len(in.present) <= slot.pos {
+				in.present = append(in.present, false)
+				in.dataPoints = append(in.dataPoints, nil)
+			}
+			if !in.present[slot.pos] {
+				in.present[slot.pos] = true
+				in.dataPoints[slot.pos] = dp
+				in.groupCount++
+				if in.first == nil {
+					in.first = slot
+				}
+			}
+		}
+		if in.groupCount > 0 {
+			perInput = append(perInput, in)
+		}
+	}
+
+	idx.releaseIdleSlots()
+
+	strategy := rule.matchingStrategy()
+	if strategy == matchingCartesian {
+		return idx.cartesianGroups(perInput, inputs, rule)
+	}
+
+	// Step 2: Split inputs into broadcast candidates and discriminating inputs.
+	// Strict matching broadcasts nothing, so every input is discriminating.
+	var multi, single []*inputSlots
+	for _, in := range perInput {
+		if in.groupCount == 1 && strategy == matchingBroadcast {
+			single = append(single, in)
+		} else {
+			multi = append(multi, in)
+		}
+	}
+
+	// Step 3: Determine target slots, in key order
+	var targets []*attributeGroupSlot
+	if len(multi) > 0 && !rule.fillsPartialGroups() {
+		for _, slot := range idx.ordered {
+			inAll := true
+			for _, in := range multi {
+				if !in.has(slot) {
+					inAll = false
+					break
+				}
+			}
+			if inAll {
+				targets = append(targets, slot)
+			}
+		}
+	}
+
+	// If no common attribute sets, or groups lacking inputs are filled, use all
+	// attribute sets of discriminating inputs
+	if len(multi) > 0 && len(targets) == 0 {
+		for _, slot := range idx.ordered {
+			for _, in := range multi {
+				if in.has(slot) {
+					targets = append(targets, slot)
+					break
+				}
+			}
+		}
+	}
+
+	// Step 4: Create matched groups using broadcast semantics
+	var matchedGroups []dataPointGroup
+	buildGroup := func(slot *attributeGroupSlot) {
+		group := dataPointGroup{
+			attributes: pcommon.NewMap(),
+			dataPoints: make(map[string]dataPoint, len(perInput)),
+		}
+		for _, in := range multi {
+			if in.has(slot) {
+				dp := in.dataPoints[slot.pos]
+				group.dataPoints[in.name] = dp
+				if group.attributes.Len() == 0 {
+					dp.Attributes().CopyTo(group.attributes)
+				}
+			}
+		}
+		for _, in := range single {
+			dp := in.dataPoints[in.first.pos]
+			group.dataPoints[in.name] = dp
+			if len(multi) == 0 && group.attributes.Len() == 0 {
+				dp.Attributes().CopyTo(group.attributes)
+			}
+		}
+		attrs := group.attributes
+		if slot != nil {
+			attrs = slot.attrs
+		}
+		if fillPartialGroup(group, attrs, inputs, rule) {
+			matchedGroups = append(matchedGroups, group)
+		}
+	}
+
+	if len(multi) == 0 {
+		// All inputs have single groups - a single broadcast group
+		buildGroup(nil)
+	} else {
+		for _, slot := range targets {
+			buildGroup(slot)
+		}
+	}
+
+	return matchedGroups
+}
+
+// attributeIndexKey identifies the incremental index of a rule for one resource
+type attributeIndexKey struct {
+	ruleIdx  int
+	resource string // Resource attribute set key
+}
+
+// matchDataPoints groups data points for a rule using the rule's incremental index
+// for the resource of its inputs, or the index of the attribute value an expanded
+// rule infers for. Attribute groups a cartesian rule left out for its group limit
+// are counted and logged.
+func (mp *metricsinferenceprocessor) matchDataPoints(ruleCtx *modelContext, inputs map[string]pmetric.Metric, rule internalRule) []dataPointGroup {
+	var idx *attributeGroupIndex
+	if ruleCtx.expansion != nil {
+		idx = ruleCtx.expansion.index
+	} else {
+		key := attributeIndexKey{ruleIdx: ruleCtx.ruleIndex}
+		if ruleCtx.hasContext {
+			key.resource = attributeSetKey(ruleCtx.resourceMetrics.Resource().Attributes())
+		}
+		now := time.Now()
+		mp.attributeIndexLock.Lock()
+		mp.pruneAttributeIndexes(now)
+		var exists bool
+		idx, exists = mp.attributeIndexes[key]
+		if !exists {
+			idx = newAttributeGroupIndex()
+			mp.attributeIndexes[key] = idx
+		}
+		idx.lastUsed = now
+		mp.attributeIndexLock.Unlock()
+	}
+
+	groups := idx.match(inputs, rule)
+	if skipped := idx.takeSkipped(); skipped > 0 {
+		mp.telemetry.recordTruncatedDataPoints(context.Background(), rule.modelName, truncatedMaxCartesianGroups, skipped)
+		mp.logLimiter.Warn(ruleCtx.ruleIndex, "Cartesian rule exceeded its attribute group limit, leaving combinations out",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", ruleCtx.ruleIndex),
+			zap.Int("max_cartesian_groups", rule.maxCartesian),
+			zap.Int("count", skipped))
+	}
+	return groups
+}
+
+// pruneAttributeIndexes forgets the indexes of resources not matched within
+// attributeIndexTTL, so resources that come and go, such as short-lived
+// containers, do not accumulate. It checks at most once per TTL. The caller
+// must hold mp.attributeIndexLock.
+func (mp *metricsinferenceprocessor) pruneAttributeIndexes(now time.Time) {
+	if now.Sub(mp.lastAttributeIndexPrune) < attributeIndexTTL {
+		return
+	}
+	mp.lastAttributeIndexPrune = now
+	for key, idx := range mp.attributeIndexes {
+		if now.Sub(idx.lastUsed) > attributeIndexTTL {
+			delete(mp.attributeIndexes, key)
+		}
+	}
+}
+
+// takeSkipped returns the cartesian combinations left out since it was last called
+func (idx *attributeGroupIndex) takeSkipped() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	skipped := idx.skipped
+	idx.skipped = 0
+	return skipped
+}
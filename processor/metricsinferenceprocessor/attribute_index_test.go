@@ -0,0 +1,183 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// newAttributedGauge creates a gauge with one data point per attribute set
+func newAttributedGauge(name string, attrSets []map[string]string) pmetric.Metric {
+	metric := pmetric.NewMetric()
+	metric.SetName(name)
+	gauge := metric.SetEmptyGauge()
+	for i, attrs := range attrSets {
+		dp := gauge.DataPoints().AppendEmpty()
+		dp.SetDoubleValue(float64(i))
+		for k, v := range attrs {
+			dp.Attributes().PutStr(k, v)
+		}
+	}
+	return metric
+}
+
+// groupsSummary reduces matched groups to comparable values
+func groupsSummary(groups []dataPointGroup) []map[string]interface{} {
+	summary := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		entry := map[string]interface{}{"_attrs": attributeSetKey(group.attributes)}
+		for name, dp := range group.dataPoints {
+			entry[name] = attributeSetKey(dp.Attributes()) + fmt.Sprintf("=%v", dp.(pmetric.NumberDataPoint).DoubleValue())
+		}
+		summary = append(summary, entry)
+	}
+	return summary
+}
+
+func TestAttributeGroupIndexMatchesLegacyPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs map[string][]map[string]string
+	}{
+		{
+			name: "single input without attributes",
+			inputs: map[string][]map[string]string{
+				"a": {{}},
+			},
+		},
+		{
+			name: "matching attribute sets",
+			inputs: map[string][]map[string]string{
+				"a": {{"cpu": "0"}, {"cpu": "1"}, {"cpu": "2"}},
+				"b": {{"cpu": "2"}, {"cpu": "0"}, {"cpu": "1"}},
+			},
+		},
+		{
+			name: "broadcast single group input",
+			inputs: map[string][]map[string]string{
+				"usage": {{"state": "used"}, {"state": "free"}, {"state": "cached"}},
+				"limit": {{"host": "h1"}},
+			},
+		},
+		{
+			name: "partial overlap",
+			inputs: map[string][]map[string]string{
+				"a": {{"cpu": "0"}, {"cpu": "1"}},
+				"b": {{"cpu": "1"}, {"cpu": "2"}},
+			},
+		},
+		{
+			name: "disjoint attribute sets",
+			inputs: map[string][]map[string]string{
+				"a": {{"x": "1"}, {"x": "2"}},
+				"b": {{"y": "1"}, {"y": "2"}},
+			},
+		},
+		{
+			name: "duplicate data points keep the first",
+			inputs: map[string][]map[string]string{
+				"a": {{"cpu": "0"}, {"cpu": "0"}, {"cpu": "1"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := internalRule{}
+			inputs := make(map[string]pmetric.Metric)
+			for name, attrSets := range tt.inputs {
+				rule.inputs = append(rule.inputs, name)
+				inputs[name] = newAttributedGauge(name, attrSets)
+			}
+
+			expected := groupsSummary(matchDataPointsByAttributes(inputs, rule))
+			idx := newAttributeGroupIndex()
+			// Run several batches so the steady-state path is exercised too
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, expected, groupsSummary(idx.match(inputs, rule)), "batch %d", i)
+			}
+		})
+	}
+}
+
+func TestAttributeGroupIndexReleasesIdleSeries(t *testing.T) {
+	rule := internalRule{inputs: []string{"a"}}
+	idx := newAttributeGroupIndex()
+
+	first := map[string]pmetric.Metric{"a": newAttributedGauge("a", []map[string]string{{"pod": "p1"}, {"pod": "p2"}})}
+	second := map[string]pmetric.Metric{"a": newAttributedGauge("a", []map[string]string{{"pod": "p2"}, {"pod": "p3"}})}
+
+	require.Len(t, idx.match(first, rule), 2)
+	for i := 0; i < attributeIndexIdleBatches; i++ {
+		require.Len(t, idx.match(second, rule), 2)
+	}
+
+	assert.Len(t, idx.ordered, 2, "the p1 slot should be released after going idle")
+	for _, slot := range idx.ordered {
+		assert.NotEqual(t, "pod=p1", slot.key)
+	}
+}
+
+func TestAttributeSetHashIsOrderIndependent(t *testing.T) {
+	a := pcommon.NewMap()
+	a.PutStr("x", "1")
+	a.PutStr("y", "2")
+	b := pcommon.NewMap()
+	b.PutStr("y", "2")
+	b.PutStr("x", "1")
+
+	assert.Equal(t, attributeSetHash(a), attributeSetHash(b))
+	assert.True(t, attributeSetsMatch(a, b))
+
+	b.PutStr("y", "3")
+	assert.NotEqual(t, attributeSetHash(a), attributeSetHash(b))
+	assert.False(t, attributeSetsMatch(a, b))
+}
+
+// benchmarkMatchingInputs builds two high-cardinality inputs plus a broadcast input
+func benchmarkMatchingInputs(series int) (map[string]pmetric.Metric, internalRule) {
+	attrSets := make([]map[string]string, series)
+	for i := range attrSets {
+		attrSets[i] = map[string]string{
+			"host.name": fmt.Sprintf("host-%d", i%50),
+			"container": fmt.Sprintf("container-%d", i),
+			"namespace": "production",
+		}
+	}
+	inputs := map[string]pmetric.Metric{
+		"usage":   newAttributedGauge("usage", attrSets),
+		"request": newAttributedGauge("request", attrSets),
+		"limit":   newAttributedGauge("limit", []map[string]string{{"cluster": "c1"}}),
+	}
+	return inputs, internalRule{inputs: []string{"usage", "request", "limit"}}
+}
+
+func BenchmarkMatchDataPointsByAttributes(b *testing.B) {
+	for _, series := range []int{100, 1000, 10000} {
+		inputs, rule := benchmarkMatchingInputs(series)
+
+		b.Run(fmt.Sprintf("legacy/%d", series), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = matchDataPointsByAttributes(inputs, rule)
+			}
+		})
+
+		b.Run(fmt.Sprintf("indexed/%d", series), func(b *testing.B) {
+			idx := newAttributeGroupIndex()
+			_ = idx.match(inputs, rule) // Warm the index as in steady state
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = idx.match(inputs, rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// What happens to an attribute value before it is sent to the inference service
+const (
+	privacyKeep   = "keep"   // send the value as is (default)
+	privacyHash   = "hash"   // send a keyed hash of the value
+	privacyRedact = "redact" // send redactedValue instead of the value
+)
+
+// redactedValue stands in for redacted attribute values
+const redactedValue = "[redacted]"
+
+// hashedValueBytes is the length of the hash sent for hashed values, 128 bits
+// being plenty to tell the entities of a deployment apart
+const hashedValueBytes = 16
+
+// attributePrivacy is the internal form of a rule's attribute privacy
+// configuration. A nil attributePrivacy sends every value as is.
+type attributePrivacy struct {
+	actions   map[string]string // Action by attribute key
+	resources string            // Action for the other resource attributes
+	key       []byte            // HMAC key of hashed values, nil for plain SHA-256
+}
+
+// newAttributePrivacy converts a rule's attribute privacy configuration. It
+// returns nil when the rule sends every value as is.
+func newAttributePrivacy(cfg *AttributePrivacyConfig) *attributePrivacy {
+	if cfg == nil {
+		return nil
+	}
+	privacy := &attributePrivacy{
+		actions:   make(map[string]string, len(cfg.Hash)+len(cfg.Redact)),
+		resources: cfg.ResourceAttributes,
+	}
+	for _, key := range cfg.Hash {
+		privacy.actions[key] = privacyHash
+	}
+	for _, key := range cfg.Redact {
+		privacy.actions[key] = privacyRedact
+	}
+	if privacy.resources == "" {
+		privacy.resources = privacyKeep
+	}
+	if cfg.Key != "" {
+		privacy.key = []byte(cfg.Key)
+	}
+	return privacy
+}
+
+// validateAttributePrivacy checks a rule's attribute privacy configuration
+func validateAttributePrivacy(cfg *AttributePrivacyConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.ResourceAttributes {
+	case "", privacyKeep, privacyHash, privacyRedact:
+	default:
+		return fmt.Errorf("invalid resource_attributes %q (must be 'keep', 'hash', or 'redact')", cfg.ResourceAttributes)
+	}
+	if len(cfg.Hash) == 0 && len(cfg.Redact) == 0 && (cfg.ResourceAttributes == "" || cfg.ResourceAttributes == privacyKeep) {
+		return errors.New("hash, redact or resource_attributes is required")
+	}
+
+	seen := make(map[string]bool, len(cfg.Hash)+len(cfg.Redact))
+	for _, key := range append(append([]string{}, cfg.Hash...), cfg.Redact...) {
+		if key == "" {
+			return errors.New("attribute keys must not be empty")
+		}
+		if seen[key] {
+			return fmt.Errorf("attribute %q is listed more than once", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// value returns the form of an attribute value sent to the inference service.
+// Attributes not listed by key follow the policy for resource attributes when
+// they are read from the resource, and are sent as is otherwise.
+func (p *attributePrivacy) value(key string, value pcommon.Value, resource bool) string {
+	return p.apply(key, value.AsString(), resource)
+}
+
+// apply returns the form of an attribute value, already rendered as a string,
+// sent to the inference service
+func (p *attributePrivacy) apply(key, value string, resource bool) string {
+	if p == nil {
+		return value
+	}
+	action, listed := p.actions[key]
+	if !listed {
+		action = privacyKeep
+		if resource {
+			action = p.resources
+		}
+	}
+	switch action {
+	case privacyHash:
+		return p.hash(key, value)
+	case privacyRedact:
+		return redactedValue
+	}
+	return value
+}
+
+// hash returns the hex-encoded hash of an attribute value. The key of the
+// attribute is hashed along with it, so equal values of different attributes
+// cannot be linked.
+func (p *attributePrivacy) hash(key, value string) string {
+	var h hash.Hash
+	if p.key != nil {
+		h = hmac.New(sha256.New, p.key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil)[:hashedValueBytes])
+}
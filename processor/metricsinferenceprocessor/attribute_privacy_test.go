@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestAttributePrivacy(t *testing.T) {
+	var none *attributePrivacy
+	assert.Equal(t, "node-1", none.apply("host.name", "node-1", true))
+
+	privacy := newAttributePrivacy(&AttributePrivacyConfig{
+		Hash:   []string{"host.name", "user.id"},
+		Redact: []string{"user.email"},
+	})
+	hashed := privacy.apply("host.name", "node-1", true)
+	assert.Len(t, hashed, 2*hashedValueBytes)
+	assert.NotContains(t, hashed, "node-1")
+	assert.Equal(t, hashed, privacy.apply("host.name", "node-1", false), "hashes are stable")
+	assert.NotEqual(t, hashed, privacy.apply("host.name", "node-2", true))
+	assert.NotEqual(t, hashed, privacy.apply("user.id", "node-1", false), "equal values of other attributes are not linked")
+	assert.Equal(t, redactedValue, privacy.apply("user.email", "ops@example.com", false))
+	assert.Equal(t, "eu-west-1", privacy.apply("cloud.region", "eu-west-1", true), "other attributes are kept by default")
+
+	// A key changes every hash
+	keyed := newAttributePrivacy(&AttributePrivacyConfig{Hash: []string{"host.name"}, Key: "secret"})
+	assert.NotEqual(t, hashed, keyed.apply("host.name", "node-1", true))
+
+	// Other resource attributes follow resource_attributes, other attributes are kept
+	resources := newAttributePrivacy(&AttributePrivacyConfig{Redact: []string{"user.email"}, ResourceAttributes: privacyHash})
+	assert.Equal(t, hashed, resources.apply("host.name", "node-1", true))
+	assert.Equal(t, "0", resources.apply("cpu", "0", false))
+	assert.Equal(t, redactedValue, resources.apply("user.email", "ops@example.com", true))
+}
+
+func TestValidateAttributePrivacy(t *testing.T) {
+	assert.NoError(t, validateAttributePrivacy(nil))
+	assert.NoError(t, validateAttributePrivacy(&AttributePrivacyConfig{Hash: []string{"host.name"}}))
+	assert.NoError(t, validateAttributePrivacy(&AttributePrivacyConfig{ResourceAttributes: privacyRedact}))
+
+	tests := []struct {
+		name   string
+		cfg    *AttributePrivacyConfig
+		errMsg string
+	}{
+		{"nothing protected", &AttributePrivacyConfig{ResourceAttributes: privacyKeep}, "is required"},
+		{"invalid resource policy", &AttributePrivacyConfig{ResourceAttributes: "drop"}, "invalid resource_attributes"},
+		{"empty key", &AttributePrivacyConfig{Hash: []string{""}}, "must not be empty"},
+		{"hashed and redacted", &AttributePrivacyConfig{Hash: []string{"host.name"}, Redact: []string{"host.name"}}, "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateAttributePrivacy(tt.cfg), tt.errMsg)
+		})
+	}
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{{
+			ModelName:        "scorer",
+			Inputs:           []string{"cpu"},
+			AttributePrivacy: &AttributePrivacyConfig{},
+		}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid attribute_privacy in rule 0")
+}
+
+func TestAttributePrivacyRequests(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	privacy := &AttributePrivacyConfig{
+		Redact:             []string{"user.email"},
+		ResourceAttributes: privacyHash,
+		Key:                "secret",
+	}
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{
+			Endpoint: mockServer.Endpoint(),
+			Headers:  map[string]string{"x-scope-orgid": "{resource:tenant.id}"},
+		},
+		Timeout: 5,
+		Rules: []Rule{{
+			ModelName:  "scorer",
+			Inputs:     []string{"cpu", "memory"},
+			Outputs:    []OutputSpec{{Name: "score"}},
+			Parameters: map[string]interface{}{"context": "{attr:cpu}/{attr:user.email}"},
+			ForwardAttributes: []ForwardAttributeConfig{
+				{Key: "host.name"},
+				{Key: "tenant.id", As: forwardAsTensor},
+			},
+			AttributePrivacy: privacy,
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	attrs := map[string]string{"cpu": "0", "user.email": "ops@example.com"}
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "cpu", DataPoints: []testutil.TestDataPoint{{Value: 0.5, Attributes: attrs}}},
+		{MetricName: "memory", DataPoints: []testutil.TestDataPoint{{Value: 0.7, Attributes: attrs}}},
+	})
+	resource := md.ResourceMetrics().At(0).Resource()
+	resource.Attributes().PutStr("host.name", "node-1")
+	resource.Attributes().PutStr("tenant.id", "acme")
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	request := requests[0]
+	expected := newAttributePrivacy(privacy)
+
+	// Resource identifiers are hashed everywhere they are sent
+	assert.Equal(t, expected.apply("host.name", "node-1", true), request.Parameters["host.name"].GetStringParam())
+	var tenant []byte
+	for _, input := range request.Inputs {
+		if input.Name == "tenant.id" {
+			tenant = input.Contents.BytesContents[0]
+		}
+	}
+	assert.Equal(t, expected.apply("tenant.id", "acme", true), string(tenant))
+	headers := mockServer.GetRequestMetadata()
+	require.Len(t, headers, 1)
+	assert.Equal(t, []string{expected.apply("tenant.id", "acme", true)}, headers[0].Get("x-scope-orgid"))
+
+	// Data point attributes are kept unless listed
+	assert.Equal(t, "0/"+redactedValue, request.Parameters["context"].GetStringParam())
+}
@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+)
+
+// Defaults of a rule's auto_disable settings
+const (
+	defaultAutoDisableFailureRatio = 0.5
+	defaultAutoDisableWindow       = 5 * time.Minute
+	defaultAutoDisableMinRequests  = 10
+	defaultAutoDisableCooldown     = time.Minute
+)
+
+// skipReasonAutoDisabled is the skip reason of rules left out while auto-disabled
+const skipReasonAutoDisabled = "auto_disabled"
+
+// Changes of a rule's auto-disable state caused by the outcome of one of its calls
+const (
+	autoDisableUnchanged   = iota
+	autoDisableTripped     // the rule failed too often and was disabled
+	autoDisableProbeFailed // the probe failed and the rule stays disabled
+	autoDisableRecovered   // the probe succeeded and the rule was enabled again
+)
+
+// validateAutoDisable checks a rule's auto_disable settings
+func validateAutoDisable(rule Rule) error {
+	cfg := rule.AutoDisable
+	if cfg == nil {
+		return nil
+	}
+	if rule.Aggregate != nil {
+		return errors.New("auto_disable does not apply to aggregation rules, which do not infer")
+	}
+	if cfg.FailureRatio < 0 || cfg.FailureRatio >= 1 {
+		return fmt.Errorf("failure_ratio must be in [0, 1), got %v", cfg.FailureRatio)
+	}
+	if cfg.Window < 0 {
+		return errors.New("window must not be negative")
+	}
+	if cfg.MinRequests < 0 {
+		return errors.New("min_requests must not be negative")
+	}
+	if cfg.Cooldown < 0 {
+		return errors.New("cooldown must not be negative")
+	}
+	return nil
+}
+
+// callOutcome is the result of one of a rule's calls within the sliding window
+type callOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// autoDisabler disables a rule whose calls fail too often for a cool-down
+// period, then lets a single probe through: the rule is enabled again when the
+// probe succeeds, and disabled for another cool-down when it fails
+type autoDisabler struct {
+	failureRatio float64
+	window       time.Duration
+	minRequests  int
+	cooldown     time.Duration
+
+	mu            sync.Mutex
+	outcomes      []callOutcome // Outcomes of the calls in the window, oldest first
+	disabledUntil time.Time     // End of the cool-down, zero while the rule is enabled
+	probing       bool          // Whether a probe was let through and its outcome is pending
+}
+
+// newAutoDisabler converts a rule's auto_disable settings, applying defaults.
+// It returns nil when the rule is never disabled.
+func newAutoDisabler(cfg *AutoDisableConfig) *autoDisabler {
+	if cfg == nil {
+		return nil
+	}
+	d := &autoDisabler{
+		failureRatio: cfg.FailureRatio,
+		window:       cfg.Window,
+		minRequests:  cfg.MinRequests,
+		cooldown:     cfg.Cooldown,
+	}
+	if d.failureRatio == 0 {
+		d.failureRatio = defaultAutoDisableFailureRatio
+	}
+	if d.window == 0 {
+		d.window = defaultAutoDisableWindow
+	}
+	if d.minRequests == 0 {
+		d.minRequests = defaultAutoDisableMinRequests
+	}
+	if d.cooldown == 0 {
+		d.cooldown = defaultAutoDisableCooldown
+	}
+	return d
+}
+
+// allow reports whether the rule may infer at now. Once the cool-down is over a
+// probe is let through, and the next probe waits for another cool-down, so a
+// probe whose outcome never comes, as when the rule found no inputs, is retried.
+func (d *autoDisabler) allow(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.disabledUntil.IsZero() {
+		return true
+	}
+	if now.Before(d.disabledUntil) {
+		return false
+	}
+	d.disabledUntil = now.Add(d.cooldown)
+	d.probing = true
+	return true
+}
+
+// record adds the outcome of a call of the rule at now, and returns how the
+// rule's state changed. Outcomes of calls completing while the rule is disabled,
+// other than the probe's, are ignored.
+func (d *autoDisabler) record(now time.Time, failed bool) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.probing {
+		d.probing = false
+		if failed {
+			d.disabledUntil = now.Add(d.cooldown)
+			return autoDisableProbeFailed
+		}
+		d.disabledUntil = time.Time{}
+		return autoDisableRecovered
+	}
+	if !d.disabledUntil.IsZero() {
+		return autoDisableUnchanged
+	}
+
+	d.outcomes = append(d.outcomes, callOutcome{at: now, failed: failed})
+	start := now.Add(-d.window)
+	expired := 0
+	for expired < len(d.outcomes) && d.outcomes[expired].at.Before(start) {
+		expired++
+	}
+	d.outcomes = d.outcomes[expired:]
+
+	if len(d.outcomes) < d.minRequests || d.ratio() <= d.failureRatio {
+		return autoDisableUnchanged
+	}
+	d.outcomes = nil
+	d.disabledUntil = now.Add(d.cooldown)
+	return autoDisableTripped
+}
+
+// disabled reports whether the rule is disabled, including while its probe runs
+func (d *autoDisabler) disabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.disabledUntil.IsZero()
+}
+
+// ratio returns the fraction of failed calls in the window
+func (d *autoDisabler) ratio() float64 {
+	failures := 0
+	for _, outcome := range d.outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(d.outcomes))
+}
+
+// autoDisabled reports whether a rule is left out of the current batch because
+// it is auto-disabled, counting the skip
+func (mp *metricsinferenceprocessor) autoDisabled(ctx context.Context, ruleIdx int) bool {
+	rule := mp.rules[ruleIdx]
+	if rule.autoDisable == nil || rule.autoDisable.allow(time.Now()) {
+		return false
+	}
+	mp.telemetry.recordSkippedInference(ctx, rule.modelName, skipReasonAutoDisabled)
+	return true
+}
+
+// recordCallOutcome feeds the outcome of a rule's call to its auto-disabler,
+// and reports the rule being disabled or enabled again through logs, telemetry
+// and the component status. Skipped calls are not fed to it, as they say
+// nothing of the model's health.
+func (mp *metricsinferenceprocessor) recordCallOutcome(ctx context.Context, call *ruleCall, err error) {
+	rule := call.ctx.rule
+	if rule.autoDisable == nil {
+		return
+	}
+
+	switch rule.autoDisable.record(time.Now(), err != nil) {
+	case autoDisableTripped:
+		mp.logger.Warn("Disabling failing inference rule for its cool-down",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Duration("cooldown", rule.autoDisable.cooldown),
+			zap.Error(err))
+		mp.telemetry.recordAutoDisabledRule(ctx, rule.id, 1)
+		mp.autoDisabledRules.Add(1)
+		mp.reportStatus(componentstatus.NewRecoverableErrorEvent(
+			fmt.Errorf("rule %d (model %s) disabled after failing more than %.0f%% of its calls: %w", call.ruleIdx, rule.modelName, 100*rule.autoDisable.failureRatio, err)))
+	case autoDisableProbeFailed:
+		mp.logger.Warn("Probe of disabled inference rule failed, keeping it disabled",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Duration("cooldown", rule.autoDisable.cooldown),
+			zap.Error(err))
+	case autoDisableRecovered:
+		mp.logger.Info("Probe of disabled inference rule succeeded, enabling it again",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx))
+		mp.telemetry.recordAutoDisabledRule(ctx, rule.id, -1)
+		if mp.autoDisabledRules.Add(-1) == 0 {
+			mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestAutoDisabler(t *testing.T) {
+	d := newAutoDisabler(&AutoDisableConfig{FailureRatio: 0.5, Window: time.Minute, MinRequests: 4, Cooldown: 10 * time.Second})
+	now := time.Now()
+
+	// Failing half the calls is not more than the ratio
+	for i, failed := range []bool{true, false, true} {
+		assert.Equal(t, autoDisableUnchanged, d.record(now.Add(time.Duration(i)*time.Second), failed))
+	}
+	assert.Equal(t, autoDisableUnchanged, d.record(now.Add(3*time.Second), false))
+	assert.Equal(t, autoDisableTripped, d.record(now.Add(4*time.Second), true), "3 of 5 calls failed")
+	assert.Equal(t, autoDisableUnchanged, d.record(now.Add(5*time.Second), true), "outcomes are ignored while disabled")
+
+	// The cool-down ends with a single probe
+	assert.False(t, d.allow(now.Add(10*time.Second)))
+	assert.True(t, d.allow(now.Add(15*time.Second)))
+	assert.False(t, d.allow(now.Add(16*time.Second)), "a single probe runs at a time")
+	assert.Equal(t, autoDisableProbeFailed, d.record(now.Add(16*time.Second), true))
+	assert.False(t, d.allow(now.Add(20*time.Second)))
+	assert.True(t, d.allow(now.Add(26*time.Second)))
+	assert.Equal(t, autoDisableRecovered, d.record(now.Add(27*time.Second), false))
+	assert.True(t, d.allow(now.Add(28*time.Second)))
+
+	// Outcomes older than the window do not count
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, autoDisableUnchanged, d.record(now.Add(30*time.Second), true))
+	}
+	assert.Equal(t, autoDisableUnchanged, d.record(now.Add(2*time.Minute), true))
+
+	defaults := newAutoDisabler(&AutoDisableConfig{})
+	assert.Equal(t, defaultAutoDisableFailureRatio, defaults.failureRatio)
+	assert.Equal(t, defaultAutoDisableWindow, defaults.window)
+	assert.Equal(t, defaultAutoDisableMinRequests, defaults.minRequests)
+	assert.Equal(t, defaultAutoDisableCooldown, defaults.cooldown)
+	assert.Nil(t, newAutoDisabler(nil))
+}
+
+func TestValidateAutoDisable(t *testing.T) {
+	assert.NoError(t, validateAutoDisable(Rule{}))
+	assert.NoError(t, validateAutoDisable(Rule{AutoDisable: &AutoDisableConfig{FailureRatio: 0.2, Window: time.Minute}}))
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		errMsg string
+	}{
+		{"ratio of 1", Rule{AutoDisable: &AutoDisableConfig{FailureRatio: 1}}, "failure_ratio"},
+		{"negative window", Rule{AutoDisable: &AutoDisableConfig{Window: -time.Second}}, "window"},
+		{"negative min requests", Rule{AutoDisable: &AutoDisableConfig{MinRequests: -1}}, "min_requests"},
+		{"negative cooldown", Rule{AutoDisable: &AutoDisableConfig{Cooldown: -time.Second}}, "cooldown"},
+		{"aggregation", Rule{AutoDisable: &AutoDisableConfig{}, Aggregate: &AggregateConfig{}}, "aggregation rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateAutoDisable(tt.rule), tt.errMsg)
+		})
+	}
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"cpu"}, AutoDisable: &AutoDisableConfig{FailureRatio: 2}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid auto_disable in rule 0")
+}
+
+func TestAutoDisableFailingRule(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelError("scorer", status.Error(codes.Unavailable, "model not loaded")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "{model}.{output}",
+			Outputs:       []OutputSpec{{Name: "score"}},
+			AutoDisable:   &AutoDisableConfig{MinRequests: 2, Cooldown: time.Hour},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	consume := func() {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"cpu"},
+			MetricValues: [][]float64{{0.5}},
+		})
+		_ = processor.ConsumeMetrics(context.Background(), input)
+	}
+
+	// Two failed calls disable the rule, which then sends no requests
+	consume()
+	consume()
+	require.Len(t, mockServer.GetRequests(), 2)
+	assert.Equal(t, int32(1), processor.autoDisabledRules.Load())
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 2)
+	assert.Len(t, sink.AllMetrics(), 3, "batches pass through while the rule is disabled")
+
+	// Once the cool-down ends, a successful probe enables the rule again
+	mockServer.Reset()
+	mockServer.SetModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1))
+	processor.rules[0].autoDisable.mu.Lock()
+	processor.rules[0].autoDisable.disabledUntil = time.Now().Add(-time.Second)
+	processor.rules[0].autoDisable.mu.Unlock()
+	consume()
+	require.Len(t, mockServer.GetRequests(), 1)
+	assert.Equal(t, int32(0), processor.autoDisabledRules.Load())
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 2)
+	assert.Equal(t, 1.0, findMetricByName(sink.AllMetrics()[4], "scorer.score").Gauge().DataPoints().At(0).DoubleValue())
+}
+
+func TestAutoDisableIntervalRule(t *testing.T) {
+	processor, _, mockServer := startIntervalProcessor(t, time.Hour)
+	processor.rules[0].autoDisable = newAutoDisabler(&AutoDisableConfig{MinRequests: 1, Cooldown: time.Hour})
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+
+	// A failed call disables the rule, whose ticks then send no requests
+	mockServer.Reset()
+	mockServer.SetModelError("forecaster", status.Error(codes.Unavailable, "model not loaded"))
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start, 1)))
+	processor.inferInterval(ctx, 0)
+	require.Len(t, mockServer.GetRequests(), 1)
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(time.Second), 2)))
+	processor.inferInterval(ctx, 0)
+	assert.Len(t, mockServer.GetRequests(), 1)
+
+	// Once the cool-down ends, batches arriving before the tick leave the probe
+	// to the tick, whose success enables the rule again
+	mockServer.Reset()
+	mockServer.SetModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 0.5))
+	processor.rules[0].autoDisable.mu.Lock()
+	processor.rules[0].autoDisable.disabledUntil = time.Now().Add(-time.Second)
+	processor.rules[0].autoDisable.mu.Unlock()
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(2*time.Second), 3)))
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(3*time.Second), 4)))
+	processor.inferInterval(ctx, 0)
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, []float64{3, 4}, requests[0].Inputs[0].Contents.Fp64Contents)
+	assert.False(t, processor.rules[0].autoDisable.disabled())
+}
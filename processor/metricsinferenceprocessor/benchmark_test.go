@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// newBenchmarkProcessor starts a processor running the rules in-process, so the
+// benchmark measures the processor rather than the network
+func newBenchmarkProcessor(b *testing.B, rules ...Rule) *metricsinferenceprocessor {
+	cfg := &Config{Timeout: 5, Rules: rules}
+	require.NoError(b, cfg.Validate())
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zap.NewNop())
+	require.NoError(b, err)
+	require.NoError(b, processor.Start(context.Background(), nil))
+	b.Cleanup(func() {
+		require.NoError(b, processor.Shutdown(context.Background()))
+	})
+	return processor
+}
+
+func BenchmarkProcessMetrics(b *testing.B) {
+	names := testutil.ScaleMetricNames(testutil.ScaleSpec{Metrics: 2})
+	factor := 2.0
+	cases := []struct {
+		name string
+		rule Rule
+	}{
+		{
+			// One input: every series is an attribute group of its own
+			name: "single_input",
+			rule: Rule{
+				ModelName: "scale",
+				Inputs:    names[:1],
+				Backend:   backendBuiltin,
+				Builtin:   &BuiltinConfig{Operation: operationScale, Operand: &factor},
+			},
+		},
+		{
+			// Two inputs: series are matched across metrics by attribute set
+			name: "matched_inputs",
+			rule: Rule{
+				ModelName: "add",
+				Inputs:    names,
+				Backend:   backendBuiltin,
+				Builtin:   &BuiltinConfig{Operation: operationAdd},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			processor := newBenchmarkProcessor(b, tc.rule)
+			testutil.RunScaleBenchmarks(b, testutil.DefaultScaleSpecs, func(md pmetric.Metrics) error {
+				return processor.processMetrics(context.Background(), md)
+			})
+		})
+	}
+}
+
+func BenchmarkTensorEncoding(b *testing.B) {
+	settings := EncoderSettings{DataHandling: createDefaultConfig().(*Config).DataHandling}
+	for _, spec := range testutil.DefaultScaleSpecs {
+		metric := testutil.GenerateScaleMetrics(spec).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		dataPoints := extractDataPoints(metric)
+
+		b.Run("metric/"+spec.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := encodeGauge(metric.Name(), metric, settings); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("data_points/"+spec.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := dataPointsToTensor(metric.Name(), dataPoints, settings); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
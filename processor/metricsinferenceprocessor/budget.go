@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// latencySmoothing is the weight of the latest call in a model's expected latency
+const latencySmoothing = 0.2
+
+// errInsufficientBudget fails calls that would not finish within the time left
+// to the batch. It wraps context.DeadlineExceeded, as the call would have run out of time.
+var errInsufficientBudget = fmt.Errorf("remaining time budget is shorter than the model's expected latency: %w", context.DeadlineExceeded)
+
+// pipelineDeadlineKey marks batch contexts bounded by the deadline of the
+// context the batch was consumed with, rather than by the maximum batch delay
+type pipelineDeadlineKey struct{}
+
+// latencyEstimates tracks the expected latency of every model, a moving average
+// of the latencies of its completed calls. Calls skipped for their expected
+// latency decay it, so a model is measured again once the estimate fits the
+// budget, rather than skipped forever after a slow spell.
+type latencyEstimates struct {
+	mu        sync.Mutex
+	latencies map[string]time.Duration // Model name -> expected latency
+}
+
+func newLatencyEstimates() *latencyEstimates {
+	return &latencyEstimates{latencies: make(map[string]time.Duration)}
+}
+
+// record adds the latency of a completed call of a model to its expected latency
+func (e *latencyEstimates) record(modelName string, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	expected, ok := e.latencies[modelName]
+	if !ok {
+		e.latencies[modelName] = latency
+		return
+	}
+	e.latencies[modelName] = expected + time.Duration(latencySmoothing*float64(latency-expected))
+}
+
+// decay lowers the expected latency of a model whose call was skipped for it,
+// as a completed call without latency would
+func (e *latencyEstimates) decay(modelName string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if expected, ok := e.latencies[modelName]; ok {
+		e.latencies[modelName] = expected - time.Duration(latencySmoothing*float64(expected))
+	}
+}
+
+// expected returns the expected latency of a model, zero before its first call completes
+func (e *latencyEstimates) expected(modelName string) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latencies[modelName]
+}
+
+// checkBudget fails a call of a model when the time left before ctx's deadline
+// is shorter than the model's expected latency, so calls bound to run out of
+// time are skipped instead of occupying the server. Each skip decays the
+// expected latency.
+func (mp *metricsinferenceprocessor) checkBudget(ctx context.Context, modelName string) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	expected := mp.latencies.expected(modelName)
+	if remaining := time.Until(deadline); expected > 0 && remaining < expected {
+		mp.latencies.decay(modelName)
+		return fmt.Errorf("%w (%s left, %s expected)", errInsufficientBudget, remaining.Round(time.Millisecond), expected.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// boundedByPipeline reports whether a batch context's deadline is the deadline of
+// the context the batch was consumed with
+func boundedByPipeline(batchCtx context.Context) bool {
+	bounded, _ := batchCtx.Value(pipelineDeadlineKey{}).(bool)
+	return bounded
+}
@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// operationScale multiplies the single input of a builtin rule by its operand
+const operationScale = "scale"
+
+// builtinBackend computes derived metrics with simple arithmetic in-process
+// instead of calling the inference server. The first input is the left
+// operand; the second input, or the configured constant, is the right one.
+type builtinBackend struct {
+	operation string
+	operand   float64
+	left      string
+	right     string // Second input, empty when the operand is a constant
+}
+
+// newBuiltinBackend creates a builtin backend from a rule's configuration
+func newBuiltinBackend(cfg *BuiltinConfig, inputs []string) *builtinBackend {
+	b := &builtinBackend{operation: cfg.Operation, left: inputs[0]}
+	if cfg.Operation == operationScale {
+		b.operation = operationMultiply
+	}
+	if cfg.Operand != nil {
+		b.operand = *cfg.Operand
+	} else {
+		b.right = inputs[1]
+	}
+	return b
+}
+
+// validateBuiltinBackend checks a rule's builtin configuration
+func validateBuiltinBackend(rule Rule) error {
+	if rule.Backend != backendBuiltin {
+		if rule.Builtin != nil {
+			return errors.New("builtin requires backend 'builtin'")
+		}
+		return nil
+	}
+
+	cfg := rule.Builtin
+	if cfg == nil {
+		return errors.New("backend 'builtin' requires a builtin configuration")
+	}
+	if rule.Synthetic != nil {
+		return errors.New("backend 'builtin' cannot be combined with synthetic")
+	}
+	if rule.Sequence.Enabled {
+		return errors.New("backend 'builtin' does not support sequences")
+	}
+	if len(rule.Outputs) > 1 {
+		return fmt.Errorf("backend 'builtin' produces a single output, got %d", len(rule.Outputs))
+	}
+
+	switch cfg.Operation {
+	case operationScale:
+		if len(rule.Inputs) != 1 || cfg.Operand == nil {
+			return errors.New("operation 'scale' requires exactly one input and an operand")
+		}
+	case operationAdd, operationSubtract, operationMultiply, operationDivide, operationPercent:
+		switch {
+		case cfg.Operand == nil && len(rule.Inputs) != 2:
+			return fmt.Errorf("operation %q requires two inputs, or one input and an operand, got %d inputs", cfg.Operation, len(rule.Inputs))
+		case cfg.Operand != nil && len(rule.Inputs) != 1:
+			return fmt.Errorf("operation %q with an operand requires exactly one input, got %d", cfg.Operation, len(rule.Inputs))
+		}
+	default:
+		return fmt.Errorf("invalid operation %q (must be 'add', 'subtract', 'multiply', 'divide', 'percent', or 'scale')", cfg.Operation)
+	}
+	return nil
+}
+
+// ModelInfer applies the operation to every element of the input tensors. A
+// right-hand input with a single element is broadcast to every element of the
+// left one. Elements the operation is undefined for, such as a division by
+// zero, are NaN, which the non_finite policy drops by default.
+func (b *builtinBackend) ModelInfer(_ context.Context, request *pb.ModelInferRequest, _ ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	left, err := requestInputValues(request, b.left)
+	if err != nil {
+		return nil, err
+	}
+	right := []float64{b.operand}
+	if b.right != "" {
+		if right, err = requestInputValues(request, b.right); err != nil {
+			return nil, err
+		}
+		if len(right) != 1 && len(right) != len(left) {
+			return nil, fmt.Errorf("input %q has %d elements, input %q has %d", b.left, len(left), b.right, len(right))
+		}
+	}
+
+	results := make([]float64, len(left))
+	for i, value := range left {
+		operand := right[0]
+		if len(right) > 1 {
+			operand = right[i]
+		}
+		result, err := calculateValue(value, operand, b.operation, request.ModelName)
+		if err != nil {
+			result = math.NaN()
+		}
+		results[i] = result
+	}
+
+	return &pb.ModelInferResponse{
+		ModelName:    request.ModelName,
+		ModelVersion: request.ModelVersion,
+		Id:           request.Id,
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{
+				Name:     "prediction",
+				Datatype: "FP64",
+				Shape:    []int64{int64(len(results))},
+				Contents: &pb.InferTensorContents{Fp64Contents: results},
+			},
+		},
+	}, nil
+}
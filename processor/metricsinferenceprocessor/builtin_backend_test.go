@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// builtinRequest builds a request with one FP64 tensor per named input
+func builtinRequest(inputs map[string][]float64) *pb.ModelInferRequest {
+	request := &pb.ModelInferRequest{ModelName: "derived"}
+	for name, values := range inputs {
+		request.Inputs = append(request.Inputs, &pb.ModelInferRequest_InferInputTensor{
+			Name:     name,
+			Datatype: "FP64",
+			Shape:    []int64{int64(len(values))},
+			Contents: &pb.InferTensorContents{Fp64Contents: values},
+		})
+	}
+	return request
+}
+
+func TestBuiltinBackendOperations(t *testing.T) {
+	operand := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name     string
+		cfg      BuiltinConfig
+		inputs   []string
+		values   map[string][]float64
+		expected []float64
+	}{
+		{
+			name:     "add inputs",
+			cfg:      BuiltinConfig{Operation: "add"},
+			inputs:   []string{"x", "y"},
+			values:   map[string][]float64{"x": {1, 2}, "y": {10, 20}},
+			expected: []float64{11, 22},
+		},
+		{
+			name:     "subtract broadcast input",
+			cfg:      BuiltinConfig{Operation: "subtract"},
+			inputs:   []string{"x", "y"},
+			values:   map[string][]float64{"x": {5, 7}, "y": {1}},
+			expected: []float64{4, 6},
+		},
+		{
+			name:     "percent",
+			cfg:      BuiltinConfig{Operation: "percent"},
+			inputs:   []string{"used", "total"},
+			values:   map[string][]float64{"used": {25, 1}, "total": {100, 0}},
+			expected: []float64{25, math.NaN()},
+		},
+		{
+			name:     "divide by constant",
+			cfg:      BuiltinConfig{Operation: "divide", Operand: operand(1024)},
+			inputs:   []string{"bytes"},
+			values:   map[string][]float64{"bytes": {2048}},
+			expected: []float64{2},
+		},
+		{
+			name:     "scale",
+			cfg:      BuiltinConfig{Operation: "scale", Operand: operand(0.5)},
+			inputs:   []string{"x"},
+			values:   map[string][]float64{"x": {4, 8}},
+			expected: []float64{2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newBuiltinBackend(&tt.cfg, tt.inputs)
+			response, err := backend.ModelInfer(context.Background(), builtinRequest(tt.values))
+			require.NoError(t, err)
+			require.Len(t, response.Outputs, 1)
+			values := response.Outputs[0].Contents.Fp64Contents
+			require.Len(t, values, len(tt.expected))
+			for i, expected := range tt.expected {
+				if math.IsNaN(expected) {
+					assert.True(t, math.IsNaN(values[i]))
+					continue
+				}
+				assert.Equal(t, expected, values[i])
+			}
+		})
+	}
+
+	// Inputs of different lengths cannot be combined element by element
+	backend := newBuiltinBackend(&BuiltinConfig{Operation: "add"}, []string{"x", "y"})
+	_, err := backend.ModelInfer(context.Background(), builtinRequest(map[string][]float64{"x": {1, 2, 3}, "y": {1, 2}}))
+	assert.ErrorContains(t, err, "elements")
+}
+
+func TestValidateBuiltinBackend(t *testing.T) {
+	operand := 2.0
+	builtin := func(cfg BuiltinConfig, inputs ...string) Rule {
+		return Rule{ModelName: "m", Inputs: inputs, Backend: "builtin", Builtin: &cfg}
+	}
+
+	assert.NoError(t, validateBuiltinBackend(Rule{ModelName: "m", Inputs: []string{"x"}}))
+	assert.NoError(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "add"}, "x", "y")))
+	assert.NoError(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "percent", Operand: &operand}, "x")))
+	assert.NoError(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "scale", Operand: &operand}, "x")))
+
+	assert.ErrorContains(t, validateBuiltinBackend(Rule{Builtin: &BuiltinConfig{Operation: "add"}}), "requires backend 'builtin'")
+	assert.ErrorContains(t, validateBuiltinBackend(Rule{Inputs: []string{"x"}, Backend: "builtin"}), "requires a builtin configuration")
+	assert.ErrorContains(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "modulo"}, "x", "y")), "invalid operation")
+	assert.ErrorContains(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "add"}, "x")), "requires two inputs")
+	assert.ErrorContains(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "add", Operand: &operand}, "x", "y")), "exactly one input")
+	assert.ErrorContains(t, validateBuiltinBackend(builtin(BuiltinConfig{Operation: "scale"}, "x")), "operand")
+
+	sequenced := builtin(BuiltinConfig{Operation: "add"}, "x", "y")
+	sequenced.Sequence.Enabled = true
+	assert.ErrorContains(t, validateBuiltinBackend(sequenced), "does not support sequences")
+
+	// Builtin-only configurations do not need an inference server
+	cfg := &Config{Rules: []Rule{builtin(BuiltinConfig{Operation: "add"}, "x", "y")}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestBuiltinRuleWithoutServer(t *testing.T) {
+	cfg := &Config{
+		Timeout:   5,
+		NonFinite: NonFiniteConfig{Policy: nonFinitePolicyDrop},
+		Rules: []Rule{
+			{
+				ModelName:     "disk_usage",
+				Inputs:        []string{"disk.used", "disk.total"},
+				OutputPattern: "disk.used_percent",
+				Backend:       "builtin",
+				Builtin:       &BuiltinConfig{Operation: "percent"},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for name, values := range map[string]map[string]float64{
+		"disk.used":  {"sda": 30, "sdb": 5},
+		"disk.total": {"sda": 120, "sdb": 0},
+	} {
+		metric := metrics.AppendEmpty()
+		metric.SetName(name)
+		dps := metric.SetEmptyGauge().DataPoints()
+		for _, device := range []string{"sda", "sdb"} {
+			dp := dps.AppendEmpty()
+			dp.Attributes().PutStr("device", device)
+			dp.SetDoubleValue(values[device])
+		}
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// The device with no capacity divides by zero and is dropped
+	output := findMetricByName(sink.AllMetrics()[0], "disk.used_percent")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	assert.Equal(t, 25.0, output.Gauge().DataPoints().At(0).DoubleValue())
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// cardinalitySeriesTTL is how long a series counted against max_series is
+// remembered after it was last produced. Series forgotten free room for new ones.
+const cardinalitySeriesTTL = time.Hour
+
+// defaultMaxCartesianGroups bounds the attribute groups of cartesian rules when
+// max_cartesian_groups is not set, as their number is the product of the
+// inputs' attribute sets
+const defaultMaxCartesianGroups = 10000
+
+// Reasons output data points are dropped by the cardinality limits
+const (
+	truncatedMaxDataPoints      = "max_data_points"
+	truncatedMaxSeries          = "max_series"
+	truncatedMaxCartesianGroups = "max_cartesian_groups" // counts attribute groups left out rather than data points
+)
+
+// validateCardinalityConfig checks the output cardinality limits
+func validateCardinalityConfig(cfg CardinalityConfig) error {
+	if cfg.MaxDataPoints < 0 {
+		return errors.New("max_data_points must not be negative")
+	}
+	if cfg.MaxSeries < 0 {
+		return errors.New("max_series must not be negative")
+	}
+	if cfg.MaxCartesianGroups < 0 {
+		return errors.New("max_cartesian_groups must not be negative")
+	}
+	return nil
+}
+
+// tracksOutputSeries reports whether the processor keeps state per output series,
+// for staleness markers or the max_series limit. Settings that put a value
+// changing with every call on output attributes would make each inference a new
+// series and grow that state without bound.
+func (cfg *Config) tracksOutputSeries() bool {
+	return cfg.Staleness.Period > 0 || cfg.Cardinality.MaxSeries > 0
+}
+
+// maxCartesianGroups returns the maximum number of attribute groups of a
+// cartesian rule, applying the default
+func (cfg CardinalityConfig) maxCartesianGroups() int {
+	if cfg.MaxCartesianGroups > 0 {
+		return cfg.MaxCartesianGroups
+	}
+	return defaultMaxCartesianGroups
+}
+
+// outputBudget counts the output data points every rule added to a batch, so no
+// rule adds more than max_data_points to it
+type outputBudget struct {
+	max  int
+	used map[int]int // Rule index -> data points added
+}
+
+// newOutputBudget creates the budget of a batch, or returns nil when the number
+// of data points is not limited
+func newOutputBudget(maxDataPoints int) *outputBudget {
+	if maxDataPoints <= 0 {
+		return nil
+	}
+	return &outputBudget{max: maxDataPoints, used: make(map[int]int)}
+}
+
+// spent reports whether a rule already added as many data points to the batch as allowed
+func (b *outputBudget) spent(ruleIdx int) bool {
+	return b != nil && b.used[ruleIdx] >= b.max
+}
+
+// take counts a data point a rule adds to the batch
+func (b *outputBudget) take(ruleIdx int) {
+	if b != nil {
+		b.used[ruleIdx]++
+	}
+}
+
+// seriesLimiter remembers the series every rule produced recently, so no rule
+// produces more than max_series distinct series
+type seriesLimiter struct {
+	mu        sync.Mutex
+	maxSeries int
+	series    map[int]map[string]time.Time // Rule index -> resource, metric and attribute set key -> last produced
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// newSeriesLimiter creates a limiter, or returns nil when the number of series is not limited
+func newSeriesLimiter(maxSeries int) *seriesLimiter {
+	if maxSeries <= 0 {
+		return nil
+	}
+	return &seriesLimiter{
+		maxSeries: maxSeries,
+		series:    make(map[int]map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// admit records a series as produced by a rule now. It returns false, without
+// recording it, for a new series when the rule already produces max_series.
+func (l *seriesLimiter) admit(ruleIdx int, key string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	series := l.series[ruleIdx]
+	if series == nil {
+		series = make(map[string]time.Time)
+		l.series[ruleIdx] = series
+	}
+	if _, exists := series[key]; !exists && len(series) >= l.maxSeries {
+		return false
+	}
+	series[key] = now
+	return true
+}
+
+// forgetRules forgets the series of the rules from index from on, whose rules
+// were replaced
+func (l *seriesLimiter) forgetRules(from int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ruleIdx := range l.series {
+		if ruleIdx >= from {
+			delete(l.series, ruleIdx)
+		}
+	}
+}
+
+// prune forgets series not produced within cardinalitySeriesTTL, checking at most
+// once per TTL. The caller must hold l.mu.
+func (l *seriesLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < cardinalitySeriesTTL {
+		return
+	}
+	l.lastPrune = now
+	for _, series := range l.series {
+		for key, produced := range series {
+			if now.Sub(produced) > cardinalitySeriesTTL {
+				delete(series, key)
+			}
+		}
+	}
+}
+
+// limitOutputs drops the data points of the metrics an output added from index
+// first on that exceed the cardinality limits: those of series beyond the rule's
+// max_data_points for the batch, and those of series beyond its max_series. Dropped data
+// points are logged and counted in telemetry.
+func (mp *metricsinferenceprocessor) limitOutputs(metrics pmetric.MetricSlice, first int, resource pcommon.Resource, ruleCtx *modelContext) {
+	if mp.seriesLimiter == nil && ruleCtx.budget == nil {
+		return
+	}
+
+	ruleIdx := ruleCtx.ruleIndex
+	prefix := attributeSetKey(resource.Attributes()) + "\x00"
+	dropped := make(map[string]int) // Reason -> data points
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		dps, ok := numberDataPoints(metric)
+		if !ok {
+			continue
+		}
+		dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			switch {
+			case ruleCtx.budget.spent(ruleIdx):
+				dropped[truncatedMaxDataPoints]++
+			case !mp.seriesLimiter.admit(ruleIdx, prefix+metric.Name()+"\x00"+attributeSetKey(dp.Attributes())):
+				dropped[truncatedMaxSeries]++
+			default:
+				ruleCtx.budget.take(ruleIdx)
+				return false
+			}
+			return true
+		})
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	removeEmptyOutputs(metrics, first)
+	for _, reason := range []string{truncatedMaxDataPoints, truncatedMaxSeries} {
+		count := dropped[reason]
+		if count == 0 {
+			continue
+		}
+		mp.telemetry.recordTruncatedDataPoints(context.Background(), ruleCtx.rule.modelName, reason, count)
+		mp.logLimiter.Warn(ruleIdx, "Rule exceeded an output cardinality limit, dropping data points",
+			zap.String("model", ruleCtx.rule.modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.String("limit", reason),
+			zap.Int("count", count))
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSeriesLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newSeriesLimiter(2)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.admit(0, "a"))
+	assert.True(t, limiter.admit(0, "b"))
+	assert.False(t, limiter.admit(0, "c"))
+
+	// Known series keep being produced, and other rules have their own limit
+	assert.True(t, limiter.admit(0, "a"))
+	assert.True(t, limiter.admit(1, "c"))
+
+	// Series no longer produced free room for new ones
+	now = now.Add(cardinalitySeriesTTL / 2)
+	assert.True(t, limiter.admit(0, "a"))
+	now = now.Add(cardinalitySeriesTTL)
+	assert.True(t, limiter.admit(0, "c"))
+	assert.False(t, limiter.admit(0, "d"))
+
+	assert.Nil(t, newSeriesLimiter(0))
+	assert.True(t, (*seriesLimiter)(nil).admit(0, "a"))
+}
+
+func TestOutputBudget(t *testing.T) {
+	budget := newOutputBudget(2)
+	for i := 0; i < 2; i++ {
+		assert.False(t, budget.spent(0))
+		budget.take(0)
+	}
+	assert.True(t, budget.spent(0))
+	assert.False(t, budget.spent(1))
+
+	assert.Nil(t, newOutputBudget(0))
+	assert.False(t, (*outputBudget)(nil).spent(0))
+}
+
+// hostBatch returns a batch with request and error gauges holding a data point
+// for each host from first to last
+func hostBatch(first, last int) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range []string{"requests", "errors"} {
+		metric := metrics.AppendEmpty()
+		metric.SetName(name)
+		dps := metric.SetEmptyGauge().DataPoints()
+		for i := first; i <= last; i++ {
+			dp := dps.AppendEmpty()
+			dp.Attributes().PutStr("host.name", fmt.Sprintf("host-%d", i))
+			dp.SetDoubleValue(float64(i))
+		}
+	}
+	return md
+}
+
+func TestCardinalityLimits(t *testing.T) {
+	cfg := &Config{
+		Timeout:     5,
+		Cardinality: CardinalityConfig{MaxDataPoints: 3, MaxSeries: 4},
+		Rules: []Rule{{
+			ModelName:     "total",
+			Inputs:        []string{"requests", "errors"},
+			OutputPattern: "requests.total",
+			Backend:       backendBuiltin,
+			Builtin:       &BuiltinConfig{Operation: operationAdd},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	reader := sdkmetric.NewManualReader()
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	hosts := func(md pmetric.Metrics) []string {
+		var hosts []string
+		dps := findMetricByName(md, "requests.total").Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			host, _ := dps.At(i).Attributes().Get("requests.host.name")
+			hosts = append(hosts, host.Str())
+		}
+		return hosts
+	}
+
+	// Data points beyond max_data_points are dropped from the batch
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), hostBatch(0, 5)))
+	assert.Equal(t, []string{"host-0", "host-1", "host-2"}, hosts(sink.AllMetrics()[0]))
+
+	// Series beyond max_series are dropped while the ones produced before continue
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), hostBatch(2, 5)))
+	assert.Equal(t, []string{"host-2", "host-3"}, hosts(sink.AllMetrics()[1]))
+
+	// The inputs themselves are left alone
+	assert.Equal(t, 4, findMetricByName(sink.AllMetrics()[1], "requests").Gauge().DataPoints().Len())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	truncated := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otelcol_processor_metricsinference_truncated_data_points" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				reason, _ := dp.Attributes.Value(telemetryAttrReason)
+				truncated[reason.AsString()] += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{truncatedMaxDataPoints: 3, truncatedMaxSeries: 2}, truncated)
+}
+
+func TestValidateCardinalityConfig(t *testing.T) {
+	assert.NoError(t, validateCardinalityConfig(CardinalityConfig{}))
+	assert.NoError(t, validateCardinalityConfig(CardinalityConfig{MaxDataPoints: 100, MaxSeries: 1000}))
+	assert.ErrorContains(t, validateCardinalityConfig(CardinalityConfig{MaxDataPoints: -1}), "max_data_points")
+	assert.ErrorContains(t, validateCardinalityConfig(CardinalityConfig{MaxSeries: -1}), "max_series")
+	assert.ErrorContains(t, validateCardinalityConfig(CardinalityConfig{MaxCartesianGroups: -1}), "max_cartesian_groups")
+	assert.Equal(t, defaultMaxCartesianGroups, CardinalityConfig{}.maxCartesianGroups())
+	assert.Equal(t, 50, CardinalityConfig{MaxCartesianGroups: 50}.maxCartesianGroups())
+}
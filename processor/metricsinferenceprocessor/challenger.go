@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Comparisons of a challenger model with the champion
+const (
+	comparisonDelta     = "delta"
+	comparisonAgreement = "agreement"
+)
+
+// labelChallengerModelName identifies the challenger model on comparison data points
+const labelChallengerModelName = "otel.inference.challenger.model.name"
+
+// challenger is the internal form of a rule's challenger configuration
+type challenger struct {
+	modelName    string
+	modelVersion string
+	comparison   string
+	tolerance    float64
+}
+
+// newChallenger converts a rule's challenger configuration, applying defaults.
+// It returns nil when the rule has no challenger.
+func newChallenger(cfg *ChallengerConfig) *challenger {
+	if cfg == nil {
+		return nil
+	}
+	c := &challenger{
+		modelName:    cfg.ModelName,
+		modelVersion: cfg.ModelVersion,
+		comparison:   cfg.Comparison,
+		tolerance:    cfg.Tolerance,
+	}
+	if c.comparison == "" {
+		c.comparison = comparisonDelta
+	}
+	return c
+}
+
+// validateChallenger checks a rule's challenger configuration
+func validateChallenger(rule Rule) error {
+	cfg := rule.Challenger
+	if cfg == nil {
+		return nil
+	}
+	if cfg.ModelName == "" {
+		return errors.New("model_name must not be empty")
+	}
+	if cfg.ModelName == rule.ModelName && cfg.ModelVersion == rule.ModelVersion {
+		return errors.New("challenger must differ from the rule's model")
+	}
+	switch cfg.Comparison {
+	case "", comparisonDelta, comparisonAgreement:
+	default:
+		return fmt.Errorf("invalid comparison %q (must be 'delta' or 'agreement')", cfg.Comparison)
+	}
+	if cfg.Tolerance < 0 {
+		return errors.New("tolerance must not be negative")
+	}
+	if rule.inProcess() {
+		return errors.New("challengers are served by the inference server and require the server backend")
+	}
+	if rule.Mode == ruleModeShadow {
+		return errors.New("challengers are not supported in shadow mode")
+	}
+	return nil
+}
+
+// challengerCall returns the call sending a rule's request to its challenger model
+func (mp *metricsinferenceprocessor) challengerCall(call *ruleCall) *ruleCall {
+	c := call.ctx.rule.challenger
+	request := proto.Clone(call.request).(*pb.ModelInferRequest)
+	request.ModelName = c.modelName
+	request.ModelVersion = c.modelVersion
+	// The challenger's request carries the champion's key under its own model
+	request.Id = c.modelName + strings.TrimPrefix(call.request.Id, call.ctx.rule.modelName)
+	mp.setIdempotencyKey(request)
+	return &ruleCall{ruleIdx: call.ruleIdx, ctx: call.ctx, request: request}
+}
+
+// compareChallenger adds a comparison metric for every output metric the champion
+// added to sm from index first on. The challenger's response is decoded like the
+// champion's, outside the batch, and data points are paired by position.
+func (mp *metricsinferenceprocessor) compareChallenger(ctx context.Context, md pmetric.Metrics, call *ruleCall, sm pmetric.ScopeMetrics, first int) {
+	rule := call.ctx.rule
+	c := rule.challenger
+	challengerCall := call.challenger
+	switch {
+	case challengerCall.skipped != "":
+		mp.logSkippedCall(ctx, challengerCall)
+		return
+	case challengerCall.err != nil:
+		mp.logLimiter.Error(call.ruleIdx, "Failed to perform challenger inference",
+			zap.String("model", rule.modelName),
+			zap.String("challenger", c.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Error(challengerCall.err))
+		return
+	}
+
+	// Fallbacks, sampling hints and input attributes belong to the champion's outputs only
+	challengerRule := rule
+	challengerRule.outputs = make([]internalOutputSpec, len(rule.outputs))
+	for i, output := range rule.outputs {
+		output.fallback = outputFallback{}
+		output.samplingHint = nil
+		output.attach = false
+		challengerRule.outputs[i] = output
+	}
+	scratch := pmetric.NewScopeMetrics()
+	challengerCtx := *call.ctx
+	challengerCtx.scopeMetrics = scratch
+	challengerCtx.inputScopes = nil
+	challengerCtx.hasContext = true
+	challengerCtx.budget = nil // Challenger outputs are compared, not added to the batch
+	if _, err := mp.processInferenceResponse(md, challengerRule, challengerCall.response, &challengerCtx); err != nil {
+		mp.logLimiter.Error(call.ruleIdx, "Failed to process challenger inference response",
+			zap.String("model", rule.modelName),
+			zap.String("challenger", c.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Error(err))
+		return
+	}
+	challengerMetrics := make(map[string]pmetric.Metric, scratch.Metrics().Len())
+	for i := 0; i < scratch.Metrics().Len(); i++ {
+		challengerMetrics[scratch.Metrics().At(i).Name()] = scratch.Metrics().At(i)
+	}
+
+	last := sm.Metrics().Len()
+	for i := first; i < last; i++ {
+		champion := sm.Metrics().At(i)
+		other, ok := challengerMetrics[champion.Name()]
+		if !ok {
+			continue // Not a model output, such as a sampling marker
+		}
+		championDPs, challengerDPs := extractNumberDataPoints(champion), extractNumberDataPoints(other)
+		if len(championDPs) != len(challengerDPs) {
+			mp.logLimiter.Warn(call.ruleIdx, "Challenger output does not match the champion's",
+				zap.String("model", rule.modelName),
+				zap.String("challenger", c.modelName),
+				zap.String("output_name", champion.Name()),
+				zap.Int("champion_data_points", len(championDPs)),
+				zap.Int("challenger_data_points", len(challengerDPs)))
+			continue
+		}
+		if len(championDPs) == 0 {
+			continue
+		}
+		c.addComparison(sm, champion, championDPs, challengerDPs, rule.modelName)
+	}
+}
+
+// addComparison appends the metric comparing the challenger's data points of an
+// output with the champion's
+func (c *challenger) addComparison(sm pmetric.ScopeMetrics, champion pmetric.Metric, championDPs, challengerDPs []pmetric.NumberDataPoint, championModel string) {
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName(champion.Name() + ".challenger_" + c.comparison)
+	if c.comparison == comparisonAgreement {
+		metric.SetDescription(fmt.Sprintf("1 while challenger %s agrees with champion %s on %s within %g", c.modelName, championModel, champion.Name(), c.tolerance))
+		metric.SetUnit("1")
+	} else {
+		metric.SetDescription(fmt.Sprintf("Challenger %s minus champion %s for %s", c.modelName, championModel, champion.Name()))
+		metric.SetUnit(champion.Unit())
+	}
+
+	dps := metric.SetEmptyGauge().DataPoints()
+	for i, championDP := range championDPs {
+		dp := dps.AppendEmpty()
+		championDP.Attributes().CopyTo(dp.Attributes())
+		dp.Attributes().PutStr(labelChallengerModelName, c.modelName)
+		dp.SetStartTimestamp(championDP.StartTimestamp())
+		dp.SetTimestamp(championDP.Timestamp())
+
+		delta := dataPointValue(challengerDPs[i]) - dataPointValue(championDP)
+		switch {
+		case c.comparison == comparisonDelta:
+			dp.SetDoubleValue(delta)
+		case math.Abs(delta) <= c.tolerance:
+			dp.SetIntValue(1)
+		default:
+			dp.SetIntValue(0)
+		}
+	}
+}
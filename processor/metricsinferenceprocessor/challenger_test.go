@@ -0,0 +1,141 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	grpccodes "google.golang.org/grpc/codes"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestChallengerComparison(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.5)),
+		testutil.WithModelResponse("scorer_v2", testutil.CreateMockResponseForCalculation("scorer_v2", 0.8)),
+		testutil.WithModelError("scorer_v3", testutil.CreateMockErrorResponse(grpccodes.Unavailable, "model unavailable")))
+
+	tests := []struct {
+		name       string
+		challenger ChallengerConfig
+		metric     string
+		check      func(t *testing.T, value float64)
+	}{
+		{
+			name:       "delta",
+			challenger: ChallengerConfig{ModelName: "scorer_v2"},
+			metric:     "cpu.score.challenger_delta",
+			check:      func(t *testing.T, value float64) { assert.InDelta(t, 0.3, value, 1e-9) },
+		},
+		{
+			name:       "agreement",
+			challenger: ChallengerConfig{ModelName: "scorer_v2", Comparison: "agreement", Tolerance: 0.5},
+			metric:     "cpu.score.challenger_agreement",
+			check:      func(t *testing.T, value float64) { assert.Equal(t, 1.0, value) },
+		},
+		{
+			name:       "disagreement",
+			challenger: ChallengerConfig{ModelName: "scorer_v2", Comparison: "agreement", Tolerance: 0.1},
+			metric:     "cpu.score.challenger_agreement",
+			check:      func(t *testing.T, value float64) { assert.Equal(t, 0.0, value) },
+		},
+		{
+			name:       "failed challenger",
+			challenger: ChallengerConfig{ModelName: "scorer_v3"},
+			metric:     "cpu.score.challenger_delta",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenger := tt.challenger
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Rules: []Rule{{
+					ModelName:     "scorer",
+					Inputs:        []string{"cpu.usage"},
+					Outputs:       []OutputSpec{{Name: "cpu.score"}},
+					OutputPattern: "{output}",
+					Challenger:    &challenger,
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{{
+				MetricName: "cpu.usage",
+				DataPoints: []testutil.TestDataPoint{{Value: 40, Attributes: map[string]string{"host": "a"}}},
+			}})
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+			require.Len(t, sink.AllMetrics(), 1)
+			output := sink.AllMetrics()[0]
+
+			// The champion's output is emitted as usual
+			score := findMetricByName(output, "cpu.score")
+			require.Equal(t, 1, score.Gauge().DataPoints().Len())
+			assert.Equal(t, 0.5, score.Gauge().DataPoints().At(0).DoubleValue())
+
+			comparison := findMetricByName(output, tt.metric)
+			if tt.check == nil {
+				assert.Equal(t, "", comparison.Name(), "no comparison without a challenger result")
+				return
+			}
+			require.Equal(t, 1, comparison.Gauge().DataPoints().Len())
+			dp := comparison.Gauge().DataPoints().At(0)
+			tt.check(t, dataPointValue(dp))
+			challengerName, ok := dp.Attributes().Get(labelChallengerModelName)
+			require.True(t, ok)
+			assert.Equal(t, challenger.ModelName, challengerName.Str())
+			host, ok := dp.Attributes().Get("cpu.usage.host")
+			require.True(t, ok, "comparisons keep the champion's attributes")
+			assert.Equal(t, "a", host.Str())
+		})
+	}
+
+	// Both models received the same inputs
+	requests := mockServer.GetRequests()
+	inputs := make(map[string][]float64)
+	for _, request := range requests {
+		inputs[request.ModelName] = request.Inputs[0].Contents.Fp64Contents
+	}
+	assert.Equal(t, inputs["scorer"], inputs["scorer_v2"])
+}
+
+func TestValidateChallenger(t *testing.T) {
+	rule := func(challenger ChallengerConfig) Rule {
+		return Rule{ModelName: "scorer", Inputs: []string{"x"}, Challenger: &challenger}
+	}
+
+	assert.NoError(t, validateChallenger(Rule{ModelName: "scorer"}))
+	assert.NoError(t, validateChallenger(rule(ChallengerConfig{ModelName: "scorer_v2"})))
+	assert.NoError(t, validateChallenger(rule(ChallengerConfig{ModelName: "scorer", ModelVersion: "2"})))
+	assert.NoError(t, validateChallenger(rule(ChallengerConfig{ModelName: "scorer_v2", Comparison: "agreement", Tolerance: 0.1})))
+
+	assert.ErrorContains(t, validateChallenger(rule(ChallengerConfig{})), "model_name must not be empty")
+	assert.ErrorContains(t, validateChallenger(rule(ChallengerConfig{ModelName: "scorer"})), "must differ")
+	assert.ErrorContains(t, validateChallenger(rule(ChallengerConfig{ModelName: "v2", Comparison: "ratio"})), "invalid comparison")
+	assert.ErrorContains(t, validateChallenger(rule(ChallengerConfig{ModelName: "v2", Tolerance: -1})), "tolerance must not be negative")
+
+	local := rule(ChallengerConfig{ModelName: "v2"})
+	local.Backend = "local"
+	assert.ErrorContains(t, validateChallenger(local), "require the server backend")
+
+	shadow := rule(ChallengerConfig{ModelName: "v2"})
+	shadow.Mode = "shadow"
+	assert.ErrorContains(t, validateChallenger(shadow), "not supported in shadow mode")
+}
@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Layouts of the feature matrix of rules combining their inputs
+const (
+	combineLayoutColumns = "columns" // [rows, n_inputs]: a column per input
+	combineLayoutRows    = "rows"    // [n_inputs, rows]: a row per input
+)
+
+// combinedInputs merges a rule's input tensors into one feature matrix
+type combinedInputs struct {
+	tensorName string
+	layout     string
+}
+
+// validateCombineInputs checks a rule's combine_inputs configuration. Encoders
+// and the local backend work on the tensors of individual inputs, which are no
+// longer sent.
+func validateCombineInputs(rule Rule) error {
+	if rule.CombineInputs == nil {
+		return nil
+	}
+	switch rule.CombineInputs.Layout {
+	case "", combineLayoutColumns, combineLayoutRows:
+	default:
+		return fmt.Errorf("invalid layout %q (must be 'columns' or 'rows')", rule.CombineInputs.Layout)
+	}
+	if len(rule.Encoders) > 0 {
+		return errors.New("combine_inputs cannot be combined with encoders")
+	}
+	if rule.Backend == backendLocal || rule.Backend == backendBuiltin {
+		return fmt.Errorf("combine_inputs is not supported with the %s backend", rule.Backend)
+	}
+	return nil
+}
+
+// newCombinedInputs creates the merging of a rule's inputs, nil when they are
+// sent as separate tensors
+func newCombinedInputs(cfg *CombineInputsConfig) *combinedInputs {
+	if cfg == nil {
+		return nil
+	}
+	combined := &combinedInputs{tensorName: cfg.TensorName, layout: cfg.Layout}
+	if combined.tensorName == "" {
+		combined.tensorName = defaultWindowTensorName
+	}
+	if combined.layout == "" {
+		combined.layout = combineLayoutColumns
+	}
+	return combined
+}
+
+// apply replaces the tensors of a request's inputs with one FP64 feature
+// matrix. Every input must have the same number of values, which are the rows
+// of the matrix: the input's window, or its value per matched data point group.
+// Columns of the "columns" layout and rows of the "rows" layout follow the
+// order of the rule's inputs.
+func (c *combinedInputs) apply(request *pb.ModelInferRequest, inputs []string) error {
+	byName := make(map[string]*pb.ModelInferRequest_InferInputTensor, len(request.Inputs))
+	for _, tensor := range request.Inputs {
+		byName[tensor.Name] = tensor
+	}
+
+	rows := -1
+	values := make([][]float64, len(inputs))
+	for i, input := range inputs {
+		tensor, exists := byName[input]
+		if !exists {
+			return fmt.Errorf("input %s has no tensor", input)
+		}
+		inputValues, ok := tensorValues(tensor.Contents)
+		if !ok {
+			return fmt.Errorf("input %s has no numeric contents", input)
+		}
+		if rows >= 0 && len(inputValues) != rows {
+			return fmt.Errorf("input %s has %d values, %s has %d", input, len(inputValues), inputs[0], rows)
+		}
+		rows = len(inputValues)
+		values[i] = inputValues
+	}
+
+	contents := make([]float64, 0, rows*len(inputs))
+	shape := []int64{int64(rows), int64(len(inputs))}
+	if c.layout == combineLayoutRows {
+		shape = []int64{int64(len(inputs)), int64(rows)}
+		for i := range inputs {
+			contents = append(contents, values[i]...)
+		}
+	} else {
+		for r := 0; r < rows; r++ {
+			for i := range inputs {
+				contents = append(contents, values[i][r])
+			}
+		}
+	}
+
+	request.Inputs = []*pb.ModelInferRequest_InferInputTensor{{
+		Name:     c.tensorName,
+		Datatype: "FP64",
+		Shape:    shape,
+		Contents: &pb.InferTensorContents{Fp64Contents: contents},
+	}}
+	return nil
+}
+
+// tensorValues returns the numeric contents of a tensor as float64. ok is false
+// when the tensor has no numeric contents.
+func tensorValues(contents *pb.InferTensorContents) (values []float64, ok bool) {
+	switch {
+	case contents == nil:
+		return nil, false
+	case len(contents.Fp64Contents) > 0:
+		return contents.Fp64Contents, true
+	case len(contents.Fp32Contents) > 0:
+		values = make([]float64, len(contents.Fp32Contents))
+		for i, v := range contents.Fp32Contents {
+			values[i] = float64(v)
+		}
+		return values, true
+	case len(contents.Int64Contents) > 0:
+		values = make([]float64, len(contents.Int64Contents))
+		for i, v := range contents.Int64Contents {
+			values[i] = float64(v)
+		}
+		return values, true
+	case len(contents.IntContents) > 0:
+		values = make([]float64, len(contents.IntContents))
+		for i, v := range contents.IntContents {
+			values[i] = float64(v)
+		}
+		return values, true
+	}
+	return nil, false
+}
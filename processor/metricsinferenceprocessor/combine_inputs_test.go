@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// separateInputs returns a request with one tensor per input, as built before combining
+func separateInputs() *pb.ModelInferRequest {
+	return &pb.ModelInferRequest{Inputs: []*pb.ModelInferRequest_InferInputTensor{
+		{Name: "memory", Datatype: "INT64", Shape: []int64{3}, Contents: &pb.InferTensorContents{Int64Contents: []int64{10, 20, 30}}},
+		{Name: "cpu", Datatype: "FP64", Shape: []int64{3}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3}}},
+	}}
+}
+
+func TestCombinedInputsLayouts(t *testing.T) {
+	request := separateInputs()
+	require.NoError(t, newCombinedInputs(&CombineInputsConfig{TensorName: "features"}).apply(request, []string{"cpu", "memory"}))
+	require.Len(t, request.Inputs, 1)
+	assert.Equal(t, "features", request.Inputs[0].Name)
+	assert.Equal(t, "FP64", request.Inputs[0].Datatype)
+	assert.Equal(t, []int64{3, 2}, request.Inputs[0].Shape)
+	assert.Equal(t, []float64{1, 10, 2, 20, 3, 30}, request.Inputs[0].Contents.Fp64Contents)
+
+	request = separateInputs()
+	require.NoError(t, newCombinedInputs(&CombineInputsConfig{Layout: combineLayoutRows}).apply(request, []string{"cpu", "memory"}))
+	assert.Equal(t, defaultWindowTensorName, request.Inputs[0].Name)
+	assert.Equal(t, []int64{2, 3}, request.Inputs[0].Shape)
+	assert.Equal(t, []float64{1, 2, 3, 10, 20, 30}, request.Inputs[0].Contents.Fp64Contents)
+
+	request = separateInputs()
+	request.Inputs[0].Contents.Int64Contents = []int64{10, 20}
+	assert.ErrorContains(t, newCombinedInputs(&CombineInputsConfig{}).apply(request, []string{"cpu", "memory"}), "has 2 values")
+
+	request = separateInputs()
+	assert.ErrorContains(t, newCombinedInputs(&CombineInputsConfig{}).apply(request, []string{"cpu", "disk"}), "input disk has no tensor")
+}
+
+func TestCombinedInputsRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("classifier", testutil.CreateMockResponseForCalculation("classifier", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "classifier",
+			Inputs:        []string{"cpu", "memory"},
+			Outputs:       []OutputSpec{{Name: "class"}},
+			CombineInputs: &CombineInputsConfig{TensorName: "features"},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "cpu", DataPoints: []testutil.TestDataPoint{
+			{Value: 0.5, Attributes: map[string]string{"host.name": "a"}},
+			{Value: 0.25, Attributes: map[string]string{"host.name": "b"}},
+		}},
+		{MetricName: "memory", DataPoints: []testutil.TestDataPoint{
+			{Value: 50, Attributes: map[string]string{"host.name": "a"}},
+			{Value: 25, Attributes: map[string]string{"host.name": "b"}},
+		}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// A row per matched host, a column per input
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	input := requests[0].Inputs[0]
+	assert.Equal(t, "features", input.Name)
+	assert.Equal(t, []int64{2, 2}, input.Shape)
+	assert.ElementsMatch(t, []float64{0.5, 50, 0.25, 25}, input.Contents.Fp64Contents)
+	for row := 0; row < 2; row++ {
+		assert.Equal(t, input.Contents.Fp64Contents[row*2]*100, input.Contents.Fp64Contents[row*2+1])
+	}
+}
+
+func TestCombinedInputsSharedModel(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("classifier", testutil.CreateMockResponseForCalculation("classifier", 1)))
+
+	// Two rules on one model, only the first of which combines its inputs
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "classifier",
+				Inputs:        []string{"cpu", "memory"},
+				Outputs:       []OutputSpec{{Name: "class"}},
+				CombineInputs: &CombineInputsConfig{TensorName: "features"},
+			},
+			{
+				ModelName: "classifier",
+				Inputs:    []string{"cpu"},
+				Outputs:   []OutputSpec{{Name: "cpu_class"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu", "memory"},
+		MetricValues: [][]float64{{0.5}, {50}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	names := make(map[string][]int64)
+	for _, request := range requests {
+		require.Len(t, request.Inputs, 1)
+		names[request.Inputs[0].Name] = request.Inputs[0].Shape
+	}
+	assert.Equal(t, map[string][]int64{"features": {1, 2}, "cpu": {1}}, names)
+}
+
+func TestValidateCombineInputs(t *testing.T) {
+	rule := Rule{ModelName: "m", Inputs: []string{"x", "y"}, CombineInputs: &CombineInputsConfig{Layout: combineLayoutRows}}
+	assert.NoError(t, validateCombineInputs(rule))
+	assert.NoError(t, validateCombineInputs(Rule{ModelName: "m", Inputs: []string{"x"}}))
+
+	rule.CombineInputs.Layout = "features"
+	assert.ErrorContains(t, validateCombineInputs(rule), `invalid layout "features"`)
+
+	rule.CombineInputs.Layout = ""
+	rule.Encoders = map[string]string{"x": "gauge"}
+	assert.ErrorContains(t, validateCombineInputs(rule), "encoders")
+}
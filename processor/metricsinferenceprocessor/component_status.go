@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+)
+
+// reportStatus reports a status event to the collector, so orchestration layers
+// can see the processor's health. It is a no-op when the host does not accept
+// status reports, as in tests without a host.
+func (mp *metricsinferenceprocessor) reportStatus(event *componentstatus.Event) {
+	componentstatus.ReportStatus(mp.host, event)
+}
+
+// invalidSelectors returns an error naming every rule input that is not a valid
+// selector. Such inputs never match a metric, so their rules never run.
+func invalidSelectors(cfg *Config) error {
+	var errs []error
+	for i, rule := range cfg.Rules {
+		for _, input := range rule.configuredInputs() {
+			if _, err := parseLabelSelector(input); err != nil {
+				errs = append(errs, fmt.Errorf("rule %d input %q never matches: %w", i, input, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reportInvalidSelectors reports rules that can never match as a permanent error
+func (mp *metricsinferenceprocessor) reportInvalidSelectors() {
+	if err := invalidSelectors(mp.config); err != nil {
+		mp.logger.Error("Rules with invalid input selectors will never run", zap.Error(err))
+		mp.reportStatus(componentstatus.NewPermanentErrorEvent(err))
+	}
+}
+
+// reportMetadataStatus reports failed model metadata discovery as a recoverable
+// error, and a later discovery of every model's metadata as recovery
+func (mp *metricsinferenceprocessor) reportMetadataStatus(err error) {
+	if err != nil {
+		mp.metadataFailed.Store(true)
+		mp.reportStatus(componentstatus.NewRecoverableErrorEvent(fmt.Errorf("model metadata discovery failed: %w", err)))
+		return
+	}
+	if mp.metadataFailed.Swap(false) {
+		mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+	}
+}
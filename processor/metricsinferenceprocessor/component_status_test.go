@@ -0,0 +1,120 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// statusHost is a host recording the status events reported to it
+type statusHost struct {
+	component.Host
+	mu     sync.Mutex
+	events []*componentstatus.Event
+}
+
+func newStatusHost() *statusHost {
+	return &statusHost{Host: componenttest.NewNopHost()}
+}
+
+func (h *statusHost) Report(event *componentstatus.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *statusHost) statuses() []componentstatus.Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var statuses []componentstatus.Status
+	for _, event := range h.events {
+		statuses = append(statuses, event.Status())
+	}
+	return statuses
+}
+
+func TestMetadataDiscoveryStatus(t *testing.T) {
+	mockServer := testutil.StartMockServer(t)
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "forecaster", Inputs: []string{"cpu.usage"}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	host := newStatusHost()
+	require.NoError(t, processor.Start(context.Background(), host), "metadata discovery failures do not fail startup")
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// The model serves no metadata, so the processor is degraded
+	require.Equal(t, []componentstatus.Status{componentstatus.StatusRecoverableError}, host.statuses())
+	assert.ErrorContains(t, host.events[0].Err(), "model forecaster")
+
+	// Discovery keeps failing without a recovery report
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Len(t, host.statuses(), 1)
+
+	// Once the metadata is served, the processor reports it has recovered
+	mockServer.SetModelMetadata("forecaster", &pb.ModelMetadataResponse{
+		Name:    "forecaster",
+		Outputs: []*pb.ModelMetadataResponse_TensorMetadata{{Name: "forecast", Datatype: "FP64", Shape: []int64{1}}},
+	})
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Equal(t, []componentstatus.Status{componentstatus.StatusRecoverableError, componentstatus.StatusOK}, host.statuses())
+}
+
+func TestInvalidSelectorStatus(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", &pb.ModelMetadataResponse{Name: "scorer"}))
+
+	// Configuration validation rejects invalid selectors, so it is skipped here
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"cpu.usage"}, Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "scorer", Inputs: []string{`cpu.usage{host="a"`}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	host := newStatusHost()
+	require.NoError(t, processor.Start(context.Background(), host))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	require.Equal(t, []componentstatus.Status{componentstatus.StatusPermanentError}, host.statuses())
+	assert.ErrorContains(t, host.events[0].Err(), "rule 1 input")
+}
+
+func TestStatusWithoutReporter(t *testing.T) {
+	// Hosts that do not accept status reports, or no host at all, are ignored
+	mp := &metricsinferenceprocessor{}
+	mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+	mp.host = componenttest.NewNopHost()
+	mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+}
@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Request compressions
+const (
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+	compressionNone = "none"
+)
+
+// The zstd compressor is registered with gRPC only when the process has none,
+// such as the one of the collector's own gRPC settings, which is then used as is
+// rather than replaced: compressors are looked up by their name on the wire.
+func init() {
+	if encoding.GetCompressor(compressionZstd) == nil {
+		encoding.RegisterCompressor(&zstdCompressor{})
+	}
+}
+
+// validateCompressionAlgorithm checks the compressor of the gRPC client
+func validateCompressionAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", compressionGzip, compressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression_algorithm %q (must be 'gzip' or 'zstd')", algorithm)
+	}
+}
+
+// validateRuleCompression checks the compression of a rule's requests
+func validateRuleCompression(compression string) error {
+	switch compression {
+	case "", compressionGzip, compressionZstd, compressionNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression %q (must be 'gzip', 'zstd', or 'none')", compression)
+	}
+}
+
+// compressorName returns the gRPC compressor of a compression, gzip by default
+func compressorName(compression string) string {
+	switch compression {
+	case compressionZstd:
+		return compressionZstd
+	case compressionNone:
+		return encoding.Identity
+	default:
+		return gzip.Name
+	}
+}
+
+// ruleCallOptions returns the call options of a rule's requests, which override
+// the compression configured for the gRPC client
+func ruleCallOptions(rule Rule) []grpc.CallOption {
+	if rule.Compression == "" {
+		return nil
+	}
+	return []grpc.CallOption{grpc.UseCompressor(compressorName(rule.Compression))}
+}
+
+// zstdCompressor compresses gRPC messages with zstd. Encoders and decoders are
+// reused across messages, as creating them allocates their whole window.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// zstdWriter returns its encoder to the pool once the message is compressed
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once the message is read. A
+// decoder whose message is not read to the end is left to the garbage
+// collector, which it can be as it decodes synchronously.
+type zstdReader struct {
+	decoder *zstd.Decoder
+	pool    *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.decoder.Read(p)
+	if err != nil {
+		// Releases the decoder's reference to the message
+		_ = r.decoder.Reset(nil)
+		r.pool.Put(r.decoder)
+		r.decoder = nil
+	}
+	return n, err
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if writer, ok := c.encoders.Get().(*zstdWriter); ok {
+		writer.Reset(w)
+		return writer, nil
+	}
+	encoder, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: encoder, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if decoder, ok := c.decoders.Get().(*zstd.Decoder); ok {
+		if err := decoder.Reset(r); err != nil {
+			c.decoders.Put(decoder)
+			return nil, err
+		}
+		return &zstdReader{decoder: decoder, pool: &c.decoders}, nil
+	}
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{decoder: decoder, pool: &c.decoders}, nil
+}
+
+func (c *zstdCompressor) Name() string {
+	return compressionZstd
+}
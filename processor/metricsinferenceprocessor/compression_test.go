@@ -0,0 +1,168 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestZstdCompressorRoundTrip(t *testing.T) {
+	require.NotNil(t, encoding.GetCompressor(compressionZstd), "zstd is registered with gRPC")
+
+	compressor := &zstdCompressor{}
+	message := []byte(strings.Repeat("inference ", 100))
+	for i := 0; i < 2; i++ {
+		// The second message reuses the pooled encoder and decoder
+		var compressed bytes.Buffer
+		writer, err := compressor.Compress(&compressed)
+		require.NoError(t, err)
+		_, err = writer.Write(message)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		assert.Less(t, compressed.Len(), len(message))
+
+		reader, err := compressor.Decompress(&compressed)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, message, decompressed)
+	}
+}
+
+func TestRequestCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		algorithm   string
+		compression string
+	}{
+		{name: "gzip"},
+		{name: "zstd", algorithm: compressionZstd},
+		{name: "rule_zstd", compression: compressionZstd},
+		{name: "rule_none", algorithm: compressionZstd, compression: compressionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{
+					Endpoint:             mockServer.Endpoint(),
+					Compression:          true,
+					CompressionAlgorithm: tt.algorithm,
+				},
+				Timeout: 5,
+				Rules: []Rule{
+					{
+						ModelName:     "scorer",
+						Inputs:        []string{"metric_1"},
+						OutputPattern: "scorer.{output}",
+						Compression:   tt.compression,
+						Outputs:       []OutputSpec{{Name: "score"}},
+					},
+				},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"metric_1"},
+				MetricValues: [][]float64{{1}},
+			})
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+			// The server decompresses the request and the response is decoded
+			require.Len(t, mockServer.GetRequests(), 1)
+			assert.Equal(t, []float64{1}, mockServer.GetRequests()[0].Inputs[0].Contents.Fp64Contents)
+			assert.Equal(t, "scorer.score", findMetricByName(sink.AllMetrics()[0], "scorer.score").Name())
+		})
+	}
+}
+
+func TestMaxSendMessageSize(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint(), MaxSendMessageSize: 256},
+		Timeout:            5,
+		DataHandling:       DataHandlingConfig{Mode: "window", WindowSize: 100},
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "small.{output}", Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "scorer", Inputs: []string{"metric_2"}, OutputPattern: "large.{output}", Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1", "metric_2"},
+		MetricValues: [][]float64{{1}, {2}},
+	})
+	large := findMetricByName(input, "metric_2").Gauge().DataPoints()
+	for i := 0; i < 100; i++ {
+		large.AppendEmpty().SetDoubleValue(float64(i))
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// The oversized request fails before it is sent, the other rule is unaffected
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "metric_1", requests[0].Inputs[0].Name)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, "small.score", findMetricByName(output, "small.score").Name())
+	assert.Empty(t, findMetricByName(output, "large.score").Name())
+}
+
+func TestValidateCompression(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x"}}},
+	}
+	for _, algorithm := range []string{"", "gzip", "zstd"} {
+		cfg.GRPCClientSettings.CompressionAlgorithm = algorithm
+		assert.NoError(t, cfg.Validate())
+	}
+	cfg.GRPCClientSettings.CompressionAlgorithm = "snappy"
+	assert.ErrorContains(t, cfg.Validate(), `invalid compression_algorithm "snappy"`)
+	cfg.GRPCClientSettings.CompressionAlgorithm = ""
+
+	cfg.GRPCClientSettings.MaxSendMessageSize = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_send_message_size")
+	cfg.GRPCClientSettings.MaxSendMessageSize = 0
+
+	for _, compression := range []string{"", "gzip", "zstd", "none"} {
+		cfg.Rules[0].Compression = compression
+		assert.NoError(t, cfg.Validate())
+	}
+	cfg.Rules[0].Compression = "lz4"
+	assert.ErrorContains(t, cfg.Validate(), `invalid compression "lz4"`)
+}
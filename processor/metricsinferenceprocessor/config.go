@@ -5,6 +5,7 @@ package metricsinferenceprocessor // import "github.com/rbellamy/opentelemetry-i
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
@@ -18,14 +19,293 @@ type Config struct {
 	// Rules define how to process metrics and which inference model to use.
 	Rules []Rule `mapstructure:"rules"`
 
+	// RulesFiles lists YAML files, or glob patterns of files, with further rules
+	// under a top-level "rules" key. They are merged after the inline rules.
+	RulesFiles []string `mapstructure:"rules_files"`
+
 	// Timeout for inference requests in seconds. Default is 10 seconds.
 	Timeout int `mapstructure:"timeout"`
 
+	// MaxBatchDelay bounds how long a batch waits for inference. Rules that have not
+	// finished by then are skipped and the batch moves on without their outputs.
+	// Zero waits for every rule.
+	MaxBatchDelay time.Duration `mapstructure:"max_batch_delay"`
+
+	// MaxGroupsPerRequest splits the inference of a rule matching more attribute
+	// groups than this into several requests of at most this many groups, sent
+	// concurrently, whose responses are merged, so very large batches do not exceed
+	// the server's limits or the message size caps. Zero, the default, sends one
+	// request however many groups match.
+	MaxGroupsPerRequest int `mapstructure:"max_groups_per_request"`
+
+	// Queue configures the bounded queue that inference calls wait in
+	Queue QueueConfig `mapstructure:"queue"`
+
 	// Naming configures the naming strategy for output metrics
 	Naming NamingConfig `mapstructure:"naming"`
 
+	// OutputScope selects the instrumentation scope inference outputs are added to:
+	// "input" (default) adds them to the scope of the rule's inputs, "dedicated" to
+	// an "opentelemetry.inference" scope versioned with the collector, so inferred
+	// metrics are not mixed into the scopes of the instrumentation they came from.
+	OutputScope string `mapstructure:"output_scope"`
+
+	// Emit selects what the processor forwards downstream: "all" (default) forwards
+	// the batch's metrics along with the outputs, "outputs_only" only the metrics
+	// the processor produced, for pipelines whose inputs are already exported by
+	// another pipeline.
+	Emit string `mapstructure:"emit"`
+
+	// OutputStartTimestamp sets the start timestamp of output data points, for
+	// backends computing rates or deltas over inferred series: "none" (default)
+	// leaves it zero, "input" uses the earliest start of the input data points
+	// inferred from, and "activation" the time the rule became active.
+	OutputStartTimestamp string `mapstructure:"output_start_timestamp"`
+
 	// DataHandling configures how metric data points are processed for inference
 	DataHandling DataHandlingConfig `mapstructure:"data_handling"`
+
+	// Cache configures reuse of inference results for identical requests
+	Cache CacheConfig `mapstructure:"cache"`
+
+	// Units configures validation and normalization of configured output units
+	Units UnitsConfig `mapstructure:"units"`
+
+	// Metadata configures how model metadata is kept up to date
+	Metadata MetadataConfig `mapstructure:"metadata"`
+
+	// DryRun checks every rule against the metadata of its model at startup and
+	// fails the startup with a report of every mismatch, such as inputs the model
+	// does not expect or outputs it does not produce. When every rule matches,
+	// batches are forwarded unchanged, without inference. Default is false.
+	DryRun bool `mapstructure:"dry_run"`
+
+	// WarmUp configures the warm-up requests sent to models at startup
+	WarmUp WarmUpConfig `mapstructure:"warm_up"`
+
+	// Logging configures deduplication of warnings that repeat every batch
+	Logging LoggingConfig `mapstructure:"logging"`
+
+	// NonFinite configures how NaN and infinite values returned by models are handled
+	NonFinite NonFiniteConfig `mapstructure:"non_finite"`
+
+	// Staleness configures staleness markers for output series that are no longer produced
+	Staleness StalenessConfig `mapstructure:"staleness"`
+
+	// Cardinality limits the output data points and series every rule produces
+	Cardinality CardinalityConfig `mapstructure:"cardinality"`
+
+	// ErrorMetrics configures metrics recording failed inferences in the batch
+	ErrorMetrics ErrorMetricsConfig `mapstructure:"error_metrics"`
+
+	// OnError decides what happens to a batch when a rule's inference fails, for
+	// rules that do not set their own policy: "pass" forwards it with whatever
+	// outputs succeeded (default), "drop_inputs" removes the failed rule's input
+	// data points, "drop_batch" drops the whole batch, and "retry" returns the
+	// batch to the preceding component with a retryable error when the failure is
+	// transient, or a permanent one when retrying cannot fix it.
+	OnError string `mapstructure:"on_error"`
+
+	// IdempotencyParameter names the request parameter the request ID is sent in,
+	// so inference servers and caches can deduplicate retried requests. Request
+	// IDs are derived from the rule and its input data points. Empty, the default,
+	// disables it.
+	IdempotencyParameter string `mapstructure:"idempotency_parameter"`
+
+	// Storage is the ID of a storage extension used to persist per-series state,
+	// such as delta and rate baselines, scaling windows, local backend history and
+	// last values, across collector restarts. State is kept in memory only when unset.
+	Storage *component.ID `mapstructure:"storage"`
+
+	// StorageInterval is how often the state is saved to the storage extension
+	// while running, so a crash loses at most that much history. The state is
+	// also saved at shutdown. Default is a minute.
+	StorageInterval time.Duration `mapstructure:"storage_interval"`
+
+	// RemoteRules receives rules pushed by an OpAMP server through a custom
+	// capability of an OpAMP extension, applied after the local rules. Rules are
+	// only configured locally when unset.
+	RemoteRules *RemoteRulesConfig `mapstructure:"remote_rules"`
+
+	// clientInjected is set by NewFactoryWithClient, whose processors use the
+	// injected client rather than the configured endpoints
+	clientInjected bool
+}
+
+// RemoteRulesConfig defines how rules are received from an OpAMP server. The
+// server sends the rules as custom messages of the capability, and every set it
+// sends replaces the remote rules applied before. Outcomes are sent back as
+// custom messages of the same capability.
+type RemoteRulesConfig struct {
+	// Extension is the ID of the OpAMP extension the capability is registered
+	// with, such as opamp
+	Extension component.ID `mapstructure:"extension"`
+
+	// Capability is the custom capability the rules are exchanged under. Default
+	// is "io.opentelemetry.inference.rules".
+	Capability string `mapstructure:"capability"`
+}
+
+// QueueConfig defines the bounded queue inference calls of all batches wait in
+// before a worker sends them, so bursts of batches neither start an unbounded
+// number of concurrent calls nor stall the pipeline indefinitely.
+type QueueConfig struct {
+	// QueueSize is the number of calls that may wait for a worker. Default is 0,
+	// which disables the queue and sends every call right away.
+	QueueSize int `mapstructure:"queue_size"`
+
+	// Workers is the number of calls sent concurrently. Default is 10.
+	Workers int `mapstructure:"workers"`
+
+	// DropPolicy decides what happens to a call submitted while the queue is full:
+	// "block" waits for room (default), "drop_oldest" drops the longest waiting call,
+	// and "drop_newest" drops the submitted call. Dropped calls are skipped for their batch.
+	DropPolicy string `mapstructure:"drop_policy"`
+
+	// MaxWait bounds how long the block policy waits for room before dropping the
+	// call. Default is 0, which waits as long as the batch has time left.
+	MaxWait time.Duration `mapstructure:"max_wait"`
+}
+
+// LoggingConfig defines how repeated warnings, such as missing inputs for a rule,
+// are limited so they do not flood the logs at scrape frequency.
+type LoggingConfig struct {
+	// RepeatInterval is how long repeats of a warning for the same rule are suppressed
+	// after it is logged. Default is 1 minute; 0 logs every occurrence.
+	RepeatInterval time.Duration `mapstructure:"repeat_interval"`
+
+	// MaxRepeatInterval caps the suppression interval, which doubles every time a
+	// repeated warning is logged again. Default is 15 minutes.
+	MaxRepeatInterval time.Duration `mapstructure:"max_repeat_interval"`
+}
+
+// NonFiniteConfig defines how NaN and ±Inf values in model outputs are handled.
+// Such values are valid in OTLP but rejected or mishandled by some exporters.
+type NonFiniteConfig struct {
+	// Policy is applied to every NaN or infinite output value:
+	// "propagate" emits the value as returned by the model (default), "drop"
+	// omits the data point, "zero" replaces the value with 0, and "clamp"
+	// replaces ±Inf with the largest finite values and drops NaN.
+	Policy string `mapstructure:"policy"`
+}
+
+// StalenessConfig defines when output series that are no longer produced, because
+// their inputs disappeared or their model keeps failing, are marked stale. A data
+// point flagged as having no recorded value is emitted once for such a series, so
+// backends such as Prometheus end the series instead of repeating its last value.
+type StalenessConfig struct {
+	// Period is how long a series may go without being produced before it is marked
+	// stale. Series are checked whenever a batch is processed. Default is 0, which
+	// disables staleness markers.
+	Period time.Duration `mapstructure:"period"`
+}
+
+// CardinalityConfig defines guardrails against rules producing far more output
+// data points than intended, such as a broadcast rule whose inputs have many
+// attribute sets. Data points beyond a limit are dropped with a warning and
+// counted in the otelcol_processor_metricsinference_truncated_data_points metric.
+type CardinalityConfig struct {
+	// MaxDataPoints is the maximum number of output data points a rule adds to a
+	// batch; later ones are dropped. Default is 0, which means no limit.
+	MaxDataPoints int `mapstructure:"max_data_points"`
+
+	// MaxSeries is the maximum number of distinct series, by resource, metric name
+	// and attribute set, a rule produces. Data points of new series are dropped
+	// while a rule is at the limit; series not produced for an hour are forgotten.
+	// Default is 0, which means no limit.
+	MaxSeries int `mapstructure:"max_series"`
+
+	// MaxCartesianGroups is the maximum number of attribute groups a rule with
+	// cartesian matching infers on per resource and batch; the combinations past
+	// it, in key order, are left out. Default is 0, which means 10000.
+	MaxCartesianGroups int `mapstructure:"max_cartesian_groups"`
+}
+
+// ErrorMetricsConfig defines the otel.inference.error gauge, which makes inference
+// failures visible in metric backends rather than only in the collector's logs.
+type ErrorMetricsConfig struct {
+	// Enabled emits a data point of 1 for every failed or skipped inference of a
+	// rule, with the model name and version, rule index and gRPC status code as
+	// attributes. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// MetadataConfig defines how model metadata discovered at startup is refreshed.
+// Refreshing lets long-lived collectors pick up new signatures after a model redeploy.
+type MetadataConfig struct {
+	// RefreshInterval is how often model metadata is re-queried. When a model's
+	// versions or signature change, its discovered outputs are rebuilt.
+	// Default is 0, which disables refreshing.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+
+	// RepositoryPollInterval is how often the server's model repository is listed
+	// to detect models being unloaded and loaded again, as with Triton's explicit
+	// model control. Rules of an unloaded model skip inference, and its cached
+	// metadata is fetched again once it is loaded. Requires the model_repository
+	// extension. Default is 0, which disables polling.
+	RepositoryPollInterval time.Duration `mapstructure:"repository_poll_interval"`
+
+	// Concurrency is the maximum number of models whose metadata is queried at
+	// once, at startup and on every refresh. Default is 0, which queries 4 at once.
+	Concurrency int `mapstructure:"concurrency"`
+
+	// TTL is how long cached model metadata is used before it is queried again.
+	// Expired metadata keeps being used while the next batch of one of the
+	// model's rules has it refreshed in the background. Default is 0, which
+	// keeps metadata until a refresh or repeated input validation failures.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// WarmUpConfig defines the requests sent to every model once its metadata is
+// discovered, so models that load or compile lazily are ready for the first batch.
+type WarmUpConfig struct {
+	// Enabled sends every model served by the inference server a request of
+	// zeros shaped after its input signature. Models without discovered input
+	// metadata are not warmed up. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Timeout bounds the warm-up of all models. Default is 0, which uses the
+	// request timeout.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// UnitsConfig defines how configured output units are checked. Units on prediction
+// metrics are passed to backends as-is, so mistakes such as "bytes" instead of "By"
+// would otherwise go unnoticed.
+type UnitsConfig struct {
+	// Validation controls how units that are not valid UCUM are handled:
+	// "warn" logs a warning (default), "strict" rejects the configuration, "none" disables checks.
+	Validation string `mapstructure:"validation"`
+
+	// Normalize replaces common non-UCUM spellings with their UCUM equivalent
+	// (e.g. "bytes" -> "By", "seconds" -> "s") before validation. Default is false.
+	Normalize bool `mapstructure:"normalize"`
+
+	// Allowed lists additional units accepted besides UCUM units.
+	Allowed []string `mapstructure:"allowed"`
+}
+
+// outputUnit returns a configured output unit, normalized when enabled
+func (c UnitsConfig) outputUnit(unit string) string {
+	if c.Normalize {
+		return normalizeUnit(unit)
+	}
+	return unit
+}
+
+// CacheConfig defines the inference result cache. When enabled, a request whose
+// model, version, input tensors and parameters match a recent request is answered
+// from the cache instead of calling the inference server.
+type CacheConfig struct {
+	// Enabled turns on the result cache. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+
+	// TTL is how long a cached result stays valid. Default is 1 minute.
+	TTL time.Duration `mapstructure:"ttl"`
+
+	// MaxEntries is the maximum number of cached results; the least recently
+	// used entry is evicted when the cache is full. Default is 1000.
+	MaxEntries int `mapstructure:"max_entries"`
 }
 
 // GRPCClientSettings defines the configuration for the gRPC client.
@@ -33,20 +313,133 @@ type GRPCClientSettings struct {
 	// Endpoint for the inference service (e.g., "localhost:50051")
 	Endpoint string `mapstructure:"endpoint"`
 
+	// Endpoints lists several inference services serving the same models, in
+	// priority order. Mutually exclusive with Endpoint.
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// LoadBalancing selects how calls are spread over Endpoints: "failover"
+	// (default) sends every call to the first healthy endpoint, "round_robin"
+	// rotates over the healthy endpoints. Rules with sequences require failover.
+	LoadBalancing string `mapstructure:"load_balancing"`
+
+	// HealthCheckInterval is how often Endpoints are probed with ServerLive to
+	// detect failed and recovered endpoints. Default is 10 seconds.
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+
+	// Channels is the number of gRPC connections opened to each endpoint. Calls
+	// rotate over them, so many concurrent rules are not limited by the flow
+	// control of a single HTTP/2 connection. Default is 1.
+	Channels int `mapstructure:"channels"`
+
 	// UseSSL indicates whether to use SSL/TLS for the connection
 	UseSSL bool `mapstructure:"use_ssl"`
 
 	// Compression indicates whether to use gRPC compression
 	Compression bool `mapstructure:"compression"`
 
+	// CompressionAlgorithm selects the compressor used when Compression is
+	// enabled: "gzip" (default) or "zstd"
+	CompressionAlgorithm string `mapstructure:"compression_algorithm"`
+
+	// MaxSendMessageSize sets the maximum size in bytes of a request the client sends.
+	// Larger requests fail before they are sent. Zero means no limit.
+	MaxSendMessageSize int `mapstructure:"max_send_message_size"`
+
 	// MaxReceiveMessageSize sets the maximum message size in bytes the client can receive
 	MaxReceiveMessageSize int `mapstructure:"max_receive_message_size"`
 
-	// Headers to be sent with gRPC requests
+	// Headers to be sent with gRPC requests. Values may contain {resource:<attribute>}
+	// placeholders, resolved per inference request from the resource attributes of
+	// the rule's input metrics (e.g. "x-scope-orgid": "{resource:tenant.id}").
 	Headers map[string]string `mapstructure:"headers"`
 
+	// MissingHeaderAttributes is what happens to an inference call whose resource
+	// lacks an attribute of a templated header: "skip" (default) skips the call for
+	// the batch, so its outputs fall back, and "omit" sends it without the header.
+	MissingHeaderAttributes string `mapstructure:"missing_header_attributes"`
+
 	// KeepAlive settings for the gRPC client
 	KeepAlive *KeepAliveClientConfig `mapstructure:"keepalive"`
+
+	// LazyConnect lets the processor start when no endpoint is reachable. Batches
+	// then pass through without inference while connection attempts, spaced out
+	// with exponential backoff, are made until one succeeds.
+	LazyConnect bool `mapstructure:"lazy_connect"`
+
+	// StartupHealthCheck decides what a server failing the ServerLive health check
+	// does at startup: "required" (default) fails startup, or defers the connection
+	// with LazyConnect, "warn" starts anyway and infers once the server is up, and
+	// "skip" does not check the server.
+	StartupHealthCheck string `mapstructure:"startup_health_check"`
+
+	// StartupRetries is how many times a failed startup health check is retried
+	// before the startup_health_check policy applies. Default is 0.
+	StartupRetries int `mapstructure:"startup_retries"`
+
+	// StartupRetryBackoff is the wait before the first retry, doubling for each
+	// further retry up to 30 seconds. Default is 1 second.
+	StartupRetryBackoff time.Duration `mapstructure:"startup_retry_backoff"`
+}
+
+// endpointList returns the configured inference endpoints in priority order
+func (s GRPCClientSettings) endpointList() []string {
+	if len(s.Endpoints) > 0 {
+		return s.Endpoints
+	}
+	if s.Endpoint != "" {
+		return []string{s.Endpoint}
+	}
+	return nil
+}
+
+// validate checks the endpoint and load balancing settings
+func (s GRPCClientSettings) validate() error {
+	if s.Endpoint != "" && len(s.Endpoints) > 0 {
+		return fmt.Errorf("endpoint and endpoints are mutually exclusive")
+	}
+
+	seen := make(map[string]bool, len(s.Endpoints))
+	for i, endpoint := range s.Endpoints {
+		if endpoint == "" {
+			return fmt.Errorf("endpoints[%d] must not be empty", i)
+		}
+		if seen[endpoint] {
+			return fmt.Errorf("duplicate endpoint %q", endpoint)
+		}
+		seen[endpoint] = true
+	}
+
+	switch s.LoadBalancing {
+	case "", loadBalancingFailover, loadBalancingRoundRobin:
+	default:
+		return fmt.Errorf("invalid load_balancing %q (must be 'failover' or 'round_robin')", s.LoadBalancing)
+	}
+
+	if s.HealthCheckInterval < 0 {
+		return fmt.Errorf("health_check_interval must not be negative")
+	}
+
+	if s.Channels < 0 {
+		return fmt.Errorf("channels must not be negative")
+	}
+
+	if err := validateCompressionAlgorithm(s.CompressionAlgorithm); err != nil {
+		return err
+	}
+
+	if s.MaxSendMessageSize < 0 {
+		return fmt.Errorf("max_send_message_size must not be negative")
+	}
+
+	if _, err := newRequestHeaders(s.Headers); err != nil {
+		return fmt.Errorf("invalid headers: %w", err)
+	}
+
+	if err := validateMissingHeaderAttributes(s.MissingHeaderAttributes); err != nil {
+		return err
+	}
+
+	return validateStartupHealthCheck(s)
 }
 
 // KeepAliveClientConfig defines the configuration for gRPC client keep-alive.
@@ -66,17 +459,110 @@ var _ component.Config = (*Config)(nil)
 // Validate checks whether the input configuration has all of the required fields for the processor.
 // An error is returned if there are any invalid inputs.
 func (cfg *Config) Validate() error {
-	if cfg.GRPCClientSettings.Endpoint == "" {
+	expanded, err := cfg.withRuleFiles()
+	if err != nil {
+		return err
+	}
+	return expanded.withPresets().validate()
+}
+
+// validate checks a configuration whose rules files have been merged
+func (cfg *Config) validate() error {
+	if len(cfg.GRPCClientSettings.endpointList()) == 0 && cfg.requiresInferenceServer() && !cfg.clientInjected {
 		return fmt.Errorf("gRPC endpoint must be specified")
 	}
 
+	if err := cfg.GRPCClientSettings.validate(); err != nil {
+		return fmt.Errorf("invalid gRPC client settings: %w", err)
+	}
+
+	switch cfg.Units.Validation {
+	case "", unitValidationWarn, unitValidationStrict, unitValidationNone:
+	default:
+		return fmt.Errorf("invalid units.validation %q (must be 'warn', 'strict', or 'none')", cfg.Units.Validation)
+	}
+
+	if err := validateOnError(cfg.OnError); err != nil {
+		return err
+	}
+
+	switch cfg.OutputScope {
+	case "", outputScopeInput, outputScopeDedicated:
+	default:
+		return fmt.Errorf("invalid output_scope %q (must be 'input' or 'dedicated')", cfg.OutputScope)
+	}
+
+	if err := validateEmit(cfg.Emit); err != nil {
+		return err
+	}
+
+	if err := validateStartTimestamp(cfg.OutputStartTimestamp); err != nil {
+		return err
+	}
+
+	if cfg.Logging.RepeatInterval < 0 || cfg.Logging.MaxRepeatInterval < 0 {
+		return fmt.Errorf("logging intervals must not be negative")
+	}
+
+	if cfg.Metadata.RefreshInterval < 0 {
+		return fmt.Errorf("metadata.refresh_interval must not be negative")
+	}
+
+	if cfg.Metadata.RepositoryPollInterval < 0 {
+		return fmt.Errorf("metadata.repository_poll_interval must not be negative")
+	}
+
+	if cfg.Metadata.Concurrency < 0 {
+		return fmt.Errorf("metadata.concurrency must not be negative")
+	}
+
+	if cfg.Metadata.TTL < 0 {
+		return fmt.Errorf("metadata.ttl must not be negative")
+	}
+
+	if err := validateDryRun(cfg); err != nil {
+		return err
+	}
+
+	if err := validateWarmUp(cfg.WarmUp); err != nil {
+		return fmt.Errorf("invalid warm_up: %w", err)
+	}
+
+	if err := validateRuleIDs(cfg.Rules); err != nil {
+		return err
+	}
+
 	for i, rule := range cfg.Rules {
-		if rule.ModelName == "" {
+		if err := validatePreset(rule); err != nil {
+			return fmt.Errorf("invalid preset in rule %d: %w", i, err)
+		}
+		if err := validateModelSelector(rule); err != nil {
+			return fmt.Errorf("invalid model_selector in rule %d: %w", i, err)
+		}
+		if rule.ModelName == "" && rule.ModelSelector == nil && rule.Aggregate == nil {
 			return fmt.Errorf("missing required field \"model_name\" for rule at index %d", i)
 		}
-		if len(rule.Inputs) == 0 {
+		if err := validateInputMap(rule); err != nil {
+			return fmt.Errorf("invalid input_map in rule %d: %w", i, err)
+		}
+		if err := validateTimeFeatures(rule); err != nil {
+			return fmt.Errorf("invalid time_features in rule %d: %w", i, err)
+		}
+		// Mapped inputs are validated like inputs from here on
+		rule.Inputs = rule.configuredInputs()
+		// Inputs are discovered from model metadata, which in-process backends do not have
+		if len(rule.Inputs) == 0 && rule.inProcess() {
 			return fmt.Errorf("missing required field \"inputs\" for rule at index %d", i)
 		}
+		if len(rule.Inputs) > 0 && rule.InputPrefix != "" {
+			return fmt.Errorf("input_prefix in rule %d requires inputs to be omitted", i)
+		}
+		// Inputs that are not valid selectors would never match a metric
+		for _, input := range rule.Inputs {
+			if _, err := parseLabelSelector(input); err != nil {
+				return fmt.Errorf("invalid input %q in rule %d: %w", input, i, err)
+			}
+		}
 		// Outputs are now optional - they can be discovered from model metadata
 		// We'll validate at runtime if neither configured nor discovered outputs exist
 
@@ -86,6 +572,195 @@ func (cfg *Config) Validate() error {
 				return fmt.Errorf("invalid output_pattern in rule %d: %w", i, err)
 			}
 		}
+
+		if rule.Synthetic != nil {
+			if err := validateSyntheticConfig(rule.Synthetic); err != nil {
+				return fmt.Errorf("invalid synthetic configuration in rule %d: %w", i, err)
+			}
+		}
+
+		if err := validateRuleCompression(rule.Compression); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", i, err)
+		}
+
+		if err := validateRuleEncoders(rule); err != nil {
+			return fmt.Errorf("invalid encoders in rule %d: %w", i, err)
+		}
+
+		if err := validateCombineInputs(rule); err != nil {
+			return fmt.Errorf("invalid combine_inputs in rule %d: %w", i, err)
+		}
+
+		if _, ok := rule.Parameters[paramExperimentID]; ok && rule.ExperimentID != "" {
+			return fmt.Errorf("experiment_id in rule %d is set both as a rule field and in parameters", i)
+		}
+		if _, ok := rule.Parameters[paramRunID]; ok && rule.RunID != "" {
+			return fmt.Errorf("run_id in rule %d is set both as a rule field and in parameters", i)
+		}
+
+		if err := validateParameterTemplates(rule); err != nil {
+			return fmt.Errorf("invalid parameters in rule %d: %w", i, err)
+		}
+
+		if err := validateOutputAttributes(rule.OutputAttributes); err != nil {
+			return fmt.Errorf("invalid output_attributes in rule %d: %w", i, err)
+		}
+
+		if err := validateForwardAttributes(rule); err != nil {
+			return fmt.Errorf("invalid forward_attributes in rule %d: %w", i, err)
+		}
+
+		if err := validateAttributePrivacy(rule.AttributePrivacy); err != nil {
+			return fmt.Errorf("invalid attribute_privacy in rule %d: %w", i, err)
+		}
+
+		if err := validateParameters(rule.Parameters); err != nil {
+			return fmt.Errorf("invalid parameters in rule %d: %w", i, err)
+		}
+
+		if err := validateResponseParameters(rule.ResponseParameters); err != nil {
+			return fmt.Errorf("invalid response_parameters in rule %d: %w", i, err)
+		}
+		if cfg.tracksOutputSeries() {
+			for _, param := range newResponseParameters(rule.ResponseParameters) {
+				if param.as == responseParameterAsAttribute {
+					return fmt.Errorf("invalid response_parameters in rule %d: parameter %q surfaced as an attribute can make a new series of every inference and cannot be combined with staleness or cardinality.max_series; use 'as: metric'", i, param.key)
+				}
+			}
+		}
+
+		if err := validateMixedScopes(rule.MixedScopes); err != nil {
+			return fmt.Errorf("invalid mixed_scopes in rule %d: %w", i, err)
+		}
+
+		if err := validateScheduling(rule); err != nil {
+			return fmt.Errorf("invalid scheduling in rule %d: %w", i, err)
+		}
+
+		if err := validateAutoDisable(rule); err != nil {
+			return fmt.Errorf("invalid auto_disable in rule %d: %w", i, err)
+		}
+
+		if err := validateTrigger(rule.Trigger); err != nil {
+			return fmt.Errorf("invalid trigger in rule %d: %w", i, err)
+		}
+
+		if err := validateExpansion(rule); err != nil {
+			return fmt.Errorf("invalid expansion in rule %d: %w", i, err)
+		}
+
+		if err := validateRuleMode(rule); err != nil {
+			return fmt.Errorf("invalid mode in rule %d: %w", i, err)
+		}
+
+		if err := validateChallenger(rule); err != nil {
+			return fmt.Errorf("invalid challenger in rule %d: %w", i, err)
+		}
+
+		if err := validateRuleOnError(rule); err != nil {
+			return fmt.Errorf("invalid on_error in rule %d: %w", i, err)
+		}
+		if rule.Sequence.Enabled && cfg.GRPCClientSettings.LoadBalancing == loadBalancingRoundRobin && len(cfg.GRPCClientSettings.Endpoints) > 1 {
+			return fmt.Errorf("invalid sequence in rule %d: round_robin load balancing would spread the sequence over several endpoints, each holding part of its state; use failover", i)
+		}
+		if rule.Sequence.Enabled && resolveOnError(cfg, rule) == onErrorRetry {
+			return fmt.Errorf("invalid on_error in rule %d: retry is not supported by sequence rules, whose server-side state cannot be rolled back", i)
+		}
+
+		if err := validatePartialGroups(rule.PartialGroups); err != nil {
+			return fmt.Errorf("invalid partial_groups in rule %d: %w", i, err)
+		}
+
+		if err := validateMatchingStrategy(rule.MatchingStrategy); err != nil {
+			return fmt.Errorf("invalid matching_strategy in rule %d: %w", i, err)
+		}
+
+		if err := validateRecordLatency(rule); err != nil {
+			return fmt.Errorf("invalid record_latency in rule %d: %w", i, err)
+		}
+		if rule.RecordLatency == recordLatencyAttributes && cfg.tracksOutputSeries() {
+			return fmt.Errorf("invalid record_latency in rule %d: 'attributes' makes a new series of every inference and cannot be combined with staleness or cardinality.max_series; use 'exemplar'", i)
+		}
+
+		if err := validateInputTransforms(rule); err != nil {
+			return fmt.Errorf("invalid transforms in rule %d: %w", i, err)
+		}
+
+		if err := validateLocalBackend(rule); err != nil {
+			return fmt.Errorf("invalid backend configuration in rule %d: %w", i, err)
+		}
+
+		if err := validateBuiltinBackend(rule); err != nil {
+			return fmt.Errorf("invalid backend configuration in rule %d: %w", i, err)
+		}
+
+		if err := validateRuleAggregate(rule); err != nil {
+			return fmt.Errorf("invalid aggregate in rule %d: %w", i, err)
+		}
+
+		if rule.Sequence.CorrelationID > math.MaxInt64 {
+			return fmt.Errorf("sequence.correlation_id in rule %d exceeds the maximum int64 value", i)
+		}
+
+		if err := validateControlInputs(rule); err != nil {
+			return fmt.Errorf("invalid sequence control inputs in rule %d: %w", i, err)
+		}
+
+		// Validate output post-processing transforms
+		for j, output := range rule.Outputs {
+			if _, err := parsePostTransforms(output.Post); err != nil {
+				return fmt.Errorf("invalid post transform for output %d in rule %d: %w", j, i, err)
+			}
+			if err := validateDescriptionTemplate(output.Description); err != nil {
+				return fmt.Errorf("invalid description template for output %d in rule %d: %w", j, i, err)
+			}
+			if cfg.Units.Validation == unitValidationStrict {
+				if err := validateUnit(cfg.Units.outputUnit(output.Unit), cfg.Units.Allowed); err != nil {
+					return fmt.Errorf("invalid unit for output %d in rule %d: %w", j, i, err)
+				}
+			}
+			if err := validateOutputColumns(output); err != nil {
+				return fmt.Errorf("invalid columns for output %d in rule %d: %w", j, i, err)
+			}
+			if err := validateFallbackConfig(output.Fallback); err != nil {
+				return fmt.Errorf("invalid fallback for output %d in rule %d: %w", j, i, err)
+			}
+			if rule.Mode == ruleModeShadow && output.Fallback.Policy != "" && output.Fallback.Policy != fallbackPolicySkip {
+				return fmt.Errorf("fallback for output %d in rule %d is not supported in shadow mode", j, i)
+			}
+			if err := validateSamplingHintConfig(output.SamplingHint); err != nil {
+				return fmt.Errorf("invalid sampling_hint for output %d in rule %d: %w", j, i, err)
+			}
+			if output.Horizon < 0 {
+				return fmt.Errorf("horizon for output %d in rule %d must not be negative", j, i)
+			}
+			if output.TensorName != "" && output.OutputIndex != nil {
+				return fmt.Errorf("tensor_name and output_index of output %d in rule %d are mutually exclusive", j, i)
+			}
+			if err := validateOutputTemporality(output); err != nil {
+				return fmt.Errorf("invalid temporality for output %d in rule %d: %w", j, i, err)
+			}
+			if output.Temporality == temporalityCumulative && rule.Challenger != nil {
+				return fmt.Errorf("cumulative temporality for output %d in rule %d is not supported with a challenger", j, i)
+			}
+			if err := validateHistogramOutput(output); err != nil {
+				return fmt.Errorf("invalid histogram for output %d in rule %d: %w", j, i, err)
+			}
+			if output.Histogram != nil && rule.Challenger != nil {
+				return fmt.Errorf("histogram output %d in rule %d is not supported with a challenger", j, i)
+			}
+			if err := validateEmbeddingOutput(output); err != nil {
+				return fmt.Errorf("invalid embedding for output %d in rule %d: %w", j, i, err)
+			}
+			if output.Embedding != nil && output.Embedding.VectorAttribute != "" {
+				if cfg.tracksOutputSeries() {
+					return fmt.Errorf("invalid embedding for output %d in rule %d: vector_attribute makes a new series of every inference and cannot be combined with staleness or cardinality.max_series", j, i)
+				}
+				if output.Fallback.Policy == fallbackPolicyLastValue {
+					return fmt.Errorf("invalid embedding for output %d in rule %d: vector_attribute makes a new series of every inference and cannot be combined with a last_value fallback", j, i)
+				}
+			}
+		}
 	}
 
 	// Validate data handling configuration
@@ -106,9 +781,77 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if err := validateAlignmentFill(cfg.DataHandling.Fill); err != nil {
+		return fmt.Errorf("invalid data_handling: %w", err)
+	}
+
+	if err := validateWindowLayout(cfg); err != nil {
+		return fmt.Errorf("invalid data_handling: %w", err)
+	}
+
+	if err := validateExponentialHistogramConfig(cfg.DataHandling.ExponentialHistogram); err != nil {
+		return fmt.Errorf("invalid data_handling.exponential_histogram: %w", err)
+	}
+
+	if cfg.MaxBatchDelay < 0 {
+		return fmt.Errorf("max_batch_delay must not be negative")
+	}
+
+	if cfg.MaxGroupsPerRequest < 0 {
+		return fmt.Errorf("max_groups_per_request must not be negative")
+	}
+
+	if cfg.StorageInterval < 0 {
+		return fmt.Errorf("storage_interval must not be negative")
+	}
+
+	if err := validateRemoteRules(cfg.RemoteRules); err != nil {
+		return fmt.Errorf("invalid remote_rules: %w", err)
+	}
+
+	if err := validateQueueConfig(cfg.Queue); err != nil {
+		return fmt.Errorf("invalid queue: %w", err)
+	}
+
+	if err := validateNonFiniteConfig(cfg.NonFinite); err != nil {
+		return fmt.Errorf("invalid non_finite: %w", err)
+	}
+
+	if cfg.Staleness.Period < 0 {
+		return fmt.Errorf("staleness.period must not be negative")
+	}
+
+	if err := validateCardinalityConfig(cfg.Cardinality); err != nil {
+		return fmt.Errorf("invalid cardinality: %w", err)
+	}
+
+	// Validate cache configuration
+	if cfg.Cache.Enabled {
+		if cfg.Cache.TTL <= 0 {
+			return fmt.Errorf("cache.ttl must be positive when the cache is enabled")
+		}
+		if cfg.Cache.MaxEntries <= 0 {
+			return fmt.Errorf("cache.max_entries must be positive when the cache is enabled")
+		}
+	}
+
 	return nil
 }
 
+// requiresInferenceServer reports whether any rule needs the remote inference server.
+// A configuration without rules still requires an endpoint.
+func (cfg *Config) requiresInferenceServer() bool {
+	if len(cfg.Rules) == 0 {
+		return true
+	}
+	for _, rule := range cfg.Rules {
+		if !rule.inProcess() {
+			return true
+		}
+	}
+	return false
+}
+
 // OutputSpec defines the specification for a single output from the inference model.
 type OutputSpec struct {
 	// Name specifies the name to use for the output metric.
@@ -121,14 +864,146 @@ type OutputSpec struct {
 	DataType string `mapstructure:"data_type"`
 
 	// Description specifies a description for the output metric.
+	// It may be a template using the same variables as OutputPattern
+	// ({output}, {model}, {version}, {input}, {input[N]}) plus {horizon}.
+	// Example: "Predicted {horizon} CPU utilization from {input} by {model} v{version}"
 	Description string `mapstructure:"description"`
 
+	// Horizon specifies how far ahead the output predicts, used by the {horizon}
+	// description variable (e.g. 15m).
+	Horizon time.Duration `mapstructure:"horizon"`
+
 	// Unit specifies the unit for the output metric.
 	Unit string `mapstructure:"unit"`
 
 	// OutputIndex specifies which output tensor to use (0-based index).
 	// If not specified, defaults to 0 for single output or matches by name.
 	OutputIndex *int `mapstructure:"output_index"`
+
+	// TensorName selects the output tensor by its name in the response, whatever
+	// its position, as Triton ensembles do not guarantee the order of their outputs.
+	// Mutually exclusive with OutputIndex.
+	TensorName string `mapstructure:"tensor_name"`
+
+	// Post specifies a chain of transforms applied to each output value before
+	// the data point is created, in order. Supported transforms:
+	//   clamp(min,max) - Limit the value to the given range
+	//   scale(factor)  - Multiply the value by factor
+	//   offset(delta)  - Add delta to the value
+	//   round(digits)  - Round the value to the given number of decimal places
+	//   convert(from,to) - Convert between units (e.g. "1" to "%", "s" to "ms", "By" to "MiBy")
+	// Example: ["clamp(0,1)", "scale(100)", "round(2)"]
+	Post []string `mapstructure:"post"`
+
+	// Columns controls how an output tensor shaped [N, M] is decoded. The first dimension
+	// maps to the matched attribute groups and the second dimension to:
+	//   "index" - one data point per column, with the column in IndexAttribute (default)
+	//   "split" - a separate metric per column, named "<output>.<column>"
+	// Outputs with a single value per row are not affected.
+	Columns string `mapstructure:"columns"`
+
+	// ColumnNames names the columns of the second dimension (e.g. ["p10", "p50", "p90"]).
+	// If not provided, columns are numbered from 0.
+	ColumnNames []string `mapstructure:"column_names"`
+
+	// IndexAttribute is the data point attribute holding the column in "index" mode.
+	// Defaults to "otel.inference.output.index".
+	IndexAttribute string `mapstructure:"index_attribute"`
+
+	// ElementNames names the elements of a fixed-size output vector, such as
+	// ["p50", "p90", "p99"]. The tensor is decoded as rows of that many elements
+	// whatever its shape, and the elements are handled as columns, so Columns
+	// selects separate metrics or an attribute per element. Mutually exclusive
+	// with ColumnNames.
+	ElementNames []string `mapstructure:"element_names"`
+
+	// Fallback defines the values emitted for this output when inference fails,
+	// so dashboards built on predictions do not go blank during model errors.
+	Fallback FallbackConfig `mapstructure:"fallback"`
+
+	// SamplingHint flags output values above a threshold, so trace sampling can be
+	// boosted while the model reports an incident.
+	SamplingHint *SamplingHintConfig `mapstructure:"sampling_hint"`
+
+	// Temporality is "gauge" (default) to emit values as the model returns them, or
+	// "cumulative" for models returning per-interval increments, which are then
+	// accumulated per series across batches into a cumulative sum.
+	Temporality string `mapstructure:"temporality"`
+
+	// AttachToInput also writes each value as an attribute named after the output
+	// metric, such as anomaly.score=0.93, on the input data points it was inferred
+	// from, for backends that enrich data points rather than join series.
+	AttachToInput bool `mapstructure:"attach_to_input"`
+
+	// Histogram assembles an output of bucket counts, such as a predicted latency
+	// distribution, into a histogram metric with a data point per matched
+	// attribute group, rather than a gauge point per bucket.
+	Histogram *HistogramOutputConfig `mapstructure:"histogram"`
+
+	// Embedding reduces an output of embedding vectors, a vector per matched
+	// attribute group, to a gauge point per vector, such as its similarity to a
+	// reference embedding, for embedding-drift monitoring.
+	Embedding *EmbeddingOutputConfig `mapstructure:"embedding"`
+}
+
+// EmbeddingOutputConfig defines how an output of embedding vectors is reduced.
+type EmbeddingOutputConfig struct {
+	// Reduce is the value of a vector's data point: "norm" (default), its L2 norm,
+	// "cosine_similarity", its cosine similarity with Reference, or
+	// "euclidean_distance", its distance to Reference.
+	Reduce string `mapstructure:"reduce"`
+
+	// Reference is the embedding vectors are compared with, such as the centroid
+	// of a baseline period. Required for cosine_similarity and euclidean_distance.
+	Reference []float64 `mapstructure:"reference"`
+
+	// VectorAttribute forwards the raw vector as an array attribute of this name
+	// on its data point. Vectors are not forwarded when empty.
+	VectorAttribute string `mapstructure:"vector_attribute"`
+}
+
+// HistogramOutputConfig defines how an output of bucket counts is assembled into a histogram.
+type HistogramOutputConfig struct {
+	// Bounds are the explicit bucket bounds, strictly increasing. The output has
+	// one more bucket than bounds, the last one counting values above the last bound.
+	Bounds []float64 `mapstructure:"bounds"`
+
+	// BoundsTensor names a second output tensor holding the bucket bounds, for
+	// models predicting them along with the counts. It holds one bound fewer than
+	// the buckets, shared by every row, or that many per row. Mutually exclusive
+	// with Bounds.
+	BoundsTensor string `mapstructure:"bounds_tensor"`
+}
+
+// SamplingHintConfig defines how anomalous output values are signaled to trace sampling.
+type SamplingHintConfig struct {
+	// Threshold is the output value above which a data point is anomalous.
+	Threshold float64 `mapstructure:"threshold"`
+
+	// Attribute is set to true on anomalous output data points, for use by
+	// attribute-based sampling policies. Defaults to "otel.inference.sampling.boost".
+	Attribute string `mapstructure:"attribute"`
+
+	// Metric, when set, names a marker gauge emitted with each result, keyed by
+	// the service.name of the resource: 1 while any output data point is
+	// anomalous and 0 otherwise.
+	Metric string `mapstructure:"metric"`
+}
+
+// FallbackConfig defines the fallback policy of an output.
+type FallbackConfig struct {
+	// Policy is one of:
+	//   "skip"       - emit nothing for the failed batch (default)
+	//   "last_value" - re-emit the last successful value of each attribute set
+	//   "constant"   - emit Value for each matched attribute group
+	Policy string `mapstructure:"policy"`
+
+	// Value is the value emitted by the "constant" policy.
+	Value float64 `mapstructure:"value"`
+
+	// TTL is how long a successful value may be re-emitted by the "last_value"
+	// policy. Default is 5 minutes.
+	TTL time.Duration `mapstructure:"ttl"`
 }
 
 // Rule defines a processing rule for metrics inference.
@@ -139,9 +1014,33 @@ type Rule struct {
 	// ModelVersion specifies the version of the model to use. If empty, the server will choose.
 	ModelVersion string `mapstructure:"model_version"`
 
+	// ModelSelector selects the model from the inference server's repository by
+	// labels instead of naming it with ModelName and ModelVersion.
+	ModelSelector *ModelSelectorConfig `mapstructure:"model_selector"`
+
+	// Preset configures the rule for a well-known model server runtime. With
+	// "alibi-drift", the is_drift, distance and p_val outputs of an Alibi-Detect
+	// drift detector served by MLServer are emitted as the drift.detected,
+	// drift.distance and drift.p_value metrics. Outputs and output_pattern
+	// configured on the rule replace the preset's.
+	Preset string `mapstructure:"preset"`
+
 	// Inputs specifies the list of metric names required as input for the model.
+	// When omitted, the inputs are discovered from the model's metadata: each
+	// input tensor is looked up as the metric of the same name.
 	Inputs []string `mapstructure:"inputs"`
 
+	// InputMap maps the model's input names to the metric selectors sent as them,
+	// so each tensor is named after the model input it feeds instead of relying
+	// on the order of inputs. Every input of the model's metadata must be mapped.
+	// Mutually exclusive with inputs.
+	InputMap map[string]string `mapstructure:"input_map"`
+
+	// InputPrefix is prepended to the model's input tensor names to form the
+	// names of the metrics looked up for them when inputs are discovered, such as
+	// "system." for a model taking "cpu.utilization". Requires inputs to be omitted.
+	InputPrefix string `mapstructure:"input_prefix"`
+
 	// Outputs specifies the list of outputs to create from the inference results.
 	// Each output represents a metric that will be created from the inference response.
 	Outputs []OutputSpec `mapstructure:"outputs"`
@@ -158,7 +1057,542 @@ type Rule struct {
 	OutputPattern string `mapstructure:"output_pattern"`
 
 	// Parameters contains additional parameters to pass to the inference service.
+	// Nested maps and lists are sent as JSON strings, for servers such as MLServer
+	// taking structured parameters.
 	Parameters map[string]interface{} `mapstructure:"parameters"`
+
+	// ResponseParameters surfaces parameters of the model's response, such as the
+	// is_drift and p_val of a drift detector, as attributes or metrics.
+	ResponseParameters []ResponseParameterConfig `mapstructure:"response_parameters"`
+
+	// Sequence configures stateful (sequence) model support.
+	Sequence SequenceConfig `mapstructure:"sequence"`
+
+	// Synthetic replaces the inference server with deterministic generated predictions
+	// for this rule, so pipelines and dashboards can be built before a model exists.
+	Synthetic *SyntheticConfig `mapstructure:"synthetic"`
+
+	// ID identifies the rule on the "otel.inference.rule_id" label added with the
+	// semantic-convention labels feature gate. Defaults to the rule's index.
+	ID string `mapstructure:"id"`
+
+	// InferenceLabels adds the labels identifying the model to every output data
+	// point. Set to false to leave them out, for low-cardinality requirements.
+	// Default is true.
+	InferenceLabels *bool `mapstructure:"inference_labels"`
+
+	// Route is stamped on every output data point as the "otel.route" attribute, so a
+	// routing connector can send this rule's outputs to a dedicated pipeline.
+	Route string `mapstructure:"route"`
+
+	// Scope names the instrumentation scope the rule's outputs are added to,
+	// overriding output_scope for this rule.
+	Scope string `mapstructure:"scope"`
+
+	// Compression overrides the compression of the rule's requests: "gzip", "zstd"
+	// or "none". Requests use the gRPC client's compression when empty.
+	Compression string `mapstructure:"compression"`
+
+	// ExperimentID and RunID tag the rule's inference with the identifiers of an
+	// experiment tracking system. They are sent as the "experiment_id" and "run_id"
+	// request parameters and stamped on every output data point as the
+	// "otel.inference.experiment.id" and "otel.inference.run.id" attributes.
+	ExperimentID string `mapstructure:"experiment_id"`
+	RunID        string `mapstructure:"run_id"`
+
+	// Backend selects where inference runs: "server" (default) calls the inference
+	// server, "local" evaluates the built-in function configured in Local in-process,
+	// and "builtin" computes the arithmetic configured in Builtin in-process.
+	Backend string `mapstructure:"backend"`
+
+	// Local configures the built-in function evaluated by the "local" backend.
+	Local *LocalConfig `mapstructure:"local"`
+
+	// Builtin configures the arithmetic computed by the "builtin" backend.
+	Builtin *BuiltinConfig `mapstructure:"builtin"`
+
+	// Aggregate makes the rule an aggregation rule, which combines the values of its
+	// inputs, typically the outputs of other rules, into one composite metric
+	// instead of calling a model. ModelName is optional for aggregation rules.
+	Aggregate *AggregateConfig `mapstructure:"aggregate"`
+
+	// Encoders selects, by input name, a registered tensor encoder that converts the
+	// input metric instead of the builtin conversion for its type. Custom encoders
+	// are registered with RegisterTensorEncoder in custom collector builds.
+	Encoders map[string]string `mapstructure:"encoders"`
+
+	// Transforms converts, by input name, cumulative inputs into per-series
+	// changes, aggregates and rescales inputs before they are encoded.
+	Transforms map[string]InputTransformConfig `mapstructure:"transforms"`
+
+	// TimeFeatures configures the calendar features sent to the model when the
+	// rule declares the synthetic __time_features__ input, in inputs or as a
+	// metric selector of input_map, for forecasting models taking calendar
+	// covariates that are not in the metric stream.
+	TimeFeatures *TimeFeaturesConfig `mapstructure:"time_features"`
+
+	// OutputAttributes controls how input data point attributes are copied onto
+	// output data points. By default every attribute is copied as "<input>.<key>".
+	OutputAttributes OutputAttributesConfig `mapstructure:"output_attributes"`
+
+	// ForwardAttributes sends resource and scope attributes of the rule's inputs to
+	// the model, so it can tell the entities it scores apart.
+	ForwardAttributes []ForwardAttributeConfig `mapstructure:"forward_attributes"`
+
+	// AttributePrivacy hashes or redacts attribute values before they are sent to
+	// the inference service in parameters, tensors or headers, for services
+	// operated in a different trust domain.
+	AttributePrivacy *AttributePrivacyConfig `mapstructure:"attribute_privacy"`
+
+	// MaxInFlight limits the inference requests of this rule running at once across
+	// concurrent batches. Batches wait for a free slot within the rule's deadline.
+	// Zero means no limit.
+	MaxInFlight int `mapstructure:"max_in_flight"`
+
+	// MaxGroupsPerRequest overrides the processor's max_groups_per_request for the
+	// rule. Zero uses the processor's.
+	MaxGroupsPerRequest int `mapstructure:"max_groups_per_request"`
+
+	// Deadline is the time budget of the rule's inference in a batch, including the
+	// wait for an in-flight slot. When it expires the rule is skipped for the batch.
+	// Zero uses the request timeout.
+	Deadline time.Duration `mapstructure:"deadline"`
+
+	// Priority orders the rule's calls in the inference queue: calls of a higher
+	// priority leave it first, and drop_oldest drops the calls of the lowest
+	// priority. Default is 0.
+	Priority int `mapstructure:"priority"`
+
+	// Queue gives the rule an inference queue with workers of its own instead of
+	// the processor's queue, so a slow model cannot starve the calls of other
+	// rules. Its queue_size must be positive.
+	Queue *QueueConfig `mapstructure:"queue"`
+
+	// Enabled turns the rule off when set to false, leaving it in the configuration.
+	// Default is true.
+	Enabled *bool `mapstructure:"enabled"`
+
+	// FeatureGate is the ID of a feature gate toggling the rule at runtime: the rule
+	// only infers while the gate is enabled. The gate must be registered when the
+	// collector starts, such as with RegisterRuleGate.
+	FeatureGate string `mapstructure:"feature_gate"`
+
+	// AutoDisable disables the rule for a cool-down period when too many of its
+	// inference calls fail, so a broken model stops spending the batch's latency
+	// budget. Nil never disables the rule.
+	AutoDisable *AutoDisableConfig `mapstructure:"auto_disable"`
+
+	// Trigger decides when the rule infers: on the arrival of every batch (default),
+	// or on a timer, from the inputs accumulated since the last inference.
+	Trigger TriggerConfig `mapstructure:"trigger"`
+
+	// ExpandBy names a resource or data point attribute, such as host.name, the rule
+	// is expanded over: it infers once per distinct value of the attribute and
+	// resource holding it, with separate state for each, and its outputs are
+	// labeled with the value.
+	ExpandBy string `mapstructure:"expand_by"`
+
+	// Mode is "active" (default), where outputs are added to the batch, or "shadow",
+	// where the rule infers and reports its results without adding its outputs, so
+	// a new model can be validated safely before it is rolled out.
+	Mode string `mapstructure:"mode"`
+
+	// Shadow configures what a rule in shadow mode reports.
+	Shadow ShadowConfig `mapstructure:"shadow"`
+
+	// Challenger compares a second model with the rule's model, the champion, on
+	// the same inputs. The champion's outputs are emitted as usual, along with a
+	// metric per output comparing the two models.
+	Challenger *ChallengerConfig `mapstructure:"challenger"`
+
+	// OnError overrides the processor's on_error policy for the rule.
+	OnError string `mapstructure:"on_error"`
+
+	// PartialGroups decides what happens to an attribute group that lacks some of
+	// the rule's inputs: "skip" (default) leaves it out, "broadcast_missing" sends
+	// NaN for the missing gauge and sum inputs, so the model sees them as missing,
+	// and "fill_zero" sends zero in their place.
+	PartialGroups string `mapstructure:"partial_groups"`
+
+	// MatchingStrategy decides how the data points of the rule's inputs are matched
+	// into attribute groups: "broadcast" also joins inputs with a single attribute
+	// set to every group, "strict" only matches identical attribute sets, and
+	// "cartesian" infers on every combination of the inputs' attribute sets.
+	// Defaults to "broadcast", or "strict" when the
+	// processor.metricsinference.broadcastMatching feature gate is disabled.
+	MatchingStrategy string `mapstructure:"matching_strategy"`
+
+	// MixedScopes decides where the outputs go when the rule's inputs are read
+	// from several instrumentation scopes: "first" (default) adds them to the
+	// scope of the first input, "dedicated" to the "opentelemetry.inference"
+	// scope of its resource, and "duplicate" adds a copy to the scope of every
+	// input. Rules with an output scope keep their outputs there.
+	MixedScopes string `mapstructure:"mixed_scopes"`
+
+	// RecordLatency records the duration of the rule's inference and its request
+	// ID on every gauge and sum output data point, so investigating a slow model
+	// can start from its outputs: "exemplar" adds an exemplar carrying them and
+	// the trace of the request, "attributes" adds them as attributes.
+	RecordLatency string `mapstructure:"record_latency"`
+
+	// CombineInputs sends the rule's inputs as one feature matrix instead of a
+	// tensor per input, as models taking a single feature matrix expect.
+	CombineInputs *CombineInputsConfig `mapstructure:"combine_inputs"`
+}
+
+// CombineInputsConfig merges a rule's inputs into one 2D tensor. Each input
+// contributes its window, or its value per matched data point group, so the
+// tensor is [1, n_inputs] for the latest values and [window, n_inputs] for
+// windows in the "columns" layout.
+type CombineInputsConfig struct {
+	// TensorName is the name of the combined tensor. Default is "input".
+	TensorName string `mapstructure:"tensor_name"`
+
+	// Layout is "columns" (default), a column per input, or "rows", a row per input.
+	Layout string `mapstructure:"layout"`
+}
+
+// TimeFeaturesConfig selects the calendar features generated for a rule's
+// __time_features__ input, sent as a [rows, features] tensor with a row per
+// matched attribute group.
+type TimeFeaturesConfig struct {
+	// Features lists the features of every row, in order: hour_of_day,
+	// day_of_week (0 is Monday), day_of_month, month, and the cyclic encodings
+	// hour_sin, hour_cos, day_of_week_sin and day_of_week_cos. Default is
+	// hour_of_day, day_of_week and the four cyclic encodings.
+	Features []string `mapstructure:"features"`
+
+	// Timezone is the IANA time zone the calendar is read in, such as
+	// "Europe/Paris". Default is UTC.
+	Timezone string `mapstructure:"timezone"`
+}
+
+// ModelSelectorConfig selects a rule's model by labels. The ready models of the
+// repository are matched against the parameters of their metadata when the
+// processor starts and whenever model metadata is refreshed.
+type ModelSelectorConfig struct {
+	// Labels must all equal metadata parameters of the model, such as
+	// {task: forecast, metric: cpu}.
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Repository limits the search to one model repository. Default is every
+	// repository of the server.
+	Repository string `mapstructure:"repository"`
+}
+
+// AutoDisableConfig defines when a failing rule is disabled, and for how long.
+type AutoDisableConfig struct {
+	// FailureRatio is the fraction of the rule's calls in the window that must be
+	// exceeded by its failed calls for the rule to be disabled. Default is 0.5.
+	FailureRatio float64 `mapstructure:"failure_ratio"`
+
+	// Window is the sliding window the failure ratio is computed over. Default is 5 minutes.
+	Window time.Duration `mapstructure:"window"`
+
+	// MinRequests is the number of calls the window must hold before the rule can
+	// be disabled, so a few early failures do not disable it. Default is 10.
+	MinRequests int `mapstructure:"min_requests"`
+
+	// Cooldown is how long the rule stays disabled before a probe call is let
+	// through: the rule is enabled again when the probe succeeds, and disabled for
+	// another cool-down when it fails. Default is 1 minute.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// TriggerConfig defines when a rule infers.
+type TriggerConfig struct {
+	// Mode is "arrival" (default), where the rule infers on every batch, or
+	// "interval", where it infers every Every from the inputs accumulated since its
+	// last inference and its outputs are emitted with the next batch.
+	Mode string `mapstructure:"mode"`
+
+	// Every is the interval between inferences in "interval" mode.
+	Every time.Duration `mapstructure:"every"`
+}
+
+// ChallengerConfig defines a model compared with a rule's model.
+type ChallengerConfig struct {
+	// ModelName specifies the challenger model.
+	ModelName string `mapstructure:"model_name"`
+
+	// ModelVersion specifies the challenger model version. If empty, the server will choose.
+	ModelVersion string `mapstructure:"model_version"`
+
+	// Comparison is one of:
+	//   "delta"     - emit "<output>.challenger_delta", the challenger's value minus the champion's (default)
+	//   "agreement" - emit "<output>.challenger_agreement", 1 when the values differ by at most Tolerance, 0 otherwise
+	Comparison string `mapstructure:"comparison"`
+
+	// Tolerance is the largest difference counted as agreement.
+	Tolerance float64 `mapstructure:"tolerance"`
+}
+
+// ShadowConfig defines what a rule in shadow mode reports besides its latency.
+type ShadowConfig struct {
+	// SampleRate is the fraction of batches, between 0 and 1, whose outputs are
+	// emitted with the "shadow" attribute set to true. Default is 0, which only
+	// logs output values at debug level.
+	SampleRate float64 `mapstructure:"sample_rate"`
+}
+
+// ForwardAttributeConfig defines a resource or scope attribute sent to the model.
+type ForwardAttributeConfig struct {
+	// Key is the attribute key, e.g. "host.name".
+	Key string `mapstructure:"key"`
+
+	// Source is where the attribute is read from: "resource" (default) or "scope".
+	Source string `mapstructure:"source"`
+
+	// As is how the attribute is sent:
+	//   "parameter" - a string request parameter, omitted when the attribute is missing (default)
+	//   "tensor"    - a BYTES input tensor with one element per matched attribute group
+	As string `mapstructure:"as"`
+
+	// Name is the parameter or tensor name. Defaults to Key.
+	Name string `mapstructure:"name"`
+}
+
+// ResponseParameterConfig defines a response parameter surfaced by a rule.
+type ResponseParameterConfig struct {
+	// Key is the parameter key in the response, e.g. "is_drift".
+	Key string `mapstructure:"key"`
+
+	// As is how the parameter is surfaced:
+	//   "attribute" - an attribute of every output data point of the response, keeping its type (default)
+	//   "metric"    - a gauge of its own; bools are 1 or 0 and strings are parsed as numbers
+	As string `mapstructure:"as"`
+
+	// Name is the attribute or metric name. Defaults to Key.
+	Name string `mapstructure:"name"`
+}
+
+// AttributePrivacyConfig defines how attribute values are protected before they are
+// sent to the inference service.
+type AttributePrivacyConfig struct {
+	// Hash lists the attribute keys whose values are sent as hashes, so the model
+	// can still tell entities apart without learning their identity.
+	Hash []string `mapstructure:"hash"`
+
+	// Redact lists the attribute keys whose values are sent as "[redacted]".
+	Redact []string `mapstructure:"redact"`
+
+	// ResourceAttributes is what happens to the values of the resource attributes
+	// not listed in Hash or Redact: "keep" (default), "hash" or "redact", so no raw
+	// resource identifier reaches the inference service.
+	ResourceAttributes string `mapstructure:"resource_attributes"`
+
+	// Key is the secret key hashes are computed with as HMAC-SHA256, so hashed
+	// identifiers cannot be recovered by hashing candidate values. Without it,
+	// values are hashed with plain SHA-256.
+	Key string `mapstructure:"key"`
+}
+
+// OutputAttributesConfig defines the attributes of a rule's output data points.
+type OutputAttributesConfig struct {
+	// Mode is one of:
+	//   "namespaced" - copy attributes as "<input>.<key>" string attributes (default)
+	//   "as_is"      - copy attributes under their own key and type, so outputs
+	//                  join with the input series
+	Mode string `mapstructure:"mode"`
+
+	// Include lists the input attribute keys to copy. When empty, every key not in
+	// Exclude is copied. Include and Exclude are mutually exclusive.
+	Include []string `mapstructure:"include"`
+	Exclude []string `mapstructure:"exclude"`
+
+	// Rename maps output attribute keys, after namespacing, to the keys to use instead.
+	Rename map[string]string `mapstructure:"rename"`
+
+	// Static attributes are added to every output data point, overriding copied
+	// attributes with the same key.
+	Static map[string]string `mapstructure:"static"`
+
+	// OnConflict resolves a key copied from several inputs with different values:
+	//   "first"     - keep the value of the first input in rule order (default)
+	//   "namespace" - copy the conflicting attribute as "<input>.<key>" for each input
+	//   "drop"      - omit the attribute
+	OnConflict string `mapstructure:"on_conflict"`
+}
+
+// InputTransformConfig defines how an input's values are fed to the model.
+type InputTransformConfig struct {
+	// As is "value" (default) for the raw values, "delta" for the change since
+	// the previous data point of the series, or "rate" for that change per second.
+	As string `mapstructure:"as"`
+
+	// StaleAfter is the gap between two data points of a series after which the
+	// previous point is considered stale and the series starts over. Default is 5 minutes.
+	StaleAfter time.Duration `mapstructure:"stale_after"`
+
+	// Aggregate reduces the input's data points to a single value before it is
+	// encoded: "sum", "avg", "max" or "p95". Aggregation is applied after the
+	// delta or rate conversion and before scaling. Default is no aggregation.
+	Aggregate string `mapstructure:"aggregate"`
+
+	// Normalize rescales values to [0, 1] using a min and max. Mutually exclusive
+	// with Standardize. Scaling is applied after the delta or rate conversion.
+	Normalize *NormalizeConfig `mapstructure:"normalize"`
+
+	// Standardize rescales values to zero mean and unit deviation.
+	Standardize *StandardizeConfig `mapstructure:"standardize"`
+
+	// ExposeScaling stamps the statistics the input was scaled with on every
+	// output data point as "<input>.scaling.<statistic>" attributes.
+	ExposeScaling bool `mapstructure:"expose_scaling"`
+}
+
+// NormalizeConfig defines min-max scaling of an input, with fixed bounds or with
+// the minimum and maximum of recent values.
+type NormalizeConfig struct {
+	// Min and Max are the fixed bounds mapped to 0 and 1.
+	Min float64 `mapstructure:"min"`
+	Max float64 `mapstructure:"max"`
+
+	// Auto uses the minimum and maximum of the most recent values instead of fixed bounds.
+	Auto bool `mapstructure:"auto"`
+
+	// Window is the number of recent values used by Auto. Default is 100.
+	Window int `mapstructure:"window"`
+}
+
+// StandardizeConfig defines z-score scaling of an input, with fixed statistics or
+// with the mean and standard deviation of recent values.
+type StandardizeConfig struct {
+	// Mean and Std are the fixed statistics, typically those of the training data.
+	Mean float64 `mapstructure:"mean"`
+	Std  float64 `mapstructure:"std"`
+
+	// Auto uses the mean and standard deviation of the most recent values instead of fixed statistics.
+	Auto bool `mapstructure:"auto"`
+
+	// Window is the number of recent values used by Auto. Default is 100.
+	Window int `mapstructure:"window"`
+}
+
+// LocalConfig defines a built-in model evaluated in-process by the local backend.
+// Functions keep rolling state per series of the rule's single input.
+type LocalConfig struct {
+	// Function is the model: "ewma", "zscore", "mad", "linear_regression", or
+	// "holt_winters".
+	Function string `mapstructure:"function"`
+
+	// Alpha is the smoothing factor for "ewma", and of the level for
+	// "holt_winters", in (0, 1].
+	Alpha float64 `mapstructure:"alpha"`
+
+	// Beta is the smoothing factor of the trend for "holt_winters", in [0, 1].
+	Beta float64 `mapstructure:"beta"`
+
+	// Gamma is the smoothing factor of the seasonal component for "holt_winters",
+	// in [0, 1].
+	Gamma float64 `mapstructure:"gamma"`
+
+	// Season is the number of values in one season for "holt_winters", such as
+	// 24 for hourly values with a daily cycle. Zero means no seasonality.
+	Season int `mapstructure:"season"`
+
+	// Window is the number of recent values used by "zscore", "mad" and
+	// "linear_regression". Defaults to 10.
+	Window int `mapstructure:"window"`
+
+	// Horizon is the number of steps ahead "linear_regression" and "holt_winters"
+	// forecast. Defaults to 1.
+	Horizon int `mapstructure:"horizon"`
+}
+
+// BuiltinConfig defines a derived metric computed in-process by the builtin
+// backend, for trivial calculations that do not warrant a model server round trip.
+type BuiltinConfig struct {
+	// Operation is "add", "subtract", "multiply", "divide", "percent", or "scale".
+	// "percent" divides the first operand by the second and multiplies by 100.
+	Operation string `mapstructure:"operation"`
+
+	// Operand is the constant right operand of a rule with a single input, and
+	// the factor of "scale". Rules with two inputs use the second input instead.
+	Operand *float64 `mapstructure:"operand"`
+}
+
+// AggregateConfig defines how an aggregation rule combines its inputs, such as
+// the maximum anomaly score across the cpu, memory and disk models of a host.
+type AggregateConfig struct {
+	// Function is "sum", "avg", "max", or "p95", as for input transforms.
+	Function string `mapstructure:"function"`
+
+	// GroupBy lists the attributes values are combined by: one output data point
+	// is produced per distinct combination of their values in a resource. Keys
+	// are also found namespaced by an input, as copied by earlier rules. When
+	// empty, all values of a resource are combined into one data point.
+	GroupBy []string `mapstructure:"group_by"`
+}
+
+// SyntheticConfig defines a deterministic signal produced in place of model outputs.
+// Values depend only on the configuration and the current time.
+type SyntheticConfig struct {
+	// Function is the signal shape: "sine", "step", or "constant".
+	Function string `mapstructure:"function"`
+
+	// Offset is the baseline value (the constant value for "constant").
+	Offset float64 `mapstructure:"offset"`
+
+	// Amplitude is the peak deviation from the offset for "sine" and the step height for "step".
+	Amplitude float64 `mapstructure:"amplitude"`
+
+	// Period is the duration of one full cycle for "sine" and "step".
+	Period time.Duration `mapstructure:"period"`
+
+	// Seed shifts the phase of each generated series deterministically.
+	Seed int64 `mapstructure:"seed"`
+}
+
+// SequenceConfig defines how requests for stateful models are grouped into a sequence.
+// When enabled, the Triton sequence batcher parameters (sequence_id, sequence_start,
+// sequence_end) are sent with every request, and the sequence is ended on shutdown
+// so the server can release the sequence slot instead of waiting for it to time out.
+type SequenceConfig struct {
+	// Enabled turns on sequence control parameters for the rule.
+	Enabled bool `mapstructure:"enabled"`
+
+	// CorrelationID is the sequence ID sent to the server.
+	// If zero, a stable ID is derived from the model name and rule index.
+	CorrelationID uint64 `mapstructure:"correlation_id"`
+
+	// ControlInputs names CONTROL input tensors that carry the sequence flags and
+	// correlation IDs alongside the data inputs, for models that read them directly.
+	ControlInputs ControlInputsConfig `mapstructure:"control_inputs"`
+
+	// PerSeries derives a correlation ID from each matched attribute set, so the model
+	// can keep separate state for every series. Requires control_inputs.correlation_id.
+	PerSeries bool `mapstructure:"per_series"`
+}
+
+// ControlInputsConfig defines the names of the Triton sequence CONTROL input tensors.
+// Empty names are not sent.
+type ControlInputsConfig struct {
+	// Start is the CONTROL_SEQUENCE_START tensor, set for the first request of a series.
+	Start string `mapstructure:"start"`
+
+	// End is the CONTROL_SEQUENCE_END tensor, set when a series is ended on shutdown.
+	End string `mapstructure:"end"`
+
+	// Ready is the CONTROL_SEQUENCE_READY tensor, set for every series with data.
+	Ready string `mapstructure:"ready"`
+
+	// CorrelationID is the CONTROL_SEQUENCE_CORRID tensor, sent as UINT64.
+	CorrelationID string `mapstructure:"correlation_id"`
+
+	// DataType is the datatype of the start, end and ready flags: "INT32" (default), "BOOL", or "FP32".
+	DataType string `mapstructure:"data_type"`
+}
+
+// names returns the configured control tensor names
+func (c ControlInputsConfig) names() []string {
+	var names []string
+	for _, name := range []string{c.Start, c.End, c.Ready, c.CorrelationID} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 // DataHandlingConfig defines how metric data points are processed for inference
@@ -182,4 +1616,39 @@ type DataHandlingConfig struct {
 	// TimestampTolerance specifies the maximum time difference (in milliseconds) between
 	// data points to consider them temporally aligned. Default is 1000 (1 second).
 	TimestampTolerance int64 `mapstructure:"timestamp_tolerance"`
+
+	// Fill completes timestamps at which some inputs lack a data point within the
+	// tolerance, as when the scrape phases of metrics drift apart: "drop" (default)
+	// skips the timestamp, "ffill" repeats the input's previous data point and
+	// "linear" interpolates gauge and sum values between the data points around it.
+	Fill string `mapstructure:"fill"`
+
+	// WindowLayout sends the aligned windows of a rule's inputs as one 2D tensor in
+	// "window" mode, as multivariate models such as LSTM autoencoders expect:
+	// "time" has a row per timestamp ([window, n_inputs]), "features" a row per
+	// input ([n_inputs, window]). By default every input is a separate tensor.
+	// Rules with a single input are not affected.
+	WindowLayout string `mapstructure:"window_layout"`
+
+	// WindowTensorName is the name of the 2D window tensor. Default is "input".
+	WindowTensorName string `mapstructure:"window_tensor_name"`
+
+	// ExponentialHistogram converts exponential histogram inputs into fixed-length
+	// feature vectors instead of flattening their buckets as recorded.
+	ExponentialHistogram ExponentialHistogramConfig `mapstructure:"exponential_histogram"`
+}
+
+// ExponentialHistogramConfig defines fixed-length feature vectors for exponential
+// histogram inputs. Data points recorded at different scales and offsets are rescaled
+// to a common scale and bucket range, so models receive constant-shape inputs.
+type ExponentialHistogramConfig struct {
+	// Scale is the target scale buckets are rescaled to, in [-10, 20].
+	Scale int32 `mapstructure:"scale"`
+
+	// Buckets is the number of buckets kept for each of the positive and negative ranges.
+	// Default is 0, which keeps the original flattening of recorded buckets.
+	Buckets int `mapstructure:"buckets"`
+
+	// Offset is the bucket index, at the target scale, of the first kept bucket.
+	Offset int32 `mapstructure:"offset"`
 }
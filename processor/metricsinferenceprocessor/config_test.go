@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -61,6 +62,17 @@ func TestLoadConfig(t *testing.T) {
 					AlignTimestamps:    true,
 					TimestampTolerance: 1000,
 				},
+				Cache: CacheConfig{
+					TTL:        time.Minute,
+					MaxEntries: 1000,
+				},
+				Units: UnitsConfig{
+					Validation: "warn",
+				},
+				Logging: LoggingConfig{
+					RepeatInterval:    time.Minute,
+					MaxRepeatInterval: 15 * time.Minute,
+				},
 			},
 		},
 		{
@@ -0,0 +1,172 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Temporalities of output metrics
+const (
+	temporalityGauge      = "gauge"      // Values are emitted as the model returns them
+	temporalityCumulative = "cumulative" // Values are per-interval increments, accumulated into a cumulative sum
+)
+
+// cumulativeSeriesTTL is how long the running total of a series that is no
+// longer produced is kept. A series produced again after that restarts from zero.
+const cumulativeSeriesTTL = time.Hour
+
+// validateOutputTemporality checks an output's temporality. Accumulated outputs
+// are sums, which the gauge fallbacks cannot stand in for.
+func validateOutputTemporality(output OutputSpec) error {
+	switch output.Temporality {
+	case "", temporalityGauge:
+		return nil
+	case temporalityCumulative:
+	default:
+		return fmt.Errorf("invalid temporality %q (must be 'gauge' or 'cumulative')", output.Temporality)
+	}
+	if output.Fallback.Policy != "" && output.Fallback.Policy != fallbackPolicySkip {
+		return errors.New("fallback is not supported with cumulative temporality")
+	}
+	return nil
+}
+
+// cumulativeSeries is the running total of one output series
+type cumulativeSeries struct {
+	start    pcommon.Timestamp // Start of the first accumulated interval
+	last     pcommon.Timestamp // Timestamp of the last accumulated increment
+	sum      float64
+	intSum   int64
+	isInt    bool
+	recorded time.Time
+}
+
+// cumulativeStore accumulates the per-interval increments of outputs with
+// cumulative temporality, per output, resource, metric name and attribute set
+type cumulativeStore struct {
+	mu        sync.Mutex
+	series    map[lastValueOutput]map[string]*cumulativeSeries // Output -> resource, metric and attribute set key -> series
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// newCumulativeStore creates an empty store
+func newCumulativeStore() *cumulativeStore {
+	return &cumulativeStore{
+		series: make(map[lastValueOutput]map[string]*cumulativeSeries),
+		now:    time.Now,
+	}
+}
+
+// accumulate adds the increments in the gauges of metrics[first:], which were
+// produced for one output, to the running totals of their series, and turns the
+// gauges into cumulative sums of those totals. A series starts at the start
+// timestamp of its first increment, or its timestamp when unset. Increments no
+// later than the last one accumulated, as in a replayed batch, are not added
+// again, so the sum does not count them twice.
+func (s *cumulativeStore) accumulate(ruleIdx, outputIdx int, resource pcommon.Resource, metrics pmetric.MetricSlice, first int, monotonic bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	key := lastValueOutput{ruleIdx: ruleIdx, outputIdx: outputIdx}
+	bySeries, exists := s.series[key]
+	if !exists {
+		bySeries = make(map[string]*cumulativeSeries)
+		s.series[key] = bySeries
+	}
+
+	prefix := attributeSetKey(resource.Attributes()) + "\x00"
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if metric.Type() != pmetric.MetricTypeGauge {
+			continue
+		}
+		dps := pmetric.NewNumberDataPointSlice()
+		metric.Gauge().DataPoints().MoveAndAppendTo(dps)
+		sum := metric.SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		sum.SetIsMonotonic(monotonic)
+
+		for j := 0; j < dps.Len(); j++ {
+			dp := dps.At(j)
+			seriesKey := prefix + metric.Name() + "\x00" + attributeSetKey(dp.Attributes())
+			series, exists := bySeries[seriesKey]
+			if !exists {
+				series = &cumulativeSeries{start: dp.StartTimestamp(), isInt: dp.ValueType() == pmetric.NumberDataPointValueTypeInt}
+				if series.start == 0 {
+					series.start = dp.Timestamp()
+				}
+				bySeries[seriesKey] = series
+			}
+			series.add(dp)
+			series.recorded = now
+
+			dp.SetStartTimestamp(series.start)
+			if series.isInt {
+				dp.SetIntValue(series.intSum)
+			} else {
+				dp.SetDoubleValue(series.sum)
+			}
+		}
+		dps.MoveAndAppendTo(sum.DataPoints())
+	}
+}
+
+// add accumulates the value of a data point unless it is not newer than the
+// last increment. Non-finite increments are skipped.
+func (c *cumulativeSeries) add(dp pmetric.NumberDataPoint) {
+	if c.last != 0 && dp.Timestamp() <= c.last {
+		return
+	}
+	c.last = dp.Timestamp()
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		c.intSum += dp.IntValue()
+		c.sum += float64(dp.IntValue())
+		return
+	}
+	if math.IsNaN(dp.DoubleValue()) || math.IsInf(dp.DoubleValue(), 0) {
+		return
+	}
+	c.isInt = false
+	c.sum += dp.DoubleValue()
+}
+
+// forgetRules forgets the running totals of the rules from index from on, whose
+// rules were replaced
+func (s *cumulativeStore) forgetRules(from int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for output := range s.series {
+		if output.ruleIdx >= from {
+			delete(s.series, output)
+		}
+	}
+}
+
+// prune forgets series not produced within cumulativeSeriesTTL, checking at most
+// once per TTL. The caller must hold s.mu.
+func (s *cumulativeStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < cumulativeSeriesTTL {
+		return
+	}
+	s.lastPrune = now
+	for _, bySeries := range s.series {
+		for key, series := range bySeries {
+			if now.Sub(series.recorded) > cumulativeSeriesTTL {
+				delete(bySeries, key)
+			}
+		}
+	}
+}
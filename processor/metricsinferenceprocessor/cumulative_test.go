@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// incrementGauge returns a metric slice with one gauge holding an increment of a host
+func incrementGauge(timestamp pcommon.Timestamp, host string, increment float64) pmetric.MetricSlice {
+	metrics := pmetric.NewMetricSlice()
+	metric := metrics.AppendEmpty()
+	metric.SetName("requests.predicted")
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("host.name", host)
+	dp.SetTimestamp(timestamp)
+	dp.SetDoubleValue(increment)
+	return metrics
+}
+
+func TestCumulativeStoreAccumulate(t *testing.T) {
+	store := newCumulativeStore()
+	resource := pcommon.NewResource()
+
+	first := incrementGauge(1000, "a", 2)
+	store.accumulate(0, 0, resource, first, 0, true)
+	sum := first.At(0).Sum()
+	assert.Equal(t, pmetric.AggregationTemporalityCumulative, sum.AggregationTemporality())
+	assert.True(t, sum.IsMonotonic())
+	assert.Equal(t, 2.0, sum.DataPoints().At(0).DoubleValue())
+	assert.Equal(t, pcommon.Timestamp(1000), sum.DataPoints().At(0).StartTimestamp())
+
+	second := incrementGauge(2000, "a", 3)
+	store.accumulate(0, 0, resource, second, 0, true)
+	dp := second.At(0).Sum().DataPoints().At(0)
+	assert.Equal(t, 5.0, dp.DoubleValue())
+	assert.Equal(t, pcommon.Timestamp(1000), dp.StartTimestamp())
+
+	// A replayed increment is not counted twice
+	replayed := incrementGauge(2000, "a", 3)
+	store.accumulate(0, 0, resource, replayed, 0, true)
+	assert.Equal(t, 5.0, replayed.At(0).Sum().DataPoints().At(0).DoubleValue())
+
+	// Other series and other outputs have their own totals
+	other := incrementGauge(2000, "b", 1)
+	store.accumulate(0, 0, resource, other, 0, true)
+	assert.Equal(t, 1.0, other.At(0).Sum().DataPoints().At(0).DoubleValue())
+	otherOutput := incrementGauge(3000, "a", 7)
+	store.accumulate(0, 1, resource, otherOutput, 0, false)
+	assert.Equal(t, 7.0, otherOutput.At(0).Sum().DataPoints().At(0).DoubleValue())
+	assert.False(t, otherOutput.At(0).Sum().IsMonotonic())
+}
+
+func TestCumulativeStoreRestart(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := newCumulativeStore()
+	store.now = func() time.Time { return now }
+	resource := pcommon.NewResource()
+
+	store.accumulate(0, 0, resource, incrementGauge(1000, "a", 2), 0, true)
+
+	// Saved totals continue after a restart
+	restarted := newCumulativeStore()
+	restarted.restore(0, store.snapshot(0))
+	metrics := incrementGauge(2000, "a", 3)
+	restarted.accumulate(0, 0, resource, metrics, 0, true)
+	assert.Equal(t, 5.0, metrics.At(0).Sum().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, pcommon.Timestamp(1000), metrics.At(0).Sum().DataPoints().At(0).StartTimestamp())
+
+	// A series not produced for longer than the TTL starts over
+	now = now.Add(2 * cumulativeSeriesTTL)
+	metrics = incrementGauge(5000, "a", 4)
+	store.accumulate(0, 0, resource, metrics, 0, true)
+	assert.Equal(t, 4.0, metrics.At(0).Sum().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, pcommon.Timestamp(5000), metrics.At(0).Sum().DataPoints().At(0).StartTimestamp())
+}
+
+func TestCumulativeOutput(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 2)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "forecaster",
+			Inputs:        []string{"requests"},
+			OutputPattern: "requests.{output}",
+			Outputs:       []OutputSpec{{Name: "predicted", Temporality: temporalityCumulative}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for i := 0; i < 2; i++ {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"requests"},
+			MetricValues: [][]float64{{10}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+
+	batches := sink.AllMetrics()
+	require.Len(t, batches, 2)
+	var starts []pcommon.Timestamp
+	for i, expected := range []float64{2, 4} {
+		metric := findMetricByName(batches[i], "requests.predicted")
+		require.Equal(t, pmetric.MetricTypeSum, metric.Type())
+		assert.Equal(t, pmetric.AggregationTemporalityCumulative, metric.Sum().AggregationTemporality())
+		dp := metric.Sum().DataPoints().At(0)
+		assert.Equal(t, expected, dp.DoubleValue())
+		starts = append(starts, dp.StartTimestamp())
+	}
+	assert.Equal(t, starts[0], starts[1])
+}
+
+func TestValidateOutputTemporality(t *testing.T) {
+	assert.NoError(t, validateOutputTemporality(OutputSpec{}))
+	assert.NoError(t, validateOutputTemporality(OutputSpec{Temporality: temporalityCumulative}))
+	assert.ErrorContains(t, validateOutputTemporality(OutputSpec{Temporality: "delta"}), "invalid temporality")
+	assert.ErrorContains(t, validateOutputTemporality(OutputSpec{
+		Temporality: temporalityCumulative,
+		Fallback:    FallbackConfig{Policy: fallbackPolicyLastValue},
+	}), "fallback")
+}
@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// dataPoint is the part of a data point used to preserve attributes and match
+// inputs. Number, histogram, exponential histogram and summary data points all
+// implement it, so attribute groups work regardless of the input metric type.
+type dataPoint interface {
+	Attributes() pcommon.Map
+	StartTimestamp() pcommon.Timestamp
+	Timestamp() pcommon.Timestamp
+}
+
+// extractDataPoints returns the data points of a metric of any type, for
+// attribute copying and group matching
+func extractDataPoints(metric pmetric.Metric) []dataPoint {
+	var dataPoints []dataPoint
+
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		for _, dp := range extractNumberDataPoints(metric) {
+			dataPoints = append(dataPoints, dp)
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dataPoints = append(dataPoints, dps.At(i))
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dataPoints = append(dataPoints, dps.At(i))
+		}
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dataPoints = append(dataPoints, dps.At(i))
+		}
+	}
+
+	return dataPoints
+}
+
+// extractNumberDataPoints returns the data points of a gauge or sum, or nil for
+// other metric types
+func extractNumberDataPoints(metric pmetric.Metric) []pmetric.NumberDataPoint {
+	dps, ok := numberDataPoints(metric)
+	if !ok {
+		return nil
+	}
+	dataPoints := make([]pmetric.NumberDataPoint, dps.Len())
+	for i := range dataPoints {
+		dataPoints[i] = dps.At(i)
+	}
+	return dataPoints
+}
+
+// dataPointsToTensor converts data points of one input, such as those of its
+// matched groups, to an inference tensor with the same layout the builtin
+// encoder of their type uses for a whole metric
+func dataPointsToTensor(name string, dataPoints []dataPoint, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
+	// Number data points, the common case, take one value each
+	contents := settings.fp64Contents(len(dataPoints))
+	for _, dp := range dataPoints {
+		switch dp := dp.(type) {
+		case pmetric.NumberDataPoint:
+			switch dp.ValueType() {
+			case pmetric.NumberDataPointValueTypeInt:
+				contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.IntValue()))
+			case pmetric.NumberDataPointValueTypeDouble:
+				contents.Fp64Contents = append(contents.Fp64Contents, dp.DoubleValue())
+			default:
+				return nil, fmt.Errorf("unsupported data point value type: %v", dp.ValueType())
+			}
+		case pmetric.HistogramDataPoint:
+			contents.Fp64Contents = append(contents.Fp64Contents, histogramDataPointValues(dp)...)
+		case pmetric.SummaryDataPoint:
+			contents.Fp64Contents = append(contents.Fp64Contents, summaryDataPointValues(dp)...)
+		case pmetric.ExponentialHistogramDataPoint:
+			if layout := settings.DataHandling.ExponentialHistogram; layout.Buckets > 0 {
+				contents.Fp64Contents = append(contents.Fp64Contents, exponentialHistogramFeatures(dp, layout)...)
+			} else {
+				contents.Fp64Contents = append(contents.Fp64Contents, exponentialHistogramDataPointValues(dp)...)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported data point type %T", dp)
+		}
+	}
+
+	shape := []int64{int64(len(contents.Fp64Contents))}
+	if len(dataPoints) > 0 {
+		if _, ok := dataPoints[0].(pmetric.ExponentialHistogramDataPoint); ok {
+			if layout := settings.DataHandling.ExponentialHistogram; layout.Buckets > 0 {
+				// With a fixed layout, every data point is a row of the same length
+				shape = []int64{int64(len(dataPoints)), int64(exponentialHistogramFeatureLength(layout))}
+			}
+		}
+	}
+
+	return &pb.ModelInferRequest_InferInputTensor{
+		Name:     name,
+		Datatype: "FP64",
+		Shape:    shape,
+		Contents: contents,
+	}, nil
+}
+
+// histogramDataPointValues returns the tensor values of a histogram data point:
+// [count, sum, bucket counts...]
+func histogramDataPointValues(dp pmetric.HistogramDataPoint) []float64 {
+	values := make([]float64, 0, 2+dp.BucketCounts().Len())
+	values = append(values, float64(dp.Count()), dp.Sum())
+	for j := 0; j < dp.BucketCounts().Len(); j++ {
+		values = append(values, float64(dp.BucketCounts().At(j)))
+	}
+	return values
+}
+
+// summaryDataPointValues returns the tensor values of a summary data point:
+// [count, sum, quantile, value, quantile, value, ...]
+func summaryDataPointValues(dp pmetric.SummaryDataPoint) []float64 {
+	values := make([]float64, 0, 2+2*dp.QuantileValues().Len())
+	values = append(values, float64(dp.Count()), dp.Sum())
+	for j := 0; j < dp.QuantileValues().Len(); j++ {
+		qv := dp.QuantileValues().At(j)
+		values = append(values, qv.Quantile(), qv.Value())
+	}
+	return values
+}
+
+// exponentialHistogramDataPointValues returns the tensor values of an exponential
+// histogram data point without a fixed layout: [count, sum, scale, zero_count,
+// positive offset, positive buckets..., negative offset, negative buckets...]
+func exponentialHistogramDataPointValues(dp pmetric.ExponentialHistogramDataPoint) []float64 {
+	values := make([]float64, 0, 6+dp.Positive().BucketCounts().Len()+dp.Negative().BucketCounts().Len())
+	values = append(values, float64(dp.Count()), dp.Sum(), float64(dp.Scale()), float64(dp.ZeroCount()))
+	values = append(values, float64(dp.Positive().Offset()))
+	for j := 0; j < dp.Positive().BucketCounts().Len(); j++ {
+		values = append(values, float64(dp.Positive().BucketCounts().At(j)))
+	}
+	values = append(values, float64(dp.Negative().Offset()))
+	for j := 0; j < dp.Negative().BucketCounts().Len(); j++ {
+		values = append(values, float64(dp.Negative().BucketCounts().At(j)))
+	}
+	return values
+}
@@ -0,0 +1,154 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// hostedHistogram creates a histogram with one data point per host
+func hostedHistogram(metric pmetric.Metric, hosts ...string) {
+	metric.SetName("http.duration")
+	dps := metric.SetEmptyHistogram().DataPoints()
+	for i, host := range hosts {
+		dp := dps.AppendEmpty()
+		dp.Attributes().PutStr("host", host)
+		dp.SetCount(uint64(10 * (i + 1)))
+		dp.SetSum(float64(100 * (i + 1)))
+		dp.BucketCounts().FromRaw([]uint64{uint64(i + 1), uint64(i + 2)})
+		dp.ExplicitBounds().FromRaw([]float64{50})
+	}
+}
+
+func TestExtractDataPointsAllTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(pmetric.Metric)
+	}{
+		{name: "gauge", build: func(m pmetric.Metric) { m.SetEmptyGauge().DataPoints().AppendEmpty().Attributes().PutStr("host", "a") }},
+		{name: "sum", build: func(m pmetric.Metric) { m.SetEmptySum().DataPoints().AppendEmpty().Attributes().PutStr("host", "a") }},
+		{name: "histogram", build: func(m pmetric.Metric) {
+			m.SetEmptyHistogram().DataPoints().AppendEmpty().Attributes().PutStr("host", "a")
+		}},
+		{name: "exponential_histogram", build: func(m pmetric.Metric) {
+			m.SetEmptyExponentialHistogram().DataPoints().AppendEmpty().Attributes().PutStr("host", "a")
+		}},
+		{name: "summary", build: func(m pmetric.Metric) {
+			m.SetEmptySummary().DataPoints().AppendEmpty().Attributes().PutStr("host", "a")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := pmetric.NewMetric()
+			tt.build(metric)
+			dataPoints := extractDataPoints(metric)
+			require.Len(t, dataPoints, 1)
+			host, ok := dataPoints[0].Attributes().Get("host")
+			require.True(t, ok)
+			assert.Equal(t, "a", host.Str())
+		})
+	}
+
+	assert.Empty(t, extractNumberDataPoints(pmetric.NewMetric()))
+}
+
+func TestDataPointsToTensor(t *testing.T) {
+	metric := pmetric.NewMetric()
+	hostedHistogram(metric, "a", "b")
+
+	// Histogram data points use the layout of the histogram encoder
+	tensor, err := dataPointsToTensor("x", extractDataPoints(metric), EncoderSettings{})
+	require.NoError(t, err)
+	expected, err := encodeHistogram("x", metric, EncoderSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, expected.Shape, tensor.Shape)
+	assert.Equal(t, expected.Contents.Fp64Contents, tensor.Contents.Fp64Contents)
+
+	summary := pmetric.NewMetric()
+	dp := summary.SetEmptySummary().DataPoints().AppendEmpty()
+	dp.SetCount(4)
+	dp.SetSum(8)
+	qv := dp.QuantileValues().AppendEmpty()
+	qv.SetQuantile(0.5)
+	qv.SetValue(2)
+	tensor, err = dataPointsToTensor("x", extractDataPoints(summary), EncoderSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{4, 8, 0.5, 2}, tensor.Contents.Fp64Contents)
+
+	// A fixed exponential histogram layout gives one row per data point
+	exponential := pmetric.NewMetric()
+	exponential.SetEmptyExponentialHistogram().DataPoints().AppendEmpty().SetCount(3)
+	exponential.ExponentialHistogram().DataPoints().AppendEmpty().SetCount(5)
+	settings := EncoderSettings{DataHandling: DataHandlingConfig{ExponentialHistogram: ExponentialHistogramConfig{Buckets: 2}}}
+	tensor, err = dataPointsToTensor("x", extractDataPoints(exponential), settings)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, int64(exponentialHistogramFeatureLength(settings.DataHandling.ExponentialHistogram))}, tensor.Shape)
+}
+
+func TestHistogramInputAttributesPreserved(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("latency", testutil.CreateMockResponseForScalingArray("latency", 1, []float64{1, 2})))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "latency",
+				Inputs:        []string{"http.duration", "cpu.usage"},
+				OutputPattern: "latency.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	hostedHistogram(metrics.AppendEmpty(), "a", "b")
+	cpu := metrics.AppendEmpty()
+	cpu.SetName("cpu.usage")
+	cpu.SetEmptyGauge()
+	for i, host := range []string{"b", "a"} {
+		dp := cpu.Gauge().DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("host", host)
+		dp.SetDoubleValue(float64(i))
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// The histogram is matched with the gauge per host, one histogram row per group
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	for _, input := range requests[0].Inputs {
+		if input.Name == "http.duration" {
+			assert.Equal(t, []float64{10, 100, 1, 2, 20, 200, 2, 3}, input.Contents.Fp64Contents)
+		}
+	}
+
+	output := findMetricByName(sink.AllMetrics()[0], "latency.score")
+	require.Equal(t, 2, output.Gauge().DataPoints().Len())
+	for i, expected := range []string{"a", "b"} {
+		host, ok := output.Gauge().DataPoints().At(i).Attributes().Get("http.duration.host")
+		require.True(t, ok, "histogram attributes are copied to outputs")
+		assert.Equal(t, expected, host.Str())
+	}
+}
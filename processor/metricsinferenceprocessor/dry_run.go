@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// validateDryRun checks that a dry run can reach the inference server at startup
+func validateDryRun(cfg *Config) error {
+	if cfg.DryRun && cfg.GRPCClientSettings.LazyConnect {
+		return errors.New("dry_run cannot be combined with lazy_connect, which defers model discovery until batches arrive")
+	}
+	return nil
+}
+
+// dryRun checks every rule against the metadata of its model once the inference
+// server is connected, logging each rule that matches and each mismatch. It
+// returns every mismatch, failing the startup, so a configuration is known to
+// work before any metric flows. It is a no-op unless dry_run is set.
+func (mp *metricsinferenceprocessor) dryRun() error {
+	if !mp.config.DryRun {
+		return nil
+	}
+
+	var errs []error
+	if err := invalidSelectors(mp.config); err != nil {
+		errs = append(errs, err)
+	}
+	for i, rule := range mp.rules {
+		if rule.inProcess() || !rule.enabled {
+			continue // In-process and disabled rules do not call the server
+		}
+		problems := mp.dryRunRule(rule)
+		if len(problems) == 0 {
+			mp.logger.Info("Dry run: rule matches its model",
+				zap.Int("rule_index", i),
+				zap.String("model", rule.modelName))
+			continue
+		}
+		for _, problem := range problems {
+			mp.logger.Warn("Dry run: rule does not match its model",
+				zap.Int("rule_index", i),
+				zap.String("model", rule.modelName),
+				zap.String("problem", problem))
+			errs = append(errs, fmt.Errorf("rule %d (model %q): %s", i, rule.modelName, problem))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("dry run found %d problems: %w", len(errs), errors.Join(errs...))
+	}
+	mp.logger.Info("Dry run passed, batches are forwarded without inference", zap.Int("rules", len(mp.rules)))
+	return nil
+}
+
+// dryRunRule returns the problems a rule would run into with its model: a model
+// that could not be resolved or described, inputs the requests it builds do
+// not fit in count, datatype or rank, and outputs the model does not produce
+func (mp *metricsinferenceprocessor) dryRunRule(rule internalRule) []string {
+	if rule.modelName == "" {
+		return []string{"no model in the repository matches the model selector"}
+	}
+	metadata, exists := mp.modelMetadata[rule.modelName]
+	if !exists {
+		return []string{"model metadata could not be queried"}
+	}
+
+	problems := mp.dryRunInputs(rule, metadata.inputs)
+	if err := checkControlInputs(rule, metadata.inputs); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for j, output := range rule.outputs {
+		switch {
+		case output.discovered:
+		case output.tensorName != "":
+			if !slices.ContainsFunc(metadata.outputs, func(tensor *pb.ModelMetadataResponse_TensorMetadata) bool {
+				return tensor.Name == output.tensorName
+			}) {
+				problems = append(problems, fmt.Sprintf("output %d reads tensor %q, which the model does not produce", j, output.tensorName))
+			}
+		case output.outputIndex != nil:
+			if *output.outputIndex >= len(metadata.outputs) {
+				problems = append(problems, fmt.Sprintf("output %d reads output index %d, but the model has %d outputs", j, *output.outputIndex, len(metadata.outputs)))
+			}
+		case len(metadata.outputs) > 0 && j >= len(metadata.outputs):
+			problems = append(problems, fmt.Sprintf("output %d has no model output at its position, the model has %d outputs", j, len(metadata.outputs)))
+		}
+	}
+	return problems
+}
+
+// dryRunInputs checks the tensors a rule sends against the model's inputs.
+// Forwarded attributes and sequence control tensors are matched by name, the
+// rule's inputs by position, or as one 2D tensor when they are combined.
+func (mp *metricsinferenceprocessor) dryRunInputs(rule internalRule, inputs []*pb.ModelMetadataResponse_TensorMetadata) []string {
+	if len(inputs) == 0 {
+		return nil // The model does not describe its inputs
+	}
+	extra := rule.attachedTensorNames()
+	var expected []*pb.ModelMetadataResponse_TensorMetadata
+	for _, input := range inputs {
+		if !slices.Contains(extra, input.Name) {
+			expected = append(expected, input)
+		}
+	}
+
+	var problems []string
+	if mp.combinesInputs(rule) {
+		name := defaultWindowTensorName
+		if rule.combine != nil {
+			name = rule.combine.tensorName
+		} else if mp.config.DataHandling.WindowTensorName != "" {
+			name = mp.config.DataHandling.WindowTensorName
+		}
+		if len(expected) != 1 {
+			return []string{fmt.Sprintf("inputs are sent as one tensor, but the model expects %d inputs", len(expected))}
+		}
+		if expected[0].Name != name {
+			problems = append(problems, fmt.Sprintf("inputs are sent as tensor %q, but the model expects %q", name, expected[0].Name))
+		}
+		if len(expected[0].Shape) != 2 {
+			problems = append(problems, fmt.Sprintf("inputs are sent as a 2D tensor, but the model expects shape %v", expected[0].Shape))
+		}
+		return append(problems, mp.dryRunDatatype(expected[0])...)
+	}
+
+	if len(expected) != len(rule.inputs) {
+		return []string{fmt.Sprintf("the rule has %d inputs, but the model expects %d", len(rule.inputs), len(expected))}
+	}
+	for i, input := range expected {
+		if len(input.Shape) > 2 {
+			problems = append(problems, fmt.Sprintf("input %s is sent as a 1D or 2D tensor, but the model expects shape %v for %q", rule.inputs[i], input.Shape, input.Name))
+		}
+		problems = append(problems, mp.dryRunDatatype(input)...)
+	}
+	return problems
+}
+
+// dryRunDatatype checks that a model input takes the floating point values
+// rules send
+func (mp *metricsinferenceprocessor) dryRunDatatype(input *pb.ModelMetadataResponse_TensorMetadata) []string {
+	if !mp.isDataTypeCompatible("FP64", input.Datatype) {
+		return []string{fmt.Sprintf("model input %q takes %s, which metric values cannot be converted to", input.Name, input.Datatype)}
+	}
+	if isHalfPrecision(input.Datatype) && !mp.supportsExtension(extensionBinaryTensorData) {
+		return []string{fmt.Sprintf("model input %q takes %s, but the inference server does not support the %s extension", input.Name, input.Datatype, extensionBinaryTensorData)}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// dryRunMetadata describes a model taking two FP32 inputs and producing a score
+var dryRunMetadata = &pb.ModelMetadataResponse{
+	Name: "scorer",
+	Inputs: []*pb.ModelMetadataResponse_TensorMetadata{
+		{Name: "cpu", Datatype: "FP32", Shape: []int64{-1}},
+		{Name: "memory", Datatype: "FP32", Shape: []int64{-1}},
+	},
+	Outputs: []*pb.ModelMetadataResponse_TensorMetadata{{Name: "score", Datatype: "FP32", Shape: []int64{-1}}},
+}
+
+func TestDryRunPasses(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", dryRunMetadata),
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		DryRun:             true,
+		Rules: []Rule{{
+			ModelName: "scorer",
+			Inputs:    []string{"cpu", "memory"},
+			Outputs:   []OutputSpec{{Name: "score", TensorName: "score"}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Batches are forwarded without inference
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu", "memory"},
+		MetricValues: [][]float64{{0.5}, {0.7}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	assert.Empty(t, mockServer.GetRequests())
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 2, sink.AllMetrics()[0].MetricCount())
+}
+
+func TestDryRunReportsMismatches(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", dryRunMetadata),
+		testutil.WithModelMetadata("tokenizer", &pb.ModelMetadataResponse{
+			Name:    "tokenizer",
+			Inputs:  []*pb.ModelMetadataResponse_TensorMetadata{{Name: "text", Datatype: "BYTES", Shape: []int64{1, 1, 1}}},
+			Outputs: []*pb.ModelMetadataResponse_TensorMetadata{{Name: "tokens", Datatype: "INT64", Shape: []int64{-1}}},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		DryRun:             true,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"cpu"}, Outputs: []OutputSpec{{Name: "score", TensorName: "anomaly"}}},
+			{ModelName: "tokenizer", Inputs: []string{"log_rate"}, Outputs: []OutputSpec{{Name: "a"}, {Name: "b"}}},
+			{ModelName: "unknown", Inputs: []string{"cpu"}, Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "scorer", Inputs: []string{"cpu", "memory"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	err = processor.Start(context.Background(), nil)
+	require.Error(t, err)
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	assert.ErrorContains(t, err, "dry run found 6 problems")
+	assert.ErrorContains(t, err, `rule 0 (model "scorer"): the rule has 1 inputs, but the model expects 2`)
+	assert.ErrorContains(t, err, `rule 0 (model "scorer"): output 0 reads tensor "anomaly", which the model does not produce`)
+	assert.ErrorContains(t, err, `rule 1 (model "tokenizer"): input log_rate is sent as a 1D or 2D tensor`)
+	assert.ErrorContains(t, err, `rule 1 (model "tokenizer"): model input "text" takes BYTES`)
+	assert.ErrorContains(t, err, `rule 1 (model "tokenizer"): output 1 has no model output at its position`)
+	assert.ErrorContains(t, err, `rule 2 (model "unknown"): model metadata could not be queried`)
+	assert.NotContains(t, err.Error(), "rule 3")
+}
+
+func TestDryRunCombinedInputs(t *testing.T) {
+	processor, err := newMetricsProcessor(&Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{{
+			ModelName:     "classifier",
+			Inputs:        []string{"cpu", "memory"},
+			CombineInputs: &CombineInputsConfig{TensorName: "features"},
+		}},
+	}, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	rule := processor.rules[0]
+
+	matrix := []*pb.ModelMetadataResponse_TensorMetadata{{Name: "features", Datatype: "FP32", Shape: []int64{-1, 2}}}
+	assert.Empty(t, processor.dryRunInputs(rule, matrix))
+
+	matrix[0].Name = "input"
+	assert.Equal(t, []string{`inputs are sent as tensor "features", but the model expects "input"`}, processor.dryRunInputs(rule, matrix))
+
+	assert.Equal(t, []string{"inputs are sent as one tensor, but the model expects 2 inputs"}, processor.dryRunInputs(rule, dryRunMetadata.Inputs))
+}
+
+func TestValidateDryRun(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001", LazyConnect: true},
+		DryRun:             true,
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x"}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "dry_run cannot be combined with lazy_connect")
+}
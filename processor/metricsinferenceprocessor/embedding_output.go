@@ -0,0 +1,174 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Reductions of an embedding vector to the value of its data point
+const (
+	embeddingNorm              = "norm"               // L2 norm of the vector (default)
+	embeddingCosineSimilarity  = "cosine_similarity"  // cosine similarity with the reference vector
+	embeddingEuclideanDistance = "euclidean_distance" // Euclidean distance to the reference vector
+)
+
+// embeddingOutput is the internal form of an output's embedding configuration
+type embeddingOutput struct {
+	reduce          string
+	reference       []float64 // Reference vector of similarities and distances, nil for norms
+	referenceNorm   float64   // L2 norm of the reference vector
+	vectorAttribute string    // Attribute the raw vector is forwarded as, empty when not forwarded
+}
+
+// newEmbeddingOutput converts an embedding configuration, applying defaults. It
+// returns nil when the output is not an embedding.
+func newEmbeddingOutput(cfg *EmbeddingOutputConfig) *embeddingOutput {
+	if cfg == nil {
+		return nil
+	}
+	embedding := &embeddingOutput{
+		reduce:          cfg.Reduce,
+		reference:       cfg.Reference,
+		referenceNorm:   vectorNorm(cfg.Reference),
+		vectorAttribute: cfg.VectorAttribute,
+	}
+	if embedding.reduce == "" {
+		embedding.reduce = embeddingNorm
+	}
+	return embedding
+}
+
+// validateEmbeddingOutput checks an output's embedding configuration. An
+// embedding data point stands for a whole vector, so the settings decoding the
+// values of single elements do not apply.
+func validateEmbeddingOutput(output OutputSpec) error {
+	cfg := output.Embedding
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Reduce {
+	case "", embeddingNorm:
+		if len(cfg.Reference) > 0 {
+			return errors.New("reference only applies to cosine_similarity and euclidean_distance")
+		}
+	case embeddingCosineSimilarity, embeddingEuclideanDistance:
+		if len(cfg.Reference) == 0 {
+			return fmt.Errorf("reference is required for %s", cfg.Reduce)
+		}
+	default:
+		return fmt.Errorf("invalid reduce %q (must be 'norm', 'cosine_similarity', or 'euclidean_distance')", cfg.Reduce)
+	}
+	for i, value := range cfg.Reference {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("reference element %d is not finite", i)
+		}
+	}
+	if cfg.Reduce == embeddingCosineSimilarity && vectorNorm(cfg.Reference) == 0 {
+		return errors.New("reference must not be the zero vector for cosine_similarity")
+	}
+
+	switch {
+	case output.Histogram != nil:
+		return errors.New("embedding and histogram are mutually exclusive")
+	case output.DataType == "string":
+		return errors.New("embedding outputs must be numeric")
+	case output.Temporality == temporalityCumulative:
+		return errors.New("embedding outputs do not support cumulative temporality")
+	case output.Columns != "" || len(output.ColumnNames) > 0 || len(output.ElementNames) > 0:
+		return errors.New("embedding outputs reduce the tensor's columns, columns, column_names and element_names do not apply")
+	}
+	return nil
+}
+
+// vectorNorm returns the L2 norm of a vector
+func vectorNorm(vector []float64) float64 {
+	var sum float64
+	for _, value := range vector {
+		sum += value * value
+	}
+	return math.Sqrt(sum)
+}
+
+// value reduces an embedding vector to the value of its data point
+func (e *embeddingOutput) value(vector []float64) float64 {
+	switch e.reduce {
+	case embeddingCosineSimilarity:
+		var dot float64
+		for i, value := range vector {
+			dot += value * e.reference[i]
+		}
+		return dot / (vectorNorm(vector) * e.referenceNorm)
+	case embeddingEuclideanDistance:
+		var sum float64
+		for i, value := range vector {
+			diff := value - e.reference[i]
+			sum += diff * diff
+		}
+		return math.Sqrt(sum)
+	}
+	return vectorNorm(vector)
+}
+
+// processEmbeddingOutput reduces an output tensor of embedding vectors to a gauge
+// with a data point per vector. A [rows, dimensions] tensor has a row per matched
+// attribute group, a flat tensor is a single vector; with a reference, a flat
+// tensor holding several vectors is split by the reference's dimensions. Values
+// go through the output's post transforms, and the raw vector is forwarded as an
+// array attribute when the output says so.
+func (mp *metricsinferenceprocessor) processEmbeddingOutput(metric pmetric.Metric, outputTensor *pb.ModelInferResponse_InferOutputTensor, outputSpec internalOutputSpec, context *modelContext) error {
+	values := tensorFloatValues(outputTensor)
+	if len(values) == 0 {
+		return errors.New("embedding output tensor has no values")
+	}
+
+	embedding := outputSpec.embedding
+	dimensions := len(values)
+	if _, cols, shaped := tensorMatrixShape(outputTensor.Shape); shaped {
+		dimensions = cols
+	} else if embedding.reference != nil {
+		dimensions = len(embedding.reference)
+	}
+	if len(values)%dimensions != 0 {
+		return fmt.Errorf("embedding output tensor has %d values, not a multiple of %d dimensions", len(values), dimensions)
+	}
+	if embedding.reference != nil && dimensions != len(embedding.reference) {
+		return fmt.Errorf("embedding output tensor has %d dimensions but the reference has %d", dimensions, len(embedding.reference))
+	}
+	rows := len(values) / dimensions
+	if context != nil && len(context.matchedDataPoints) > 0 && rows != len(context.matchedDataPoints) {
+		return fmt.Errorf("embedding output tensor has %d rows but there are %d matched data point groups", rows, len(context.matchedDataPoints))
+	}
+
+	dps := metric.SetEmptyGauge().DataPoints()
+	timestamp := pcommon.NewTimestampFromTime(time.Now())
+	for r := 0; r < rows; r++ {
+		vector := values[r*dimensions : (r+1)*dimensions]
+		dp := dps.AppendEmpty()
+		dp.SetTimestamp(timestamp)
+		dp.SetDoubleValue(applyPostTransforms(embedding.value(vector), outputSpec.post))
+		copyGroupAttributes(dp.Attributes(), context, r)
+		if embedding.vectorAttribute != "" {
+			dp.Attributes().PutEmptySlice(embedding.vectorAttribute).FromRaw(vectorRaw(vector))
+		}
+	}
+	return nil
+}
+
+// vectorRaw converts a vector to the raw form of an attribute array
+func vectorRaw(vector []float64) []any {
+	raw := make([]any, len(vector))
+	for i, value := range vector {
+		raw[i] = value
+	}
+	return raw
+}
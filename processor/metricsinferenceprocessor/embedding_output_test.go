@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestEmbeddingValue(t *testing.T) {
+	norm := newEmbeddingOutput(&EmbeddingOutputConfig{})
+	assert.Equal(t, embeddingNorm, norm.reduce)
+	assert.Equal(t, 5.0, norm.value([]float64{3, 4}))
+
+	cosine := newEmbeddingOutput(&EmbeddingOutputConfig{Reduce: embeddingCosineSimilarity, Reference: []float64{1, 0}})
+	assert.InDelta(t, 1, cosine.value([]float64{2, 0}), 1e-9)
+	assert.InDelta(t, 0, cosine.value([]float64{0, 3}), 1e-9)
+	assert.InDelta(t, 0.6, cosine.value([]float64{3, 4}), 1e-9)
+
+	distance := newEmbeddingOutput(&EmbeddingOutputConfig{Reduce: embeddingEuclideanDistance, Reference: []float64{1, 1}})
+	assert.Equal(t, 5.0, distance.value([]float64{4, 5}))
+
+	assert.Nil(t, newEmbeddingOutput(nil))
+}
+
+func TestValidateEmbeddingOutput(t *testing.T) {
+	assert.NoError(t, validateEmbeddingOutput(OutputSpec{}))
+	assert.NoError(t, validateEmbeddingOutput(OutputSpec{Embedding: &EmbeddingOutputConfig{VectorAttribute: "embedding"}}))
+	assert.NoError(t, validateEmbeddingOutput(OutputSpec{Embedding: &EmbeddingOutputConfig{Reduce: embeddingCosineSimilarity, Reference: []float64{0.2, 0.8}}}))
+
+	tests := []struct {
+		name   string
+		output OutputSpec
+		errMsg string
+	}{
+		{"invalid reduce", OutputSpec{Embedding: &EmbeddingOutputConfig{Reduce: "mean"}}, "invalid reduce"},
+		{"missing reference", OutputSpec{Embedding: &EmbeddingOutputConfig{Reduce: embeddingEuclideanDistance}}, "reference is required"},
+		{"reference of a norm", OutputSpec{Embedding: &EmbeddingOutputConfig{Reference: []float64{1}}}, "only applies to"},
+		{"zero reference", OutputSpec{Embedding: &EmbeddingOutputConfig{Reduce: embeddingCosineSimilarity, Reference: []float64{0, 0}}}, "zero vector"},
+		{"histogram", OutputSpec{Embedding: &EmbeddingOutputConfig{}, Histogram: &HistogramOutputConfig{Bounds: []float64{1}}}, "mutually exclusive"},
+		{"cumulative", OutputSpec{Embedding: &EmbeddingOutputConfig{}, Temporality: temporalityCumulative}, "cumulative"},
+		{"columns", OutputSpec{Embedding: &EmbeddingOutputConfig{}, ElementNames: []string{"x", "y"}}, "do not apply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateEmbeddingOutput(tt.output), tt.errMsg)
+		})
+	}
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{{
+			ModelName: "encoder",
+			Inputs:    []string{"cpu"},
+			Outputs:   []OutputSpec{{Name: "embedding", Embedding: &EmbeddingOutputConfig{Reduce: "mean"}}},
+		}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid embedding for output 0 in rule 0")
+}
+
+func TestEmbeddingVectorAttributeWithSeriesState(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+			Timeout:            5,
+			Rules: []Rule{{
+				ModelName: "encoder",
+				Inputs:    []string{"cpu"},
+				Outputs:   []OutputSpec{{Name: "embedding", Embedding: &EmbeddingOutputConfig{VectorAttribute: "embedding.vector"}}},
+			}},
+		}
+	}
+	require.NoError(t, newConfig().Validate())
+
+	cfg := newConfig()
+	cfg.Staleness.Period = time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with staleness or cardinality.max_series")
+
+	cfg = newConfig()
+	cfg.Cardinality.MaxSeries = 100
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with staleness or cardinality.max_series")
+
+	cfg = newConfig()
+	cfg.Rules[0].Outputs[0].Fallback = FallbackConfig{Policy: fallbackPolicyLastValue}
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with a last_value fallback")
+
+	cfg = newConfig()
+	cfg.Rules[0].Outputs[0].Embedding.VectorAttribute = ""
+	cfg.Rules[0].Outputs[0].Fallback = FallbackConfig{Policy: fallbackPolicyLastValue}
+	cfg.Staleness.Period = time.Minute
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestProcessEmbeddingOutput(t *testing.T) {
+	mp := &metricsinferenceprocessor{}
+	tensor := func(shape []int64, values ...float32) *pb.ModelInferResponse_InferOutputTensor {
+		return &pb.ModelInferResponse_InferOutputTensor{Datatype: "FP32", Shape: shape, Contents: &pb.InferTensorContents{Fp32Contents: values}}
+	}
+
+	// A vector per row, reduced to its norm
+	spec := internalOutputSpec{embedding: newEmbeddingOutput(&EmbeddingOutputConfig{VectorAttribute: "vector"})}
+	metric := pmetric.NewMetric()
+	require.NoError(t, mp.processEmbeddingOutput(metric, tensor([]int64{2, 2}, 3, 4, 6, 8), spec, nil))
+	dps := metric.Gauge().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	assert.Equal(t, 5.0, dps.At(0).DoubleValue())
+	assert.Equal(t, 10.0, dps.At(1).DoubleValue())
+	vector, ok := dps.At(1).Attributes().Get("vector")
+	require.True(t, ok)
+	assert.Equal(t, []any{6.0, 8.0}, vector.Slice().AsRaw())
+
+	// Flat tensors are split by the reference's dimensions
+	spec = internalOutputSpec{embedding: newEmbeddingOutput(&EmbeddingOutputConfig{Reduce: embeddingEuclideanDistance, Reference: []float64{0, 0}})}
+	metric = pmetric.NewMetric()
+	require.NoError(t, mp.processEmbeddingOutput(metric, tensor([]int64{4}, 3, 4, 0, 1), spec, nil))
+	assert.Equal(t, 2, metric.Gauge().DataPoints().Len())
+	_, ok = metric.Gauge().DataPoints().At(0).Attributes().Get("vector")
+	assert.False(t, ok, "vectors are only forwarded when configured")
+
+	assert.ErrorContains(t, mp.processEmbeddingOutput(pmetric.NewMetric(), tensor([]int64{1, 3}, 1, 2, 3), spec, nil), "the reference has 2")
+	assert.ErrorContains(t, mp.processEmbeddingOutput(pmetric.NewMetric(), tensor([]int64{3}, 1, 2, 3), spec, nil), "not a multiple of 2")
+	assert.ErrorContains(t, mp.processEmbeddingOutput(pmetric.NewMetric(), tensor([]int64{0}), spec, nil), "no values")
+}
+
+func TestEmbeddingOutput(t *testing.T) {
+	response := &pb.ModelInferResponse{
+		ModelName: "encoder",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+			Name:     "embedding",
+			Datatype: "FP32",
+			Shape:    []int64{1, 3},
+			Contents: &pb.InferTensorContents{Fp32Contents: []float32{0, 1, 0}},
+		}},
+	}
+	mockServer := testutil.StartMockServer(t, testutil.WithModelResponse("encoder", response))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "encoder",
+			Inputs:        []string{"latency"},
+			OutputPattern: "{output}",
+			Outputs: []OutputSpec{{
+				Name: "embedding.similarity",
+				Post: []string{"round(3)"},
+				Embedding: &EmbeddingOutputConfig{
+					Reduce:          embeddingCosineSimilarity,
+					Reference:       []float64{1, 1, 0},
+					VectorAttribute: "embedding.vector",
+				},
+			}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"latency"},
+		MetricValues: [][]float64{{120}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	similarity := findMetricByName(sink.AllMetrics()[0], "embedding.similarity")
+	require.Equal(t, pmetric.MetricTypeGauge, similarity.Type())
+	dp := similarity.Gauge().DataPoints().At(0)
+	assert.Equal(t, 0.707, dp.DoubleValue())
+	vector, ok := dp.Attributes().Get("embedding.vector")
+	require.True(t, ok)
+	assert.Equal(t, []any{0.0, 1.0, 0.0}, vector.Slice().AsRaw())
+}
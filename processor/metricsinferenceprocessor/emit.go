@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// What the processor forwards downstream, as set by Config.Emit
+const (
+	EmitAll         = "all"          // the batch's metrics along with the outputs (default)
+	EmitOutputsOnly = "outputs_only" // only the metrics the processor produced
+)
+
+// validateEmit checks the emit setting
+func validateEmit(emit string) error {
+	switch emit {
+	case "", EmitAll, EmitOutputsOnly:
+		return nil
+	}
+	return fmt.Errorf("invalid emit %q (must be 'all' or 'outputs_only')", emit)
+}
+
+// outputsOnly reports whether the processor forwards only the metrics it produced
+func (mp *metricsinferenceprocessor) outputsOnly() bool {
+	return mp.config.Emit == EmitOutputsOnly
+}
+
+// passThrough forwards a batch the processor did not infer on. Nothing is
+// forwarded when only outputs are emitted, as the batch holds none.
+func (mp *metricsinferenceprocessor) passThrough(ctx context.Context, md pmetric.Metrics) error {
+	if mp.outputsOnly() {
+		return nil
+	}
+	return mp.nextConsumer.ConsumeMetrics(ctx, md)
+}
+
+// removeInputs removes the metrics a batch held when counts were taken with
+// scopeMetricCounts, keeping only the metrics added since, then the scopes and
+// resources left without metrics
+func removeInputs(md pmetric.Metrics, counts [][]int) {
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len() && i < len(counts); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len() && j < len(counts[i]); j++ {
+			index := 0
+			sms.At(j).Metrics().RemoveIf(func(pmetric.Metric) bool {
+				index++
+				return index <= counts[i][j]
+			})
+		}
+	}
+	rms.RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestRemoveInputs(t *testing.T) {
+	md := pmetric.NewMetrics()
+	first := md.ResourceMetrics().AppendEmpty()
+	sm := first.ScopeMetrics().AppendEmpty()
+	sm.Metrics().AppendEmpty().SetName("cpu")
+	sm.Metrics().AppendEmpty().SetName("memory")
+	md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("disk")
+	counts := scopeMetricCounts(md)
+
+	// Outputs added to the inputs' scope and to a scope of their own
+	sm.Metrics().AppendEmpty().SetName("cpu.prediction")
+	first.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("memory.prediction")
+
+	removeInputs(md, counts)
+	require.Equal(t, 1, md.ResourceMetrics().Len(), "resources left without metrics are removed")
+	sms := md.ResourceMetrics().At(0).ScopeMetrics()
+	require.Equal(t, 2, sms.Len())
+	assert.Equal(t, "cpu.prediction", sms.At(0).Metrics().At(0).Name())
+	assert.Equal(t, 1, sms.At(0).Metrics().Len())
+	assert.Equal(t, "memory.prediction", sms.At(1).Metrics().At(0).Name())
+}
+
+func TestValidateEmit(t *testing.T) {
+	for _, emit := range []string{"", EmitAll, EmitOutputsOnly} {
+		assert.NoError(t, validateEmit(emit))
+	}
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Emit:               "inputs_only",
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid emit")
+}
+
+func TestEmitOutputsOnly(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scaler", testutil.CreateMockResponseForCalculation("scaler", 2)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Emit:               EmitOutputsOnly,
+		Rules: []Rule{{
+			ModelName:     "scaler",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "{input}.{output}",
+			Outputs:       []OutputSpec{{Name: "scaled"}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu", "memory"},
+		MetricValues: [][]float64{{0.5}, {0.7}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 1, output.MetricCount())
+	assert.Equal(t, pmetric.MetricTypeGauge, findMetricByName(output, "cpu.scaled").Type())
+
+	// Batches without outputs are not forwarded
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"memory"},
+		MetricValues: [][]float64{{0.7}},
+	})))
+	assert.Len(t, sink.AllMetrics(), 1)
+}
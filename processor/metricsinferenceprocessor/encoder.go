@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Builtin encoder names, one per metric type
+const (
+	encoderGauge                = "gauge"
+	encoderSum                  = "sum"
+	encoderHistogram            = "histogram"
+	encoderSummary              = "summary"
+	encoderExponentialHistogram = "exponential_histogram"
+)
+
+// EncoderSettings carries the processor settings an encoder may honor
+type EncoderSettings struct {
+	// DataHandling is the processor's data handling configuration
+	DataHandling DataHandlingConfig
+
+	buffers *tensorBuffers // Pool the builtin encoders draw tensor contents from, nil to allocate them
+}
+
+// TensorEncoder converts an input metric into an inference input tensor. The
+// tensor name should be the input name, which is how the processor and the
+// inference server refer to the input.
+type TensorEncoder func(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error)
+
+var (
+	encoderRegistryLock sync.RWMutex
+	encoderRegistry     = map[string]TensorEncoder{
+		encoderGauge:                encodeGauge,
+		encoderSum:                  encodeSum,
+		encoderHistogram:            encodeHistogram,
+		encoderSummary:              encodeSummary,
+		encoderExponentialHistogram: encodeExponentialHistogram,
+	}
+)
+
+// RegisterTensorEncoder makes an encoder available to rules under the given name,
+// for custom collector builds that need input formats the builtin encoders do not
+// produce. It is meant to be called from an init function, before configurations
+// are validated. Names must be unique, so builtin encoders cannot be replaced.
+func RegisterTensorEncoder(name string, encoder TensorEncoder) error {
+	if name == "" {
+		return errors.New("encoder name must not be empty")
+	}
+	if encoder == nil {
+		return fmt.Errorf("encoder %q must not be nil", name)
+	}
+
+	encoderRegistryLock.Lock()
+	defer encoderRegistryLock.Unlock()
+
+	if _, exists := encoderRegistry[name]; exists {
+		return fmt.Errorf("encoder %q is already registered", name)
+	}
+	encoderRegistry[name] = encoder
+	return nil
+}
+
+// lookupTensorEncoder returns the encoder registered under a name
+func lookupTensorEncoder(name string) (TensorEncoder, bool) {
+	encoderRegistryLock.RLock()
+	defer encoderRegistryLock.RUnlock()
+
+	encoder, ok := encoderRegistry[name]
+	return encoder, ok
+}
+
+// registeredEncoderNames returns the sorted names of all registered encoders
+func registeredEncoderNames() []string {
+	encoderRegistryLock.RLock()
+	defer encoderRegistryLock.RUnlock()
+
+	names := make([]string, 0, len(encoderRegistry))
+	for name := range encoderRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinEncoderName returns the name of the builtin encoder for a metric type
+func builtinEncoderName(metricType pmetric.MetricType) string {
+	switch metricType {
+	case pmetric.MetricTypeGauge:
+		return encoderGauge
+	case pmetric.MetricTypeSum:
+		return encoderSum
+	case pmetric.MetricTypeHistogram:
+		return encoderHistogram
+	case pmetric.MetricTypeSummary:
+		return encoderSummary
+	case pmetric.MetricTypeExponentialHistogram:
+		return encoderExponentialHistogram
+	default:
+		return ""
+	}
+}
+
+// validateRuleEncoders checks that a rule's encoders refer to its inputs and to registered encoders
+func validateRuleEncoders(rule Rule) error {
+	for input, name := range rule.Encoders {
+		found := false
+		for _, ruleInput := range rule.Inputs {
+			if ruleInput == input {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("encoder for unknown input %q", input)
+		}
+		if _, ok := lookupTensorEncoder(name); !ok {
+			return fmt.Errorf("unknown encoder %q for input %q (registered: %v)", name, input, registeredEncoderNames())
+		}
+	}
+	return nil
+}
+
+// encoderSettings returns the settings passed to encoders
+func (mp *metricsinferenceprocessor) encoderSettings() EncoderSettings {
+	return EncoderSettings{DataHandling: mp.config.DataHandling}
+}
+
+// encodeRuleInput converts an input with the encoder configured for it on the rule.
+// ok is false when the input has no configured encoder and uses the default conversion.
+func (mp *metricsinferenceprocessor) encodeRuleInput(rule *internalRule, name string, metric pmetric.Metric, settings EncoderSettings) (tensor *pb.ModelInferRequest_InferInputTensor, ok bool, err error) {
+	encoder, ok := rule.encoders[name]
+	if !ok {
+		return nil, false, nil
+	}
+	tensor, err = encoder(name, metric, settings)
+	return tensor, true, err
+}
@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// registerTestEncoder registers an encoder for the duration of a test
+func registerTestEncoder(t *testing.T, name string, encoder TensorEncoder) {
+	require.NoError(t, RegisterTensorEncoder(name, encoder))
+	t.Cleanup(func() {
+		encoderRegistryLock.Lock()
+		defer encoderRegistryLock.Unlock()
+		delete(encoderRegistry, name)
+	})
+}
+
+// encodeDataPointCount is a custom encoder sending only the number of data points
+func encodeDataPointCount(name string, metric pmetric.Metric, _ EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
+	return &pb.ModelInferRequest_InferInputTensor{
+		Name:     name,
+		Datatype: "INT64",
+		Shape:    []int64{1},
+		Contents: &pb.InferTensorContents{Int64Contents: []int64{int64(metric.Gauge().DataPoints().Len())}},
+	}, nil
+}
+
+func TestRegisterTensorEncoder(t *testing.T) {
+	registerTestEncoder(t, "test_count", encodeDataPointCount)
+
+	encoder, ok := lookupTensorEncoder("test_count")
+	require.True(t, ok)
+	assert.NotNil(t, encoder)
+	assert.Contains(t, registeredEncoderNames(), "test_count")
+
+	assert.ErrorContains(t, RegisterTensorEncoder("test_count", encodeDataPointCount), "already registered")
+	assert.ErrorContains(t, RegisterTensorEncoder(encoderGauge, encodeDataPointCount), "already registered")
+	assert.ErrorContains(t, RegisterTensorEncoder("", encodeDataPointCount), "must not be empty")
+	assert.ErrorContains(t, RegisterTensorEncoder("nil_encoder", nil), "must not be nil")
+}
+
+func TestBuiltinEncoders(t *testing.T) {
+	for _, metricType := range []pmetric.MetricType{
+		pmetric.MetricTypeGauge,
+		pmetric.MetricTypeSum,
+		pmetric.MetricTypeHistogram,
+		pmetric.MetricTypeSummary,
+		pmetric.MetricTypeExponentialHistogram,
+	} {
+		_, ok := lookupTensorEncoder(builtinEncoderName(metricType))
+		assert.True(t, ok, "missing builtin encoder for %s", metricType)
+	}
+	assert.Empty(t, builtinEncoderName(pmetric.MetricTypeEmpty))
+}
+
+func TestValidateRuleEncoders(t *testing.T) {
+	registerTestEncoder(t, "test_count", encodeDataPointCount)
+
+	rule := Rule{ModelName: "m", Inputs: []string{"x", "y"}}
+	assert.NoError(t, validateRuleEncoders(rule))
+
+	rule.Encoders = map[string]string{"x": "test_count", "y": encoderGauge}
+	assert.NoError(t, validateRuleEncoders(rule))
+
+	rule.Encoders = map[string]string{"z": "test_count"}
+	assert.ErrorContains(t, validateRuleEncoders(rule), "unknown input \"z\"")
+
+	rule.Encoders = map[string]string{"x": "protobuf"}
+	assert.ErrorContains(t, validateRuleEncoders(rule), "unknown encoder \"protobuf\"")
+}
+
+func TestCustomEncoderRequest(t *testing.T) {
+	registerTestEncoder(t, "test_count", encodeDataPointCount)
+
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("counter", testutil.CreateMockResponseForCalculation("counter", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "counter",
+				Inputs:    []string{"metric_1"},
+				Encoders:  map[string]string{"metric_1": "test_count"},
+				Outputs:   []OutputSpec{{Name: "result"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1, 2, 3}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	tensor := requests[0].Inputs[0]
+	assert.Equal(t, "INT64", tensor.Datatype)
+	assert.Equal(t, []int64{3}, tensor.Contents.Int64Contents)
+}
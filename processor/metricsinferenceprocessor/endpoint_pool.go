@@ -0,0 +1,272 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Load balancing policies across multiple inference endpoints
+const (
+	loadBalancingFailover   = "failover"
+	loadBalancingRoundRobin = "round_robin"
+)
+
+// defaultHealthCheckInterval is how often endpoints are probed with ServerLive
+// when no interval is configured
+const defaultHealthCheckInterval = 10 * time.Second
+
+// poolEndpoint is one inference server of an endpoint pool
+type poolEndpoint struct {
+	address  string
+	channels []*poolChannel // Connections to the server, empty for an injected client
+	client   pb.GRPCInferenceServiceClient
+	healthy  atomic.Bool
+	next     atomic.Uint64 // Round robin position over the channels
+}
+
+// poolChannel is one gRPC connection to an endpoint. Each channel is a separate
+// HTTP/2 connection with its own flow control window.
+type poolChannel struct {
+	conn     *grpc.ClientConn
+	index    int
+	inFlight atomic.Int64
+}
+
+// endpointPool spreads calls over several inference servers. It implements
+// grpc.ClientConnInterface, so the generated inference client works on top of it
+// unchanged. With the failover policy, calls go to the first healthy endpoint in
+// configured (priority) order; with round robin, consecutive calls rotate over
+// the healthy endpoints. A call failing with Unavailable marks its endpoint
+// unhealthy and is retried on the next candidate. A background ServerLive probe
+// brings endpoints back once they recover.
+type endpointPool struct {
+	endpoints []*poolEndpoint
+	policy    string
+	logger    *zap.Logger
+	telemetry *processorTelemetry
+	next      atomic.Uint64 // Round robin position
+
+	cancel context.CancelFunc // Stops the background health check, nil when not running
+	done   chan struct{}      // Closed when the background health check exits
+}
+
+var _ grpc.ClientConnInterface = (*endpointPool)(nil)
+
+// newEndpointPool dials every address, opening the given number of channels to
+// each. Dialing does not block, so an endpoint that is down at startup only
+// shows up in the first health check.
+func newEndpointPool(ctx context.Context, addresses []string, policy string, channels int, logger *zap.Logger, telemetry *processorTelemetry, dialOpts ...grpc.DialOption) (*endpointPool, error) {
+	if policy == "" {
+		policy = loadBalancingFailover
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+	pool := &endpointPool{policy: policy, logger: logger, telemetry: telemetry}
+	for _, address := range addresses {
+		endpoint := &poolEndpoint{address: address}
+		endpoint.healthy.Store(true)
+		pool.endpoints = append(pool.endpoints, endpoint)
+		for i := 0; i < channels; i++ {
+			conn, err := grpc.DialContext(ctx, address, dialOpts...)
+			if err != nil {
+				_ = pool.Close()
+				return nil, fmt.Errorf("failed to connect to inference server %s: %w", address, err)
+			}
+			endpoint.channels = append(endpoint.channels, &poolChannel{conn: conn, index: i})
+		}
+		endpoint.client = pb.NewGRPCInferenceServiceClient(endpoint.channels[0].conn)
+	}
+	return pool, nil
+}
+
+// newClientPool wraps a client injected by the program embedding the processor
+// as the only endpoint of a pool, so it is health checked like a dialed
+// endpoint. Calls go to the client directly rather than through the pool.
+func newClientPool(client pb.GRPCInferenceServiceClient, logger *zap.Logger) *endpointPool {
+	endpoint := &poolEndpoint{address: "injected client", client: client}
+	endpoint.healthy.Store(true)
+	return &endpointPool{
+		endpoints: []*poolEndpoint{endpoint},
+		policy:    loadBalancingFailover,
+		logger:    logger,
+	}
+}
+
+// candidates returns the endpoints to try for a call, in order. Healthy
+// endpoints come first; unhealthy ones are kept as a last resort so that a
+// stale health state never rejects a call outright.
+func (p *endpointPool) candidates() []*poolEndpoint {
+	n := len(p.endpoints)
+	start := 0
+	if p.policy == loadBalancingRoundRobin && n > 1 {
+		start = int((p.next.Add(1) - 1) % uint64(n))
+	}
+
+	healthy := make([]*poolEndpoint, 0, n)
+	var unhealthy []*poolEndpoint
+	for i := 0; i < n; i++ {
+		endpoint := p.endpoints[(start+i)%n]
+		if endpoint.healthy.Load() {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// Invoke performs a unary call on the first candidate endpoint, failing over to
+// the next one when an endpoint is unavailable
+func (p *endpointPool) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	var err error
+	for _, endpoint := range p.candidates() {
+		channel := endpoint.channel()
+		p.trackInFlight(ctx, endpoint, channel, 1)
+		err = channel.conn.Invoke(ctx, method, args, reply, opts...)
+		p.trackInFlight(ctx, endpoint, channel, -1)
+		if err == nil {
+			endpoint.markHealthy(p.logger)
+			return nil
+		}
+		if status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+			return err
+		}
+		endpoint.markUnhealthy(p.logger, err)
+	}
+	return err
+}
+
+// NewStream opens a stream on the first candidate endpoint. Streams are not
+// retried, since part of the stream may already have been consumed.
+func (p *endpointPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return nil, status.Error(codes.Unavailable, "no inference endpoints configured")
+	}
+	return candidates[0].channel().conn.NewStream(ctx, desc, method, opts...)
+}
+
+// channel returns the channel the next call to an endpoint goes to, rotating
+// over its channels
+func (e *poolEndpoint) channel() *poolChannel {
+	if len(e.channels) == 1 {
+		return e.channels[0]
+	}
+	return e.channels[(e.next.Add(1)-1)%uint64(len(e.channels))]
+}
+
+// trackInFlight records a call starting (positive delta) or finishing on a channel
+func (p *endpointPool) trackInFlight(ctx context.Context, endpoint *poolEndpoint, channel *poolChannel, delta int64) {
+	channel.inFlight.Add(delta)
+	if p.telemetry != nil {
+		p.telemetry.recordChannelInFlight(context.WithoutCancel(ctx), endpoint.address, channel.index, delta)
+	}
+}
+
+// checkHealth probes every endpoint with ServerLive and records the result.
+// It returns an error only when no endpoint is live.
+func (p *endpointPool) checkHealth(ctx context.Context, headers map[string]string, timeout time.Duration) error {
+	if len(headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(headers))
+	}
+
+	var errs []error
+	for _, endpoint := range p.endpoints {
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := endpoint.client.ServerLive(probeCtx, &pb.ServerLiveRequest{})
+		cancel()
+		if err == nil && !resp.GetLive() {
+			err = errors.New("server is not live")
+		}
+		if err != nil {
+			endpoint.markUnhealthy(p.logger, err)
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint.address, err))
+			continue
+		}
+		endpoint.markHealthy(p.logger)
+	}
+
+	if len(errs) == len(p.endpoints) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// startHealthCheck probes the endpoints in the background at the given interval.
+// A single endpoint has nothing to fail over to, so it is not probed.
+func (p *endpointPool) startHealthCheck(headers map[string]string, interval, timeout time.Duration) {
+	if len(p.endpoints) < 2 || interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.cancel = cancel
+	p.done = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.checkHealth(ctx, headers, timeout); err != nil && ctx.Err() == nil {
+					p.logger.Warn("No inference endpoint is live", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the health check and closes every connection
+func (p *endpointPool) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+		p.cancel = nil
+		p.done = nil
+	}
+
+	var errs []error
+	for _, endpoint := range p.endpoints {
+		// Injected clients have no channels, they are closed by their owner
+		for _, channel := range endpoint.channels {
+			if err := channel.conn.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", endpoint.address, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// markHealthy records that an endpoint answered, logging the recovery
+func (e *poolEndpoint) markHealthy(logger *zap.Logger) {
+	if !e.healthy.Swap(true) {
+		logger.Info("Inference endpoint recovered", zap.String("endpoint", e.address))
+	}
+}
+
+// markUnhealthy records that an endpoint failed, logging the transition
+func (e *poolEndpoint) markUnhealthy(logger *zap.Logger, err error) {
+	if e.healthy.Swap(false) {
+		logger.Warn("Inference endpoint unavailable", zap.String("endpoint", e.address), zap.Error(err))
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// startPoolProcessor starts a processor with one rule against several endpoints
+func startPoolProcessor(t *testing.T, settings GRPCClientSettings) *metricsinferenceprocessor {
+	cfg := &Config{
+		GRPCClientSettings: settings,
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor
+}
+
+// consumeBatch sends one batch with the rule input through the processor
+func consumeBatch(t *testing.T, processor *metricsinferenceprocessor) {
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+}
+
+func TestEndpointFailover(t *testing.T) {
+	primary := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+	backup := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 2)))
+
+	processor := startPoolProcessor(t, GRPCClientSettings{
+		Endpoints: []string{primary.Endpoint(), backup.Endpoint()},
+	})
+
+	// Every call goes to the first endpoint while it is healthy
+	consumeBatch(t, processor)
+	consumeBatch(t, processor)
+	assert.Len(t, primary.GetRequests(), 2)
+	assert.Empty(t, backup.GetRequests())
+
+	// Once it goes down, calls fail over to the next endpoint
+	primary.Stop()
+	consumeBatch(t, processor)
+	consumeBatch(t, processor)
+	assert.Len(t, primary.GetRequests(), 2)
+	assert.Len(t, backup.GetRequests(), 2)
+	assert.False(t, processor.endpoints.endpoints[0].healthy.Load())
+}
+
+func TestEndpointRoundRobin(t *testing.T) {
+	first := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+	second := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 2)))
+
+	processor := startPoolProcessor(t, GRPCClientSettings{
+		Endpoints:     []string{first.Endpoint(), second.Endpoint()},
+		LoadBalancing: loadBalancingRoundRobin,
+	})
+
+	for i := 0; i < 4; i++ {
+		consumeBatch(t, processor)
+	}
+	assert.Len(t, first.GetRequests(), 2)
+	assert.Len(t, second.GetRequests(), 2)
+}
+
+func TestEndpointDownAtStartup(t *testing.T) {
+	down := testutil.StartMockServer(t)
+	down.Stop()
+	live := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	processor := startPoolProcessor(t, GRPCClientSettings{
+		Endpoints:           []string{down.Endpoint(), live.Endpoint()},
+		HealthCheckInterval: 50 * time.Millisecond,
+	})
+
+	// The startup health check already skips the endpoint that is down
+	assert.False(t, processor.endpoints.endpoints[0].healthy.Load())
+	assert.True(t, processor.endpoints.endpoints[1].healthy.Load())
+
+	consumeBatch(t, processor)
+	assert.Len(t, live.GetRequests(), 1)
+
+	// The background health check keeps probing every endpoint
+	calls := live.GetServerLiveCalls()
+	assert.Eventually(t, func() bool {
+		return live.GetServerLiveCalls() > calls
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAllEndpointsDown(t *testing.T) {
+	first := testutil.StartMockServer(t)
+	second := testutil.StartMockServer(t)
+	first.Stop()
+	second.Stop()
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoints: []string{first.Endpoint(), second.Endpoint()}},
+		Timeout:            1,
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}}},
+	}
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.ErrorContains(t, processor.Start(context.Background(), nil), "inference server health check failed")
+	assert.NoError(t, processor.Shutdown(context.Background()))
+}
+
+func TestEndpointChannels(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint(), Channels: 3},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	reader := sdkmetric.NewManualReader()
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	endpoint := processor.endpoints.endpoints[0]
+	require.Len(t, endpoint.channels, 3)
+	for i := 0; i < 3; i++ {
+		consumeBatch(t, processor)
+	}
+	assert.Len(t, mockServer.GetRequests(), 3)
+
+	// Calls rotated over every channel, and none is left in flight
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	channels := make(map[int64]bool)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otelcol_processor_metricsinference_channel_in_flight" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				assert.Zero(t, dp.Value)
+				channel, _ := dp.Attributes.Value(telemetryAttrChannel)
+				channels[channel.AsInt64()] = true
+			}
+		}
+	}
+	assert.Equal(t, map[int64]bool{0: true, 1: true, 2: true}, channels)
+	for _, channel := range endpoint.channels {
+		assert.Zero(t, channel.inFlight.Load())
+	}
+}
+
+func TestGRPCClientSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings GRPCClientSettings
+		wantErr  string
+	}{
+		{name: "single endpoint", settings: GRPCClientSettings{Endpoint: "a:8001"}},
+		{name: "endpoint list", settings: GRPCClientSettings{Endpoints: []string{"a:8001", "b:8001"}, LoadBalancing: loadBalancingRoundRobin}},
+		{
+			name:     "both endpoint forms",
+			settings: GRPCClientSettings{Endpoint: "a:8001", Endpoints: []string{"b:8001"}},
+			wantErr:  "mutually exclusive",
+		},
+		{
+			name:     "empty endpoint",
+			settings: GRPCClientSettings{Endpoints: []string{"a:8001", ""}},
+			wantErr:  "endpoints[1] must not be empty",
+		},
+		{
+			name:     "duplicate endpoint",
+			settings: GRPCClientSettings{Endpoints: []string{"a:8001", "a:8001"}},
+			wantErr:  "duplicate endpoint",
+		},
+		{
+			name:     "unknown policy",
+			settings: GRPCClientSettings{Endpoints: []string{"a:8001"}, LoadBalancing: "random"},
+			wantErr:  "invalid load_balancing",
+		},
+		{
+			name:     "negative health check interval",
+			settings: GRPCClientSettings{Endpoint: "a:8001", HealthCheckInterval: -time.Second},
+			wantErr:  "health_check_interval",
+		},
+		{
+			name:     "negative channels",
+			settings: GRPCClientSettings{Endpoint: "a:8001", Channels: -1},
+			wantErr:  "channels must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRoundRobinWithSequences(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoints: []string{"a:8001", "b:8001"}, LoadBalancing: loadBalancingRoundRobin},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName: "rnn_model",
+			Inputs:    []string{"cpu_usage"},
+			Sequence:  SequenceConfig{Enabled: true},
+		}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "round_robin load balancing would spread the sequence over several endpoints")
+
+	cfg.GRPCClientSettings.LoadBalancing = loadBalancingFailover
+	assert.NoError(t, cfg.Validate())
+
+	// A single endpoint keeps the sequence in one place whatever the policy
+	cfg.GRPCClientSettings.LoadBalancing = loadBalancingRoundRobin
+	cfg.GRPCClientSettings.Endpoints = []string{"a:8001"}
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// errorMetricName is the gauge recording failed inferences in the batch
+	errorMetricName = "otel.inference.error"
+
+	// Attributes of error data points
+	labelRuleIndex      = "otel.inference.rule.index"
+	labelGRPCStatusCode = "rpc.grpc.status_code"
+)
+
+// errorCode returns the gRPC status code of a failed inference. Calls dropped by
+// the inference queue map to ResourceExhausted, and calls cancelled or out of
+// time before the server answered to Canceled and DeadlineExceeded.
+func errorCode(err error) codes.Code {
+	if errors.Is(err, errQueueFull) {
+		return codes.ResourceExhausted
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
+
+// emitErrorMetric adds a data point of 1 to the otel.inference.error gauge for a
+// failed inference, in the scope the rule's outputs go to, when error metrics
+// are enabled
+func (mp *metricsinferenceprocessor) emitErrorMetric(md pmetric.Metrics, context *modelContext, err error) {
+	if !mp.config.ErrorMetrics.Enabled {
+		return
+	}
+	sm, scopeErr := mp.outputScopeMetrics(md, context)
+	if scopeErr != nil {
+		mp.logger.Warn("Cannot emit error metric", zap.String("model", context.rule.modelName), zap.Error(scopeErr))
+		return
+	}
+
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName(errorMetricName)
+	metric.SetDescription("Failed inference of a metrics inference rule")
+	metric.SetUnit("1")
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	dp.SetIntValue(1)
+
+	attrs := dp.Attributes()
+	putModelLabels(attrs, &context.rule)
+	attrs.PutInt(labelRuleIndex, int64(context.ruleIndex))
+	if context.expansion != nil {
+		attrs.PutStr(context.rule.expandBy, context.expansion.value)
+	}
+	attrs.PutInt(labelGRPCStatusCode, int64(errorCode(err)))
+}
@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestErrorMetrics(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)),
+				testutil.WithModelError("broken", status.Error(codes.Unavailable, "model not loaded")))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				ErrorMetrics:       ErrorMetricsConfig{Enabled: enabled},
+				Rules: []Rule{
+					{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "scorer.{output}", Outputs: []OutputSpec{{Name: "score"}}},
+					{ModelName: "broken", ModelVersion: "2", Inputs: []string{"metric_1"}, OutputPattern: "broken.{output}", Outputs: []OutputSpec{{Name: "score"}}},
+				},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"metric_1"},
+				MetricValues: [][]float64{{1}},
+			})
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+			errorMetric := findMetricByName(sink.AllMetrics()[0], errorMetricName)
+			if !enabled {
+				assert.Empty(t, errorMetric.Name())
+				return
+			}
+
+			// Only the failed rule records an error
+			require.Equal(t, 1, errorMetric.Gauge().DataPoints().Len())
+			dp := errorMetric.Gauge().DataPoints().At(0)
+			assert.Equal(t, int64(1), dp.IntValue())
+			assert.Equal(t, map[string]any{
+				labelInferenceModelName:    "broken",
+				labelInferenceModelVersion: "2",
+				labelRuleIndex:             int64(1),
+				labelGRPCStatusCode:        int64(codes.Unavailable),
+			}, dp.Attributes().AsRaw())
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, codes.NotFound, errorCode(fmt.Errorf("inference failed: %w", status.Error(codes.NotFound, "no model"))))
+	assert.Equal(t, codes.DeadlineExceeded, errorCode(context.DeadlineExceeded))
+	assert.Equal(t, codes.Canceled, errorCode(context.Canceled))
+	assert.Equal(t, codes.ResourceExhausted, errorCode(fmt.Errorf("%w: %w", errQueueFull, context.Canceled)))
+	assert.Equal(t, codes.Unknown, errorCode(errors.New("invalid response")))
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Scale limits of exponential histograms as defined by the OpenTelemetry data model
+const (
+	minExponentialScale = -10
+	maxExponentialScale = 20
+)
+
+// exponentialHistogramFeatureHeader is the number of leading values of a feature
+// vector: count, sum and zero count
+const exponentialHistogramFeatureHeader = 3
+
+// validateExponentialHistogramConfig checks the fixed-length feature configuration
+func validateExponentialHistogramConfig(cfg ExponentialHistogramConfig) error {
+	if cfg.Buckets < 0 {
+		return fmt.Errorf("buckets must not be negative")
+	}
+	if cfg.Buckets > 0 && (cfg.Scale < minExponentialScale || cfg.Scale > maxExponentialScale) {
+		return fmt.Errorf("scale %d out of range [%d, %d]", cfg.Scale, minExponentialScale, maxExponentialScale)
+	}
+	return nil
+}
+
+// exponentialHistogramFeatureLength returns the length of the feature vector
+// produced for every data point
+func exponentialHistogramFeatureLength(cfg ExponentialHistogramConfig) int {
+	return exponentialHistogramFeatureHeader + 2*cfg.Buckets
+}
+
+// exponentialHistogramFeatures converts a data point into a fixed-length feature vector:
+// [count, sum, zero_count, positive buckets..., negative buckets...]. Buckets are
+// rescaled to the target scale and cover the bucket indexes [offset, offset+buckets)
+// for each sign; counts outside that range are folded into the first or last bucket
+// so the vector always accounts for every observation.
+func exponentialHistogramFeatures(dp pmetric.ExponentialHistogramDataPoint, cfg ExponentialHistogramConfig) []float64 {
+	features := make([]float64, exponentialHistogramFeatureLength(cfg))
+	features[0] = float64(dp.Count())
+	features[1] = dp.Sum()
+	features[2] = float64(dp.ZeroCount())
+
+	positive := features[exponentialHistogramFeatureHeader : exponentialHistogramFeatureHeader+cfg.Buckets]
+	negative := features[exponentialHistogramFeatureHeader+cfg.Buckets:]
+	rescaleBuckets(positive, dp.Positive(), dp.Scale(), cfg.Scale, cfg.Offset)
+	rescaleBuckets(negative, dp.Negative(), dp.Scale(), cfg.Scale, cfg.Offset)
+	return features
+}
+
+// rescaleBuckets adds the counts of one sign's buckets, recorded at scale from,
+// into out at scale to, with out[0] holding bucket index offset
+func rescaleBuckets(out []float64, buckets pmetric.ExponentialHistogramDataPointBuckets, from, to, offset int32) {
+	counts := buckets.BucketCounts()
+	for i := 0; i < counts.Len(); i++ {
+		if count := counts.At(i); count > 0 {
+			addRescaledBucket(out, int64(buckets.Offset())+int64(i), float64(count), from, to, offset)
+		}
+	}
+}
+
+// addRescaledBucket adds the count of bucket index at scale from into out at scale to.
+// Lowering the scale merges 2^(from-to) buckets into one exactly. Raising it splits a
+// bucket into 2^(to-from) narrower ones, and the count is spread evenly across them
+// since the distribution within a bucket is unknown.
+func addRescaledBucket(out []float64, index int64, count float64, from, to, offset int32) {
+	first := int64(offset)
+	last := first + int64(len(out)) - 1
+
+	if to <= from {
+		target := index >> uint(from-to) // Arithmetic shift rounds negative indexes down
+		out[clampBucket(target, first, last)-first] += count
+		return
+	}
+
+	shift := uint(to - from)
+	width := int64(1) << shift
+	lo := index << shift
+	hi := lo + width - 1
+	share := count / float64(width)
+
+	if lo < first {
+		out[0] += share * float64(min(hi, first-1)-lo+1)
+	}
+	if hi > last {
+		out[len(out)-1] += share * float64(hi-max(lo, last+1)+1)
+	}
+	for k := max(lo, first); k <= min(hi, last); k++ {
+		out[k-first] += share
+	}
+}
+
+// clampBucket limits a bucket index to the range [first, last]
+func clampBucket(index, first, last int64) int64 {
+	if index < first {
+		return first
+	}
+	if index > last {
+		return last
+	}
+	return index
+}
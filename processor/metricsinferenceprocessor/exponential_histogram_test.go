@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func appendExponentialDataPoint(metric pmetric.Metric, scale, offset int32, positive []uint64) {
+	dp := metric.ExponentialHistogram().DataPoints().AppendEmpty()
+	dp.SetScale(scale)
+	dp.SetZeroCount(1)
+	var count uint64 = 1
+	for _, c := range positive {
+		count += c
+	}
+	dp.SetCount(count)
+	dp.SetSum(42)
+	dp.Positive().SetOffset(offset)
+	dp.Positive().BucketCounts().FromRaw(positive)
+}
+
+func TestExponentialHistogramFeatures(t *testing.T) {
+	metric := pmetric.NewMetric()
+	metric.SetEmptyExponentialHistogram()
+	cfg := ExponentialHistogramConfig{Scale: 0, Buckets: 4, Offset: 0}
+
+	tests := []struct {
+		name     string
+		scale    int32
+		offset   int32
+		positive []uint64
+		want     []float64
+	}{
+		{
+			name:     "same scale",
+			scale:    0,
+			offset:   1,
+			positive: []uint64{2, 3},
+			want:     []float64{0, 2, 3, 0},
+		},
+		{
+			name:     "downscale merges buckets",
+			scale:    1,
+			offset:   0,
+			positive: []uint64{1, 2, 3, 4},
+			want:     []float64{3, 7, 0, 0},
+		},
+		{
+			name:     "upscale splits buckets evenly",
+			scale:    -1,
+			offset:   0,
+			positive: []uint64{4},
+			want:     []float64{2, 2, 0, 0},
+		},
+		{
+			name:     "buckets outside the range fold into the edges",
+			scale:    0,
+			offset:   -2,
+			positive: []uint64{1, 1, 1, 1, 1, 1, 1, 1},
+			want:     []float64{3, 1, 1, 3},
+		},
+		{
+			name:     "upscale below the range",
+			scale:    -2,
+			offset:   -1,
+			positive: []uint64{4, 8},
+			want:     []float64{6, 2, 2, 2},
+		},
+		{
+			name:     "upscale above the range",
+			scale:    -1,
+			offset:   1,
+			positive: []uint64{2, 4},
+			want:     []float64{0, 0, 1, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dps := metric.ExponentialHistogram().DataPoints()
+			dps.RemoveIf(func(pmetric.ExponentialHistogramDataPoint) bool { return true })
+			appendExponentialDataPoint(metric, tt.scale, tt.offset, tt.positive)
+
+			features := exponentialHistogramFeatures(dps.At(0), cfg)
+			require.Len(t, features, exponentialHistogramFeatureLength(cfg))
+			assert.Equal(t, float64(dps.At(0).Count()), features[0])
+			assert.Equal(t, 42.0, features[1])
+			assert.Equal(t, 1.0, features[2])
+			assert.Equal(t, tt.want, features[3:7])
+			assert.Equal(t, []float64{0, 0, 0, 0}, features[7:])
+		})
+	}
+}
+
+func TestExponentialHistogramTensorShape(t *testing.T) {
+	metric := pmetric.NewMetric()
+	metric.SetName("latency")
+	metric.SetEmptyExponentialHistogram()
+	appendExponentialDataPoint(metric, 3, 10, []uint64{1, 2, 3})
+	appendExponentialDataPoint(metric, 1, -4, []uint64{5})
+
+	settings := EncoderSettings{DataHandling: DataHandlingConfig{
+		ExponentialHistogram: ExponentialHistogramConfig{Scale: 1, Buckets: 8},
+	}}
+
+	tensor, err := encodeExponentialHistogram("latency", metric, settings)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 19}, tensor.Shape, "every data point should produce a row of the same length")
+	assert.Len(t, tensor.Contents.Fp64Contents, 38)
+}
+
+func TestValidateExponentialHistogramConfig(t *testing.T) {
+	assert.NoError(t, validateExponentialHistogramConfig(ExponentialHistogramConfig{}))
+	assert.NoError(t, validateExponentialHistogramConfig(ExponentialHistogramConfig{Scale: 4, Buckets: 160, Offset: -20}))
+	assert.ErrorContains(t, validateExponentialHistogramConfig(ExponentialHistogramConfig{Buckets: -1}), "must not be negative")
+	assert.ErrorContains(t, validateExponentialHistogramConfig(ExponentialHistogramConfig{Scale: 21, Buckets: 8}), "out of range")
+}
@@ -6,28 +6,86 @@ package metricsinferenceprocessor // import "github.com/rbellamy/opentelemetry-i
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/processor"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/metadata"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
 )
 
 // This file handles the creation of the processor factory and the processor instances.
 
 var processorCapabilities = consumer.Capabilities{MutatesData: true}
 
+// FactoryOption customizes the processors a factory creates, for custom collector
+// distributions that extend the processor without forking it.
+type FactoryOption func(*factoryOptions)
+
+// factoryOptions holds the customizations of a factory's processors
+type factoryOptions struct {
+	unaryInterceptors []grpc.UnaryClientInterceptor
+	client            pb.GRPCInferenceServiceClient // Replaces the connection to the configured endpoints, nil when not injected
+}
+
+// WithUnaryClientInterceptors adds unary interceptors around every call of the
+// inference client to the inference server, such as to refresh auth tokens, record
+// custom metrics or inject faults. The first interceptor is the outermost.
+// Rules with in-process backends do not call the server and are not intercepted.
+func WithUnaryClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) FactoryOption {
+	return func(o *factoryOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
 // NewFactory returns a new factory for the Metrics Inference processor.
-func NewFactory() processor.Factory {
+func NewFactory(opts ...FactoryOption) processor.Factory {
+	var options factoryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 	return processor.NewFactory(
-		metadata.Type,       // Type of the processor
-		createDefaultConfig, // Function to create default configuration
-		processor.WithMetrics(createMetricsProcessor, metadata.MetricsStability), // Specify it's a metrics processor
+		metadata.Type,                 // Type of the processor
+		options.createDefaultConfig(), // Function to create default configuration
+		processor.WithMetrics(options.createMetricsProcessor, metadata.MetricsStability), // Specify it's a metrics processor
 	)
 }
 
+// NewFactoryWithClient returns a factory whose processors send every call to
+// the given inference client instead of dialing the configured endpoints, for
+// programs embedding the collector that bring their own connection or a mock.
+// grpc.endpoint is then optional, and the connection settings and interceptors
+// of the factory do not apply; the client is not closed at shutdown.
+func NewFactoryWithClient(client pb.GRPCInferenceServiceClient, opts ...FactoryOption) processor.Factory {
+	return NewFactory(append(opts, WithClient(client))...)
+}
+
+// WithClient sends every call of the factory's processors to the given inference
+// client, as NewFactoryWithClient does, for factories built on top of this one
+// such as the inference connector's.
+func WithClient(client pb.GRPCInferenceServiceClient) FactoryOption {
+	return func(o *factoryOptions) {
+		o.client = client
+	}
+}
+
+// createDefaultConfig returns the function creating the default configuration,
+// which does not require an endpoint when a client is injected
+func (o factoryOptions) createDefaultConfig() component.CreateDefaultConfigFunc {
+	if o.client == nil {
+		return createDefaultConfig
+	}
+	return func() component.Config {
+		cfg := createDefaultConfig().(*Config)
+		cfg.clientInjected = true
+		return cfg
+	}
+}
+
 // createDefaultConfig creates the default configuration for the processor.
 func createDefaultConfig() component.Config {
 	return &Config{
@@ -47,11 +105,23 @@ func createDefaultConfig() component.Config {
 			AlignTimestamps:    true,     // Default to temporal alignment
 			TimestampTolerance: 1000,     // 1 second tolerance
 		},
+		Cache: CacheConfig{
+			Enabled:    false,       // Opt-in, results are reused only when configured
+			TTL:        time.Minute, // Cached results expire after a minute
+			MaxEntries: 1000,
+		},
+		Units: UnitsConfig{
+			Validation: "warn", // Report suspicious units without rejecting the configuration
+		},
+		Logging: LoggingConfig{
+			RepeatInterval:    time.Minute,      // Log a repeated warning at most once a minute at first
+			MaxRepeatInterval: 15 * time.Minute, // Back off to one summary every 15 minutes
+		},
 	}
 }
 
 // createMetricsProcessor creates the metrics processor based on the config.
-func createMetricsProcessor(
+func (o factoryOptions) createMetricsProcessor(
 	ctx context.Context, // Keep ctx for potential future use in processor creation/start
 	set processor.Settings, // Settings for creating the processor
 	cfg component.Config,
@@ -68,6 +138,16 @@ func createMetricsProcessor(
 		set.Logger.Error("Failed to create metrics inference processor", zap.Error(err))
 		return nil, fmt.Errorf("failed to create metrics inference processor: %w", err)
 	}
+	mp.id = set.ID
+	mp.scopeVersion = set.BuildInfo.Version
+	mp.unaryInterceptors = o.unaryInterceptors
+	mp.injectedClient = o.client
+
+	// Report internal telemetry through the collector's meter and tracer providers
+	mp.telemetry, err = newProcessorTelemetry(set.MeterProvider, set.TracerProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics inference processor telemetry: %w", err)
+	}
 
 	// Return the processor directly since it already implements processor.Metrics
 	return mp, nil
@@ -5,8 +5,12 @@ package metricsinferenceprocessor
 
 import (
 	"context"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,8 +18,10 @@ import (
 	"go.opentelemetry.io/collector/confmap/confmaptest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/processor/processortest"
+	"google.golang.org/grpc"
 
 	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/metadata"
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
 )
 
 func TestType(t *testing.T) {
@@ -43,6 +49,18 @@ func TestCreateDefaultConfig(t *testing.T) {
 			AlignTimestamps:    true,
 			TimestampTolerance: 1000,
 		},
+		Cache: CacheConfig{
+			Enabled:    false,
+			TTL:        time.Minute,
+			MaxEntries: 1000,
+		},
+		Units: UnitsConfig{
+			Validation: "warn",
+		},
+		Logging: LoggingConfig{
+			RepeatInterval:    time.Minute,
+			MaxRepeatInterval: 15 * time.Minute,
+		},
 	}
 	assert.Equal(t, expected, cfg)
 	assert.NoError(t, componenttest.CheckConfigStruct(cfg))
@@ -83,3 +101,50 @@ func TestCreateProcessors(t *testing.T) {
 		})
 	}
 }
+
+func TestUnaryClientInterceptors(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	// Interceptors run in registration order, around every call to the server
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			mu.Lock()
+			calls = append(calls, name+" "+path.Base(method))
+			mu.Unlock()
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	factory := NewFactory(WithUnaryClientInterceptors(record("outer")), WithUnaryClientInterceptors(record("inner")))
+
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.GRPCClientSettings.Endpoint = mockServer.Endpoint()
+	cfg.Rules = []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}}}
+
+	sink := &consumertest.MetricsSink{}
+	mp, err := factory.CreateMetrics(context.Background(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, mp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, mp.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, mp.ConsumeMetrics(context.Background(), input))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, calls, "outer ModelMetadata")
+	var inferCalls []string
+	for _, call := range calls {
+		if strings.HasSuffix(call, " ModelInfer") {
+			inferCalls = append(inferCalls, call)
+		}
+	}
+	assert.Equal(t, []string{"outer ModelInfer", "inner ModelInfer"}, inferCalls)
+}
@@ -0,0 +1,315 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Fallback policies for outputs of failed inferences
+const (
+	fallbackPolicySkip      = "skip"
+	fallbackPolicyLastValue = "last_value"
+	fallbackPolicyConstant  = "constant"
+)
+
+// defaultFallbackTTL is how long last values are kept when no TTL is configured
+const defaultFallbackTTL = 5 * time.Minute
+
+// lastValuePruneInterval is how often last values past their TTL are looked for
+// under every resource, including resources no longer produced whose fallbacks
+// are never emitted
+const lastValuePruneInterval = time.Minute
+
+// outputFallback is the internal form of an output's fallback configuration
+type outputFallback struct {
+	policy string
+	value  float64
+	ttl    time.Duration
+}
+
+// newOutputFallback converts a fallback configuration, applying defaults
+func newOutputFallback(cfg FallbackConfig) outputFallback {
+	fallback := outputFallback{policy: cfg.Policy, value: cfg.Value, ttl: cfg.TTL}
+	if fallback.policy == "" {
+		fallback.policy = fallbackPolicySkip
+	}
+	if fallback.ttl == 0 {
+		fallback.ttl = defaultFallbackTTL
+	}
+	return fallback
+}
+
+// validateFallbackConfig checks an output's fallback configuration
+func validateFallbackConfig(cfg FallbackConfig) error {
+	switch cfg.Policy {
+	case "", fallbackPolicySkip, fallbackPolicyLastValue, fallbackPolicyConstant:
+	default:
+		return fmt.Errorf("invalid policy %q (must be 'skip', 'last_value', or 'constant')", cfg.Policy)
+	}
+	if cfg.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	return nil
+}
+
+// lastValueOutput identifies an output of a rule
+type lastValueOutput struct {
+	ruleIdx   int
+	outputIdx int
+}
+
+// lastValueEntry is the last successful data point of one attribute set
+type lastValueEntry struct {
+	dataPoint pmetric.NumberDataPoint
+	recorded  time.Time
+}
+
+// lastValueResource is the last values of an output produced under one resource
+type lastValueResource struct {
+	attributes pcommon.Map
+	metrics    map[string]map[string]*lastValueEntry // Metric name -> attribute set key -> entry
+}
+
+// lastValueStore keeps the last successful data point per output metric and
+// attribute set of each resource, for outputs with the last_value fallback policy
+type lastValueStore struct {
+	mu        sync.Mutex
+	entries   map[lastValueOutput]map[string]*lastValueResource // Output -> resource attribute set key -> last values
+	ttls      map[lastValueOutput]time.Duration                 // TTL of every output recorded or emitted since the start
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// newLastValueStore creates an empty last value store
+func newLastValueStore() *lastValueStore {
+	return &lastValueStore{
+		entries: make(map[lastValueOutput]map[string]*lastValueResource),
+		ttls:    make(map[lastValueOutput]time.Duration),
+		now:     time.Now,
+	}
+}
+
+// forgetRules forgets the last values of the rules from index from on, whose
+// rules were replaced
+func (s *lastValueStore) forgetRules(from int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for output := range s.entries {
+		if output.ruleIdx >= from {
+			delete(s.entries, output)
+		}
+	}
+}
+
+// resourceEntries returns the last values of an output under a resource,
+// creating them if none were recorded. The caller must hold s.mu.
+func (s *lastValueStore) resourceEntries(key lastValueOutput, resource pcommon.Map) *lastValueResource {
+	byResource, exists := s.entries[key]
+	if !exists {
+		byResource = make(map[string]*lastValueResource)
+		s.entries[key] = byResource
+	}
+	resourceKey := attributeSetKey(resource)
+	entries, exists := byResource[resourceKey]
+	if !exists {
+		entries = &lastValueResource{
+			attributes: pcommon.NewMap(),
+			metrics:    make(map[string]map[string]*lastValueEntry),
+		}
+		resource.CopyTo(entries.attributes)
+		byResource[resourceKey] = entries
+	}
+	return entries
+}
+
+// record stores the gauge data points of metrics[first:], which were produced
+// for one output under resource, and are kept for ttl
+func (s *lastValueStore) record(ruleIdx, outputIdx int, resource pcommon.Resource, ttl time.Duration, metrics pmetric.MetricSlice, first int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := lastValueOutput{ruleIdx: ruleIdx, outputIdx: outputIdx}
+	s.ttls[key] = ttl
+	now := s.now()
+	s.prune(now)
+	byMetric := s.resourceEntries(key, resource.Attributes()).metrics
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if metric.Type() != pmetric.MetricTypeGauge {
+			continue
+		}
+		bySeries, exists := byMetric[metric.Name()]
+		if !exists {
+			bySeries = make(map[string]*lastValueEntry)
+			byMetric[metric.Name()] = bySeries
+		}
+		dps := metric.Gauge().DataPoints()
+		for j := 0; j < dps.Len(); j++ {
+			dp := pmetric.NewNumberDataPoint()
+			dps.At(j).CopyTo(dp)
+			bySeries[attributeSetKey(dp.Attributes())] = &lastValueEntry{dataPoint: dp, recorded: now}
+		}
+	}
+}
+
+// emit appends the unexpired last values of an output under resource to sm, one
+// metric per recorded metric name, dropping expired entries. It returns the
+// metrics added.
+func (s *lastValueStore) emit(ruleIdx, outputIdx int, resource pcommon.Resource, ttl time.Duration, sm pmetric.ScopeMetrics) []pmetric.Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := lastValueOutput{ruleIdx: ruleIdx, outputIdx: outputIdx}
+	s.ttls[key] = ttl
+	entries, exists := s.entries[key][attributeSetKey(resource.Attributes())]
+	if !exists {
+		return nil
+	}
+	byMetric := entries.metrics
+	names := make([]string, 0, len(byMetric))
+	for name := range byMetric {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := s.now()
+	var emitted []pmetric.Metric
+	for _, name := range names {
+		bySeries := byMetric[name]
+		keys := make([]string, 0, len(bySeries))
+		for key, entry := range bySeries {
+			if now.Sub(entry.recorded) > ttl {
+				delete(bySeries, key)
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			delete(byMetric, name)
+			continue
+		}
+		sort.Strings(keys)
+
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		dps := metric.SetEmptyGauge().DataPoints()
+		for _, key := range keys {
+			dp := dps.AppendEmpty()
+			bySeries[key].dataPoint.CopyTo(dp)
+			dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+		}
+		emitted = append(emitted, metric)
+	}
+	return emitted
+}
+
+// prune forgets the last values past their output's TTL under every resource,
+// and the resources left without any, checking at most once per
+// lastValuePruneInterval. Outputs whose TTL is not known yet, as restored ones,
+// are kept. The caller must hold s.mu.
+func (s *lastValueStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < lastValuePruneInterval {
+		return
+	}
+	s.lastPrune = now
+	for output, byResource := range s.entries {
+		ttl, known := s.ttls[output]
+		if !known {
+			continue
+		}
+		for resourceKey, entries := range byResource {
+			for name, bySeries := range entries.metrics {
+				for key, entry := range bySeries {
+					if now.Sub(entry.recorded) > ttl {
+						delete(bySeries, key)
+					}
+				}
+				if len(bySeries) == 0 {
+					delete(entries.metrics, name)
+				}
+			}
+			if len(entries.metrics) == 0 {
+				delete(byResource, resourceKey)
+			}
+		}
+	}
+}
+
+// emitFallbacks adds fallback values for the outputs of a rule whose inference
+// failed, leaving out those marked in produced, which the response did yield.
+// It returns true if any metric was added.
+func (mp *metricsinferenceprocessor) emitFallbacks(md pmetric.Metrics, context *modelContext, produced []bool) bool {
+	rule := &mp.rules[context.ruleIndex]
+
+	var sm pmetric.ScopeMetrics
+	hasScope := false
+	added := false
+	first := 0
+	for outputIdx, outputSpec := range rule.outputs {
+		if outputSpec.fallback.policy == fallbackPolicySkip || (outputIdx < len(produced) && produced[outputIdx]) {
+			continue
+		}
+		if !hasScope {
+			var err error
+			if sm, err = mp.outputScopeMetrics(md, context); err != nil {
+				mp.logger.Warn("Cannot emit fallback values", zap.String("model", rule.modelName), zap.Error(err))
+				return false
+			}
+			hasScope = true
+			first = sm.Metrics().Len()
+		}
+
+		description := mp.outputDescription(rule, outputIdx, outputSpec, "")
+		unit := outputSpec.unit
+		if unit == "" {
+			unit = postTransformsUnit(outputSpec.post)
+		}
+
+		var metrics []pmetric.Metric
+		switch outputSpec.fallback.policy {
+		case fallbackPolicyLastValue:
+			metrics = mp.lastValues.emit(context.ruleIndex, outputIdx, outputResource(md, context), outputSpec.fallback.ttl, sm)
+		case fallbackPolicyConstant:
+			metric := sm.Metrics().AppendEmpty()
+			metric.SetName(mp.outputMetricName(rule, outputIdx, outputSpec, ""))
+			dps := metric.SetEmptyGauge().DataPoints()
+			groups := len(context.matchedDataPoints)
+			if groups == 0 {
+				groups = 1
+			}
+			for i := 0; i < groups; i++ {
+				dp := dps.AppendEmpty()
+				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+				dp.SetDoubleValue(outputSpec.fallback.value)
+				copyAttributesFromDataPointGroup(dp, context, i)
+			}
+			metrics = append(metrics, metric)
+		}
+
+		for _, metric := range metrics {
+			metric.SetDescription(description)
+			metric.SetUnit(unit)
+		}
+		if len(metrics) > 0 {
+			added = true
+			mp.logger.Debug("Emitted fallback values for failed inference",
+				zap.String("model", rule.modelName),
+				zap.String("output", outputSpec.name),
+				zap.String("policy", outputSpec.fallback.policy),
+				zap.Int("metric_count", len(metrics)))
+		}
+	}
+	if added {
+		mp.recordOutputSeries(md, context, sm, first)
+	}
+	return added
+}
@@ -0,0 +1,269 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// fallbackInput creates a batch with two CPU series
+func fallbackInput() pmetric.Metrics {
+	return testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{
+			MetricName: "cpu_usage",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 0.2, Attributes: map[string]string{"cpu": "0"}},
+				{Value: 0.4, Attributes: map[string]string{"cpu": "1"}},
+			},
+		},
+	})
+}
+
+// newFallbackProcessor starts a processor with one rule whose output uses the given fallback
+func newFallbackProcessor(t *testing.T, mockServer *testutil.MockInferenceServer, fallback FallbackConfig) (*metricsinferenceprocessor, *consumertest.MetricsSink) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecast_model",
+				Inputs:        []string{"cpu_usage"},
+				Outputs:       []OutputSpec{{Name: "forecast", Unit: "1", Fallback: fallback}},
+				OutputPattern: "{output}",
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor, sink
+}
+
+// forecastValues returns the forecast values of a batch by CPU
+func forecastValues(t *testing.T, md pmetric.Metrics) map[string]float64 {
+	metric := findMetricByName(md, "forecast")
+	if metric.Type() != pmetric.MetricTypeGauge {
+		return nil
+	}
+	assert.Equal(t, "1", metric.Unit())
+	values := make(map[string]float64)
+	dps := metric.Gauge().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		cpu, ok := dps.At(i).Attributes().Get("cpu_usage.cpu")
+		require.True(t, ok)
+		values[cpu.AsString()] = dps.At(i).DoubleValue()
+	}
+	return values
+}
+
+func TestFallbackLastValue(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("forecast_model", &pb.ModelInferResponse{
+		ModelName: "forecast_model",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{Name: "forecast", Datatype: "FP64", Shape: []int64{2}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.25, 0.45}}},
+		},
+	})
+
+	processor, sink := newFallbackProcessor(t, mockServer, FallbackConfig{Policy: "last_value", TTL: time.Minute})
+	now := time.Now()
+	processor.lastValues.now = func() time.Time { return now }
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Equal(t, map[string]float64{"0": 0.25, "1": 0.45}, forecastValues(t, sink.AllMetrics()[0]))
+
+	// The model fails, so the last successful values are re-emitted
+	mockServer.SetModelError("forecast_model", errors.New("model unavailable"))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Equal(t, map[string]float64{"0": 0.25, "1": 0.45}, forecastValues(t, sink.AllMetrics()[1]))
+
+	// Once the values are older than the TTL nothing is emitted
+	now = now.Add(time.Minute)
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Nil(t, forecastValues(t, sink.AllMetrics()[2]))
+}
+
+func TestFallbackConstant(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+	mockServer.SetModelError("forecast_model", errors.New("model unavailable"))
+
+	processor, sink := newFallbackProcessor(t, mockServer, FallbackConfig{Policy: "constant", Value: -1})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Equal(t, map[string]float64{"0": -1, "1": -1}, forecastValues(t, sink.AllMetrics()[0]))
+}
+
+func TestFallbackSkip(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+	mockServer.SetModelError("forecast_model", errors.New("model unavailable"))
+
+	processor, sink := newFallbackProcessor(t, mockServer, FallbackConfig{})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Nil(t, forecastValues(t, sink.AllMetrics()[0]))
+}
+
+func TestFallbackRequestCreationFailure(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecast_model",
+				Inputs:        []string{"cpu_usage"},
+				Outputs:       []OutputSpec{{Name: "forecast", Unit: "1", Fallback: FallbackConfig{Policy: "constant", Value: -1}}},
+				OutputPattern: "{output}",
+				Parameters:    map[string]interface{}{"entity": "{attr:host.name}"},
+			},
+		},
+	}
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// The resource has no host.name, so the request is never built
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Equal(t, map[string]float64{"0": -1, "1": -1}, forecastValues(t, sink.AllMetrics()[0]))
+}
+
+func TestFallbackPartialResponse(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	// The model answers with the forecast but not its bound
+	mockServer.SetModelResponse("forecast_model", &pb.ModelInferResponse{
+		ModelName: "forecast_model",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{Name: "forecast", Datatype: "FP64", Shape: []int64{2}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.25, 0.45}}},
+		},
+	})
+
+	fallback := FallbackConfig{Policy: "constant", Value: -1}
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "forecast_model",
+				Inputs:    []string{"cpu_usage"},
+				Outputs: []OutputSpec{
+					{Name: "forecast", TensorName: "forecast", Unit: "1", Fallback: fallback},
+					{Name: "bound", TensorName: "bound", Unit: "1", Fallback: fallback},
+				},
+				OutputPattern: "{output}",
+			},
+		},
+	}
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), fallbackInput()))
+	md := sink.AllMetrics()[0]
+	names := make(map[string]int)
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		names[metrics.At(i).Name()]++
+	}
+	assert.Equal(t, 1, names["forecast"])
+	assert.Equal(t, 1, names["bound"])
+	assert.Equal(t, map[string]float64{"0": 0.25, "1": 0.45}, forecastValues(t, md))
+	bound := findMetricByName(md, "bound")
+	require.Equal(t, pmetric.MetricTypeGauge, bound.Type())
+	for i := 0; i < bound.Gauge().DataPoints().Len(); i++ {
+		assert.Equal(t, -1.0, bound.Gauge().DataPoints().At(i).DoubleValue())
+	}
+}
+
+func TestLastValueStoreResources(t *testing.T) {
+	store := newLastValueStore()
+	resources := map[string]pcommon.Resource{}
+	for host, value := range map[string]float64{"a": 1, "b": 2} {
+		resource := pcommon.NewResource()
+		resource.Attributes().PutStr("host.name", host)
+		resources[host] = resource
+
+		metrics := pmetric.NewMetricSlice()
+		metric := metrics.AppendEmpty()
+		metric.SetName("forecast")
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("cpu", "0")
+		dp.SetDoubleValue(value)
+		store.record(0, 0, resource, time.Hour, metrics, 0)
+	}
+
+	// Each resource falls back to its own last values, also after a restart
+	restored := newLastValueStore()
+	restored.restore(0, store.snapshot(0))
+	for _, s := range []*lastValueStore{store, restored} {
+		for host, expected := range map[string]float64{"a": 1, "b": 2} {
+			emitted := s.emit(0, 0, resources[host], time.Hour, pmetric.NewScopeMetrics())
+			require.Len(t, emitted, 1)
+			require.Equal(t, 1, emitted[0].Gauge().DataPoints().Len())
+			assert.Equal(t, expected, emitted[0].Gauge().DataPoints().At(0).DoubleValue())
+		}
+		assert.Empty(t, s.emit(0, 0, pcommon.NewResource(), time.Hour, pmetric.NewScopeMetrics()))
+	}
+
+	// Resources no longer produced are forgotten once their last values expire,
+	// although their fallbacks are never emitted
+	now := time.Now().Add(2 * time.Hour)
+	store.now = func() time.Time { return now }
+	metrics := pmetric.NewMetricSlice()
+	metric := metrics.AppendEmpty()
+	metric.SetName("forecast")
+	metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(3)
+	store.record(0, 0, resources["a"], time.Hour, metrics, 0)
+	assert.Len(t, store.entries[lastValueOutput{}], 1)
+}
+
+func TestValidateFallbackConfig(t *testing.T) {
+	assert.NoError(t, validateFallbackConfig(FallbackConfig{}))
+	assert.NoError(t, validateFallbackConfig(FallbackConfig{Policy: "constant", Value: 0}))
+	assert.NoError(t, validateFallbackConfig(FallbackConfig{Policy: "last_value", TTL: time.Minute}))
+	assert.ErrorContains(t, validateFallbackConfig(FallbackConfig{Policy: "zero"}), "invalid policy")
+	assert.ErrorContains(t, validateFallbackConfig(FallbackConfig{Policy: "last_value", TTL: -time.Second}), "ttl must not be negative")
+
+	fallback := newOutputFallback(FallbackConfig{Policy: "last_value"})
+	assert.Equal(t, defaultFallbackTTL, fallback.ttl)
+	assert.Equal(t, fallbackPolicySkip, newOutputFallback(FallbackConfig{}).policy)
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Sources of forwarded attributes
+const (
+	forwardSourceResource = "resource"
+	forwardSourceScope    = "scope"
+)
+
+// Ways forwarded attributes are sent to the model
+const (
+	forwardAsParameter = "parameter"
+	forwardAsTensor    = "tensor"
+)
+
+// forwardedAttribute is the internal form of a forwarded attribute, with defaults applied
+type forwardedAttribute struct {
+	key    string
+	source string
+	as     string
+	name   string
+}
+
+// newForwardedAttributes converts a rule's forwarded attribute configuration, applying defaults
+func newForwardedAttributes(cfgs []ForwardAttributeConfig) []forwardedAttribute {
+	forwarded := make([]forwardedAttribute, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		attr := forwardedAttribute{key: cfg.Key, source: cfg.Source, as: cfg.As, name: cfg.Name}
+		if attr.source == "" {
+			attr.source = forwardSourceResource
+		}
+		if attr.as == "" {
+			attr.as = forwardAsParameter
+		}
+		if attr.name == "" {
+			attr.name = attr.key
+		}
+		forwarded = append(forwarded, attr)
+	}
+	return forwarded
+}
+
+// validateForwardAttributes checks a rule's forwarded attributes. Parameter names
+// must not clash with other request parameters, and tensor names must not clash
+// with data or control inputs.
+func validateForwardAttributes(rule Rule) error {
+	taken := make(map[string]bool)
+	for _, input := range rule.Inputs {
+		taken[forwardAsTensor+"/"+input] = true
+	}
+	for _, name := range rule.Sequence.ControlInputs.names() {
+		taken[forwardAsTensor+"/"+name] = true
+	}
+	for name := range rule.Parameters {
+		taken[forwardAsParameter+"/"+name] = true
+	}
+	if rule.ExperimentID != "" {
+		taken[forwardAsParameter+"/"+paramExperimentID] = true
+	}
+	if rule.RunID != "" {
+		taken[forwardAsParameter+"/"+paramRunID] = true
+	}
+	if rule.Sequence.Enabled {
+		for _, name := range []string{paramSequenceID, paramSequenceStart, paramSequenceEnd} {
+			taken[forwardAsParameter+"/"+name] = true
+		}
+	}
+
+	for i, attr := range newForwardedAttributes(rule.ForwardAttributes) {
+		if attr.key == "" {
+			return fmt.Errorf("forwarded attribute %d: key must not be empty", i)
+		}
+		switch attr.source {
+		case forwardSourceResource, forwardSourceScope:
+		default:
+			return fmt.Errorf("forwarded attribute %q: invalid source %q (must be 'resource' or 'scope')", attr.key, attr.source)
+		}
+		switch attr.as {
+		case forwardAsParameter, forwardAsTensor:
+		default:
+			return fmt.Errorf("forwarded attribute %q: invalid as %q (must be 'parameter' or 'tensor')", attr.key, attr.as)
+		}
+		if taken[attr.as+"/"+attr.name] {
+			return fmt.Errorf("forwarded attribute %q: %s name %q is already used", attr.key, attr.as, attr.name)
+		}
+		taken[attr.as+"/"+attr.name] = true
+	}
+	return nil
+}
+
+// errNoForwardContext is returned when a rule forwards attributes but its inputs
+// have no resource or scope to read them from
+var errNoForwardContext = errors.New("no resource or scope context to forward attributes from")
+
+// applyForwardedAttributes adds the resource and scope attributes a rule forwards
+// to its request, read from the resource and scope of the rule's inputs and hashed
+// or redacted by the rule's attribute privacy. Parameters are omitted when the
+// attribute is missing; tensors have one element per matched group, all with the
+// same value, and an empty value when the attribute is missing.
+func (mp *metricsinferenceprocessor) applyForwardedAttributes(ruleIdx int, request *pb.ModelInferRequest, context *modelContext) error {
+	forwarded := mp.rules[ruleIdx].forwardAttributes
+	privacy := mp.rules[ruleIdx].privacy
+	if len(forwarded) == 0 {
+		return nil
+	}
+	if !context.hasContext {
+		return errNoForwardContext
+	}
+
+	rows := len(context.matchedDataPoints)
+	if rows == 0 {
+		rows = 1
+	}
+	for _, attr := range forwarded {
+		attrs := context.resourceMetrics.Resource().Attributes()
+		if attr.source == forwardSourceScope {
+			attrs = context.scopeMetrics.Scope().Attributes()
+		}
+		value, ok := attrs.Get(attr.key)
+		sent := ""
+		if ok {
+			sent = privacy.value(attr.key, value, attr.source == forwardSourceResource)
+		}
+
+		if attr.as == forwardAsTensor {
+			request.Inputs = append(request.Inputs, attributeTensor(attr.name, sent, ok, rows))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if request.Parameters == nil {
+			request.Parameters = make(map[string]*pb.InferParameter)
+		}
+		request.Parameters[attr.name] = &pb.InferParameter{
+			ParameterChoice: &pb.InferParameter_StringParam{StringParam: sent},
+		}
+	}
+	return nil
+}
+
+// attributeTensor builds a BYTES tensor repeating an attribute value once per row
+func attributeTensor(name string, value string, ok bool, rows int) *pb.ModelInferRequest_InferInputTensor {
+	var element []byte
+	if ok {
+		element = []byte(value)
+	}
+	contents := make([][]byte, rows)
+	for i := range contents {
+		contents[i] = element
+	}
+	return &pb.ModelInferRequest_InferInputTensor{
+		Name:     name,
+		Datatype: "BYTES",
+		Shape:    []int64{int64(rows)},
+		Contents: &pb.InferTensorContents{BytesContents: contents},
+	}
+}
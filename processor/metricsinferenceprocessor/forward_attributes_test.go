@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestForwardAttributes(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("baseline", testutil.CreateMockResponseForCalculation("baseline", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "baseline",
+				Inputs:    []string{"cpu.utilization"},
+				Outputs:   []OutputSpec{{Name: "deviation"}},
+				ForwardAttributes: []ForwardAttributeConfig{
+					{Key: "host.name"},
+					{Key: "k8s.pod.name", As: forwardAsTensor, Name: "pod"},
+					{Key: "cloud.region"},
+					{Key: "team", Source: forwardSourceScope, As: forwardAsTensor},
+				},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{{
+		MetricName: "cpu.utilization",
+		DataPoints: []testutil.TestDataPoint{
+			{Value: 0.5, Attributes: map[string]string{"cpu": "0"}},
+			{Value: 0.7, Attributes: map[string]string{"cpu": "1"}},
+		},
+	}})
+	rm := md.ResourceMetrics().At(0)
+	rm.Resource().Attributes().PutStr("host.name", "node-1")
+	rm.Resource().Attributes().PutStr("k8s.pod.name", "api-7f9")
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	request := requests[0]
+
+	require.Contains(t, request.Parameters, "host.name")
+	assert.Equal(t, "node-1", request.Parameters["host.name"].GetStringParam())
+	assert.NotContains(t, request.Parameters, "cloud.region", "missing attributes are not sent as parameters")
+
+	require.Len(t, request.Inputs, 3)
+	pod := request.Inputs[1]
+	assert.Equal(t, "pod", pod.Name)
+	assert.Equal(t, "BYTES", pod.Datatype)
+	assert.Equal(t, []int64{2}, pod.Shape)
+	assert.Equal(t, [][]byte{[]byte("api-7f9"), []byte("api-7f9")}, pod.Contents.BytesContents)
+	team := request.Inputs[2]
+	assert.Equal(t, "team", team.Name)
+	assert.Equal(t, [][]byte{{}, {}}, team.Contents.BytesContents)
+}
+
+func TestValidateForwardAttributes(t *testing.T) {
+	rule := Rule{ModelName: "m", Inputs: []string{"x"}, Parameters: map[string]interface{}{"mode": "fast"}}
+
+	rule.ForwardAttributes = []ForwardAttributeConfig{{Key: "host.name"}, {Key: "host.name", As: forwardAsTensor}}
+	assert.NoError(t, validateForwardAttributes(rule))
+
+	tests := []struct {
+		name    string
+		forward []ForwardAttributeConfig
+		wantErr string
+	}{
+		{name: "empty key", forward: []ForwardAttributeConfig{{}}, wantErr: "key must not be empty"},
+		{name: "bad source", forward: []ForwardAttributeConfig{{Key: "a", Source: "span"}}, wantErr: "invalid source"},
+		{name: "bad as", forward: []ForwardAttributeConfig{{Key: "a", As: "label"}}, wantErr: "invalid as"},
+		{name: "parameter clash", forward: []ForwardAttributeConfig{{Key: "a", Name: "mode"}}, wantErr: "parameter name \"mode\" is already used"},
+		{name: "input clash", forward: []ForwardAttributeConfig{{Key: "x", As: forwardAsTensor}}, wantErr: "tensor name \"x\" is already used"},
+		{name: "duplicate", forward: []ForwardAttributeConfig{{Key: "a"}, {Key: "b", Name: "a"}}, wantErr: "already used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule.ForwardAttributes = tt.forward
+			assert.ErrorContains(t, validateForwardAttributes(rule), tt.wantErr)
+		})
+	}
+}
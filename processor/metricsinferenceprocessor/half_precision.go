@@ -0,0 +1,148 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Half-precision tensor datatypes, which have no typed contents and are sent
+// and received as raw contents
+const (
+	dataTypeFP16 = "FP16"
+	dataTypeBF16 = "BF16"
+)
+
+// isHalfPrecision reports whether a tensor datatype is FP16 or BF16
+func isHalfPrecision(datatype string) bool {
+	return datatype == dataTypeFP16 || datatype == dataTypeBF16
+}
+
+// float32ToFP16 converts a float32 to IEEE 754 half precision, rounding to the
+// nearest even value. Values beyond the half-precision range become infinities.
+func float32ToFP16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff > 0x7f800000: // NaN
+		return sign | 0x7e00
+	case exp >= 0x1f: // Infinity or overflow
+		return sign | 0x7c00
+	case exp <= 0: // Subnormal or underflow
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint16(mant >> shift)
+		rem := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rem > mid || (rem == mid && half&1 == 1) {
+			half++
+		}
+		return sign | half
+	}
+
+	half := sign | uint16(exp)<<10 | uint16(mant>>13)
+	// A carry out of the mantissa correctly rounds up into the exponent
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++
+	}
+	return half
+}
+
+// fp16ToFloat32 converts an IEEE 754 half-precision value to a float32, which
+// represents every half-precision value exactly
+func fp16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0x1f: // Infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0: // Subnormal, mant * 2^-24
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// float32ToBF16 converts a float32 to bfloat16, its upper 16 bits, rounding to
+// the nearest even value
+func float32ToBF16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	if bits&0x7fffffff > 0x7f800000 {
+		// Keep NaNs quiet, as truncation could clear every mantissa bit
+		return uint16(bits>>16) | 0x40
+	}
+	bits += 0x7fff + (bits>>16)&1
+	return uint16(bits >> 16)
+}
+
+// bf16ToFloat32 converts a bfloat16 value to a float32
+func bf16ToFloat32(b uint16) float32 {
+	return math.Float32frombits(uint32(b) << 16)
+}
+
+// packHalfPrecisionInputs converts the request tensors a model takes in FP16 or
+// BF16, per its metadata, to that datatype. Tensors are matched to the model's
+// inputs by name, then the rule's inputs by position. Raw contents must be used
+// for all inputs of a request or none, so every input is then sent as raw
+// contents, which requires the server's binary tensor data extension.
+func (mp *metricsinferenceprocessor) packHalfPrecisionInputs(ruleIdx int, request *pb.ModelInferRequest) error {
+	rule := mp.rules[ruleIdx]
+	metadata, exists := mp.modelMetadata[rule.modelName]
+	if !exists || rule.backend != nil {
+		return nil
+	}
+
+	byName := make(map[string]string, len(metadata.inputs))
+	half := false
+	for _, input := range metadata.inputs {
+		byName[input.Name] = input.Datatype
+		half = half || isHalfPrecision(input.Datatype)
+	}
+	if !half {
+		return nil
+	}
+	if !mp.supportsExtension(extensionBinaryTensorData) {
+		return fmt.Errorf("model takes half-precision inputs, but the inference server does not support the %s extension", extensionBinaryTensorData)
+	}
+
+	positional := !mp.combinesInputs(rule)
+	for i, tensor := range request.Inputs {
+		datatype, exists := byName[tensor.Name]
+		if !exists && positional && i < len(rule.inputs) && i < len(metadata.inputs) {
+			datatype = metadata.inputs[i].Datatype
+		}
+		if isHalfPrecision(datatype) {
+			tensor.Datatype = datatype
+		}
+	}
+
+	raw := make([][]byte, 0, len(request.Inputs))
+	for _, tensor := range request.Inputs {
+		contents, err := encodeRawContents(tensor.Datatype, tensor.Contents)
+		if err != nil {
+			return fmt.Errorf("failed to encode input %q as raw contents: %w", tensor.Name, err)
+		}
+		raw = append(raw, contents)
+		tensor.Contents = nil
+	}
+	request.RawInputContents = raw
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestFP16Conversion(t *testing.T) {
+	tests := []struct {
+		value float32
+		half  uint16
+	}{
+		{value: 0, half: 0x0000},
+		{value: float32(math.Copysign(0, -1)), half: 0x8000},
+		{value: 1, half: 0x3c00},
+		{value: -2, half: 0xc000},
+		{value: 0.5, half: 0x3800},
+		{value: 65504, half: 0x7bff},                       // Largest finite value
+		{value: float32(math.Ldexp(1, -14)), half: 0x0400}, // Smallest normal value
+		{value: float32(math.Ldexp(1, -24)), half: 0x0001}, // Smallest subnormal value
+		{value: float32(math.Inf(1)), half: 0x7c00},
+		{value: float32(math.Inf(-1)), half: 0xfc00},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.half, float32ToFP16(tt.value), "value %v", tt.value)
+		assert.Equal(t, tt.value, fp16ToFloat32(tt.half), "half %#04x", tt.half)
+	}
+
+	// Rounding to the nearest even value, overflow and underflow
+	assert.Equal(t, uint16(0x3c00), float32ToFP16(1+float32(math.Ldexp(1, -11))))
+	assert.Equal(t, uint16(0x3c01), float32ToFP16(1+float32(math.Ldexp(1, -10))))
+	assert.Equal(t, uint16(0x7c00), float32ToFP16(70000))
+	assert.Equal(t, uint16(0x0000), float32ToFP16(1e-9))
+	assert.True(t, math.IsNaN(float64(fp16ToFloat32(float32ToFP16(float32(math.NaN()))))))
+}
+
+func TestBF16Conversion(t *testing.T) {
+	assert.Equal(t, uint16(0x3f80), float32ToBF16(1))
+	assert.Equal(t, uint16(0xc000), float32ToBF16(-2))
+	assert.Equal(t, float32(1), bf16ToFloat32(0x3f80))
+	assert.Equal(t, float32(3.140625), bf16ToFloat32(float32ToBF16(3.14159)))
+	assert.True(t, math.IsNaN(float64(bf16ToFloat32(float32ToBF16(float32(math.NaN()))))))
+}
+
+func TestRawContentsRoundTrip(t *testing.T) {
+	tests := []struct {
+		datatype string
+		contents *pb.InferTensorContents
+	}{
+		{datatype: "BOOL", contents: &pb.InferTensorContents{BoolContents: []bool{true, false}}},
+		{datatype: "INT8", contents: &pb.InferTensorContents{IntContents: []int32{-3, 7}}},
+		{datatype: "INT16", contents: &pb.InferTensorContents{IntContents: []int32{-300, 700}}},
+		{datatype: "INT32", contents: &pb.InferTensorContents{IntContents: []int32{-3, 70000}}},
+		{datatype: "INT64", contents: &pb.InferTensorContents{Int64Contents: []int64{-3, 1 << 40}}},
+		{datatype: "UINT16", contents: &pb.InferTensorContents{UintContents: []uint32{3, 60000}}},
+		{datatype: "UINT64", contents: &pb.InferTensorContents{Uint64Contents: []uint64{3, 1 << 63}}},
+		{datatype: "FP16", contents: &pb.InferTensorContents{Fp32Contents: []float32{0.5, -1.25}}},
+		{datatype: "BF16", contents: &pb.InferTensorContents{Fp32Contents: []float32{0.5, -1.25}}},
+		{datatype: "FP32", contents: &pb.InferTensorContents{Fp32Contents: []float32{0.1, -1.25}}},
+		{datatype: "FP64", contents: &pb.InferTensorContents{Fp64Contents: []float64{0.1, -1.25}}},
+		{datatype: "BYTES", contents: &pb.InferTensorContents{BytesContents: [][]byte{[]byte("web-1"), {}}}},
+	}
+	for _, tt := range tests {
+		raw, err := encodeRawContents(tt.datatype, tt.contents)
+		require.NoError(t, err, tt.datatype)
+		decoded, err := decodeRawContents(tt.datatype, raw)
+		require.NoError(t, err, tt.datatype)
+		assert.Equal(t, tt.contents.String(), decoded.String(), tt.datatype)
+	}
+
+	_, err := decodeRawContents("FP16", []byte{1, 2, 3})
+	assert.ErrorContains(t, err, "not a whole number")
+	_, err = decodeRawContents("BYTES", []byte{9, 0, 0, 0, 'a'})
+	assert.ErrorContains(t, err, "exceeds")
+	_, err = encodeRawContents("COMPLEX", &pb.InferTensorContents{})
+	assert.ErrorContains(t, err, "unsupported datatype")
+}
+
+func TestHalfPrecisionModel(t *testing.T) {
+	rawScore := binary.LittleEndian.AppendUint16(nil, float32ToFP16(0.75))
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", &pb.ModelMetadataResponse{
+			Name:     "scorer",
+			Versions: []string{"1"},
+			Inputs:   []*pb.ModelMetadataResponse_TensorMetadata{{Name: "cpu", Datatype: "FP16", Shape: []int64{-1}}},
+			Outputs:  []*pb.ModelMetadataResponse_TensorMetadata{{Name: "score", Datatype: "FP16", Shape: []int64{1}}},
+		}),
+		testutil.WithModelResponse("scorer", &pb.ModelInferResponse{
+			ModelName:         "scorer",
+			Outputs:           []*pb.ModelInferResponse_InferOutputTensor{{Name: "score", Datatype: "FP16", Shape: []int64{1}}},
+			RawOutputContents: [][]byte{rawScore},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "cpu.{output}",
+		}},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu"},
+		MetricValues: [][]float64{{0.5}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// The input is packed as FP16 raw contents
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "FP16", requests[0].Inputs[0].Datatype)
+	assert.Nil(t, requests[0].Inputs[0].Contents)
+	assert.Equal(t, [][]byte{{0x00, 0x38}}, requests[0].RawInputContents)
+
+	// The FP16 output is decoded from raw contents
+	metric := findMetricByName(sink.AllMetrics()[0], "cpu.score")
+	require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+	assert.Equal(t, 0.75, metric.Gauge().DataPoints().At(0).DoubleValue())
+}
@@ -0,0 +1,179 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// histogramOutput is the internal form of an output's histogram configuration
+type histogramOutput struct {
+	bounds       []float64 // Explicit bucket bounds, nil when read from boundsTensor
+	boundsTensor string    // Output tensor holding the bucket bounds, empty when configured
+}
+
+// newHistogramOutput converts a histogram configuration. It returns nil when the
+// output is not assembled into a histogram.
+func newHistogramOutput(cfg *HistogramOutputConfig) *histogramOutput {
+	if cfg == nil {
+		return nil
+	}
+	return &histogramOutput{bounds: cfg.Bounds, boundsTensor: cfg.BoundsTensor}
+}
+
+// validateHistogramOutput checks an output's histogram configuration. A
+// histogram data point stands for a whole attribute group, so the settings
+// decoding or post-processing the values of single data points do not apply.
+func validateHistogramOutput(output OutputSpec) error {
+	cfg := output.Histogram
+	if cfg == nil {
+		return nil
+	}
+	switch {
+	case len(cfg.Bounds) == 0 && cfg.BoundsTensor == "":
+		return errors.New("bounds or bounds_tensor is required")
+	case len(cfg.Bounds) > 0 && cfg.BoundsTensor != "":
+		return errors.New("bounds and bounds_tensor are mutually exclusive")
+	}
+	if err := checkBucketBounds(cfg.Bounds); err != nil {
+		return err
+	}
+
+	switch {
+	case output.DataType == "string":
+		return errors.New("histogram outputs must be numeric")
+	case output.Temporality == temporalityCumulative:
+		return errors.New("histogram outputs do not support cumulative temporality")
+	case output.Fallback.Policy != "" && output.Fallback.Policy != fallbackPolicySkip:
+		return errors.New("histogram outputs do not support fallback")
+	case output.SamplingHint != nil:
+		return errors.New("histogram outputs do not support sampling_hint")
+	case output.AttachToInput:
+		return errors.New("histogram outputs do not support attach_to_input")
+	case output.Columns != "" || len(output.ColumnNames) > 0 || len(output.ElementNames) > 0:
+		return errors.New("histogram outputs take their buckets from the tensor's columns, columns, column_names and element_names do not apply")
+	}
+	return nil
+}
+
+// checkBucketBounds checks that bucket bounds are finite and strictly increasing
+func checkBucketBounds(bounds []float64) error {
+	for i, bound := range bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("bucket bound %d is not finite", i)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("bucket bounds must be strictly increasing, %v follows %v", bound, bounds[i-1])
+		}
+	}
+	return nil
+}
+
+// tensorFloatValues flattens the numeric contents of a tensor, whatever its
+// datatype, in row-major order
+func tensorFloatValues(tensor *pb.ModelInferResponse_InferOutputTensor) []float64 {
+	contents := tensor.Contents
+	if contents == nil {
+		return nil
+	}
+	values := make([]float64, 0, len(contents.Fp64Contents)+len(contents.Fp32Contents)+len(contents.Int64Contents)+len(contents.IntContents))
+	values = append(values, contents.Fp64Contents...)
+	for _, val := range contents.Fp32Contents {
+		values = append(values, float64(val))
+	}
+	for _, val := range contents.Int64Contents {
+		values = append(values, float64(val))
+	}
+	for _, val := range contents.IntContents {
+		values = append(values, float64(val))
+	}
+	return values
+}
+
+// processHistogramOutput assembles an output tensor of bucket counts into a
+// histogram metric. A [rows, buckets] tensor has a row per matched attribute
+// group, a flat tensor is a single row; with explicit bounds, a flat tensor
+// holding several rows of buckets is split by the number of buckets. Bounds read
+// from a tensor are shared by every row, or given per row as a [rows, bounds]
+// tensor. Counts go through the output's post transforms, such as a scale turning
+// predicted probabilities into counts, and are rounded to whole numbers.
+func (mp *metricsinferenceprocessor) processHistogramOutput(metric pmetric.Metric, outputTensor *pb.ModelInferResponse_InferOutputTensor, response *pb.ModelInferResponse, outputSpec internalOutputSpec, context *modelContext) error {
+	counts := tensorFloatValues(outputTensor)
+	if len(counts) == 0 {
+		return errors.New("histogram output tensor has no bucket counts")
+	}
+
+	bounds := outputSpec.histogram.bounds
+	buckets := len(bounds) + 1
+	if bounds == nil {
+		buckets = len(counts)
+		if _, cols, shaped := tensorMatrixShape(outputTensor.Shape); shaped {
+			buckets = cols
+		}
+	}
+	if len(counts)%buckets != 0 {
+		return fmt.Errorf("histogram output tensor has %d values, not a multiple of %d buckets", len(counts), buckets)
+	}
+	rows := len(counts) / buckets
+	if context != nil && len(context.matchedDataPoints) > 0 && rows != len(context.matchedDataPoints) {
+		return fmt.Errorf("histogram output tensor has %d rows but there are %d matched data point groups", rows, len(context.matchedDataPoints))
+	}
+
+	// Bounds predicted along with the counts are shared or given per row
+	perRow := false
+	if bounds == nil {
+		boundsTensor := findOutputTensor(response.Outputs, outputSpec.histogram.boundsTensor)
+		if boundsTensor == nil {
+			return fmt.Errorf("bounds tensor %q not found in response", outputSpec.histogram.boundsTensor)
+		}
+		bounds = tensorFloatValues(boundsTensor)
+		switch len(bounds) {
+		case buckets - 1:
+		case rows * (buckets - 1):
+			perRow = rows > 1
+		default:
+			return fmt.Errorf("bounds tensor %q has %d values, expected %d for %d buckets", outputSpec.histogram.boundsTensor, len(bounds), buckets-1, buckets)
+		}
+	}
+
+	histogram := metric.SetEmptyHistogram()
+	histogram.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	timestamp := pcommon.NewTimestampFromTime(time.Now())
+	for r := 0; r < rows; r++ {
+		rowBounds := bounds
+		if perRow {
+			rowBounds = bounds[r*(buckets-1) : (r+1)*(buckets-1)]
+		}
+		if err := checkBucketBounds(rowBounds); err != nil {
+			return err
+		}
+
+		bucketCounts := make([]uint64, buckets)
+		var total uint64
+		for b, value := range counts[r*buckets : (r+1)*buckets] {
+			value = math.Round(applyPostTransforms(value, outputSpec.post))
+			if math.IsNaN(value) || value < 0 || value > math.MaxInt64 {
+				return fmt.Errorf("bucket %d of row %d has count %v, not a non-negative number", b, r, value)
+			}
+			bucketCounts[b] = uint64(value)
+			total += bucketCounts[b]
+		}
+
+		dp := histogram.DataPoints().AppendEmpty()
+		dp.SetTimestamp(timestamp)
+		dp.ExplicitBounds().FromRaw(rowBounds)
+		dp.BucketCounts().FromRaw(bucketCounts)
+		dp.SetCount(total)
+		copyGroupAttributes(dp.Attributes(), context, r)
+	}
+	return nil
+}
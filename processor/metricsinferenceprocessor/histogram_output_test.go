@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestValidateHistogramOutput(t *testing.T) {
+	bounds := &HistogramOutputConfig{Bounds: []float64{10, 50, 100}}
+	assert.NoError(t, validateHistogramOutput(OutputSpec{}))
+	assert.NoError(t, validateHistogramOutput(OutputSpec{Histogram: bounds}))
+	assert.NoError(t, validateHistogramOutput(OutputSpec{Histogram: &HistogramOutputConfig{BoundsTensor: "bounds"}}))
+
+	tests := []struct {
+		name   string
+		output OutputSpec
+		errMsg string
+	}{
+		{"no bounds", OutputSpec{Histogram: &HistogramOutputConfig{}}, "bounds or bounds_tensor is required"},
+		{"both bounds", OutputSpec{Histogram: &HistogramOutputConfig{Bounds: []float64{1}, BoundsTensor: "bounds"}}, "mutually exclusive"},
+		{"decreasing bounds", OutputSpec{Histogram: &HistogramOutputConfig{Bounds: []float64{10, 5}}}, "strictly increasing"},
+		{"infinite bound", OutputSpec{Histogram: &HistogramOutputConfig{Bounds: []float64{math.Inf(1)}}}, "not finite"},
+		{"string", OutputSpec{Histogram: bounds, DataType: "string"}, "must be numeric"},
+		{"cumulative", OutputSpec{Histogram: bounds, Temporality: temporalityCumulative}, "cumulative"},
+		{"fallback", OutputSpec{Histogram: bounds, Fallback: FallbackConfig{Policy: fallbackPolicyLastValue}}, "fallback"},
+		{"sampling hint", OutputSpec{Histogram: bounds, SamplingHint: &SamplingHintConfig{Threshold: 1}}, "sampling_hint"},
+		{"attach", OutputSpec{Histogram: bounds, AttachToInput: true}, "attach_to_input"},
+		{"columns", OutputSpec{Histogram: bounds, ColumnNames: []string{"a", "b"}}, "do not apply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateHistogramOutput(tt.output), tt.errMsg)
+		})
+	}
+}
+
+func TestProcessHistogramOutput(t *testing.T) {
+	mp := &metricsinferenceprocessor{}
+	countsTensor := func(shape []int64, counts ...float32) *pb.ModelInferResponse_InferOutputTensor {
+		return &pb.ModelInferResponse_InferOutputTensor{
+			Name:     "latency",
+			Datatype: "FP32",
+			Shape:    shape,
+			Contents: &pb.InferTensorContents{Fp32Contents: counts},
+		}
+	}
+
+	// Explicit bounds split a flat tensor into rows, and counts are rounded
+	metric := pmetric.NewMetric()
+	spec := internalOutputSpec{histogram: &histogramOutput{bounds: []float64{10, 100}}}
+	err := mp.processHistogramOutput(metric, countsTensor([]int64{6}, 1, 2.4, 3, 0, 5.6, 1), &pb.ModelInferResponse{}, spec, nil)
+	require.NoError(t, err)
+	require.Equal(t, pmetric.MetricTypeHistogram, metric.Type())
+	assert.Equal(t, pmetric.AggregationTemporalityDelta, metric.Histogram().AggregationTemporality())
+	dps := metric.Histogram().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	assert.Equal(t, []float64{10, 100}, dps.At(0).ExplicitBounds().AsRaw())
+	assert.Equal(t, []uint64{1, 2, 3}, dps.At(0).BucketCounts().AsRaw())
+	assert.Equal(t, uint64(6), dps.At(0).Count())
+	assert.Equal(t, []uint64{0, 6, 1}, dps.At(1).BucketCounts().AsRaw())
+	assert.Equal(t, uint64(7), dps.At(1).Count())
+
+	// Post transforms turn predicted probabilities into counts
+	spec.post, err = parsePostTransforms([]string{"scale(100)"})
+	require.NoError(t, err)
+	metric = pmetric.NewMetric()
+	require.NoError(t, mp.processHistogramOutput(metric, countsTensor([]int64{3}, 0.25, 0.5, 0.25), &pb.ModelInferResponse{}, spec, nil))
+	assert.Equal(t, []uint64{25, 50, 25}, metric.Histogram().DataPoints().At(0).BucketCounts().AsRaw())
+
+	// Bounds read from a second tensor, shared by every row or given per row
+	spec = internalOutputSpec{histogram: &histogramOutput{boundsTensor: "bounds"}}
+	boundsResponse := func(bounds ...float64) *pb.ModelInferResponse {
+		return &pb.ModelInferResponse{Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+			Name:     "bounds",
+			Datatype: "FP64",
+			Contents: &pb.InferTensorContents{Fp64Contents: bounds},
+		}}}
+	}
+	metric = pmetric.NewMetric()
+	require.NoError(t, mp.processHistogramOutput(metric, countsTensor([]int64{2, 3}, 1, 2, 3, 4, 5, 6), boundsResponse(1, 2), spec, nil))
+	dps = metric.Histogram().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	assert.Equal(t, []float64{1, 2}, dps.At(1).ExplicitBounds().AsRaw())
+	assert.Equal(t, []uint64{4, 5, 6}, dps.At(1).BucketCounts().AsRaw())
+
+	metric = pmetric.NewMetric()
+	require.NoError(t, mp.processHistogramOutput(metric, countsTensor([]int64{2, 3}, 1, 2, 3, 4, 5, 6), boundsResponse(1, 2, 5, 10), spec, nil))
+	assert.Equal(t, []float64{5, 10}, metric.Histogram().DataPoints().At(1).ExplicitBounds().AsRaw())
+
+	// Invalid outputs
+	assert.ErrorContains(t, mp.processHistogramOutput(pmetric.NewMetric(), countsTensor([]int64{3}, 1, 2, 3), &pb.ModelInferResponse{}, spec, nil), "not found")
+	assert.ErrorContains(t, mp.processHistogramOutput(pmetric.NewMetric(), countsTensor([]int64{3}, 1, 2, 3), boundsResponse(1), spec, nil), "expected 2")
+	assert.ErrorContains(t, mp.processHistogramOutput(pmetric.NewMetric(), countsTensor([]int64{3}, 1, 2, 3), boundsResponse(2, 1), spec, nil), "strictly increasing")
+	spec = internalOutputSpec{histogram: &histogramOutput{bounds: []float64{10}}}
+	assert.ErrorContains(t, mp.processHistogramOutput(pmetric.NewMetric(), countsTensor([]int64{3}, 1, 2, 3), &pb.ModelInferResponse{}, spec, nil), "not a multiple of 2 buckets")
+	assert.ErrorContains(t, mp.processHistogramOutput(pmetric.NewMetric(), countsTensor([]int64{2}, 1, -2), &pb.ModelInferResponse{}, spec, nil), "not a non-negative number")
+}
+
+func TestHistogramOutput(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("latency_model", &pb.ModelInferResponse{
+			ModelName: "latency_model",
+			Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+				Name:     "distribution",
+				Datatype: "INT64",
+				Shape:    []int64{2, 3},
+				Contents: &pb.InferTensorContents{Int64Contents: []int64{5, 3, 1, 0, 2, 8}},
+			}},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "latency_model",
+			Inputs:        []string{"requests"},
+			OutputPattern: "latency.{output}",
+			Outputs: []OutputSpec{{
+				Name:      "predicted",
+				Unit:      "ms",
+				Histogram: &HistogramOutputConfig{Bounds: []float64{100, 500}},
+			}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "requests", DataPoints: []testutil.TestDataPoint{
+			{Value: 10, Attributes: map[string]string{"service.name": "checkout"}},
+			{Value: 20, Attributes: map[string]string{"service.name": "search"}},
+		}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	metric := findMetricByName(sink.AllMetrics()[0], "latency.predicted")
+	require.Equal(t, pmetric.MetricTypeHistogram, metric.Type())
+	assert.Equal(t, "ms", metric.Unit())
+	dps := metric.Histogram().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	counts := map[string][]uint64{}
+	for i := 0; i < dps.Len(); i++ {
+		service, ok := dps.At(i).Attributes().Get("requests.service.name")
+		require.True(t, ok)
+		assert.Equal(t, []float64{100, 500}, dps.At(i).ExplicitBounds().AsRaw())
+		counts[service.Str()] = dps.At(i).BucketCounts().AsRaw()
+	}
+	assert.ElementsMatch(t, [][]uint64{{5, 3, 1}, {0, 2, 8}}, [][]uint64{counts["checkout"], counts["search"]})
+
+	// Histogram outputs are not supported with a challenger
+	cfg.Rules[0].Challenger = &ChallengerConfig{ModelName: "latency_model_v2"}
+	assert.ErrorContains(t, cfg.Validate(), "not supported with a challenger")
+}
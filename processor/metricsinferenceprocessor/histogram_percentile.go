@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// percentileFuncPattern matches derived percentile inputs such as "p99(http.server.duration)"
+var percentileFuncPattern = regexp.MustCompile(`^p(\d+(?:\.\d+)?)\((.*)\)$`)
+
+// parsePercentileFunc extracts the percentile and inner selector from a derived
+// percentile input. ok is false when the input is not a percentile function.
+func parsePercentileFunc(input string) (quantile float64, inner string, ok bool, err error) {
+	matches := percentileFuncPattern.FindStringSubmatch(strings.TrimSpace(input))
+	if matches == nil {
+		return 0, "", false, nil
+	}
+
+	percentile, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil || percentile <= 0 || percentile >= 100 {
+		return 0, "", true, fmt.Errorf("invalid percentile %q: must be between 0 and 100 exclusive", matches[1])
+	}
+	return percentile / 100, matches[2], true, nil
+}
+
+// histogramPercentileMetric derives a gauge holding the estimated quantile of each
+// histogram data point. Attributes and timestamps are preserved so the derived
+// input participates in attribute matching and alignment like any other gauge.
+func histogramPercentileMetric(metric pmetric.Metric, quantile float64) (pmetric.Metric, error) {
+	if metric.Type() != pmetric.MetricTypeHistogram {
+		return pmetric.Metric{}, fmt.Errorf("percentile inputs require a histogram metric, %s is a %s", metric.Name(), metric.Type().String())
+	}
+
+	derived := pmetric.NewMetric()
+	derived.SetName(metric.Name())
+	derived.SetUnit(metric.Unit())
+	gauge := derived.SetEmptyGauge()
+
+	dps := metric.Histogram().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		hdp := dps.At(i)
+		value, ok := estimateHistogramQuantile(hdp, quantile)
+		if !ok {
+			continue // Empty histograms have no meaningful percentile
+		}
+
+		dp := gauge.DataPoints().AppendEmpty()
+		hdp.Attributes().CopyTo(dp.Attributes())
+		dp.SetStartTimestamp(hdp.StartTimestamp())
+		dp.SetTimestamp(hdp.Timestamp())
+		dp.SetDoubleValue(value)
+	}
+
+	return derived, nil
+}
+
+// estimateHistogramQuantile estimates a quantile from explicit bucket counts using
+// linear interpolation within the bucket containing the target rank. Recorded
+// min/max values bound the first and overflow buckets when available.
+func estimateHistogramQuantile(dp pmetric.HistogramDataPoint, quantile float64) (float64, bool) {
+	counts := dp.BucketCounts()
+	bounds := dp.ExplicitBounds()
+
+	var total uint64
+	for i := 0; i < counts.Len(); i++ {
+		total += counts.At(i)
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	// Without explicit bounds there is a single bucket spanning all values
+	if bounds.Len() == 0 {
+		if dp.HasMin() && dp.HasMax() {
+			return dp.Min() + (dp.Max()-dp.Min())*quantile, true
+		}
+		return dp.Sum() / float64(total), true
+	}
+
+	rank := quantile * float64(total)
+	var cumulative uint64
+	for i := 0; i < counts.Len(); i++ {
+		count := counts.At(i)
+		if count == 0 || float64(cumulative+count) < rank {
+			cumulative += count
+			continue
+		}
+
+		// Lower edge: previous bound, or the recorded minimum for the first bucket
+		var lower float64
+		switch {
+		case i > 0:
+			lower = bounds.At(i - 1)
+		case dp.HasMin():
+			lower = dp.Min()
+		default:
+			lower = math.Min(0, bounds.At(0))
+		}
+
+		// Upper edge: this bucket's bound, or the recorded maximum for the overflow bucket
+		var upper float64
+		switch {
+		case i < bounds.Len():
+			upper = bounds.At(i)
+		case dp.HasMax():
+			upper = dp.Max()
+		default:
+			return bounds.At(bounds.Len() - 1), true
+		}
+
+		// Recorded extremes tighten the bucket edges when they fall inside it
+		if dp.HasMin() {
+			lower = math.Max(lower, dp.Min())
+		}
+		if dp.HasMax() {
+			upper = math.Min(upper, dp.Max())
+		}
+
+		fraction := (rank - float64(cumulative)) / float64(count)
+		return lower + (upper-lower)*fraction, true
+	}
+
+	return bounds.At(bounds.Len() - 1), true
+}
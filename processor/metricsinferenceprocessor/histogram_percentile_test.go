@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// newTestHistogramDataPoint creates a histogram data point with the given bounds and counts
+func newTestHistogramDataPoint(bounds []float64, counts []uint64) pmetric.HistogramDataPoint {
+	dp := pmetric.NewHistogramDataPoint()
+	dp.ExplicitBounds().FromRaw(bounds)
+	dp.BucketCounts().FromRaw(counts)
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	dp.SetCount(total)
+	return dp
+}
+
+func TestEstimateHistogramQuantile(t *testing.T) {
+	tests := []struct {
+		name     string
+		dp       func() pmetric.HistogramDataPoint
+		quantile float64
+		expected float64
+		ok       bool
+	}{
+		{
+			name: "median interpolated within bucket",
+			dp: func() pmetric.HistogramDataPoint {
+				return newTestHistogramDataPoint([]float64{10, 20, 30}, []uint64{0, 10, 0, 0})
+			},
+			quantile: 0.5,
+			expected: 15,
+			ok:       true,
+		},
+		{
+			name: "p99 across buckets",
+			dp: func() pmetric.HistogramDataPoint {
+				return newTestHistogramDataPoint([]float64{100, 200, 400}, []uint64{50, 40, 10, 0})
+			},
+			quantile: 0.99,
+			expected: 380,
+			ok:       true,
+		},
+		{
+			name: "overflow bucket without max returns highest bound",
+			dp: func() pmetric.HistogramDataPoint {
+				return newTestHistogramDataPoint([]float64{1, 2}, []uint64{0, 0, 5})
+			},
+			quantile: 0.9,
+			expected: 2,
+			ok:       true,
+		},
+		{
+			name: "overflow bucket bounded by max",
+			dp: func() pmetric.HistogramDataPoint {
+				dp := newTestHistogramDataPoint([]float64{1, 2}, []uint64{0, 0, 4})
+				dp.SetMax(4)
+				return dp
+			},
+			quantile: 0.5,
+			expected: 3,
+			ok:       true,
+		},
+		{
+			name: "first bucket bounded by min",
+			dp: func() pmetric.HistogramDataPoint {
+				dp := newTestHistogramDataPoint([]float64{10}, []uint64{4, 0})
+				dp.SetMin(6)
+				return dp
+			},
+			quantile: 0.5,
+			expected: 8,
+			ok:       true,
+		},
+		{
+			name: "empty histogram",
+			dp: func() pmetric.HistogramDataPoint {
+				return newTestHistogramDataPoint([]float64{1}, []uint64{0, 0})
+			},
+			quantile: 0.5,
+			ok:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := estimateHistogramQuantile(tt.dp(), tt.quantile)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.InDelta(t, tt.expected, value, 1e-9)
+			}
+		})
+	}
+}
+
+func TestParsePercentileSelector(t *testing.T) {
+	selector, err := parseLabelSelector(`p99.9(http.server.duration{http.route="/api"})`)
+	require.NoError(t, err)
+	assert.Equal(t, "http.server.duration", selector.metricName)
+	assert.Equal(t, map[string]string{"http.route": "/api"}, selector.labels)
+	assert.InDelta(t, 0.999, selector.percentile, 1e-12)
+
+	selector, err = parseLabelSelector("http.server.duration")
+	require.NoError(t, err)
+	assert.Zero(t, selector.percentile)
+
+	_, err = parseLabelSelector("p100(http.server.duration)")
+	assert.Error(t, err)
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8081"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"p0(latency)"}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), `invalid input "p0(latency)" in rule 0`)
+}
+
+func TestPercentileInputSentToModel(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "latency_model",
+				Inputs:    []string{"p99(http.server.duration)"},
+				Outputs:   []OutputSpec{{Name: "latency_anomaly"}},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("http.server.duration")
+	hdp := metric.SetEmptyHistogram().DataPoints().AppendEmpty()
+	newTestHistogramDataPoint([]float64{100, 200, 400}, []uint64{50, 40, 10, 0}).CopyTo(hdp)
+	hdp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	assert.Equal(t, "p99(http.server.duration)", requests[0].Inputs[0].Name)
+	require.Len(t, requests[0].Inputs[0].Contents.Fp64Contents, 1)
+	assert.InDelta(t, 380.0, requests[0].Inputs[0].Contents.Fp64Contents[0], 1e-9)
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/grpc"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Inference backends
+const (
+	backendServer  = "server"
+	backendLocal   = "local"
+	backendBuiltin = "builtin"
+)
+
+// inProcess reports whether a rule's inference runs in-process rather than on
+// the inference server
+func (r Rule) inProcess() bool {
+	return r.Synthetic != nil || r.Backend == backendLocal || r.Backend == backendBuiltin || r.Aggregate != nil
+}
+
+// inProcess reports whether the rule runs without the inference server, on an
+// in-process backend or as an aggregation
+func (r *internalRule) inProcess() bool {
+	return r.backend != nil || r.aggregate != nil
+}
+
+// InferenceClient performs inference requests for a rule. The gRPC client of the
+// inference server satisfies it, as do the in-process synthetic and local backends.
+type InferenceClient interface {
+	ModelInfer(ctx context.Context, in *pb.ModelInferRequest, opts ...grpc.CallOption) (*pb.ModelInferResponse, error)
+}
+
+var (
+	_ InferenceClient = (pb.GRPCInferenceServiceClient)(nil)
+	_ InferenceClient = (*syntheticBackend)(nil)
+	_ InferenceClient = (*localBackend)(nil)
+)
+
+// seriesKeysContextKey is the context key for the series identities of a request
+type seriesKeysContextKey struct{}
+
+// withSeriesKeys attaches the identity of the series behind each element of a
+// request's input tensors, so in-process backends can keep per-series state.
+// Series are told apart by their resource's attributes and, for an expanded
+// rule, by the value inferred for.
+func withSeriesKeys(ctx context.Context, resource pcommon.Map, groups []dataPointGroup, expansion *ruleExpansion) context.Context {
+	if len(groups) == 0 {
+		return ctx
+	}
+	resourceHash := attributeSetHash(resource)
+	keys := make([]uint64, len(groups))
+	for i, group := range groups {
+		keys[i] = resourceHash*31 + attributeSetHash(group.attributes)
+		if expansion != nil {
+			keys[i] = keys[i]*31 + fnvAddString(fnvOffset64, expansion.value)
+		}
+	}
+	return context.WithValue(ctx, seriesKeysContextKey{}, keys)
+}
+
+// seriesKeysFromContext returns the series identities attached by withSeriesKeys
+func seriesKeysFromContext(ctx context.Context) []uint64 {
+	keys, _ := ctx.Value(seriesKeysContextKey{}).([]uint64)
+	return keys
+}
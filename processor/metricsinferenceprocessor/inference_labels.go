@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/collector/featuregate"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Semantic-convention labels identifying the model and rule behind a data point
+const (
+	labelMLModelName    = "ml.model.name"
+	labelMLModelVersion = "ml.model.version"
+	labelRuleID         = "otel.inference.rule_id"
+)
+
+// semconvLabelsGate switches the model labels of inferred metrics from the
+// processor's own otel.inference.model.* keys to the ML semantic conventions
+var semconvLabelsGate = featuregate.GlobalRegistry().MustRegister(
+	"processor.metricsinference.semconvLabels",
+	featuregate.StageAlpha,
+	featuregate.WithRegisterDescription("When enabled, inferred metrics are labeled with ml.model.name, ml.model.version and "+
+		"otel.inference.rule_id instead of otel.inference.model.name and otel.inference.model.version"))
+
+// modelLabelKeys returns the label keys of the model name and version
+func modelLabelKeys() (name, version string) {
+	if semconvLabelsGate.IsEnabled() {
+		return labelMLModelName, labelMLModelVersion
+	}
+	return labelInferenceModelName, labelInferenceModelVersion
+}
+
+// ruleID returns the identifier of a rule on the otel.inference.rule_id label:
+// its configured id, or its index
+func ruleID(rule Rule, ruleIdx int) string {
+	if rule.ID != "" {
+		return rule.ID
+	}
+	return strconv.Itoa(ruleIdx)
+}
+
+// validateRuleIDs checks that configured rule ids are unique
+func validateRuleIDs(rules []Rule) error {
+	seen := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		id := ruleID(rule, i)
+		if other, exists := seen[id]; exists {
+			return fmt.Errorf("rules %d and %d have the same id %q", other, i, id)
+		}
+		seen[id] = i
+	}
+	return nil
+}
+
+// putModelLabels adds the labels identifying the model behind a data point:
+// its name and, when configured, version, and with semantic-convention labels
+// the rule's id
+func putModelLabels(attrs pcommon.Map, rule *internalRule) {
+	nameKey, versionKey := modelLabelKeys()
+	attrs.PutStr(nameKey, rule.modelName)
+	if rule.modelVersion != "" {
+		attrs.PutStr(versionKey, rule.modelVersion)
+	}
+	if semconvLabelsGate.IsEnabled() {
+		attrs.PutStr(labelRuleID, rule.id)
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/featuregate"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/processor/processortest"
+	"go.uber.org/zap/zaptest"
 
 	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/metadata"
 	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
@@ -151,6 +153,180 @@ func TestAttributesPreservedWithModelLabels(t *testing.T) {
 	assert.False(t, hasStatus, "status label should not be present")
 }
 
+func TestRouteLabelOnOutputs(t *testing.T) {
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecaster",
+				Inputs:        []string{"test.metric"},
+				OutputPattern: "routed.{output}",
+				Synthetic:     &SyntheticConfig{Function: "constant", Offset: 1},
+				Route:         "predictions",
+			},
+			{
+				ModelName:     "detector",
+				Inputs:        []string{"test.metric"},
+				OutputPattern: "unrouted.{output}",
+				Synthetic:     &SyntheticConfig{Function: "constant", Offset: 2},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), createTestMetricsWithAttributes()))
+	require.Len(t, sink.AllMetrics(), 1)
+	md := sink.AllMetrics()[0]
+
+	routed := findMetricByName(md, "routed.prediction")
+	require.Equal(t, 1, routed.Gauge().DataPoints().Len())
+	route, ok := routed.Gauge().DataPoints().At(0).Attributes().Get("otel.route")
+	require.True(t, ok, "route label should be present on routed outputs")
+	assert.Equal(t, "predictions", route.Str())
+
+	unrouted := findMetricByName(md, "unrouted.prediction")
+	require.Equal(t, 1, unrouted.Gauge().DataPoints().Len())
+	_, ok = unrouted.Gauge().DataPoints().At(0).Attributes().Get("otel.route")
+	assert.False(t, ok, "route label should not be present without a route")
+
+	input := findMetricByName(md, "test.metric")
+	_, ok = input.Gauge().DataPoints().At(0).Attributes().Get("otel.route")
+	assert.False(t, ok, "input metrics should not be routed")
+}
+
+func TestExperimentTagging(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("ranker", testutil.CreateMockResponseForCalculation("ranker", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "ranker",
+				Inputs:        []string{"test.metric"},
+				OutputPattern: "tagged.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+				ExperimentID:  "exp-42",
+				RunID:         "run-7",
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), createTestMetricsWithAttributes()))
+
+	// The identifiers reach the inference server as request parameters
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "exp-42", requests[0].Parameters["experiment_id"].GetStringParam())
+	assert.Equal(t, "run-7", requests[0].Parameters["run_id"].GetStringParam())
+
+	// and are stamped on the outputs
+	require.Len(t, sink.AllMetrics(), 1)
+	output := findMetricByName(sink.AllMetrics()[0], "tagged.score")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	attrs := output.Gauge().DataPoints().At(0).Attributes()
+	experiment, ok := attrs.Get(labelExperimentID)
+	require.True(t, ok)
+	assert.Equal(t, "exp-42", experiment.Str())
+	run, ok := attrs.Get(labelRunID)
+	require.True(t, ok)
+	assert.Equal(t, "run-7", run.Str())
+
+	// Setting an identifier twice is ambiguous
+	cfg.Rules[0].Parameters = map[string]interface{}{"run_id": "run-8"}
+	assert.ErrorContains(t, cfg.Validate(), "run_id in rule 0 is set both")
+}
+
+func TestSemanticConventionLabels(t *testing.T) {
+	disabled := false
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecaster",
+				ModelVersion:  "3",
+				Inputs:        []string{"test.metric"},
+				OutputPattern: "labeled.{output}",
+				Synthetic:     &SyntheticConfig{Function: "constant", Offset: 1},
+				ID:            "cpu-forecast",
+			},
+			{
+				ModelName:       "detector",
+				Inputs:          []string{"test.metric"},
+				OutputPattern:   "unlabeled.{output}",
+				Synthetic:       &SyntheticConfig{Function: "constant", Offset: 2},
+				InferenceLabels: &disabled,
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	consume := func(t *testing.T) pmetric.Metrics {
+		sink := &consumertest.MetricsSink{}
+		processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+		require.NoError(t, err)
+		require.NoError(t, processor.Start(context.Background(), nil))
+		defer func() {
+			assert.NoError(t, processor.Shutdown(context.Background()))
+		}()
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), createTestMetricsWithAttributes()))
+		require.Len(t, sink.AllMetrics(), 1)
+		return sink.AllMetrics()[0]
+	}
+	labels := func(md pmetric.Metrics, name string) map[string]any {
+		metric := findMetricByName(md, name)
+		require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+		return metric.Gauge().DataPoints().At(0).Attributes().AsRaw()
+	}
+
+	md := consume(t)
+	attrs := labels(md, "labeled.prediction")
+	assert.Equal(t, "forecaster", attrs[labelInferenceModelName])
+	assert.Equal(t, "3", attrs[labelInferenceModelVersion])
+	assert.NotContains(t, attrs, labelRuleID)
+
+	// Behind the feature gate, the semantic-convention labels replace the custom ones
+	require.NoError(t, featuregate.GlobalRegistry().Set(semconvLabelsGate.ID(), true))
+	t.Cleanup(func() {
+		require.NoError(t, featuregate.GlobalRegistry().Set(semconvLabelsGate.ID(), false))
+	})
+	md = consume(t)
+	attrs = labels(md, "labeled.prediction")
+	assert.Equal(t, "forecaster", attrs[labelMLModelName])
+	assert.Equal(t, "3", attrs[labelMLModelVersion])
+	assert.Equal(t, "cpu-forecast", attrs[labelRuleID])
+	assert.NotContains(t, attrs, labelInferenceModelName)
+
+	// A rule can leave the labels out entirely
+	attrs = labels(md, "unlabeled.prediction")
+	for _, key := range []string{labelMLModelName, labelRuleID, labelInferenceModelName} {
+		assert.NotContains(t, attrs, key)
+	}
+}
+
+func TestValidateRuleIDs(t *testing.T) {
+	assert.NoError(t, validateRuleIDs([]Rule{{ID: "a"}, {ID: "b"}, {}}))
+	assert.ErrorContains(t, validateRuleIDs([]Rule{{ID: "a"}, {ID: "a"}}), `same id "a"`)
+	assert.ErrorContains(t, validateRuleIDs([]Rule{{}, {ID: "0"}}), `same id "0"`)
+}
+
 func createTestMetricsWithAttributes() pmetric.Metrics {
 	md := pmetric.NewMetrics()
 	rm := md.ResourceMetrics().AppendEmpty()
@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Input aggregations
+const (
+	aggregateSum = "sum"
+	aggregateAvg = "avg"
+	aggregateMax = "max"
+	aggregateP95 = "p95"
+)
+
+// validateAggregate checks an input's aggregation
+func validateAggregate(aggregate string) error {
+	switch aggregate {
+	case "", aggregateSum, aggregateAvg, aggregateMax, aggregateP95:
+		return nil
+	default:
+		return fmt.Errorf("invalid aggregate %q (must be 'sum', 'avg', 'max', or 'p95')", aggregate)
+	}
+}
+
+// aggregateMetric reduces the data points of a gauge or sum metric to a gauge with
+// a single data point without attributes. The data point spans from the earliest
+// start timestamp to the latest timestamp of the aggregated points. A metric
+// without data points yields a gauge without data points.
+func aggregateMetric(metric pmetric.Metric, aggregate string) (pmetric.Metric, error) {
+	dps, ok := numberDataPoints(metric)
+	if !ok {
+		return pmetric.Metric{}, fmt.Errorf("aggregate %s requires a gauge or sum metric, %s is a %s", aggregate, metric.Name(), metric.Type().String())
+	}
+
+	aggregated := pmetric.NewMetric()
+	aggregated.SetName(metric.Name())
+	aggregated.SetUnit(metric.Unit())
+	gauge := aggregated.SetEmptyGauge()
+	if dps.Len() == 0 {
+		return aggregated, nil
+	}
+
+	out := gauge.DataPoints().AppendEmpty()
+	values := make([]float64, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		values[i] = dataPointValue(dp)
+		if dp.StartTimestamp() != 0 && (out.StartTimestamp() == 0 || dp.StartTimestamp() < out.StartTimestamp()) {
+			out.SetStartTimestamp(dp.StartTimestamp())
+		}
+		if dp.Timestamp() > out.Timestamp() {
+			out.SetTimestamp(dp.Timestamp())
+		}
+	}
+	out.SetDoubleValue(aggregateValues(values, aggregate))
+	return aggregated, nil
+}
+
+// aggregateValues reduces values, which must not be empty, with an aggregation
+func aggregateValues(values []float64, aggregate string) float64 {
+	switch aggregate {
+	case aggregateAvg:
+		return sumValues(values) / float64(len(values))
+	case aggregateMax:
+		maximum := math.Inf(-1)
+		for _, value := range values {
+			maximum = math.Max(maximum, value)
+		}
+		return maximum
+	case aggregateP95:
+		return valuesQuantile(values, 0.95)
+	default:
+		return sumValues(values)
+	}
+}
+
+// sumValues returns the sum of values
+func sumValues(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum
+}
+
+// valuesQuantile returns a quantile of values, which must not be empty,
+// interpolating linearly between the two closest ranks
+func valuesQuantile(values []float64, quantile float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := quantile * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
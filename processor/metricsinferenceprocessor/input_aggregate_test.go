@@ -0,0 +1,145 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// perCoreGauge creates a CPU gauge with one data point per value, labelled by core
+func perCoreGauge(values ...float64) pmetric.Metric {
+	metric := pmetric.NewMetric()
+	metric.SetName("cpu.usage")
+	metric.SetUnit("1")
+	dps := metric.SetEmptyGauge().DataPoints()
+	for i, value := range values {
+		dp := dps.AppendEmpty()
+		dp.Attributes().PutStr("core", strconv.Itoa(i))
+		dp.SetTimestamp(pcommon.Timestamp(100 + i))
+		dp.SetDoubleValue(value)
+	}
+	return metric
+}
+
+func TestAggregateMetric(t *testing.T) {
+	values := []float64{0.2, 0.9, 0.4, 0.5}
+	tests := []struct {
+		aggregate string
+		expected  float64
+	}{
+		{aggregate: aggregateSum, expected: 2.0},
+		{aggregate: aggregateAvg, expected: 0.5},
+		{aggregate: aggregateMax, expected: 0.9},
+		{aggregate: aggregateP95, expected: 0.84}, // Between 0.5 and 0.9 at rank 2.85
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.aggregate, func(t *testing.T) {
+			aggregated, err := aggregateMetric(perCoreGauge(values...), tt.aggregate)
+			require.NoError(t, err)
+			assert.Equal(t, "cpu.usage", aggregated.Name())
+			assert.Equal(t, "1", aggregated.Unit())
+			require.Equal(t, 1, aggregated.Gauge().DataPoints().Len())
+			dp := aggregated.Gauge().DataPoints().At(0)
+			assert.InDelta(t, tt.expected, dp.DoubleValue(), 1e-9)
+			assert.Equal(t, 0, dp.Attributes().Len())
+			assert.Equal(t, pcommon.Timestamp(103), dp.Timestamp())
+		})
+	}
+
+	empty, err := aggregateMetric(perCoreGauge(), aggregateSum)
+	require.NoError(t, err)
+	assert.Equal(t, 0, empty.Gauge().DataPoints().Len())
+
+	histogram := pmetric.NewMetric()
+	histogram.SetEmptyHistogram()
+	_, err = aggregateMetric(histogram, aggregateSum)
+	assert.Error(t, err)
+}
+
+func TestInputTransformAggregateRate(t *testing.T) {
+	transform := newInputTransform(InputTransformConfig{As: inputAsRate, Aggregate: aggregateSum})
+	require.NotNil(t, transform)
+
+	_, _, err := transform.apply(newCounter(pmetric.AggregationTemporalityCumulative,
+		counterPoint{host: "a", start: 0, at: 10, value: 100},
+		counterPoint{host: "b", start: 0, at: 10, value: 50}), pcommon.NewMap())
+	require.NoError(t, err)
+
+	// Rates are computed per series and then summed
+	aggregated, _, err := transform.apply(newCounter(pmetric.AggregationTemporalityCumulative,
+		counterPoint{host: "a", start: 0, at: 20, value: 130},
+		counterPoint{host: "b", start: 0, at: 20, value: 70}), pcommon.NewMap())
+	require.NoError(t, err)
+	require.Equal(t, 1, aggregated.Gauge().DataPoints().Len())
+	assert.InDelta(t, 5.0, aggregated.Gauge().DataPoints().At(0).DoubleValue(), 1e-9)
+}
+
+func TestValidateAggregate(t *testing.T) {
+	rule := Rule{ModelName: "m", Inputs: []string{"x"}}
+	for _, aggregate := range []string{"sum", "avg", "max", "p95"} {
+		rule.Transforms = map[string]InputTransformConfig{"x": {Aggregate: aggregate}}
+		assert.NoError(t, validateInputTransforms(rule))
+	}
+	rule.Transforms = map[string]InputTransformConfig{"x": {Aggregate: "median"}}
+	assert.ErrorContains(t, validateInputTransforms(rule), `invalid aggregate "median"`)
+}
+
+func TestAggregatedInputRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("capacity", testutil.CreateMockResponseForCalculation("capacity", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "capacity",
+				Inputs:        []string{"cpu.usage"},
+				OutputPattern: "capacity.{output}",
+				Transforms:    map[string]InputTransformConfig{"cpu.usage": {Aggregate: aggregateSum}},
+				Outputs:       []OutputSpec{{Name: "forecast"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	perCoreGauge(0.25, 0.5, 0.75, 1).CopyTo(md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty())
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// The model receives the total rather than one value per core
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	assert.Equal(t, []int64{1}, requests[0].Inputs[0].Shape)
+	assert.Equal(t, []float64{2.5}, requests[0].Inputs[0].Contents.Fp64Contents)
+
+	// The input itself passes through unchanged
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 4, findMetricByName(output, "cpu.usage").Gauge().DataPoints().Len())
+	forecast := findMetricByName(output, "capacity.forecast")
+	require.Equal(t, 1, forecast.Gauge().DataPoints().Len())
+	_, hasCore := forecast.Gauge().DataPoints().At(0).Attributes().Get("cpu.usage.core")
+	assert.False(t, hasCore, "aggregated inputs carry no per-core attributes")
+}
@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"slices"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// mergeDiscoveredInputs sets the inputs of rules that omit them from their
+// model's input tensors. Every tensor is looked up as the metric named after it,
+// with the rule's input prefix, so self-describing models need no inputs in the
+// configuration. Rules whose model has no metadata keep no inputs, and do not run.
+func (mp *metricsinferenceprocessor) mergeDiscoveredInputs() {
+	for ruleIdx := range mp.rules {
+		rule := &mp.rules[ruleIdx]
+		if !rule.discoverInputs {
+			continue
+		}
+		metadata, hasMetadata := mp.modelMetadata[rule.modelName]
+		if !hasMetadata {
+			rule.setDiscoveredInputs(nil)
+			continue
+		}
+
+		rule.setDiscoveredInputs(metadata.inputs)
+		mp.logger.Info("Using discovered inputs for model",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Strings("inputs", rule.inputs))
+	}
+}
+
+// setDiscoveredInputs replaces the rule's inputs with the metrics looked up for
+// the given input tensors. Discovered names are matched exactly, never parsed as
+// label selectors. Sequence control tensors and forwarded attributes are sent by
+// the rule itself, so they are not looked up as metrics.
+func (r *internalRule) setDiscoveredInputs(tensors []*pb.ModelMetadataResponse_TensorMetadata) {
+	attached := r.attachedTensorNames()
+	r.inputs = make([]string, 0, len(tensors))
+	r.inputSelectors = make([]*labelSelector, 0, len(tensors))
+	r.inputTensors = nil
+	for _, tensor := range tensors {
+		if slices.Contains(attached, tensor.Name) {
+			continue
+		}
+		input := r.inputPrefix + tensor.Name
+		r.inputs = append(r.inputs, input)
+		r.inputSelectors = append(r.inputSelectors, &labelSelector{metricName: input})
+		if input != tensor.Name {
+			if r.inputTensors == nil {
+				r.inputTensors = make(map[string]string, len(tensors))
+			}
+			r.inputTensors[input] = tensor.Name
+		}
+	}
+}
+
+// attachedTensorNames returns the names of the tensors a rule sends besides its
+// inputs: its sequence control tensors and forwarded attributes
+func (r *internalRule) attachedTensorNames() []string {
+	names := r.controlInputs.names()
+	for _, attr := range r.forwardAttributes {
+		names = append(names, attr.name)
+	}
+	return names
+}
+
+// renameInputTensors gives the tensors of a request the names of the model's
+// inputs they were mapped to or discovered from
+func renameInputTensors(request *pb.ModelInferRequest, tensors map[string]string) {
+	if len(tensors) == 0 {
+		return
+	}
+	for _, input := range request.Inputs {
+		if name, ok := tensors[input.Name]; ok {
+			input.Name = name
+		}
+	}
+}
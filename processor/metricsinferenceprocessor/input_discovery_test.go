@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestDiscoveredInputs(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.7)),
+		testutil.WithModelMetadata("scorer", &pb.ModelMetadataResponse{
+			Name: "scorer",
+			Inputs: []*pb.ModelMetadataResponse_TensorMetadata{
+				{Name: "cpu.utilization", Datatype: "FP64", Shape: []int64{1}},
+				{Name: "memory.utilization", Datatype: "FP64", Shape: []int64{1}},
+			},
+			Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
+				{Name: "score", Datatype: "FP64", Shape: []int64{1}},
+			},
+		}))
+
+	// The model's name is all the rule needs
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			InputPrefix:   "system.",
+			OutputPattern: "system.{output}",
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"system.cpu.utilization", "system.memory.utilization"}, processor.rules[0].inputs)
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"system.cpu.utilization", "system.memory.utilization", "system.disk.io"},
+		MetricValues: [][]float64{{0.5}, {0.25}, {100}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Metrics are found under the prefix and sent under the model's tensor names
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	var tensors []string
+	for _, tensor := range requests[0].Inputs {
+		tensors = append(tensors, tensor.Name)
+	}
+	assert.ElementsMatch(t, []string{"cpu.utilization", "memory.utilization"}, tensors)
+
+	metric := findMetricByName(sink.AllMetrics()[0], "system.score")
+	require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+	assert.Equal(t, 0.7, metric.Gauge().DataPoints().At(0).DoubleValue())
+}
+
+func TestDiscoveredInputsWithoutMetadata(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.7)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules:              []Rule{{ModelName: "scorer"}},
+	}
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Without metadata to discover inputs from, the rule does not run
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Equal(t, 1, sink.AllMetrics()[0].MetricCount())
+}
+
+func TestDiscoveredInputsSkipAttachedTensors(t *testing.T) {
+	rule := internalRule{
+		discoverInputs:    true,
+		controlInputs:     ControlInputsConfig{Start: "START", CorrelationID: "CORRID"},
+		forwardAttributes: newForwardedAttributes([]ForwardAttributeConfig{{Key: "host.name", As: forwardAsTensor}}),
+	}
+	rule.setDiscoveredInputs([]*pb.ModelMetadataResponse_TensorMetadata{
+		{Name: "START", Datatype: "INT32", Shape: []int64{1}},
+		{Name: "cpu.utilization", Datatype: "FP64", Shape: []int64{1}},
+		{Name: "CORRID", Datatype: "UINT64", Shape: []int64{1}},
+		{Name: "host.name", Datatype: "BYTES", Shape: []int64{1}},
+	})
+	assert.Equal(t, []string{"cpu.utilization"}, rule.inputs)
+	require.Len(t, rule.inputSelectors, 1)
+	assert.Equal(t, "cpu.utilization", rule.inputSelectors[0].metricName)
+}
+
+func TestValidateDiscoveredInputs(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", InputPrefix: "system."}},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Rules[0].Inputs = []string{"cpu"}
+	assert.ErrorContains(t, cfg.Validate(), "input_prefix in rule 0 requires inputs to be omitted")
+
+	// In-process backends have no metadata to discover inputs from
+	cfg.Rules = []Rule{{ModelName: "smoother", Backend: backendLocal, Local: &LocalConfig{Function: "ewma", Alpha: 0.5}}}
+	assert.ErrorContains(t, cfg.Validate(), "missing required field \"inputs\"")
+}
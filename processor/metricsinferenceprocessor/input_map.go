@@ -0,0 +1,145 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// validateInputMap checks that a rule's input map names every model input and
+// metric selector once, and that it is not combined with other ways of
+// configuring the rule's inputs
+func validateInputMap(rule Rule) error {
+	if len(rule.InputMap) == 0 {
+		return nil
+	}
+	switch {
+	case len(rule.Inputs) > 0:
+		return errors.New("input_map and inputs are mutually exclusive")
+	case rule.InputPrefix != "":
+		return errors.New("input_map and input_prefix are mutually exclusive")
+	case rule.inProcess():
+		return errors.New("in-process backends have no model inputs to map")
+	case rule.CombineInputs != nil:
+		return errors.New("inputs combined into one tensor cannot be mapped to model inputs")
+	}
+
+	selectors := make(map[string]string, len(rule.InputMap))
+	for _, modelInput := range slices.Sorted(maps.Keys(rule.InputMap)) {
+		selector := rule.InputMap[modelInput]
+		if strings.TrimSpace(modelInput) == "" {
+			return errors.New("model input names must not be empty")
+		}
+		if strings.TrimSpace(selector) == "" {
+			return fmt.Errorf("model input %q has no metric selector", modelInput)
+		}
+		if previous, ok := selectors[selector]; ok {
+			return fmt.Errorf("metric selector %q is mapped to both %q and %q", selector, previous, modelInput)
+		}
+		selectors[selector] = modelInput
+	}
+	return nil
+}
+
+// configuredInputs returns the inputs of a rule: its metric selectors, from its
+// input map when it has one, without the synthetic __time_features__ input
+func (r Rule) configuredInputs() []string {
+	if len(r.InputMap) == 0 {
+		return withoutTimeFeatures(r.Inputs)
+	}
+	inputs, _ := mappedInputs(r.InputMap)
+	return withoutTimeFeatures(inputs)
+}
+
+// mappedInputs returns the metric selectors of an input map, ordered by the
+// model input they are mapped to so the order is stable, and the model input
+// names they are sent under
+func mappedInputs(inputMap map[string]string) ([]string, map[string]string) {
+	inputs := make([]string, 0, len(inputMap))
+	tensors := make(map[string]string, len(inputMap))
+	for _, modelInput := range slices.Sorted(maps.Keys(inputMap)) {
+		selector := inputMap[modelInput]
+		inputs = append(inputs, selector)
+		tensors[selector] = modelInput
+	}
+	return inputs, tensors
+}
+
+// checkInputMap reports the model inputs a rule's input map leaves unmapped and
+// the mapped names the model does not have. Sequence control tensors and
+// forwarded attributes are sent by the rule itself, so they need no mapping.
+func checkInputMap(rule internalRule, modelInputs []*pb.ModelMetadataResponse_TensorMetadata) error {
+	mapped := make(map[string]bool, len(rule.inputTensors))
+	for _, modelInput := range rule.inputTensors {
+		mapped[modelInput] = true
+	}
+	attached := rule.attachedTensorNames()
+
+	var unmapped []string
+	for _, input := range modelInputs {
+		if !mapped[input.Name] && !slices.Contains(attached, input.Name) {
+			unmapped = append(unmapped, input.Name)
+		}
+		delete(mapped, input.Name)
+	}
+
+	var errs []error
+	if len(unmapped) > 0 {
+		errs = append(errs, fmt.Errorf("model %s inputs %v are not mapped", rule.modelName, unmapped))
+	}
+	if len(mapped) > 0 {
+		unknown := slices.Sorted(maps.Keys(mapped))
+		errs = append(errs, fmt.Errorf("model %s has no inputs %v", rule.modelName, unknown))
+	}
+	return errors.Join(errs...)
+}
+
+// checkInputMaps logs the rules whose input map does not match their model's
+// metadata. Such rules fail input validation, and do not run, until the model's
+// signature matches.
+func (mp *metricsinferenceprocessor) checkInputMaps() {
+	for ruleIdx, rule := range mp.rules {
+		if !rule.mapsInputs {
+			continue
+		}
+		metadata, hasMetadata := mp.modelMetadata[rule.modelName]
+		if !hasMetadata || len(metadata.inputs) == 0 {
+			continue
+		}
+		if err := checkInputMap(rule, metadata.inputs); err != nil {
+			mp.logger.Error("Rule input_map does not match the model's inputs, the rule will not run",
+				zap.String("model", rule.modelName),
+				zap.Int("rule_index", ruleIdx),
+				zap.Error(err))
+		}
+	}
+}
+
+// expectedModelInput returns the metadata of the model input a rule input is
+// sent as: the input it is mapped or renamed to, else the input at the same
+// position. It returns nil when the model has no such input.
+func expectedModelInput(rule internalRule, modelInputs []*pb.ModelMetadataResponse_TensorMetadata, position int, inputName string) *pb.ModelMetadataResponse_TensorMetadata {
+	name, renamed := rule.inputTensors[inputName]
+	if !renamed {
+		if position >= len(modelInputs) {
+			return nil
+		}
+		return modelInputs[position]
+	}
+	idx := slices.IndexFunc(modelInputs, func(input *pb.ModelMetadataResponse_TensorMetadata) bool {
+		return input.Name == name
+	})
+	if idx < 0 {
+		return nil
+	}
+	return modelInputs[idx]
+}
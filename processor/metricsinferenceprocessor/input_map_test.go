@@ -0,0 +1,207 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// hostModelMetadata describes a model taking "memory" and "cpu", in that order
+func hostModelMetadata() *pb.ModelMetadataResponse {
+	return &pb.ModelMetadataResponse{
+		Name: "host_health",
+		Inputs: []*pb.ModelMetadataResponse_TensorMetadata{
+			{Name: "memory", Datatype: "FP64", Shape: []int64{1}},
+			{Name: "cpu", Datatype: "FP64", Shape: []int64{1}},
+		},
+		Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
+			{Name: "score", Datatype: "FP64", Shape: []int64{1}},
+		},
+	}
+}
+
+func TestInputMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputMap map[string]string
+		infers   bool
+	}{
+		{
+			name:     "every model input mapped",
+			inputMap: map[string]string{"cpu": "system.cpu.utilization", "memory": "system.memory.utilization"},
+			infers:   true,
+		},
+		{
+			name:     "model input unmapped",
+			inputMap: map[string]string{"cpu": "system.cpu.utilization"},
+		},
+		{
+			name:     "unknown model input",
+			inputMap: map[string]string{"cpu": "system.cpu.utilization", "mem": "system.memory.utilization"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelResponse("host_health", testutil.CreateMockResponseForCalculation("host_health", 0.9)),
+				testutil.WithModelMetadata("host_health", hostModelMetadata()))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Rules: []Rule{{
+					ModelName:     "host_health",
+					InputMap:      tt.inputMap,
+					OutputPattern: "host.{output}",
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"system.memory.utilization", "system.cpu.utilization"},
+				MetricValues: [][]float64{{0.25}, {0.5}},
+			})
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+			requests := mockServer.GetRequests()
+			if !tt.infers {
+				assert.Empty(t, requests, "a rule not matching the model's inputs does not run")
+				return
+			}
+
+			// Each metric is sent under the name of the model input it is mapped to
+			require.Len(t, requests, 1)
+			tensors := make(map[string]float64)
+			for _, tensor := range requests[0].Inputs {
+				require.Len(t, tensor.Contents.Fp64Contents, 1)
+				tensors[tensor.Name] = tensor.Contents.Fp64Contents[0]
+			}
+			assert.Equal(t, map[string]float64{"cpu": 0.5, "memory": 0.25}, tensors)
+
+			metric := findMetricByName(sink.AllMetrics()[0], "host.score")
+			require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+			assert.Equal(t, 0.9, metric.Gauge().DataPoints().At(0).DoubleValue())
+		})
+	}
+}
+
+func TestMappedInputs(t *testing.T) {
+	inputs, tensors := mappedInputs(map[string]string{
+		"memory": "system.memory.utilization",
+		"cpu":    `system.cpu.utilization{state="user"}`,
+	})
+	assert.Equal(t, []string{`system.cpu.utilization{state="user"}`, "system.memory.utilization"}, inputs)
+	assert.Equal(t, map[string]string{
+		`system.cpu.utilization{state="user"}`: "cpu",
+		"system.memory.utilization":            "memory",
+	}, tensors)
+}
+
+func TestCheckInputMap(t *testing.T) {
+	_, tensors := mappedInputs(map[string]string{"cpu": "system.cpu.utilization", "disk": "system.disk.io"})
+	rule := internalRule{modelName: "host_health", inputTensors: tensors, mapsInputs: true}
+
+	err := checkInputMap(rule, hostModelMetadata().Inputs)
+	assert.ErrorContains(t, err, "model host_health inputs [memory] are not mapped")
+	assert.ErrorContains(t, err, "model host_health has no inputs [disk]")
+
+	_, rule.inputTensors = mappedInputs(map[string]string{"cpu": "system.cpu.utilization", "memory": "system.memory.utilization"})
+	assert.NoError(t, checkInputMap(rule, hostModelMetadata().Inputs))
+
+	// Control tensors and forwarded attributes are not mapped
+	rule.controlInputs = ControlInputsConfig{Start: "START"}
+	rule.forwardAttributes = newForwardedAttributes([]ForwardAttributeConfig{{Key: "host.name", As: forwardAsTensor}})
+	modelInputs := append(hostModelMetadata().Inputs,
+		&pb.ModelMetadataResponse_TensorMetadata{Name: "START", Datatype: "INT32", Shape: []int64{1}},
+		&pb.ModelMetadataResponse_TensorMetadata{Name: "host.name", Datatype: "BYTES", Shape: []int64{1}})
+	assert.NoError(t, checkInputMap(rule, modelInputs))
+}
+
+func TestValidateInputMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr string
+	}{
+		{
+			name: "valid",
+			rule: Rule{ModelName: "host_health", InputMap: map[string]string{"cpu": "system.cpu.utilization"}},
+		},
+		{
+			name: "with inputs",
+			rule: Rule{
+				ModelName: "host_health",
+				Inputs:    []string{"system.cpu.utilization"},
+				InputMap:  map[string]string{"cpu": "system.cpu.utilization"},
+			},
+			wantErr: "input_map and inputs are mutually exclusive",
+		},
+		{
+			name: "with input_prefix",
+			rule: Rule{
+				ModelName:   "host_health",
+				InputPrefix: "system.",
+				InputMap:    map[string]string{"cpu": "system.cpu.utilization"},
+			},
+			wantErr: "input_map and input_prefix are mutually exclusive",
+		},
+		{
+			name: "in-process backend",
+			rule: Rule{
+				ModelName: "smoother",
+				Backend:   backendLocal,
+				Local:     &LocalConfig{Function: "ewma", Alpha: 0.5},
+				InputMap:  map[string]string{"cpu": "system.cpu.utilization"},
+			},
+			wantErr: "in-process backends have no model inputs to map",
+		},
+		{
+			name:    "empty selector",
+			rule:    Rule{ModelName: "host_health", InputMap: map[string]string{"cpu": ""}},
+			wantErr: "model input \"cpu\" has no metric selector",
+		},
+		{
+			name: "duplicate selector",
+			rule: Rule{
+				ModelName: "host_health",
+				InputMap:  map[string]string{"cpu": "system.cpu.utilization", "load": "system.cpu.utilization"},
+			},
+			wantErr: "metric selector \"system.cpu.utilization\" is mapped to both \"cpu\" and \"load\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+				Rules:              []Rule{tt.rule},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, "invalid input_map in rule 0")
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
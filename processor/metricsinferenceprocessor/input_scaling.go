@@ -0,0 +1,203 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Scaling methods
+const (
+	scalingNormalize   = "normalize"
+	scalingStandardize = "standardize"
+)
+
+// defaultScalingWindow is the default number of recent values the rolling
+// statistics of automatic scaling are computed from
+const defaultScalingWindow = 100
+
+// scalingParameters are the statistics an input was scaled with, by name
+// ("min" and "max", or "mean" and "std")
+type scalingParameters map[string]float64
+
+// validateScaling checks the normalize and standardize options of an input
+func validateScaling(cfg InputTransformConfig) error {
+	if cfg.Normalize != nil && cfg.Standardize != nil {
+		return errors.New("normalize and standardize are mutually exclusive")
+	}
+	if n := cfg.Normalize; n != nil {
+		if n.Window < 0 {
+			return errors.New("normalize.window must not be negative")
+		}
+		if n.Auto && (n.Min != 0 || n.Max != 0) {
+			return errors.New("normalize.auto cannot be combined with min and max")
+		}
+		if !n.Auto && n.Max <= n.Min {
+			return fmt.Errorf("normalize.max (%g) must be greater than normalize.min (%g)", n.Max, n.Min)
+		}
+	}
+	if s := cfg.Standardize; s != nil {
+		if s.Window < 0 {
+			return errors.New("standardize.window must not be negative")
+		}
+		if s.Auto && (s.Mean != 0 || s.Std != 0) {
+			return errors.New("standardize.auto cannot be combined with mean and std")
+		}
+		if !s.Auto && s.Std <= 0 {
+			return errors.New("standardize.std must be positive")
+		}
+	}
+	if cfg.ExposeScaling && cfg.Normalize == nil && cfg.Standardize == nil {
+		return errors.New("expose_scaling requires normalize or standardize")
+	}
+	return nil
+}
+
+// inputScaler rescales the values of an input, either with fixed statistics or
+// with rolling statistics over the most recent values of the input. Statistics
+// are shared by all series of the input, like the scaler a model is trained with.
+type inputScaler struct {
+	method string
+	auto   bool
+	a, b   float64 // Fixed min and max, or mean and std
+
+	mu      sync.Mutex
+	window  []float64 // Most recent values, a ring of at most size values
+	size    int
+	written int
+}
+
+// newInputScaler creates the scaler configured for an input, or returns nil
+// when the input is not scaled
+func newInputScaler(cfg InputTransformConfig) *inputScaler {
+	switch {
+	case cfg.Normalize != nil:
+		return &inputScaler{
+			method: scalingNormalize,
+			auto:   cfg.Normalize.Auto,
+			a:      cfg.Normalize.Min,
+			b:      cfg.Normalize.Max,
+			size:   scalingWindow(cfg.Normalize.Window),
+		}
+	case cfg.Standardize != nil:
+		return &inputScaler{
+			method: scalingStandardize,
+			auto:   cfg.Standardize.Auto,
+			a:      cfg.Standardize.Mean,
+			b:      cfg.Standardize.Std,
+			size:   scalingWindow(cfg.Standardize.Window),
+		}
+	default:
+		return nil
+	}
+}
+
+// scalingWindow returns the configured window, or the default when unset
+func scalingWindow(window int) int {
+	if window <= 0 {
+		return defaultScalingWindow
+	}
+	return window
+}
+
+// scale returns a gauge with the scaled values of a gauge or sum metric, along
+// with the statistics used. Automatic statistics include the values being scaled.
+func (s *inputScaler) scale(metric pmetric.Metric) (pmetric.Metric, scalingParameters, error) {
+	var dps pmetric.NumberDataPointSlice
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dps = metric.Gauge().DataPoints()
+	case pmetric.MetricTypeSum:
+		dps = metric.Sum().DataPoints()
+	default:
+		return pmetric.Metric{}, nil, fmt.Errorf("%s requires a gauge or sum metric, %s is a %s", s.method, metric.Name(), metric.Type().String())
+	}
+
+	values := make([]float64, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		values[i] = dataPointValue(dps.At(i))
+	}
+	a, b := s.statistics(values)
+
+	scaled := pmetric.NewMetric()
+	scaled.SetName(metric.Name())
+	scaled.SetUnit("1")
+	gauge := scaled.SetEmptyGauge()
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		out := gauge.DataPoints().AppendEmpty()
+		dp.Attributes().CopyTo(out.Attributes())
+		out.SetStartTimestamp(dp.StartTimestamp())
+		out.SetTimestamp(dp.Timestamp())
+		out.SetDoubleValue(s.apply(values[i], a, b))
+	}
+
+	if s.method == scalingNormalize {
+		return scaled, scalingParameters{"min": a, "max": b}, nil
+	}
+	return scaled, scalingParameters{"mean": a, "std": b}, nil
+}
+
+// apply scales one value. A degenerate range or a zero deviation maps every
+// value to 0, which is also what a constant input would be scaled to.
+func (s *inputScaler) apply(value, a, b float64) float64 {
+	if s.method == scalingNormalize {
+		if b <= a {
+			return 0
+		}
+		return (value - a) / (b - a)
+	}
+	if b <= 0 {
+		return 0
+	}
+	return (value - a) / b
+}
+
+// statistics returns the min and max, or the mean and standard deviation, to
+// scale with. Automatic statistics first take in the new values.
+func (s *inputScaler) statistics(values []float64) (float64, float64) {
+	if !s.auto {
+		return s.a, s.b
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, value := range values {
+		if len(s.window) < s.size {
+			s.window = append(s.window, value)
+		} else {
+			s.window[s.written%s.size] = value
+		}
+		s.written++
+	}
+	if len(s.window) == 0 {
+		return 0, 0
+	}
+
+	if s.method == scalingNormalize {
+		lo, hi := s.window[0], s.window[0]
+		for _, v := range s.window[1:] {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		return lo, hi
+	}
+
+	var sum float64
+	for _, v := range s.window {
+		sum += v
+	}
+	mean := sum / float64(len(s.window))
+	var variance float64
+	for _, v := range s.window {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(s.window)))
+}
@@ -0,0 +1,179 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// newGaugeValues creates a gauge with one data point per value
+func newGaugeValues(values ...float64) pmetric.Metric {
+	metric := pmetric.NewMetric()
+	metric.SetName("cpu.utilization")
+	gauge := metric.SetEmptyGauge()
+	for _, value := range values {
+		gauge.DataPoints().AppendEmpty().SetDoubleValue(value)
+	}
+	return metric
+}
+
+// scaledValues applies a transform and returns the scaled values in order
+func scaledValues(t *testing.T, transform *inputTransform, metric pmetric.Metric) ([]float64, scalingParameters) {
+	scaled, params, err := transform.apply(metric, pcommon.NewMap())
+	require.NoError(t, err)
+	values := make([]float64, scaled.Gauge().DataPoints().Len())
+	for i := range values {
+		values[i] = scaled.Gauge().DataPoints().At(i).DoubleValue()
+	}
+	return values, params
+}
+
+func TestInputScalingFixed(t *testing.T) {
+	normalize := newInputTransform(InputTransformConfig{Normalize: &NormalizeConfig{Min: 0, Max: 200}})
+	values, params := scaledValues(t, normalize, newGaugeValues(0, 50, 200))
+	assert.Equal(t, []float64{0, 0.25, 1}, values)
+	assert.Equal(t, scalingParameters{"min": 0, "max": 200}, params)
+
+	standardize := newInputTransform(InputTransformConfig{Standardize: &StandardizeConfig{Mean: 10, Std: 2}})
+	values, params = scaledValues(t, standardize, newGaugeValues(6, 10, 13))
+	assert.Equal(t, []float64{-2, 0, 1.5}, values)
+	assert.Equal(t, scalingParameters{"mean": 10, "std": 2}, params)
+}
+
+func TestInputScalingAuto(t *testing.T) {
+	normalize := newInputTransform(InputTransformConfig{Normalize: &NormalizeConfig{Auto: true, Window: 3}})
+
+	// A single value has no range yet
+	values, _ := scaledValues(t, normalize, newGaugeValues(5))
+	assert.Equal(t, []float64{0}, values)
+
+	values, params := scaledValues(t, normalize, newGaugeValues(15, 10))
+	assert.Equal(t, []float64{1, 0.5}, values)
+	assert.Equal(t, scalingParameters{"min": 5, "max": 15}, params)
+
+	// The oldest value leaves the window
+	_, params = scaledValues(t, normalize, newGaugeValues(20))
+	assert.Equal(t, scalingParameters{"min": 10, "max": 20}, params)
+
+	standardize := newInputTransform(InputTransformConfig{Standardize: &StandardizeConfig{Auto: true}})
+	values, params = scaledValues(t, standardize, newGaugeValues(2, 4, 4, 4, 5, 5, 7, 9))
+	assert.Equal(t, scalingParameters{"mean": 5, "std": 2}, params)
+	assert.Equal(t, -1.5, values[0])
+}
+
+func TestValidateScaling(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     InputTransformConfig
+		wantErr string
+	}{
+		{name: "fixed normalize", cfg: InputTransformConfig{Normalize: &NormalizeConfig{Min: -1, Max: 1}}},
+		{name: "auto standardize", cfg: InputTransformConfig{As: inputAsRate, Standardize: &StandardizeConfig{Auto: true, Window: 50}, ExposeScaling: true}},
+		{
+			name:    "both methods",
+			cfg:     InputTransformConfig{Normalize: &NormalizeConfig{Max: 1}, Standardize: &StandardizeConfig{Std: 1}},
+			wantErr: "mutually exclusive",
+		},
+		{
+			name:    "empty range",
+			cfg:     InputTransformConfig{Normalize: &NormalizeConfig{Min: 5, Max: 5}},
+			wantErr: "must be greater than",
+		},
+		{
+			name:    "auto with bounds",
+			cfg:     InputTransformConfig{Normalize: &NormalizeConfig{Auto: true, Max: 5}},
+			wantErr: "cannot be combined",
+		},
+		{
+			name:    "zero deviation",
+			cfg:     InputTransformConfig{Standardize: &StandardizeConfig{Mean: 3}},
+			wantErr: "std must be positive",
+		},
+		{
+			name:    "negative window",
+			cfg:     InputTransformConfig{Standardize: &StandardizeConfig{Auto: true, Window: -1}},
+			wantErr: "window must not be negative",
+		},
+		{
+			name:    "nothing to expose",
+			cfg:     InputTransformConfig{As: inputAsRate, ExposeScaling: true},
+			wantErr: "expose_scaling requires",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateScaling(tt.cfg)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestScaledInputRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "scorer",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "scaled.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+				Transforms: map[string]InputTransformConfig{
+					"metric_1": {Normalize: &NormalizeConfig{Min: 0, Max: 100}, ExposeScaling: true},
+				},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{40}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, []float64{0.4}, requests[0].Inputs[0].Contents.Fp64Contents)
+
+	// The input metric itself passes through unscaled
+	md := sink.AllMetrics()[0]
+	assert.Equal(t, 40.0, findMetricByName(md, "metric_1").Gauge().DataPoints().At(0).DoubleValue())
+
+	output := findMetricByName(md, "scaled.score")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	attrs := output.Gauge().DataPoints().At(0).Attributes()
+	maxValue, ok := attrs.Get("metric_1.scaling.max")
+	require.True(t, ok)
+	assert.Equal(t, 100.0, maxValue.Double())
+	minValue, ok := attrs.Get("metric_1.scaling.min")
+	require.True(t, ok)
+	assert.Equal(t, 0.0, minValue.Double())
+}
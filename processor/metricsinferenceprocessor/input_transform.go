@@ -0,0 +1,251 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Input transforms
+const (
+	inputAsValue = "value"
+	inputAsDelta = "delta"
+	inputAsRate  = "rate"
+)
+
+// defaultTransformStaleAfter is the default gap after which a series' previous
+// data point is no longer used to compute deltas and rates
+const defaultTransformStaleAfter = 5 * time.Minute
+
+// validateInputTransforms checks that a rule's transforms refer to its inputs and are well-formed
+func validateInputTransforms(rule Rule) error {
+	for input, transform := range rule.Transforms {
+		found := false
+		for _, ruleInput := range rule.Inputs {
+			if ruleInput == input {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("transform for unknown input %q", input)
+		}
+		switch transform.As {
+		case "", inputAsValue, inputAsDelta, inputAsRate:
+		default:
+			return fmt.Errorf("invalid as %q for input %q (must be 'value', 'delta', or 'rate')", transform.As, input)
+		}
+		if err := validateAggregate(transform.Aggregate); err != nil {
+			return fmt.Errorf("%w for input %q", err, input)
+		}
+		if transform.StaleAfter < 0 {
+			return fmt.Errorf("stale_after for input %q must not be negative", input)
+		}
+		if err := validateScaling(transform); err != nil {
+			return fmt.Errorf("invalid scaling for input %q: %w", input, err)
+		}
+	}
+	return nil
+}
+
+// inputTransform converts the data points of an input into deltas or rates,
+// aggregates them into a single value, and then scales them. For deltas and
+// rates, it keeps the previous data point of every series across batches. A
+// series yields no value for its first data point, after a gap longer than
+// staleAfter, or for a data point that is not newer than the previous one.
+type inputTransform struct {
+	as            string
+	staleAfter    time.Duration
+	aggregate     string       // Empty when the data points are not aggregated
+	scaler        *inputScaler // Nil when the input is not scaled
+	exposeScaling bool         // Whether the scaling statistics are stamped on outputs
+
+	mu         sync.Mutex
+	series     map[uint64]*transformSeries
+	generation uint64
+}
+
+// transformSeries is the previous data point of one series
+type transformSeries struct {
+	value     float64
+	start     pcommon.Timestamp
+	timestamp pcommon.Timestamp
+	lastSeen  uint64
+}
+
+// newInputTransforms creates the transforms configured for a rule's inputs, by
+// input name, or returns nil when every input is fed as is
+func newInputTransforms(configs map[string]InputTransformConfig) map[string]*inputTransform {
+	var transforms map[string]*inputTransform
+	for input, cfg := range configs {
+		if transform := newInputTransform(cfg); transform != nil {
+			if transforms == nil {
+				transforms = make(map[string]*inputTransform)
+			}
+			transforms[input] = transform
+		}
+	}
+	return transforms
+}
+
+// newInputTransform creates the transform configured for an input, or returns
+// nil when the input is fed as is
+func newInputTransform(cfg InputTransformConfig) *inputTransform {
+	as := cfg.As
+	if as == "" {
+		as = inputAsValue
+	}
+	scaler := newInputScaler(cfg)
+	if as == inputAsValue && cfg.Aggregate == "" && scaler == nil {
+		return nil
+	}
+	staleAfter := cfg.StaleAfter
+	if staleAfter == 0 {
+		staleAfter = defaultTransformStaleAfter
+	}
+	return &inputTransform{
+		as:            as,
+		staleAfter:    staleAfter,
+		aggregate:     cfg.Aggregate,
+		scaler:        scaler,
+		exposeScaling: cfg.ExposeScaling,
+		series:        make(map[uint64]*transformSeries),
+	}
+}
+
+// apply converts a metric into deltas or rates and aggregates its data points,
+// if configured, and scales the result. It returns the scaling statistics used,
+// nil when the input is not scaled.
+func (t *inputTransform) apply(metric pmetric.Metric, resourceAttrs pcommon.Map) (pmetric.Metric, scalingParameters, error) {
+	var err error
+	if t.as != inputAsValue {
+		if metric, err = t.difference(metric, resourceAttrs); err != nil {
+			return pmetric.Metric{}, nil, err
+		}
+	}
+	if t.aggregate != "" {
+		if metric, err = aggregateMetric(metric, t.aggregate); err != nil {
+			return pmetric.Metric{}, nil, err
+		}
+	}
+	if t.scaler == nil {
+		return metric, nil, nil
+	}
+	return t.scaler.scale(metric)
+}
+
+// difference returns a gauge holding the delta or rate of every series of a gauge or
+// sum metric that has a usable previous data point. Series are keyed by their
+// resource and data point attributes.
+func (t *inputTransform) difference(metric pmetric.Metric, resourceAttrs pcommon.Map) (pmetric.Metric, error) {
+	var dps pmetric.NumberDataPointSlice
+	deltaSum, monotonic := false, false
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dps = metric.Gauge().DataPoints()
+	case pmetric.MetricTypeSum:
+		dps = metric.Sum().DataPoints()
+		deltaSum = metric.Sum().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+		monotonic = metric.Sum().IsMonotonic()
+	default:
+		return pmetric.Metric{}, fmt.Errorf("%s inputs require a gauge or sum metric, %s is a %s", t.as, metric.Name(), metric.Type().String())
+	}
+
+	derived := pmetric.NewMetric()
+	derived.SetName(metric.Name())
+	derived.SetUnit(metric.Unit())
+	if t.as == inputAsRate && metric.Unit() != "" {
+		derived.SetUnit(metric.Unit() + "/s")
+	}
+	gauge := derived.SetEmptyGauge()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.generation++
+	resourceKey := attributeSetHash(resourceAttrs)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		value, ok := t.next(resourceKey, dp, deltaSum, monotonic)
+		if !ok {
+			continue
+		}
+		out := gauge.DataPoints().AppendEmpty()
+		dp.Attributes().CopyTo(out.Attributes())
+		out.SetStartTimestamp(dp.StartTimestamp())
+		out.SetTimestamp(dp.Timestamp())
+		out.SetDoubleValue(value)
+	}
+	t.evictIdle()
+
+	return derived, nil
+}
+
+// next records a data point of a series and returns its delta or rate. Delta
+// sums already carry the change over their interval; gauges and cumulative sums
+// are differenced against the previous data point of the series. The caller must hold t.mu.
+func (t *inputTransform) next(resourceKey uint64, dp pmetric.NumberDataPoint, deltaSum, monotonic bool) (float64, bool) {
+	value := dataPointValue(dp)
+	timestamp := dp.Timestamp().AsTime()
+	key := resourceKey*31 + attributeSetHash(dp.Attributes())
+
+	prev, exists := t.series[key]
+	if exists && dp.Timestamp() <= prev.timestamp {
+		return 0, false // Duplicate or out-of-order data point
+	}
+	if !exists {
+		prev = &transformSeries{}
+		t.series[key] = prev
+	}
+	stale := !exists || timestamp.Sub(prev.timestamp.AsTime()) > t.staleAfter
+	previous := *prev
+	prev.value = value
+	prev.start = dp.StartTimestamp()
+	prev.timestamp = dp.Timestamp()
+	prev.lastSeen = t.generation
+
+	var delta float64
+	var interval time.Duration
+	switch {
+	case deltaSum:
+		delta = value
+		if dp.StartTimestamp() != 0 && dp.StartTimestamp() < dp.Timestamp() {
+			interval = timestamp.Sub(dp.StartTimestamp().AsTime())
+		} else if !stale {
+			interval = timestamp.Sub(previous.timestamp.AsTime())
+		}
+	case stale:
+		return 0, false
+	case monotonic && (dp.StartTimestamp() != previous.start || value < previous.value):
+		// The counter was reset, so it counted up from zero since the previous point
+		delta = value
+		interval = timestamp.Sub(previous.timestamp.AsTime())
+	default:
+		delta = value - previous.value
+		interval = timestamp.Sub(previous.timestamp.AsTime())
+	}
+
+	if t.as == inputAsDelta {
+		return delta, true
+	}
+	if interval <= 0 {
+		return 0, false
+	}
+	return delta / interval.Seconds(), true
+}
+
+// evictIdle drops series that have not been seen for attributeIndexIdleBatches
+// batches. The caller must hold t.mu.
+func (t *inputTransform) evictIdle() {
+	for key, series := range t.series {
+		if t.generation-series.lastSeen > attributeIndexIdleBatches {
+			delete(t.series, key)
+		}
+	}
+}
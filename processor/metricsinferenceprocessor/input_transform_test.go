@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// counterPoint is a data point of a test counter series
+type counterPoint struct {
+	host  string
+	start int64 // Seconds
+	at    int64 // Seconds
+	value float64
+}
+
+// newCounter creates a sum metric with one data point per counterPoint
+func newCounter(temporality pmetric.AggregationTemporality, points ...counterPoint) pmetric.Metric {
+	metric := pmetric.NewMetric()
+	metric.SetName("http.requests")
+	metric.SetUnit("{request}")
+	sum := metric.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(temporality)
+	for _, p := range points {
+		dp := sum.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("host", p.host)
+		dp.SetStartTimestamp(pcommon.Timestamp(p.start * int64(time.Second)))
+		dp.SetTimestamp(pcommon.Timestamp(p.at * int64(time.Second)))
+		dp.SetDoubleValue(p.value)
+	}
+	return metric
+}
+
+// transformedValues applies a transform and returns the resulting values by host
+func transformedValues(t *testing.T, transform *inputTransform, metric pmetric.Metric) map[string]float64 {
+	derived, _, err := transform.apply(metric, pcommon.NewMap())
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for i := 0; i < derived.Gauge().DataPoints().Len(); i++ {
+		dp := derived.Gauge().DataPoints().At(i)
+		host, _ := dp.Attributes().Get("host")
+		values[host.Str()] = dp.DoubleValue()
+	}
+	return values
+}
+
+func TestInputTransformRate(t *testing.T) {
+	transform := newInputTransform(InputTransformConfig{As: inputAsRate, StaleAfter: time.Minute})
+	cumulative := pmetric.AggregationTemporalityCumulative
+
+	// The first data point of a series has nothing to compare against
+	assert.Empty(t, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 0, at: 10, value: 100})))
+
+	assert.Equal(t, map[string]float64{"a": 5}, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 0, at: 20, value: 150},
+		counterPoint{host: "b", start: 0, at: 20, value: 7})))
+
+	// A counter reset counts up from zero; a duplicate point is skipped
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1}, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 25, at: 30, value: 20},
+		counterPoint{host: "b", start: 0, at: 30, value: 17})))
+	assert.Empty(t, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 25, at: 30, value: 20})))
+
+	// After a gap longer than stale_after the series starts over
+	assert.Empty(t, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 25, at: 200, value: 90})))
+	assert.Equal(t, map[string]float64{"a": 1}, transformedValues(t, transform, newCounter(cumulative,
+		counterPoint{host: "a", start: 25, at: 210, value: 100})))
+
+	derived, _, err := transform.apply(newCounter(cumulative), pcommon.NewMap())
+	require.NoError(t, err)
+	assert.Equal(t, "{request}/s", derived.Unit())
+}
+
+func TestInputTransformDelta(t *testing.T) {
+	transform := newInputTransform(InputTransformConfig{As: inputAsDelta})
+
+	// Delta sums are used as they are, including their first data point
+	delta := pmetric.AggregationTemporalityDelta
+	assert.Equal(t, map[string]float64{"a": 4}, transformedValues(t, transform, newCounter(delta,
+		counterPoint{host: "a", start: 0, at: 10, value: 4})))
+
+	// Gauges are differenced and may go down
+	transform = newInputTransform(InputTransformConfig{As: inputAsDelta})
+	gauge := func(at int64, value float64) pmetric.Metric {
+		metric := pmetric.NewMetric()
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("host", "a")
+		dp.SetTimestamp(pcommon.Timestamp(at * int64(time.Second)))
+		dp.SetDoubleValue(value)
+		return metric
+	}
+	assert.Empty(t, transformedValues(t, transform, gauge(1, 10)))
+	assert.Equal(t, map[string]float64{"a": -4}, transformedValues(t, transform, gauge(2, 6)))
+
+	histogram := pmetric.NewMetric()
+	histogram.SetEmptyHistogram()
+	_, _, err := transform.apply(histogram, pcommon.NewMap())
+	assert.ErrorContains(t, err, "require a gauge or sum metric")
+}
+
+func TestValidateInputTransforms(t *testing.T) {
+	rule := Rule{ModelName: "m", Inputs: []string{"x"}}
+	assert.NoError(t, validateInputTransforms(rule))
+
+	rule.Transforms = map[string]InputTransformConfig{"x": {As: inputAsRate, StaleAfter: time.Minute}}
+	assert.NoError(t, validateInputTransforms(rule))
+
+	rule.Transforms = map[string]InputTransformConfig{"y": {As: inputAsRate}}
+	assert.ErrorContains(t, validateInputTransforms(rule), "unknown input \"y\"")
+
+	rule.Transforms = map[string]InputTransformConfig{"x": {As: "increase"}}
+	assert.ErrorContains(t, validateInputTransforms(rule), "invalid as \"increase\"")
+
+	rule.Transforms = map[string]InputTransformConfig{"x": {As: inputAsDelta, StaleAfter: -time.Second}}
+	assert.ErrorContains(t, validateInputTransforms(rule), "stale_after")
+
+	assert.Nil(t, newInputTransform(InputTransformConfig{As: inputAsValue}))
+}
+
+func TestRateInputRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("load", testutil.CreateMockResponseForCalculation("load", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:  "load",
+				Inputs:     []string{"http.requests"},
+				Transforms: map[string]InputTransformConfig{"http.requests": {As: inputAsRate}},
+				Outputs:    []OutputSpec{{Name: "forecast"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for _, p := range []counterPoint{
+		{host: "a", start: 0, at: 10, value: 100},
+		{host: "a", start: 0, at: 20, value: 130},
+	} {
+		md := pmetric.NewMetrics()
+		newCounter(pmetric.AggregationTemporalityCumulative, p).
+			CopyTo(md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty())
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+	}
+
+	// The first batch only primes the series, the second sends its rate
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	assert.Equal(t, []float64{3}, requests[0].Inputs[0].Contents.Fp64Contents)
+}
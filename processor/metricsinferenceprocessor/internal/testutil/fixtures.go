@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Fixture configures a mock inference server for a test scenario
+type Fixture func(m *MockInferenceServer)
+
+// WithModelResponse returns a fixture that configures the response for a model
+func WithModelResponse(modelName string, response *pb.ModelInferResponse) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetModelResponse(modelName, response)
+	}
+}
+
+// WithModelError returns a fixture that configures an error response for a model
+func WithModelError(modelName string, err error) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetModelError(modelName, err)
+	}
+}
+
+// WithModelMetadata returns a fixture that configures the metadata response for a model
+func WithModelMetadata(modelName string, metadata *pb.ModelMetadataResponse) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetModelMetadata(modelName, metadata)
+	}
+}
+
+// WithServerExtensions returns a fixture that configures the extensions the server reports
+func WithServerExtensions(extensions ...string) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetServerExtensions(extensions)
+	}
+}
+
+// WithLatency returns a fixture that delays every inference call by latency plus
+// a random jitter below jitter
+func WithLatency(latency, jitter time.Duration) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetFaults("", FaultConfig{Latency: latency, Jitter: jitter})
+	}
+}
+
+// WithFaults returns a fixture that configures the faults of a model's inference calls
+func WithFaults(modelName string, faults FaultConfig) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetFaults(modelName, faults)
+	}
+}
+
+// WithResponseSequence returns a fixture that configures the outcomes of a model's
+// next inference calls
+func WithResponseSequence(modelName string, steps ...MockStep) Fixture {
+	return func(m *MockInferenceServer) {
+		m.SetResponseSequence(modelName, steps...)
+	}
+}
+
+// StartMockServer starts an isolated mock inference server with the fixtures applied
+// and stops it when the test finishes. Each test gets its own server and port, so
+// tests using it can run in parallel.
+func StartMockServer(t testing.TB, fixtures ...Fixture) *MockInferenceServer {
+	t.Helper()
+
+	server := NewMockInferenceServer()
+	for _, fixture := range fixtures {
+		fixture(server)
+	}
+	server.Start(t)
+	t.Cleanup(server.Stop)
+	return server
+}
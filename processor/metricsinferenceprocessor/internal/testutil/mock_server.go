@@ -6,29 +6,46 @@ package testutil
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
 )
 
-// MockInferenceServer implements the GRPCInferenceService for testing
+// MockInferenceServer implements the GRPCInferenceService for testing.
+// It is safe for concurrent use, so each parallel test can run its own server.
 type MockInferenceServer struct {
 	pb.UnimplementedGRPCInferenceServiceServer
 
+	mu sync.Mutex
+
 	// Configuration
 	responses map[string]*pb.ModelInferResponse
 	metadata  map[string]*pb.ModelMetadataResponse
 	errors    map[string]error
 
+	extensions []string // Extensions reported in server metadata
+
+	// Fault injection
+	faults    map[string]*injectedFaults // Faults of inference calls, by model name ("" for every model)
+	sequences map[string][]MockStep      // Outcomes of the next inference calls, by model name
+
+	unloaded map[string]bool // Models unloaded from the repository
+
 	// Request tracking
 	requests        []*pb.ModelInferRequest
+	requestMetadata []metadata.MD
 	serverLiveCalls int
 
 	// Server management
@@ -43,25 +60,103 @@ func NewMockInferenceServer() *MockInferenceServer {
 		responses: make(map[string]*pb.ModelInferResponse),
 		metadata:  make(map[string]*pb.ModelMetadataResponse),
 		errors:    make(map[string]error),
+		faults:    make(map[string]*injectedFaults),
+		sequences: make(map[string][]MockStep),
+		unloaded:  make(map[string]bool),
 		requests:  make([]*pb.ModelInferRequest, 0),
+		// The extensions Triton reports that the processor makes use of
+		extensions: []string{"health_check", "model_repository", "binary_tensor_data", "parameters"},
 	}
 }
 
 // SetModelResponse configures the response for a specific model
 func (m *MockInferenceServer) SetModelResponse(modelName string, response *pb.ModelInferResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.responses[modelName] = response
 }
 
 // SetModelError configures an error response for a specific model
 func (m *MockInferenceServer) SetModelError(modelName string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.errors[modelName] = err
 }
 
 // SetModelMetadata configures the metadata response for a specific model
 func (m *MockInferenceServer) SetModelMetadata(modelName string, metadata *pb.ModelMetadataResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.metadata[modelName] = metadata
 }
 
+// SetServerExtensions configures the extensions reported in server metadata
+func (m *MockInferenceServer) SetServerExtensions(extensions []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.extensions = extensions
+}
+
+// FaultConfig injects faults into the inference calls of a model
+type FaultConfig struct {
+	// Latency delays every answer
+	Latency time.Duration
+
+	// Jitter adds a random delay, uniformly below it, to the latency
+	Jitter time.Duration
+
+	// ErrorRate is the fraction of calls, between 0 and 1, failing with a
+	// transient error instead of their response
+	ErrorRate float64
+
+	// ErrorCode is the status of transient errors. Default is Unavailable.
+	ErrorCode codes.Code
+
+	// Seed seeds the draws of jitter and transient errors, so a test sees the
+	// same faults on every run
+	Seed int64
+}
+
+// injectedFaults are the faults of a model with the draws deciding them
+type injectedFaults struct {
+	FaultConfig
+	random *rand.Rand
+}
+
+// MockStep is the outcome of one inference call in a response sequence: its
+// error when set, else its response, else the model's configured behavior
+type MockStep struct {
+	Response *pb.ModelInferResponse
+	Err      error
+}
+
+// SetFaults configures the faults of a model's inference calls, or of every
+// model without faults of its own when modelName is empty. Calls of a response
+// sequence are delayed too, but never fail with a transient error.
+func (m *MockInferenceServer) SetFaults(modelName string, faults FaultConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faults[modelName] = &injectedFaults{FaultConfig: faults, random: rand.New(rand.NewSource(faults.Seed))}
+}
+
+// SetResponseSequence configures the outcomes of the next inference calls of a
+// model, one step per call, such as a failure followed by a success. Once the
+// steps are used up, calls get the model's configured response or error again.
+func (m *MockInferenceServer) SetResponseSequence(modelName string, steps ...MockStep) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sequences[modelName] = append([]MockStep(nil), steps...)
+}
+
+// SetModelUnloaded unloads a model from the repository, or loads it again. An
+// unloaded model is listed as unavailable, is not ready, and fails inference and
+// metadata calls with Unavailable, as with Triton's explicit model control.
+func (m *MockInferenceServer) SetModelUnloaded(modelName string, unloaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unloaded[modelName] = unloaded
+}
+
 // Endpoint returns the server endpoint address
 func (m *MockInferenceServer) Endpoint() string {
 	return m.address
@@ -69,11 +164,22 @@ func (m *MockInferenceServer) Endpoint() string {
 
 // GetRequests returns all received inference requests
 func (m *MockInferenceServer) GetRequests() []*pb.ModelInferRequest {
-	return m.requests
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*pb.ModelInferRequest(nil), m.requests...)
+}
+
+// GetRequestMetadata returns the incoming gRPC metadata of all received inference requests
+func (m *MockInferenceServer) GetRequestMetadata() []metadata.MD {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]metadata.MD(nil), m.requestMetadata...)
 }
 
 // GetServerLiveCalls returns the number of ServerLive calls received
 func (m *MockInferenceServer) GetServerLiveCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.serverLiveCalls
 }
 
@@ -82,8 +188,8 @@ func (m *MockInferenceServer) GetAddress() string {
 	return m.address
 }
 
-// Start starts the mock server on a random available port
-func (m *MockInferenceServer) Start(t *testing.T) {
+// Start starts the mock server on a random available port and waits until it serves requests
+func (m *MockInferenceServer) Start(t testing.TB) {
 	lis, err := net.Listen("tcp", "localhost:0")
 	require.NoError(t, err)
 
@@ -99,17 +205,30 @@ func (m *MockInferenceServer) Start(t *testing.T) {
 		}
 	}()
 
-	// Wait for server to be ready
-	time.Sleep(10 * time.Millisecond)
+	m.waitReady(t)
+}
+
+// waitReady polls the server readiness endpoint until it answers. ServerReady is
+// used rather than ServerLive so the probe does not count as a health check.
+func (m *MockInferenceServer) waitReady(t testing.TB) {
+	conn, err := grpc.NewClient(m.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewGRPCInferenceServiceClient(conn)
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		resp, err := client.ServerReady(ctx, &pb.ServerReadyRequest{})
+		return err == nil && resp.Ready
+	}, 5*time.Second, time.Millisecond, "mock inference server did not become ready")
 }
 
 // Stop stops the mock server
 func (m *MockInferenceServer) Stop() {
 	if m.server != nil {
-		// GracefulStop ensures all pending RPCs are completed
+		// GracefulStop returns once all pending RPCs are completed
 		m.server.GracefulStop()
-		// Give the server time to fully clean up
-		time.Sleep(50 * time.Millisecond)
 	}
 	if m.listener != nil {
 		m.listener.Close()
@@ -118,15 +237,23 @@ func (m *MockInferenceServer) Stop() {
 
 // Reset clears all requests and responses
 func (m *MockInferenceServer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.requests = make([]*pb.ModelInferRequest, 0)
+	m.requestMetadata = nil
 	m.responses = make(map[string]*pb.ModelInferResponse)
 	m.metadata = make(map[string]*pb.ModelMetadataResponse)
 	m.errors = make(map[string]error)
+	m.faults = make(map[string]*injectedFaults)
+	m.sequences = make(map[string][]MockStep)
+	m.unloaded = make(map[string]bool)
 	m.serverLiveCalls = 0
 }
 
 // ServerLive implements the health check
 func (m *MockInferenceServer) ServerLive(ctx context.Context, req *pb.ServerLiveRequest) (*pb.ServerLiveResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.serverLiveCalls++
 	return &pb.ServerLiveResponse{Live: true}, nil
 }
@@ -138,6 +265,13 @@ func (m *MockInferenceServer) ServerReady(ctx context.Context, req *pb.ServerRea
 
 // ModelReady implements the model readiness check
 func (m *MockInferenceServer) ModelReady(ctx context.Context, req *pb.ModelReadyRequest) (*pb.ModelReadyResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.unloaded[req.Name] {
+		return &pb.ModelReadyResponse{Ready: false}, nil
+	}
+
 	// Check if we have a response configured for this model
 	if _, exists := m.responses[req.Name]; exists {
 		return &pb.ModelReadyResponse{Ready: true}, nil
@@ -154,15 +288,24 @@ func (m *MockInferenceServer) ModelReady(ctx context.Context, req *pb.ModelReady
 
 // ServerMetadata implements the server metadata retrieval
 func (m *MockInferenceServer) ServerMetadata(ctx context.Context, req *pb.ServerMetadataRequest) (*pb.ServerMetadataResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return &pb.ServerMetadataResponse{
 		Name:       "mock-inference-server",
 		Version:    "1.0.0",
-		Extensions: []string{"health_check"},
+		Extensions: m.extensions,
 	}, nil
 }
 
 // ModelMetadata implements the model metadata retrieval
 func (m *MockInferenceServer) ModelMetadata(ctx context.Context, req *pb.ModelMetadataRequest) (*pb.ModelMetadataResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.unloaded[req.Name] {
+		return nil, unloadedError(req.Name)
+	}
+
 	// Check if we have custom metadata for this model
 	if metadata, exists := m.metadata[req.Name]; exists {
 		return metadata, nil
@@ -178,11 +321,114 @@ func (m *MockInferenceServer) ModelMetadata(ctx context.Context, req *pb.ModelMe
 	return nil, status.Error(codes.NotFound, fmt.Sprintf("model metadata not found for model: %s", req.Name))
 }
 
-// ModelInfer implements the main inference endpoint
+// RepositoryIndex lists the models with configured metadata, one entry per
+// version, in name order. Unloaded models are unavailable, and left out when
+// only ready models are requested.
+func (m *MockInferenceServer) RepositoryIndex(ctx context.Context, req *pb.RepositoryIndexRequest) (*pb.RepositoryIndexResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.metadata))
+	for name := range m.metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := &pb.RepositoryIndexResponse{}
+	for _, name := range names {
+		versions := m.metadata[name].Versions
+		if len(versions) == 0 {
+			versions = []string{""}
+		}
+		state := "READY"
+		if m.unloaded[name] {
+			if req.Ready {
+				continue
+			}
+			state = "UNAVAILABLE"
+		}
+		for _, version := range versions {
+			resp.Models = append(resp.Models, &pb.RepositoryIndexResponse_ModelIndex{
+				Name:    name,
+				Version: version,
+				State:   state,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// ModelInfer implements the main inference endpoint. Configured latency is
+// waited out before answering, so a call whose deadline passes first fails
+// with DeadlineExceeded as with a slow server.
 func (m *MockInferenceServer) ModelInfer(ctx context.Context, req *pb.ModelInferRequest) (*pb.ModelInferResponse, error) {
+	response, delay, err := m.modelInfer(ctx, req)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return response, err
+}
+
+// modelInfer records a request and decides its outcome, along with how long
+// answering it is delayed
+func (m *MockInferenceServer) modelInfer(ctx context.Context, req *pb.ModelInferRequest) (*pb.ModelInferResponse, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Store the request for verification
 	m.requests = append(m.requests, req)
+	md, _ := metadata.FromIncomingContext(ctx)
+	m.requestMetadata = append(m.requestMetadata, md)
 
+	if m.unloaded[req.ModelName] {
+		return nil, 0, unloadedError(req.ModelName)
+	}
+
+	fault, hasFault := m.faults[req.ModelName]
+	if !hasFault {
+		fault, hasFault = m.faults[""]
+	}
+	var delay time.Duration
+	if hasFault {
+		delay = fault.Latency
+		if fault.Jitter > 0 {
+			delay += time.Duration(fault.random.Int63n(int64(fault.Jitter)))
+		}
+	}
+
+	// A configured sequence decides the outcome of the next calls
+	if steps := m.sequences[req.ModelName]; len(steps) > 0 {
+		step := steps[0]
+		m.sequences[req.ModelName] = steps[1:]
+		if step.Err != nil {
+			return nil, delay, step.Err
+		}
+		if step.Response != nil {
+			return step.Response, delay, nil
+		}
+	}
+
+	// Transient errors fail a fraction of the calls
+	if hasFault && fault.ErrorRate > 0 && fault.random.Float64() < fault.ErrorRate {
+		code := fault.ErrorCode
+		if code == codes.OK {
+			code = codes.Unavailable
+		}
+		return nil, delay, status.Error(code, "injected transient error")
+	}
+	response, err := m.respond(req)
+	return response, delay, err
+}
+
+// respond returns the configured error or response of a model, or a default
+// response. The caller must hold m.mu.
+func (m *MockInferenceServer) respond(req *pb.ModelInferRequest) (*pb.ModelInferResponse, error) {
 	// Check if we have an error configured for this model
 	if err, exists := m.errors[req.ModelName]; exists {
 		return nil, err
@@ -500,3 +746,8 @@ func CreateMockResponseForFilesystem(modelName string, capacityPrediction float6
 func CreateMockErrorResponse(code codes.Code, message string) error {
 	return status.Error(code, message)
 }
+
+// unloadedError is the error of calls to an unloaded model
+func unloadedError(modelName string) error {
+	return status.Error(codes.Unavailable, fmt.Sprintf("request for unknown model: '%s' is not found", modelName))
+}
@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package testutil
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// ScaleSpec describes a high-cardinality batch: Metrics gauges, each with a data
+// point series per attribute set, DataPoints points long
+type ScaleSpec struct {
+	Metrics       int
+	AttributeSets int
+	DataPoints    int
+}
+
+// String names the spec in benchmark names, e.g. "4x1000x1"
+func (s ScaleSpec) String() string {
+	return fmt.Sprintf("%dx%dx%d", s.Metrics, s.AttributeSets, s.DataPoints)
+}
+
+// TotalDataPoints returns the number of data points in a batch of the spec
+func (s ScaleSpec) TotalDataPoints() int {
+	return s.Metrics * s.AttributeSets * s.DataPoints
+}
+
+// DefaultScaleSpecs are the batch sizes benchmarks run at, from a small batch to
+// one with many series per metric
+var DefaultScaleSpecs = []ScaleSpec{
+	{Metrics: 2, AttributeSets: 10, DataPoints: 1},
+	{Metrics: 4, AttributeSets: 100, DataPoints: 1},
+	{Metrics: 4, AttributeSets: 1000, DataPoints: 1},
+	{Metrics: 4, AttributeSets: 1000, DataPoints: 10},
+	{Metrics: 8, AttributeSets: 10000, DataPoints: 1},
+}
+
+// ScaleMetricNames returns the names of the metrics of a spec's batch
+func ScaleMetricNames(spec ScaleSpec) []string {
+	names := make([]string, spec.Metrics)
+	for i := range names {
+		names[i] = fmt.Sprintf("scale.metric.%d", i)
+	}
+	return names
+}
+
+// GenerateScaleMetrics creates a batch of the spec's size in one resource. Every
+// metric has the same attribute sets, with "host.name" and "pod.name" attributes,
+// so the series of different metrics match. Data points of a series are one
+// second apart and their values differ across metrics, attribute sets and time.
+func GenerateScaleMetrics(spec ScaleSpec) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "scale-test")
+	sm := rm.ScopeMetrics().AppendEmpty()
+
+	start := time.Now().Add(-time.Duration(spec.DataPoints) * time.Second)
+	for m, name := range ScaleMetricNames(spec) {
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		dps := metric.SetEmptyGauge().DataPoints()
+		dps.EnsureCapacity(spec.AttributeSets * spec.DataPoints)
+		for a := 0; a < spec.AttributeSets; a++ {
+			for p := 0; p < spec.DataPoints; p++ {
+				dp := dps.AppendEmpty()
+				dp.Attributes().PutStr("host.name", fmt.Sprintf("host-%d", a%100))
+				dp.Attributes().PutStr("pod.name", fmt.Sprintf("pod-%d", a))
+				dp.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(p) * time.Second)))
+				dp.SetDoubleValue(float64(m*spec.AttributeSets+a) + float64(p)/10)
+			}
+		}
+	}
+	return md
+}
+
+// RunScaleBenchmarks runs fn as a sub-benchmark per spec on a fresh copy of the
+// spec's batch in every iteration, as processing adds outputs to the batch.
+// Copying is excluded from the timings, and the throughput is reported in data
+// points per second.
+func RunScaleBenchmarks(b *testing.B, specs []ScaleSpec, fn func(md pmetric.Metrics) error) {
+	for _, spec := range specs {
+		batch := GenerateScaleMetrics(spec)
+		b.Run(spec.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				md := pmetric.NewMetrics()
+				batch.CopyTo(md)
+				b.StartTimer()
+				if err := fn(md); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(spec.TotalDataPoints())*float64(b.N)/b.Elapsed().Seconds(), "datapoints/s")
+		})
+	}
+}
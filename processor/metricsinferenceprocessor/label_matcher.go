@@ -15,35 +15,35 @@ func matchesSelector(metric pmetric.Metric, selector *labelSelector) bool {
 	}
 
 	// First check metric name
-	if metric.Name() != selector.metricName {
+	if !selector.matchesName(metric.Name()) {
 		return false
 	}
 
 	// If no label filters, metric name match is sufficient
-	if len(selector.labels) == 0 {
+	if len(selector.labels) == 0 && len(selector.matchers) == 0 {
 		return true
 	}
 
 	// Check if any data point matches the label filters
 	switch metric.Type() {
 	case pmetric.MetricTypeGauge:
-		return hasMatchingGaugeDataPoint(metric.Gauge(), selector.labels)
+		return hasMatchingGaugeDataPoint(metric.Gauge(), selector)
 	case pmetric.MetricTypeSum:
-		return hasMatchingSumDataPoint(metric.Sum(), selector.labels)
+		return hasMatchingSumDataPoint(metric.Sum(), selector)
 	case pmetric.MetricTypeHistogram:
-		return hasMatchingHistogramDataPoint(metric.Histogram(), selector.labels)
+		return hasMatchingHistogramDataPoint(metric.Histogram(), selector)
 	case pmetric.MetricTypeSummary:
-		return hasMatchingSummaryDataPoint(metric.Summary(), selector.labels)
+		return hasMatchingSummaryDataPoint(metric.Summary(), selector)
 	default:
 		return false
 	}
 }
 
 // hasMatchingGaugeDataPoint checks if any gauge data point matches the label filters
-func hasMatchingGaugeDataPoint(gauge pmetric.Gauge, labelFilters map[string]string) bool {
+func hasMatchingGaugeDataPoint(gauge pmetric.Gauge, selector *labelSelector) bool {
 	dps := gauge.DataPoints()
 	for i := 0; i < dps.Len(); i++ {
-		if dataPointMatchesLabels(dps.At(i).Attributes(), labelFilters) {
+		if dataPointMatchesSelector(dps.At(i).Attributes(), selector) {
 			return true
 		}
 	}
@@ -51,10 +51,10 @@ func hasMatchingGaugeDataPoint(gauge pmetric.Gauge, labelFilters map[string]stri
 }
 
 // hasMatchingSumDataPoint checks if any sum data point matches the label filters
-func hasMatchingSumDataPoint(sum pmetric.Sum, labelFilters map[string]string) bool {
+func hasMatchingSumDataPoint(sum pmetric.Sum, selector *labelSelector) bool {
 	dps := sum.DataPoints()
 	for i := 0; i < dps.Len(); i++ {
-		if dataPointMatchesLabels(dps.At(i).Attributes(), labelFilters) {
+		if dataPointMatchesSelector(dps.At(i).Attributes(), selector) {
 			return true
 		}
 	}
@@ -62,10 +62,10 @@ func hasMatchingSumDataPoint(sum pmetric.Sum, labelFilters map[string]string) bo
 }
 
 // hasMatchingHistogramDataPoint checks if any histogram data point matches the label filters
-func hasMatchingHistogramDataPoint(histogram pmetric.Histogram, labelFilters map[string]string) bool {
+func hasMatchingHistogramDataPoint(histogram pmetric.Histogram, selector *labelSelector) bool {
 	dps := histogram.DataPoints()
 	for i := 0; i < dps.Len(); i++ {
-		if dataPointMatchesLabels(dps.At(i).Attributes(), labelFilters) {
+		if dataPointMatchesSelector(dps.At(i).Attributes(), selector) {
 			return true
 		}
 	}
@@ -73,16 +73,33 @@ func hasMatchingHistogramDataPoint(histogram pmetric.Histogram, labelFilters map
 }
 
 // hasMatchingSummaryDataPoint checks if any summary data point matches the label filters
-func hasMatchingSummaryDataPoint(summary pmetric.Summary, labelFilters map[string]string) bool {
+func hasMatchingSummaryDataPoint(summary pmetric.Summary, selector *labelSelector) bool {
 	dps := summary.DataPoints()
 	for i := 0; i < dps.Len(); i++ {
-		if dataPointMatchesLabels(dps.At(i).Attributes(), labelFilters) {
+		if dataPointMatchesSelector(dps.At(i).Attributes(), selector) {
 			return true
 		}
 	}
 	return false
 }
 
+// dataPointMatchesSelector checks if data point attributes satisfy all label matches of a selector
+func dataPointMatchesSelector(attributes pcommon.Map, selector *labelSelector) bool {
+	if !dataPointMatchesLabels(attributes, selector.labels) {
+		return false
+	}
+	for _, matcher := range selector.matchers {
+		value := ""
+		if actual, exists := attributes.Get(matcher.key); exists {
+			value = actual.AsString()
+		}
+		if !matcher.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
 // dataPointMatchesLabels checks if data point attributes match all label filters
 func dataPointMatchesLabels(attributes pcommon.Map, labelFilters map[string]string) bool {
 	for key, expectedValue := range labelFilters {
@@ -98,58 +115,132 @@ func dataPointMatchesLabels(attributes pcommon.Map, labelFilters map[string]stri
 }
 
 // filterMetricByLabels creates a new metric containing only data points that match the label filters
-func filterMetricByLabels(metric pmetric.Metric, labelFilters map[string]string) pmetric.Metric {
+func filterMetricByLabels(metric pmetric.Metric, selector *labelSelector) pmetric.Metric {
 	filtered := pmetric.NewMetric()
 	metric.CopyTo(filtered)
 
 	// If no label filters, return the whole metric
-	if len(labelFilters) == 0 {
+	if len(selector.labels) == 0 && len(selector.matchers) == 0 {
 		return filtered
 	}
 
 	// Filter data points based on metric type
 	switch filtered.Type() {
 	case pmetric.MetricTypeGauge:
-		filterGaugeDataPoints(filtered.Gauge(), labelFilters)
+		filterGaugeDataPoints(filtered.Gauge(), selector)
 	case pmetric.MetricTypeSum:
-		filterSumDataPoints(filtered.Sum(), labelFilters)
+		filterSumDataPoints(filtered.Sum(), selector)
 	case pmetric.MetricTypeHistogram:
-		filterHistogramDataPoints(filtered.Histogram(), labelFilters)
+		filterHistogramDataPoints(filtered.Histogram(), selector)
 	case pmetric.MetricTypeSummary:
-		filterSummaryDataPoints(filtered.Summary(), labelFilters)
+		filterSummaryDataPoints(filtered.Summary(), selector)
 	}
 
 	return filtered
 }
 
 // filterGaugeDataPoints removes data points that don't match the label filters
-func filterGaugeDataPoints(gauge pmetric.Gauge, labelFilters map[string]string) {
+func filterGaugeDataPoints(gauge pmetric.Gauge, selector *labelSelector) {
 	dps := gauge.DataPoints()
 	dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
-		return !dataPointMatchesLabels(dp.Attributes(), labelFilters)
+		return !dataPointMatchesSelector(dp.Attributes(), selector)
 	})
 }
 
 // filterSumDataPoints removes data points that don't match the label filters
-func filterSumDataPoints(sum pmetric.Sum, labelFilters map[string]string) {
+func filterSumDataPoints(sum pmetric.Sum, selector *labelSelector) {
 	dps := sum.DataPoints()
 	dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
-		return !dataPointMatchesLabels(dp.Attributes(), labelFilters)
+		return !dataPointMatchesSelector(dp.Attributes(), selector)
 	})
 }
 
 // filterHistogramDataPoints removes data points that don't match the label filters
-func filterHistogramDataPoints(histogram pmetric.Histogram, labelFilters map[string]string) {
+func filterHistogramDataPoints(histogram pmetric.Histogram, selector *labelSelector) {
 	dps := histogram.DataPoints()
 	dps.RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
-		return !dataPointMatchesLabels(dp.Attributes(), labelFilters)
+		return !dataPointMatchesSelector(dp.Attributes(), selector)
 	})
 }
 
 // filterSummaryDataPoints removes data points that don't match the label filters
-func filterSummaryDataPoints(summary pmetric.Summary, labelFilters map[string]string) {
+func filterSummaryDataPoints(summary pmetric.Summary, selector *labelSelector) {
 	dps := summary.DataPoints()
 	dps.RemoveIf(func(dp pmetric.SummaryDataPoint) bool {
-		return !dataPointMatchesLabels(dp.Attributes(), labelFilters)
+		return !dataPointMatchesSelector(dp.Attributes(), selector)
 	})
 }
+
+// mergeMatchedMetrics combines the metrics selected by a name pattern into one
+// metric of the first metric's type. Each data point records its source metric
+// in the metric.name attribute, so series of different metrics stay distinct.
+// Metrics of another type than the first are skipped.
+func mergeMatchedMetrics(metrics []pmetric.Metric) pmetric.Metric {
+	merged := pmetric.NewMetric()
+	first := metrics[0]
+	merged.SetName(first.Name())
+	merged.SetUnit(first.Unit())
+	merged.SetDescription(first.Description())
+
+	switch first.Type() {
+	case pmetric.MetricTypeGauge:
+		dst := merged.SetEmptyGauge().DataPoints()
+		for _, metric := range metrics {
+			if metric.Type() != first.Type() {
+				continue
+			}
+			src := metric.Gauge().DataPoints()
+			for i := 0; i < src.Len(); i++ {
+				dp := dst.AppendEmpty()
+				src.At(i).CopyTo(dp)
+				dp.Attributes().PutStr(sourceMetricAttr, metric.Name())
+			}
+		}
+	case pmetric.MetricTypeSum:
+		sum := merged.SetEmptySum()
+		sum.SetAggregationTemporality(first.Sum().AggregationTemporality())
+		sum.SetIsMonotonic(first.Sum().IsMonotonic())
+		for _, metric := range metrics {
+			if metric.Type() != first.Type() {
+				continue
+			}
+			src := metric.Sum().DataPoints()
+			for i := 0; i < src.Len(); i++ {
+				dp := sum.DataPoints().AppendEmpty()
+				src.At(i).CopyTo(dp)
+				dp.Attributes().PutStr(sourceMetricAttr, metric.Name())
+			}
+		}
+	case pmetric.MetricTypeHistogram:
+		histogram := merged.SetEmptyHistogram()
+		histogram.SetAggregationTemporality(first.Histogram().AggregationTemporality())
+		for _, metric := range metrics {
+			if metric.Type() != first.Type() {
+				continue
+			}
+			src := metric.Histogram().DataPoints()
+			for i := 0; i < src.Len(); i++ {
+				dp := histogram.DataPoints().AppendEmpty()
+				src.At(i).CopyTo(dp)
+				dp.Attributes().PutStr(sourceMetricAttr, metric.Name())
+			}
+		}
+	case pmetric.MetricTypeSummary:
+		dst := merged.SetEmptySummary().DataPoints()
+		for _, metric := range metrics {
+			if metric.Type() != first.Type() {
+				continue
+			}
+			src := metric.Summary().DataPoints()
+			for i := 0; i < src.Len(); i++ {
+				dp := dst.AppendEmpty()
+				src.At(i).CopyTo(dp)
+				dp.Attributes().PutStr(sourceMetricAttr, metric.Name())
+			}
+		}
+	default:
+		first.CopyTo(merged)
+	}
+
+	return merged
+}
@@ -5,134 +5,339 @@ package metricsinferenceprocessor
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+)
+
+// Label match operators
+const (
+	matchEqual       = "="
+	matchNotEqual    = "!="
+	matchRegexp      = "=~"
+	matchNotRegexp   = "!~"
+	metricNameLabel  = "__name__"
+	sourceMetricAttr = "metric.name" // Attribute recording the source metric of data points merged by a name pattern
 )
 
 // labelSelector represents a parsed label selector for metric filtering
 type labelSelector struct {
 	metricName string
-	labels     map[string]string
+	// namePattern matches metric names when the selector uses __name__=~, in which
+	// case metricName is empty and every matching metric feeds the input
+	namePattern *regexp.Regexp
+	labels      map[string]string // Exact label matches
+	matchers    []labelMatcher    // Negative and regular expression label matches
+	// percentile is the quantile (0-1) to derive from a histogram metric, 0 when unused
+	percentile float64
+}
+
+// labelMatcher is a label match other than equality
+type labelMatcher struct {
+	key   string
+	op    string
+	value string
+	re    *regexp.Regexp // Anchored expression for =~ and !~
+}
+
+// matches reports whether an attribute value satisfies the matcher. As in
+// Prometheus, a missing attribute is treated as an empty value.
+func (m labelMatcher) matches(value string) bool {
+	switch m.op {
+	case matchNotEqual:
+		return value != m.value
+	case matchRegexp:
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// hasFilters reports whether the selector filters metrics beyond an exact name
+func (ls *labelSelector) hasFilters() bool {
+	return ls.namePattern != nil || len(ls.labels) > 0 || len(ls.matchers) > 0
 }
 
-// parseLabelSelector parses a Prometheus-style metric selector
+// matchesName reports whether a metric name is selected
+func (ls *labelSelector) matchesName(name string) bool {
+	if ls.namePattern != nil {
+		return ls.namePattern.MatchString(name)
+	}
+	return name == ls.metricName
+}
+
+// parseLabelSelector parses a Prometheus-style metric selector. The grammar,
+// with whitespace allowed between tokens, is:
+//
+//	selector  = percentile "(" vector ")" | vector
+//	vector    = name [ "{" [ matchers ] "}" ] | "{" matchers "}"
+//	matchers  = matcher { "," matcher } [ "," ]
+//	matcher   = name ( "=" | "!=" | "=~" | "!~" ) value
+//	name      = name_char { name_char }
+//	name_char = letter | digit | "_" | "." | ":" | "-" | "/"
+//	value     = '"' chars '"' | "'" chars "'" | "`" raw chars "`" | bare value
+//
+// Double- and single-quoted values take Go escape sequences, such as \" and
+// \\; other backslashes, such as in the regular expression "a\.b", are kept as
+// written. Backquoted values are taken literally. A bare value runs up to the
+// next comma, closing brace or space and may not contain quotes or backslashes.
+//
 // Examples:
 //   - "metric_name" -> just the metric name, no label filtering
 //   - "metric_name{label1=\"value1\"}" -> metric with single label filter
 //   - "metric_name{label1=\"value1\",label2=\"value2\"}" -> metric with multiple label filters
+//   - "metric_name{cpu=~\"0|1\",state!=\"idle\"}" -> regular expression and negative matches
+//   - "{__name__=~\"system\\.cpu\\..*\"}" -> every metric whose name matches the expression
+//   - "p99(metric_name{label1=\"value1\"})" -> 99th percentile estimated from a histogram metric
 func parseLabelSelector(selector string) (*labelSelector, error) {
 	selector = strings.TrimSpace(selector)
 	if selector == "" {
 		return nil, fmt.Errorf("empty selector")
 	}
 
-	// Check if selector contains labels
-	openBrace := strings.Index(selector, "{")
-	if openBrace == -1 {
-		// No labels, just metric name
-		return &labelSelector{
-			metricName: selector,
-			labels:     make(map[string]string),
-		}, nil
+	// Derived percentile input wrapping a histogram selector
+	quantile, inner, isPercentile, err := parsePercentileFunc(selector)
+	if err != nil {
+		return nil, err
+	}
+	if isPercentile {
+		parsed, err := parseLabelSelector(inner)
+		if err != nil {
+			return nil, err
+		}
+		parsed.percentile = quantile
+		return parsed, nil
+	}
+
+	p := &selectorParser{input: selector}
+	parsed := &labelSelector{
+		metricName: p.name(),
+		labels:     make(map[string]string),
+	}
+	p.skipSpace()
+	if p.peek() == '{' {
+		p.pos++
+		if err := p.matchers(parsed); err != nil {
+			return nil, err
+		}
+	}
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.rest())
 	}
 
-	// Extract metric name
-	metricName := strings.TrimSpace(selector[:openBrace])
-	if metricName == "" {
+	if err := parsed.applyNameMatch(); err != nil {
+		return nil, err
+	}
+	if parsed.metricName == "" && parsed.namePattern == nil {
 		return nil, fmt.Errorf("empty metric name")
 	}
+	return parsed, nil
+}
 
-	// Check for closing brace
-	closeBrace := strings.LastIndex(selector, "}")
-	if closeBrace == -1 || closeBrace <= openBrace {
-		return nil, fmt.Errorf("invalid selector syntax: missing or misplaced closing brace")
+// applyNameMatch moves a __name__ label match into the metric name or name pattern
+func (ls *labelSelector) applyNameMatch() error {
+	if name, ok := ls.labels[metricNameLabel]; ok {
+		if ls.metricName != "" {
+			return fmt.Errorf("metric name given both before the braces and as %s", metricNameLabel)
+		}
+		ls.metricName = name
+		delete(ls.labels, metricNameLabel)
 	}
 
-	// Extract label part
-	labelPart := selector[openBrace+1 : closeBrace]
-	labels, err := parseLabelPairs(labelPart)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse labels: %w", err)
+	matchers := ls.matchers[:0]
+	for _, matcher := range ls.matchers {
+		if matcher.key != metricNameLabel {
+			matchers = append(matchers, matcher)
+			continue
+		}
+		if matcher.op != matchRegexp {
+			return fmt.Errorf("%s only supports the = and =~ operators", metricNameLabel)
+		}
+		if ls.metricName != "" || ls.namePattern != nil {
+			return fmt.Errorf("metric name given both before the braces and as %s", metricNameLabel)
+		}
+		ls.namePattern = matcher.re
 	}
+	ls.matchers = matchers
+	return nil
+}
+
+// selectorParser reads a selector from left to right
+type selectorParser struct {
+	input string
+	pos   int
+}
 
-	return &labelSelector{
-		metricName: metricName,
-		labels:     labels,
-	}, nil
+// errorf reports a syntax error at the current position
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid selector syntax at position %d: %s", p.pos, fmt.Sprintf(format, args...))
 }
 
-// parseLabelPairs parses comma-separated label pairs
-func parseLabelPairs(labelPart string) (map[string]string, error) {
-	labels := make(map[string]string)
-	labelPart = strings.TrimSpace(labelPart)
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.input)
+}
 
-	if labelPart == "" {
-		return labels, nil
+// peek returns the next byte, 0 at the end of the selector
+func (p *selectorParser) peek() byte {
+	if p.done() {
+		return 0
 	}
+	return p.input[p.pos]
+}
 
-	// Split by comma, but need to handle commas within quotes
-	pairs := splitLabelPairs(labelPart)
+func (p *selectorParser) rest() string {
+	return p.input[p.pos:]
+}
 
-	for _, pair := range pairs {
-		pair = strings.TrimSpace(pair)
-		if pair == "" {
-			continue
-		}
+func (p *selectorParser) skipSpace() {
+	for !p.done() && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
 
-		// Find the equals sign
-		eqIndex := strings.Index(pair, "=")
-		if eqIndex == -1 {
-			return nil, fmt.Errorf("invalid label pair: %s (missing '=')", pair)
-		}
+// isNameChar reports whether a byte may appear in a metric or label name. Names
+// follow OpenTelemetry, which also allows dots, dashes and slashes.
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == ':' || c == '-' || c == '/'
+}
 
-		key := strings.TrimSpace(pair[:eqIndex])
-		value := strings.TrimSpace(pair[eqIndex+1:])
+// name reads a metric or label name, empty when none follows
+func (p *selectorParser) name() string {
+	start := p.pos
+	for !p.done() && isNameChar(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
 
-		if key == "" {
-			return nil, fmt.Errorf("empty label key in pair: %s", pair)
+// matchers reads the matchers following an opening brace, up to and including
+// the closing brace
+func (p *selectorParser) matchers(ls *labelSelector) error {
+	for {
+		p.skipSpace()
+		switch {
+		case p.done():
+			return p.errorf("missing closing brace")
+		case p.peek() == '}':
+			p.pos++
+			return nil
 		}
 
-		// Remove quotes from value
-		value = strings.Trim(value, "\"")
+		if err := p.matcher(ls); err != nil {
+			return err
+		}
 
-		labels[key] = value
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		case 0:
+			return p.errorf("missing closing brace")
+		default:
+			return p.errorf("expected ',' or '}' after matcher, found %q", p.rest())
+		}
 	}
-
-	return labels, nil
 }
 
-// splitLabelPairs splits label pairs by comma, respecting quoted values
-func splitLabelPairs(labelPart string) []string {
-	if labelPart == "" {
-		return []string{}
+// matcher reads one label matcher into the selector
+func (p *selectorParser) matcher(ls *labelSelector) error {
+	key := p.name()
+	if key == "" {
+		if c := p.peek(); c == '=' || c == '!' {
+			return p.errorf("empty label key")
+		}
+		return p.errorf("unexpected %q, expected a label name", p.rest())
 	}
 
-	var pairs []string
-	var current strings.Builder
-	inQuotes := false
+	p.skipSpace()
+	op := ""
+	for _, candidate := range []string{matchNotEqual, matchRegexp, matchNotRegexp, matchEqual} {
+		if strings.HasPrefix(p.rest(), candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return p.errorf("label %s is missing '=', '!=', '=~' or '!~'", key)
+	}
+	p.pos += len(op)
 
-	for i, ch := range labelPart {
-		switch ch {
-		case '"':
-			inQuotes = !inQuotes
-			current.WriteRune(ch)
-		case ',':
-			if !inQuotes {
-				pairs = append(pairs, current.String())
-				current.Reset()
-			} else {
-				current.WriteRune(ch)
-			}
-		default:
-			current.WriteRune(ch)
+	p.skipSpace()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	if op == matchEqual {
+		if _, exists := ls.labels[key]; exists {
+			return fmt.Errorf("label %s is matched for equality more than once", key)
+		}
+		ls.labels[key] = value
+		return nil
+	}
+
+	matcher := labelMatcher{key: key, op: op, value: value}
+	if op == matchRegexp || op == matchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regular expression for label %s: %w", key, err)
 		}
+		matcher.re = re
+	}
+	ls.matchers = append(ls.matchers, matcher)
+	return nil
+}
 
-		// Handle last pair
-		if i == len(labelPart)-1 {
-			if current.Len() > 0 {
-				pairs = append(pairs, current.String())
+// value reads a quoted or bare label value
+func (p *selectorParser) value() (string, error) {
+	quote := p.peek()
+	switch quote {
+	case '"', '\'':
+		p.pos++
+		var value strings.Builder
+		for {
+			if p.done() {
+				return "", p.errorf("unterminated quoted value")
+			}
+			if p.peek() == quote {
+				p.pos++
+				return value.String(), nil
 			}
+			r, _, tail, err := strconv.UnquoteChar(p.rest(), quote)
+			if err != nil {
+				// Keep backslashes Go does not know, as regular expressions use them
+				if p.peek() != '\\' || p.pos+1 >= len(p.input) {
+					return "", p.errorf("invalid escape sequence in %q", p.rest())
+				}
+				value.WriteString(p.input[p.pos : p.pos+2])
+				p.pos += 2
+				continue
+			}
+			value.WriteRune(r)
+			p.pos = len(p.input) - len(tail)
+		}
+	case '`':
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if end == -1 {
+			p.pos++
+			return "", p.errorf("unterminated quoted value")
 		}
+		value := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
 	}
 
-	return pairs
+	// Bare values are accepted for configurations written before quoting was required
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(",}\"'`\\", rune(p.peek())) && !unicode.IsSpace(rune(p.peek())) {
+		p.pos++
+	}
+	if c := p.peek(); c == '"' || c == '\'' || c == '`' || c == '\\' {
+		return "", p.errorf("unexpected %q in unquoted value", c)
+	}
+	return p.input[start:p.pos], nil
 }
@@ -4,10 +4,17 @@
 package metricsinferenceprocessor
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
 )
 
 func TestParseLabelSelector(t *testing.T) {
@@ -59,13 +66,13 @@ func TestParseLabelSelector(t *testing.T) {
 			name:          "missing closing brace",
 			selector:      "metric_name{label=\"value\"",
 			wantErr:       true,
-			errorContains: "missing or misplaced closing brace",
+			errorContains: "missing closing brace",
 		},
 		{
-			name:       "missing opening brace",
-			selector:   "metric_name label=\"value\"}",
-			wantMetric: "metric_name label=\"value\"}",
-			wantLabels: map[string]string{},
+			name:          "missing opening brace",
+			selector:      "metric_name label=\"value\"}",
+			wantErr:       true,
+			errorContains: "unexpected",
 		},
 		{
 			name:          "empty metric name",
@@ -119,43 +126,193 @@ func TestParseLabelSelector(t *testing.T) {
 	}
 }
 
-func TestSplitLabelPairs(t *testing.T) {
-	tests := []struct {
-		name      string
-		labelPart string
-		want      []string
-	}{
-		{
-			name:      "single pair",
-			labelPart: `label="value"`,
-			want:      []string{`label="value"`},
-		},
-		{
-			name:      "multiple pairs",
-			labelPart: `a="1",b="2",c="3"`,
-			want:      []string{`a="1"`, `b="2"`, `c="3"`},
+func TestParseLabelMatchers(t *testing.T) {
+	ls, err := parseLabelSelector(`system.cpu.time{cpu=~"0|1", state!="idle", host="a", mode!~"irq.*"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "system.cpu.time", ls.metricName)
+	assert.Equal(t, map[string]string{"host": "a"}, ls.labels)
+	require.Len(t, ls.matchers, 3)
+	assert.Equal(t, matchRegexp, ls.matchers[0].op)
+	assert.Equal(t, matchNotEqual, ls.matchers[1].op)
+	assert.Equal(t, matchNotRegexp, ls.matchers[2].op)
+
+	// Regular expressions are anchored, and a missing attribute is an empty value
+	assert.True(t, ls.matchers[0].matches("1"))
+	assert.False(t, ls.matchers[0].matches("10"))
+	assert.True(t, ls.matchers[1].matches(""))
+	assert.False(t, ls.matchers[1].matches("idle"))
+	assert.False(t, ls.matchers[2].matches("irq.soft"))
+	assert.True(t, ls.matchers[2].matches("user"))
+
+	ls, err = parseLabelSelector(`{__name__=~"system\.cpu\..*", state="user"}`)
+	require.NoError(t, err)
+	assert.Empty(t, ls.metricName)
+	assert.True(t, ls.hasFilters())
+	assert.True(t, ls.matchesName("system.cpu.time"))
+	assert.False(t, ls.matchesName("system.memory.usage"))
+
+	ls, err = parseLabelSelector(`{__name__="system.cpu.time"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "system.cpu.time", ls.metricName)
+	assert.False(t, ls.hasFilters())
+
+	for selector, wantErr := range map[string]string{
+		`metric{cpu=~"("}`:               "error parsing regexp",
+		`{__name__!="metric"}`:           "only supports the = and =~ operators",
+		`metric{__name__=~"metric.*"}`:   "metric name given both",
+		`metric{state!}`:                 "missing '='",
+		`{__name__=~"a.*",__name__="b"}`: "metric name given both",
+	} {
+		_, err := parseLabelSelector(selector)
+		assert.ErrorContains(t, err, wantErr, selector)
+	}
+}
+
+func TestSelectorMatchGroups(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+	mockServer.SetModelResponse("busy", &pb.ModelInferResponse{
+		ModelName: "busy",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+			Name:     "score",
+			Datatype: "FP64",
+			Shape:    []int64{3},
+			Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3}},
+		}},
+	})
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "busy",
+				Inputs:        []string{`{__name__=~"cpu\.(user|system)", cpu=~"0|1", state!="idle"}`},
+				OutputPattern: "{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+			},
 		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
 		{
-			name:      "comma in quoted value",
-			labelPart: `label="value,with,commas",other="normal"`,
-			want:      []string{`label="value,with,commas"`, `other="normal"`},
+			MetricName: "cpu.user",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 10, Attributes: map[string]string{"cpu": "0", "state": "busy"}},
+				{Value: 20, Attributes: map[string]string{"cpu": "1", "state": "busy"}},
+				{Value: 30, Attributes: map[string]string{"cpu": "2", "state": "busy"}},
+				{Value: 40, Attributes: map[string]string{"cpu": "0", "state": "idle"}},
+			},
 		},
 		{
-			name:      "spaces around commas",
-			labelPart: `a="1" , b="2" , c="3"`,
-			want:      []string{`a="1" `, ` b="2" `, ` c="3"`},
+			MetricName: "cpu.system",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 5, Attributes: map[string]string{"cpu": "1", "state": "busy"}},
+			},
 		},
 		{
-			name:      "empty string",
-			labelPart: "",
-			want:      []string{},
+			MetricName: "cpu.nice",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 1, Attributes: map[string]string{"cpu": "0", "state": "busy"}},
+			},
 		},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	assert.ElementsMatch(t, []float64{5, 10, 20}, requests[0].Inputs[0].Contents.Fp64Contents)
+
+	// Every matched group keeps its attributes, including the metric it came from,
+	// namespaced by the input
+	input := cfg.Rules[0].Inputs[0]
+	output := findMetricByName(sink.AllMetrics()[0], "score")
+	require.Equal(t, pmetric.MetricTypeGauge, output.Type())
+	require.Equal(t, 3, output.Gauge().DataPoints().Len())
+	groups := make(map[string]bool)
+	for i := 0; i < output.Gauge().DataPoints().Len(); i++ {
+		attrs := output.Gauge().DataPoints().At(i).Attributes()
+		name, ok := attrs.Get(input + "." + sourceMetricAttr)
+		require.True(t, ok)
+		cpu, ok := attrs.Get(input + ".cpu")
+		require.True(t, ok)
+		state, ok := attrs.Get(input + ".state")
+		require.True(t, ok)
+		assert.Equal(t, "busy", state.Str())
+		groups[name.Str()+"/"+cpu.Str()] = true
+	}
+	assert.Equal(t, map[string]bool{"cpu.system/1": true, "cpu.user/0": true, "cpu.user/1": true}, groups)
+}
+
+func TestParseLabelValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     string
+	}{
+		{name: "double quoted", selector: `metric{label="a b"}`, want: "a b"},
+		{name: "escaped quote", selector: `metric{label="say \"hi\""}`, want: `say "hi"`},
+		{name: "escaped backslash", selector: `metric{label="C:\\temp"}`, want: `C:\temp`},
+		{name: "single quoted", selector: `metric{label='it\'s'}`, want: "it's"},
+		{name: "backquoted", selector: "metric{label=`a\\.b`}", want: `a\.b`},
+		{name: "unknown escape kept", selector: `metric{label="a\.b"}`, want: `a\.b`},
+		{name: "closing brace in value", selector: `metric{label="}"}`, want: "}"},
+		{name: "bare", selector: `metric{label=busy}`, want: "busy"},
+		{name: "trailing comma", selector: `metric{label="a",}`, want: "a"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := splitLabelPairs(tt.labelPart)
-			assert.Equal(t, tt.want, got)
+			ls, err := parseLabelSelector(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"label": tt.want}, ls.labels)
 		})
 	}
 }
+
+func TestParseLabelSelectorErrors(t *testing.T) {
+	for selector, wantErr := range map[string]string{
+		`metric{label="a"} extra`:     `position 18: unexpected "extra"`,
+		`metric label="a"`:            `position 7: unexpected "label=\"a\""`,
+		`metric{label="a" other="b"}`: "expected ',' or '}' after matcher",
+		`metric{label="a}`:            "unterminated quoted value",
+		"metric{label=`a}":            "unterminated quoted value",
+		`metric{label=a"b"}`:          "in unquoted value",
+		`metric{label="a",label="b"}`: "label label is matched for equality more than once",
+		`metric{label="a\`:            "invalid escape sequence",
+		`metric{,}`:                   "expected a label name",
+		`metric{label="a",`:           "missing closing brace",
+		`metric{label="a"`:            "missing closing brace",
+		`metric{label~"a"}`:           "missing '='",
+	} {
+		_, err := parseLabelSelector(selector)
+		assert.ErrorContains(t, err, wantErr, selector)
+	}
+}
+
+func TestValidateInputSelectors(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{`cpu{state=~"busy|idle"}`, "memory"}},
+			{ModelName: "scorer", Inputs: []string{"memory", `cpu{state="busy"`}},
+		},
+	}
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, `invalid input "cpu{state=\"busy\"" in rule 1`)
+	assert.ErrorContains(t, err, "missing closing brace")
+
+	cfg.Rules = cfg.Rules[:1]
+	assert.NoError(t, cfg.Validate())
+}
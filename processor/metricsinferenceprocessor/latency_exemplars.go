@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// How the latency of a rule's inference is recorded on its outputs
+const (
+	recordLatencyExemplar   = "exemplar"   // an exemplar per output data point
+	recordLatencyAttributes = "attributes" // attributes of the output data points
+)
+
+// labelInferenceDuration holds the duration of the inference, in seconds, when
+// latencies are recorded as attributes
+const labelInferenceDuration = "otel.inference.duration"
+
+// validateRecordLatency checks a rule's record_latency setting. Aggregation rules
+// do not infer, so they have no latency to record.
+func validateRecordLatency(rule Rule) error {
+	switch rule.RecordLatency {
+	case "":
+		return nil
+	case recordLatencyExemplar, recordLatencyAttributes:
+	default:
+		return fmt.Errorf("invalid record_latency %q (must be 'exemplar' or 'attributes')", rule.RecordLatency)
+	}
+	if rule.Aggregate != nil {
+		return errors.New("record_latency is not supported by aggregation rules")
+	}
+	return nil
+}
+
+// recordLatency records the latency and request ID of a call on the gauge and
+// sum data points of the output metrics from firstMetric on. Exemplars also
+// carry the trace and span IDs of the call's ModelInfer span when it is
+// recorded, so a slow output leads to the trace of its request.
+func recordLatency(sm pmetric.ScopeMetrics, firstMetric int, call *ruleCall) {
+	mode := call.ctx.rule.recordLatency
+	seconds := call.latency.Seconds()
+	for i := firstMetric; i < sm.Metrics().Len(); i++ {
+		dps, ok := numberDataPoints(sm.Metrics().At(i))
+		if !ok {
+			continue
+		}
+		for j := 0; j < dps.Len(); j++ {
+			dp := dps.At(j)
+			if mode == recordLatencyAttributes {
+				dp.Attributes().PutDouble(labelInferenceDuration, seconds)
+				dp.Attributes().PutStr(spanAttrRequestID, call.request.Id)
+				continue
+			}
+			exemplar := dp.Exemplars().AppendEmpty()
+			exemplar.SetTimestamp(dp.Timestamp())
+			exemplar.SetDoubleValue(seconds)
+			exemplar.FilteredAttributes().PutStr(spanAttrRequestID, call.request.Id)
+			if call.span.IsValid() {
+				exemplar.SetTraceID(pcommon.TraceID(call.span.TraceID()))
+				exemplar.SetSpanID(pcommon.SpanID(call.span.SpanID()))
+			}
+		}
+	}
+}
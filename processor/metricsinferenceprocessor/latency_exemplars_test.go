@@ -0,0 +1,138 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRecordLatency(t *testing.T) {
+	tests := []struct {
+		mode  string
+		check func(t *testing.T, attrs pcommon.Map, exemplars int)
+	}{
+		{
+			mode: recordLatencyExemplar,
+			check: func(t *testing.T, attrs pcommon.Map, exemplars int) {
+				assert.Equal(t, 1, exemplars)
+				_, ok := attrs.Get(labelInferenceDuration)
+				assert.False(t, ok)
+			},
+		},
+		{
+			mode: recordLatencyAttributes,
+			check: func(t *testing.T, attrs pcommon.Map, exemplars int) {
+				assert.Equal(t, 0, exemplars)
+				duration, ok := attrs.Get(labelInferenceDuration)
+				require.True(t, ok)
+				assert.GreaterOrEqual(t, duration.Double(), 0.0)
+				requestID, ok := attrs.Get(spanAttrRequestID)
+				require.True(t, ok)
+				assert.Contains(t, requestID.Str(), "total-")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := &Config{
+				Timeout: 5,
+				Rules: []Rule{{
+					ModelName:     "total",
+					Inputs:        []string{"requests", "errors"},
+					OutputPattern: "requests.total",
+					Backend:       backendBuiltin,
+					Builtin:       &BuiltinConfig{Operation: operationAdd},
+					RecordLatency: tt.mode,
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			recorder := tracetest.NewSpanRecorder()
+			tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			processor.telemetry, err = newProcessorTelemetry(nil, tracerProvider)
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), hostBatch(0, 1)))
+			dps := findMetricByName(sink.AllMetrics()[0], "requests.total").Gauge().DataPoints()
+			require.Equal(t, 2, dps.Len())
+			for i := 0; i < dps.Len(); i++ {
+				tt.check(t, dps.At(i).Attributes(), dps.At(i).Exemplars().Len())
+			}
+
+			if tt.mode != recordLatencyExemplar {
+				return
+			}
+			// The exemplar leads to the span of the request
+			require.Len(t, recorder.Ended(), 1)
+			span := recorder.Ended()[0]
+			exemplar := dps.At(0).Exemplars().At(0)
+			assert.Equal(t, pcommon.TraceID(span.SpanContext().TraceID()), exemplar.TraceID())
+			assert.Equal(t, pcommon.SpanID(span.SpanContext().SpanID()), exemplar.SpanID())
+			requestID, ok := exemplar.FilteredAttributes().Get(spanAttrRequestID)
+			require.True(t, ok)
+			value, _ := spanAttribute(span, spanAttrRequestID)
+			assert.Equal(t, value.AsString(), requestID.Str())
+		})
+	}
+}
+
+func TestValidateRecordLatency(t *testing.T) {
+	assert.NoError(t, validateRecordLatency(Rule{}))
+	assert.NoError(t, validateRecordLatency(Rule{RecordLatency: recordLatencyExemplar}))
+	assert.NoError(t, validateRecordLatency(Rule{RecordLatency: recordLatencyAttributes}))
+	assert.ErrorContains(t, validateRecordLatency(Rule{RecordLatency: "histogram"}), "invalid record_latency \"histogram\"")
+	assert.ErrorContains(t, validateRecordLatency(Rule{
+		RecordLatency: recordLatencyExemplar,
+		Aggregate:     &AggregateConfig{Function: aggregateMax},
+	}), "aggregation rules")
+}
+
+func TestRecordLatencyAttributesWithSeriesTracking(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			Timeout: 5,
+			Rules: []Rule{{
+				ModelName:     "total",
+				Inputs:        []string{"requests", "errors"},
+				OutputPattern: "requests.total",
+				Backend:       backendBuiltin,
+				Builtin:       &BuiltinConfig{Operation: operationAdd},
+				RecordLatency: recordLatencyAttributes,
+			}},
+		}
+	}
+	require.NoError(t, newConfig().Validate())
+
+	cfg := newConfig()
+	cfg.Staleness.Period = time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with staleness or cardinality.max_series")
+
+	cfg = newConfig()
+	cfg.Cardinality.MaxSeries = 100
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with staleness or cardinality.max_series")
+
+	cfg = newConfig()
+	cfg.Rules[0].RecordLatency = recordLatencyExemplar
+	cfg.Staleness.Period = time.Minute
+	assert.NoError(t, cfg.Validate())
+}
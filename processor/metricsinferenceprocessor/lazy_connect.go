@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+)
+
+// Backoff between lazy connection attempts
+const (
+	lazyConnectInitialBackoff = time.Second
+	lazyConnectMaxBackoff     = time.Minute
+)
+
+// lazyConnection tracks the connection to an inference server that was not
+// reachable at startup. The first batch attempts to connect; after a failed
+// attempt, the next one is made by the first batch after the backoff, which
+// doubles up to lazyConnectMaxBackoff.
+type lazyConnection struct {
+	connected   atomic.Bool
+	mu          sync.Mutex    // Held by the batch attempting to connect
+	backoff     time.Duration // Wait after the next failed attempt
+	nextAttempt time.Time     // Earliest time of the next attempt
+}
+
+// newLazyConnection creates a lazy connection attempted by the first batch
+func newLazyConnection() *lazyConnection {
+	return &lazyConnection{backoff: lazyConnectInitialBackoff}
+}
+
+// lazyConnected reports whether batches can use the inference server. With a
+// pending lazy connection it attempts to connect when an attempt is due; batches
+// arriving while another batch attempts to connect do not wait for it.
+func (mp *metricsinferenceprocessor) lazyConnected(ctx context.Context) bool {
+	lc := mp.lazyConnection
+	if lc == nil || lc.connected.Load() {
+		return true
+	}
+	if !lc.mu.TryLock() {
+		return false
+	}
+	defer lc.mu.Unlock()
+	if lc.connected.Load() {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(lc.nextAttempt) {
+		return false
+	}
+	if err := mp.connectLazily(ctx); err != nil {
+		mp.logLimiter.Warn(noRule, "Inference server not reachable, passing metrics through without inference",
+			zap.Duration("retry_in", lc.backoff),
+			zap.Error(err))
+		lc.nextAttempt = now.Add(lc.backoff)
+		lc.backoff = min(2*lc.backoff, lazyConnectMaxBackoff)
+		return false
+	}
+	lc.connected.Store(true)
+	return true
+}
+
+// connectLazily connects to the inference server and completes the startup
+// that was deferred because the server was not reachable
+func (mp *metricsinferenceprocessor) connectLazily(ctx context.Context) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.endpoints == nil {
+		return errors.New("processor is shut down")
+	}
+
+	if err := mp.checkServer(ctx); err != nil {
+		return err
+	}
+	mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+
+	// Discovered outputs change the rules that concurrent batches read
+	mp.metadataLock.Lock()
+	defer mp.metadataLock.Unlock()
+	if err := mp.completeConnection(ctx); err != nil {
+		mp.logger.Error("Discovered model outputs create invalid rule dependencies, keeping the previous rule order",
+			zap.Error(err))
+	}
+	return nil
+}
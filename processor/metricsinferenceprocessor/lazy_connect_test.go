@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// serveMockServer serves a mock inference server on a given address until the test finishes
+func serveMockServer(t *testing.T, address string, server *testutil.MockInferenceServer) {
+	lis, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	pb.RegisterGRPCInferenceServiceServer(grpcServer, server)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+}
+
+func TestLazyConnect(t *testing.T) {
+	// Reserve an address nothing listens on yet
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: address, LazyConnect: true},
+		Timeout:            1,
+		Rules: []Rule{
+			{
+				ModelName:     "scorer",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "scorer.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	host := newStatusHost()
+	require.NoError(t, processor.Start(context.Background(), host), "an unreachable server does not fail startup")
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+	require.Equal(t, []componentstatus.Status{componentstatus.StatusRecoverableError}, host.statuses())
+
+	consume := func() {
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})))
+	}
+
+	// The first batch attempts to connect, fails and passes through
+	consume()
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 1, sink.AllMetrics()[0].MetricCount())
+	lc := processor.lazyConnection
+	assert.Equal(t, 2*lazyConnectInitialBackoff, lc.backoff)
+	assert.True(t, lc.nextAttempt.After(time.Now()))
+
+	// Batches within the backoff pass through without an attempt
+	consume()
+	assert.Equal(t, 2*lazyConnectInitialBackoff, lc.backoff)
+
+	// Once the server is up, the next due attempt connects and inference runs
+	server := testutil.NewMockInferenceServer()
+	server.SetModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1))
+	serveMockServer(t, address, server)
+	require.Eventually(t, func() bool {
+		lc.mu.Lock()
+		lc.nextAttempt = time.Time{}
+		lc.mu.Unlock()
+		consume()
+		return lc.connected.Load()
+	}, 10*time.Second, 100*time.Millisecond)
+
+	last := sink.AllMetrics()[len(sink.AllMetrics())-1]
+	assert.Equal(t, "scorer.score", findMetricByName(last, "scorer.score").Name())
+	assert.Equal(t, componentstatus.StatusOK, host.statuses()[1])
+}
+
+func TestUnreachableServerFailsStart(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: address},
+		Timeout:            1,
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.ErrorContains(t, processor.Start(context.Background(), nil), "health check failed")
+	assert.NoError(t, processor.Shutdown(context.Background()))
+}
@@ -0,0 +1,360 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Local backend functions
+const (
+	localFunctionEWMA             = "ewma"
+	localFunctionZScore           = "zscore"
+	localFunctionLinearRegression = "linear_regression"
+	localFunctionHoltWinters      = "holt_winters"
+	localFunctionMAD              = "mad"
+
+	// ONNX models are evaluated by the inference server for now, as running them
+	// in-process requires cgo and the ONNX Runtime shared library
+	localFunctionONNX = "onnx"
+)
+
+// madScale makes the median absolute deviation of normally distributed values
+// an estimate of their standard deviation, so MAD scores read like z-scores
+const madScale = 1.4826
+
+// Local backend defaults
+const (
+	defaultLocalWindow  = 10
+	defaultLocalHorizon = 1
+)
+
+// localBackend evaluates simple built-in models in-process instead of calling
+// the inference server. It keeps a rolling state per series, so it is only
+// meaningful for rules with a single input.
+type localBackend struct {
+	function string
+	alpha    float64
+	beta     float64
+	gamma    float64
+	season   int
+	window   int
+	horizon  int
+	input    string
+
+	mu         sync.Mutex
+	series     map[uint64]*localSeries
+	generation uint64
+}
+
+// localSeries is the rolling state of one series
+type localSeries struct {
+	ewma     float64
+	history  []float64 // Most recent values, oldest first, at most window long
+	lastSeen uint64
+
+	// Holt-Winters components
+	level    float64
+	trend    float64
+	seasonal []float64 // Seasonal offsets, one per position in the season
+	steps    int       // Values smoothed so far
+}
+
+// newLocalBackend creates a local backend from a rule's configuration
+func newLocalBackend(cfg *LocalConfig, input string) *localBackend {
+	window := cfg.Window
+	if window <= 0 {
+		window = defaultLocalWindow
+	}
+	horizon := cfg.Horizon
+	if horizon <= 0 {
+		horizon = defaultLocalHorizon
+	}
+	return &localBackend{
+		function: cfg.Function,
+		alpha:    cfg.Alpha,
+		beta:     cfg.Beta,
+		gamma:    cfg.Gamma,
+		season:   cfg.Season,
+		window:   window,
+		horizon:  horizon,
+		input:    input,
+		series:   make(map[uint64]*localSeries),
+	}
+}
+
+// validateLocalBackend checks a rule's backend selection and local configuration
+func validateLocalBackend(rule Rule) error {
+	switch rule.Backend {
+	case "", backendServer, backendBuiltin:
+		if rule.Local != nil {
+			return errors.New("local requires backend 'local'")
+		}
+		return nil
+	case backendLocal:
+	default:
+		return fmt.Errorf("invalid backend %q (must be 'server', 'local', or 'builtin')", rule.Backend)
+	}
+
+	if rule.Local == nil {
+		return errors.New("backend 'local' requires a local configuration")
+	}
+	if rule.Synthetic != nil {
+		return errors.New("backend 'local' cannot be combined with synthetic")
+	}
+	if rule.Sequence.Enabled {
+		return errors.New("backend 'local' does not support sequences")
+	}
+	if len(rule.Inputs) != 1 {
+		return fmt.Errorf("backend 'local' requires exactly one input, got %d", len(rule.Inputs))
+	}
+	if len(rule.Outputs) > 1 {
+		return fmt.Errorf("backend 'local' produces a single output, got %d", len(rule.Outputs))
+	}
+
+	cfg := rule.Local
+	switch cfg.Function {
+	case localFunctionEWMA:
+		if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+			return fmt.Errorf("alpha must be in (0, 1] for function %q", cfg.Function)
+		}
+	case localFunctionZScore, localFunctionLinearRegression, localFunctionMAD:
+		if cfg.Window < 0 || cfg.Window == 1 {
+			return fmt.Errorf("window must be at least 2 for function %q", cfg.Function)
+		}
+	case localFunctionHoltWinters:
+		if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+			return fmt.Errorf("alpha must be in (0, 1] for function %q", cfg.Function)
+		}
+		if cfg.Beta < 0 || cfg.Beta > 1 {
+			return fmt.Errorf("beta must be in [0, 1] for function %q", cfg.Function)
+		}
+		if cfg.Gamma < 0 || cfg.Gamma > 1 {
+			return fmt.Errorf("gamma must be in [0, 1] for function %q", cfg.Function)
+		}
+		if cfg.Season < 0 || cfg.Season == 1 {
+			return errors.New("season must be 0 (no seasonality) or at least 2")
+		}
+	case localFunctionONNX:
+		return errors.New("function 'onnx' is not supported by the local backend yet; serve ONNX models with the inference server")
+	default:
+		return fmt.Errorf("invalid function %q (must be 'ewma', 'zscore', 'mad', 'linear_regression', or 'holt_winters')", cfg.Function)
+	}
+	if cfg.Horizon < 0 {
+		return errors.New("horizon must not be negative")
+	}
+	return nil
+}
+
+// ModelInfer evaluates the configured function for every element of the input
+// tensor. Elements are attributed to series by the keys attached with
+// withSeriesKeys, falling back to their position in the tensor.
+func (b *localBackend) ModelInfer(ctx context.Context, request *pb.ModelInferRequest, _ ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	values, err := requestInputValues(request, b.input)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := seriesKeysFromContext(ctx)
+	if len(keys) != len(values) {
+		keys = nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.generation++
+	results := make([]float64, len(values))
+	for i, value := range values {
+		key := uint64(i)
+		if keys != nil {
+			key = keys[i]
+		}
+		series, exists := b.series[key]
+		if !exists {
+			series = &localSeries{ewma: value}
+			b.series[key] = series
+		}
+		series.lastSeen = b.generation
+		results[i] = b.evaluate(series, value)
+	}
+	b.evictIdle()
+
+	return &pb.ModelInferResponse{
+		ModelName:    request.ModelName,
+		ModelVersion: request.ModelVersion,
+		Id:           request.Id,
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{
+				Name:     "prediction",
+				Datatype: "FP64",
+				Shape:    []int64{int64(len(results))},
+				Contents: &pb.InferTensorContents{Fp64Contents: results},
+			},
+		},
+	}, nil
+}
+
+// requestInputValues returns the values of a request's input tensor as float64
+func requestInputValues(request *pb.ModelInferRequest, name string) ([]float64, error) {
+	var tensor *pb.ModelInferRequest_InferInputTensor
+	for _, input := range request.Inputs {
+		if input.Name == name {
+			tensor = input
+			break
+		}
+	}
+	if tensor == nil || tensor.Contents == nil {
+		return nil, fmt.Errorf("input %q not found in request", name)
+	}
+	if values, ok := tensorValues(tensor.Contents); ok {
+		return values, nil
+	}
+	return nil, fmt.Errorf("input %q has no numeric contents", name)
+}
+
+// evaluate adds a value to a series and returns the function result for it.
+// The caller must hold b.mu.
+func (b *localBackend) evaluate(series *localSeries, value float64) float64 {
+	switch b.function {
+	case localFunctionEWMA:
+		series.ewma = b.alpha*value + (1-b.alpha)*series.ewma
+		return series.ewma
+	case localFunctionHoltWinters:
+		return b.holtWinters(series, value)
+	}
+
+	series.history = append(series.history, value)
+	if len(series.history) > b.window {
+		series.history = series.history[len(series.history)-b.window:]
+	}
+
+	switch b.function {
+	case localFunctionZScore:
+		return zScore(series.history, value)
+	case localFunctionMAD:
+		return madScore(series.history, value)
+	default:
+		return linearForecast(series.history, b.horizon)
+	}
+}
+
+// evictIdle drops series that have not been seen for attributeIndexIdleBatches
+// requests. The caller must hold b.mu.
+func (b *localBackend) evictIdle() {
+	for key, series := range b.series {
+		if b.generation-series.lastSeen > attributeIndexIdleBatches {
+			delete(b.series, key)
+		}
+	}
+}
+
+// zScore returns how many standard deviations value lies from the mean of
+// history, or 0 when history has no spread
+func zScore(history []float64, value float64) float64 {
+	var sum float64
+	for _, v := range history {
+		sum += v
+	}
+	mean := sum / float64(len(history))
+
+	var variance float64
+	for _, v := range history {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(history)))
+	if stddev == 0 {
+		return 0
+	}
+	return (value - mean) / stddev
+}
+
+// holtWinters adds a value to the level, trend and seasonal components of a
+// series with additive Holt-Winters smoothing, and returns their forecast
+// horizon steps ahead. Without a season it is Holt's linear trend method.
+// The caller must hold b.mu.
+func (b *localBackend) holtWinters(series *localSeries, value float64) float64 {
+	if b.season > 0 && series.seasonal == nil {
+		series.seasonal = make([]float64, b.season)
+	}
+	seasonal := func(step int) float64 {
+		if b.season == 0 {
+			return 0
+		}
+		return series.seasonal[step%b.season]
+	}
+
+	if series.steps == 0 {
+		series.level = value
+	} else {
+		level := b.alpha*(value-seasonal(series.steps)) + (1-b.alpha)*(series.level+series.trend)
+		series.trend = b.beta*(level-series.level) + (1-b.beta)*series.trend
+		series.level = level
+	}
+	if b.season > 0 {
+		pos := series.steps % b.season
+		series.seasonal[pos] = b.gamma*(value-series.level) + (1-b.gamma)*series.seasonal[pos]
+	}
+	series.steps++
+
+	return series.level + float64(b.horizon)*series.trend + seasonal(series.steps-1+b.horizon)
+}
+
+// madScore returns how far value lies from the median of history, in units of
+// the scaled median absolute deviation, or 0 when history has no spread. It is
+// robust to the outliers it detects inflating the spread.
+func madScore(history []float64, value float64) float64 {
+	median := medianOf(history)
+	deviations := make([]float64, len(history))
+	for i, v := range history {
+		deviations[i] = math.Abs(v - median)
+	}
+	mad := medianOf(deviations) * madScale
+	if mad == 0 {
+		return 0
+	}
+	return (value - median) / mad
+}
+
+// medianOf returns the median of values, leaving them unchanged
+func medianOf(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// linearForecast fits a least-squares line through history, indexed by
+// position, and extrapolates it horizon steps past the last value
+func linearForecast(history []float64, horizon int) float64 {
+	n := float64(len(history))
+	last := history[len(history)-1]
+	if len(history) < 2 {
+		return last
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range history {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+	return intercept + slope*(n-1+float64(horizon))
+}
@@ -0,0 +1,180 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func localRequest(values ...float64) *pb.ModelInferRequest {
+	return &pb.ModelInferRequest{
+		ModelName: "local",
+		Inputs: []*pb.ModelInferRequest_InferInputTensor{
+			{Name: "x", Contents: &pb.InferTensorContents{Fp64Contents: values}},
+		},
+	}
+}
+
+func localPredictions(t *testing.T, b *localBackend, ctx context.Context, values ...float64) []float64 {
+	resp, err := b.ModelInfer(ctx, localRequest(values...))
+	require.NoError(t, err)
+	require.Len(t, resp.Outputs, 1)
+	return resp.Outputs[0].Contents.Fp64Contents
+}
+
+func TestLocalBackendFunctions(t *testing.T) {
+	ctx := context.Background()
+
+	ewma := newLocalBackend(&LocalConfig{Function: "ewma", Alpha: 0.5}, "x")
+	assert.Equal(t, []float64{10}, localPredictions(t, ewma, ctx, 10))
+	assert.Equal(t, []float64{15}, localPredictions(t, ewma, ctx, 20))
+	assert.Equal(t, []float64{17.5}, localPredictions(t, ewma, ctx, 20))
+
+	zscore := newLocalBackend(&LocalConfig{Function: "zscore", Window: 4}, "x")
+	assert.Equal(t, []float64{0}, localPredictions(t, zscore, ctx, 5), "a single value has no spread")
+	localPredictions(t, zscore, ctx, 5)
+	localPredictions(t, zscore, ctx, 5)
+	assert.InDelta(t, 1.732, localPredictions(t, zscore, ctx, 9)[0], 0.001)
+
+	regression := newLocalBackend(&LocalConfig{Function: "linear_regression", Window: 3, Horizon: 2}, "x")
+	assert.Equal(t, []float64{1}, localPredictions(t, regression, ctx, 1))
+	localPredictions(t, regression, ctx, 2)
+	assert.InDelta(t, 5, localPredictions(t, regression, ctx, 3)[0], 1e-9)
+	// Only the window is used for the fit
+	assert.InDelta(t, 6, localPredictions(t, regression, ctx, 4)[0], 1e-9)
+
+	mad := newLocalBackend(&LocalConfig{Function: "mad", Window: 5}, "x")
+	assert.Equal(t, []float64{0}, localPredictions(t, mad, ctx, 1), "a single value has no spread")
+	for _, value := range []float64{2, 3, 4} {
+		localPredictions(t, mad, ctx, value)
+	}
+	// The outlier barely moves the median absolute deviation
+	assert.InDelta(t, 97/madScale, localPredictions(t, mad, ctx, 100)[0], 1e-9)
+}
+
+func TestLocalBackendHoltWinters(t *testing.T) {
+	ctx := context.Background()
+
+	// Without a season, the level and trend follow a straight line exactly
+	holt := newLocalBackend(&LocalConfig{Function: "holt_winters", Alpha: 1, Beta: 1}, "x")
+	assert.Equal(t, []float64{1}, localPredictions(t, holt, ctx, 1))
+	assert.Equal(t, []float64{3}, localPredictions(t, holt, ctx, 2))
+	assert.Equal(t, []float64{4}, localPredictions(t, holt, ctx, 3))
+
+	// With a season, the forecast anticipates the next value of the cycle
+	seasonal := newLocalBackend(&LocalConfig{Function: "holt_winters", Alpha: 0.5, Beta: 0.1, Gamma: 0.5, Season: 2}, "x")
+	var forecast float64
+	for i := 0; i < 40; i++ {
+		forecast = localPredictions(t, seasonal, ctx, []float64{10, 20}[i%2])[0]
+	}
+	assert.InDelta(t, 10, forecast, 0.5)
+
+	// The components are saved and restored with the rest of the series state
+	restored := newLocalBackend(&LocalConfig{Function: "holt_winters", Alpha: 0.5, Beta: 0.1, Gamma: 0.5, Season: 2}, "x")
+	restored.restore(seasonal.snapshot())
+	assert.Equal(t, localPredictions(t, seasonal, ctx, 10), localPredictions(t, restored, ctx, 10))
+}
+
+func TestLocalBackendSeriesKeys(t *testing.T) {
+	b := newLocalBackend(&LocalConfig{Function: "ewma", Alpha: 0.5}, "x")
+
+	group := func(host string) dataPointGroup {
+		attrs := pcommon.NewMap()
+		attrs.PutStr("host", host)
+		return dataPointGroup{attributes: attrs}
+	}
+
+	first := withSeriesKeys(context.Background(), pcommon.NewMap(), []dataPointGroup{group("a"), group("b")}, nil)
+	assert.Equal(t, []float64{10, 100}, localPredictions(t, b, first, 10, 100))
+
+	// Series keep their state when their position in the request changes
+	swapped := withSeriesKeys(context.Background(), pcommon.NewMap(), []dataPointGroup{group("b"), group("a")}, nil)
+	assert.Equal(t, []float64{50, 15}, localPredictions(t, b, swapped, 0, 20))
+
+	_, err := b.ModelInfer(context.Background(), &pb.ModelInferRequest{})
+	assert.ErrorContains(t, err, "input \"x\" not found")
+}
+
+func TestValidateLocalBackend(t *testing.T) {
+	local := func(cfg LocalConfig) Rule {
+		return Rule{ModelName: "m", Inputs: []string{"x"}, Backend: "local", Local: &cfg}
+	}
+
+	assert.NoError(t, validateLocalBackend(Rule{ModelName: "m", Inputs: []string{"x"}}))
+	assert.NoError(t, validateLocalBackend(local(LocalConfig{Function: "ewma", Alpha: 0.3})))
+	assert.NoError(t, validateLocalBackend(local(LocalConfig{Function: "zscore"})))
+	assert.NoError(t, validateLocalBackend(local(LocalConfig{Function: "linear_regression", Window: 5, Horizon: 3})))
+
+	assert.ErrorContains(t, validateLocalBackend(Rule{Backend: "onnx"}), "invalid backend")
+	assert.ErrorContains(t, validateLocalBackend(Rule{Local: &LocalConfig{Function: "ewma"}}), "requires backend 'local'")
+	assert.ErrorContains(t, validateLocalBackend(Rule{Inputs: []string{"x"}, Backend: "local"}), "requires a local configuration")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "ewma"})), "alpha must be in")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "zscore", Window: 1})), "window must be at least 2")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "median"})), "invalid function")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "onnx"})), "serve ONNX models with the inference server")
+	assert.NoError(t, validateLocalBackend(local(LocalConfig{Function: "mad", Window: 20})))
+	assert.NoError(t, validateLocalBackend(local(LocalConfig{Function: "holt_winters", Alpha: 0.5, Beta: 0.1, Gamma: 0.3, Season: 24})))
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "holt_winters"})), "alpha must be in")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "holt_winters", Alpha: 0.5, Beta: 2})), "beta must be in")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "holt_winters", Alpha: 0.5, Gamma: -1})), "gamma must be in")
+	assert.ErrorContains(t, validateLocalBackend(local(LocalConfig{Function: "holt_winters", Alpha: 0.5, Season: 1})), "season")
+
+	twoInputs := local(LocalConfig{Function: "ewma", Alpha: 0.3})
+	twoInputs.Inputs = []string{"x", "y"}
+	assert.ErrorContains(t, validateLocalBackend(twoInputs), "exactly one input")
+
+	sequenced := local(LocalConfig{Function: "ewma", Alpha: 0.3})
+	sequenced.Sequence.Enabled = true
+	assert.ErrorContains(t, validateLocalBackend(sequenced), "does not support sequences")
+
+	// Local-only configurations do not need an inference server
+	cfg := &Config{Rules: []Rule{local(LocalConfig{Function: "ewma", Alpha: 0.3})}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLocalRuleWithoutServer(t *testing.T) {
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{
+			{
+				ModelName:     "smoother",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "smoothed.{input}",
+				Backend:       "local",
+				Local:         &LocalConfig{Function: "ewma", Alpha: 0.5},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for _, value := range []float64{10, 30} {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{value}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	require.Len(t, sink.AllMetrics(), 2)
+
+	output := findMetricByName(sink.AllMetrics()[1], "smoothed.metric_1")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	assert.Equal(t, 20.0, output.Gauge().DataPoints().At(0).DoubleValue())
+}
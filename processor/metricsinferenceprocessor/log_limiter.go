@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// noRule is the rule index of log messages not tied to a rule
+const noRule = -1
+
+// logKey identifies a repeated log message
+type logKey struct {
+	rule    int
+	message string
+}
+
+// logEntry tracks the repeats of one log message
+type logEntry struct {
+	next       time.Time     // Time from which the message is logged again
+	interval   time.Duration // Current suppression interval, doubled on every repeat up to the maximum
+	lastSeen   time.Time     // Time of the latest occurrence, logged or not
+	suppressed int           // Occurrences suppressed since the message was last logged
+}
+
+// logLimiter deduplicates log messages that repeat every batch, such as a rule
+// whose inputs never arrive. The first occurrence of a message for a rule is
+// logged; repeats are suppressed for an interval that doubles each time the
+// message is logged again, up to a maximum. A logged repeat carries the number
+// of suppressed occurrences, so it doubles as a periodic summary. A message that
+// stays quiet for the maximum interval starts over from the base interval.
+type logLimiter struct {
+	logger      *zap.Logger
+	interval    time.Duration
+	maxInterval time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[logKey]*logEntry
+}
+
+// newLogLimiter creates a log limiter. A non-positive interval disables limiting.
+func newLogLimiter(logger *zap.Logger, cfg LoggingConfig) *logLimiter {
+	maxInterval := cfg.MaxRepeatInterval
+	if maxInterval < cfg.RepeatInterval {
+		maxInterval = cfg.RepeatInterval
+	}
+	return &logLimiter{
+		logger:      logger,
+		interval:    cfg.RepeatInterval,
+		maxInterval: maxInterval,
+		now:         time.Now,
+		entries:     make(map[logKey]*logEntry),
+	}
+}
+
+// Warn logs a warning for a rule unless it is a suppressed repeat
+func (l *logLimiter) Warn(rule int, msg string, fields ...zap.Field) {
+	l.log(zapcore.WarnLevel, rule, msg, fields)
+}
+
+// Error logs an error for a rule unless it is a suppressed repeat
+func (l *logLimiter) Error(rule int, msg string, fields ...zap.Field) {
+	l.log(zapcore.ErrorLevel, rule, msg, fields)
+}
+
+// log writes a message at the given level if the limiter allows it
+func (l *logLimiter) log(level zapcore.Level, rule int, msg string, fields []zap.Field) {
+	suppressed, ok := l.allow(logKey{rule: rule, message: msg})
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		fields = append(fields, zap.Int("suppressed_count", suppressed))
+	}
+	if ce := l.logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// allow records an occurrence of a message and reports whether to log it,
+// along with the number of occurrences suppressed since it was last logged
+func (l *logLimiter) allow(key logKey) (int, bool) {
+	if l.interval <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	entry, exists := l.entries[key]
+	if !exists || now.Sub(entry.lastSeen) >= l.maxInterval {
+		l.entries[key] = &logEntry{
+			next:     now.Add(l.interval),
+			interval: l.interval,
+			lastSeen: now,
+		}
+		return 0, true
+	}
+
+	entry.lastSeen = now
+	if now.Before(entry.next) {
+		entry.suppressed++
+		return 0, false
+	}
+
+	suppressed := entry.suppressed
+	entry.suppressed = 0
+	entry.interval = min(entry.interval*2, l.maxInterval)
+	entry.next = now.Add(entry.interval)
+	return suppressed, true
+}
@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestLogLimiterBackoff(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	limiter := newLogLimiter(zap.New(core), LoggingConfig{RepeatInterval: time.Minute, MaxRepeatInterval: 4 * time.Minute})
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	// Occurrences every 30s: logged at 0, then after 1m, 2m and 4m (capped) intervals
+	var loggedAt []time.Duration
+	for i := 0; i <= 24; i++ {
+		before := logs.Len()
+		limiter.Warn(0, "No input metrics found for inference rule")
+		if logs.Len() > before {
+			loggedAt = append(loggedAt, now.Sub(time.Unix(0, 0)))
+		}
+		now = now.Add(30 * time.Second)
+	}
+	assert.Equal(t, []time.Duration{0, time.Minute, 3 * time.Minute, 7 * time.Minute, 11 * time.Minute}, loggedAt)
+
+	entries := logs.All()
+	assert.NotContains(t, entries[0].ContextMap(), "suppressed_count")
+	assert.Equal(t, int64(1), entries[1].ContextMap()["suppressed_count"])
+	assert.Equal(t, int64(3), entries[2].ContextMap()["suppressed_count"])
+	assert.Equal(t, int64(7), entries[3].ContextMap()["suppressed_count"])
+
+	// Other rules and messages are limited independently
+	limiter.Warn(1, "No input metrics found for inference rule")
+	limiter.Error(0, "Failed to perform inference")
+	assert.Equal(t, 7, logs.Len())
+
+	// A message that stays quiet for the maximum interval starts over
+	now = now.Add(10 * time.Minute)
+	limiter.Warn(0, "No input metrics found for inference rule")
+	limiter.Warn(0, "No input metrics found for inference rule")
+	assert.Equal(t, 8, logs.Len())
+	assert.NotContains(t, logs.All()[7].ContextMap(), "suppressed_count")
+}
+
+func TestLogLimiterDisabled(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	limiter := newLogLimiter(zap.New(core), LoggingConfig{})
+
+	for i := 0; i < 3; i++ {
+		limiter.Warn(0, "No input metrics found for inference rule")
+	}
+	assert.Equal(t, 3, logs.Len())
+}
+
+func TestMissingInputWarningsAreLimited(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	cfg := &Config{
+		Timeout: 5,
+		Logging: LoggingConfig{RepeatInterval: time.Hour},
+		Rules: []Rule{
+			{
+				ModelName: "forecaster",
+				Inputs:    []string{"missing.metric"},
+				Synthetic: &SyntheticConfig{Function: "constant"},
+			},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zap.New(core))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for i := 0; i < 5; i++ {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	assert.Equal(t, 1, logs.FilterMessage("No input metrics found for inference rule").Len())
+}
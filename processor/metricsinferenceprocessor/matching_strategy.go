@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/collector/featuregate"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// How the data points of a rule's inputs are matched into attribute groups
+const (
+	matchingBroadcast = "broadcast" // inputs with a single attribute set join every group
+	matchingStrict    = "strict"    // only identical attribute sets are matched
+	matchingCartesian = "cartesian" // every combination of the inputs' attribute sets is a group
+)
+
+// broadcastMatchingGate selects broadcast matching for rules without a
+// matching_strategy. Disabling it restores the strict matching of earlier
+// releases, whose output cardinality broadcasting changed.
+var broadcastMatchingGate = featuregate.GlobalRegistry().MustRegister(
+	"processor.metricsinference.broadcastMatching",
+	featuregate.StageBeta,
+	featuregate.WithRegisterDescription("When enabled, inputs of metrics inference rules with a single attribute set are "+
+		"broadcast to the attribute groups of the other inputs; when disabled, only identical attribute sets are matched"))
+
+// validateMatchingStrategy checks a rule's matching_strategy
+func validateMatchingStrategy(strategy string) error {
+	switch strategy {
+	case "", matchingBroadcast, matchingStrict, matchingCartesian:
+		return nil
+	}
+	return fmt.Errorf("invalid matching_strategy %q (must be 'broadcast', 'strict', or 'cartesian')", strategy)
+}
+
+// matchingStrategy returns the strategy a rule's inputs are matched with: its own,
+// or the one selected by the broadcast matching feature gate
+func (rule internalRule) matchingStrategy() string {
+	if rule.matching != "" {
+		return rule.matching
+	}
+	if broadcastMatchingGate.IsEnabled() {
+		return matchingBroadcast
+	}
+	return matchingStrict
+}
+
+// cartesianGroups builds a group for every combination of the attribute sets of
+// the inputs, in key order, carrying the attributes of all its data points. The
+// number of combinations is the product of the inputs' attribute sets, so only
+// the first rule.maxCartesian are built, and the others are counted in
+// idx.skipped before any request is built from them. The caller must hold idx.mu.
+func (idx *attributeGroupIndex) cartesianGroups(perInput []*inputSlots, inputs map[string]pmetric.Metric, rule internalRule) []dataPointGroup {
+	if len(perInput) == 0 {
+		return nil
+	}
+
+	limit := rule.maxCartesian
+	if limit <= 0 {
+		limit = defaultMaxCartesianGroups
+	}
+	combinations := 1
+	for _, in := range perInput {
+		if combinations > math.MaxInt/in.groupCount {
+			combinations = math.MaxInt
+			break
+		}
+		combinations *= in.groupCount
+	}
+
+	var matchedGroups []dataPointGroup
+	visited := 0
+	chosen := make([]dataPoint, len(perInput))
+	var combine func(i int)
+	combine = func(i int) {
+		if i == len(perInput) {
+			visited++
+			group := dataPointGroup{
+				attributes: pcommon.NewMap(),
+				dataPoints: make(map[string]dataPoint, len(perInput)),
+			}
+			for j, in := range perInput {
+				group.dataPoints[in.name] = chosen[j]
+				chosen[j].Attributes().Range(func(k string, v pcommon.Value) bool {
+					if _, exists := group.attributes.Get(k); !exists {
+						v.CopyTo(group.attributes.PutEmpty(k))
+					}
+					return true
+				})
+			}
+			if fillPartialGroup(group, group.attributes, inputs, rule) {
+				matchedGroups = append(matchedGroups, group)
+			}
+			return
+		}
+		in := perInput[i]
+		for _, slot := range idx.ordered {
+			if visited == limit {
+				return
+			}
+			if in.has(slot) {
+				chosen[i] = in.dataPoints[slot.pos]
+				combine(i + 1)
+			}
+		}
+	}
+	combine(0)
+	idx.skipped += combinations - visited
+	return matchedGroups
+}
@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/featuregate"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestMatchingStrategies(t *testing.T) {
+	// A per-host metric and a cluster-wide metric with a single attribute set
+	inputs := map[string]pmetric.Metric{
+		"cpu":   newAttributedGauge("cpu", []map[string]string{{"host": "a"}, {"host": "b"}}),
+		"quota": newAttributedGauge("quota", []map[string]string{{"cluster": "east"}}),
+	}
+
+	tests := []struct {
+		strategy string
+		expected []map[string]interface{}
+	}{
+		{
+			strategy: matchingBroadcast,
+			expected: []map[string]interface{}{
+				{"_attrs": "host=a", "cpu": "host=a=0", "quota": "cluster=east=0"},
+				{"_attrs": "host=b", "cpu": "host=b=1", "quota": "cluster=east=0"},
+			},
+		},
+		{
+			strategy: matchingStrict,
+			expected: []map[string]interface{}{},
+		},
+		{
+			strategy: matchingCartesian,
+			expected: []map[string]interface{}{
+				{"_attrs": "cluster=east,host=a", "cpu": "host=a=0", "quota": "cluster=east=0"},
+				{"_attrs": "cluster=east,host=b", "cpu": "host=b=1", "quota": "cluster=east=0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			rule := internalRule{inputs: []string{"cpu", "quota"}, matching: tt.strategy}
+			assert.Equal(t, tt.expected, groupsSummary(newAttributeGroupIndex().match(inputs, rule)))
+		})
+	}
+}
+
+func TestStrictMatching(t *testing.T) {
+	inputs := map[string]pmetric.Metric{
+		"a": newAttributedGauge("a", []map[string]string{{"cpu": "0"}}),
+		"b": newAttributedGauge("b", []map[string]string{{"cpu": "0"}, {"cpu": "1"}}),
+	}
+
+	// Identical attribute sets are still matched
+	rule := internalRule{inputs: []string{"a", "b"}, matching: matchingStrict}
+	assert.Equal(t, []map[string]interface{}{
+		{"_attrs": "cpu=0", "a": "cpu=0=0", "b": "cpu=0=0"},
+	}, groupsSummary(newAttributeGroupIndex().match(inputs, rule)))
+
+	// Groups lacking inputs are filled rather than broadcast to
+	rule.partialGroups = partialGroupsFillZero
+	assert.Equal(t, []map[string]interface{}{
+		{"_attrs": "cpu=0", "a": "cpu=0=0", "b": "cpu=0=0"},
+		{"_attrs": "cpu=1", "a": "cpu=1=0", "b": "cpu=1=1"},
+	}, groupsSummary(newAttributeGroupIndex().match(inputs, rule)))
+}
+
+func TestCartesianMatching(t *testing.T) {
+	inputs := map[string]pmetric.Metric{
+		"a": newAttributedGauge("a", []map[string]string{{"host": "x"}, {"host": "y"}}),
+		"b": newAttributedGauge("b", []map[string]string{{"disk": "0"}, {"disk": "1"}, {"disk": "2"}}),
+	}
+	rule := internalRule{inputs: []string{"a", "b"}, matching: matchingCartesian}
+	groups := newAttributeGroupIndex().match(inputs, rule)
+	require.Len(t, groups, 6)
+	assert.Equal(t, map[string]interface{}{"_attrs": "disk=2,host=y", "a": "host=y=1", "b": "disk=2=2"}, groupsSummary(groups)[5])
+
+	// Combinations past the group limit are left out, in key order, and counted
+	rule.maxCartesian = 4
+	idx := newAttributeGroupIndex()
+	groups = idx.match(inputs, rule)
+	require.Len(t, groups, 4)
+	assert.Equal(t, map[string]interface{}{"_attrs": "disk=0,host=y", "a": "host=y=1", "b": "disk=0=0"}, groupsSummary(groups)[3])
+	assert.Equal(t, 2, idx.takeSkipped())
+	assert.Zero(t, idx.takeSkipped())
+}
+
+func TestBroadcastMatchingGate(t *testing.T) {
+	inputs := map[string]pmetric.Metric{
+		"cpu":   newAttributedGauge("cpu", []map[string]string{{"host": "a"}, {"host": "b"}}),
+		"quota": newAttributedGauge("quota", []map[string]string{{"cluster": "east"}}),
+	}
+	rule := internalRule{inputs: []string{"cpu", "quota"}}
+	assert.Equal(t, matchingBroadcast, rule.matchingStrategy())
+	assert.Len(t, newAttributeGroupIndex().match(inputs, rule), 2)
+
+	// Disabling the gate restores strict matching, unless the rule selects a strategy
+	require.NoError(t, featuregate.GlobalRegistry().Set(broadcastMatchingGate.ID(), false))
+	defer func() {
+		require.NoError(t, featuregate.GlobalRegistry().Set(broadcastMatchingGate.ID(), true))
+	}()
+	assert.Equal(t, matchingStrict, rule.matchingStrategy())
+	assert.Empty(t, newAttributeGroupIndex().match(inputs, rule))
+	rule.matching = matchingBroadcast
+	assert.Len(t, newAttributeGroupIndex().match(inputs, rule), 2)
+}
+
+func TestValidateMatchingStrategy(t *testing.T) {
+	for _, strategy := range []string{"", matchingBroadcast, matchingStrict, matchingCartesian} {
+		assert.NoError(t, validateMatchingStrategy(strategy))
+	}
+	assert.ErrorContains(t, validateMatchingStrategy("nearest"), "invalid matching_strategy")
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"cpu", "quota"}, MatchingStrategy: "nearest"}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid matching_strategy in rule 0")
+}
@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+const (
+	// defaultMetadataConcurrency is the number of models whose metadata is
+	// queried at once when none is configured
+	defaultMetadataConcurrency = 4
+
+	// validationFailuresBeforeRefresh is the number of consecutive input
+	// validation failures of a model after which its metadata is queried again,
+	// as the model may have been redeployed with a new signature
+	validationFailuresBeforeRefresh = 3
+)
+
+// fetchModelsMetadata queries the metadata of several models concurrently, at
+// most metadata.concurrency at once. It returns the responses and the errors of
+// the models whose query failed, by model name.
+func (mp *metricsinferenceprocessor) fetchModelsMetadata(ctx context.Context, client pb.GRPCInferenceServiceClient, models map[string]string) (map[string]*pb.ModelMetadataResponse, map[string]error) {
+	concurrency := mp.config.Metadata.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMetadataConcurrency
+	}
+
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		fetched = make(map[string]*pb.ModelMetadataResponse, len(models))
+		failed  = make(map[string]error)
+		slots   = make(chan struct{}, concurrency)
+	)
+	for modelName, modelVersion := range models {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			resp, err := mp.fetchModelMetadata(ctx, client, modelName, modelVersion)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed[modelName] = err
+				return
+			}
+			fetched[modelName] = resp
+		}()
+	}
+	wg.Wait()
+	return fetched, failed
+}
+
+// expired reports whether cached metadata is older than the configured TTL.
+// Metadata never expires without a TTL.
+func (m *modelMetadata) expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(m.fetchedAt) >= ttl
+}
+
+// staleMetadata tracks the models whose metadata is to be queried again lazily,
+// because their cached metadata expired or their rules' inputs repeatedly fail
+// validation against it. Each queued model is queried in a goroutine of its
+// own, so no batch waits for the inference server's metadata.
+type staleMetadata struct {
+	lock     sync.Mutex
+	pending  map[string]bool // Models being queried
+	failures map[string]int  // Consecutive input validation failures, by model
+
+	client  pb.GRPCInferenceServiceClient // Client models are queried with, nil until started
+	ctx     context.Context
+	cancel  context.CancelFunc
+	queries sync.WaitGroup
+}
+
+func newStaleMetadata() *staleMetadata {
+	return &staleMetadata{
+		pending:  make(map[string]bool),
+		failures: make(map[string]int),
+	}
+}
+
+// start lets queued models be queried with the client
+func (s *staleMetadata) start(client pb.GRPCInferenceServiceClient) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if client == nil || s.client != nil {
+		return
+	}
+	s.client = client
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+// stop cancels the queries in progress and waits for them to exit
+func (s *staleMetadata) stop() {
+	s.lock.Lock()
+	if s.client == nil {
+		s.lock.Unlock()
+		return
+	}
+	s.cancel()
+	s.client = nil
+	s.lock.Unlock()
+	s.queries.Wait()
+}
+
+// queue queries a model's metadata in the background with refresh, unless a
+// query of it is already in progress or querying is not started
+func (s *staleMetadata) queue(modelName string, refresh func(ctx context.Context, client pb.GRPCInferenceServiceClient, modelName string)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.client == nil || s.pending[modelName] {
+		return
+	}
+	s.pending[modelName] = true
+
+	ctx, client := s.ctx, s.client
+	s.queries.Add(1)
+	go func() {
+		defer s.queries.Done()
+		refresh(ctx, client, modelName)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.pending, modelName)
+	}()
+}
+
+// validated records the outcome of validating a rule's inputs against its
+// model's metadata. It reports whether the model failed validation often enough
+// in a row that its metadata should be queried again.
+func (s *staleMetadata) validated(modelName string, err error) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err == nil {
+		delete(s.failures, modelName)
+		return false
+	}
+	s.failures[modelName]++
+	if s.failures[modelName] < validationFailuresBeforeRefresh {
+		return false
+	}
+	delete(s.failures, modelName)
+	return true
+}
+
+// checkMetadataFreshness queues a refresh of a model's metadata when its cached
+// metadata expired, or when the rule's inputs just failed validation against it
+// too many times in a row. The caller must hold mp.metadataLock.
+func (mp *metricsinferenceprocessor) checkMetadataFreshness(modelName string, validationErr error) {
+	if mp.stale.validated(modelName, validationErr) {
+		mp.logger.Info("Inputs repeatedly failed validation against model metadata, querying it again",
+			zap.String("model", modelName))
+		mp.stale.queue(modelName, mp.refreshStaleModel)
+		return
+	}
+	if metadata, ok := mp.modelMetadata[modelName]; ok && metadata.expired(mp.config.Metadata.TTL, time.Now()) {
+		mp.stale.queue(modelName, mp.refreshStaleModel)
+	}
+}
+
+// refreshStaleModel queries a model's metadata again. Changed metadata replaces
+// the cached metadata and rebuilds the model's discovered inputs and outputs;
+// unchanged metadata is kept for another TTL. A failed query keeps the cached
+// metadata until the model is queued again.
+func (mp *metricsinferenceprocessor) refreshStaleModel(ctx context.Context, client pb.GRPCInferenceServiceClient, modelName string) {
+	mp.metadataLock.RLock()
+	modelVersion, served := mp.serverModels()[modelName]
+	mp.metadataLock.RUnlock()
+	if !served {
+		return
+	}
+
+	resp, err := mp.fetchModelMetadata(ctx, client, modelName, modelVersion)
+	if err != nil {
+		mp.logger.Debug("Failed to refresh stale metadata for model",
+			zap.String("model", modelName),
+			zap.Error(err))
+		return
+	}
+
+	mp.metadataLock.Lock()
+	defer mp.metadataLock.Unlock()
+	if cached, exists := mp.modelMetadata[modelName]; exists && !cached.changed(resp) {
+		cached.fetchedAt = time.Now()
+		return
+	}
+	mp.applyModelMetadata(ctx, map[string]*pb.ModelMetadataResponse{modelName: resp})
+}
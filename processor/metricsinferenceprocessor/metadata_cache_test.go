@@ -0,0 +1,205 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// slowMetadataClient answers metadata queries after a delay, tracking how many
+// are in flight at once
+type slowMetadataClient struct {
+	pb.GRPCInferenceServiceClient // Calls not overridden are not expected
+	inFlight                      atomic.Int32
+	maxInFlight                   atomic.Int32
+}
+
+func (c *slowMetadataClient) ModelMetadata(_ context.Context, req *pb.ModelMetadataRequest, _ ...grpc.CallOption) (*pb.ModelMetadataResponse, error) {
+	current := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		highest := c.maxInFlight.Load()
+		if current <= highest || c.maxInFlight.CompareAndSwap(highest, current) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if req.Name == "missing" {
+		return nil, errors.New("model not found")
+	}
+	return &pb.ModelMetadataResponse{Name: req.Name, Versions: []string{"1"}}, nil
+}
+
+func TestFetchModelsMetadata(t *testing.T) {
+	client := &slowMetadataClient{}
+	mp := &metricsinferenceprocessor{
+		config: &Config{Timeout: 5, Metadata: MetadataConfig{Concurrency: 2}},
+		logger: zaptest.NewLogger(t),
+	}
+
+	models := map[string]string{"a": "", "b": "", "c": "", "d": "", "e": "", "missing": ""}
+	fetched, failed := mp.fetchModelsMetadata(context.Background(), client, models)
+	assert.Len(t, fetched, 5)
+	assert.Equal(t, "c", fetched["c"].Name)
+	require.Len(t, failed, 1)
+	assert.ErrorContains(t, failed["missing"], "model not found")
+
+	assert.Equal(t, int32(2), client.maxInFlight.Load(), "queries run concurrently, at most concurrency at once")
+}
+
+func TestStaleMetadata(t *testing.T) {
+	stale := newStaleMetadata()
+	var refreshes atomic.Int32
+	release := make(chan struct{})
+	refresh := func(context.Context, pb.GRPCInferenceServiceClient, string) {
+		refreshes.Add(1)
+		<-release
+	}
+
+	// Models are not queried before querying is started
+	stale.queue("scorer", refresh)
+	assert.Equal(t, int32(0), refreshes.Load())
+
+	// A model is queried once at a time
+	stale.start(&slowMetadataClient{})
+	stale.queue("scorer", refresh)
+	stale.queue("scorer", refresh)
+	close(release)
+	stale.stop()
+	assert.Equal(t, int32(1), refreshes.Load())
+	assert.Empty(t, stale.pending)
+
+	// Only consecutive validation failures ask for a refresh
+	failure := errors.New("shape mismatch")
+	assert.False(t, stale.validated("scorer", failure))
+	assert.False(t, stale.validated("scorer", failure))
+	assert.False(t, stale.validated("scorer", nil))
+	for i := 1; i < validationFailuresBeforeRefresh; i++ {
+		assert.False(t, stale.validated("scorer", failure))
+	}
+	assert.True(t, stale.validated("scorer", failure))
+	assert.False(t, stale.validated("scorer", failure), "the count starts over after a refresh")
+
+	// Expiry
+	metadata := &modelMetadata{fetchedAt: time.Now().Add(-time.Minute)}
+	assert.False(t, metadata.expired(0, time.Now()), "metadata never expires without a TTL")
+	assert.True(t, metadata.expired(time.Second, time.Now()))
+	assert.False(t, metadata.expired(time.Hour, time.Now()))
+}
+
+func TestLazyMetadataRefresh(t *testing.T) {
+	twoInputs := &pb.ModelMetadataResponse{
+		Name:     "scorer",
+		Versions: []string{"1"},
+		Inputs: []*pb.ModelMetadataResponse_TensorMetadata{
+			{Name: "metric_1", Datatype: "FP64", Shape: []int64{1}},
+			{Name: "metric_2", Datatype: "FP64", Shape: []int64{1}},
+		},
+	}
+	oneInput := &pb.ModelMetadataResponse{
+		Name:     "scorer",
+		Versions: []string{"2"},
+		Inputs:   twoInputs.Inputs[:1],
+	}
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// initial is the model's metadata at startup, redeployed after it
+		initial *pb.ModelMetadataResponse
+		infers  bool
+	}{
+		{
+			// Expired metadata is queried again while batches keep inferring
+			name:    "expired",
+			ttl:     20 * time.Millisecond,
+			initial: scorerMetadata("1", "calculated_output"),
+			infers:  true,
+		},
+		{
+			// Metadata the rule's inputs keep failing against is queried again
+			name:    "validation failures",
+			initial: twoInputs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelMetadata("scorer", tt.initial),
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.5)))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Metadata:           MetadataConfig{TTL: tt.ttl},
+				Rules: []Rule{
+					{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{output}"},
+				},
+			}
+			require.NoError(t, cfg.Validate())
+
+			processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			mockServer.SetModelMetadata("scorer", oneInput)
+			time.Sleep(2 * tt.ttl)
+			consume := func() {
+				input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"metric_1"}, MetricValues: [][]float64{{1}}})
+				require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+			}
+			for i := 0; i < validationFailuresBeforeRefresh; i++ {
+				consume()
+			}
+			if tt.infers {
+				assert.Len(t, mockServer.GetRequests(), validationFailuresBeforeRefresh)
+			} else {
+				assert.Empty(t, mockServer.GetRequests(), "inputs fail validation against the initial metadata")
+			}
+
+			require.Eventually(t, func() bool {
+				processor.metadataLock.RLock()
+				defer processor.metadataLock.RUnlock()
+				metadata, ok := processor.modelMetadata["scorer"]
+				return ok && len(metadata.versions) == 1 && metadata.versions[0] == "2"
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// Batches infer against the refreshed metadata
+			requests := len(mockServer.GetRequests())
+			consume()
+			assert.Len(t, mockServer.GetRequests(), requests+1)
+		})
+	}
+}
+
+func TestValidateMetadataCache(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}}},
+	}
+	cfg.Metadata.Concurrency = -1
+	assert.ErrorContains(t, cfg.Validate(), "metadata.concurrency must not be negative")
+	cfg.Metadata.Concurrency = 8
+	cfg.Metadata.TTL = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "metadata.ttl must not be negative")
+	cfg.Metadata.TTL = time.Minute
+	assert.NoError(t, cfg.Validate())
+}
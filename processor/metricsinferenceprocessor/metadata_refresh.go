@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// newModelMetadata creates cached metadata from a metadata response
+func newModelMetadata(resp *pb.ModelMetadataResponse) *modelMetadata {
+	return &modelMetadata{
+		versions:  resp.Versions,
+		inputs:    resp.Inputs,
+		outputs:   resp.Outputs,
+		fetchedAt: time.Now(),
+	}
+}
+
+// changed reports whether a metadata response differs from the cached metadata,
+// either in the served versions or in the input and output signature
+func (m *modelMetadata) changed(resp *pb.ModelMetadataResponse) bool {
+	if !slices.Equal(m.versions, resp.Versions) {
+		return true
+	}
+	return !tensorMetadataEqual(m.inputs, resp.Inputs) || !tensorMetadataEqual(m.outputs, resp.Outputs)
+}
+
+// tensorMetadataEqual compares two tensor signatures element by element
+func tensorMetadataEqual(a, b []*pb.ModelMetadataResponse_TensorMetadata) bool {
+	return slices.EqualFunc(a, b, func(x, y *pb.ModelMetadataResponse_TensorMetadata) bool {
+		return proto.Equal(x, y)
+	})
+}
+
+// serverModels returns the models served by the inference server, keyed by
+// name with the configured (pinned) version
+func (mp *metricsinferenceprocessor) serverModels() map[string]string {
+	models := make(map[string]string) // model name -> version
+	for _, rule := range mp.rules {
+		if rule.inProcess() {
+			continue // In-process rules have no server-side model
+		}
+		if !rule.enabled {
+			continue // Rules disabled in the configuration never infer
+		}
+		if rule.modelName == "" {
+			continue // The model selector has not resolved to a model yet
+		}
+		models[rule.modelName] = rule.modelVersion
+	}
+	return models
+}
+
+// fetchModelMetadata queries the metadata of one model with the configured headers and timeout
+func (mp *metricsinferenceprocessor) fetchModelMetadata(ctx context.Context, client pb.GRPCInferenceServiceClient, modelName, modelVersion string) (*pb.ModelMetadataResponse, error) {
+	ctx = mp.headers.withStatic(ctx)
+
+	timeoutDuration := 5 * time.Second
+	if mp.config.Timeout > 0 {
+		timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
+	return client.ModelMetadata(ctx, &pb.ModelMetadataRequest{
+		Name:    modelName,
+		Version: modelVersion,
+	})
+}
+
+// startMetadataRefresh polls model metadata in the background at the configured
+// interval. It is a no-op when refreshing is disabled.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) startMetadataRefresh(client pb.GRPCInferenceServiceClient) {
+	interval := mp.config.Metadata.RefreshInterval
+	if interval <= 0 || client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	mp.refreshCancel = cancel
+	mp.refreshDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mp.refreshModelMetadata(ctx, client)
+			}
+		}
+	}()
+}
+
+// stopMetadataRefresh stops the background metadata refresh and waits for it to exit.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) stopMetadataRefresh() {
+	if mp.refreshCancel == nil {
+		return
+	}
+	mp.refreshCancel()
+	<-mp.refreshDone
+	mp.refreshCancel = nil
+	mp.refreshDone = nil
+}
+
+// refreshModelMetadata re-resolves model selectors and re-queries the metadata of
+// every server model and, for models whose version or signature changed or that
+// a selector switched to, replaces the cached metadata and rebuilds their
+// discovered inputs and outputs. Failed queries keep the cached metadata.
+func (mp *metricsinferenceprocessor) refreshModelMetadata(ctx context.Context, client pb.GRPCInferenceServiceClient) {
+	selected := mp.selectModels(ctx, client)
+	mp.metadataLock.Lock()
+	reselected := mp.applySelectedModels(selected)
+	mp.metadataLock.Unlock()
+
+	mp.metadataLock.RLock()
+	models := mp.serverModels()
+	mp.metadataLock.RUnlock()
+
+	fetched, failed := mp.fetchModelsMetadata(ctx, client, models)
+	for modelName, err := range failed {
+		mp.logger.Debug("Failed to refresh metadata for model",
+			zap.String("model", modelName),
+			zap.Error(err))
+	}
+	if len(failed) == 0 {
+		// A failed startup discovery has recovered
+		mp.reportMetadataStatus(nil)
+	}
+
+	mp.metadataLock.Lock()
+	defer mp.metadataLock.Unlock()
+
+	// Unchanged metadata is kept for another TTL
+	changed := make(map[string]*pb.ModelMetadataResponse)
+	now := time.Now()
+	for modelName, resp := range fetched {
+		if cached, exists := mp.modelMetadata[modelName]; exists && !cached.changed(resp) {
+			cached.fetchedAt = now
+			continue
+		}
+		changed[modelName] = resp
+	}
+	if len(changed) == 0 && !reselected {
+		return
+	}
+	mp.applyModelMetadata(ctx, changed)
+}
+
+// applyModelMetadata replaces the cached metadata of models whose metadata
+// changed or was fetched again, and rebuilds the discovered inputs and outputs
+// of their rules. The caller must hold mp.metadataLock for writing.
+func (mp *metricsinferenceprocessor) applyModelMetadata(ctx context.Context, changed map[string]*pb.ModelMetadataResponse) {
+	for modelName, resp := range changed {
+		var previous []string
+		if cached, exists := mp.modelMetadata[modelName]; exists {
+			previous = cached.versions
+		}
+		mp.modelMetadata[modelName] = newModelMetadata(resp)
+		mp.telemetry.recordModelMetadataChange(ctx, modelName)
+		mp.logger.Info("Model metadata changed, refreshing inputs and outputs",
+			zap.String("model", modelName),
+			zap.Strings("previous_versions", previous),
+			zap.Strings("versions", resp.Versions))
+
+		// Results of the previous version must not answer requests to the new one
+		if mp.resultCache != nil {
+			if purged := mp.resultCache.purge(modelName); purged > 0 {
+				mp.logger.Debug("Purged cached inference results of model",
+					zap.String("model", modelName),
+					zap.Int("entries", purged))
+			}
+		}
+
+		// Outputs discovered from the old signature are rebuilt from the new one
+		for ruleIdx := range mp.rules {
+			rule := &mp.rules[ruleIdx]
+			if rule.modelName != modelName || rule.inProcess() {
+				continue
+			}
+			rule.outputs = slices.DeleteFunc(rule.outputs, func(output internalOutputSpec) bool {
+				return output.discovered
+			})
+		}
+	}
+
+	mp.mergeDiscoveredInputs()
+	mp.checkInputMaps()
+	mp.mergeDiscoveredOutputs()
+
+	if err := mp.updateRuleGraph(); err != nil {
+		mp.logger.Error("Refreshed model inputs or outputs create invalid rule dependencies, keeping the previous rule order",
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func scorerMetadata(version string, outputs ...string) *pb.ModelMetadataResponse {
+	resp := &pb.ModelMetadataResponse{Name: "scorer", Versions: []string{version}}
+	for _, name := range outputs {
+		resp.Outputs = append(resp.Outputs, &pb.ModelMetadataResponse_TensorMetadata{
+			Name:     name,
+			Datatype: "FP64",
+			Shape:    []int64{1},
+		})
+	}
+	return resp
+}
+
+func ruleOutputNames(rule internalRule) []string {
+	names := make([]string, 0, len(rule.outputs))
+	for _, output := range rule.outputs {
+		names = append(names, output.name)
+	}
+	return names
+}
+
+func TestModelMetadataChanged(t *testing.T) {
+	cached := newModelMetadata(scorerMetadata("1", "score"))
+
+	assert.False(t, cached.changed(scorerMetadata("1", "score")))
+	assert.True(t, cached.changed(scorerMetadata("2", "score")), "a new version is a change")
+	assert.True(t, cached.changed(scorerMetadata("1", "score", "confidence")), "a new output is a change")
+
+	reshaped := scorerMetadata("1", "score")
+	reshaped.Outputs[0].Shape = []int64{-1, 3}
+	assert.True(t, cached.changed(reshaped), "a new shape is a change")
+}
+
+func TestRefreshModelMetadata(t *testing.T) {
+	mockServer := testutil.StartMockServer(t, testutil.WithModelMetadata("scorer", scorerMetadata("1", "score")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{model}.{output}"},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"scorer.score"}, ruleOutputNames(processor.rules[0]))
+
+	// An unchanged model keeps its outputs
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Equal(t, []string{"scorer.score"}, ruleOutputNames(processor.rules[0]))
+
+	// A redeployed model replaces its discovered outputs
+	mockServer.SetModelMetadata("scorer", scorerMetadata("2", "score", "confidence"))
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Equal(t, []string{"scorer.score", "scorer.confidence"}, ruleOutputNames(processor.rules[0]))
+	assert.Equal(t, []string{"2"}, processor.modelMetadata["scorer"].versions)
+}
+
+func TestMetadataRefreshLoop(t *testing.T) {
+	mockServer := testutil.StartMockServer(t, testutil.WithModelMetadata("scorer", scorerMetadata("1", "score")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Metadata:           MetadataConfig{RefreshInterval: 10 * time.Millisecond},
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{output}"},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+
+	mockServer.SetModelMetadata("scorer", scorerMetadata("2", "anomaly"))
+	require.Eventually(t, func() bool {
+		processor.metadataLock.RLock()
+		defer processor.metadataLock.RUnlock()
+		names := ruleOutputNames(processor.rules[0])
+		return len(names) == 1 && names[0] == "anomaly"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, processor.Shutdown(context.Background()))
+	assert.Nil(t, processor.refreshCancel, "shutdown should stop the refresh")
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Where the outputs of a rule whose inputs span several scopes are added
+const (
+	mixedScopesFirst     = "first"     // the scope of the rule's first input (default)
+	mixedScopesDedicated = "dedicated" // the dedicated inference scope of the first input's resource
+	mixedScopesDuplicate = "duplicate" // a copy in the scope of every input
+)
+
+// validateMixedScopes checks the mixed_scopes setting of a rule
+func validateMixedScopes(mixed string) error {
+	switch mixed {
+	case "", mixedScopesFirst, mixedScopesDedicated, mixedScopesDuplicate:
+		return nil
+	}
+	return fmt.Errorf("invalid mixed_scopes %q (must be 'first', 'dedicated', or 'duplicate')", mixed)
+}
+
+// addInputScope records the scope an input of the rule was read from, once per scope
+func (context *modelContext) addInputScope(sm pmetric.ScopeMetrics) {
+	for _, scope := range context.inputScopes {
+		if scope == sm {
+			return
+		}
+	}
+	context.inputScopes = append(context.inputScopes, sm)
+}
+
+// spansScopes reports whether the inputs of a rule were read from several scopes
+func (context *modelContext) spansScopes() bool {
+	return len(context.inputScopes) > 1
+}
+
+// inputScopeOutputs returns the scope the outputs of a rule without an output
+// scope are added to: the scope of its first input, or the dedicated inference
+// scope of its resource when its inputs span scopes and the rule says so
+func (mp *metricsinferenceprocessor) inputScopeOutputs(context *modelContext) pmetric.ScopeMetrics {
+	if context.rule.mixedScopes != mixedScopesDedicated || !context.spansScopes() {
+		return context.scopeMetrics
+	}
+	scope := pcommon.NewInstrumentationScope()
+	scope.SetName(inferenceScopeName)
+	scope.SetVersion(mp.scopeVersion)
+	return findOrAppendScope(context.resourceMetrics, scope)
+}
+
+// duplicateOutputs copies the metrics a rule added to sm from index first on to
+// the scopes of its other inputs, when its inputs span scopes and the rule
+// duplicates its outputs. Rules with an output scope keep their outputs there.
+func duplicateOutputs(context *modelContext, sm pmetric.ScopeMetrics, first int) {
+	if context.rule.mixedScopes != mixedScopesDuplicate || context.rule.outputScope != "" || !context.spansScopes() {
+		return
+	}
+	for _, scope := range context.inputScopes {
+		if scope == sm {
+			continue
+		}
+		for i := first; i < sm.Metrics().Len(); i++ {
+			sm.Metrics().At(i).CopyTo(scope.Metrics().AppendEmpty())
+		}
+	}
+}
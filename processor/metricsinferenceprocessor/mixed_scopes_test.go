@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// multiScopeMetrics returns a batch whose cpu and memory metrics are recorded by
+// different instrumentation scopes of the same resource
+func multiScopeMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host.name", "node-1")
+	for _, scope := range []struct{ scope, metric string }{{"hostmetrics/cpu", "cpu"}, {"hostmetrics/memory", "memory"}} {
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName(scope.scope)
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(scope.metric)
+		metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(0.5)
+	}
+	return md
+}
+
+// scopeMetricNames returns the metric names of every scope of a batch, by scope name
+func scopeMetricNames(md pmetric.Metrics) map[string][]string {
+	names := make(map[string][]string)
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		sms := md.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			scope := sms.At(j).Scope().Name()
+			for k := 0; k < sms.At(j).Metrics().Len(); k++ {
+				names[scope] = append(names[scope], sms.At(j).Metrics().At(k).Name())
+			}
+		}
+	}
+	return names
+}
+
+func TestMixedScopes(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	tests := []struct {
+		mixed    string
+		expected map[string][]string
+	}{
+		{
+			mixed: "",
+			expected: map[string][]string{
+				"hostmetrics/cpu":    {"cpu", "scorer.score"},
+				"hostmetrics/memory": {"memory"},
+			},
+		},
+		{
+			mixed: mixedScopesDedicated,
+			expected: map[string][]string{
+				"hostmetrics/cpu":    {"cpu"},
+				"hostmetrics/memory": {"memory"},
+				inferenceScopeName:   {"scorer.score"},
+			},
+		},
+		{
+			mixed: mixedScopesDuplicate,
+			expected: map[string][]string{
+				"hostmetrics/cpu":    {"cpu", "scorer.score"},
+				"hostmetrics/memory": {"memory", "scorer.score"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mixed, func(t *testing.T) {
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Rules: []Rule{{
+					ModelName:     "scorer",
+					Inputs:        []string{"cpu", "memory"},
+					OutputPattern: "{model}.{output}",
+					Outputs:       []OutputSpec{{Name: "score"}},
+					MixedScopes:   tt.mixed,
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), multiScopeMetrics()))
+			require.Len(t, sink.AllMetrics(), 1)
+			assert.Equal(t, tt.expected, scopeMetricNames(sink.AllMetrics()[0]))
+		})
+	}
+}
+
+func TestMixedScopesSingleScope(t *testing.T) {
+	// Inputs of a single scope are not affected
+	md := multiScopeMetrics()
+	sm := md.ResourceMetrics().At(0).ScopeMetrics().At(0)
+	context := &modelContext{
+		rule:            internalRule{mixedScopes: mixedScopesDuplicate},
+		resourceMetrics: md.ResourceMetrics().At(0),
+		scopeMetrics:    sm,
+		hasContext:      true,
+	}
+	context.addInputScope(sm)
+	context.addInputScope(sm)
+	assert.False(t, context.spansScopes())
+
+	sm.Metrics().AppendEmpty().SetName("cpu.score")
+	duplicateOutputs(context, sm, 1)
+	assert.Equal(t, 1, md.ResourceMetrics().At(0).ScopeMetrics().At(1).Metrics().Len())
+
+	context.rule.mixedScopes = mixedScopesDedicated
+	assert.Equal(t, sm, (&metricsinferenceprocessor{}).inputScopeOutputs(context))
+}
+
+func TestValidateMixedScopes(t *testing.T) {
+	for _, mixed := range []string{"", mixedScopesFirst, mixedScopesDedicated, mixedScopesDuplicate} {
+		assert.NoError(t, validateMixedScopes(mixed))
+	}
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"cpu"}, MixedScopes: "merge"}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid mixed_scopes in rule 0")
+}
@@ -0,0 +1,242 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// modelSelector selects a rule's model from the inference server's repository
+// by the labels in the models' metadata
+type modelSelector struct {
+	labels     map[string]string
+	repository string
+}
+
+// selectedModel is the model a selector resolved to
+type selectedModel struct {
+	name    string
+	version string
+}
+
+// newModelSelector creates the selector configured for a rule, or nil when the
+// rule names its model
+func newModelSelector(cfg *ModelSelectorConfig) *modelSelector {
+	if cfg == nil {
+		return nil
+	}
+	return &modelSelector{labels: cfg.Labels, repository: cfg.Repository}
+}
+
+// validateModelSelector checks that a rule either names its model or selects it
+func validateModelSelector(rule Rule) error {
+	if rule.ModelSelector == nil {
+		return nil
+	}
+	if rule.ModelName != "" || rule.ModelVersion != "" {
+		return errors.New("model_selector cannot be combined with model_name or model_version")
+	}
+	if len(rule.ModelSelector.Labels) == 0 {
+		return errors.New("labels must not be empty")
+	}
+	if rule.inProcess() {
+		return errors.New("model_selector requires a model served by the inference server")
+	}
+	return nil
+}
+
+// matches reports whether the parameters of a model's metadata carry every label
+// of the selector
+func (s *modelSelector) matches(parameters map[string]*pb.InferParameter) bool {
+	for key, value := range s.labels {
+		if parameterString(parameters[key]) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parameterString returns the value of a metadata parameter as a string, empty
+// when the parameter is not set
+func parameterString(param *pb.InferParameter) string {
+	switch v := param.GetParameterChoice().(type) {
+	case *pb.InferParameter_StringParam:
+		return v.StringParam
+	case *pb.InferParameter_Int64Param:
+		return strconv.FormatInt(v.Int64Param, 10)
+	case *pb.InferParameter_BoolParam:
+		return strconv.FormatBool(v.BoolParam)
+	}
+	return ""
+}
+
+// selectModels resolves the model of every rule with a model selector from the
+// ready models of the inference server's repository, by rule index. Rules whose
+// lookup fails are left out.
+func (mp *metricsinferenceprocessor) selectModels(ctx context.Context, client pb.GRPCInferenceServiceClient) map[int]selectedModel {
+	// Selectors never change, so they are read without the metadata lock
+	selectors := make(map[int]*modelSelector)
+	for ruleIdx := range mp.rules {
+		if selector := mp.rules[ruleIdx].selector; selector != nil {
+			selectors[ruleIdx] = selector
+		}
+	}
+	if len(selectors) == 0 {
+		return nil
+	}
+	if !mp.supportsExtension(extensionModelRepository) {
+		for ruleIdx := range selectors {
+			mp.logLimiter.Warn(ruleIdx, "Inference server does not support the model repository extension, model selector not resolved",
+				zap.Int("rule_index", ruleIdx))
+		}
+		return nil
+	}
+
+	indexes := make(map[string][]*pb.RepositoryIndexResponse_ModelIndex) // Repository name -> ready models
+	parameters := make(map[selectedModel]map[string]*pb.InferParameter)
+	selected := make(map[int]selectedModel)
+	for ruleIdx, selector := range selectors {
+		models, exists := indexes[selector.repository]
+		if !exists {
+			resp, err := mp.fetchRepositoryIndex(ctx, client, selector.repository)
+			if err != nil {
+				mp.logLimiter.Warn(ruleIdx, "Failed to list models for model selector",
+					zap.Int("rule_index", ruleIdx),
+					zap.String("repository", selector.repository),
+					zap.Error(err))
+				continue
+			}
+			models = resp.Models
+			indexes[selector.repository] = models
+		}
+
+		var matches []selectedModel
+		for _, entry := range models {
+			model := selectedModel{name: entry.Name, version: entry.Version}
+			params, exists := parameters[model]
+			if !exists {
+				resp, err := mp.fetchModelMetadata(ctx, client, model.name, model.version)
+				if err != nil {
+					mp.logger.Debug("Failed to query metadata of repository model",
+						zap.String("model", model.name),
+						zap.String("version", model.version),
+						zap.Error(err))
+					continue
+				}
+				params = resp.Parameters
+				parameters[model] = params
+			}
+			if selector.matches(params) {
+				matches = append(matches, model)
+			}
+		}
+
+		model, err := pickSelectedModel(matches)
+		if err != nil {
+			mp.logLimiter.Warn(ruleIdx, "Model selector did not resolve to a model",
+				zap.Int("rule_index", ruleIdx),
+				zap.Any("labels", selector.labels),
+				zap.Error(err))
+			continue
+		}
+		selected[ruleIdx] = model
+	}
+	return selected
+}
+
+// applySelectedModels switches rules to the models their selectors resolved to.
+// Rules whose model changed lose the inputs and outputs discovered from the
+// previous model.
+// It reports whether any rule changed. The caller must hold mp.metadataLock for
+// writing, or be starting the processor.
+func (mp *metricsinferenceprocessor) applySelectedModels(selected map[int]selectedModel) bool {
+	changed := false
+	for ruleIdx, model := range selected {
+		rule := &mp.rules[ruleIdx]
+		if rule.modelName == model.name && rule.modelVersion == model.version {
+			continue
+		}
+		mp.logger.Info("Model selector resolved to a new model",
+			zap.Int("rule_index", ruleIdx),
+			zap.String("previous_model", rule.modelName),
+			zap.String("model", model.name),
+			zap.String("version", model.version))
+		rule.modelName = model.name
+		rule.modelVersion = model.version
+		rule.outputs = slices.DeleteFunc(rule.outputs, func(output internalOutputSpec) bool {
+			return output.discovered
+		})
+		if rule.discoverInputs {
+			rule.setDiscoveredInputs(nil)
+		}
+		changed = true
+	}
+	return changed
+}
+
+// pickSelectedModel returns the model a selector resolves to: the latest version
+// of the one model matching it. Matching several models is an error, so that a
+// rule never switches between models on the order of the repository index.
+func pickSelectedModel(matches []selectedModel) (selectedModel, error) {
+	if len(matches) == 0 {
+		return selectedModel{}, errors.New("no ready model matches the labels")
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compareModelVersions(matches[i].version, matches[j].version) < 0
+	})
+	for _, model := range matches[1:] {
+		if model.name != matches[0].name {
+			return selectedModel{}, fmt.Errorf("several models match the labels: %s and %s", matches[0].name, model.name)
+		}
+	}
+	return matches[len(matches)-1], nil
+}
+
+// compareModelVersions orders model versions numerically when both are numbers,
+// as served by Triton and KServe, and lexically otherwise
+func compareModelVersions(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil && x < y:
+		return -1
+	case errA == nil && errB == nil && x > y:
+		return 1
+	case errA == nil && errB == nil:
+		return 0
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// fetchRepositoryIndex lists the ready models of a repository, or of every
+// repository when the name is empty, with the configured headers and timeout
+func (mp *metricsinferenceprocessor) fetchRepositoryIndex(ctx context.Context, client pb.GRPCInferenceServiceClient, repository string) (*pb.RepositoryIndexResponse, error) {
+	ctx = mp.headers.withStatic(ctx)
+
+	timeoutDuration := 5 * time.Second
+	if mp.config.Timeout > 0 {
+		timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
+	return client.RepositoryIndex(ctx, &pb.RepositoryIndexRequest{
+		RepositoryName: repository,
+		Ready:          true,
+	})
+}
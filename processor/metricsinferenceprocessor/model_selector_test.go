@@ -0,0 +1,141 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// labeledMetadata returns the metadata of a model with one output and string
+// parameters labeling it
+func labeledMetadata(name, version string, labels map[string]string) *pb.ModelMetadataResponse {
+	resp := &pb.ModelMetadataResponse{
+		Name:       name,
+		Versions:   []string{version},
+		Outputs:    []*pb.ModelMetadataResponse_TensorMetadata{{Name: "forecast", Datatype: "FP64", Shape: []int64{1}}},
+		Parameters: make(map[string]*pb.InferParameter),
+	}
+	for key, value := range labels {
+		resp.Parameters[key] = &pb.InferParameter{ParameterChoice: &pb.InferParameter_StringParam{StringParam: value}}
+	}
+	return resp
+}
+
+func TestModelSelector(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("cpu_forecaster", labeledMetadata("cpu_forecaster", "1", map[string]string{"task": "forecast", "metric": "cpu"})),
+		testutil.WithModelMetadata("memory_forecaster", labeledMetadata("memory_forecaster", "1", map[string]string{"task": "forecast", "metric": "memory"})),
+		testutil.WithModelResponse("cpu_forecaster", testutil.CreateMockResponseForCalculation("cpu_forecaster", 1)),
+		testutil.WithModelResponse("cpu_forecaster_v2", testutil.CreateMockResponseForCalculation("cpu_forecaster_v2", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelSelector: &ModelSelectorConfig{Labels: map[string]string{"task": "forecast", "metric": "cpu"}},
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "cpu.{output}",
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// The selector resolves at Start, and outputs are discovered from the selected model
+	assert.Equal(t, "cpu_forecaster", processor.rules[0].modelName)
+	assert.Equal(t, "1", processor.rules[0].modelVersion)
+	assert.Equal(t, []string{"cpu.forecast"}, ruleOutputNames(processor.rules[0]))
+
+	consume := func() {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	consume()
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "cpu_forecaster", requests[0].ModelName)
+
+	// Relabeling models in the repository moves the rule on the next refresh
+	mockServer.SetModelMetadata("cpu_forecaster", labeledMetadata("cpu_forecaster", "1", map[string]string{"task": "forecast"}))
+	mockServer.SetModelMetadata("cpu_forecaster_v2", labeledMetadata("cpu_forecaster_v2", "3", map[string]string{"task": "forecast", "metric": "cpu"}))
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Equal(t, "cpu_forecaster_v2", processor.rules[0].modelName)
+	assert.Equal(t, []string{"cpu.forecast"}, ruleOutputNames(processor.rules[0]))
+
+	consume()
+	requests = mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "cpu_forecaster_v2", requests[1].ModelName)
+}
+
+func TestModelSelectorUnresolved(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("memory_forecaster", labeledMetadata("memory_forecaster", "1", map[string]string{"task": "forecast", "metric": "memory"})))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelSelector: &ModelSelectorConfig{Labels: map[string]string{"metric": "cpu"}},
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "cpu.{output}",
+			Outputs:       []OutputSpec{{Name: "forecast"}},
+		}},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// A rule whose selector matches no model does not infer
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[0], "cpu.forecast").Name())
+}
+
+func TestPickSelectedModel(t *testing.T) {
+	model, err := pickSelectedModel([]selectedModel{{"forecaster", "10"}, {"forecaster", "9"}})
+	require.NoError(t, err)
+	assert.Equal(t, selectedModel{"forecaster", "10"}, model)
+
+	_, err = pickSelectedModel([]selectedModel{{"forecaster", "1"}, {"other", "1"}})
+	assert.ErrorContains(t, err, "several models match")
+
+	_, err = pickSelectedModel(nil)
+	assert.ErrorContains(t, err, "no ready model")
+}
+
+func TestValidateModelSelector(t *testing.T) {
+	selector := &ModelSelectorConfig{Labels: map[string]string{"task": "forecast"}}
+	assert.NoError(t, validateModelSelector(Rule{ModelSelector: selector}))
+	assert.ErrorContains(t, validateModelSelector(Rule{ModelName: "scorer", ModelSelector: selector}), "cannot be combined")
+	assert.ErrorContains(t, validateModelSelector(Rule{ModelSelector: &ModelSelectorConfig{}}), "labels must not be empty")
+	assert.ErrorContains(t, validateModelSelector(Rule{ModelSelector: selector, Backend: backendLocal}), "inference server")
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Policies for NaN and infinite values returned by models
+const (
+	nonFinitePolicyDrop      = "drop"      // Drop the data point
+	nonFinitePolicyZero      = "zero"      // Replace the value with 0
+	nonFinitePolicyPropagate = "propagate" // Emit the value as returned
+	nonFinitePolicyClamp     = "clamp"     // Replace ±Inf with the largest finite values, drop NaN
+)
+
+// validateNonFiniteConfig checks the non-finite value policy
+func validateNonFiniteConfig(cfg NonFiniteConfig) error {
+	switch cfg.Policy {
+	case "", nonFinitePolicyDrop, nonFinitePolicyZero, nonFinitePolicyPropagate, nonFinitePolicyClamp:
+		return nil
+	default:
+		return fmt.Errorf("invalid policy %q (must be 'drop', 'zero', 'propagate' or 'clamp')", cfg.Policy)
+	}
+}
+
+// sanitizeOutputs applies the non-finite value policy to the metrics an output
+// added from index first on, and reports the values it changed or dropped
+func (mp *metricsinferenceprocessor) sanitizeOutputs(metrics pmetric.MetricSlice, first int, ruleIdx int, modelName string) {
+	policy := mp.config.NonFinite.Policy
+	if policy == "" || policy == nonFinitePolicyPropagate {
+		return
+	}
+
+	sanitized, emptied := 0, false
+	for i := first; i < metrics.Len(); i++ {
+		dps, ok := numberDataPoints(metrics.At(i))
+		if !ok {
+			continue
+		}
+		dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			if dp.ValueType() != pmetric.NumberDataPointValueTypeDouble {
+				return false
+			}
+			value := dp.DoubleValue()
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				return false
+			}
+			sanitized++
+			switch {
+			case policy == nonFinitePolicyZero:
+				dp.SetDoubleValue(0)
+			case policy == nonFinitePolicyClamp && math.IsInf(value, 1):
+				dp.SetDoubleValue(math.MaxFloat64)
+			case policy == nonFinitePolicyClamp && math.IsInf(value, -1):
+				dp.SetDoubleValue(-math.MaxFloat64)
+			default:
+				return true
+			}
+			return false
+		})
+		emptied = emptied || dps.Len() == 0
+	}
+	if sanitized == 0 {
+		return
+	}
+
+	// Outputs left without data points are not exported
+	if emptied {
+		removeEmptyOutputs(metrics, first)
+	}
+
+	mp.telemetry.recordSanitizedValues(context.Background(), modelName, sanitized)
+	mp.logLimiter.Warn(ruleIdx, "Model returned non-finite output values",
+		zap.String("model", modelName),
+		zap.Int("rule_index", ruleIdx),
+		zap.String("policy", policy),
+		zap.Int("count", sanitized))
+}
+
+// removeEmptyOutputs removes the gauges and sums left without data points from
+// the metrics an output added from index first on
+func removeEmptyOutputs(metrics pmetric.MetricSlice, first int) {
+	index := 0
+	metrics.RemoveIf(func(metric pmetric.Metric) bool {
+		index++
+		dps, ok := numberDataPoints(metric)
+		return index > first && ok && dps.Len() == 0
+	})
+}
+
+// numberDataPoints returns the data points of a gauge or sum
+func numberDataPoints(metric pmetric.Metric) (pmetric.NumberDataPointSlice, bool) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		return metric.Gauge().DataPoints(), true
+	case pmetric.MetricTypeSum:
+		return metric.Sum().DataPoints(), true
+	default:
+		return pmetric.NumberDataPointSlice{}, false
+	}
+}
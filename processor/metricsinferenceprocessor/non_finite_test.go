@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestSanitizeOutputs(t *testing.T) {
+	values := []float64{1.5, math.NaN(), math.Inf(1), math.Inf(-1)}
+
+	tests := []struct {
+		policy    string
+		expected  []float64
+		sanitized int64
+	}{
+		{policy: nonFinitePolicyDrop, expected: []float64{1.5}, sanitized: 3},
+		{policy: nonFinitePolicyZero, expected: []float64{1.5, 0, 0, 0}, sanitized: 3},
+		{policy: nonFinitePolicyClamp, expected: []float64{1.5, math.MaxFloat64, -math.MaxFloat64}, sanitized: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			telemetry, err := newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+			require.NoError(t, err)
+			mp := &metricsinferenceprocessor{
+				config:     &Config{NonFinite: NonFiniteConfig{Policy: tt.policy}},
+				logger:     zap.NewNop(),
+				logLimiter: newLogLimiter(zap.NewNop(), LoggingConfig{}),
+				telemetry:  telemetry,
+			}
+
+			metrics := pmetric.NewMetricSlice()
+			previous := metrics.AppendEmpty()
+			previous.SetName("previous")
+			previous.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(math.NaN())
+			output := metrics.AppendEmpty()
+			output.SetName("output")
+			dps := output.SetEmptyGauge().DataPoints()
+			for _, value := range values {
+				dps.AppendEmpty().SetDoubleValue(value)
+			}
+
+			mp.sanitizeOutputs(metrics, 1, 0, "model")
+
+			// Metrics before the output are left alone
+			assert.True(t, math.IsNaN(metrics.At(0).Gauge().DataPoints().At(0).DoubleValue()))
+			var got []float64
+			for _, dp := range extractNumberDataPoints(metrics.At(1)) {
+				got = append(got, dp.DoubleValue())
+			}
+			assert.Equal(t, tt.expected, got)
+
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			var sanitized int64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == "otelcol_processor_metricsinference_sanitized_values" {
+						for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+							sanitized += dp.Value
+						}
+					}
+				}
+			}
+			assert.Equal(t, tt.sanitized, sanitized)
+		})
+	}
+}
+
+func TestSanitizeOutputsPropagate(t *testing.T) {
+	// Values are emitted as returned unless a policy is configured
+	for _, policy := range []string{"", nonFinitePolicyPropagate} {
+		mp := &metricsinferenceprocessor{config: &Config{NonFinite: NonFiniteConfig{Policy: policy}}}
+		metrics := pmetric.NewMetricSlice()
+		metrics.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(math.Inf(1))
+
+		mp.sanitizeOutputs(metrics, 0, 0, "model")
+		assert.True(t, math.IsInf(metrics.At(0).Gauge().DataPoints().At(0).DoubleValue(), 1))
+	}
+}
+
+func TestSanitizeOutputsRemovesEmptyOutputs(t *testing.T) {
+	telemetry, err := newProcessorTelemetry(nil, nil)
+	require.NoError(t, err)
+	mp := &metricsinferenceprocessor{
+		config:     &Config{NonFinite: NonFiniteConfig{Policy: nonFinitePolicyDrop}},
+		logger:     zap.NewNop(),
+		logLimiter: newLogLimiter(zap.NewNop(), LoggingConfig{}),
+		telemetry:  telemetry,
+	}
+	metrics := pmetric.NewMetricSlice()
+	metrics.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty().SetDoubleValue(math.NaN())
+
+	mp.sanitizeOutputs(metrics, 0, 0, "model")
+	assert.Equal(t, 0, metrics.Len())
+}
+
+func TestValidateNonFiniteConfig(t *testing.T) {
+	for _, policy := range []string{"", "drop", "zero", "propagate", "clamp"} {
+		assert.NoError(t, validateNonFiniteConfig(NonFiniteConfig{Policy: policy}))
+	}
+	assert.ErrorContains(t, validateNonFiniteConfig(NonFiniteConfig{Policy: "ignore"}), "invalid policy")
+}
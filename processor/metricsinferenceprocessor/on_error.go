@@ -0,0 +1,284 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// What happens to the batch when a rule's inference fails
+const (
+	onErrorPass       = "pass"
+	onErrorDropInputs = "drop_inputs"
+	onErrorDropBatch  = "drop_batch"
+	onErrorRetry      = "retry"
+)
+
+// ruleFailure is a rule whose inference failed in a batch, with the error it failed with
+type ruleFailure struct {
+	ctx *modelContext
+	err error
+}
+
+// validateOnError checks an on_error policy
+func validateOnError(policy string) error {
+	switch policy {
+	case "", onErrorPass, onErrorDropInputs, onErrorDropBatch, onErrorRetry:
+		return nil
+	}
+	return fmt.Errorf("invalid on_error %q (must be 'pass', 'drop_inputs', 'drop_batch', or 'retry')", policy)
+}
+
+// validateRuleOnError checks a rule's on_error policy. Shadow rules never hold
+// back data, as their results do not reach the batch either, and neither do
+// interval-triggered rules, which infer after their inputs were forwarded.
+func validateRuleOnError(rule Rule) error {
+	if err := validateOnError(rule.OnError); err != nil {
+		return err
+	}
+	if rule.OnError == "" || rule.OnError == onErrorPass {
+		return nil
+	}
+	if rule.Mode == ruleModeShadow {
+		return errors.New("on_error is not supported in shadow mode")
+	}
+	if rule.Trigger.Mode == triggerModeInterval {
+		return errors.New("on_error is not supported by interval triggers")
+	}
+	return nil
+}
+
+// resolveOnError returns the on_error policy of a rule: its own, then the
+// processor's, passing data through by default
+func resolveOnError(config *Config, rule Rule) string {
+	switch {
+	case rule.Mode == ruleModeShadow, rule.Trigger.Mode == triggerModeInterval:
+		return onErrorPass
+	case rule.OnError != "":
+		return rule.OnError
+	case config.OnError != "":
+		return config.OnError
+	}
+	return onErrorPass
+}
+
+// holdBack applies the on_error policies of the rules whose inference failed in
+// a batch, once every rule has run so that failed inputs still feed other rules.
+// It reports whether the whole batch must be dropped, and the error to return to
+// the preceding component instead of forwarding it.
+func (mp *metricsinferenceprocessor) holdBack(md pmetric.Metrics, failed []ruleFailure) (bool, error) {
+	var retryErr error
+	for _, failure := range failed {
+		ruleCtx := failure.ctx
+		switch ruleCtx.rule.onError {
+		case onErrorDropBatch:
+			mp.logLimiter.Warn(ruleCtx.ruleIndex, "Inference failed, dropping the batch",
+				zap.String("model", ruleCtx.rule.modelName),
+				zap.Int("rule_index", ruleCtx.ruleIndex),
+				zap.Int("metric_count", md.MetricCount()))
+			return true, nil
+		case onErrorDropInputs:
+			dropped := dropRuleInputs(md, ruleCtx)
+			mp.logLimiter.Warn(ruleCtx.ruleIndex, "Inference failed, dropping the rule's inputs",
+				zap.String("model", ruleCtx.rule.modelName),
+				zap.Int("rule_index", ruleCtx.ruleIndex),
+				zap.Int("data_point_count", dropped))
+		case onErrorRetry:
+			err := fmt.Errorf("inference of rule %d (model %s) failed: %w", ruleCtx.ruleIndex, ruleCtx.rule.modelName, failure.err)
+			if !retryable(failure.err) {
+				// Retrying cannot fix the batch, so no failure is worth retrying
+				mp.logLimiter.Warn(ruleCtx.ruleIndex, "Inference failed permanently, rejecting the batch",
+					zap.String("model", ruleCtx.rule.modelName),
+					zap.Int("rule_index", ruleCtx.ruleIndex),
+					zap.Error(failure.err))
+				return true, consumererror.NewPermanent(err)
+			}
+			if retryErr == nil {
+				retryErr = err
+			}
+		}
+	}
+	if retryErr != nil {
+		mp.logLimiter.Warn(noRule, "Inference failed transiently, returning the batch for retry",
+			zap.Int("metric_count", md.MetricCount()),
+			zap.Error(retryErr))
+		return true, retryErr
+	}
+	return false, nil
+}
+
+// retryable reports whether a failed inference may succeed when the batch is
+// sent again: the server was unavailable or overloaded, or the call ran out of
+// time or was dropped by the inference queue. Requests the server rejected and
+// responses that cannot be decoded fail the same way on every attempt.
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch errorCode(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// dropRuleInputs removes the data points a rule selects from the batch, limited
+// to the value an expanded rule inferred for, and the metrics left empty. It
+// returns the number of data points removed.
+func dropRuleInputs(md pmetric.Metrics, ruleCtx *modelContext) int {
+	rule := ruleCtx.rule
+	dropped := 0
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		// A resource carrying an expanded rule's attribute belongs to one value as a whole
+		resourceValue, inResource := "", false
+		if ruleCtx.expansion != nil {
+			if value, exists := rms.At(i).Resource().Attributes().Get(rule.expandBy); exists {
+				resourceValue, inResource = value.AsString(), true
+			}
+			if inResource && resourceValue != ruleCtx.expansion.value {
+				continue
+			}
+		}
+
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sms.At(j).Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				var selectors []*labelSelector
+				for _, selector := range rule.inputSelectors {
+					if selector != nil && selector.matchesName(metric.Name()) {
+						selectors = append(selectors, selector)
+					}
+				}
+				if len(selectors) == 0 {
+					return false
+				}
+				remaining := removeDataPointsIf(metric, func(attrs pcommon.Map) bool {
+					if ruleCtx.expansion != nil && !inResource {
+						value, exists := attrs.Get(rule.expandBy)
+						if !exists || value.AsString() != ruleCtx.expansion.value {
+							return false
+						}
+					}
+					for _, selector := range selectors {
+						if dataPointMatchesSelector(attrs, selector) {
+							dropped++
+							return true
+						}
+					}
+					return false
+				})
+				return remaining == 0
+			})
+		}
+	}
+	return dropped
+}
+
+// removeDataPointsIf removes the data points of a metric of any type whose
+// attributes match, returning the number of data points left
+func removeDataPointsIf(metric pmetric.Metric, remove func(pcommon.Map) bool) int {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		dps, _ := numberDataPoints(metric)
+		dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool { return remove(dp.Attributes()) })
+		return dps.Len()
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		dps.RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return remove(dp.Attributes()) })
+		return dps.Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		dps.RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool { return remove(dp.Attributes()) })
+		return dps.Len()
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		dps.RemoveIf(func(dp pmetric.SummaryDataPoint) bool { return remove(dp.Attributes()) })
+		return dps.Len()
+	}
+	return 0
+}
+
+// batchCheckpoint is the per-series state of the rules before a batch, restored
+// when the batch is returned for retry so that the retried batch finds the state
+// the first attempt found
+type batchCheckpoint struct {
+	rules      []persistedRule
+	expansions map[int]map[string]map[string]persistedTransform // Transforms of expanded rules, by rule index, value and resource, and input
+	pending    pmetric.Metrics                                  // Outputs of interval inferences the batch took
+}
+
+// retriesBatches reports whether a rule may return a batch for retry. The caller
+// must hold mp.metadataLock.
+func (mp *metricsinferenceprocessor) retriesBatches() bool {
+	for _, rule := range mp.rules {
+		if rule.onError == onErrorRetry {
+			return true
+		}
+	}
+	return false
+}
+
+// checkpoint captures the per-series state of every rule. The caller must hold
+// mp.metadataLock.
+func (mp *metricsinferenceprocessor) checkpoint() *batchCheckpoint {
+	cp := &batchCheckpoint{
+		rules:      make([]persistedRule, len(mp.rules)),
+		expansions: make(map[int]map[string]map[string]persistedTransform),
+	}
+	for ruleIdx := range mp.rules {
+		cp.rules[ruleIdx] = mp.snapshotRule(ruleIdx)
+	}
+
+	mp.expansionLock.Lock()
+	defer mp.expansionLock.Unlock()
+	for ruleIdx, byValue := range mp.expansions {
+		cp.expansions[ruleIdx] = make(map[string]map[string]persistedTransform, len(byValue))
+		for value, expansion := range byValue {
+			saved := make(map[string]persistedTransform, len(expansion.transforms))
+			for input, transform := range expansion.transforms {
+				saved[input] = transform.snapshot()
+			}
+			cp.expansions[ruleIdx][value] = saved
+		}
+	}
+	return cp
+}
+
+// rollback restores the per-series state of every rule to a checkpoint, and the
+// outputs of interval inferences the batch took. State created since, such as
+// the series of values an expanded rule saw for the first time, is cleared. The
+// caller must hold mp.metadataLock.
+func (mp *metricsinferenceprocessor) rollback(cp *batchCheckpoint) {
+	mp.restorePendingOutputs(cp.pending)
+	for ruleIdx, saved := range cp.rules {
+		rule := mp.rules[ruleIdx]
+		mp.lastValues.reset(ruleIdx, saved.LastValues)
+		mp.cumulative.reset(ruleIdx, saved.Cumulative)
+		for input, transform := range rule.transforms {
+			transform.reset(saved.Transforms[input])
+		}
+		if backend, ok := rule.backend.(*localBackend); ok {
+			backend.reset(saved.Local)
+		}
+	}
+
+	mp.expansionLock.Lock()
+	defer mp.expansionLock.Unlock()
+	for ruleIdx, byValue := range mp.expansions {
+		for value, expansion := range byValue {
+			saved := cp.expansions[ruleIdx][value]
+			for input, transform := range expansion.transforms {
+				transform.reset(saved[input])
+			}
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// runFailingBatch sends a batch with cpu and memory metrics through a processor
+// whose scorer model fails, returning what reached the next consumer
+func runFailingBatch(t *testing.T, globalPolicy, rulePolicy string) []pmetric.Metrics {
+	batches, err := consumeFailingBatch(t, codes.Unavailable, globalPolicy, rulePolicy)
+	require.NoError(t, err)
+	return batches
+}
+
+// consumeFailingBatch sends a batch with cpu and memory metrics through a
+// processor whose scorer model fails with code, returning what reached the next
+// consumer and the error returned to the preceding component
+func consumeFailingBatch(t *testing.T, code codes.Code, globalPolicy, rulePolicy string) ([]pmetric.Metrics, error) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelError("scorer", testutil.CreateMockErrorResponse(code, "model not loaded")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		OnError:            globalPolicy,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			Inputs:        []string{"cpu{state=busy}"},
+			OutputPattern: "cpu.{output}",
+			Outputs:       []OutputSpec{{Name: "score"}},
+			OnError:       rulePolicy,
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{
+			MetricName: "cpu",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 0.9, Attributes: map[string]string{"state": "busy"}},
+				{Value: 0.1, Attributes: map[string]string{"state": "idle"}},
+			},
+		},
+		{
+			MetricName: "memory",
+			DataPoints: []testutil.TestDataPoint{{Value: 0.5}},
+		},
+	})
+	err = processor.ConsumeMetrics(context.Background(), input)
+	return sink.AllMetrics(), err
+}
+
+func TestOnErrorPass(t *testing.T) {
+	batches := runFailingBatch(t, "", "")
+	require.Len(t, batches, 1)
+	assert.Equal(t, 2, findMetricByName(batches[0], "cpu").Gauge().DataPoints().Len())
+	assert.Equal(t, "memory", findMetricByName(batches[0], "memory").Name())
+}
+
+func TestOnErrorDropInputs(t *testing.T) {
+	batches := runFailingBatch(t, "", onErrorDropInputs)
+	require.Len(t, batches, 1)
+
+	// Only the data points the rule selected are removed
+	dps := findMetricByName(batches[0], "cpu").Gauge().DataPoints()
+	require.Equal(t, 1, dps.Len())
+	state, _ := dps.At(0).Attributes().Get("state")
+	assert.Equal(t, "idle", state.AsString())
+	assert.Equal(t, "memory", findMetricByName(batches[0], "memory").Name())
+}
+
+func TestOnErrorDropBatch(t *testing.T) {
+	// The processor's policy applies to rules without their own
+	assert.Empty(t, runFailingBatch(t, onErrorDropBatch, ""))
+
+	// A rule's policy overrides the processor's
+	assert.Len(t, runFailingBatch(t, onErrorDropBatch, onErrorPass), 1)
+}
+
+func TestOnErrorRetry(t *testing.T) {
+	// Transient failures return the batch for retry
+	batches, err := consumeFailingBatch(t, codes.Unavailable, onErrorRetry, "")
+	assert.Empty(t, batches)
+	require.Error(t, err)
+	assert.False(t, consumererror.IsPermanent(err))
+	assert.ErrorContains(t, err, "inference of rule 0 (model scorer) failed")
+
+	// Failures retrying cannot fix are permanent
+	batches, err = consumeFailingBatch(t, codes.InvalidArgument, onErrorRetry, "")
+	assert.Empty(t, batches)
+	require.Error(t, err)
+	assert.True(t, consumererror.IsPermanent(err))
+
+	// Other policies never return errors
+	batches, err = consumeFailingBatch(t, codes.InvalidArgument, onErrorRetry, onErrorDropInputs)
+	assert.NoError(t, err)
+	assert.Len(t, batches, 1)
+}
+
+func TestOnErrorRetryResend(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("adder", testutil.CreateMockResponseForCalculation("adder", 2)),
+		testutil.WithModelError("scorer", testutil.CreateMockErrorResponse(codes.Unavailable, "model not loaded")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "adder",
+				Inputs:        []string{"cpu{state=busy}"},
+				OutputPattern: "cpu.total",
+				Outputs:       []OutputSpec{{Temporality: temporalityCumulative}},
+			},
+			{
+				ModelName:     "scorer",
+				Inputs:        []string{"cpu{state=busy}"},
+				OutputPattern: "cpu.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+				OnError:       onErrorRetry,
+			},
+			{
+				ModelName: "scorer",
+				Inputs:    []string{"memory"},
+				OnError:   onErrorDropInputs,
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{
+			MetricName: "cpu",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 0.9, Attributes: map[string]string{"state": "busy"}},
+				{Value: 0.1, Attributes: map[string]string{"state": "idle"}},
+			},
+		},
+		{
+			MetricName: "memory",
+			DataPoints: []testutil.TestDataPoint{{Value: 0.5}},
+		},
+	})
+	sent := pmetric.NewMetrics()
+	input.CopyTo(sent)
+
+	// The batch comes back unchanged: no outputs, no dropped inputs
+	err = processor.ConsumeMetrics(context.Background(), input)
+	require.Error(t, err)
+	assert.False(t, consumererror.IsPermanent(err))
+	assert.Empty(t, sink.AllMetrics())
+	assert.Equal(t, sent, input)
+
+	// Once the model recovers, the retried batch gets its outputs once, and the
+	// running total counts the batch once
+	mockServer.Reset()
+	mockServer.SetModelResponse("adder", testutil.CreateMockResponseForCalculation("adder", 2))
+	mockServer.SetModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1))
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	require.Len(t, sink.AllMetrics(), 1)
+	batch := sink.AllMetrics()[0]
+	assert.Equal(t, 4, batch.MetricCount())
+	assert.Equal(t, 2, findMetricByName(batch, "cpu").Gauge().DataPoints().Len())
+	assert.Equal(t, 1, findMetricByName(batch, "memory").Gauge().DataPoints().Len())
+	assert.Equal(t, 1, findMetricByName(batch, "cpu.score").Gauge().DataPoints().Len())
+	total := findMetricByName(batch, "cpu.total")
+	require.Equal(t, pmetric.MetricTypeSum, total.Type())
+	assert.Equal(t, 2.0, total.Sum().DataPoints().At(0).DoubleValue())
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(status.Error(codes.Unavailable, "server restarting")))
+	assert.True(t, retryable(status.Error(codes.ResourceExhausted, "overloaded")))
+	assert.True(t, retryable(context.DeadlineExceeded))
+	assert.True(t, retryable(errInsufficientBudget))
+	assert.True(t, retryable(errQueueFull))
+	assert.False(t, retryable(status.Error(codes.NotFound, "unknown model")))
+	assert.False(t, retryable(status.Error(codes.InvalidArgument, "shape mismatch")))
+	assert.False(t, retryable(errors.New("output tensor has no contents")))
+	assert.False(t, retryable(nil))
+}
+
+func TestDropRuleInputsOfExpansion(t *testing.T) {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu")
+	gauge := metric.SetEmptyGauge()
+	for _, host := range []string{"a", "b"} {
+		gauge.DataPoints().AppendEmpty().Attributes().PutStr("host.name", host)
+	}
+	for _, host := range []string{"a", "b"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host.name", host)
+		resourceMetric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		resourceMetric.SetName("cpu")
+		resourceMetric.SetEmptyGauge().DataPoints().AppendEmpty()
+	}
+
+	selector, err := parseLabelSelector("cpu")
+	require.NoError(t, err)
+	ruleCtx := &modelContext{
+		rule:      internalRule{inputSelectors: []*labelSelector{selector}, expandBy: "host.name"},
+		expansion: &ruleExpansion{value: "a"},
+	}
+
+	// Only the data points and resources of the failed value are removed
+	assert.Equal(t, 2, dropRuleInputs(md, ruleCtx))
+	rms := md.ResourceMetrics()
+	dps := rms.At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	require.Equal(t, 1, dps.Len())
+	host, _ := dps.At(0).Attributes().Get("host.name")
+	assert.Equal(t, "b", host.AsString())
+	assert.Equal(t, 0, rms.At(1).ScopeMetrics().At(0).Metrics().Len())
+	assert.Equal(t, 1, rms.At(2).ScopeMetrics().At(0).Metrics().Len())
+}
+
+func TestValidateRuleOnError(t *testing.T) {
+	assert.NoError(t, validateRuleOnError(Rule{OnError: onErrorDropInputs}))
+	assert.NoError(t, validateRuleOnError(Rule{OnError: onErrorRetry}))
+	assert.ErrorContains(t, validateRuleOnError(Rule{OnError: "hold"}), "invalid on_error")
+	assert.ErrorContains(t, validateRuleOnError(Rule{OnError: onErrorDropBatch, Mode: ruleModeShadow}), "shadow mode")
+	assert.ErrorContains(t, validateRuleOnError(Rule{OnError: onErrorDropBatch, Trigger: TriggerConfig{Mode: triggerModeInterval}}), "interval")
+
+	// Shadow rules pass data through whatever the processor's policy
+	assert.Equal(t, onErrorPass, resolveOnError(&Config{OnError: onErrorDropBatch}, Rule{Mode: ruleModeShadow}))
+	assert.Equal(t, onErrorDropBatch, resolveOnError(&Config{OnError: onErrorDropBatch}, Rule{}))
+
+	// Sequences advance on the server, so their batches cannot be retried
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		OnError:            onErrorRetry,
+		Rules:              []Rule{{ModelName: "tracker", Inputs: []string{"cpu"}, Sequence: SequenceConfig{Enabled: true}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "retry is not supported by sequence rules")
+}
+
+func TestOnErrorRetryKeepsIntervalOutputs(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 0.5)),
+		testutil.WithModelError("scorer", testutil.CreateMockErrorResponse(codes.Unavailable, "model not loaded")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecaster",
+				Inputs:        []string{"cpu"},
+				OutputPattern: "forecaster.{output}",
+				Outputs:       []OutputSpec{{Name: "forecast"}},
+				Trigger:       TriggerConfig{Mode: "interval", Every: time.Hour},
+			},
+			{
+				ModelName:     "scorer",
+				Inputs:        []string{"cpu"},
+				OutputPattern: "cpu.{output}",
+				Outputs:       []OutputSpec{{Name: "score"}},
+				OnError:       onErrorRetry,
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	require.Error(t, processor.ConsumeMetrics(ctx, cpuBatch(start, 1)))
+	processor.inferInterval(ctx, 0)
+
+	// The batch returned for retry comes back without the interval outputs,
+	// which wait for the next batch
+	input := cpuBatch(start.Add(time.Second), 2)
+	sent := pmetric.NewMetrics()
+	input.CopyTo(sent)
+	require.Error(t, processor.ConsumeMetrics(ctx, input))
+	assert.Equal(t, sent, input)
+	processor.pendingLock.Lock()
+	assert.Equal(t, 1, processor.pendingOutputs.MetricCount())
+	processor.pendingLock.Unlock()
+
+	mockServer.Reset()
+	mockServer.SetModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1))
+	require.NoError(t, processor.ConsumeMetrics(ctx, input))
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, "forecaster.forecast", findMetricByName(sink.AllMetrics()[0], "forecaster.forecast").Name())
+}
@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Output attribute modes
+const (
+	outputAttributesNamespaced = "namespaced"
+	outputAttributesAsIs       = "as_is"
+)
+
+// Resolutions of attributes copied from several inputs with different values
+const (
+	attributeConflictFirst     = "first"
+	attributeConflictNamespace = "namespace"
+	attributeConflictDrop      = "drop"
+)
+
+// validateOutputAttributes checks a rule's output attribute policy
+func validateOutputAttributes(cfg OutputAttributesConfig) error {
+	switch cfg.Mode {
+	case "", outputAttributesNamespaced, outputAttributesAsIs:
+	default:
+		return fmt.Errorf("invalid mode %q (must be 'namespaced' or 'as_is')", cfg.Mode)
+	}
+	switch cfg.OnConflict {
+	case "", attributeConflictFirst, attributeConflictNamespace, attributeConflictDrop:
+	default:
+		return fmt.Errorf("invalid on_conflict %q (must be 'first', 'namespace', or 'drop')", cfg.OnConflict)
+	}
+	if len(cfg.Include) > 0 && len(cfg.Exclude) > 0 {
+		return errors.New("include and exclude are mutually exclusive")
+	}
+	for from, to := range cfg.Rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("rename %q to %q: keys must not be empty", from, to)
+		}
+	}
+	for key := range cfg.Static {
+		if key == "" {
+			return errors.New("static attribute keys must not be empty")
+		}
+	}
+	return nil
+}
+
+// outputAttributePolicy decides which input attributes are copied onto output
+// data points, and under which keys
+type outputAttributePolicy struct {
+	asIs       bool
+	include    map[string]bool // Empty when every key is included
+	exclude    map[string]bool
+	rename     map[string]string
+	static     map[string]string
+	onConflict string
+}
+
+// newOutputAttributePolicy converts a rule's output attribute configuration, applying defaults
+func newOutputAttributePolicy(cfg OutputAttributesConfig) *outputAttributePolicy {
+	policy := &outputAttributePolicy{
+		asIs:       cfg.Mode == outputAttributesAsIs,
+		include:    keySet(cfg.Include),
+		exclude:    keySet(cfg.Exclude),
+		rename:     cfg.Rename,
+		static:     cfg.Static,
+		onConflict: cfg.OnConflict,
+	}
+	if policy.onConflict == "" {
+		policy.onConflict = attributeConflictFirst
+	}
+	return policy
+}
+
+// keySet converts a list of keys into a set
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// outputAttribute is an input attribute to be copied onto an output data point
+type outputAttribute struct {
+	input string
+	key   string // Key on the input data point
+	out   string // Key on the output data point
+	value pcommon.Value
+}
+
+// copyAttributes copies the attributes of the data points of an attribute
+// group onto an output data point. Inputs are visited in rule order, which
+// decides the winner of conflicting attributes under the "first" resolution.
+// Static attributes are added last and take precedence over copied attributes.
+// A nil policy copies every attribute namespaced by its input.
+func (p *outputAttributePolicy) copyAttributes(attrs pcommon.Map, inputs []string, dataPoints map[string]dataPoint) {
+	if p == nil {
+		p = newOutputAttributePolicy(OutputAttributesConfig{})
+	}
+
+	var candidates []outputAttribute
+	for _, input := range orderedInputs(inputs, dataPoints) {
+		dataPoints[input].Attributes().Range(func(k string, v pcommon.Value) bool {
+			if p.keeps(k) {
+				candidates = append(candidates, outputAttribute{input: input, key: k, out: p.outputKey(input, k), value: v})
+			}
+			return true
+		})
+	}
+
+	// Keys copied from several inputs with different values are conflicts
+	values := make(map[string]pcommon.Value, len(candidates))
+	conflicts := make(map[string]bool)
+	for _, c := range candidates {
+		if value, ok := values[c.out]; ok {
+			if !value.Equal(c.value) {
+				conflicts[c.out] = true
+			}
+			continue
+		}
+		values[c.out] = c.value
+	}
+
+	written := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		key := c.out
+		if conflicts[key] {
+			switch p.onConflict {
+			case attributeConflictDrop:
+				continue
+			case attributeConflictNamespace:
+				key = c.input + "." + c.key
+			}
+		}
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		if p.asIs {
+			c.value.CopyTo(attrs.PutEmpty(key))
+		} else {
+			attrs.PutStr(key, c.value.AsString())
+		}
+	}
+
+	for key, value := range p.static {
+		attrs.PutStr(key, value)
+	}
+}
+
+// keeps reports whether an input attribute key passes the include and exclude lists
+func (p *outputAttributePolicy) keeps(key string) bool {
+	if len(p.include) > 0 {
+		return p.include[key]
+	}
+	return !p.exclude[key]
+}
+
+// outputKey returns the key an input attribute is copied under
+func (p *outputAttributePolicy) outputKey(input, key string) string {
+	out := key
+	if !p.asIs {
+		out = input + "." + key
+	}
+	if renamed, ok := p.rename[out]; ok {
+		return renamed
+	}
+	return out
+}
+
+// orderedInputs returns the inputs of a group in rule order, followed by any
+// other inputs in name order
+func orderedInputs(inputs []string, dataPoints map[string]dataPoint) []string {
+	ordered := make([]string, 0, len(dataPoints))
+	seen := make(map[string]bool, len(dataPoints))
+	for _, input := range inputs {
+		if _, ok := dataPoints[input]; ok && !seen[input] {
+			ordered = append(ordered, input)
+			seen[input] = true
+		}
+	}
+	var rest []string
+	for input := range dataPoints {
+		if !seen[input] {
+			rest = append(rest, input)
+		}
+	}
+	sort.Strings(rest)
+	return append(ordered, rest...)
+}
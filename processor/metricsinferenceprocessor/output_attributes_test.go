@@ -0,0 +1,163 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// attributeGroup creates data points for two inputs that share host but differ in state
+func attributeGroup() map[string]dataPoint {
+	used := pmetric.NewNumberDataPoint()
+	used.Attributes().PutStr("host", "a")
+	used.Attributes().PutStr("state", "used")
+	used.Attributes().PutInt("cpu", 0)
+	limit := pmetric.NewNumberDataPoint()
+	limit.Attributes().PutStr("host", "a")
+	limit.Attributes().PutStr("state", "limit")
+	return map[string]dataPoint{"memory.usage": used, "memory.limit": limit}
+}
+
+func TestOutputAttributePolicy(t *testing.T) {
+	inputs := []string{"memory.usage", "memory.limit"}
+	tests := []struct {
+		name string
+		cfg  OutputAttributesConfig
+		want map[string]any
+	}{
+		{
+			name: "namespaced by default",
+			want: map[string]any{
+				"memory.usage.host": "a", "memory.usage.state": "used", "memory.usage.cpu": "0",
+				"memory.limit.host": "a", "memory.limit.state": "limit",
+			},
+		},
+		{
+			name: "as is keeps the first input's value",
+			cfg:  OutputAttributesConfig{Mode: outputAttributesAsIs},
+			want: map[string]any{"host": "a", "state": "used", "cpu": int64(0)},
+		},
+		{
+			name: "as is namespaces conflicts",
+			cfg:  OutputAttributesConfig{Mode: outputAttributesAsIs, OnConflict: attributeConflictNamespace},
+			want: map[string]any{"host": "a", "memory.usage.state": "used", "memory.limit.state": "limit", "cpu": int64(0)},
+		},
+		{
+			name: "as is drops conflicts",
+			cfg:  OutputAttributesConfig{Mode: outputAttributesAsIs, OnConflict: attributeConflictDrop},
+			want: map[string]any{"host": "a", "cpu": int64(0)},
+		},
+		{
+			name: "allowlist with rename and static",
+			cfg: OutputAttributesConfig{
+				Mode:    outputAttributesAsIs,
+				Include: []string{"host"},
+				Rename:  map[string]string{"host": "host.name"},
+				Static:  map[string]string{"team": "sre"},
+			},
+			want: map[string]any{"host.name": "a", "team": "sre"},
+		},
+		{
+			name: "denylist of namespaced attributes",
+			cfg:  OutputAttributesConfig{Exclude: []string{"state", "cpu"}},
+			want: map[string]any{"memory.usage.host": "a", "memory.limit.host": "a"},
+		},
+		{
+			name: "renames colliding on one key",
+			cfg: OutputAttributesConfig{
+				Include: []string{"host"},
+				Rename:  map[string]string{"memory.usage.host": "host", "memory.limit.host": "host"},
+			},
+			want: map[string]any{"host": "a"},
+		},
+		{
+			name: "static overrides copied",
+			cfg:  OutputAttributesConfig{Mode: outputAttributesAsIs, Include: []string{"host"}, Static: map[string]string{"host": "b"}},
+			want: map[string]any{"host": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := pcommon.NewMap()
+			newOutputAttributePolicy(tt.cfg).copyAttributes(attrs, inputs, attributeGroup())
+			assert.Equal(t, tt.want, attrs.AsRaw())
+		})
+	}
+
+	// Without a policy attributes are namespaced
+	attrs := pcommon.NewMap()
+	var policy *outputAttributePolicy
+	policy.copyAttributes(attrs, inputs, attributeGroup())
+	assert.Equal(t, 5, attrs.Len())
+}
+
+func TestValidateOutputAttributes(t *testing.T) {
+	assert.NoError(t, validateOutputAttributes(OutputAttributesConfig{}))
+	assert.NoError(t, validateOutputAttributes(OutputAttributesConfig{
+		Mode: outputAttributesAsIs, Include: []string{"host"}, OnConflict: attributeConflictDrop,
+	}))
+	assert.ErrorContains(t, validateOutputAttributes(OutputAttributesConfig{Mode: "flat"}), "invalid mode")
+	assert.ErrorContains(t, validateOutputAttributes(OutputAttributesConfig{OnConflict: "last"}), "invalid on_conflict")
+	assert.ErrorContains(t, validateOutputAttributes(OutputAttributesConfig{
+		Include: []string{"host"}, Exclude: []string{"state"},
+	}), "mutually exclusive")
+	assert.ErrorContains(t, validateOutputAttributes(OutputAttributesConfig{Rename: map[string]string{"host": ""}}), "must not be empty")
+	assert.ErrorContains(t, validateOutputAttributes(OutputAttributesConfig{Static: map[string]string{"": "x"}}), "must not be empty")
+}
+
+func TestOutputAttributesAsIs(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scaler", testutil.CreateMockResponseForCalculation("scaler", 2)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "scaler",
+				Inputs:        []string{"memory.usage"},
+				OutputPattern: "{output}",
+				Outputs:       []OutputSpec{{Name: "memory.usage.scaled"}},
+				OutputAttributes: OutputAttributesConfig{
+					Mode:   outputAttributesAsIs,
+					Static: map[string]string{"source": "model"},
+				},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{{
+		MetricName: "memory.usage",
+		DataPoints: []testutil.TestDataPoint{{Value: 10, Attributes: map[string]string{"state": "used"}}},
+	}})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	output := findMetricByName(sink.AllMetrics()[0], "memory.usage.scaled")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	attrs := output.Gauge().DataPoints().At(0).Attributes().AsRaw()
+	assert.Equal(t, "used", attrs["state"])
+	assert.Equal(t, "model", attrs["source"])
+	assert.Equal(t, "scaler", attrs[labelInferenceModelName])
+	assert.NotContains(t, attrs, "memory.usage.state")
+}
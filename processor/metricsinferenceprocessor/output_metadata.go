@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Well-known parameters of output tensors in model metadata
+const (
+	metadataParamUnit        = "unit"
+	metadataParamDescription = "description"
+	metadataParamMetricType  = "otel.metric.type"
+)
+
+// Metric types a model can publish for an output
+const (
+	metricTypeGauge   = "gauge"
+	metricTypeSum     = "sum"     // Cumulative, non-monotonic sum
+	metricTypeCounter = "counter" // Cumulative, monotonic sum
+)
+
+// outputMetadata is what a model publishes about an output in its metadata
+type outputMetadata struct {
+	unit        string
+	description string
+	metricType  string // Empty for gauges
+}
+
+// outputMetadataFor reads the well-known parameters of an output tensor. Units
+// are normalized and validated like configured units; unknown metric types are
+// ignored.
+func (mp *metricsinferenceprocessor) outputMetadataFor(rule *internalRule, tensor *pb.ModelMetadataResponse_TensorMetadata) outputMetadata {
+	params := tensor.GetParameters()
+	published := outputMetadata{
+		unit:        mp.config.Units.outputUnit(params[metadataParamUnit].GetStringParam()),
+		description: params[metadataParamDescription].GetStringParam(),
+	}
+
+	if mp.config.Units.Validation != unitValidationNone {
+		if err := validateUnit(published.unit, mp.config.Units.Allowed); err != nil {
+			mp.logger.Warn("Output unit in model metadata is not valid UCUM",
+				zap.String("model", rule.modelName),
+				zap.String("output", tensor.Name),
+				zap.Error(err))
+			if mp.config.Units.Validation == unitValidationStrict {
+				published.unit = ""
+			}
+		}
+	}
+
+	switch metricType := params[metadataParamMetricType].GetStringParam(); metricType {
+	case "", metricTypeGauge:
+	case metricTypeSum, metricTypeCounter:
+		published.metricType = metricType
+	default:
+		mp.logger.Warn("Unknown metric type in model metadata, using a gauge",
+			zap.String("model", rule.modelName),
+			zap.String("output", tensor.Name),
+			zap.String("metric_type", metricType))
+	}
+	return published
+}
+
+// applyMetricType converts the gauges an output produced into sums when the model
+// publishes the output as a sum or counter
+func applyMetricType(metrics pmetric.MetricSlice, first int, metricType string) {
+	if metricType == "" {
+		return
+	}
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if metric.Type() != pmetric.MetricTypeGauge {
+			continue
+		}
+		dps := pmetric.NewNumberDataPointSlice()
+		metric.Gauge().DataPoints().MoveAndAppendTo(dps)
+		sum := metric.SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		sum.SetIsMonotonic(metricType == metricTypeCounter)
+		dps.MoveAndAppendTo(sum.DataPoints())
+	}
+}
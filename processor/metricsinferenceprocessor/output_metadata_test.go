@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func stringParams(params map[string]string) map[string]*pb.InferParameter {
+	result := make(map[string]*pb.InferParameter, len(params))
+	for k, v := range params {
+		result[k] = &pb.InferParameter{ParameterChoice: &pb.InferParameter_StringParam{StringParam: v}}
+	}
+	return result
+}
+
+func TestOutputMetadataFromModel(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForMultipleOutputs("forecaster", []float64{100.5, 0.25})),
+		testutil.WithModelMetadata("forecaster", &pb.ModelMetadataResponse{
+			Name: "forecaster",
+			Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
+				{
+					Name:     "requests",
+					Datatype: "FP64",
+					Shape:    []int64{1},
+					Parameters: stringParams(map[string]string{
+						metadataParamUnit:        "{request}",
+						metadataParamDescription: "Requests expected in the next hour",
+						metadataParamMetricType:  metricTypeCounter,
+					}),
+				},
+				{
+					Name:       "latency",
+					Datatype:   "FP64",
+					Shape:      []int64{1},
+					Parameters: stringParams(map[string]string{metadataParamUnit: "seconds"}),
+				},
+			},
+		}))
+
+	tests := []struct {
+		name              string
+		outputs           []OutputSpec
+		requestsUnit      string
+		requestsDesc      string
+		latencyDescPrefix string
+	}{
+		{
+			name:              "discovered outputs",
+			requestsUnit:      "{request}",
+			requestsDesc:      "Requests expected in the next hour",
+			latencyDescPrefix: "Discovered output from model",
+		},
+		{
+			name: "configuration takes precedence",
+			outputs: []OutputSpec{
+				{Name: "forecast.requests", Unit: "1", Description: "Configured description"},
+				{Name: "forecast.latency"},
+			},
+			requestsUnit:      "1",
+			requestsDesc:      "Configured description",
+			latencyDescPrefix: "Inference result from model",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Units:              UnitsConfig{Normalize: true},
+				Rules: []Rule{{
+					ModelName:     "forecaster",
+					Inputs:        []string{"http.requests"},
+					Outputs:       tt.outputs,
+					OutputPattern: "forecast.{output}",
+				}},
+			}
+			if tt.outputs != nil {
+				cfg.Rules[0].OutputPattern = "{output}"
+			}
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zap.NewNop())
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"http.requests"}, MetricValues: [][]float64{{90}}})
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+			require.Len(t, sink.AllMetrics(), 1)
+			output := sink.AllMetrics()[0]
+
+			// The counter type published by the model is applied in both cases
+			requests := findMetricByName(output, "forecast.requests")
+			require.Equal(t, pmetric.MetricTypeSum, requests.Type())
+			assert.True(t, requests.Sum().IsMonotonic())
+			assert.Equal(t, pmetric.AggregationTemporalityCumulative, requests.Sum().AggregationTemporality())
+			require.Equal(t, 1, requests.Sum().DataPoints().Len())
+			assert.Equal(t, 100.5, requests.Sum().DataPoints().At(0).DoubleValue())
+			assert.Equal(t, tt.requestsUnit, requests.Unit())
+			assert.Equal(t, tt.requestsDesc, requests.Description())
+
+			// Published units are normalized like configured ones
+			latency := findMetricByName(output, "forecast.latency")
+			require.Equal(t, pmetric.MetricTypeGauge, latency.Type())
+			assert.Equal(t, "s", latency.Unit())
+			assert.Contains(t, latency.Description(), tt.latencyDescPrefix)
+		})
+	}
+}
+
+func TestOutputMetadataFor(t *testing.T) {
+	tensor := &pb.ModelMetadataResponse_TensorMetadata{
+		Name: "score",
+		Parameters: stringParams(map[string]string{
+			metadataParamUnit:       "bytes",
+			metadataParamMetricType: "histogram",
+		}),
+	}
+	rule := &internalRule{modelName: "scorer"}
+
+	// Unknown metric types fall back to gauges, invalid units are kept with a warning
+	mp := &metricsinferenceprocessor{config: &Config{}, logger: zap.NewNop()}
+	assert.Equal(t, outputMetadata{unit: "bytes"}, mp.outputMetadataFor(rule, tensor))
+
+	// ...unless validation is strict
+	mp.config.Units.Validation = unitValidationStrict
+	assert.Equal(t, outputMetadata{}, mp.outputMetadataFor(rule, tensor))
+
+	// Tensors without parameters publish nothing
+	assert.Equal(t, outputMetadata{}, mp.outputMetadataFor(rule, &pb.ModelMetadataResponse_TensorMetadata{Name: "score"}))
+}
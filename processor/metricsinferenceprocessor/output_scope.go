@@ -0,0 +1,26 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+// Output scopes
+const (
+	outputScopeInput     = "input"
+	outputScopeDedicated = "dedicated"
+)
+
+// inferenceScopeName is the instrumentation scope of outputs emitted in the
+// dedicated scope, and of outputs added to a resource without scopes
+const inferenceScopeName = "opentelemetry.inference"
+
+// ruleOutputScope resolves the name of the scope a rule's outputs are added to,
+// or an empty name when they are added to the scope of the rule's inputs
+func ruleOutputScope(cfg *Config, rule Rule) string {
+	if rule.Scope != "" {
+		return rule.Scope
+	}
+	if cfg.OutputScope == outputScopeDedicated {
+		return inferenceScopeName
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// scopeOfMetric returns the scope of the metric with the given name
+func scopeOfMetric(md pmetric.Metrics, name string) (pmetric.ScopeMetrics, bool) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		sms := md.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				if metrics.At(k).Name() == name {
+					return sms.At(j), true
+				}
+			}
+		}
+	}
+	return pmetric.ScopeMetrics{}, false
+}
+
+func TestOutputScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		outputScope string
+		ruleScope   string
+		scopeName   string
+		version     string
+	}{
+		{name: "input"},
+		{name: "dedicated", outputScope: outputScopeDedicated, scopeName: "opentelemetry.inference", version: "v0.9.0"},
+		{name: "rule", outputScope: outputScopeDedicated, ruleScope: "capacity.planning", scopeName: "capacity.planning", version: "v0.9.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				OutputScope:        tt.outputScope,
+				Rules: []Rule{
+					{
+						ModelName:     "scorer",
+						Inputs:        []string{"metric_1"},
+						OutputPattern: "scorer.{output}",
+						Scope:         tt.ruleScope,
+						Outputs:       []OutputSpec{{Name: "score"}},
+					},
+				},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			processor.scopeVersion = "v0.9.0"
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"metric_1"},
+				MetricValues: [][]float64{{1}},
+			})
+			inputScope := input.ResourceMetrics().At(0).ScopeMetrics().At(0).Scope().Name()
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+			output := sink.AllMetrics()[0]
+			sm, ok := scopeOfMetric(output, "scorer.score")
+			require.True(t, ok)
+			if tt.outputScope == "" {
+				tt.scopeName = inputScope
+			}
+			assert.Equal(t, tt.scopeName, sm.Scope().Name())
+			assert.Equal(t, tt.version, sm.Scope().Version())
+
+			// Inputs stay in their own scope
+			inputSM, ok := scopeOfMetric(output, "metric_1")
+			require.True(t, ok)
+			assert.Equal(t, inputScope, inputSM.Scope().Name())
+		})
+	}
+}
+
+func TestValidateOutputScope(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x"}}},
+	}
+	for _, outputScope := range []string{"", "input", "dedicated"} {
+		cfg.OutputScope = outputScope
+		assert.NoError(t, cfg.Validate())
+	}
+	cfg.OutputScope = "per_rule"
+	assert.ErrorContains(t, cfg.Validate(), `invalid output_scope "per_rule"`)
+}
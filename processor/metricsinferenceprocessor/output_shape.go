@@ -0,0 +1,208 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Column decoding modes for two-dimensional output tensors
+const (
+	columnsModeIndex = "index"
+	columnsModeSplit = "split"
+)
+
+// defaultIndexAttribute holds the column of a data point in "index" mode
+const defaultIndexAttribute = "otel.inference.output.index"
+
+// tensorMatrixShape interprets an output shape as [rows, columns], folding trailing
+// dimensions into the columns. ok is false for shapes without multiple columns,
+// which are decoded as a flat sequence of values.
+func tensorMatrixShape(shape []int64) (rows, cols int, ok bool) {
+	if len(shape) < 2 || shape[0] < 0 {
+		return 0, 0, false
+	}
+	cols = 1
+	for _, dim := range shape[1:] {
+		if dim < 0 {
+			return 0, 0, false
+		}
+		cols *= int(dim)
+	}
+	if cols <= 1 {
+		return 0, 0, false
+	}
+	return int(shape[0]), cols, true
+}
+
+// elementMatrixShape interprets an output shape as rows of a fixed number of named
+// elements, whatever its dimensions. A tensor whose size is not a multiple of the
+// number of elements is read as a single row, so decoding reports the mismatch.
+func elementMatrixShape(shape []int64, elements int) (rows, cols int, ok bool) {
+	size := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			return 0, 0, false
+		}
+		size *= int(dim)
+	}
+	if size == 0 || size%elements != 0 {
+		return 1, size, true
+	}
+	return size / elements, elements, true
+}
+
+// outputColumnNames returns the names of the columns of an output: its element
+// names, or the names of the columns of shaped tensors
+func outputColumnNames(output OutputSpec) []string {
+	if len(output.ElementNames) > 0 {
+		return output.ElementNames
+	}
+	return output.ColumnNames
+}
+
+// tensorValue is a single numeric element of an output tensor
+type tensorValue struct {
+	double  float64
+	integer int64
+	isInt   bool
+}
+
+// tensorNumericValues flattens the numeric contents of an output tensor in row-major
+// order, reading the same contents as processOutputTensor for the output type
+func tensorNumericValues(tensor *pb.ModelInferResponse_InferOutputTensor, outputType string) ([]tensorValue, error) {
+	var values []tensorValue
+	if tensor.Contents == nil {
+		return values, nil
+	}
+
+	switch outputType {
+	case "float", "double":
+		for _, val := range tensor.Contents.Fp64Contents {
+			values = append(values, tensorValue{double: val})
+		}
+		for _, val := range tensor.Contents.Fp32Contents {
+			values = append(values, tensorValue{double: float64(val)})
+		}
+	case "int", "int64", "int32":
+		for _, val := range tensor.Contents.Int64Contents {
+			values = append(values, tensorValue{integer: val, isInt: true})
+		}
+		for _, val := range tensor.Contents.IntContents {
+			values = append(values, tensorValue{integer: int64(val), isInt: true})
+		}
+	case "bool":
+		for _, val := range tensor.Contents.BoolContents {
+			if val {
+				values = append(values, tensorValue{double: 1})
+			} else {
+				values = append(values, tensorValue{double: 0})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported output data type for shaped output: %s", outputType)
+	}
+	return values, nil
+}
+
+// processShapedOutputTensor decodes a [rows, columns] output tensor. Rows map to the
+// matched attribute groups in order; columns become either an index attribute on each
+// data point or separate metrics suffixed with the column name.
+func (mp *metricsinferenceprocessor) processShapedOutputTensor(sm pmetric.ScopeMetrics, metric pmetric.Metric, outputTensor *pb.ModelInferResponse_InferOutputTensor, outputType, metricName string, outputSpec internalOutputSpec, rows, cols int, context *modelContext) error {
+	values, err := tensorNumericValues(outputTensor, outputType)
+	if err != nil {
+		return err
+	}
+	if len(values) != rows*cols {
+		return fmt.Errorf("output tensor has %d values, expected %d for shape %v", len(values), rows*cols, outputTensor.Shape)
+	}
+	if context != nil && len(context.matchedDataPoints) > 0 && rows != len(context.matchedDataPoints) {
+		return fmt.Errorf("output tensor has %d rows but there are %d matched data point groups", rows, len(context.matchedDataPoints))
+	}
+	if len(outputSpec.columnNames) > 0 && len(outputSpec.columnNames) != cols {
+		return fmt.Errorf("output tensor has %d columns but %d column names are configured", cols, len(outputSpec.columnNames))
+	}
+
+	column := func(c int) string {
+		if len(outputSpec.columnNames) > 0 {
+			return outputSpec.columnNames[c]
+		}
+		return strconv.Itoa(c)
+	}
+
+	setValue := func(dp pmetric.NumberDataPoint, value tensorValue) {
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+		if value.isInt {
+			setIntOrTransformedValue(dp, value.integer, outputSpec.post)
+		} else {
+			dp.SetDoubleValue(applyPostTransforms(value.double, outputSpec.post))
+		}
+	}
+
+	if outputSpec.columns == columnsModeSplit {
+		for c := 0; c < cols; c++ {
+			columnMetric := metric
+			if c > 0 {
+				columnMetric = sm.Metrics().AppendEmpty()
+				columnMetric.SetDescription(metric.Description())
+				columnMetric.SetUnit(metric.Unit())
+			}
+			columnMetric.SetName(fmt.Sprintf("%s.%s", metricName, column(c)))
+			dps := columnMetric.SetEmptyGauge().DataPoints()
+			for r := 0; r < rows; r++ {
+				dp := dps.AppendEmpty()
+				setValue(dp, values[r*cols+c])
+				copyAttributesFromDataPointGroup(dp, context, r)
+			}
+		}
+		return nil
+	}
+
+	indexAttribute := outputSpec.indexAttribute
+	if indexAttribute == "" {
+		indexAttribute = defaultIndexAttribute
+	}
+	dps := metric.SetEmptyGauge().DataPoints()
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			dp := dps.AppendEmpty()
+			setValue(dp, values[r*cols+c])
+			copyAttributesFromDataPointGroup(dp, context, r)
+			dp.Attributes().PutStr(indexAttribute, column(c))
+		}
+	}
+	return nil
+}
+
+// validateOutputColumns checks the shaped output options of an output specification
+func validateOutputColumns(output OutputSpec) error {
+	switch output.Columns {
+	case "", columnsModeIndex, columnsModeSplit:
+	default:
+		return fmt.Errorf("invalid columns mode %q (must be 'index' or 'split')", output.Columns)
+	}
+
+	if len(output.ColumnNames) > 0 && len(output.ElementNames) > 0 {
+		return fmt.Errorf("element_names cannot be combined with column_names")
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range outputColumnNames(output) {
+		if name == "" {
+			return fmt.Errorf("column names must not be empty")
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate column name %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
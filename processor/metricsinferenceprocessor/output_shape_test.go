@@ -0,0 +1,230 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestTensorMatrixShape(t *testing.T) {
+	tests := []struct {
+		shape      []int64
+		rows, cols int
+		ok         bool
+	}{
+		{shape: nil},
+		{shape: []int64{4}},
+		{shape: []int64{4, 1}},
+		{shape: []int64{4, 3}, rows: 4, cols: 3, ok: true},
+		{shape: []int64{2, 3, 2}, rows: 2, cols: 6, ok: true},
+		{shape: []int64{-1, 3}},
+	}
+
+	for _, tt := range tests {
+		rows, cols, ok := tensorMatrixShape(tt.shape)
+		assert.Equal(t, tt.ok, ok, "shape %v", tt.shape)
+		assert.Equal(t, tt.rows, rows, "shape %v", tt.shape)
+		assert.Equal(t, tt.cols, cols, "shape %v", tt.shape)
+	}
+}
+
+func TestElementMatrixShape(t *testing.T) {
+	tests := []struct {
+		shape      []int64
+		rows, cols int
+		ok         bool
+	}{
+		{shape: []int64{3}, rows: 1, cols: 3, ok: true},
+		{shape: []int64{1, 3}, rows: 1, cols: 3, ok: true},
+		{shape: []int64{6}, rows: 2, cols: 3, ok: true},
+		{shape: []int64{4}, rows: 1, cols: 4, ok: true},
+		{shape: []int64{-1}},
+	}
+
+	for _, tt := range tests {
+		rows, cols, ok := elementMatrixShape(tt.shape, 3)
+		assert.Equal(t, tt.ok, ok, "shape %v", tt.shape)
+		assert.Equal(t, tt.rows, rows, "shape %v", tt.shape)
+		assert.Equal(t, tt.cols, cols, "shape %v", tt.shape)
+	}
+}
+
+func TestValidateOutputColumns(t *testing.T) {
+	assert.NoError(t, validateOutputColumns(OutputSpec{}))
+	assert.NoError(t, validateOutputColumns(OutputSpec{Columns: "split", ColumnNames: []string{"p10", "p90"}}))
+	assert.ErrorContains(t, validateOutputColumns(OutputSpec{Columns: "rows"}), "invalid columns mode")
+	assert.ErrorContains(t, validateOutputColumns(OutputSpec{ColumnNames: []string{"a", "a"}}), "duplicate column name")
+	assert.ErrorContains(t, validateOutputColumns(OutputSpec{ColumnNames: []string{""}}), "must not be empty")
+	assert.NoError(t, validateOutputColumns(OutputSpec{ElementNames: []string{"p50", "p90", "p99"}}))
+	assert.ErrorContains(t, validateOutputColumns(OutputSpec{ElementNames: []string{"p50", "p50"}}), "duplicate column name")
+	assert.ErrorContains(t, validateOutputColumns(OutputSpec{ColumnNames: []string{"a"}, ElementNames: []string{"b"}}), "cannot be combined")
+}
+
+// runShapedOutput sends two CPU series through a model returning the given tensor
+func runShapedOutput(t *testing.T, output OutputSpec, tensor *pb.ModelInferResponse_InferOutputTensor) pmetric.Metrics {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("quantile_model", &pb.ModelInferResponse{
+		ModelName: "quantile_model",
+		Outputs:   []*pb.ModelInferResponse_InferOutputTensor{tensor},
+	})
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "quantile_model",
+				Inputs:        []string{"cpu_usage"},
+				Outputs:       []OutputSpec{output},
+				OutputPattern: "{output}",
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{
+			MetricName: "cpu_usage",
+			DataPoints: []testutil.TestDataPoint{
+				{Value: 0.2, Attributes: map[string]string{"cpu": "0"}},
+				{Value: 0.4, Attributes: map[string]string{"cpu": "1"}},
+			},
+		},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	require.Len(t, sink.AllMetrics(), 1)
+	return sink.AllMetrics()[0]
+}
+
+// quantileTensor is a [2, 3] forecast with one row per CPU series
+func quantileTensor() *pb.ModelInferResponse_InferOutputTensor {
+	return &pb.ModelInferResponse_InferOutputTensor{
+		Name:     "forecast",
+		Datatype: "FP64",
+		Shape:    []int64{2, 3},
+		Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3, 4, 5, 6}},
+	}
+}
+
+func TestShapedOutputIndexColumns(t *testing.T) {
+	md := runShapedOutput(t, OutputSpec{Name: "forecast", ColumnNames: []string{"p10", "p50", "p90"}}, quantileTensor())
+
+	metric := findMetricByName(md, "forecast")
+	dps := metric.Gauge().DataPoints()
+	require.Equal(t, 6, dps.Len())
+
+	values := make(map[string]float64)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		cpu, _ := dp.Attributes().Get("cpu_usage.cpu")
+		column, _ := dp.Attributes().Get(defaultIndexAttribute)
+		values[cpu.AsString()+"/"+column.AsString()] = dp.DoubleValue()
+	}
+	assert.Equal(t, map[string]float64{
+		"0/p10": 1, "0/p50": 2, "0/p90": 3,
+		"1/p10": 4, "1/p50": 5, "1/p90": 6,
+	}, values)
+}
+
+func TestShapedOutputSplitColumns(t *testing.T) {
+	md := runShapedOutput(t, OutputSpec{Name: "forecast", Columns: "split", IndexAttribute: "unused"}, quantileTensor())
+
+	for column, expected := range map[string][]float64{"0": {1, 4}, "1": {2, 5}, "2": {3, 6}} {
+		metric := findMetricByName(md, "forecast."+column)
+		dps := metric.Gauge().DataPoints()
+		require.Equal(t, 2, dps.Len(), "column %s", column)
+		for row, value := range expected {
+			assert.Equal(t, value, dps.At(row).DoubleValue())
+			_, hasIndex := dps.At(row).Attributes().Get("unused")
+			assert.False(t, hasIndex)
+		}
+	}
+}
+
+func TestShapedOutputRowMismatch(t *testing.T) {
+	tensor := &pb.ModelInferResponse_InferOutputTensor{
+		Name:     "forecast",
+		Datatype: "FP64",
+		Shape:    []int64{3, 2},
+		Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3, 4, 5, 6}},
+	}
+	md := runShapedOutput(t, OutputSpec{Name: "forecast"}, tensor)
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			for k := 0; k < sms.At(j).Metrics().Len(); k++ {
+				assert.NotEqual(t, "forecast", sms.At(j).Metrics().At(k).Name(), "mismatched rows must not produce an output")
+			}
+		}
+	}
+}
+
+// flatQuantileTensor is the forecast of quantileTensor as a flat vector of three
+// elements per CPU series
+func flatQuantileTensor() *pb.ModelInferResponse_InferOutputTensor {
+	tensor := quantileTensor()
+	tensor.Shape = []int64{6}
+	return tensor
+}
+
+func TestOutputElementNamesSplit(t *testing.T) {
+	md := runShapedOutput(t, OutputSpec{Name: "latency", Columns: "split", ElementNames: []string{"p50", "p90", "p99"}}, flatQuantileTensor())
+
+	for element, expected := range map[string][]float64{"p50": {1, 4}, "p90": {2, 5}, "p99": {3, 6}} {
+		dps := findMetricByName(md, "latency."+element).Gauge().DataPoints()
+		require.Equal(t, 2, dps.Len(), "element %s", element)
+		for row, value := range expected {
+			assert.Equal(t, value, dps.At(row).DoubleValue())
+		}
+	}
+}
+
+func TestOutputElementNamesAttribute(t *testing.T) {
+	md := runShapedOutput(t, OutputSpec{Name: "latency", ElementNames: []string{"p50", "p90", "p99"}, IndexAttribute: "quantile"}, flatQuantileTensor())
+
+	dps := findMetricByName(md, "latency").Gauge().DataPoints()
+	require.Equal(t, 6, dps.Len())
+	values := make(map[string]float64)
+	for i := 0; i < dps.Len(); i++ {
+		cpu, _ := dps.At(i).Attributes().Get("cpu_usage.cpu")
+		quantile, _ := dps.At(i).Attributes().Get("quantile")
+		values[cpu.AsString()+"/"+quantile.AsString()] = dps.At(i).DoubleValue()
+	}
+	assert.Equal(t, map[string]float64{
+		"0/p50": 1, "0/p90": 2, "0/p99": 3,
+		"1/p50": 4, "1/p90": 5, "1/p99": 6,
+	}, values)
+}
+
+func TestOutputElementNamesSizeMismatch(t *testing.T) {
+	tensor := flatQuantileTensor()
+	tensor.Shape = []int64{5}
+	tensor.Contents.Fp64Contents = tensor.Contents.Fp64Contents[:5]
+	md := runShapedOutput(t, OutputSpec{Name: "latency", ElementNames: []string{"p50", "p90", "p99"}}, tensor)
+
+	// A vector that does not hold whole rows of elements produces no output
+	assert.Empty(t, findMetricByName(md, "latency").Name())
+}
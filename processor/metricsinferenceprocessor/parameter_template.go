@@ -0,0 +1,211 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Variables of parameter templates
+const (
+	templateFirstTimestamp = "first_timestamp"
+	templateLastTimestamp  = "last_timestamp"
+	templateCount          = "count"
+	templateAttr           = "attr"
+	templateMin            = "min"
+	templateMax            = "max"
+	templateMean           = "mean"
+	templateSum            = "sum"
+	templateLast           = "last"
+)
+
+// templateVariableRegex matches a template variable such as {count} or
+// {attr:host.name}. Braces around anything else, such as JSON, are left as is.
+var templateVariableRegex = regexp.MustCompile(`\{([a-z_]+)(?::([^{}]+))?\}`)
+
+// newParameterTemplates returns the string parameters of a rule that reference
+// template variables, by parameter name
+func newParameterTemplates(params map[string]interface{}) map[string]string {
+	var templates map[string]string
+	for name, value := range params {
+		s, ok := value.(string)
+		if !ok || !templateVariableRegex.MatchString(s) {
+			continue
+		}
+		if templates == nil {
+			templates = make(map[string]string)
+		}
+		templates[name] = s
+	}
+	return templates
+}
+
+// validateParameterTemplates checks the variables of a rule's templated
+// parameters. Aggregates must name one of the rule's inputs.
+func validateParameterTemplates(rule Rule) error {
+	for name, template := range newParameterTemplates(rule.Parameters) {
+		for _, match := range templateVariableRegex.FindAllStringSubmatch(template, -1) {
+			variable, arg := match[1], match[2]
+			switch variable {
+			case templateFirstTimestamp, templateLastTimestamp, templateCount:
+				if arg != "" {
+					return fmt.Errorf("parameter %q: variable {%s} takes no argument", name, variable)
+				}
+			case templateAttr:
+				if arg == "" {
+					return fmt.Errorf("parameter %q: variable {attr} requires an attribute key", name)
+				}
+			case templateMin, templateMax, templateMean, templateSum, templateLast:
+				if !slices.Contains(rule.Inputs, arg) {
+					return fmt.Errorf("parameter %q: variable {%s} must name an input of the rule, got %q", name, variable, arg)
+				}
+			default:
+				return fmt.Errorf("parameter %q: unknown template variable {%s}", name, variable)
+			}
+		}
+	}
+	return nil
+}
+
+// applyParameterTemplates renders the templated parameters of a rule from the
+// inputs of its request, replacing their configured values. A variable that
+// cannot be rendered, such as a missing attribute, fails the request rather
+// than sending the model a parameter without its context.
+func (mp *metricsinferenceprocessor) applyParameterTemplates(ruleIdx int, request *pb.ModelInferRequest, context *modelContext) error {
+	templates := mp.rules[ruleIdx].paramTemplates
+	if len(templates) == 0 {
+		return nil
+	}
+
+	for name, template := range templates {
+		var renderErr error
+		rendered := templateVariableRegex.ReplaceAllStringFunc(template, func(variable string) string {
+			match := templateVariableRegex.FindStringSubmatch(variable)
+			value, err := renderTemplateVariable(match[1], match[2], context)
+			if err != nil && renderErr == nil {
+				renderErr = err
+			}
+			return value
+		})
+		if renderErr != nil {
+			return fmt.Errorf("failed to render parameter %q: %w", name, renderErr)
+		}
+		if request.Parameters == nil {
+			request.Parameters = make(map[string]*pb.InferParameter)
+		}
+		request.Parameters[name] = &pb.InferParameter{
+			ParameterChoice: &pb.InferParameter_StringParam{StringParam: rendered},
+		}
+	}
+	return nil
+}
+
+// errNoTemplateData is returned when a template variable reads the data points
+// of a request that has none
+var errNoTemplateData = errors.New("no data points to render the template from")
+
+// renderTemplateVariable returns the value of one template variable for a request
+func renderTemplateVariable(variable, arg string, context *modelContext) (string, error) {
+	switch variable {
+	case templateFirstTimestamp, templateLastTimestamp, templateCount:
+		var first, last pcommon.Timestamp
+		count := 0
+		for _, metric := range context.inputs {
+			for _, dp := range extractDataPoints(metric) {
+				ts := dp.Timestamp()
+				if count == 0 || ts < first {
+					first = ts
+				}
+				if ts > last {
+					last = ts
+				}
+				count++
+			}
+		}
+		switch {
+		case variable == templateCount:
+			return strconv.Itoa(count), nil
+		case count == 0:
+			return "", errNoTemplateData
+		case variable == templateFirstTimestamp:
+			return formatTemplateTimestamp(first), nil
+		}
+		return formatTemplateTimestamp(last), nil
+
+	case templateAttr:
+		return templateAttribute(arg, context)
+	}
+
+	metric, exists := context.inputs[arg]
+	if !exists {
+		return "", fmt.Errorf("input %q is missing", arg)
+	}
+	dps := extractNumberDataPoints(metric)
+	if len(dps) == 0 {
+		return "", fmt.Errorf("input %q has no number data points", arg)
+	}
+	var result float64
+	switch variable {
+	case templateMin:
+		result = math.Inf(1)
+		for _, dp := range dps {
+			result = math.Min(result, dataPointValue(dp))
+		}
+	case templateMax:
+		result = math.Inf(-1)
+		for _, dp := range dps {
+			result = math.Max(result, dataPointValue(dp))
+		}
+	case templateSum, templateMean:
+		for _, dp := range dps {
+			result += dataPointValue(dp)
+		}
+		if variable == templateMean {
+			result /= float64(len(dps))
+		}
+	case templateLast:
+		result = dataPointValue(dps[len(dps)-1])
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+// templateAttribute looks an attribute up for a request: the value an expanded
+// rule infers for, then the attributes of the first matched group, the resource
+// and the scope of the inputs. The value is hashed or redacted by the rule's
+// attribute privacy.
+func templateAttribute(key string, context *modelContext) (string, error) {
+	privacy := context.rule.privacy
+	if context.expansion != nil && key == context.rule.expandBy {
+		return privacy.apply(key, context.expansion.value, true), nil
+	}
+	if len(context.matchedDataPoints) > 0 {
+		if value, ok := context.matchedDataPoints[0].attributes.Get(key); ok {
+			return privacy.value(key, value, false), nil
+		}
+	}
+	if context.hasContext {
+		if value, ok := context.resourceMetrics.Resource().Attributes().Get(key); ok {
+			return privacy.value(key, value, true), nil
+		}
+		if value, ok := context.scopeMetrics.Scope().Attributes().Get(key); ok {
+			return privacy.value(key, value, false), nil
+		}
+	}
+	return "", fmt.Errorf("attribute %q not found", key)
+}
+
+// formatTemplateTimestamp renders a data point timestamp as RFC 3339 in UTC
+func formatTemplateTimestamp(ts pcommon.Timestamp) string {
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
+}
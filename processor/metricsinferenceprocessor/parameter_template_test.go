@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestParameterTemplates(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "forecaster",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "forecast.{output}",
+			Outputs:       []OutputSpec{{Name: "value"}},
+			Parameters: map[string]interface{}{
+				"window_start": "{first_timestamp}",
+				"window_end":   "{last_timestamp}",
+				"entity":       "{attr:host.name}",
+				"summary":      "{count} points, mean {mean:cpu}, max {max:cpu}",
+				"config":       `{"mode":"fast"}`,
+				"horizon":      12,
+			},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host.name", "web-1")
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu")
+	gauge := metric.SetEmptyGauge()
+	for i, value := range []float64{1, 2, 6} {
+		dp := gauge.DataPoints().AppendEmpty()
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(i) * time.Minute)))
+		dp.SetDoubleValue(value)
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	params := requests[0].Parameters
+	assert.Equal(t, "2026-01-02T03:04:05Z", params["window_start"].GetStringParam())
+	assert.Equal(t, "2026-01-02T03:06:05Z", params["window_end"].GetStringParam())
+	assert.Equal(t, "web-1", params["entity"].GetStringParam())
+	assert.Equal(t, "3 points, mean 3, max 6", params["summary"].GetStringParam())
+
+	// Parameters without template variables are sent as configured
+	assert.Equal(t, `{"mode":"fast"}`, params["config"].GetStringParam())
+	assert.Equal(t, int64(12), params["horizon"].GetInt64Param())
+}
+
+func TestParameterTemplateMissingAttribute(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "forecaster",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "forecast.{output}",
+			Outputs:       []OutputSpec{{Name: "value"}},
+			Parameters:    map[string]interface{}{"entity": "{attr:host.name}"},
+		}},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// A parameter that cannot be rendered fails the request instead of being sent
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[0], "forecast.value").Name())
+}
+
+func TestValidateParameterTemplates(t *testing.T) {
+	rule := func(value string) Rule {
+		return Rule{ModelName: "m", Inputs: []string{"cpu"}, Parameters: map[string]interface{}{"p": value}}
+	}
+	assert.NoError(t, validateParameterTemplates(rule("{first_timestamp}/{attr:host.name}/{sum:cpu}")))
+	assert.NoError(t, validateParameterTemplates(rule(`{"literal": true}`)))
+	assert.ErrorContains(t, validateParameterTemplates(rule("{window}")), "unknown template variable")
+	assert.ErrorContains(t, validateParameterTemplates(rule("{mean:memory}")), "must name an input")
+	assert.ErrorContains(t, validateParameterTemplates(rule("{count:cpu}")), "takes no argument")
+}
@@ -0,0 +1,226 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Ways response parameters are surfaced
+const (
+	responseParameterAsAttribute = "attribute" // an attribute on the rule's output data points
+	responseParameterAsMetric    = "metric"    // a gauge of its own
+)
+
+// inferParameter converts a configured parameter value to a request parameter.
+// The protocol only has bool, int64 and string parameters, so floats are sent as
+// strings, and nested maps and lists as JSON strings, which servers such as
+// MLServer decode as structured parameters.
+func inferParameter(value interface{}) (*pb.InferParameter, error) {
+	param := &pb.InferParameter{}
+	switch val := value.(type) {
+	case bool:
+		param.ParameterChoice = &pb.InferParameter_BoolParam{BoolParam: val}
+	case int:
+		param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: int64(val)}
+	case int64:
+		param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: val}
+	case float32:
+		param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%f", val)}
+	case float64:
+		param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%f", val)}
+	case string:
+		param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: val}
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: string(encoded)}
+	default:
+		// Convert anything else to string
+		param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%v", val)}
+	}
+	return param, nil
+}
+
+// newInferParameters converts a rule's configured parameters to request
+// parameters, or returns nil when it has none. Values were checked by
+// validateParameters, so none fails to convert.
+func newInferParameters(params map[string]interface{}) map[string]*pb.InferParameter {
+	if len(params) == 0 {
+		return nil
+	}
+	converted := make(map[string]*pb.InferParameter, len(params))
+	for name, value := range params {
+		if param, err := inferParameter(value); err == nil {
+			converted[name] = param
+		}
+	}
+	return converted
+}
+
+// validateParameters checks that a rule's parameters can be sent, structured
+// values being encoded as JSON
+func validateParameters(params map[string]interface{}) error {
+	for name, value := range params {
+		if _, err := inferParameter(value); err != nil {
+			return fmt.Errorf("parameter %q cannot be encoded as JSON: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// responseParameter is the internal form of a surfaced response parameter, with defaults applied
+type responseParameter struct {
+	key  string
+	as   string
+	name string
+}
+
+// newResponseParameters converts a rule's response parameter configuration, applying defaults
+func newResponseParameters(cfgs []ResponseParameterConfig) []responseParameter {
+	surfaced := make([]responseParameter, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		param := responseParameter{key: cfg.Key, as: cfg.As, name: cfg.Name}
+		if param.as == "" {
+			param.as = responseParameterAsAttribute
+		}
+		if param.name == "" {
+			param.name = param.key
+		}
+		surfaced = append(surfaced, param)
+	}
+	return surfaced
+}
+
+// validateResponseParameters checks a rule's surfaced response parameters
+func validateResponseParameters(cfgs []ResponseParameterConfig) error {
+	seen := make(map[string]bool, len(cfgs))
+	for i, param := range newResponseParameters(cfgs) {
+		if param.key == "" {
+			return fmt.Errorf("response parameter %d: key must not be empty", i)
+		}
+		switch param.as {
+		case responseParameterAsAttribute, responseParameterAsMetric:
+		default:
+			return fmt.Errorf("response parameter %q: invalid as %q (must be 'attribute' or 'metric')", param.key, param.as)
+		}
+		if seen[param.as+"/"+param.name] {
+			return fmt.Errorf("response parameter %q: %s name %q is already used", param.key, param.as, param.name)
+		}
+		seen[param.as+"/"+param.name] = true
+	}
+	return nil
+}
+
+// errNotNumeric is returned for response parameters whose value is not a number
+var errNotNumeric = errors.New("value is not numeric")
+
+// parameterNumber returns the value of a response parameter as a number: bools
+// are 1 or 0, and strings are parsed, as floats are sent as strings
+func parameterNumber(param *pb.InferParameter) (float64, error) {
+	switch choice := param.ParameterChoice.(type) {
+	case *pb.InferParameter_BoolParam:
+		if choice.BoolParam {
+			return 1, nil
+		}
+		return 0, nil
+	case *pb.InferParameter_Int64Param:
+		return float64(choice.Int64Param), nil
+	case *pb.InferParameter_StringParam:
+		value, err := strconv.ParseFloat(choice.StringParam, 64)
+		if err != nil {
+			return 0, errNotNumeric
+		}
+		return value, nil
+	}
+	return 0, errNotNumeric
+}
+
+// putParameter sets an attribute to the value of a response parameter, keeping its type
+func putParameter(attrs pcommon.Map, name string, param *pb.InferParameter) {
+	switch choice := param.ParameterChoice.(type) {
+	case *pb.InferParameter_BoolParam:
+		attrs.PutBool(name, choice.BoolParam)
+	case *pb.InferParameter_Int64Param:
+		attrs.PutInt(name, choice.Int64Param)
+	case *pb.InferParameter_StringParam:
+		attrs.PutStr(name, choice.StringParam)
+	}
+}
+
+// surfaceResponseParameters adds the response-level parameters a rule surfaces,
+// such as the is_drift and p_val of a drift detector, to the metrics its response
+// added from index first on: as attributes of every data point, or as gauges of
+// their own carrying the model labels. Parameters missing from the response are
+// left out.
+func (mp *metricsinferenceprocessor) surfaceResponseParameters(sm pmetric.ScopeMetrics, first int, response *pb.ModelInferResponse, context *modelContext) {
+	surfaced := context.rule.responseParams
+	if len(surfaced) == 0 {
+		return
+	}
+
+	added := sm.Metrics().Len()
+	timestamp := pcommon.NewTimestampFromTime(time.Now())
+	for _, surface := range surfaced {
+		param, ok := response.Parameters[surface.key]
+		if !ok || param.ParameterChoice == nil {
+			continue
+		}
+
+		if surface.as == responseParameterAsAttribute {
+			for i := first; i < added; i++ {
+				forEachDataPointAttributes(sm.Metrics().At(i), func(attrs pcommon.Map) {
+					putParameter(attrs, surface.name, param)
+				})
+			}
+			continue
+		}
+
+		value, err := parameterNumber(param)
+		if err != nil {
+			mp.logLimiter.Warn(context.ruleIndex, "Response parameter is not numeric, not emitting its metric",
+				zap.String("model", context.rule.modelName),
+				zap.String("parameter", surface.key),
+				zap.Error(err))
+			continue
+		}
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(surface.name)
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(timestamp)
+		dp.SetDoubleValue(value)
+		if context.rule.inferenceLabels {
+			putModelLabels(dp.Attributes(), &context.rule)
+		}
+	}
+}
+
+// forEachDataPointAttributes calls fn with the attributes of every data point of
+// an output metric
+func forEachDataPointAttributes(metric pmetric.Metric, fn func(pcommon.Map)) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		dps, _ := numberDataPoints(metric)
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestInferParameters(t *testing.T) {
+	params := newInferParameters(map[string]interface{}{
+		"enabled":   true,
+		"limit":     10,
+		"threshold": 0.5,
+		"mode":      "fast",
+		"detector": map[string]interface{}{
+			"p_val":    0.05,
+			"features": []interface{}{"cpu", "memory"},
+		},
+		"windows": []interface{}{5, 15},
+	})
+	assert.True(t, params["enabled"].GetBoolParam())
+	assert.Equal(t, int64(10), params["limit"].GetInt64Param())
+	assert.Equal(t, "0.500000", params["threshold"].GetStringParam())
+	assert.Equal(t, "fast", params["mode"].GetStringParam())
+	assert.JSONEq(t, `{"p_val": 0.05, "features": ["cpu", "memory"]}`, params["detector"].GetStringParam())
+	assert.JSONEq(t, `[5, 15]`, params["windows"].GetStringParam())
+
+	assert.Nil(t, newInferParameters(nil))
+
+	assert.NoError(t, validateParameters(map[string]interface{}{"detector": map[string]interface{}{"drift": true}}))
+	assert.ErrorContains(t, validateParameters(map[string]interface{}{"detector": map[string]interface{}{"fn": func() {}}}), "cannot be encoded as JSON")
+}
+
+func TestValidateResponseParameters(t *testing.T) {
+	assert.NoError(t, validateResponseParameters([]ResponseParameterConfig{
+		{Key: "is_drift"},
+		{Key: "is_drift", As: responseParameterAsMetric, Name: "drift.detected"},
+	}))
+	assert.ErrorContains(t, validateResponseParameters([]ResponseParameterConfig{{}}), "key must not be empty")
+	assert.ErrorContains(t, validateResponseParameters([]ResponseParameterConfig{{Key: "p_val", As: "tensor"}}), "invalid as")
+	assert.ErrorContains(t, validateResponseParameters([]ResponseParameterConfig{
+		{Key: "p_val"},
+		{Key: "distance", Name: "p_val"},
+	}), "already used")
+}
+
+func TestResponseParameterAttributesWithSeriesTracking(t *testing.T) {
+	newConfig := func(as string) *Config {
+		cfg := &Config{
+			GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+			Timeout:            5,
+			Rules: []Rule{{
+				ModelName:          "latency_drift",
+				Inputs:             []string{"http.server.duration"},
+				ResponseParameters: []ResponseParameterConfig{{Key: "p_val", As: as}},
+			}},
+		}
+		cfg.Staleness.Period = time.Minute
+		return cfg
+	}
+	assert.ErrorContains(t, newConfig("").Validate(), "cannot be combined with staleness or cardinality.max_series")
+	assert.ErrorContains(t, newConfig(responseParameterAsAttribute).Validate(), "parameter \"p_val\" surfaced as an attribute")
+	assert.NoError(t, newConfig(responseParameterAsMetric).Validate())
+
+	cfg := newConfig("")
+	cfg.Staleness.Period = 0
+	assert.NoError(t, cfg.Validate())
+	cfg.Cardinality.MaxSeries = 100
+	assert.ErrorContains(t, cfg.Validate(), "cannot be combined with staleness or cardinality.max_series")
+}
+
+func TestParameterNumber(t *testing.T) {
+	number := func(param *pb.InferParameter) float64 {
+		value, err := parameterNumber(param)
+		require.NoError(t, err)
+		return value
+	}
+	assert.Equal(t, 1.0, number(&pb.InferParameter{ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: true}}))
+	assert.Equal(t, 0.0, number(&pb.InferParameter{ParameterChoice: &pb.InferParameter_BoolParam{}}))
+	assert.Equal(t, 42.0, number(&pb.InferParameter{ParameterChoice: &pb.InferParameter_Int64Param{Int64Param: 42}}))
+	assert.Equal(t, 0.031, number(&pb.InferParameter{ParameterChoice: &pb.InferParameter_StringParam{StringParam: "0.031"}}))
+
+	_, err := parameterNumber(&pb.InferParameter{ParameterChoice: &pb.InferParameter_StringParam{StringParam: "drift"}})
+	assert.ErrorIs(t, err, errNotNumeric)
+}
+
+func TestResponseParameters(t *testing.T) {
+	response := testutil.CreateMockResponseForCalculation("drift_detector", 0.8)
+	response.Parameters = map[string]*pb.InferParameter{
+		"is_drift": {ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: true}},
+		"p_val":    {ParameterChoice: &pb.InferParameter_StringParam{StringParam: "0.031"}},
+		"method":   {ParameterChoice: &pb.InferParameter_StringParam{StringParam: "ks"}},
+	}
+	mockServer := testutil.StartMockServer(t, testutil.WithModelResponse("drift_detector", response))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "drift_detector",
+			Inputs:        []string{"latency"},
+			OutputPattern: "drift.{output}",
+			Outputs:       []OutputSpec{{Name: "distance"}},
+			Parameters: map[string]interface{}{
+				"detector": map[string]interface{}{"window": 100, "features": []interface{}{"latency"}},
+			},
+			ResponseParameters: []ResponseParameterConfig{
+				{Key: "is_drift"},
+				{Key: "p_val", As: responseParameterAsMetric, Name: "drift.p_value"},
+				{Key: "method", As: responseParameterAsMetric},
+				{Key: "threshold"},
+			},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"latency"},
+		MetricValues: [][]float64{{120}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Structured parameters are sent as JSON
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.JSONEq(t, `{"window": 100, "features": ["latency"]}`, requests[0].Parameters["detector"].GetStringParam())
+
+	output := sink.AllMetrics()[0]
+	distance := findMetricByName(output, "drift.distance").Gauge().DataPoints().At(0)
+	drift, ok := distance.Attributes().Get("is_drift")
+	require.True(t, ok)
+	assert.True(t, drift.Bool())
+	_, ok = distance.Attributes().Get("threshold")
+	assert.False(t, ok, "parameters missing from the response are left out")
+
+	pValue := findMetricByName(output, "drift.p_value")
+	require.Equal(t, pmetric.MetricTypeGauge, pValue.Type())
+	assert.Equal(t, 0.031, pValue.Gauge().DataPoints().At(0).DoubleValue())
+	model, ok := pValue.Gauge().DataPoints().At(0).Attributes().Get(labelInferenceModelName)
+	require.True(t, ok)
+	assert.Equal(t, "drift_detector", model.Str())
+
+	// Non-numeric parameters emit no metric
+	assert.Equal(t, pmetric.MetricTypeEmpty, findMetricByName(output, "method").Type())
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// What happens to an attribute group that lacks some of a rule's inputs
+const (
+	partialGroupsSkip             = "skip"              // leave the group out (default)
+	partialGroupsBroadcastMissing = "broadcast_missing" // send NaN for the missing inputs
+	partialGroupsFillZero         = "fill_zero"         // send zero for the missing inputs
+)
+
+// validatePartialGroups checks a rule's partial_groups policy
+func validatePartialGroups(policy string) error {
+	switch policy {
+	case "", partialGroupsSkip, partialGroupsBroadcastMissing, partialGroupsFillZero:
+		return nil
+	}
+	return fmt.Errorf("invalid partial_groups %q (must be 'skip', 'broadcast_missing', or 'fill_zero')", policy)
+}
+
+// fillsPartialGroups reports whether a rule infers on groups lacking some inputs
+func (rule internalRule) fillsPartialGroups() bool {
+	return rule.partialGroups == partialGroupsBroadcastMissing || rule.partialGroups == partialGroupsFillZero
+}
+
+// fillPartialGroup completes a group with a data point for each input it lacks,
+// carrying the group's attributes and latest timestamp, and reports whether the
+// group is complete. Only gauge and sum inputs present in the batch are filled,
+// as the values of other types have no default.
+func fillPartialGroup(group dataPointGroup, attrs pcommon.Map, inputs map[string]pmetric.Metric, rule internalRule) bool {
+	if len(group.dataPoints) == len(rule.inputs) {
+		return true
+	}
+	if !rule.fillsPartialGroups() || len(group.dataPoints) == 0 {
+		return false
+	}
+
+	var timestamp pcommon.Timestamp
+	for _, dp := range group.dataPoints {
+		timestamp = max(timestamp, dp.Timestamp())
+	}
+	value := 0.0
+	if rule.partialGroups == partialGroupsBroadcastMissing {
+		value = math.NaN()
+	}
+
+	filled := make(map[string]dataPoint, len(rule.inputs)-len(group.dataPoints))
+	for _, name := range rule.inputs {
+		if _, exists := group.dataPoints[name]; exists {
+			continue
+		}
+		metric, exists := inputs[name]
+		if !exists {
+			return false
+		}
+		if _, ok := numberDataPoints(metric); !ok {
+			return false
+		}
+		dp := pmetric.NewNumberDataPoint()
+		attrs.CopyTo(dp.Attributes())
+		dp.SetTimestamp(timestamp)
+		dp.SetDoubleValue(value)
+		filled[name] = dp
+	}
+	for name, dp := range filled {
+		group.dataPoints[name] = dp
+	}
+	if group.attributes.Len() == 0 && attrs.Len() > 0 {
+		attrs.CopyTo(group.attributes)
+	}
+	return true
+}
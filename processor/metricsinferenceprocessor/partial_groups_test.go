@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestPartialGroups(t *testing.T) {
+	inputs := map[string]pmetric.Metric{
+		"a": newAttributedGauge("a", []map[string]string{{"cpu": "0"}, {"cpu": "1"}}),
+		"b": newAttributedGauge("b", []map[string]string{{"cpu": "1"}, {"cpu": "2"}}),
+	}
+
+	tests := []struct {
+		policy   string
+		expected []map[string]interface{}
+	}{
+		{
+			policy: partialGroupsSkip,
+			expected: []map[string]interface{}{
+				{"_attrs": "cpu=1", "a": "cpu=1=1", "b": "cpu=1=0"},
+			},
+		},
+		{
+			policy: partialGroupsFillZero,
+			expected: []map[string]interface{}{
+				{"_attrs": "cpu=0", "a": "cpu=0=0", "b": "cpu=0=0"},
+				{"_attrs": "cpu=1", "a": "cpu=1=1", "b": "cpu=1=0"},
+				{"_attrs": "cpu=2", "a": "cpu=2=0", "b": "cpu=2=1"},
+			},
+		},
+		{
+			policy: partialGroupsBroadcastMissing,
+			expected: []map[string]interface{}{
+				{"_attrs": "cpu=0", "a": "cpu=0=0", "b": "cpu=0=NaN"},
+				{"_attrs": "cpu=1", "a": "cpu=1=1", "b": "cpu=1=0"},
+				{"_attrs": "cpu=2", "a": "cpu=2=NaN", "b": "cpu=2=1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			rule := internalRule{inputs: []string{"a", "b"}, partialGroups: tt.policy}
+			assert.Equal(t, tt.expected, groupsSummary(newAttributeGroupIndex().match(inputs, rule)))
+			assert.Equal(t, tt.expected, groupsSummary(matchDataPointsByAttributes(inputs, rule)))
+		})
+	}
+}
+
+func TestPartialGroupsSkipUnfillableInputs(t *testing.T) {
+	histogram := pmetric.NewMetric()
+	histogram.SetName("latency")
+	dp := histogram.SetEmptyHistogram().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("cpu", "1")
+	dp2 := histogram.Histogram().DataPoints().AppendEmpty()
+	dp2.Attributes().PutStr("cpu", "2")
+
+	rule := internalRule{inputs: []string{"a", "latency"}, partialGroups: partialGroupsFillZero}
+	inputs := map[string]pmetric.Metric{
+		"a":       newAttributedGauge("a", []map[string]string{{"cpu": "0"}, {"cpu": "1"}}),
+		"latency": histogram,
+	}
+
+	// Histograms have no default value, so only the gauge is filled
+	groups := newAttributeGroupIndex().match(inputs, rule)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "cpu=1", attributeSetKey(groups[0].attributes))
+	assert.Equal(t, "cpu=2", attributeSetKey(groups[1].attributes))
+	assert.Equal(t, 0.0, groups[1].dataPoints["a"].(pmetric.NumberDataPoint).DoubleValue())
+}
+
+func TestValidatePartialGroups(t *testing.T) {
+	for _, policy := range []string{"", partialGroupsSkip, partialGroupsBroadcastMissing, partialGroupsFillZero} {
+		assert.NoError(t, validatePartialGroups(policy))
+	}
+	assert.ErrorContains(t, validatePartialGroups("nan"), "invalid partial_groups \"nan\"")
+}
@@ -8,12 +8,14 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// PatternEvaluator evaluates output naming patterns
+// PatternEvaluator evaluates output naming patterns and description templates
 type PatternEvaluator struct {
 	pattern string
 	rule    *internalRule
+	horizon string
 }
 
 // NewPatternEvaluator creates a new pattern evaluator
@@ -24,6 +26,27 @@ func NewPatternEvaluator(pattern string, rule *internalRule) *PatternEvaluator {
 	}
 }
 
+// WithHorizon sets the prediction horizon used for the {horizon} variable
+func (pe *PatternEvaluator) WithHorizon(horizon time.Duration) *PatternEvaluator {
+	pe.horizon = formatHorizon(horizon)
+	return pe
+}
+
+// formatHorizon renders a duration without zero-valued trailing units, e.g. "15m" instead of "15m0s"
+func formatHorizon(horizon time.Duration) string {
+	if horizon <= 0 {
+		return ""
+	}
+	result := horizon.String()
+	if strings.HasSuffix(result, "m0s") {
+		result = strings.TrimSuffix(result, "0s")
+	}
+	if strings.HasSuffix(result, "h0m") {
+		result = strings.TrimSuffix(result, "0m")
+	}
+	return result
+}
+
 // Evaluate processes the pattern and returns the final metric name
 func (pe *PatternEvaluator) Evaluate(outputName string) (string, error) {
 	result := pe.pattern
@@ -37,6 +60,9 @@ func (pe *PatternEvaluator) Evaluate(outputName string) (string, error) {
 	// Replace {version} with the model version
 	result = strings.ReplaceAll(result, "{version}", pe.rule.modelVersion)
 
+	// Replace {horizon} with the prediction horizon
+	result = strings.ReplaceAll(result, "{horizon}", pe.horizon)
+
 	// Replace {input} and {input[N]} patterns
 	result = pe.replaceInputVariables(result)
 
@@ -96,6 +122,18 @@ func (pe *PatternEvaluator) replaceInputVariables(pattern string) string {
 
 // validateOutputPattern validates the pattern syntax at configuration time
 func validateOutputPattern(pattern string) error {
+	return validatePatternVariables(pattern)
+}
+
+// validateDescriptionTemplate validates an output description template at configuration
+// time. Descriptions accept the output pattern variables plus {horizon}.
+func validateDescriptionTemplate(template string) error {
+	return validatePatternVariables(template, "horizon")
+}
+
+// validatePatternVariables checks brace balance and that every variable in the
+// pattern is an output pattern variable or one of the extra variables
+func validatePatternVariables(pattern string, extraVars ...string) error {
 	if pattern == "" {
 		return nil
 	}
@@ -114,6 +152,9 @@ func validateOutputPattern(pattern string) error {
 		"version": true,
 		"input":   true,
 	}
+	for _, name := range extraVars {
+		validVars[name] = true
+	}
 
 	// Also allow input[N] patterns
 	inputArrayRegex := regexp.MustCompile(`input\[\d+\]`)
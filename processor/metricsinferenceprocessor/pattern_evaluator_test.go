@@ -5,9 +5,11 @@ package metricsinferenceprocessor
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestPatternEvaluator_Evaluate(t *testing.T) {
@@ -286,3 +288,78 @@ func TestDefaultDecorateOutputName(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputDescription(t *testing.T) {
+	mp := &metricsinferenceprocessor{
+		config: &Config{},
+		logger: zap.NewNop(),
+	}
+	rule := &internalRule{
+		modelName:    "cpu-forecaster",
+		modelVersion: "3",
+		inputs:       []string{"system.cpu.utilization"},
+	}
+
+	tests := []struct {
+		name     string
+		spec     internalOutputSpec
+		expected string
+	}{
+		{
+			name:     "default description",
+			spec:     internalOutputSpec{name: "forecast"},
+			expected: "Inference result from model cpu-forecaster",
+		},
+		{
+			name:     "plain description",
+			spec:     internalOutputSpec{name: "forecast", description: "CPU forecast"},
+			expected: "CPU forecast",
+		},
+		{
+			name: "template with horizon",
+			spec: internalOutputSpec{
+				name:        "forecast",
+				description: "Predicted {horizon} CPU utilization from {input} by {model} v{version}",
+				horizon:     15 * time.Minute,
+			},
+			expected: "Predicted 15m CPU utilization from system.cpu.utilization by cpu-forecaster v3",
+		},
+		{
+			name: "output variable",
+			spec: internalOutputSpec{
+				name:        "upper_bound",
+				description: "{output} of the {horizon} forecast",
+				horizon:     90 * time.Minute,
+			},
+			expected: "upper_bound of the 1h30m forecast",
+		},
+		{
+			name:     "undefined variable is kept verbatim",
+			spec:     internalOutputSpec{name: "forecast", description: "Forecast for {region}"},
+			expected: "Forecast for {region}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mp.outputDescription(rule, 0, tt.spec, ""))
+		})
+	}
+}
+
+func TestFormatHorizon(t *testing.T) {
+	assert.Equal(t, "", formatHorizon(0))
+	assert.Equal(t, "30s", formatHorizon(30*time.Second))
+	assert.Equal(t, "15m", formatHorizon(15*time.Minute))
+	assert.Equal(t, "1m30s", formatHorizon(90*time.Second))
+	assert.Equal(t, "2h", formatHorizon(2*time.Hour))
+	assert.Equal(t, "1h30m", formatHorizon(90*time.Minute))
+}
+
+func TestValidateDescriptionTemplate(t *testing.T) {
+	assert.NoError(t, validateDescriptionTemplate(""))
+	assert.NoError(t, validateDescriptionTemplate("Predicted {horizon} {output} from {input[0]} by {model} v{version}"))
+	assert.ErrorContains(t, validateDescriptionTemplate("Forecast for {region}"), "invalid variable: region")
+	assert.ErrorContains(t, validateDescriptionTemplate("Forecast {horizon"), "unbalanced braces")
+	assert.ErrorContains(t, validateOutputPattern("{model}.{horizon}"), "invalid variable: horizon")
+}
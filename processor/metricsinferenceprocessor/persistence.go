@@ -0,0 +1,549 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// stateStorageKey is the storage key the processor state is saved under
+const stateStorageKey = "state"
+
+// defaultStorageInterval is how often the state is saved when no interval is configured
+const defaultStorageInterval = time.Minute
+
+// persistedState is the state saved to the storage extension periodically and
+// at shutdown, and restored at startup, so models that need history do not
+// start cold
+type persistedState struct {
+	Rules []persistedRule `json:"rules"`
+}
+
+// persistedRule is the state of one rule. It is restored only into the rule at
+// the same position with the same model and inputs.
+type persistedRule struct {
+	Model      string                        `json:"model"`
+	Inputs     []string                      `json:"inputs"`
+	LastValues []persistedLastValue          `json:"last_values,omitempty"`
+	Transforms map[string]persistedTransform `json:"transforms,omitempty"`
+	Local      []persistedLocalSeries        `json:"local,omitempty"`
+	Cumulative []persistedCumulativeSeries   `json:"cumulative,omitempty"`
+}
+
+// persistedCumulativeSeries is the running total of one series of an output
+// with cumulative temporality, so the sum continues across restarts instead of
+// resetting to zero
+type persistedCumulativeSeries struct {
+	Output    int     `json:"output"`
+	Key       string  `json:"key"`
+	Value     float64 `json:"value"`
+	IntValue  *int64  `json:"int_value,omitempty"`
+	Start     uint64  `json:"start"`
+	Timestamp uint64  `json:"timestamp"`
+}
+
+// persistedLastValue is the last successful data point of one series of an output
+type persistedLastValue struct {
+	Output     int            `json:"output"`
+	Resource   map[string]any `json:"resource,omitempty"`
+	Metric     string         `json:"metric"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Value      float64        `json:"value"`
+	IntValue   *int64         `json:"int_value,omitempty"`
+	Start      uint64         `json:"start,omitempty"`
+	Timestamp  uint64         `json:"timestamp"`
+	Recorded   time.Time      `json:"recorded"`
+}
+
+// persistedTransform is the state of an input transform: the previous data
+// point of every series and the rolling window of automatic scaling
+type persistedTransform struct {
+	Series  []persistedTransformSeries `json:"series,omitempty"`
+	Window  []float64                  `json:"window,omitempty"`
+	Written int                        `json:"written,omitempty"`
+}
+
+// persistedTransformSeries is the previous data point of one transformed series
+type persistedTransformSeries struct {
+	Key       uint64  `json:"key"`
+	Value     float64 `json:"value"`
+	Start     uint64  `json:"start,omitempty"`
+	Timestamp uint64  `json:"timestamp"`
+}
+
+// persistedLocalSeries is the rolling state of one series of a local backend
+type persistedLocalSeries struct {
+	Key      uint64    `json:"key"`
+	EWMA     float64   `json:"ewma"`
+	History  []float64 `json:"history,omitempty"`
+	Level    float64   `json:"level,omitempty"`
+	Trend    float64   `json:"trend,omitempty"`
+	Seasonal []float64 `json:"seasonal,omitempty"`
+	Steps    int       `json:"steps,omitempty"`
+}
+
+// startStorage connects to the configured storage extension, restores the state
+// saved by a previous run and starts saving the state periodically. A missing or
+// unreadable state is not an error, the processor then starts without history.
+func (mp *metricsinferenceprocessor) startStorage(ctx context.Context, host component.Host) error {
+	if mp.config.Storage == nil {
+		return nil
+	}
+	if host == nil {
+		return fmt.Errorf("storage extension %s not found", mp.config.Storage)
+	}
+	ext, found := host.GetExtensions()[*mp.config.Storage]
+	if !found {
+		return fmt.Errorf("storage extension %s not found", mp.config.Storage)
+	}
+	storageExt, ok := ext.(storage.Extension)
+	if !ok {
+		return fmt.Errorf("extension %s is not a storage extension", mp.config.Storage)
+	}
+	client, err := storageExt.GetClient(ctx, component.KindProcessor, mp.id, "")
+	if err != nil {
+		return fmt.Errorf("failed to get storage client: %w", err)
+	}
+	mp.storageClient = client
+
+	mp.loadState(ctx, client)
+	mp.startStateSaving(client)
+	return nil
+}
+
+// loadState restores the state saved by a previous run, if any can be read
+func (mp *metricsinferenceprocessor) loadState(ctx context.Context, client storage.Client) {
+	data, err := client.Get(ctx, stateStorageKey)
+	if err != nil {
+		mp.logger.Warn("Failed to read saved state, starting without history", zap.Error(err))
+		return
+	}
+	if data == nil {
+		return
+	}
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		mp.logger.Warn("Failed to decode saved state, starting without history", zap.Error(err))
+		return
+	}
+	restored := mp.restoreState(state)
+	mp.logger.Info("Restored saved state", zap.Int("rules", restored))
+}
+
+// startStateSaving saves the state every storage interval in the background,
+// so a crash loses at most one interval of history
+func (mp *metricsinferenceprocessor) startStateSaving(client storage.Client) {
+	interval := mp.config.StorageInterval
+	if interval == 0 {
+		interval = defaultStorageInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	mp.storageCancel = cancel
+	mp.storageDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mp.saveState(ctx, client)
+			}
+		}
+	}()
+}
+
+// saveState writes the processor state to the storage client
+func (mp *metricsinferenceprocessor) saveState(ctx context.Context, client storage.Client) {
+	data, err := json.Marshal(mp.snapshotState())
+	if err == nil {
+		err = client.Set(ctx, stateStorageKey, data)
+	}
+	if err != nil {
+		mp.logger.Error("Failed to save state", zap.Error(err))
+	}
+}
+
+// detachStorage stops saving the state periodically, waiting for a save in
+// progress, and returns the storage client, nil when storage is not configured
+// or already stopped
+func (mp *metricsinferenceprocessor) detachStorage() storage.Client {
+	if mp.storageCancel != nil {
+		mp.storageCancel()
+		<-mp.storageDone
+		mp.storageCancel = nil
+		mp.storageDone = nil
+	}
+	client := mp.storageClient
+	mp.storageClient = nil
+	return client
+}
+
+// stopStorage saves the processor state and closes the storage client
+func (mp *metricsinferenceprocessor) stopStorage(ctx context.Context) error {
+	client := mp.detachStorage()
+	if client == nil {
+		return nil
+	}
+	mp.saveState(ctx, client)
+	if err := client.Close(ctx); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
+
+// closeStorage closes the storage client without saving the state, for a
+// Start that failed after connecting to the storage extension
+func (mp *metricsinferenceprocessor) closeStorage(ctx context.Context) {
+	client := mp.detachStorage()
+	if client == nil {
+		return
+	}
+	if err := client.Close(ctx); err != nil {
+		mp.logger.Warn("Failed to close storage client", zap.Error(err))
+	}
+}
+
+// snapshotState captures the state of every rule
+func (mp *metricsinferenceprocessor) snapshotState() persistedState {
+	mp.metadataLock.RLock()
+	defer mp.metadataLock.RUnlock()
+
+	state := persistedState{Rules: make([]persistedRule, len(mp.rules))}
+	for ruleIdx := range mp.rules {
+		state.Rules[ruleIdx] = mp.snapshotRule(ruleIdx)
+	}
+	return state
+}
+
+// snapshotRule captures the state of a rule. The caller must hold mp.metadataLock.
+func (mp *metricsinferenceprocessor) snapshotRule(ruleIdx int) persistedRule {
+	rule := mp.rules[ruleIdx]
+	saved := persistedRule{
+		Model:      rule.modelName,
+		Inputs:     rule.inputs,
+		LastValues: mp.lastValues.snapshot(ruleIdx),
+		Cumulative: mp.cumulative.snapshot(ruleIdx),
+	}
+	for input, transform := range rule.transforms {
+		if saved.Transforms == nil {
+			saved.Transforms = make(map[string]persistedTransform)
+		}
+		saved.Transforms[input] = transform.snapshot()
+	}
+	if backend, ok := rule.backend.(*localBackend); ok {
+		saved.Local = backend.snapshot()
+	}
+	return saved
+}
+
+// restoreState applies saved state to the rules it still matches and returns
+// the number of rules restored
+func (mp *metricsinferenceprocessor) restoreState(state persistedState) int {
+	mp.metadataLock.RLock()
+	defer mp.metadataLock.RUnlock()
+
+	restored := 0
+	for ruleIdx, saved := range state.Rules {
+		if ruleIdx >= len(mp.rules) {
+			break
+		}
+		rule := mp.rules[ruleIdx]
+		if saved.Model != rule.modelName || !slices.Equal(saved.Inputs, rule.inputs) {
+			mp.logger.Info("Rule changed since state was saved, starting it without history",
+				zap.Int("rule_index", ruleIdx), zap.String("model", rule.modelName))
+			continue
+		}
+		mp.lastValues.restore(ruleIdx, saved.LastValues)
+		mp.cumulative.restore(ruleIdx, saved.Cumulative)
+		for input, transform := range rule.transforms {
+			if savedTransform, ok := saved.Transforms[input]; ok {
+				transform.restore(savedTransform)
+			}
+		}
+		if backend, ok := rule.backend.(*localBackend); ok {
+			backend.restore(saved.Local)
+		}
+		restored++
+	}
+	return restored
+}
+
+// snapshot returns the last values recorded for the outputs of a rule
+func (s *lastValueStore) snapshot(ruleIdx int) []persistedLastValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saved []persistedLastValue
+	for output, byResource := range s.entries {
+		if output.ruleIdx != ruleIdx {
+			continue
+		}
+		for _, entries := range byResource {
+			for name, bySeries := range entries.metrics {
+				for _, entry := range bySeries {
+					dp := entry.dataPoint
+					value := persistedLastValue{
+						Output:     output.outputIdx,
+						Resource:   entries.attributes.AsRaw(),
+						Metric:     name,
+						Attributes: dp.Attributes().AsRaw(),
+						Value:      dp.DoubleValue(),
+						Start:      uint64(dp.StartTimestamp()),
+						Timestamp:  uint64(dp.Timestamp()),
+						Recorded:   entry.recorded,
+					}
+					if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+						intValue := dp.IntValue()
+						value.IntValue = &intValue
+					}
+					saved = append(saved, value)
+				}
+			}
+		}
+	}
+	return saved
+}
+
+// restore records saved last values for the outputs of a rule
+func (s *lastValueStore) restore(ruleIdx int, saved []persistedLastValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, value := range saved {
+		resource := pcommon.NewMap()
+		if err := resource.FromRaw(value.Resource); err != nil {
+			continue
+		}
+		byMetric := s.resourceEntries(lastValueOutput{ruleIdx: ruleIdx, outputIdx: value.Output}, resource).metrics
+		if byMetric[value.Metric] == nil {
+			byMetric[value.Metric] = make(map[string]*lastValueEntry)
+		}
+
+		dp := pmetric.NewNumberDataPoint()
+		if err := dp.Attributes().FromRaw(value.Attributes); err != nil {
+			continue
+		}
+		if value.IntValue != nil {
+			dp.SetIntValue(*value.IntValue)
+		} else {
+			dp.SetDoubleValue(value.Value)
+		}
+		dp.SetStartTimestamp(pcommon.Timestamp(value.Start))
+		dp.SetTimestamp(pcommon.Timestamp(value.Timestamp))
+		byMetric[value.Metric][attributeSetKey(dp.Attributes())] = &lastValueEntry{dataPoint: dp, recorded: value.Recorded}
+	}
+}
+
+// reset replaces the last values of the outputs of a rule with saved ones
+func (s *lastValueStore) reset(ruleIdx int, saved []persistedLastValue) {
+	s.mu.Lock()
+	for output := range s.entries {
+		if output.ruleIdx == ruleIdx {
+			delete(s.entries, output)
+		}
+	}
+	s.mu.Unlock()
+	s.restore(ruleIdx, saved)
+}
+
+// snapshot returns the series and scaling window of an input transform
+func (t *inputTransform) snapshot() persistedTransform {
+	t.mu.Lock()
+	saved := persistedTransform{}
+	for key, series := range t.series {
+		saved.Series = append(saved.Series, persistedTransformSeries{
+			Key:       key,
+			Value:     series.value,
+			Start:     uint64(series.start),
+			Timestamp: uint64(series.timestamp),
+		})
+	}
+	t.mu.Unlock()
+
+	if t.scaler != nil {
+		t.scaler.mu.Lock()
+		saved.Window = slices.Clone(t.scaler.window)
+		saved.Written = t.scaler.written
+		t.scaler.mu.Unlock()
+	}
+	return saved
+}
+
+// restore applies the saved series and scaling window of an input transform.
+// Restored series count as seen in the current batch.
+func (t *inputTransform) restore(saved persistedTransform) {
+	t.mu.Lock()
+	for _, series := range saved.Series {
+		t.series[series.Key] = &transformSeries{
+			value:     series.Value,
+			start:     pcommon.Timestamp(series.Start),
+			timestamp: pcommon.Timestamp(series.Timestamp),
+			lastSeen:  t.generation,
+		}
+	}
+	t.mu.Unlock()
+
+	if t.scaler != nil && t.scaler.auto {
+		t.scaler.mu.Lock()
+		t.scaler.window = slices.Clone(saved.Window)
+		t.scaler.written = saved.Written
+		if len(t.scaler.window) > t.scaler.size {
+			// The window shrank; statistics do not depend on order, so any values will do
+			t.scaler.window = t.scaler.window[:t.scaler.size]
+			t.scaler.written = t.scaler.size
+		}
+		t.scaler.mu.Unlock()
+	}
+}
+
+// reset replaces the series and scaling window of an input transform with saved ones
+func (t *inputTransform) reset(saved persistedTransform) {
+	t.mu.Lock()
+	clear(t.series)
+	t.mu.Unlock()
+	if t.scaler != nil {
+		t.scaler.mu.Lock()
+		t.scaler.window = nil
+		t.scaler.written = 0
+		t.scaler.mu.Unlock()
+	}
+	t.restore(saved)
+}
+
+// snapshot returns the rolling state of every series of a local backend
+func (b *localBackend) snapshot() []persistedLocalSeries {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved := make([]persistedLocalSeries, 0, len(b.series))
+	for key, series := range b.series {
+		saved = append(saved, persistedLocalSeries{
+			Key:      key,
+			EWMA:     series.ewma,
+			History:  series.history,
+			Level:    series.level,
+			Trend:    series.trend,
+			Seasonal: series.seasonal,
+			Steps:    series.steps,
+		})
+	}
+	return saved
+}
+
+// restore applies the saved rolling state of the series of a local backend.
+// Histories longer than the window keep their most recent values.
+func (b *localBackend) restore(saved []persistedLocalSeries) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, series := range saved {
+		history := series.History
+		if len(history) > b.window {
+			history = history[len(history)-b.window:]
+		}
+		restored := &localSeries{
+			ewma:     series.EWMA,
+			history:  slices.Clone(history),
+			lastSeen: b.generation,
+			level:    series.Level,
+			trend:    series.Trend,
+			steps:    series.Steps,
+		}
+		// A season of another length than configured is learned again
+		if len(series.Seasonal) == b.season {
+			restored.seasonal = slices.Clone(series.Seasonal)
+		}
+		b.series[series.Key] = restored
+	}
+}
+
+// reset replaces the rolling state of the series of a local backend with saved state
+func (b *localBackend) reset(saved []persistedLocalSeries) {
+	b.mu.Lock()
+	clear(b.series)
+	b.mu.Unlock()
+	b.restore(saved)
+}
+
+// snapshot returns the running totals of the outputs of a rule
+func (s *cumulativeStore) snapshot(ruleIdx int) []persistedCumulativeSeries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saved []persistedCumulativeSeries
+	for output, bySeries := range s.series {
+		if output.ruleIdx != ruleIdx {
+			continue
+		}
+		for key, series := range bySeries {
+			value := persistedCumulativeSeries{
+				Output:    output.outputIdx,
+				Key:       key,
+				Value:     series.sum,
+				Start:     uint64(series.start),
+				Timestamp: uint64(series.last),
+			}
+			if series.isInt {
+				intValue := series.intSum
+				value.IntValue = &intValue
+			}
+			saved = append(saved, value)
+		}
+	}
+	return saved
+}
+
+// restore resumes saved running totals for the outputs of a rule
+func (s *cumulativeStore) restore(ruleIdx int, saved []persistedCumulativeSeries) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, value := range saved {
+		key := lastValueOutput{ruleIdx: ruleIdx, outputIdx: value.Output}
+		if s.series[key] == nil {
+			s.series[key] = make(map[string]*cumulativeSeries)
+		}
+		series := &cumulativeSeries{
+			start:    pcommon.Timestamp(value.Start),
+			last:     pcommon.Timestamp(value.Timestamp),
+			sum:      value.Value,
+			recorded: now,
+		}
+		if value.IntValue != nil {
+			series.intSum = *value.IntValue
+			series.isInt = true
+		}
+		s.series[key][value.Key] = series
+	}
+}
+
+// reset replaces the running totals of the outputs of a rule with saved ones
+func (s *cumulativeStore) reset(ruleIdx int, saved []persistedCumulativeSeries) {
+	s.mu.Lock()
+	for output := range s.series {
+		if output.ruleIdx == ruleIdx {
+			delete(s.series, output)
+		}
+	}
+	s.mu.Unlock()
+	s.restore(ruleIdx, saved)
+}
@@ -0,0 +1,231 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// memoryStorage is a storage extension keeping data in memory, shared by all
+// clients so state survives a processor restart
+type memoryStorage struct {
+	component.StartFunc
+	component.ShutdownFunc
+
+	mu     sync.Mutex
+	data   map[string][]byte
+	closed int // Clients closed so far
+}
+
+func (s *memoryStorage) GetClient(context.Context, component.Kind, component.ID, string) (storage.Client, error) {
+	return &memoryClient{storage: s}, nil
+}
+
+type memoryClient struct {
+	storage *memoryStorage
+}
+
+func (c *memoryClient) Get(_ context.Context, key string) ([]byte, error) {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	return c.storage.data[key], nil
+}
+
+func (c *memoryClient) Set(_ context.Context, key string, value []byte) error {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	c.storage.data[key] = value
+	return nil
+}
+
+func (c *memoryClient) Delete(_ context.Context, key string) error {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	delete(c.storage.data, key)
+	return nil
+}
+
+func (c *memoryClient) Batch(ctx context.Context, ops ...*storage.Operation) error {
+	for _, op := range ops {
+		var err error
+		switch op.Type {
+		case storage.Get:
+			op.Value, err = c.Get(ctx, op.Key)
+		case storage.Set:
+			err = c.Set(ctx, op.Key, op.Value)
+		case storage.Delete:
+			err = c.Delete(ctx, op.Key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) Close(context.Context) error {
+	c.storage.mu.Lock()
+	defer c.storage.mu.Unlock()
+	c.storage.closed++
+	return nil
+}
+
+// extensionsHost is a host exposing a fixed set of extensions
+type extensionsHost map[component.ID]component.Component
+
+func (h extensionsHost) GetExtensions() map[component.ID]component.Component {
+	return h
+}
+
+func TestPersistentState(t *testing.T) {
+	storageID := component.MustNewID("memory")
+	host := extensionsHost{storageID: &memoryStorage{data: make(map[string][]byte)}}
+
+	newConfig := func(model string) *Config {
+		return &Config{
+			Timeout: 5,
+			Storage: &storageID,
+			Rules: []Rule{
+				{
+					ModelName:     model,
+					Inputs:        []string{"metric_1"},
+					OutputPattern: "smoothed.{input}",
+					Backend:       "local",
+					Local:         &LocalConfig{Function: "ewma", Alpha: 0.5},
+				},
+				{
+					ModelName:     "delta_smoother",
+					Inputs:        []string{"metric_2"},
+					OutputPattern: "smoothed.{input}",
+					Backend:       "local",
+					Local:         &LocalConfig{Function: "ewma", Alpha: 0.5},
+					Transforms:    map[string]InputTransformConfig{"metric_2": {As: "delta"}},
+				},
+			},
+		}
+	}
+
+	// run starts a processor, feeds it one batch, shuts it down and returns its output
+	run := func(model string, value1, value2 float64) *consumertest.MetricsSink {
+		sink := &consumertest.MetricsSink{}
+		processor, err := newMetricsProcessor(newConfig(model), sink, zaptest.NewLogger(t))
+		require.NoError(t, err)
+		require.NoError(t, processor.Start(context.Background(), host))
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1", "metric_2"},
+			MetricValues: [][]float64{{value1}, {value2}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+		require.NoError(t, processor.Shutdown(context.Background()))
+		require.Len(t, sink.AllMetrics(), 1)
+		return sink
+	}
+
+	// The first delta of a series needs a previous data point, so there is none yet
+	sink := run("smoother", 10, 100)
+	assert.Equal(t, 10.0, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_1").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, pmetric.MetricTypeEmpty, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_2").Type())
+
+	// After a restart the average and the delta baseline carry on
+	sink = run("smoother", 30, 150)
+	assert.Equal(t, 20.0, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_1").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, 50.0, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_2").Gauge().DataPoints().At(0).DoubleValue())
+
+	// A rule whose model changed starts without history
+	sink = run("other_smoother", 40, 170)
+	assert.Equal(t, 40.0, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_1").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, 35.0, findMetricByName(sink.AllMetrics()[0], "smoothed.metric_2").Gauge().DataPoints().At(0).DoubleValue())
+}
+
+func TestPersistentStateSavedPeriodically(t *testing.T) {
+	storageID := component.MustNewID("memory")
+	memory := &memoryStorage{data: make(map[string][]byte)}
+	cfg := &Config{
+		Timeout:         5,
+		Storage:         &storageID,
+		StorageInterval: 10 * time.Millisecond,
+		Rules: []Rule{{
+			ModelName:     "smoother",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "smoothed.{input}",
+			Backend:       "local",
+			Local:         &LocalConfig{Function: "ewma", Alpha: 0.5},
+		}},
+	}
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), extensionsHost{storageID: memory}))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{10}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// The history is saved while the processor keeps running
+	assert.Eventually(t, func() bool {
+		memory.mu.Lock()
+		data := memory.data[stateStorageKey]
+		memory.mu.Unlock()
+		var state persistedState
+		return json.Unmarshal(data, &state) == nil && len(state.Rules) == 1 && len(state.Rules[0].Local) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPersistentStateClosedOnFailedStart(t *testing.T) {
+	storageID := component.MustNewID("memory")
+	memory := &memoryStorage{data: make(map[string][]byte)}
+	cfg := &Config{
+		Storage:     &storageID,
+		RemoteRules: &RemoteRulesConfig{Extension: component.MustNewID("opamp")},
+		Rules: []Rule{{
+			ModelName: "smoother",
+			Inputs:    []string{"metric_1"},
+			Backend:   "local",
+			Local:     &LocalConfig{Function: "ewma", Alpha: 0.5},
+		}},
+	}
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.ErrorContains(t, processor.Start(context.Background(), extensionsHost{storageID: memory}), "opamp extension opamp not found")
+
+	// The client is closed without saving over the stored state
+	assert.Equal(t, 1, memory.closed)
+	assert.NotContains(t, memory.data, stateStorageKey)
+	assert.NoError(t, processor.Shutdown(context.Background()))
+	assert.Equal(t, 1, memory.closed)
+}
+
+func TestPersistentStateMissingExtension(t *testing.T) {
+	storageID := component.MustNewID("memory")
+	cfg := &Config{
+		Storage: &storageID,
+		Rules: []Rule{{
+			ModelName: "smoother",
+			Inputs:    []string{"metric_1"},
+			Backend:   "local",
+			Local:     &LocalConfig{Function: "ewma", Alpha: 0.5},
+		}},
+	}
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.ErrorContains(t, processor.Start(context.Background(), extensionsHost{}), "storage extension memory not found")
+}
@@ -0,0 +1,191 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// postTransform is a single step of an output post-processing chain
+type postTransform struct {
+	name  string
+	apply func(float64) float64
+	unit  string // Target unit for convert transforms, empty otherwise
+}
+
+// unitConversionFactors maps a unit to its dimension and a factor relative to the
+// dimension's base unit. Units follow the UCUM-style notation used by OpenTelemetry.
+var unitConversionFactors = map[string]struct {
+	dimension string
+	factor    float64
+}{
+	// Ratios
+	"1": {"ratio", 1},
+	"%": {"ratio", 0.01},
+
+	// Time
+	"ns":  {"time", 1e-9},
+	"us":  {"time", 1e-6},
+	"ms":  {"time", 1e-3},
+	"s":   {"time", 1},
+	"min": {"time", 60},
+	"h":   {"time", 3600},
+
+	// Bytes
+	"By":   {"bytes", 1},
+	"kBy":  {"bytes", 1e3},
+	"MBy":  {"bytes", 1e6},
+	"GBy":  {"bytes", 1e9},
+	"KiBy": {"bytes", 1 << 10},
+	"MiBy": {"bytes", 1 << 20},
+	"GiBy": {"bytes", 1 << 30},
+}
+
+// unitConversionAliases maps spellings accepted by convert to their UCUM unit
+var unitConversionAliases = map[string]string{
+	"KBy": "kBy",
+}
+
+// parsePostTransforms parses a list of transform expressions such as
+// "clamp(0,1)", "scale(100)", "offset(-5)", "round(2)" or "convert(s,ms)".
+// Transforms are applied in the order given.
+func parsePostTransforms(specs []string) ([]postTransform, error) {
+	transforms := make([]postTransform, 0, len(specs))
+	for _, spec := range specs {
+		transform, err := parsePostTransform(spec)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, transform)
+	}
+	return transforms, nil
+}
+
+// parsePostTransform parses a single transform expression
+func parsePostTransform(spec string) (postTransform, error) {
+	spec = strings.TrimSpace(spec)
+	openParen := strings.Index(spec, "(")
+	if openParen <= 0 || !strings.HasSuffix(spec, ")") {
+		return postTransform{}, fmt.Errorf("invalid transform %q: expected name(args)", spec)
+	}
+
+	name := strings.TrimSpace(spec[:openParen])
+	var args []string
+	if argPart := strings.TrimSpace(spec[openParen+1 : len(spec)-1]); argPart != "" {
+		for _, arg := range strings.Split(argPart, ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	}
+
+	switch name {
+	case "clamp":
+		values, err := parseTransformNumbers(spec, args, 2)
+		if err != nil {
+			return postTransform{}, err
+		}
+		minVal, maxVal := values[0], values[1]
+		if minVal > maxVal {
+			return postTransform{}, fmt.Errorf("invalid transform %q: min must not exceed max", spec)
+		}
+		return postTransform{name: name, apply: func(v float64) float64 {
+			return math.Max(minVal, math.Min(maxVal, v))
+		}}, nil
+
+	case "scale":
+		values, err := parseTransformNumbers(spec, args, 1)
+		if err != nil {
+			return postTransform{}, err
+		}
+		factor := values[0]
+		return postTransform{name: name, apply: func(v float64) float64 {
+			return v * factor
+		}}, nil
+
+	case "offset":
+		values, err := parseTransformNumbers(spec, args, 1)
+		if err != nil {
+			return postTransform{}, err
+		}
+		delta := values[0]
+		return postTransform{name: name, apply: func(v float64) float64 {
+			return v + delta
+		}}, nil
+
+	case "round":
+		values, err := parseTransformNumbers(spec, args, 1)
+		if err != nil {
+			return postTransform{}, err
+		}
+		if values[0] < 0 || values[0] != math.Trunc(values[0]) {
+			return postTransform{}, fmt.Errorf("invalid transform %q: digits must be a non-negative integer", spec)
+		}
+		pow := math.Pow(10, values[0])
+		return postTransform{name: name, apply: func(v float64) float64 {
+			return math.Round(v*pow) / pow
+		}}, nil
+
+	case "convert":
+		if len(args) != 2 {
+			return postTransform{}, fmt.Errorf("invalid transform %q: expected 2 arguments, got %d", spec, len(args))
+		}
+		for i, arg := range args {
+			if unit, ok := unitConversionAliases[arg]; ok {
+				args[i] = unit
+			}
+		}
+		from, fromOK := unitConversionFactors[args[0]]
+		to, toOK := unitConversionFactors[args[1]]
+		if !fromOK || !toOK {
+			return postTransform{}, fmt.Errorf("invalid transform %q: unsupported unit", spec)
+		}
+		if from.dimension != to.dimension {
+			return postTransform{}, fmt.Errorf("invalid transform %q: cannot convert %s to %s", spec, from.dimension, to.dimension)
+		}
+		factor := from.factor / to.factor
+		return postTransform{name: name, unit: args[1], apply: func(v float64) float64 {
+			return v * factor
+		}}, nil
+
+	default:
+		return postTransform{}, fmt.Errorf("unknown transform %q", name)
+	}
+}
+
+// parseTransformNumbers parses exactly n numeric arguments of a transform
+func parseTransformNumbers(spec string, args []string, n int) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("invalid transform %q: expected %d arguments, got %d", spec, n, len(args))
+	}
+	values := make([]float64, n)
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform %q: argument %q is not a number", spec, arg)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// applyPostTransforms runs a value through the post-processing chain
+func applyPostTransforms(value float64, chain []postTransform) float64 {
+	for _, transform := range chain {
+		value = transform.apply(value)
+	}
+	return value
+}
+
+// postTransformsUnit returns the unit produced by the last convert transform in the chain
+func postTransformsUnit(chain []postTransform) string {
+	unit := ""
+	for _, transform := range chain {
+		if transform.unit != "" {
+			unit = transform.unit
+		}
+	}
+	return unit
+}
@@ -0,0 +1,202 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestParsePostTransforms(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    []string
+		input    float64
+		expected float64
+		unit     string
+		errorMsg string
+	}{
+		{
+			name:     "empty chain",
+			specs:    nil,
+			input:    0.42,
+			expected: 0.42,
+		},
+		{
+			name:     "clamp scale round",
+			specs:    []string{"clamp(0,1)", "scale(100)", "round(2)"},
+			input:    0.123456,
+			expected: 12.35,
+		},
+		{
+			name:     "clamp upper bound",
+			specs:    []string{"clamp(0, 1)"},
+			input:    1.7,
+			expected: 1,
+		},
+		{
+			name:     "offset",
+			specs:    []string{"offset(-5)"},
+			input:    10,
+			expected: 5,
+		},
+		{
+			name:     "convert ratio to percent",
+			specs:    []string{"convert(1,%)"},
+			input:    0.25,
+			expected: 25,
+			unit:     "%",
+		},
+		{
+			name:     "convert seconds to milliseconds",
+			specs:    []string{"convert(s,ms)", "round(0)"},
+			input:    1.5,
+			expected: 1500,
+			unit:     "ms",
+		},
+		{
+			name:     "convert mebibytes to bytes",
+			specs:    []string{"convert(MiBy,By)"},
+			input:    2,
+			expected: 2 * 1024 * 1024,
+			unit:     "By",
+		},
+		{
+			name:     "convert bytes to kilobytes",
+			specs:    []string{"convert(By,kBy)"},
+			input:    2500,
+			expected: 2.5,
+			unit:     "kBy",
+		},
+		{
+			name:     "convert with a unit alias",
+			specs:    []string{"convert(By,KBy)"},
+			input:    2500,
+			expected: 2.5,
+			unit:     "kBy",
+		},
+		{
+			name:     "unknown transform",
+			specs:    []string{"sqrt()"},
+			errorMsg: "unknown transform \"sqrt\"",
+		},
+		{
+			name:     "missing parentheses",
+			specs:    []string{"scale"},
+			errorMsg: "expected name(args)",
+		},
+		{
+			name:     "wrong argument count",
+			specs:    []string{"clamp(0)"},
+			errorMsg: "expected 2 arguments, got 1",
+		},
+		{
+			name:     "non-numeric argument",
+			specs:    []string{"scale(abc)"},
+			errorMsg: "argument \"abc\" is not a number",
+		},
+		{
+			name:     "inverted clamp",
+			specs:    []string{"clamp(1,0)"},
+			errorMsg: "min must not exceed max",
+		},
+		{
+			name:     "negative round digits",
+			specs:    []string{"round(-1)"},
+			errorMsg: "digits must be a non-negative integer",
+		},
+		{
+			name:     "incompatible units",
+			specs:    []string{"convert(s,By)"},
+			errorMsg: "cannot convert time to bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := parsePostTransforms(tt.specs)
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, applyPostTransforms(tt.input, chain), 1e-9)
+			assert.Equal(t, tt.unit, postTransformsUnit(chain))
+		})
+	}
+}
+
+func TestConfigValidatePostTransforms(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.GRPCClientSettings.Endpoint = "localhost:8081"
+	cfg.Rules = []Rule{
+		{
+			ModelName: "model",
+			Inputs:    []string{"metric_1"},
+			Outputs: []OutputSpec{
+				{Name: "ok", Post: []string{"scale(2)"}},
+				{Name: "bad", Post: []string{"scale(2", "round(1)"}},
+			},
+		},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid post transform for output 1 in rule 0")
+}
+
+func TestPostTransformsAppliedToOutputs(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("utilization_model",
+		testutil.CreateMockResponseForCalculation("utilization_model", 1.23456))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "utilization_model",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "{output}",
+				Outputs: []OutputSpec{
+					{
+						Name: "utilization_percent",
+						Post: []string{"clamp(0,1)", "convert(1,%)", "round(1)"},
+					},
+				},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{0.5}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	require.Len(t, sink.AllMetrics(), 1)
+
+	output := findMetricByName(sink.AllMetrics()[0], "utilization_percent")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	assert.Equal(t, 100.0, output.Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, "%", output.Unit())
+}
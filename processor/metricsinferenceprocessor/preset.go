@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rule presets
+const (
+	presetAlibiDrift = "alibi-drift" // drift detectors served by MLServer's Alibi-Detect runtime
+)
+
+// alibiDriftFeatureAttribute labels the per-feature values of univariate drift
+// detectors, which return a distance and p-value per feature
+const alibiDriftFeatureAttribute = "drift.feature"
+
+// alibiDriftOutputs maps the output tensors of an Alibi-Detect drift detector to
+// metrics: whether drift was detected, the test statistic, and its p-value
+func alibiDriftOutputs() []OutputSpec {
+	return []OutputSpec{
+		{
+			Name:        "drift.detected",
+			TensorName:  "is_drift",
+			Description: "Whether {model} detected drift (1) or not (0)",
+			Unit:        "1",
+		},
+		{
+			Name:           "drift.distance",
+			TensorName:     "distance",
+			Description:    "Drift test statistic reported by {model}",
+			IndexAttribute: alibiDriftFeatureAttribute,
+		},
+		{
+			Name:           "drift.p_value",
+			TensorName:     "p_val",
+			Description:    "p-value of the drift test of {model}",
+			Unit:           "1",
+			IndexAttribute: alibiDriftFeatureAttribute,
+		},
+	}
+}
+
+// validatePreset checks the preset of a rule
+func validatePreset(rule Rule) error {
+	switch rule.Preset {
+	case "":
+		return nil
+	case presetAlibiDrift:
+	default:
+		return fmt.Errorf("unknown preset %q (must be '%s')", rule.Preset, presetAlibiDrift)
+	}
+	if rule.Aggregate != nil || rule.inProcess() {
+		return errors.New("presets describe inference server runtimes and cannot be combined with aggregate or in-process backends")
+	}
+	return nil
+}
+
+// applyPreset fills the fields of a rule its preset provides. Fields the rule
+// configures are kept, so configured outputs replace the preset's outputs.
+func applyPreset(rule Rule) Rule {
+	if rule.Preset != presetAlibiDrift {
+		return rule
+	}
+	if len(rule.Outputs) == 0 {
+		rule.Outputs = alibiDriftOutputs()
+	}
+	if rule.OutputPattern == "" {
+		// The preset's output names are complete metric names
+		rule.OutputPattern = "{output}"
+	}
+	return rule
+}
+
+// withPresets returns the configuration with the presets of its rules applied.
+// The configuration itself is returned when no rule uses a preset.
+func (cfg *Config) withPresets() *Config {
+	applied := *cfg
+	applied.Rules = nil
+	for i, rule := range cfg.Rules {
+		if rule.Preset == "" {
+			continue
+		}
+		if applied.Rules == nil {
+			applied.Rules = append([]Rule(nil), cfg.Rules...)
+		}
+		applied.Rules[i] = applyPreset(rule)
+	}
+	if applied.Rules == nil {
+		return cfg
+	}
+	return &applied
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestApplyPreset(t *testing.T) {
+	rule := applyPreset(Rule{ModelName: "drift_detector", Preset: presetAlibiDrift})
+	assert.Equal(t, "{output}", rule.OutputPattern)
+	require.Len(t, rule.Outputs, 3)
+	assert.Equal(t, []string{"is_drift", "distance", "p_val"},
+		[]string{rule.Outputs[0].TensorName, rule.Outputs[1].TensorName, rule.Outputs[2].TensorName})
+
+	// Configured fields replace the preset's
+	rule = applyPreset(Rule{
+		Preset:        presetAlibiDrift,
+		OutputPattern: "{model}.{output}",
+		Outputs:       []OutputSpec{{Name: "drift", TensorName: "is_drift"}},
+	})
+	assert.Equal(t, "{model}.{output}", rule.OutputPattern)
+	assert.Equal(t, []OutputSpec{{Name: "drift", TensorName: "is_drift"}}, rule.Outputs)
+
+	// The configuration is left untouched
+	cfg := &Config{Rules: []Rule{{ModelName: "scorer"}, {ModelName: "drift_detector", Preset: presetAlibiDrift}}}
+	applied := cfg.withPresets()
+	assert.Len(t, applied.Rules[1].Outputs, 3)
+	assert.Empty(t, cfg.Rules[1].Outputs)
+	plain := &Config{Rules: []Rule{{ModelName: "scorer"}}}
+	assert.Same(t, plain, plain.withPresets())
+}
+
+func TestValidatePreset(t *testing.T) {
+	assert.NoError(t, validatePreset(Rule{}))
+	assert.NoError(t, validatePreset(Rule{Preset: presetAlibiDrift}))
+	assert.ErrorContains(t, validatePreset(Rule{Preset: "alibi-outlier"}), "unknown preset")
+	assert.ErrorContains(t, validatePreset(Rule{Preset: presetAlibiDrift, Backend: backendLocal}), "cannot be combined")
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "drift_detector", Inputs: []string{"latency"}, Preset: "drift"}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid preset in rule 0")
+}
+
+func TestAlibiDriftPreset(t *testing.T) {
+	response := &pb.ModelInferResponse{
+		ModelName: "drift_detector",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{Name: "threshold", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.05}}},
+			{Name: "p_val", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.012}}},
+			{Name: "distance", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.41}}},
+			{Name: "is_drift", Datatype: "INT64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Int64Contents: []int64{1}}},
+		},
+	}
+	mockServer := testutil.StartMockServer(t, testutil.WithModelResponse("drift_detector", response))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName: "drift_detector",
+			Inputs:    []string{"latency"},
+			Preset:    presetAlibiDrift,
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"latency"},
+		MetricValues: [][]float64{{120}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Outputs are found by tensor name whatever their order
+	output := sink.AllMetrics()[0]
+	for name, expected := range map[string]float64{
+		"drift.detected": 1,
+		"drift.distance": 0.41,
+		"drift.p_value":  0.012,
+	} {
+		metric := findMetricByName(output, name)
+		require.Equal(t, pmetric.MetricTypeGauge, metric.Type(), name)
+		dp := metric.Gauge().DataPoints().At(0)
+		value := dp.DoubleValue()
+		if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+			value = float64(dp.IntValue())
+		}
+		assert.Equal(t, expected, value, name)
+	}
+	assert.Equal(t, "Whether drift_detector detected drift (1) or not (0)", findMetricByName(output, "drift.detected").Description())
+	assert.Equal(t, "1", findMetricByName(output, "drift.p_value").Unit())
+}
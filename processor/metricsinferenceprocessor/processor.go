@@ -5,24 +5,31 @@ package metricsinferenceprocessor // import "github.com/rbellamy/opentelemetry-i
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+	"go.opentelemetry.io/collector/featuregate"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/keepalive"
-	"google.golang.org/grpc/metadata"
 
 	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
 )
@@ -31,6 +38,17 @@ const (
 	// Inference metadata label keys - kept minimal for low cardinality
 	labelInferenceModelName    = "otel.inference.model.name"
 	labelInferenceModelVersion = "otel.inference.model.version"
+
+	// labelRoute carries the rule's route, for use with the routing connector
+	labelRoute = "otel.route"
+
+	// Experiment tracking labels, set from the rule's experiment_id and run_id
+	labelExperimentID = "otel.inference.experiment.id"
+	labelRunID        = "otel.inference.run.id"
+
+	// Request parameters carrying the rule's experiment_id and run_id
+	paramExperimentID = "experiment_id"
+	paramRunID        = "run_id"
 )
 
 // abs returns the absolute value of an int64
@@ -43,43 +61,171 @@ func abs(x int64) int64 {
 
 // modelMetadata holds cached metadata for a model
 type modelMetadata struct {
-	inputs  []*pb.ModelMetadataResponse_TensorMetadata
-	outputs []*pb.ModelMetadataResponse_TensorMetadata
+	versions  []string // Versions reported by the server
+	inputs    []*pb.ModelMetadataResponse_TensorMetadata
+	outputs   []*pb.ModelMetadataResponse_TensorMetadata
+	fetchedAt time.Time // When the metadata was last queried, for its TTL
 }
 
 // metricsinferenceprocessor implements the OpenTelemetry metrics processor interface
 // and acts as a gRPC client for the inference service.
 type metricsinferenceprocessor struct {
+	id           component.ID
+	host         component.Host // Host status events are reported to, set by Start
 	config       *Config
+	scopeVersion string // Version of dedicated output scopes, the collector's version
 	logger       *zap.Logger
 	nextConsumer consumer.Metrics
 
-	grpcConn      *grpc.ClientConn
+	endpoints     *endpointPool  // Connections to the configured inference endpoints
+	headers       requestHeaders // gRPC headers, some templated on resource attributes
 	grpcClient    pb.GRPCInferenceServiceClient
+	server        *serverInfo // What the server reported when connecting, nil when unknown; set with the model metadata
 	lock          sync.Mutex
 	rules         []internalRule
 	modelMetadata map[string]*modelMetadata // Cache of model metadata by model name
+
+	metadataLock  sync.RWMutex       // Guards rules, model metadata and the rule graph against metadata refreshes
+	refreshCancel context.CancelFunc // Stops the background metadata refresh, nil when not running
+	refreshDone   chan struct{}      // Closed when the background metadata refresh exits
+
+	unloadedModels map[string]bool    // Server models unloaded from the repository, whose rules are skipped
+	pollCancel     context.CancelFunc // Stops the background repository poll, nil when not running
+	pollDone       chan struct{}      // Closed when the background repository poll exits
+	stale          *staleMetadata     // Models whose metadata batches found expired or failing validation
+
+	unaryInterceptors []grpc.UnaryClientInterceptor // Interceptors registered with the factory, around every call to the server
+	injectedClient    pb.GRPCInferenceServiceClient // Client registered with the factory in place of the endpoints, nil when not injected
+
+	metadataFailed atomic.Bool     // Whether metadata discovery failure was reported and not yet recovered
+	lazyConnection *lazyConnection // Deferred connection to an unreachable server, nil when connected at startup
+
+	autoDisabledRules atomic.Int32 // Rules disabled for failing too often, reported as a recoverable error
+
+	remote *remoteRules // Rules received from an OpAMP server, nil when not configured
+
+	sequenceLock sync.Mutex
+	sequences    map[int]*sequenceState // Active sequences by rule index
+
+	queue         *inferenceQueue   // Bounded queue of inference calls, nil when disabled
+	resultCache   *resultCache      // Inference result cache, nil when disabled
+	lastValues    *lastValueStore   // Last successful results for last_value fallbacks
+	cumulative    *cumulativeStore  // Running totals of outputs with cumulative temporality
+	staleness     *stalenessTracker // Output series marked stale when no longer produced, nil when disabled
+	seriesLimiter *seriesLimiter    // Output series every rule produces, nil when not limited
+	telemetry     *processorTelemetry
+
+	storageClient storage.Client     // Persists state across restarts, nil when storage is not configured
+	storageCancel context.CancelFunc // Stops saving the state periodically, nil when not running
+	storageDone   chan struct{}      // Closed when the periodic state saving exits
+
+	attributeIndexLock      sync.Mutex
+	attributeIndexes        map[attributeIndexKey]*attributeGroupIndex // Attribute group indexes by rule index and resource
+	lastAttributeIndexPrune time.Time                                  // Last time idle attribute indexes were looked for
+
+	expansionLock      sync.Mutex
+	expansions         map[int]map[string]*ruleExpansion // State of expanded rules, by rule index, then attribute value and resource
+	lastExpansionPrune time.Time                         // Last time idle expansion values were looked for
+
+	activationLock sync.Mutex
+	activations    map[int]pcommon.Timestamp // Time rules became active, by rule index, for activation start timestamps
+
+	triggers       map[int]*intervalTrigger // Inputs buffered for interval-triggered rules, by rule index
+	triggerCancel  context.CancelFunc       // Stops the interval triggers, nil when not running
+	triggerDone    sync.WaitGroup           // Tracks running interval triggers
+	pendingLock    sync.Mutex
+	pendingOutputs pmetric.Metrics // Outputs of interval inferences, emitted with the next batch
+
+	latencies  *latencyEstimates // Expected latency of every model, for deadline budgeting
+	logLimiter *logLimiter       // Deduplicates warnings that repeat every batch
+
+	ruleOrder         []int   // Rule indexes in dependency order
+	ruleStages        [][]int // Rule indexes grouped into stages that can run concurrently
+	ruleHasDependents []bool  // Whether a rule's outputs feed other rules, by rule index
 }
 
 // internalOutputSpec represents a single output specification for internal processing
 type internalOutputSpec struct {
-	name        string // Name for the output metric
-	dataType    string // Expected data type of the output
-	description string // Description for the output metric
-	unit        string // Unit for the output metric
-	outputIndex *int   // Output tensor index (if specified)
-	discovered  bool   // Whether this output was discovered from metadata
+	name        string          // Name for the output metric
+	dataType    string          // Expected data type of the output
+	description string          // Description for the output metric
+	unit        string          // Unit for the output metric
+	outputIndex *int            // Output tensor index (if specified)
+	tensorName  string          // Output tensor name (if specified), matched regardless of position
+	discovered  bool            // Whether this output was discovered from metadata
+	post        []postTransform // Post-processing transforms applied to output values
+	horizon     time.Duration   // Prediction horizon for description templates
+
+	columns        string   // Decoding of the second dimension of shaped outputs: "index" or "split"
+	columnNames    []string // Names of the columns of shaped outputs
+	indexAttribute string   // Attribute holding the column in "index" mode
+	namedElements  bool     // Whether the tensor is decoded as rows of the named columns whatever its shape
+
+	fallback     outputFallback // Values emitted in place of results when inference fails
+	samplingHint *samplingHint  // Flags anomalous values for trace sampling, nil when unused
+	cumulative   bool           // Whether values are increments accumulated into a cumulative sum
+	attach       bool           // Whether values are also written as attributes of the input data points
+
+	histogram *histogramOutput // Assembles bucket counts into a histogram, nil for gauge outputs
+	embedding *embeddingOutput // Reduces embedding vectors to a value per vector, nil for other outputs
+
+	published outputMetadata // Unit, description and metric type published in model metadata
 }
 
 // internalRule represents a single inference rule configuration
 type internalRule struct {
-	modelName      string                 // Name of the model to use for inference
-	modelVersion   string                 // Version of the model to use
-	inputs         []string               // Names of input metrics (may include label selectors)
-	inputSelectors []*labelSelector       // Parsed label selectors for each input
-	outputs        []internalOutputSpec   // Output specifications
-	outputPattern  string                 // Template pattern for output metric names
-	parameters     map[string]interface{} // Additional parameters for the model
+	modelName         string                     // Name of the model to use for inference
+	modelVersion      string                     // Version of the model to use
+	inputs            []string                   // Names of input metrics (may include label selectors)
+	inputSelectors    []*labelSelector           // Parsed label selectors for each input
+	discoverInputs    bool                       // Whether inputs are discovered from model metadata
+	inputPrefix       string                     // Prefix of the metric names discovered inputs are looked up as
+	inputTensors      map[string]string          // Tensor names of mapped inputs, and of discovered inputs when they differ, by input name
+	mapsInputs        bool                       // Whether inputs are mapped to model inputs by name
+	outputs           []internalOutputSpec       // Output specifications
+	outputPattern     string                     // Template pattern for output metric names
+	parameters        map[string]interface{}     // Additional parameters for the model
+	sequenceEnabled   bool                       // Whether sequence control parameters are sent
+	correlationID     uint64                     // Sequence correlation ID for stateful models
+	controlInputs     ControlInputsConfig        // Sequence CONTROL input tensor names
+	perSeries         bool                       // Whether correlation IDs are derived per attribute set
+	backend           InferenceClient            // In-process backend, nil for rules served by the inference server
+	encoders          map[string]TensorEncoder   // Encoders configured for inputs, by input name
+	transforms        map[string]*inputTransform // Delta or rate transforms of inputs, by input name
+	route             string                     // Route stamped on outputs, empty when not routed
+	id                string                     // Identifier on the otel.inference.rule_id label
+	inferenceLabels   bool                       // Whether outputs are labeled with the model
+	outputScope       string                     // Name of the scope outputs are added to, empty for the input's scope
+	callOptions       []grpc.CallOption          // Per-call options of the rule's requests, such as its compression
+	experimentID      string                     // Experiment identifier stamped on outputs, empty when not tagged
+	runID             string                     // Run identifier stamped on outputs, empty when not tagged
+	attributes        *outputAttributePolicy     // Copying of input attributes onto outputs
+	forwardAttributes []forwardedAttribute       // Resource and scope attributes sent to the model
+	timeFeatures      *timeFeatures              // Calendar features sent as an extra tensor, nil when not declared
+	privacy           *attributePrivacy          // Hashes or redacts attribute values sent to the model, nil when unused
+	responseParams    []responseParameter        // Response parameters surfaced as attributes or metrics
+	inFlight          chan struct{}              // Slots limiting concurrent requests, nil when unlimited
+	autoDisable       *autoDisabler              // Disables the rule while it fails too often, nil when never disabled
+	maxGroups         int                        // Attribute groups per request, larger calls are split; zero is unlimited
+	priority          int                        // Order of the rule's calls in the inference queue, higher first
+	queue             *inferenceQueue            // Queue with workers of the rule's own, nil when its calls use the processor's
+	deadline          time.Duration              // Time budget of the rule's inference in a batch, zero for the request timeout
+	every             time.Duration              // Interval between inferences of an interval-triggered rule, zero when it infers on every batch
+	expandBy          string                     // Attribute the rule infers once per value of, empty when not expanded
+	paramTemplates    map[string]string          // Parameters rendered per request from the rule's inputs, by name
+	onError           string                     // What happens to the batch when the rule's inference fails
+	partialGroups     string                     // What happens to attribute groups lacking some inputs
+	mixedScopes       string                     // Where outputs go when the inputs span scopes
+	matching          string                     // How inputs are matched into attribute groups, empty for the feature gate's default
+	maxCartesian      int                        // Attribute groups a cartesian rule infers on per resource and batch
+	recordLatency     string                     // How the inference latency is recorded on outputs, empty when it is not
+	enabled           bool                       // Whether the rule is enabled in the configuration
+	gate              *featuregate.Gate          // Feature gate toggling the rule at runtime, nil when none
+	selector          *modelSelector             // Selection of the model by labels, nil when the model is named
+	shadow            *shadowMode                // Reporting of a shadow rule's results, nil for active rules
+	challenger        *challenger                // Model compared with the rule's model, nil when none
+	combine           *combinedInputs            // Merging of the inputs into one tensor, nil when sent separately
+	aggregate         *aggregation               // Combination of the inputs without inference, nil when the rule infers
 }
 
 // modelContext holds the context for processing a specific model inference
@@ -90,19 +236,29 @@ type modelContext struct {
 	resourceMetrics pmetric.ResourceMetrics
 	scopeMetrics    pmetric.ScopeMetrics
 	// Track input data points for attribute copying
-	inputDataPoints map[string][]pmetric.NumberDataPoint
+	inputDataPoints map[string][]dataPoint
 	// Track if context has been set
 	hasContext bool
 	// Track which rule index this context represents
 	ruleIndex int
 	// Track matched data point groups for attribute preservation
 	matchedDataPoints []dataPointGroup
+	// Pooled tensor buffers of the context's request, released once its call is applied
+	buffers *tensorBuffers
+	// Scaling statistics of inputs exposing them, by input name
+	scaling map[string]scalingParameters
+	// Scopes the inputs were read from, in input order
+	inputScopes []pmetric.ScopeMetrics
+	// Value of the attribute an expanded rule infers for, nil when not expanded
+	expansion *ruleExpansion
+	// Output data points the rules added to the batch, nil when not limited
+	budget *outputBudget
 }
 
 // dataPointGroup represents a group of data points with matching attribute sets
 type dataPointGroup struct {
-	attributes pcommon.Map                        // The common attribute set
-	dataPoints map[string]pmetric.NumberDataPoint // metric name -> data point
+	attributes pcommon.Map          // The common attribute set
+	dataPoints map[string]dataPoint // metric name -> data point
 }
 
 // newMetricsProcessor creates a new metrics inference processor with the given configuration.
@@ -115,38 +271,170 @@ func newMetricsProcessor(
 		return nil, fmt.Errorf("nil next consumer")
 	}
 
-	if cfg.GRPCClientSettings.Endpoint == "" {
+	cfg, err := cfg.withRuleFiles()
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.withPresets()
+
+	// Remote rules replace the rules of the configuration, which is then the
+	// processor's own rather than the collector's
+	if cfg.RemoteRules != nil {
+		owned := *cfg
+		cfg = &owned
+	}
+
+	if len(cfg.GRPCClientSettings.endpointList()) == 0 && cfg.requiresInferenceServer() && !cfg.clientInjected {
 		return nil, fmt.Errorf("gRPC endpoint must be configured")
 	}
 
+	headers, err := newRequestHeaders(cfg.GRPCClientSettings.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gRPC headers: %w", err)
+	}
+
 	mp := &metricsinferenceprocessor{
-		config:        cfg,
-		logger:        logger,
-		nextConsumer:  nextConsumer,
-		rules:         buildInternalConfig(cfg),
-		modelMetadata: make(map[string]*modelMetadata),
+		config:         cfg,
+		logger:         logger,
+		nextConsumer:   nextConsumer,
+		headers:        headers,
+		rules:          buildInternalConfig(cfg),
+		modelMetadata:  make(map[string]*modelMetadata),
+		unloadedModels: make(map[string]bool),
+		stale:          newStaleMetadata(),
+		sequences:      make(map[int]*sequenceState),
+
+		attributeIndexes: make(map[attributeIndexKey]*attributeGroupIndex),
+		expansions:       make(map[int]map[string]*ruleExpansion),
+		activations:      make(map[int]pcommon.Timestamp),
+		pendingOutputs:   pmetric.NewMetrics(),
+		lastValues:       newLastValueStore(),
+		cumulative:       newCumulativeStore(),
+		latencies:        newLatencyEstimates(),
+		staleness:        newStalenessTracker(cfg.Staleness.Period),
+		seriesLimiter:    newSeriesLimiter(cfg.Cardinality.MaxSeries),
+		logLimiter:       newLogLimiter(logger, cfg.Logging),
 	}
 
+	mp.queue = newInferenceQueue(cfg.Queue, mp.recordQueueDepth)
+	for ruleIdx, rule := range cfg.Rules {
+		if rule.Queue != nil {
+			mp.rules[ruleIdx].queue = newInferenceQueue(*rule.Queue, mp.recordQueueDepth)
+		}
+	}
+
+	if cfg.Cache.Enabled {
+		mp.resultCache = newResultCache(cfg.Cache.TTL, cfg.Cache.MaxEntries)
+	}
+
+	if err := resolveRuleGates(cfg, mp.rules); err != nil {
+		return nil, err
+	}
+
+	if err := mp.updateRuleGraph(); err != nil {
+		return nil, err
+	}
+	mp.triggers = newIntervalTriggers(mp.rules)
+
+	mp.warnInvalidUnits()
+
+	telemetry, err := newProcessorTelemetry(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry: %w", err)
+	}
+	mp.telemetry = telemetry
+
 	return mp, nil
 }
 
+// recordQueueDepth records a change of the number of a rule's calls waiting in a queue
+func (mp *metricsinferenceprocessor) recordQueueDepth(rule string, delta int64) {
+	mp.telemetry.recordQueueDepth(context.Background(), rule, delta)
+}
+
 // Start initializes the gRPC connection to the inference server
-func (mp *metricsinferenceprocessor) Start(ctx context.Context, _ component.Host) error {
+func (mp *metricsinferenceprocessor) Start(ctx context.Context, host component.Host) (err error) {
 	mp.lock.Lock()
 	defer mp.lock.Unlock()
+	mp.host = host
+
+	// Rules that can never match are reported, the other rules keep running
+	mp.reportInvalidSelectors()
+
+	// Restore per-series state saved by a previous run
+	if err := mp.startStorage(ctx, host); err != nil {
+		return err
+	}
+	// A failed start releases the storage client without saving over the state
+	defer func() {
+		if err != nil {
+			mp.closeStorage(ctx)
+		}
+	}()
+
+	// Interval-triggered rules infer on their own timers
+	mp.startTriggers()
+
+	// Rules pushed by an OpAMP server are applied once Start returns
+	if err := mp.startRemoteRules(host); err != nil {
+		return err
+	}
 
 	// Set up gRPC connection with the configured options
-	endpoint := mp.config.GRPCClientSettings.Endpoint
-	mp.logger.Info("Starting metrics inference processor", zap.String("endpoint", endpoint))
-
-	// Handle component lifecycle test case
-	// The generated lifecycle test uses "localhost:12345" which doesn't exist
-	// This allows the test to pass while maintaining production functionality
-	if endpoint == "localhost:12345" {
-		mp.logger.Info("Component lifecycle test detected - skipping gRPC connection")
+	endpoints := mp.config.GRPCClientSettings.endpointList()
+	mp.logger.Info("Starting metrics inference processor", zap.Strings("endpoints", endpoints))
+
+	// All rules produce synthetic predictions, so there is no server to connect to
+	if !mp.config.requiresInferenceServer() {
+		mp.logger.Info("All rules use synthetic predictions - skipping gRPC connection")
 		return nil
 	}
 
+	// A client injected by the embedding program replaces the configured endpoints
+	if mp.injectedClient != nil {
+		mp.endpoints = newClientPool(mp.injectedClient, mp.logger)
+		mp.grpcClient = mp.injectedClient
+	} else if err := mp.dialEndpoints(ctx, endpoints); err != nil {
+		return err
+	}
+
+	// Check if the server is alive as the startup health check is configured;
+	// with lazy connect, an unreachable server is connected to when batches
+	// arrive instead of failing startup
+	switch mp.config.GRPCClientSettings.StartupHealthCheck {
+	case startupHealthCheckSkip:
+		mp.logger.Info("Skipping the startup health check of the inference server")
+	case startupHealthCheckWarn:
+		if err := mp.checkServerAtStartup(ctx); err != nil {
+			mp.logger.Warn("Inference server not reachable, starting without it",
+				zap.Strings("endpoints", endpoints),
+				zap.Error(err))
+		}
+	default:
+		if err := mp.checkServerAtStartup(ctx); err != nil {
+			if !mp.config.GRPCClientSettings.LazyConnect {
+				return err
+			}
+			mp.logger.Warn("Inference server not reachable, connecting on first use",
+				zap.Strings("endpoints", endpoints),
+				zap.Error(err))
+			mp.reportStatus(componentstatus.NewRecoverableErrorEvent(err))
+			mp.lazyConnection = newLazyConnection()
+			return nil
+		}
+	}
+
+	if err := mp.completeConnection(ctx); err != nil {
+		return err
+	}
+
+	// A dry run checks every rule against its model before any batch flows
+	return mp.dryRun()
+}
+
+// dialEndpoints connects to the configured endpoints with the configured
+// connection settings
+func (mp *metricsinferenceprocessor) dialEndpoints(ctx context.Context, endpoints []string) error {
 	// Prepare dial options based on configuration
 	dialOpts := []grpc.DialOption{}
 
@@ -161,7 +449,9 @@ func (mp *metricsinferenceprocessor) Start(ctx context.Context, _ component.Host
 
 	// Configure compression if enabled
 	if mp.config.GRPCClientSettings.Compression {
-		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
+			grpc.UseCompressor(compressorName(mp.config.GRPCClientSettings.CompressionAlgorithm)),
+		))
 	}
 
 	// Configure maximum message size if specified
@@ -170,6 +460,11 @@ func (mp *metricsinferenceprocessor) Start(ctx context.Context, _ component.Host
 			grpc.MaxCallRecvMsgSize(mp.config.GRPCClientSettings.MaxReceiveMessageSize),
 		))
 	}
+	if mp.config.GRPCClientSettings.MaxSendMessageSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallSendMsgSize(mp.config.GRPCClientSettings.MaxSendMessageSize),
+		))
+	}
 
 	// Configure keepalive if specified
 	if mp.config.GRPCClientSettings.KeepAlive != nil {
@@ -181,97 +476,126 @@ func (mp *metricsinferenceprocessor) Start(ctx context.Context, _ component.Host
 		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(kacp))
 	}
 
-	// Establish the gRPC connection with context
-	// Using DialContext allows better control over connection lifecycle
-	conn, err := grpc.DialContext(ctx, endpoint, dialOpts...)
+	// Interceptors registered by the distribution wrap every call to the server
+	if len(mp.unaryInterceptors) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(mp.unaryInterceptors...))
+	}
+
+	// Propagate the collector's trace context to the inference server
+	dialOpts = append(dialOpts, grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+		otelgrpc.WithTracerProvider(mp.telemetry.tracerProvider),
+		otelgrpc.WithPropagators(propagation.TraceContext{}),
+	)))
+
+	// Establish the configured number of gRPC connections per endpoint; the pool
+	// balances calls across them and fails over when an endpoint is unavailable
+	pool, err := newEndpointPool(ctx, endpoints, mp.config.GRPCClientSettings.LoadBalancing,
+		mp.config.GRPCClientSettings.Channels, mp.logger, mp.telemetry, dialOpts...)
 	if err != nil {
-		return fmt.Errorf("failed to connect to inference server: %w", err)
+		return err
 	}
 
-	mp.grpcConn = conn
-	mp.grpcClient = pb.NewGRPCInferenceServiceClient(conn)
+	mp.endpoints = pool
+	mp.grpcClient = pb.NewGRPCInferenceServiceClient(pool)
+	return nil
+}
 
-	// Check if the server is alive with timeout
-	timeoutDuration := 5 * time.Second
+// requestTimeout returns the timeout of calls to the inference server
+func (mp *metricsinferenceprocessor) requestTimeout() time.Duration {
 	if mp.config.Timeout > 0 {
-		timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
+		return time.Duration(mp.config.Timeout) * time.Second
 	}
+	return 5 * time.Second
+}
 
+// checkServer performs the server health check; it succeeds as long as one endpoint is live
+func (mp *metricsinferenceprocessor) checkServer(ctx context.Context) error {
+	timeoutDuration := mp.requestTimeout()
 	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
 	defer cancel()
 
 	// Add headers if specified
-	if len(mp.config.GRPCClientSettings.Headers) > 0 {
-		md := metadata.New(mp.config.GRPCClientSettings.Headers)
-		ctx = metadata.NewOutgoingContext(ctx, md)
-	}
+	ctx = mp.headers.withStatic(ctx)
 
-	// Perform server health check
-	_, err = mp.grpcClient.ServerLive(ctx, &pb.ServerLiveRequest{})
-	if err != nil {
+	if err := mp.endpoints.checkHealth(ctx, nil, timeoutDuration); err != nil {
 		return fmt.Errorf("inference server health check failed: %w", err)
 	}
 
-	mp.logger.Info("Successfully connected to inference server", zap.String("endpoint", endpoint))
+	mp.logger.Info("Successfully connected to inference server",
+		zap.Strings("endpoints", mp.config.GRPCClientSettings.endpointList()))
+	return nil
+}
+
+// completeConnection discovers model metadata once the inference server is
+// reachable and starts the background metadata refresh and health checks.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) completeConnection(ctx context.Context) error {
+	timeoutDuration := mp.requestTimeout()
+	metadataCtx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
+	// Adapt to the extensions the server supports before using any of them
+	mp.negotiateExtensions(metadataCtx)
 
-	// Query metadata for all unique models in the rules
-	if err := mp.queryModelMetadata(ctx); err != nil {
+	// Resolve the models of rules selecting them by labels, then query metadata
+	// for all unique models in the rules
+	mp.applySelectedModels(mp.selectModels(metadataCtx, mp.grpcClient))
+	err := mp.queryModelMetadata(metadataCtx)
+	if err != nil {
 		// Log warning but don't fail - metadata discovery is optional
 		mp.logger.Warn("Failed to query model metadata, will require explicit output configuration", zap.Error(err))
 	}
+	mp.reportMetadataStatus(err)
 
-	// Merge discovered metadata with configured outputs
+	// Merge discovered metadata with configured inputs and outputs
+	mp.mergeDiscoveredInputs()
+	mp.checkInputMaps()
 	mp.mergeDiscoveredOutputs()
 
-	return nil
-}
-
-// queryModelMetadata queries and caches metadata for all unique models in the rules
-func (mp *metricsinferenceprocessor) queryModelMetadata(ctx context.Context) error {
-	// Collect unique model names
-	uniqueModels := make(map[string]string) // model name -> version
-	for _, rule := range mp.rules {
-		uniqueModels[rule.modelName] = rule.modelVersion
+	// Discovered input and output names may add dependencies between rules
+	if err := mp.updateRuleGraph(); err != nil {
+		return err
 	}
 
-	// Query metadata for each unique model
-	for modelName, modelVersion := range uniqueModels {
-		mp.logger.Info("Querying metadata for model", zap.String("model", modelName), zap.String("version", modelVersion))
+	// Load the models before the first batch needs them
+	mp.warmUpModels(ctx)
 
-		// Create metadata request
-		metadataReq := &pb.ModelMetadataRequest{
-			Name:    modelName,
-			Version: modelVersion,
-		}
+	// Pick up model redeploys while the collector keeps running
+	mp.startMetadataRefresh(mp.grpcClient)
+	mp.startRepositoryPoll(mp.grpcClient)
+	mp.stale.start(mp.grpcClient)
 
-		// Add headers if specified
-		metadataCtx := ctx
-		if len(mp.config.GRPCClientSettings.Headers) > 0 {
-			md := metadata.New(mp.config.GRPCClientSettings.Headers)
-			metadataCtx = metadata.NewOutgoingContext(ctx, md)
-		}
+	// Detect failed and recovered endpoints between calls
+	healthCheckInterval := mp.config.GRPCClientSettings.HealthCheckInterval
+	if healthCheckInterval == 0 {
+		healthCheckInterval = defaultHealthCheckInterval
+	}
+	mp.endpoints.startHealthCheck(mp.headers.static, healthCheckInterval, timeoutDuration)
+	return nil
+}
 
-		// Query model metadata with timeout
-		timeoutDuration := 5 * time.Second
-		if mp.config.Timeout > 0 {
-			timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
-		}
-		metadataCtx, cancel := context.WithTimeout(metadataCtx, timeoutDuration)
-		defer cancel()
+// queryModelMetadata queries and caches metadata for all unique models in the
+// rules. It returns the joined errors of the models whose metadata could not be
+// queried, after caching the metadata of the others.
+func (mp *metricsinferenceprocessor) queryModelMetadata(ctx context.Context) error {
+	// Query metadata for each unique model, a few models at a time
+	models := mp.serverModels()
+	for modelName, modelVersion := range models {
+		mp.logger.Info("Querying metadata for model", zap.String("model", modelName), zap.String("version", modelVersion))
+	}
+	fetched, failed := mp.fetchModelsMetadata(ctx, mp.grpcClient, models)
 
-		resp, err := mp.grpcClient.ModelMetadata(metadataCtx, metadataReq)
-		if err != nil {
-			mp.logger.Warn("Failed to query metadata for model",
-				zap.String("model", modelName),
-				zap.Error(err))
-			continue
-		}
+	var errs []error
+	for _, modelName := range slices.Sorted(maps.Keys(failed)) {
+		mp.logger.Warn("Failed to query metadata for model",
+			zap.String("model", modelName),
+			zap.Error(failed[modelName]))
+		errs = append(errs, fmt.Errorf("model %s: %w", modelName, failed[modelName]))
+	}
 
+	for modelName, resp := range fetched {
 		// Cache the metadata
-		mp.modelMetadata[modelName] = &modelMetadata{
-			inputs:  resp.Inputs,
-			outputs: resp.Outputs,
-		}
+		mp.modelMetadata[modelName] = newModelMetadata(resp)
 
 		mp.logger.Info("Successfully cached metadata for model",
 			zap.String("model", modelName),
@@ -289,7 +613,7 @@ func (mp *metricsinferenceprocessor) queryModelMetadata(ctx context.Context) err
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // validateRuleInputs validates that rule inputs match the model's expected input signature
@@ -302,6 +626,11 @@ func (mp *metricsinferenceprocessor) validateRuleInputs(rule internalRule, input
 		return nil // Skip validation if no metadata available
 	}
 
+	// Inputs combined into one tensor do not map to the model's inputs one to one
+	if mp.combinesInputs(rule) {
+		return nil
+	}
+
 	// Skip validation if model metadata has no input specifications
 	if len(metadata.inputs) == 0 {
 		mp.logger.Debug("Model metadata has no input specifications, skipping input validation",
@@ -309,10 +638,18 @@ func (mp *metricsinferenceprocessor) validateRuleInputs(rule internalRule, input
 		return nil
 	}
 
-	// Check if the number of inputs matches
-	if len(rule.inputs) != len(metadata.inputs) {
+	if err := checkControlInputs(rule, metadata.inputs); err != nil {
+		return err
+	}
+
+	// Mapped inputs must name every model input; others must match them in number
+	if rule.mapsInputs {
+		if err := checkInputMap(rule, metadata.inputs); err != nil {
+			return err
+		}
+	} else if defined := rule.definedInputs(); defined != len(metadata.inputs) {
 		return fmt.Errorf("model %s expects %d inputs but rule defines %d inputs",
-			rule.modelName, len(metadata.inputs), len(rule.inputs))
+			rule.modelName, len(metadata.inputs), defined)
 	}
 
 	// Validate each input against model expectations
@@ -323,14 +660,13 @@ func (mp *metricsinferenceprocessor) validateRuleInputs(rule internalRule, input
 			return fmt.Errorf("input metric %s not found in metrics batch", inputName)
 		}
 
-		// Get expected input metadata (assume inputs are in order)
-		if i >= len(metadata.inputs) {
+		// Get expected input metadata, by name for mapped inputs and else in order
+		expectedInput := expectedModelInput(rule, metadata.inputs, i, inputName)
+		if expectedInput == nil {
 			return fmt.Errorf("rule input %d (%s) exceeds model's expected inputs (%d)",
 				i, inputName, len(metadata.inputs))
 		}
 
-		expectedInput := metadata.inputs[i]
-
 		// Validate data type compatibility
 		err := mp.validateInputDataType(metric, expectedInput, inputName)
 		if err != nil {
@@ -449,8 +785,9 @@ func (mp *metricsinferenceprocessor) validateInputShape(metric pmetric.Metric, e
 func (mp *metricsinferenceprocessor) isDataTypeCompatible(metricType, tensorType string) bool {
 	// Define compatibility matrix
 	switch tensorType {
-	case "FP32", "FP64":
-		// Floating point tensors accept int and float metrics
+	case "FP16", "BF16", "FP32", "FP64":
+		// Floating point tensors accept int and float metrics, half precision
+		// being packed into raw contents
 		return metricType == "INT64" || metricType == "FP64"
 	case "INT8", "INT16", "INT32", "INT64":
 		// Integer tensors accept int metrics, and can convert floats if they're whole numbers
@@ -467,12 +804,30 @@ func (mp *metricsinferenceprocessor) isDataTypeCompatible(metricType, tensorType
 
 // Shutdown closes the gRPC connection
 func (mp *metricsinferenceprocessor) Shutdown(ctx context.Context) error {
+	// Remote rules restart the triggers, so they are stopped first
+	mp.stopRemoteRules()
+
+	// Triggers read the client under mp.lock, so they are stopped before taking it
+	mp.stopTriggers()
+
 	mp.lock.Lock()
 	defer mp.lock.Unlock()
 
-	if mp.grpcConn != nil {
-		// Close the connection and wait for it to complete
-		err := mp.grpcConn.Close()
+	mp.stopMetadataRefresh()
+	mp.stopRepositoryPoll()
+	mp.stale.stop()
+
+	// Save per-series state for the next run
+	if err := mp.stopStorage(ctx); err != nil {
+		return err
+	}
+
+	// Release server-side sequence slots before the connection goes away
+	mp.endSequences(ctx)
+
+	if mp.endpoints != nil {
+		// Close the connections and wait for them to complete
+		err := mp.endpoints.Close()
 		if err != nil {
 			return fmt.Errorf("failed to close gRPC connection: %w", err)
 		}
@@ -488,7 +843,7 @@ func (mp *metricsinferenceprocessor) Shutdown(ctx context.Context) error {
 			return ctx.Err()
 		}
 
-		mp.grpcConn = nil
+		mp.endpoints = nil
 		mp.grpcClient = nil
 	}
 
@@ -510,252 +865,383 @@ func (mp *metricsinferenceprocessor) processMetrics(ctx context.Context, md pmet
 	client := mp.grpcClient
 	mp.lock.Unlock()
 
-	if client == nil {
-		// During component lifecycle tests, we don't have a gRPC connection
-		// Just pass through the metrics without processing
-		if mp.config.GRPCClientSettings.Endpoint == "localhost:12345" {
-			mp.logger.Debug("Component lifecycle test detected - passing through metrics without inference")
-			return mp.nextConsumer.ConsumeMetrics(ctx, md)
-		}
-		mp.logger.Error("gRPC client not initialized, dropping metrics batch")
-		return mp.nextConsumer.ConsumeMetrics(ctx, md)
+	// A dry run only checks the rules at startup
+	if mp.config.DryRun {
+		return mp.passThrough(ctx, md)
 	}
 
-	mp.logger.Debug("Processing metrics batch", zap.Int("metric_count", md.MetricCount()))
-
-	// Create a map to store rule information for each model
-
-	// Group metrics by rule (not just model name) to handle multiple instances of the same model
-	ruleContexts := make(map[int]*modelContext) // Use rule index as key
-
-	// Iterate through all resource metrics
-	for i := 0; i < md.ResourceMetrics().Len(); i++ {
-		rm := md.ResourceMetrics().At(i)
+	if client == nil && mp.config.requiresInferenceServer() {
+		mp.logLimiter.Error(noRule, "gRPC client not initialized, dropping metrics batch")
+		return mp.passThrough(ctx, md)
+	}
 
-		// Create a map of metric name to metric for easy lookup
-		metricMap := make(map[string]pmetric.Metric)
-		// Also track which ScopeMetrics each metric comes from
-		metricToScopeMap := make(map[string]pmetric.ScopeMetrics)
+	// Until a lazy connection to the inference server succeeds, batches pass through
+	if !mp.lazyConnected(ctx) {
+		return mp.passThrough(ctx, md)
+	}
 
-		// Iterate through all scope metrics
-		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
-			sm := rm.ScopeMetrics().At(j)
+	mp.logger.Debug("Processing metrics batch", zap.Int("metric_count", md.MetricCount()))
 
-			// Iterate through all metrics in this scope
-			for k := 0; k < sm.Metrics().Len(); k++ {
-				metric := sm.Metrics().At(k)
-				metricMap[metric.Name()] = metric
-				metricToScopeMap[metric.Name()] = sm
+	// The batch's own metrics, removed once the rules consumed them when only
+	// outputs are emitted
+	var inputCounts [][]int
+	if mp.outputsOnly() {
+		inputCounts = scopeMetricCounts(md)
+	}
+
+	// Keep rules and model metadata stable while the batch is processed
+	mp.metadataLock.RLock()
+	defer mp.metadataLock.RUnlock()
+
+	// A batch returned for retry must come back as it was sent, so the rules work
+	// on a copy of it, and their state is rolled back when it is returned
+	var checkpoint *batchCheckpoint
+	if mp.retriesBatches() {
+		checkpoint = mp.checkpoint()
+		original := md
+		md = pmetric.NewMetrics()
+		original.CopyTo(md)
+	}
+
+	// Outputs of interval inferences since the last batch join this one, before
+	// the rules consuming them run; a rollback holds them for the next batch
+	pending := mp.emitPendingOutputs(md)
+	if checkpoint != nil {
+		checkpoint.pending = pending
+	}
+
+	// Rules run in dependency stages so that a rule can consume the outputs of
+	// earlier rules within the same batch. The rules of a stage infer concurrently,
+	// so a slow model only delays the batch up to the maximum batch delay, and their
+	// results are applied in rule order.
+	batchCtx, cancelBatch := mp.batchContext(ctx)
+	defer cancelBatch()
+	resources := indexBatchMetrics(md)
+	budget := newOutputBudget(mp.config.Cardinality.MaxDataPoints)
+	var failed []ruleFailure
+	for _, stage := range mp.ruleStages {
+		calls := make([]*ruleCall, 0, len(stage))
+		var aggregations []int
+		for _, ruleIdx := range stage {
+			// Disabled rules are left out until they are enabled again
+			active := mp.rules[ruleIdx].active()
+			mp.trackActivation(ruleIdx, active)
+			if !active {
+				continue
+			}
+			// Interval-triggered rules only accumulate the batch's inputs, and
+			// consult their auto-disabler when the interval calls the model
+			if trigger := mp.triggers[ruleIdx]; trigger != nil {
+				trigger.buffer(resources, mp.rules[ruleIdx])
+				continue
 			}
+			// Rules failing too often are left out until their cool-down ends
+			if mp.autoDisabled(ctx, ruleIdx) {
+				continue
+			}
+			if mp.rules[ruleIdx].aggregate != nil {
+				aggregations = append(aggregations, ruleIdx)
+				continue
+			}
+			calls = append(calls, mp.prepareRuleCalls(resources, ruleIdx)...)
 		}
 
-		// Process each rule individually
-		for ruleIdx, rule := range mp.rules {
-			// Initialize rule context if not exists
-			if _, exists := ruleContexts[ruleIdx]; !exists {
-				ruleContexts[ruleIdx] = &modelContext{
-					inputs:          make(map[string]pmetric.Metric),
-					rule:            rule,
-					inputDataPoints: make(map[string][]pmetric.NumberDataPoint),
-					ruleIndex:       ruleIdx,
-				}
+		mp.inferStage(batchCtx, client, calls)
+
+		reindex := false
+		for _, call := range calls {
+			call.ctx.budget = budget
+			if err := mp.applyRuleCall(ctx, md, call); err != nil && call.ctx.rule.onError != onErrorPass {
+				failed = append(failed, ruleFailure{ctx: call.ctx, err: err})
 			}
+			reindex = reindex || mp.ruleHasDependents[call.ruleIdx]
+		}
+		releaseRuleCalls(calls)
 
-			// Collect metrics for this rule based on the inputs specified
-			for inputIdx, inputName := range rule.inputs {
-				selector := rule.inputSelectors[inputIdx]
-				if selector == nil {
-					// Invalid selector, skip this input
-					continue
-				}
+		// Aggregation rules combine values already in the batch, without inference
+		for _, ruleIdx := range aggregations {
+			mp.applyAggregation(md, resources, ruleIdx, budget)
+			reindex = reindex || mp.ruleHasDependents[ruleIdx]
+		}
 
-				// For backward compatibility, check if this is a simple metric name
-				if len(selector.labels) == 0 {
-					// No label filters, use simple name matching
-					if metric, exists := metricMap[selector.metricName]; exists {
-						ruleContexts[ruleIdx].inputs[inputName] = metric
-
-						// Set ResourceMetrics context for this rule (use first input's context)
-						if !ruleContexts[ruleIdx].hasContext {
-							ruleContexts[ruleIdx].resourceMetrics = rm
-							ruleContexts[ruleIdx].scopeMetrics = metricToScopeMap[selector.metricName]
-							ruleContexts[ruleIdx].hasContext = true
-						}
-
-						// Collect data points for attribute copying
-						dataPoints := extractDataPoints(metric)
-						ruleContexts[ruleIdx].inputDataPoints[inputName] = dataPoints
-					}
-				} else {
-					// Label filters specified, need to search through all metrics
-					for metricName, metric := range metricMap {
-						if matchesSelector(metric, selector) {
-							// Filter the metric to only include matching data points
-							filteredMetric := filterMetricByLabels(metric, selector.labels)
-							ruleContexts[ruleIdx].inputs[inputName] = filteredMetric
-
-							// Set ResourceMetrics context for this rule (use first input's context)
-							if !ruleContexts[ruleIdx].hasContext {
-								ruleContexts[ruleIdx].resourceMetrics = rm
-								ruleContexts[ruleIdx].scopeMetrics = metricToScopeMap[metricName]
-								ruleContexts[ruleIdx].hasContext = true
-							}
-
-							// Collect data points for attribute copying
-							dataPoints := extractDataPoints(filteredMetric)
-							ruleContexts[ruleIdx].inputDataPoints[inputName] = dataPoints
-							break // Only take the first match
-						}
-					}
-				}
-			}
+		// Make this stage's outputs visible to the rules consuming them
+		if reindex {
+			resources = indexBatchMetrics(md)
 		}
 	}
 
-	// Process each rule's inputs and send to inference server
-	for ruleIdx, ruleCtx := range ruleContexts {
-		modelName := ruleCtx.rule.modelName
-		expectedInputs := len(ruleCtx.rule.inputs)
-		foundInputs := len(ruleCtx.inputs)
+	if inputCounts != nil {
+		removeInputs(md, inputCounts)
+	}
 
-		if foundInputs == 0 {
-			mp.logger.Warn("No input metrics found for inference rule",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Strings("expected_inputs", ruleCtx.rule.inputs),
-				zap.String("suggestion", "Verify metric names exist in the data pipeline"))
-			continue
+	// Hold back the data of rules whose inference failed, as configured
+	if drop, err := mp.holdBack(md, failed); drop {
+		if checkpoint != nil && err != nil && !consumererror.IsPermanent(err) {
+			mp.rollback(checkpoint)
 		}
+		return err
+	}
 
-		if foundInputs < expectedInputs {
-			// Log which specific metrics are missing
-			missingInputs := make([]string, 0)
-			for _, expectedInput := range ruleCtx.rule.inputs {
-				if _, exists := ruleCtx.inputs[expectedInput]; !exists {
-					missingInputs = append(missingInputs, expectedInput)
-				}
+	// Mark series the rules stopped producing as stale
+	if markers := mp.staleness.emitMarkers(md); markers > 0 {
+		mp.logger.Debug("Emitted staleness markers for series no longer produced", zap.Int("series_count", markers))
+	}
+
+	// Batches left without outputs are not forwarded
+	if inputCounts != nil && md.ResourceMetrics().Len() == 0 {
+		return nil
+	}
+
+	return mp.nextConsumer.ConsumeMetrics(ctx, md)
+}
+
+// prepareRuleCall collects a rule's inputs from the batch and builds its inference
+// request. It returns nil when the rule cannot run on this batch, and a call
+// without a request, holding the error, when building the request failed.
+func (mp *metricsinferenceprocessor) prepareRuleCall(resources []resourceMetricIndex, ruleIdx int, expansion *ruleExpansion) *ruleCall {
+	ruleCtx := mp.collectRuleInputs(resources, ruleIdx, expansion)
+	modelName := ruleCtx.rule.modelName
+	expectedInputs := len(ruleCtx.rule.inputs)
+	foundInputs := len(ruleCtx.inputs)
+
+	if foundInputs == 0 {
+		mp.logLimiter.Warn(ruleIdx, "No input metrics found for inference rule",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Strings("expected_inputs", ruleCtx.rule.inputs),
+			zap.String("suggestion", "Verify metric names exist in the data pipeline"))
+		return nil
+	}
+
+	if foundInputs < expectedInputs {
+		// Log which specific metrics are missing
+		missingInputs := make([]string, 0)
+		for _, expectedInput := range ruleCtx.rule.inputs {
+			if _, exists := ruleCtx.inputs[expectedInput]; !exists {
+				missingInputs = append(missingInputs, expectedInput)
 			}
-			mp.logger.Warn("Some input metrics missing for inference rule",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Int("expected_count", expectedInputs),
-				zap.Int("found_count", foundInputs),
-				zap.Strings("missing_inputs", missingInputs),
-				zap.String("suggestion", "Check metric names and data pipeline configuration"))
 		}
+		mp.logLimiter.Warn(ruleIdx, "Some input metrics missing for inference rule",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Int("expected_count", expectedInputs),
+			zap.Int("found_count", foundInputs),
+			zap.Strings("missing_inputs", missingInputs),
+			zap.String("suggestion", "Check metric names and data pipeline configuration"))
+	}
 
-		// Validate inputs against model signature
-		err := mp.validateRuleInputs(mp.rules[ruleIdx], ruleCtx.inputs)
-		if err != nil {
-			mp.logger.Error("Input validation failed",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Error(err))
-			continue
-		}
+	// Validate inputs against model signature, querying the model's metadata
+	// again when it expired or keeps failing validation
+	err := mp.validateRuleInputs(mp.rules[ruleIdx], ruleCtx.inputs)
+	mp.checkMetadataFreshness(modelName, err)
+	if err != nil {
+		mp.logLimiter.Error(ruleIdx, "Input validation failed",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, err: err}
+	}
 
-		// Create inference request for this rule
-		inferRequest, err := mp.createModelInferRequest(modelName, ruleCtx.inputs, ruleCtx)
-		if err != nil {
-			mp.logger.Error("Failed to create inference request",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Error(err))
-			continue
-		}
+	// Create inference request for this rule
+	inferRequest, err := mp.createModelInferRequest(ruleCtx.inputs, ruleCtx)
+	if err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to create inference request",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, err: err}
+	}
 
-		// Set timeout for the inference request
-		timeoutDuration := 10 * time.Second
-		if mp.config.Timeout > 0 {
-			timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
-		}
+	// Mapped inputs, and discovered inputs looked up under a prefix, are sent under the model's tensor names
+	renameInputTensors(inferRequest, ruleCtx.rule.inputTensors)
 
-		// Create context with timeout
-		inferCtx, cancel := context.WithTimeout(ctx, timeoutDuration)
-		defer cancel()
+	// Add sequence controls for stateful models
+	mp.applySequenceControls(ruleIdx, inferRequest, ruleCtx.resourceMetrics.Resource().Attributes(), ruleCtx.matchedDataPoints)
 
-		// Add headers if specified
-		if len(mp.config.GRPCClientSettings.Headers) > 0 {
-			mdHeaders := metadata.New(mp.config.GRPCClientSettings.Headers)
-			inferCtx = metadata.NewOutgoingContext(inferCtx, mdHeaders)
-		}
+	// Add the resource and scope attributes the rule forwards to the model
+	if err := mp.applyForwardedAttributes(ruleIdx, inferRequest, ruleCtx); err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to forward attributes",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, err: err}
+	}
 
-		// Send request to inference server
-		inferResponse, err := client.ModelInfer(inferCtx, inferRequest)
-		if err != nil {
-			mp.logger.Error("Failed to perform inference",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Error(err))
-			continue
-		}
+	// Add the calendar features the rule declares as an extra tensor
+	mp.applyTimeFeatures(ruleIdx, inferRequest, ruleCtx)
 
-		mp.logger.Debug("Received inference response",
+	// Render the parameters templated on the request's inputs
+	if err := mp.applyParameterTemplates(ruleIdx, inferRequest, ruleCtx); err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to render parameter templates",
 			zap.String("model", modelName),
 			zap.Int("rule_index", ruleIdx),
-			zap.Int("output_count", len(inferResponse.Outputs)))
+			zap.Error(err))
+		return &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, err: err}
+	}
 
-		// Process inference response and create new metrics
-		if err := mp.processInferenceResponse(md, ruleCtx.rule, inferResponse, ruleCtx); err != nil {
-			mp.logger.Error("Failed to process inference response",
-				zap.String("model", modelName),
-				zap.Int("rule_index", ruleIdx),
-				zap.Error(err))
-		}
+	// Let the server deduplicate retries of the request
+	mp.setIdempotencyKey(inferRequest)
+
+	// Send inputs the model takes in half precision as raw contents
+	if err := mp.packHalfPrecisionInputs(ruleIdx, inferRequest); err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to pack half-precision inputs",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, err: err}
 	}
 
-	return mp.nextConsumer.ConsumeMetrics(ctx, md)
+	call := &ruleCall{ruleIdx: ruleIdx, ctx: ruleCtx, request: inferRequest}
+	if ruleCtx.rule.challenger != nil {
+		call.challenger = mp.challengerCall(call)
+	}
+	return call
 }
 
-// createModelInferRequest converts OpenTelemetry metrics to the format required by the inference server
-func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, inputs map[string]pmetric.Metric, context *modelContext) (*pb.ModelInferRequest, error) {
-	// Find the rule for this model
-	var rule *internalRule
-	for i := range mp.rules {
-		if mp.rules[i].modelName == modelName {
-			rule = &mp.rules[i]
-			break
-		}
+// applyRuleCall adds the outputs of a finished rule call to the batch, and the
+// fallback values of the outputs it did not produce when inference failed, was
+// skipped, or its request could not be built. It returns why inference failed,
+// nil when it succeeded or was never sent.
+func (mp *metricsinferenceprocessor) applyRuleCall(ctx context.Context, md pmetric.Metrics, call *ruleCall) error {
+	ruleIdx := call.ruleIdx
+	modelName := call.ctx.rule.modelName
+
+	// Calls whose request could not be built were already reported
+	if call.request == nil {
+		mp.emitFallbacks(md, call.ctx, nil)
+		return nil
+	}
+	if call.skipped != "" {
+		mp.logSkippedCall(ctx, call)
+		mp.emitFallbacks(md, call.ctx, nil)
+		mp.emitErrorMetric(md, call.ctx, call.err)
+		return call.err
+	}
+	mp.recordCallOutcome(ctx, call, call.err)
+	if call.err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to perform inference",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(call.err))
+		mp.emitFallbacks(md, call.ctx, nil)
+		mp.emitErrorMetric(md, call.ctx, call.err)
+		return call.err
 	}
+	mp.recordSequenceRequest(ruleIdx, call.request)
 
-	if rule == nil {
-		return nil, fmt.Errorf("no rule found for model '%s'", modelName)
+	// Shadow rules report their results without adding them to the batch
+	if call.ctx.rule.shadow != nil {
+		mp.applyShadowCall(ctx, md, call)
+		return nil
 	}
 
-	// Create a new inference request
-	request := &pb.ModelInferRequest{
-		ModelName:    modelName,
-		ModelVersion: rule.modelVersion,
-		Id:           strconv.FormatInt(time.Now().UnixNano(), 10), // Generate a unique ID for the request
-		Inputs:       []*pb.ModelInferRequest_InferInputTensor{},
+	mp.logger.Debug("Received inference response",
+		zap.String("model", modelName),
+		zap.Int("rule_index", ruleIdx),
+		zap.Int("output_count", len(call.response.Outputs)))
+
+	// Process inference response and create new metrics
+	sm, err := mp.outputScopeMetrics(md, call.ctx)
+	first := 0
+	var produced []bool
+	if err == nil {
+		first = sm.Metrics().Len()
+		produced, err = mp.processInferenceResponse(md, call.ctx.rule, call.response, call.ctx)
+	}
+	if err != nil {
+		mp.logLimiter.Error(ruleIdx, "Failed to process inference response",
+			zap.String("model", modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		mp.emitFallbacks(md, call.ctx, produced)
+		return err
 	}
 
-	// Add parameters from the rule if any
-	if len(rule.parameters) > 0 {
-		request.Parameters = make(map[string]*pb.InferParameter)
-		for k, v := range rule.parameters {
-			param := &pb.InferParameter{}
-
-			switch val := v.(type) {
-			case bool:
-				param.ParameterChoice = &pb.InferParameter_BoolParam{BoolParam: val}
-			case int:
-				param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: int64(val)}
-			case int64:
-				param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: val}
-			case float64:
-				// Convert to string as there's no float parameter type
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%f", val)}
-			case string:
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: val}
-			default:
-				// Convert anything else to string
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%v", val)}
-			}
+	if call.ctx.rule.recordLatency != "" {
+		recordLatency(sm, first, call)
+	}
+
+	// Compare the challenger's results with the outputs just added
+	if call.challenger != nil {
+		mp.compareChallenger(ctx, md, call, sm, first)
+	}
+	mp.recordOutputSeries(md, call.ctx, sm, first)
+
+	// Rules whose inputs span scopes may add their outputs to every input's scope
+	duplicateOutputs(call.ctx, sm, first)
+
+	// Outputs missing from a partial response fall back on their own
+	mp.emitFallbacks(md, call.ctx, produced)
+	return nil
+}
+
+// deriveSelectorInput applies derived-input functions of a selector (such as histogram
+// percentiles) to a matched metric. It returns false if the metric cannot be used.
+func (mp *metricsinferenceprocessor) deriveSelectorInput(metric pmetric.Metric, selector *labelSelector, ruleIdx int) (pmetric.Metric, bool) {
+	if selector.percentile == 0 {
+		return metric, true
+	}
 
-			request.Parameters[k] = param
+	derived, err := histogramPercentileMetric(metric, selector.percentile)
+	if err != nil {
+		mp.logLimiter.Warn(ruleIdx, "Failed to derive percentile input",
+			zap.String("metric", metric.Name()),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return pmetric.Metric{}, false
+	}
+	return derived, true
+}
+
+// transformInput applies the delta, rate, aggregation and scaling transforms
+// configured for an input, recording exposed scaling statistics on the rule
+// context. It returns false if the metric cannot be used or no series has a
+// previous value yet.
+func (mp *metricsinferenceprocessor) transformInput(ruleCtx *modelContext, metric pmetric.Metric, resource pmetric.ResourceMetrics, inputName string) (pmetric.Metric, bool) {
+	ruleIdx := ruleCtx.ruleIndex
+	transforms := mp.rules[ruleIdx].transforms
+	if ruleCtx.expansion != nil {
+		transforms = ruleCtx.expansion.transforms
+	}
+	transform := transforms[inputName]
+	if transform == nil {
+		return metric, true
+	}
+	transformed, scaling, err := transform.apply(metric, resource.Resource().Attributes())
+	if err != nil {
+		mp.logLimiter.Warn(ruleIdx, "Failed to transform input",
+			zap.String("metric", metric.Name()),
+			zap.Int("rule_index", ruleIdx),
+			zap.Error(err))
+		return pmetric.Metric{}, false
+	}
+	// Series seen for the first time have no previous value to compare against yet
+	if transformed.Gauge().DataPoints().Len() == 0 {
+		return pmetric.Metric{}, false
+	}
+	if transform.exposeScaling && scaling != nil {
+		if ruleCtx.scaling == nil {
+			ruleCtx.scaling = make(map[string]scalingParameters)
 		}
+		ruleCtx.scaling[inputName] = scaling
 	}
+	return transformed, true
+}
+
+// createModelInferRequest converts OpenTelemetry metrics to the format required by the inference server,
+// following the settings of the rule being run, which other rules of the same model do not share
+func (mp *metricsinferenceprocessor) createModelInferRequest(inputs map[string]pmetric.Metric, context *modelContext) (*pb.ModelInferRequest, error) {
+	rule := &context.rule
+
+	// Create a new inference request from the pool, with a buffer per input
+	request := newInferRequest(len(rule.inputs))
+	request.ModelName = rule.modelName
+	request.ModelVersion = rule.modelVersion
+	request.Id = requestID(context)
+	settings := mp.requestEncoderSettings(context)
+
+	// Add parameters from the rule if any
+	request.Parameters = newInferParameters(rule.parameters)
 
 	// Handle temporal alignment if enabled
 	if mp.config.DataHandling.AlignTimestamps && mp.config.DataHandling.Mode != "all" {
@@ -765,17 +1251,42 @@ func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, i
 			return nil, fmt.Errorf("failed to align data points: %w", err)
 		}
 
+		// Multivariate models take the windows of all inputs as one 2D tensor
+		if mp.combinesInputWindows(*rule) {
+			if len(alignedDataPoints) == 0 {
+				return nil, fmt.Errorf("no aligned data points found for the input window")
+			}
+			name := mp.config.DataHandling.WindowTensorName
+			if name == "" {
+				name = defaultWindowTensorName
+			}
+			tensor, err := windowTensor(name, rule.inputs, alignedDataPoints, mp.config.DataHandling.WindowLayout)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the input window: %w", err)
+			}
+			request.Inputs = append(request.Inputs, tensor)
+			return request, nil
+		}
+
 		// Create tensors from aligned data points, applying data handling mode
 		for _, inputName := range rule.inputs {
+			if metric, exists := inputs[inputName]; exists {
+				if tensor, ok, err := mp.encodeRuleInput(rule, inputName, metric, settings); ok {
+					if err != nil {
+						return nil, fmt.Errorf("failed to encode metric '%s': %w", inputName, err)
+					}
+					request.Inputs = append(request.Inputs, tensor)
+					continue
+				}
+			}
 			if dataPoints, exists := alignedDataPoints[inputName]; exists && len(dataPoints) > 0 {
-				contents := &pb.InferTensorContents{}
-				var selectedDataPoints []pmetric.NumberDataPoint
+				var selectedDataPoints []dataPoint
 
 				// Apply data handling mode to the aligned data points
 				switch mp.config.DataHandling.Mode {
 				case "latest", "":
 					// Take only the last data point
-					selectedDataPoints = []pmetric.NumberDataPoint{dataPoints[len(dataPoints)-1]}
+					selectedDataPoints = []dataPoint{dataPoints[len(dataPoints)-1]}
 				case "window":
 					// Take the last N data points
 					windowSize := mp.config.DataHandling.WindowSize
@@ -789,21 +1300,10 @@ func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, i
 					selectedDataPoints = dataPoints[startIdx:]
 				}
 
-				// Convert selected data points to tensor contents
-				for _, dp := range selectedDataPoints {
-					switch dp.ValueType() {
-					case pmetric.NumberDataPointValueTypeInt:
-						contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.IntValue()))
-					case pmetric.NumberDataPointValueTypeDouble:
-						contents.Fp64Contents = append(contents.Fp64Contents, dp.DoubleValue())
-					}
-				}
-
-				tensor := &pb.ModelInferRequest_InferInputTensor{
-					Name:     inputName,
-					Datatype: "FP64",
-					Shape:    []int64{int64(len(selectedDataPoints))},
-					Contents: contents,
+				// Convert selected data points to a tensor
+				tensor, err := dataPointsToTensor(inputName, selectedDataPoints, settings)
+				if err != nil {
+					return nil, fmt.Errorf("failed to convert metric '%s' to tensor: %w", inputName, err)
 				}
 				request.Inputs = append(request.Inputs, tensor)
 			} else {
@@ -831,7 +1331,10 @@ func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, i
 		if skipAttributeMatching || mp.config.DataHandling.Mode == "all" {
 			// Single input without discriminating attributes or "all" mode - pass through all data points
 			for name, metric := range inputs {
-				tensor, err := mp.metricToInferInputTensor(name, metric)
+				tensor, ok, err := mp.encodeRuleInput(rule, name, metric, settings)
+				if !ok {
+					tensor, err = mp.metricToInferInputTensor(name, metric, settings)
+				}
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert metric '%s' to tensor: %w", name, err)
 				}
@@ -841,12 +1344,15 @@ func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, i
 			// Multiple inputs - use attribute matching for cross-metric alignment
 			// Build matched data point groups for attribute preservation
 			if context != nil {
-				context.matchedDataPoints = matchDataPointsByAttributes(inputs, *rule)
+				context.matchedDataPoints = mp.matchDataPoints(context, inputs, *rule)
 			}
 
 			// Add each metric as an input tensor using only matched data points
 			for name, metric := range inputs {
-				tensor, err := mp.metricToInferInputTensorWithMatching(name, metric, context)
+				tensor, ok, err := mp.encodeRuleInput(rule, name, metric, settings)
+				if !ok {
+					tensor, err = mp.metricToInferInputTensorWithMatching(name, metric, context, settings)
+				}
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert metric '%s' to tensor: %w", name, err)
 				}
@@ -855,6 +1361,12 @@ func (mp *metricsinferenceprocessor) createModelInferRequest(modelName string, i
 		}
 	}
 
+	if rule.combine != nil {
+		if err := rule.combine.apply(request, rule.inputs); err != nil {
+			return nil, fmt.Errorf("failed to combine inputs: %w", err)
+		}
+	}
+
 	return request, nil
 }
 
@@ -899,13 +1411,15 @@ func attributeSetsEqual(a, b pcommon.Map) bool {
 }
 
 // matchDataPointsByAttributes groups data points by attribute sets and finds matches across inputs
+// using broadcast semantics. It rebuilds all keys on every call; the processor uses the incremental
+// attributeGroupIndex, which produces identical groups.
 func matchDataPointsByAttributes(inputs map[string]pmetric.Metric, rule internalRule) []dataPointGroup {
 	// Step 1: Group data points by attribute sets for each input metric
-	inputGroups := make(map[string]map[string][]pmetric.NumberDataPoint) // metric name -> attribute key -> data points
+	inputGroups := make(map[string]map[string][]dataPoint) // metric name -> attribute key -> data points
 
 	for _, inputName := range rule.inputs {
 		if metric, exists := inputs[inputName]; exists {
-			inputGroups[inputName] = make(map[string][]pmetric.NumberDataPoint)
+			inputGroups[inputName] = make(map[string][]dataPoint)
 			dataPoints := extractDataPoints(metric)
 
 			for _, dp := range dataPoints {
@@ -918,8 +1432,8 @@ func matchDataPointsByAttributes(inputs map[string]pmetric.Metric, rule internal
 	// Step 2: Identify inputs for broadcast semantics
 	// An input is a broadcast candidate if it has only one data point group
 	// regardless of whether it has attributes or not
-	inputsWithMultipleGroups := make(map[string]map[string][]pmetric.NumberDataPoint)
-	inputsWithSingleGroup := make(map[string]pmetric.NumberDataPoint)
+	inputsWithMultipleGroups := make(map[string]map[string][]dataPoint)
+	inputsWithSingleGroup := make(map[string]dataPoint)
 
 	for inputName, groups := range inputGroups {
 		if len(groups) == 1 {
@@ -966,8 +1480,10 @@ func matchDataPointsByAttributes(inputs map[string]pmetric.Metric, rule internal
 			}
 		}
 
-		// If no common attribute sets, use all unique attribute sets
-		if len(targetAttrKeys) == 0 {
+		// If no common attribute sets, or groups lacking inputs are filled, use all
+		// unique attribute sets
+		if len(targetAttrKeys) == 0 || rule.fillsPartialGroups() {
+			targetAttrKeys = targetAttrKeys[:0]
 			for attrKey := range allAttrKeysSet {
 				targetAttrKeys = append(targetAttrKeys, attrKey)
 			}
@@ -982,7 +1498,7 @@ func matchDataPointsByAttributes(inputs map[string]pmetric.Metric, rule internal
 	for _, attrKey := range targetAttrKeys {
 		group := dataPointGroup{
 			attributes: pcommon.NewMap(),
-			dataPoints: make(map[string]pmetric.NumberDataPoint),
+			dataPoints: make(map[string]dataPoint),
 		}
 
 		// Add data points from inputs with multiple groups (discriminating attributes)
@@ -1008,8 +1524,8 @@ func matchDataPointsByAttributes(inputs map[string]pmetric.Metric, rule internal
 			}
 		}
 
-		// Only add group if we have data points for all inputs
-		if len(group.dataPoints) == len(rule.inputs) {
+		// Only add group if we have data points for all inputs, or they were filled
+		if fillPartialGroup(group, group.attributes, inputs, rule) {
 			matchedGroups = append(matchedGroups, group)
 		}
 	}
@@ -1023,34 +1539,12 @@ func (mp *metricsinferenceprocessor) createInferRequestForGroup(modelName string
 	request := &pb.ModelInferRequest{
 		ModelName:    modelName,
 		ModelVersion: rule.modelVersion,
-		Id:           strconv.FormatInt(time.Now().UnixNano(), 10),
+		Id:           requestID(&modelContext{rule: rule, matchedDataPoints: []dataPointGroup{group}}),
 		Inputs:       []*pb.ModelInferRequest_InferInputTensor{},
 	}
 
 	// Add parameters from the rule if any
-	if len(rule.parameters) > 0 {
-		request.Parameters = make(map[string]*pb.InferParameter)
-		for k, v := range rule.parameters {
-			param := &pb.InferParameter{}
-
-			switch val := v.(type) {
-			case int:
-				param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: int64(val)}
-			case int64:
-				param.ParameterChoice = &pb.InferParameter_Int64Param{Int64Param: val}
-			case float32:
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%f", val)}
-			case float64:
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%f", val)}
-			case string:
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: val}
-			default:
-				param.ParameterChoice = &pb.InferParameter_StringParam{StringParam: fmt.Sprintf("%v", val)}
-			}
-
-			request.Parameters[k] = param
-		}
-	}
+	request.Parameters = newInferParameters(rule.parameters)
 
 	// Create tensors from the matched data points
 	for _, inputName := range rule.inputs {
@@ -1067,32 +1561,15 @@ func (mp *metricsinferenceprocessor) createInferRequestForGroup(modelName string
 }
 
 // dataPointToTensor converts a single data point to an inference tensor
-func (mp *metricsinferenceprocessor) dataPointToTensor(name string, dp pmetric.NumberDataPoint) (*pb.ModelInferRequest_InferInputTensor, error) {
-	contents := &pb.InferTensorContents{}
-
-	// Extract value from data point
-	switch dp.ValueType() {
-	case pmetric.NumberDataPointValueTypeInt:
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.IntValue()))
-	case pmetric.NumberDataPointValueTypeDouble:
-		contents.Fp64Contents = append(contents.Fp64Contents, dp.DoubleValue())
-	default:
-		return nil, fmt.Errorf("unsupported data point value type: %v", dp.ValueType())
-	}
-
-	return &pb.ModelInferRequest_InferInputTensor{
-		Name:     name,
-		Datatype: "FP64",
-		Shape:    []int64{1}, // Single value tensor
-		Contents: contents,
-	}, nil
+func (mp *metricsinferenceprocessor) dataPointToTensor(name string, dp dataPoint) (*pb.ModelInferRequest_InferInputTensor, error) {
+	return dataPointsToTensor(name, []dataPoint{dp}, mp.encoderSettings())
 }
 
 // alignDataPointsByTimestamp aligns data points from multiple metrics based on their timestamps
-func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[string]pmetric.Metric) (map[string][]pmetric.NumberDataPoint, error) {
+func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[string]pmetric.Metric) (map[string][]dataPoint, error) {
 	if !mp.config.DataHandling.AlignTimestamps {
 		// No alignment requested, return all data points
-		result := make(map[string][]pmetric.NumberDataPoint)
+		result := make(map[string][]dataPoint)
 		for name, metric := range inputs {
 			result[name] = extractDataPoints(metric)
 		}
@@ -1106,12 +1583,14 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 	type timestampedDataPoint struct {
 		timestamp uint64
 		name      string
-		dataPoint pmetric.NumberDataPoint
+		dataPoint dataPoint
 	}
 
 	var allDataPoints []timestampedDataPoint
+	series := make(map[string][]dataPoint, len(inputs))
 	for name, metric := range inputs {
 		dataPoints := extractDataPoints(metric)
+		series[name] = dataPoints
 		for _, dp := range dataPoints {
 			allDataPoints = append(allDataPoints, timestampedDataPoint{
 				timestamp: uint64(dp.Timestamp()),
@@ -1125,9 +1604,14 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 	sort.Slice(allDataPoints, func(i, j int) bool {
 		return allDataPoints[i].timestamp < allDataPoints[j].timestamp
 	})
+	for _, dataPoints := range series {
+		sort.SliceStable(dataPoints, func(i, j int) bool {
+			return dataPoints[i].Timestamp() < dataPoints[j].Timestamp()
+		})
+	}
 
 	// Group data points by timestamp (within tolerance)
-	alignedGroups := make(map[uint64]map[string]pmetric.NumberDataPoint)
+	alignedGroups := make(map[uint64]map[string]dataPoint)
 
 	for _, tdp := range allDataPoints {
 		// Find a group within tolerance
@@ -1143,7 +1627,7 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 
 		if !found {
 			groupTimestamp = tdp.timestamp
-			alignedGroups[groupTimestamp] = make(map[string]pmetric.NumberDataPoint)
+			alignedGroups[groupTimestamp] = make(map[string]dataPoint)
 		}
 
 		// Add data point to group (keep the latest if multiple for same metric)
@@ -1151,7 +1635,7 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 	}
 
 	// Find complete groups (having all required inputs)
-	result := make(map[string][]pmetric.NumberDataPoint)
+	result := make(map[string][]dataPoint)
 	requiredInputs := len(inputs)
 
 	// Sort timestamps to process in order
@@ -1164,10 +1648,10 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 	})
 
 	// Apply data handling mode to aligned groups
-	var validGroups []map[string]pmetric.NumberDataPoint
+	var validGroups []map[string]dataPoint
 	for _, ts := range timestamps {
 		group := alignedGroups[ts]
-		if len(group) == requiredInputs {
+		if len(group) == requiredInputs || fillAlignedGroup(group, ts, series, mp.config.DataHandling.Fill) {
 			validGroups = append(validGroups, group)
 		}
 	}
@@ -1179,7 +1663,7 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 			// Take only the last complete group
 			lastGroup := validGroups[len(validGroups)-1]
 			for name, dp := range lastGroup {
-				result[name] = []pmetric.NumberDataPoint{dp}
+				result[name] = []dataPoint{dp}
 			}
 		}
 	case "window":
@@ -1208,59 +1692,52 @@ func (mp *metricsinferenceprocessor) alignDataPointsByTimestamp(inputs map[strin
 }
 
 // metricToInferInputTensorWithMatching converts a metric to tensor using only matched data points
-func (mp *metricsinferenceprocessor) metricToInferInputTensorWithMatching(name string, metric pmetric.Metric, context *modelContext) (*pb.ModelInferRequest_InferInputTensor, error) {
+func (mp *metricsinferenceprocessor) metricToInferInputTensorWithMatching(name string, metric pmetric.Metric, context *modelContext, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if context == nil || len(context.matchedDataPoints) == 0 {
 		// Fallback to processing all data points
-		return mp.metricToInferInputTensor(name, metric)
+		return mp.metricToInferInputTensor(name, metric, settings)
 	}
 
 	// Extract only the data points that are in matched groups for this metric
-	contents := &pb.InferTensorContents{}
-
+	dataPoints := make([]dataPoint, 0, len(context.matchedDataPoints))
 	for _, group := range context.matchedDataPoints {
 		if dataPoint, exists := group.dataPoints[name]; exists {
-			switch dataPoint.ValueType() {
-			case pmetric.NumberDataPointValueTypeInt:
-				contents.Fp64Contents = append(contents.Fp64Contents, float64(dataPoint.IntValue()))
-			case pmetric.NumberDataPointValueTypeDouble:
-				contents.Fp64Contents = append(contents.Fp64Contents, dataPoint.DoubleValue())
-			}
+			dataPoints = append(dataPoints, dataPoint)
 		}
 	}
 
-	if len(contents.Fp64Contents) == 0 {
+	if len(dataPoints) == 0 {
 		return nil, fmt.Errorf("no matched data points found for metric '%s'", name)
 	}
 
-	return &pb.ModelInferRequest_InferInputTensor{
-		Name:     name,
-		Datatype: "FP64",
-		Shape:    []int64{int64(len(contents.Fp64Contents))},
-		Contents: contents,
-	}, nil
+	return dataPointsToTensor(name, dataPoints, settings)
 }
 
 // metricToInferInputTensor converts a single OpenTelemetry metric to an inference input tensor
-func (mp *metricsinferenceprocessor) metricToInferInputTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
-	// Create a tensor based on the metric type
-	switch metric.Type() {
-	case pmetric.MetricTypeGauge:
-		return mp.gaugeToTensor(name, metric)
-	case pmetric.MetricTypeSum:
-		return mp.sumToTensor(name, metric)
-	case pmetric.MetricTypeHistogram:
-		return mp.histogramToTensor(name, metric)
-	case pmetric.MetricTypeSummary:
-		return mp.summaryToTensor(name, metric)
-	case pmetric.MetricTypeExponentialHistogram:
-		return mp.exponentialHistogramToTensor(name, metric)
-	default:
+// with the builtin encoder for its type
+func (mp *metricsinferenceprocessor) metricToInferInputTensor(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
+	encoder, ok := lookupTensorEncoder(builtinEncoderName(metric.Type()))
+	if !ok {
 		return nil, fmt.Errorf("unsupported metric type: %s", metric.Type().String())
 	}
+	return encoder(name, metric, settings)
 }
 
-// gaugeToTensor converts a gauge metric to an inference tensor
-func (mp *metricsinferenceprocessor) gaugeToTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
+// numberTensorLength returns the number of values the gauge and sum encoders
+// take from a metric with n data points in a data handling mode
+func numberTensorLength(n int, dataHandling DataHandlingConfig) int {
+	switch dataHandling.Mode {
+	case "latest", "":
+		return 1
+	case "window":
+		return min(n, max(dataHandling.WindowSize, 1))
+	default:
+		return n
+	}
+}
+
+// encodeGauge converts a gauge metric to an inference tensor
+func encodeGauge(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if metric.Type() != pmetric.MetricTypeGauge {
 		return nil, fmt.Errorf("expected gauge metric, got %s", metric.Type().String())
 	}
@@ -1270,11 +1747,11 @@ func (mp *metricsinferenceprocessor) gaugeToTensor(name string, metric pmetric.M
 		return nil, fmt.Errorf("no data points in gauge metric")
 	}
 
-	contents := &pb.InferTensorContents{}
+	contents := settings.fp64Contents(numberTensorLength(dps.Len(), settings.DataHandling))
 	var shape []int64
 
 	// Apply data handling mode
-	switch mp.config.DataHandling.Mode {
+	switch settings.DataHandling.Mode {
 	case "latest", "":
 		// Default to latest mode - send only the most recent data point
 		dp := dps.At(dps.Len() - 1) // Get the last data point
@@ -1288,7 +1765,7 @@ func (mp *metricsinferenceprocessor) gaugeToTensor(name string, metric pmetric.M
 
 	case "window":
 		// Send the last N data points
-		windowSize := mp.config.DataHandling.WindowSize
+		windowSize := settings.DataHandling.WindowSize
 		if windowSize <= 0 {
 			windowSize = 1
 		}
@@ -1331,8 +1808,8 @@ func (mp *metricsinferenceprocessor) gaugeToTensor(name string, metric pmetric.M
 	}, nil
 }
 
-// sumToTensor converts a sum metric to an inference tensor
-func (mp *metricsinferenceprocessor) sumToTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
+// encodeSum converts a sum metric to an inference tensor
+func encodeSum(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if metric.Type() != pmetric.MetricTypeSum {
 		return nil, fmt.Errorf("expected sum metric, got %s", metric.Type().String())
 	}
@@ -1342,11 +1819,11 @@ func (mp *metricsinferenceprocessor) sumToTensor(name string, metric pmetric.Met
 		return nil, fmt.Errorf("no data points in sum metric")
 	}
 
-	contents := &pb.InferTensorContents{}
+	contents := settings.fp64Contents(numberTensorLength(dps.Len(), settings.DataHandling))
 	var shape []int64
 
 	// Apply data handling mode
-	switch mp.config.DataHandling.Mode {
+	switch settings.DataHandling.Mode {
 	case "latest", "":
 		// Default to latest mode - send only the most recent data point
 		dp := dps.At(dps.Len() - 1) // Get the last data point
@@ -1360,7 +1837,7 @@ func (mp *metricsinferenceprocessor) sumToTensor(name string, metric pmetric.Met
 
 	case "window":
 		// Send the last N data points
-		windowSize := mp.config.DataHandling.WindowSize
+		windowSize := settings.DataHandling.WindowSize
 		if windowSize <= 0 {
 			windowSize = 1
 		}
@@ -1403,8 +1880,8 @@ func (mp *metricsinferenceprocessor) sumToTensor(name string, metric pmetric.Met
 	}, nil
 }
 
-// histogramToTensor converts a histogram metric to an inference tensor
-func (mp *metricsinferenceprocessor) histogramToTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
+// encodeHistogram converts a histogram metric to an inference tensor
+func encodeHistogram(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if metric.Type() != pmetric.MetricTypeHistogram {
 		return nil, fmt.Errorf("expected histogram metric, got %s", metric.Type().String())
 	}
@@ -1426,16 +1903,7 @@ func (mp *metricsinferenceprocessor) histogramToTensor(name string, metric pmetr
 
 	// Extract values from data points
 	for i := 0; i < dps.Len(); i++ {
-		dp := dps.At(i)
-
-		// Add count and sum
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Count()))
-		contents.Fp64Contents = append(contents.Fp64Contents, dp.Sum())
-
-		// Add bucket counts
-		for j := 0; j < dp.BucketCounts().Len(); j++ {
-			contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.BucketCounts().At(j)))
-		}
+		contents.Fp64Contents = append(contents.Fp64Contents, histogramDataPointValues(dps.At(i))...)
 	}
 
 	return &pb.ModelInferRequest_InferInputTensor{
@@ -1446,8 +1914,8 @@ func (mp *metricsinferenceprocessor) histogramToTensor(name string, metric pmetr
 	}, nil
 }
 
-// summaryToTensor converts a summary metric to an inference tensor
-func (mp *metricsinferenceprocessor) summaryToTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
+// encodeSummary converts a summary metric to an inference tensor
+func encodeSummary(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if metric.Type() != pmetric.MetricTypeSummary {
 		return nil, fmt.Errorf("expected summary metric, got %s", metric.Type().String())
 	}
@@ -1469,18 +1937,7 @@ func (mp *metricsinferenceprocessor) summaryToTensor(name string, metric pmetric
 
 	// Extract values from data points
 	for i := 0; i < dps.Len(); i++ {
-		dp := dps.At(i)
-
-		// Add count and sum
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Count()))
-		contents.Fp64Contents = append(contents.Fp64Contents, dp.Sum())
-
-		// Add quantile values
-		for j := 0; j < dp.QuantileValues().Len(); j++ {
-			qv := dp.QuantileValues().At(j)
-			contents.Fp64Contents = append(contents.Fp64Contents, qv.Quantile())
-			contents.Fp64Contents = append(contents.Fp64Contents, qv.Value())
-		}
+		contents.Fp64Contents = append(contents.Fp64Contents, summaryDataPointValues(dps.At(i))...)
 	}
 
 	return &pb.ModelInferRequest_InferInputTensor{
@@ -1491,13 +1948,28 @@ func (mp *metricsinferenceprocessor) summaryToTensor(name string, metric pmetric
 	}, nil
 }
 
-// exponentialHistogramToTensor converts an exponential histogram metric to an inference tensor
-func (mp *metricsinferenceprocessor) exponentialHistogramToTensor(name string, metric pmetric.Metric) (*pb.ModelInferRequest_InferInputTensor, error) {
+// encodeExponentialHistogram converts an exponential histogram metric to an inference tensor
+func encodeExponentialHistogram(name string, metric pmetric.Metric, settings EncoderSettings) (*pb.ModelInferRequest_InferInputTensor, error) {
 	if metric.Type() != pmetric.MetricTypeExponentialHistogram {
 		return nil, fmt.Errorf("expected exponential histogram metric, got %s", metric.Type().String())
 	}
 
 	dps := metric.ExponentialHistogram().DataPoints()
+
+	// With a fixed layout, every data point becomes a row of the same length
+	if layout := settings.DataHandling.ExponentialHistogram; layout.Buckets > 0 {
+		contents := &pb.InferTensorContents{}
+		for i := 0; i < dps.Len(); i++ {
+			contents.Fp64Contents = append(contents.Fp64Contents, exponentialHistogramFeatures(dps.At(i), layout)...)
+		}
+		return &pb.ModelInferRequest_InferInputTensor{
+			Name:     name,
+			Datatype: "FP64",
+			Shape:    []int64{int64(dps.Len()), int64(exponentialHistogramFeatureLength(layout))},
+			Contents: contents,
+		}, nil
+	}
+
 	// For exponential histograms, we'll create a tensor with the following structure:
 	// [dp1_count, dp1_sum, dp1_scale, dp1_zero_count, dp1_pos_offset, dp1_pos_bucket1, ..., dp1_neg_offset, dp1_neg_bucket1, ...]
 
@@ -1516,25 +1988,7 @@ func (mp *metricsinferenceprocessor) exponentialHistogramToTensor(name string, m
 
 	// Extract values from data points
 	for i := 0; i < dps.Len(); i++ {
-		dp := dps.At(i)
-
-		// Add count, sum, scale, and zero count
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Count()))
-		contents.Fp64Contents = append(contents.Fp64Contents, dp.Sum())
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Scale()))
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.ZeroCount()))
-
-		// Add positive buckets
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Positive().Offset()))
-		for j := 0; j < dp.Positive().BucketCounts().Len(); j++ {
-			contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Positive().BucketCounts().At(j)))
-		}
-
-		// Add negative buckets
-		contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Negative().Offset()))
-		for j := 0; j < dp.Negative().BucketCounts().Len(); j++ {
-			contents.Fp64Contents = append(contents.Fp64Contents, float64(dp.Negative().BucketCounts().At(j)))
-		}
+		contents.Fp64Contents = append(contents.Fp64Contents, exponentialHistogramDataPointValues(dps.At(i))...)
 	}
 
 	return &pb.ModelInferRequest_InferInputTensor{
@@ -1545,47 +1999,50 @@ func (mp *metricsinferenceprocessor) exponentialHistogramToTensor(name string, m
 	}, nil
 }
 
-// processInferenceResponse processes the inference response and creates new metrics
-func (mp *metricsinferenceprocessor) processInferenceResponse(md pmetric.Metrics, rule internalRule, response *pb.ModelInferResponse, context *modelContext) error {
-	if len(response.Outputs) == 0 {
-		return fmt.Errorf("inference response contains no outputs")
+// findOutputTensor returns the output tensor with the given name, or nil when the response has none
+func findOutputTensor(outputs []*pb.ModelInferResponse_InferOutputTensor, name string) *pb.ModelInferResponse_InferOutputTensor {
+	for _, output := range outputs {
+		if output.Name == name {
+			return output
+		}
 	}
+	return nil
+}
 
-	// Use the ResourceMetrics and ScopeMetrics from the input context
-	var rm pmetric.ResourceMetrics
-	var sm pmetric.ScopeMetrics
+// processInferenceResponse processes the inference response and creates new metrics.
+// It returns which of the rule's outputs were produced.
+func (mp *metricsinferenceprocessor) processInferenceResponse(md pmetric.Metrics, rule internalRule, response *pb.ModelInferResponse, context *modelContext) ([]bool, error) {
+	if len(response.Outputs) == 0 {
+		return nil, fmt.Errorf("inference response contains no outputs")
+	}
 
-	if context.hasContext {
-		// Use the ResourceMetrics from the input context
-		rm = context.resourceMetrics
-		sm = context.scopeMetrics
-	} else {
-		// Fallback to the first ResourceMetrics if no context available
-		if md.ResourceMetrics().Len() == 0 {
-			return fmt.Errorf("no resource metrics available to add inference results")
-		}
-		rm = md.ResourceMetrics().At(0)
-		if rm.ScopeMetrics().Len() == 0 {
-			// Create a new scope for inference results if none exists
-			sm = rm.ScopeMetrics().AppendEmpty()
-			sm.Scope().SetName("opentelemetry.inference")
-			sm.Scope().SetVersion("1.0.0")
-		} else {
-			sm = rm.ScopeMetrics().At(0)
-		}
+	sm, err := mp.outputScopeMetrics(md, context)
+	if err != nil {
+		return nil, err
 	}
+	produced := make([]bool, len(rule.outputs))
+	firstOutput := sm.Metrics().Len()
+	start := mp.outputStartTimestamp(context)
 
 	// Process each configured output specification
 	for outputIdx, outputSpec := range rule.outputs {
 		// Determine which output tensor to use
 		var outputTensor *pb.ModelInferResponse_InferOutputTensor
 
-		if outputSpec.outputIndex != nil {
+		if outputSpec.tensorName != "" {
+			// Use the output tensor with the specified name
+			if outputTensor = findOutputTensor(response.Outputs, outputSpec.tensorName); outputTensor == nil {
+				mp.logLimiter.Warn(context.ruleIndex, "Specified output tensor not found in response",
+					zap.String("tensor_name", outputSpec.tensorName),
+					zap.Int("available_outputs", len(response.Outputs)))
+				continue
+			}
+		} else if outputSpec.outputIndex != nil {
 			// Use the specified output index
 			if *outputSpec.outputIndex >= 0 && *outputSpec.outputIndex < len(response.Outputs) {
 				outputTensor = response.Outputs[*outputSpec.outputIndex]
 			} else {
-				mp.logger.Warn("Specified output index out of range",
+				mp.logLimiter.Warn(context.ruleIndex, "Specified output index out of range",
 					zap.Int("index", *outputSpec.outputIndex),
 					zap.Int("available_outputs", len(response.Outputs)))
 				continue
@@ -1602,57 +2059,33 @@ func (mp *metricsinferenceprocessor) processInferenceResponse(md pmetric.Metrics
 		}
 
 		// Create a new metric for this output
+		firstMetric := sm.Metrics().Len()
 		metric := sm.Metrics().AppendEmpty()
 
 		// Set metric name
-		metricName := outputSpec.name
-		if metricName == "" {
-			// Use tensor name if available, otherwise generate one
-			if outputTensor.Name != "" {
-				metricName = outputTensor.Name
-			} else {
-				metricName = fmt.Sprintf("%s_output_%d", rule.modelName, outputIdx)
-			}
-		}
-
-		// Apply naming strategy: output pattern if exists, otherwise intelligent naming
-		if !outputSpec.discovered {
-			// For explicitly configured outputs, apply naming strategy
-			if rule.outputPattern != "" {
-				// Use output pattern
-				evaluator := NewPatternEvaluator(rule.outputPattern, &rule)
-				decoratedName, err := evaluator.Evaluate(metricName)
-				if err != nil {
-					mp.logger.Warn("Failed to evaluate output pattern, falling back to intelligent naming",
-						zap.String("pattern", rule.outputPattern),
-						zap.Error(err))
-					metricName = mp.defaultDecorateOutputName(&rule, metricName, outputIdx)
-				} else {
-					metricName = decoratedName
-				}
-			} else {
-				// No output pattern, use intelligent naming
-				metricName = mp.defaultDecorateOutputName(&rule, metricName, outputIdx)
-			}
-		}
-		// For discovered outputs, intelligent naming was already applied in mergeDiscoveredOutputs
+		metricName := mp.outputMetricName(&rule, outputIdx, outputSpec, outputTensor.Name)
 
 		metric.SetName(metricName)
 
 		// Set description and unit
-		description := outputSpec.description
-		if description == "" {
-			description = fmt.Sprintf("Inference result from model %s", rule.modelName)
+		metric.SetDescription(mp.outputDescription(&rule, outputIdx, outputSpec, outputTensor.Name))
+		unit := outputSpec.unit
+		if unit == "" {
+			// Fall back to the unit produced by a convert transform, if any
+			unit = postTransformsUnit(outputSpec.post)
+		}
+		if unit == "" {
+			// Then to the unit the model publishes
+			unit = outputSpec.published.unit
 		}
-		metric.SetDescription(description)
-		metric.SetUnit(outputSpec.unit)
+		metric.SetUnit(unit)
 
 		// Determine the data type of the output
 		outputType := outputSpec.dataType
 		if outputType == "" {
 			// Try to infer from the output datatype
 			switch outputTensor.Datatype {
-			case "FP32", "FP64":
+			case "FP16", "BF16", "FP32", "FP64":
 				outputType = "float"
 			case "INT8", "INT16", "INT32", "INT64":
 				outputType = "int"
@@ -1665,24 +2098,189 @@ func (mp *metricsinferenceprocessor) processInferenceResponse(md pmetric.Metrics
 			}
 		}
 
-		// Create the appropriate metric type based on the output data type
-		err := mp.processOutputTensor(metric, outputTensor, outputType, rule.modelName, metricName, context)
+		// Create the appropriate metric type based on the output data type.
+		// Tensors with several columns per row are decoded by shape, and
+		// vectors of named elements by the number of names.
+		rows, cols, shaped := tensorMatrixShape(outputTensor.Shape)
+		if outputSpec.namedElements {
+			rows, cols, shaped = elementMatrixShape(outputTensor.Shape, len(outputSpec.columnNames))
+		}
+		switch {
+		case outputSpec.histogram != nil:
+			err = mp.processHistogramOutput(metric, outputTensor, response, outputSpec, context)
+		case outputSpec.embedding != nil:
+			err = mp.processEmbeddingOutput(metric, outputTensor, outputSpec, context)
+		case shaped && outputType != "string":
+			err = mp.processShapedOutputTensor(sm, metric, outputTensor, outputType, metricName, outputSpec, rows, cols, context)
+		default:
+			err = mp.processOutputTensor(metric, outputTensor, outputType, rule.modelName, metricName, outputSpec.post, context)
+		}
 		if err != nil {
-			mp.logger.Error("Failed to process output tensor",
+			mp.logLimiter.Error(context.ruleIndex, "Failed to process output tensor",
 				zap.String("model", rule.modelName),
 				zap.String("output_name", metricName),
 				zap.Error(err))
+			// Drop the metric created for this output so no empty metric is exported
+			sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				return m.Type() == pmetric.MetricTypeEmpty && m.Name() == metricName
+			})
 			continue
 		}
+		produced[outputIdx] = true
+		mp.sanitizeOutputs(sm.Metrics(), firstMetric, context.ruleIndex, rule.modelName)
+		mp.limitOutputs(sm.Metrics(), firstMetric, outputResource(md, context), context)
+		setStartTimestamps(sm.Metrics(), firstMetric, start)
+		if outputSpec.cumulative {
+			mp.cumulative.accumulate(context.ruleIndex, outputIdx, outputResource(md, context), sm.Metrics(), firstMetric,
+				outputSpec.published.metricType == metricTypeCounter)
+		} else {
+			applyMetricType(sm.Metrics(), firstMetric, outputSpec.published.metricType)
+		}
+
+		// Remember the results so they can stand in when inference fails
+		if outputSpec.fallback.policy == fallbackPolicyLastValue {
+			mp.lastValues.record(context.ruleIndex, outputIdx, outputResource(md, context), outputSpec.fallback.ttl, sm.Metrics(), firstMetric)
+		}
+
+		// Flag anomalous values so trace sampling can be boosted
+		if outputSpec.samplingHint != nil {
+			outputSpec.samplingHint.apply(sm, firstMetric, metricName, rule.modelName, outputResource(md, context))
+		}
+
+		// Enrich the input data points with the values; shadow rules leave the batch as it is
+		if outputSpec.attach && rule.shadow == nil {
+			attachToInputs(context, sm.Metrics(), firstMetric)
+		}
 	}
 
-	return nil
+	mp.surfaceResponseParameters(sm, firstOutput, response, context)
+	return produced, nil
+}
+
+// outputResource returns the resource of the ScopeMetrics that inference results for a rule are added to
+func outputResource(md pmetric.Metrics, context *modelContext) pcommon.Resource {
+	if context.hasContext {
+		return context.resourceMetrics.Resource()
+	}
+	if md.ResourceMetrics().Len() > 0 {
+		return md.ResourceMetrics().At(0).Resource()
+	}
+	return pcommon.NewResource()
+}
+
+// outputScopeMetrics returns the ScopeMetrics that inference results for a rule are added to
+func (mp *metricsinferenceprocessor) outputScopeMetrics(md pmetric.Metrics, context *modelContext) (pmetric.ScopeMetrics, error) {
+	// Rules with an output scope add results to that scope of the input's resource
+	if context.rule.outputScope != "" {
+		var rm pmetric.ResourceMetrics
+		switch {
+		case context.hasContext:
+			rm = context.resourceMetrics
+		case md.ResourceMetrics().Len() > 0:
+			rm = md.ResourceMetrics().At(0)
+		default:
+			return pmetric.ScopeMetrics{}, fmt.Errorf("no resource metrics available to add inference results")
+		}
+		scope := pcommon.NewInstrumentationScope()
+		scope.SetName(context.rule.outputScope)
+		scope.SetVersion(mp.scopeVersion)
+		return findOrAppendScope(rm, scope), nil
+	}
+
+	// Use the ScopeMetrics from the input context
+	if context.hasContext {
+		return mp.inputScopeOutputs(context), nil
+	}
+
+	// Fallback to the first ResourceMetrics if no context available
+	if md.ResourceMetrics().Len() == 0 {
+		return pmetric.ScopeMetrics{}, fmt.Errorf("no resource metrics available to add inference results")
+	}
+	rm := md.ResourceMetrics().At(0)
+	if rm.ScopeMetrics().Len() == 0 {
+		// Create a new scope for inference results if none exists
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName(inferenceScopeName)
+		sm.Scope().SetVersion("1.0.0")
+		return sm, nil
+	}
+	return rm.ScopeMetrics().At(0), nil
+}
+
+// outputMetricName resolves the metric name for an output of a rule, applying the
+// output pattern or intelligent naming to configured outputs. The tensor name is
+// used when the output specification has no name.
+func (mp *metricsinferenceprocessor) outputMetricName(rule *internalRule, outputIdx int, outputSpec internalOutputSpec, tensorName string) string {
+	metricName := outputSpec.name
+	if metricName == "" {
+		// Use tensor name if available, otherwise generate one
+		if tensorName != "" {
+			metricName = tensorName
+		} else {
+			metricName = fmt.Sprintf("%s_output_%d", rule.modelName, outputIdx)
+		}
+	}
+
+	// Apply naming strategy: output pattern if exists, otherwise intelligent naming
+	if !outputSpec.discovered {
+		// For explicitly configured outputs, apply naming strategy
+		if rule.outputPattern != "" {
+			// Use output pattern
+			evaluator := NewPatternEvaluator(rule.outputPattern, rule)
+			decoratedName, err := evaluator.Evaluate(metricName)
+			if err != nil {
+				mp.logger.Warn("Failed to evaluate output pattern, falling back to intelligent naming",
+					zap.String("pattern", rule.outputPattern),
+					zap.Error(err))
+				metricName = mp.defaultDecorateOutputName(rule, metricName, outputIdx)
+			} else {
+				metricName = decoratedName
+			}
+		} else {
+			// No output pattern, use intelligent naming
+			metricName = mp.defaultDecorateOutputName(rule, metricName, outputIdx)
+		}
+	}
+	// For discovered outputs, intelligent naming was already applied in mergeDiscoveredOutputs
+	return metricName
+}
+
+// outputDescription renders the description of an output metric, evaluating it as a
+// template when it contains variables. Templates that fail to evaluate are used verbatim.
+func (mp *metricsinferenceprocessor) outputDescription(rule *internalRule, outputIdx int, outputSpec internalOutputSpec, tensorName string) string {
+	description := outputSpec.description
+	if description == "" {
+		description = outputSpec.published.description
+	}
+	if description == "" {
+		return fmt.Sprintf("Inference result from model %s", rule.modelName)
+	}
+	if !strings.Contains(description, "{") {
+		return description
+	}
+
+	outputName := outputSpec.name
+	if outputName == "" {
+		outputName = tensorName
+	}
+	if outputName == "" {
+		outputName = fmt.Sprintf("%s_output_%d", rule.modelName, outputIdx)
+	}
+
+	rendered, err := NewPatternEvaluator(description, rule).WithHorizon(outputSpec.horizon).Evaluate(outputName)
+	if err != nil {
+		mp.logger.Warn("Failed to evaluate description template",
+			zap.String("template", description),
+			zap.Error(err))
+		return description
+	}
+	return rendered
 }
 
 // buildInternalConfig converts the user-provided configuration into internal rule representations
 func buildInternalConfig(config *Config) []internalRule {
 	rules := make([]internalRule, 0, len(config.Rules))
-	for _, rule := range config.Rules {
+	for ruleIdx, rule := range config.Rules {
 		// Convert parameters to internal format
 		params := make(map[string]interface{})
 		if rule.Parameters != nil {
@@ -1690,14 +2288,29 @@ func buildInternalConfig(config *Config) []internalRule {
 				params[k] = v
 			}
 		}
+		if rule.ExperimentID != "" {
+			params[paramExperimentID] = rule.ExperimentID
+		}
+		if rule.RunID != "" {
+			params[paramRunID] = rule.RunID
+		}
 
-		// Parse input selectors
-		inputSelectors := make([]*labelSelector, len(rule.Inputs))
-		for i, input := range rule.Inputs {
+		// Mapped inputs are sent under the model input names they are mapped to
+		inputs := rule.Inputs
+		var inputTensors map[string]string
+		if len(rule.InputMap) > 0 {
+			inputs, inputTensors = mappedInputs(rule.InputMap)
+		}
+		// Calendar features are generated rather than read from the batch
+		inputs = withoutTimeFeatures(inputs)
+
+		// Parse input selectors. Config.Validate rejects invalid ones; in an
+		// unvalidated configuration they are left nil, match nothing, and are
+		// reported as a permanent error when the processor starts.
+		inputSelectors := make([]*labelSelector, len(inputs))
+		for i, input := range inputs {
 			selector, err := parseLabelSelector(input)
 			if err != nil {
-				// Log error but continue with nil selector (will match nothing)
-				// The processor will log warnings during ConsumeMetrics
 				inputSelectors[i] = nil
 			} else {
 				inputSelectors[i] = selector
@@ -1708,30 +2321,128 @@ func buildInternalConfig(config *Config) []internalRule {
 		var outputs []internalOutputSpec
 		for _, output := range rule.Outputs {
 			outputName := output.Name
+			if outputName == "" {
+				outputName = output.TensorName
+			}
 			if outputName == "" {
 				// If no name specified, we'll use the tensor name from inference response
 				// or fall back to model name with index
 				outputName = fmt.Sprintf("%s_output_%d", rule.ModelName, len(outputs))
 			}
 
+			// Transforms are checked in Config.Validate, so errors are not expected here
+			post, _ := parsePostTransforms(output.Post)
+
 			outputs = append(outputs, internalOutputSpec{
 				name:        outputName,
 				dataType:    output.DataType,
 				description: output.Description,
-				unit:        output.Unit,
+				unit:        config.Units.outputUnit(output.Unit),
 				outputIndex: output.OutputIndex,
+				tensorName:  output.TensorName,
 				discovered:  false, // Configured outputs are not discovered
+				post:        post,
+				horizon:     output.Horizon,
+
+				columns:        output.Columns,
+				columnNames:    outputColumnNames(output),
+				indexAttribute: output.IndexAttribute,
+				namedElements:  len(output.ElementNames) > 0,
+
+				fallback:     newOutputFallback(output.Fallback),
+				samplingHint: newSamplingHint(output.SamplingHint),
+				cumulative:   output.Temporality == temporalityCumulative,
+				attach:       output.AttachToInput,
+
+				histogram: newHistogramOutput(output.Histogram),
+				embedding: newEmbeddingOutput(output.Embedding),
 			})
 		}
 
+		correlationID := rule.Sequence.CorrelationID
+		if correlationID == 0 {
+			correlationID = defaultCorrelationID(rule.ModelName, ruleIdx)
+		}
+
+		var backend InferenceClient
+		switch {
+		case rule.Synthetic != nil:
+			backend = newSyntheticBackend(rule.Synthetic, len(rule.Outputs))
+		case rule.Backend == backendLocal && rule.Local != nil:
+			backend = newLocalBackend(rule.Local, rule.Inputs[0])
+		case rule.Backend == backendBuiltin && rule.Builtin != nil:
+			backend = newBuiltinBackend(rule.Builtin, rule.Inputs)
+		}
+		// In-process backends have no model metadata to discover outputs from
+		if backend != nil && len(outputs) == 0 {
+			outputs = append(outputs, internalOutputSpec{name: "prediction"})
+		}
+		// Aggregation outputs are named after their function unless configured
+		if rule.Aggregate != nil && len(outputs) == 0 {
+			outputs = append(outputs, internalOutputSpec{name: rule.Aggregate.Function})
+		}
+
+		var encoders map[string]TensorEncoder
+		for input, name := range rule.Encoders {
+			if encoder, ok := lookupTensorEncoder(name); ok {
+				if encoders == nil {
+					encoders = make(map[string]TensorEncoder)
+				}
+				encoders[input] = encoder
+			}
+		}
+
 		rules = append(rules, internalRule{
-			modelName:      rule.ModelName,
-			modelVersion:   rule.ModelVersion,
-			inputs:         rule.Inputs,
-			inputSelectors: inputSelectors,
-			outputs:        outputs,
-			outputPattern:  rule.OutputPattern,
-			parameters:     params,
+			modelName:         rule.ModelName,
+			modelVersion:      rule.ModelVersion,
+			inputs:            inputs,
+			inputSelectors:    inputSelectors,
+			discoverInputs:    len(inputs) == 0,
+			inputTensors:      inputTensors,
+			mapsInputs:        len(inputTensors) > 0,
+			inputPrefix:       rule.InputPrefix,
+			outputs:           outputs,
+			outputPattern:     rule.OutputPattern,
+			parameters:        params,
+			sequenceEnabled:   rule.Sequence.Enabled,
+			correlationID:     correlationID,
+			controlInputs:     rule.Sequence.ControlInputs,
+			perSeries:         rule.Sequence.PerSeries,
+			backend:           backend,
+			encoders:          encoders,
+			transforms:        newInputTransforms(rule.Transforms),
+			route:             rule.Route,
+			id:                ruleID(rule, ruleIdx),
+			inferenceLabels:   rule.InferenceLabels == nil || *rule.InferenceLabels,
+			outputScope:       ruleOutputScope(config, rule),
+			callOptions:       ruleCallOptions(rule),
+			experimentID:      rule.ExperimentID,
+			runID:             rule.RunID,
+			attributes:        newOutputAttributePolicy(rule.OutputAttributes),
+			forwardAttributes: newForwardedAttributes(rule.ForwardAttributes),
+			timeFeatures:      newTimeFeatures(rule),
+			privacy:           newAttributePrivacy(rule.AttributePrivacy),
+			responseParams:    newResponseParameters(rule.ResponseParameters),
+			paramTemplates:    newParameterTemplates(rule.Parameters),
+			onError:           resolveOnError(config, rule),
+			partialGroups:     rule.PartialGroups,
+			mixedScopes:       rule.MixedScopes,
+			matching:          rule.MatchingStrategy,
+			maxCartesian:      config.Cardinality.maxCartesianGroups(),
+			recordLatency:     rule.RecordLatency,
+			inFlight:          newInFlightSlots(rule.MaxInFlight),
+			autoDisable:       newAutoDisabler(rule.AutoDisable),
+			maxGroups:         resolveMaxGroups(config, rule),
+			priority:          rule.Priority,
+			deadline:          rule.Deadline,
+			every:             triggerInterval(rule.Trigger),
+			expandBy:          rule.ExpandBy,
+			enabled:           ruleEnabled(rule),
+			selector:          newModelSelector(rule.ModelSelector),
+			shadow:            newShadowMode(rule),
+			challenger:        newChallenger(rule.Challenger),
+			combine:           newCombinedInputs(rule.CombineInputs),
+			aggregate:         newAggregation(rule.Aggregate),
 		})
 	}
 	return rules
@@ -1758,13 +2469,18 @@ func (mp *metricsinferenceprocessor) mergeDiscoveredOutputs() {
 				outputIdx := i
 				// Decorate the output name to disambiguate multiple instances of the same model
 				decoratedName := mp.decorateOutputName(rule, output.Name, i)
+				published := mp.outputMetadataFor(rule, output)
+				description := published.description
+				if description == "" {
+					description = fmt.Sprintf("Discovered output from model %s", rule.modelName)
+				}
 				rule.outputs = append(rule.outputs, internalOutputSpec{
 					name:        decoratedName,
 					dataType:    convertKServeDataType(output.Datatype),
-					description: fmt.Sprintf("Discovered output from model %s", rule.modelName),
-					unit:        "", // No unit information in metadata
+					description: description,
 					outputIndex: &outputIdx,
 					discovered:  true,
+					published:   published,
 				})
 			}
 		} else {
@@ -1772,6 +2488,20 @@ func (mp *metricsinferenceprocessor) mergeDiscoveredOutputs() {
 			for outputIdx := range rule.outputs {
 				output := &rule.outputs[outputIdx]
 
+				// Outputs are matched to tensors like in responses: by name or index, else by position
+				metaIdx := outputIdx
+				if output.tensorName != "" {
+					metaIdx = slices.IndexFunc(metadata.outputs, func(tensor *pb.ModelMetadataResponse_TensorMetadata) bool {
+						return tensor.Name == output.tensorName
+					})
+				} else if output.outputIndex != nil {
+					metaIdx = *output.outputIndex
+				}
+				output.published = outputMetadata{}
+				if metaIdx >= 0 && metaIdx < len(metadata.outputs) {
+					output.published = mp.outputMetadataFor(rule, metadata.outputs[metaIdx])
+				}
+
 				// If output index is specified, use metadata from that index
 				if output.outputIndex != nil && *output.outputIndex < len(metadata.outputs) {
 					metaOutput := metadata.outputs[*output.outputIndex]
@@ -1790,6 +2520,11 @@ func (mp *metricsinferenceprocessor) mergeDiscoveredOutputs() {
 						output.dataType = convertKServeDataType(metaOutput.Datatype)
 					}
 				}
+
+				// Outputs selected by tensor name take the data type of that tensor
+				if output.tensorName != "" && metaIdx >= 0 && output.dataType == "" {
+					output.dataType = convertKServeDataType(metadata.outputs[metaIdx].Datatype)
+				}
 			}
 		}
 	}
@@ -1829,7 +2564,7 @@ func (mp *metricsinferenceprocessor) defaultDecorateOutputName(rule *internalRul
 // convertKServeDataType converts KServe data types to internal types
 func convertKServeDataType(kserveType string) string {
 	switch kserveType {
-	case "FP32", "FP64":
+	case "FP16", "BF16", "FP32", "FP64":
 		return "float"
 	case "INT8", "INT16", "INT32", "INT64":
 		return "int"
@@ -1843,7 +2578,7 @@ func convertKServeDataType(kserveType string) string {
 }
 
 // processOutputTensor processes a single output tensor and populates the metric
-func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric, outputTensor *pb.ModelInferResponse_InferOutputTensor, outputType, modelName, metricName string, context *modelContext) error {
+func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric, outputTensor *pb.ModelInferResponse_InferOutputTensor, outputType, modelName, metricName string, post []postTransform, context *modelContext) error {
 	switch outputType {
 	case "float", "double":
 		gauge := metric.SetEmptyGauge()
@@ -1855,7 +2590,7 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 			for _, val := range outputTensor.Contents.Fp64Contents {
 				dp := dps.AppendEmpty()
 				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
-				dp.SetDoubleValue(val)
+				dp.SetDoubleValue(applyPostTransforms(val, post))
 				// Copy attributes from specific input data point
 				copyAttributesFromDataPointGroup(dp, context, dataPointIndex)
 				dataPointIndex++
@@ -1863,7 +2598,7 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 			for _, val := range outputTensor.Contents.Fp32Contents {
 				dp := dps.AppendEmpty()
 				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
-				dp.SetDoubleValue(float64(val))
+				dp.SetDoubleValue(applyPostTransforms(float64(val), post))
 				// Copy attributes from specific input data point
 				copyAttributesFromDataPointGroup(dp, context, dataPointIndex)
 				dataPointIndex++
@@ -1880,7 +2615,7 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 			for _, val := range outputTensor.Contents.Int64Contents {
 				dp := dps.AppendEmpty()
 				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
-				dp.SetIntValue(val)
+				setIntOrTransformedValue(dp, val, post)
 				// Copy attributes from specific input data point
 				copyAttributesFromDataPointGroup(dp, context, dataPointIndex)
 				dataPointIndex++
@@ -1888,7 +2623,7 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 			for _, val := range outputTensor.Contents.IntContents {
 				dp := dps.AppendEmpty()
 				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
-				dp.SetIntValue(int64(val))
+				setIntOrTransformedValue(dp, int64(val), post)
 				// Copy attributes from specific input data point
 				copyAttributesFromDataPointGroup(dp, context, dataPointIndex)
 				dataPointIndex++
@@ -1906,9 +2641,9 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 				dp := dps.AppendEmpty()
 				dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
 				if val {
-					dp.SetDoubleValue(1.0)
+					dp.SetDoubleValue(applyPostTransforms(1.0, post))
 				} else {
-					dp.SetDoubleValue(0.0)
+					dp.SetDoubleValue(applyPostTransforms(0.0, post))
 				}
 				// Copy attributes from specific input data point
 				copyAttributesFromDataPointGroup(dp, context, dataPointIndex)
@@ -1934,86 +2669,65 @@ func (mp *metricsinferenceprocessor) processOutputTensor(metric pmetric.Metric,
 	return nil
 }
 
+// setIntOrTransformedValue sets an integer output value, switching to a double value
+// when post-processing transforms are configured since they may produce fractions
+func setIntOrTransformedValue(dp pmetric.NumberDataPoint, val int64, post []postTransform) {
+	if len(post) == 0 {
+		dp.SetIntValue(val)
+		return
+	}
+	dp.SetDoubleValue(applyPostTransforms(float64(val), post))
+}
+
 // copyAttributesFromDataPointGroup copies attributes from the specific matched data point group to the output data point
 // and adds inference metadata labels (model name and version only)
 func copyAttributesFromDataPointGroup(outputDP pmetric.NumberDataPoint, context *modelContext, dataPointIndex int) {
+	copyGroupAttributes(outputDP.Attributes(), context, dataPointIndex)
+}
+
+// copyGroupAttributes copies the attributes of the specific matched data point group, and the
+// inference metadata labels, to the attributes of an output data point of any type
+func copyGroupAttributes(attrs pcommon.Map, context *modelContext, dataPointIndex int) {
 	if context == nil {
 		return
 	}
 
-	attrs := outputDP.Attributes()
-
-	// Copy attributes from the matched data point group with namespacing
+	// Copy attributes from the matched data point group according to the rule's policy
 	if len(context.matchedDataPoints) > dataPointIndex {
 		// Use the matched data point groups for correct attribute mapping
 		group := context.matchedDataPoints[dataPointIndex]
-
-		// For each input metric in the group
-		for inputName, dataPoint := range group.dataPoints {
-			// Copy each attribute with the input metric name as prefix
-			dataPoint.Attributes().Range(func(k string, v pcommon.Value) bool {
-				// Namespace the attribute with the input metric name
-				namespacedKey := fmt.Sprintf("%s.%s", inputName, k)
-				attrs.PutStr(namespacedKey, v.AsString())
-				return true
-			})
-		}
+		context.rule.attributes.copyAttributes(attrs, context.rule.inputs, group.dataPoints)
 	} else if len(context.inputDataPoints) > 0 {
-		// Fallback to old behavior if matching is not available
-		// Still apply namespacing for consistency
+		// Fallback to the first data point of each input if matching is not available
+		first := make(map[string]dataPoint, len(context.inputDataPoints))
 		for inputName, dataPoints := range context.inputDataPoints {
 			if len(dataPoints) > 0 {
-				dataPoints[0].Attributes().Range(func(k string, v pcommon.Value) bool {
-					namespacedKey := fmt.Sprintf("%s.%s", inputName, k)
-					attrs.PutStr(namespacedKey, v.AsString())
-					return true
-				})
+				first[inputName] = dataPoints[0]
 			}
 		}
+		context.rule.attributes.copyAttributes(attrs, context.rule.inputs, first)
 	}
 
-	// Add inference metadata labels (model name and version only - no status)
-	attrs.PutStr(labelInferenceModelName, context.rule.modelName)
-	if context.rule.modelVersion != "" {
-		attrs.PutStr(labelInferenceModelVersion, context.rule.modelVersion)
+	// Add inference metadata labels (model name and version only - no status),
+	// unless the rule leaves them out
+	if context.rule.inferenceLabels {
+		putModelLabels(attrs, &context.rule)
 	}
-}
-
-// extractDataPoints extracts all NumberDataPoints from a metric for attribute copying
-func extractDataPoints(metric pmetric.Metric) []pmetric.NumberDataPoint {
-	var dataPoints []pmetric.NumberDataPoint
-
-	switch metric.Type() {
-	case pmetric.MetricTypeGauge:
-		gauge := metric.Gauge()
-		for i := 0; i < gauge.DataPoints().Len(); i++ {
-			dataPoints = append(dataPoints, gauge.DataPoints().At(i))
-		}
-	case pmetric.MetricTypeSum:
-		sum := metric.Sum()
-		for i := 0; i < sum.DataPoints().Len(); i++ {
-			dataPoints = append(dataPoints, sum.DataPoints().At(i))
-		}
-	case pmetric.MetricTypeHistogram:
-		histogram := metric.Histogram()
-		for i := 0; i < histogram.DataPoints().Len(); i++ {
-			// Note: HistogramDataPoint doesn't implement NumberDataPoint interface
-			// For now, we'll skip histogram metrics for attribute copying
-			// This could be enhanced in the future if needed
-		}
-	case pmetric.MetricTypeExponentialHistogram:
-		expHistogram := metric.ExponentialHistogram()
-		for i := 0; i < expHistogram.DataPoints().Len(); i++ {
-			// Note: ExponentialHistogramDataPoint doesn't implement NumberDataPoint interface
-			// For now, we'll skip exponential histogram metrics for attribute copying
-		}
-	case pmetric.MetricTypeSummary:
-		summary := metric.Summary()
-		for i := 0; i < summary.DataPoints().Len(); i++ {
-			// Note: SummaryDataPoint doesn't implement NumberDataPoint interface
-			// For now, we'll skip summary metrics for attribute copying
+	if context.rule.route != "" {
+		attrs.PutStr(labelRoute, context.rule.route)
+	}
+	if context.rule.experimentID != "" {
+		attrs.PutStr(labelExperimentID, context.rule.experimentID)
+	}
+	if context.rule.runID != "" {
+		attrs.PutStr(labelRunID, context.rule.runID)
+	}
+	if context.expansion != nil {
+		attrs.PutStr(context.rule.expandBy, context.expansion.value)
+	}
+	for inputName, scaling := range context.scaling {
+		for statistic, value := range scaling {
+			attrs.PutDouble(inputName+".scaling."+statistic, value)
 		}
 	}
-
-	return dataPoints
 }
@@ -600,112 +600,125 @@ func TestGoldenFileMetrics(t *testing.T) {
 		},
 	}
 
-	for _, testCase := range testCases {
-		t.Run(testCase.Name, func(t *testing.T) {
-			// Create mock server for inference
-			mockServer := testutil.NewMockInferenceServer()
-			mockServer.Start(t)
-			defer mockServer.Stop()
-
-			// Set up mock responses based on test case
-			switch testCase.Name {
-			// Basic inference tests
-			case "basic_cpu_prediction":
-				mockServer.SetModelResponse("cpu_prediction", testutil.CreateMockResponseForScaling("cpu_prediction", 1.13, 0.75))
-			case "multiple_outputs":
-				mockServer.SetModelResponse("health_prediction", testutil.CreateMockResponseForMultipleOutputs("health_prediction", []float64{0.92, 1.0}))
-			case "no_rules":
-				// No mock responses needed for passthrough test
-			case "metadata_discovery":
-				// Set up metadata for discovery model
-				mockServer.SetModelMetadata("discovery_model", &pb.ModelMetadataResponse{
-					Name: "discovery_model",
-					Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
-						{
-							Name:     "discovered_prediction",
-							Datatype: "FP64",
-							Shape:    []int64{1},
-						},
-						{
-							Name:     "discovered_confidence",
-							Datatype: "FP64",
-							Shape:    []int64{1},
-						},
+	// Mock server fixtures by test case, applied to an isolated server per test
+	fixtures := map[string][]testutil.Fixture{
+		// Basic inference tests
+		"basic_cpu_prediction": {
+			testutil.WithModelResponse("cpu_prediction", testutil.CreateMockResponseForScaling("cpu_prediction", 1.13, 0.75)),
+		},
+		"multiple_outputs": {
+			testutil.WithModelResponse("health_prediction", testutil.CreateMockResponseForMultipleOutputs("health_prediction", []float64{0.92, 1.0})),
+		},
+		"metadata_discovery": {
+			testutil.WithModelMetadata("discovery_model", &pb.ModelMetadataResponse{
+				Name: "discovery_model",
+				Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
+					{
+						Name:     "discovered_prediction",
+						Datatype: "FP64",
+						Shape:    []int64{1},
 					},
-				})
-				// Set up inference response
-				mockServer.SetModelResponse("discovery_model", &pb.ModelInferResponse{
-					ModelName: "discovery_model",
-					Outputs: []*pb.ModelInferResponse_InferOutputTensor{
-						{
-							Name:     "discovered_prediction",
-							Datatype: "FP64",
-							Shape:    []int64{1},
-							Contents: &pb.InferTensorContents{
-								Fp64Contents: []float64{0.8475},
-							},
+					{
+						Name:     "discovered_confidence",
+						Datatype: "FP64",
+						Shape:    []int64{1},
+					},
+				},
+			}),
+			testutil.WithModelResponse("discovery_model", &pb.ModelInferResponse{
+				ModelName: "discovery_model",
+				Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+					{
+						Name:     "discovered_prediction",
+						Datatype: "FP64",
+						Shape:    []int64{1},
+						Contents: &pb.InferTensorContents{
+							Fp64Contents: []float64{0.8475},
 						},
-						{
-							Name:     "discovered_confidence",
-							Datatype: "FP64",
-							Shape:    []int64{1},
-							Contents: &pb.InferTensorContents{
-								Fp64Contents: []float64{0.95},
-							},
+					},
+					{
+						Name:     "discovered_confidence",
+						Datatype: "FP64",
+						Shape:    []int64{1},
+						Contents: &pb.InferTensorContents{
+							Fp64Contents: []float64{0.95},
 						},
 					},
-				})
-
-			// Input metric types tests
-			case "sum_gauge_inference":
-				mockServer.SetModelResponse("filesystem_prediction", testutil.CreateMockResponseForFilesystem("filesystem_prediction", 52428800000.0))
-			case "gauge_only_inference":
-				mockServer.SetModelResponse("utilization_prediction", testutil.CreateMockResponseForScaling("utilization_prediction", 1.2, 0.8))
-			case "sum_only_inference":
-				mockServer.SetModelResponse("usage_prediction", testutil.CreateMockResponseForDataType("usage_prediction", "INT64", int64(45036953600)))
-			case "multi_attribute_inference":
-				mockServer.SetModelResponse("capacity_anomaly_detection", testutil.CreateMockResponseForMultipleOutputs("capacity_anomaly_detection", []float64{0.15, 0.0}))
-
-			// Multi-model tests
-			case "multiple_models_same_input":
-				mockServer.SetModelResponse("cpu_anomaly_detector", testutil.CreateMockResponseForScaling("cpu_anomaly_detector", 1.1, 0.75))
-				mockServer.SetModelResponse("cpu_predictor", testutil.CreateMockResponseForScaling("cpu_predictor", 1.15, 0.75))
-			case "multiple_models_different_inputs":
-				mockServer.SetModelResponse("cpu_model", testutil.CreateMockResponseForScaling("cpu_model", 1.1, 0.75))
-				mockServer.SetModelResponse("memory_model", testutil.CreateMockResponseForScaling("memory_model", 1.2, 0.45))
-				mockServer.SetModelResponse("combined_model", testutil.CreateMockResponseForCalculation("combined_model", 0.89))
-			case "sequential_processing":
-				mockServer.SetModelResponse("stage1_model", testutil.CreateMockResponseForScaling("stage1_model", 1.0, 0.75))
-				mockServer.SetModelResponse("stage2_model", testutil.CreateMockResponseForScaling("stage2_model", 1.0, 0.45))
-			case "model_versioning":
-				// Set up responses for both model versions
-				mockServer.SetModelResponse("cpu_model", testutil.CreateMockResponseForScaling("cpu_model", 1.1, 0.75))
-
-			// Data types tests
-			case "float_output":
-				mockServer.SetModelResponse("float_prediction_model", testutil.CreateMockResponseForDataType("float_prediction_model", "FP32", float32(0.85)))
-			case "int_output":
-				mockServer.SetModelResponse("int_prediction_model", testutil.CreateMockResponseForDataType("int_prediction_model", "INT32", int32(1)))
-			case "double_output":
-				mockServer.SetModelResponse("double_prediction_model", testutil.CreateMockResponseForDataType("double_prediction_model", "FP64", float64(0.85)))
-			case "mixed_types":
-				values := map[string]interface{}{
-					"anomaly_score": float32(0.15),
-					"alert_level":   int32(1),
-					"confidence":    float64(0.95),
-				}
-				mockServer.SetModelResponse("mixed_types_model", testutil.CreateMockResponseForMixedTypes("mixed_types_model", values))
-			case "int_gauge_input":
-				mockServer.SetModelResponse("int_input_model", testutil.CreateMockResponseForDataType("int_input_model", "INT64", int64(1100)))
-
-			// Error handling tests
-			case "server_error":
-				mockServer.SetModelError("failing_model", testutil.CreateMockErrorResponse(codes.Internal, "model inference failed"))
-			case "missing_input_metric":
-				mockServer.SetModelResponse("cpu_prediction", testutil.CreateMockResponseForScaling("cpu_prediction", 1.13, 0.75))
-			case "model_not_ready":
-				mockServer.SetModelError("not_ready_model", testutil.CreateMockErrorResponse(codes.Unavailable, "model not ready"))
-			}
+				},
+			}),
+		},
+
+		// Input metric types tests
+		"sum_gauge_inference": {
+			testutil.WithModelResponse("filesystem_prediction", testutil.CreateMockResponseForFilesystem("filesystem_prediction", 52428800000.0)),
+		},
+		"gauge_only_inference": {
+			testutil.WithModelResponse("utilization_prediction", testutil.CreateMockResponseForScaling("utilization_prediction", 1.2, 0.8)),
+		},
+		"sum_only_inference": {
+			testutil.WithModelResponse("usage_prediction", testutil.CreateMockResponseForDataType("usage_prediction", "INT64", int64(45036953600))),
+		},
+		"multi_attribute_inference": {
+			testutil.WithModelResponse("capacity_anomaly_detection", testutil.CreateMockResponseForMultipleOutputs("capacity_anomaly_detection", []float64{0.15, 0.0})),
+		},
+
+		// Multi-model tests
+		"multiple_models_same_input": {
+			testutil.WithModelResponse("cpu_anomaly_detector", testutil.CreateMockResponseForScaling("cpu_anomaly_detector", 1.1, 0.75)),
+			testutil.WithModelResponse("cpu_predictor", testutil.CreateMockResponseForScaling("cpu_predictor", 1.15, 0.75)),
+		},
+		"multiple_models_different_inputs": {
+			testutil.WithModelResponse("cpu_model", testutil.CreateMockResponseForScaling("cpu_model", 1.1, 0.75)),
+			testutil.WithModelResponse("memory_model", testutil.CreateMockResponseForScaling("memory_model", 1.2, 0.45)),
+			testutil.WithModelResponse("combined_model", testutil.CreateMockResponseForCalculation("combined_model", 0.89)),
+		},
+		"sequential_processing": {
+			testutil.WithModelResponse("stage1_model", testutil.CreateMockResponseForScaling("stage1_model", 1.0, 0.75)),
+			testutil.WithModelResponse("stage2_model", testutil.CreateMockResponseForScaling("stage2_model", 1.0, 0.45)),
+		},
+		"model_versioning": {
+			testutil.WithModelResponse("cpu_model", testutil.CreateMockResponseForScaling("cpu_model", 1.1, 0.75)),
+		},
+
+		// Data types tests
+		"float_output": {
+			testutil.WithModelResponse("float_prediction_model", testutil.CreateMockResponseForDataType("float_prediction_model", "FP32", float32(0.85))),
+		},
+		"int_output": {
+			testutil.WithModelResponse("int_prediction_model", testutil.CreateMockResponseForDataType("int_prediction_model", "INT32", int32(1))),
+		},
+		"double_output": {
+			testutil.WithModelResponse("double_prediction_model", testutil.CreateMockResponseForDataType("double_prediction_model", "FP64", float64(0.85))),
+		},
+		"mixed_types": {
+			testutil.WithModelResponse("mixed_types_model", testutil.CreateMockResponseForMixedTypes("mixed_types_model", map[string]interface{}{
+				"anomaly_score": float32(0.15),
+				"alert_level":   int32(1),
+				"confidence":    float64(0.95),
+			})),
+		},
+		"int_gauge_input": {
+			testutil.WithModelResponse("int_input_model", testutil.CreateMockResponseForDataType("int_input_model", "INT64", int64(1100))),
+		},
+
+		// Error handling tests
+		"server_error": {
+			testutil.WithModelError("failing_model", testutil.CreateMockErrorResponse(codes.Internal, "model inference failed")),
+		},
+		"missing_input_metric": {
+			testutil.WithModelResponse("cpu_prediction", testutil.CreateMockResponseForScaling("cpu_prediction", 1.13, 0.75)),
+		},
+		"model_not_ready": {
+			testutil.WithModelError("not_ready_model", testutil.CreateMockErrorResponse(codes.Unavailable, "model not ready")),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			t.Parallel()
+
+			// Each test case runs against its own mock server
+			mockServer := testutil.StartMockServer(t, fixtures[testCase.Name]...)
 
 			// Load configuration
 			var configPath string
@@ -730,8 +743,9 @@ func TestGoldenFileMetrics(t *testing.T) {
 				if testCase.Name != "no_rules" {
 					cfgTyped.GRPCClientSettings.Endpoint = mockServer.GetAddress()
 				} else {
-					// For no_rules test, use the special test endpoint and clear rules
+					// For no_rules test, use an unreachable endpoint and clear rules
 					cfgTyped.GRPCClientSettings.Endpoint = "localhost:12345"
+					cfgTyped.GRPCClientSettings.LazyConnect = true
 					cfgTyped.Rules = []Rule{}
 				}
 			}
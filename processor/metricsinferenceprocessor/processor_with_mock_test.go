@@ -6,6 +6,7 @@ package metricsinferenceprocessor
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,6 +14,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
 	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
@@ -431,3 +433,96 @@ func TestMetricsInferenceProcessorConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsInferenceProcessorWithFaultyServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures []testutil.Fixture
+		deadline time.Duration
+		expected []float64 // Score of each batch, -1 for the fallback of a failed inference
+	}{
+		{
+			name: "fails then succeeds",
+			fixtures: []testutil.Fixture{testutil.WithResponseSequence("scorer",
+				testutil.MockStep{Err: testutil.CreateMockErrorResponse(codes.Unavailable, "model loading")})},
+			expected: []float64{-1, 0.5, 0.5},
+		},
+		{
+			name:     "transient errors",
+			fixtures: []testutil.Fixture{testutil.WithFaults("scorer", testutil.FaultConfig{ErrorRate: 1})},
+			expected: []float64{-1, -1},
+		},
+		{
+			name:     "latency past the deadline",
+			fixtures: []testutil.Fixture{testutil.WithLatency(5*time.Second, 0)},
+			deadline: 20 * time.Millisecond,
+			expected: []float64{-1},
+		},
+		{
+			name:     "latency within the deadline",
+			fixtures: []testutil.Fixture{testutil.WithLatency(10*time.Millisecond, 10*time.Millisecond)},
+			deadline: time.Second,
+			expected: []float64{0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtures := append([]testutil.Fixture{
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.5)),
+			}, tt.fixtures...)
+			mockServer := testutil.StartMockServer(t, fixtures...)
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Rules: []Rule{{
+					ModelName:     "scorer",
+					Inputs:        []string{"cpu.usage"},
+					Outputs:       []OutputSpec{{Name: "cpu.score", Fallback: FallbackConfig{Policy: fallbackPolicyConstant, Value: -1}}},
+					OutputPattern: "{output}",
+					Deadline:      tt.deadline,
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			for i, expected := range tt.expected {
+				input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"cpu.usage"}, MetricValues: [][]float64{{40}}})
+				require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+				score := findMetricByName(sink.AllMetrics()[i], "cpu.score")
+				require.Equal(t, 1, score.Gauge().DataPoints().Len(), "batch %d", i)
+				assert.Equal(t, expected, score.Gauge().DataPoints().At(0).DoubleValue(), "batch %d", i)
+			}
+			assert.Len(t, mockServer.GetRequests(), len(tt.expected))
+		})
+	}
+}
+
+func TestMockServerFaultsAreReproducible(t *testing.T) {
+	failures := func() []bool {
+		server := testutil.NewMockInferenceServer()
+		server.SetFaults("", testutil.FaultConfig{ErrorRate: 0.5, ErrorCode: codes.ResourceExhausted, Seed: 42})
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, err := server.ModelInfer(context.Background(), &pb.ModelInferRequest{ModelName: "scorer"})
+			if err != nil {
+				assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			}
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first := failures()
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+	assert.Equal(t, first, failures(), "the same seed injects the same faults")
+}
@@ -0,0 +1,207 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policies for inference calls submitted while the queue is full
+const (
+	queueDropPolicyBlock      = "block"       // Wait for room, up to the maximum wait
+	queueDropPolicyDropOldest = "drop_oldest" // Drop the longest waiting call
+	queueDropPolicyDropNewest = "drop_newest" // Drop the submitted call
+)
+
+// defaultQueueWorkers is the number of queue workers when none is configured
+const defaultQueueWorkers = 10
+
+// errQueueFull is returned for inference calls dropped by the queue
+var errQueueFull = errors.New("inference queue is full")
+
+// validateQueueConfig checks the inference queue settings
+func validateQueueConfig(cfg QueueConfig) error {
+	if cfg.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("workers must not be negative")
+	}
+	if cfg.MaxWait < 0 {
+		return fmt.Errorf("max_wait must not be negative")
+	}
+	switch cfg.DropPolicy {
+	case "", queueDropPolicyBlock, queueDropPolicyDropOldest, queueDropPolicyDropNewest:
+		return nil
+	default:
+		return fmt.Errorf("invalid drop_policy %q (must be 'block', 'drop_oldest' or 'drop_newest')", cfg.DropPolicy)
+	}
+}
+
+// queuedCall is an inference call waiting in the queue
+type queuedCall struct {
+	run      func()      // Runs the call on a worker
+	drop     func(error) // Fails the call without running it
+	rule     string      // Identifier of the call's rule on the queue depth metric
+	priority int         // Calls of higher priority leave the queue first
+}
+
+// inferenceQueue runs inference calls of all batches on a bounded number of
+// workers, holding at most size waiting calls. Workers are started as calls
+// arrive and exit once the queue is empty.
+type inferenceQueue struct {
+	mu      sync.Mutex
+	calls   *list.List    // Waiting calls, by descending priority and oldest first within a priority
+	freed   chan struct{} // Closed and replaced whenever a waiting call leaves the queue
+	running int           // Number of running workers
+
+	size       int
+	workers    int
+	dropPolicy string
+	maxWait    time.Duration
+
+	depthChanged func(rule string, delta int64) // Reports changes of the number of waiting calls of a rule
+}
+
+// newInferenceQueue creates a queue, or returns nil when the queue is disabled
+func newInferenceQueue(cfg QueueConfig, depthChanged func(rule string, delta int64)) *inferenceQueue {
+	if cfg.QueueSize <= 0 {
+		return nil
+	}
+	q := &inferenceQueue{
+		calls:        list.New(),
+		freed:        make(chan struct{}),
+		size:         cfg.QueueSize,
+		workers:      cfg.Workers,
+		dropPolicy:   cfg.DropPolicy,
+		maxWait:      cfg.MaxWait,
+		depthChanged: depthChanged,
+	}
+	if q.workers <= 0 {
+		q.workers = defaultQueueWorkers
+	}
+	if q.dropPolicy == "" {
+		q.dropPolicy = queueDropPolicyBlock
+	}
+	return q
+}
+
+// submit queues a call. When the queue is full, the drop policy decides whether
+// the call waits for room, replaces the oldest waiting call of the lowest
+// priority, or is dropped. With the block policy, submit waits until there is
+// room, the maximum wait elapses or ctx is done; dropped calls are failed with
+// errQueueFull.
+func (q *inferenceQueue) submit(ctx context.Context, call queuedCall) {
+	var timeout <-chan time.Time
+	if q.maxWait > 0 {
+		timer := time.NewTimer(q.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		q.mu.Lock()
+		if q.calls.Len() < q.size {
+			q.push(call)
+			q.mu.Unlock()
+			return
+		}
+
+		switch q.dropPolicy {
+		case queueDropPolicyDropNewest:
+			q.mu.Unlock()
+			call.drop(errQueueFull)
+			return
+		case queueDropPolicyDropOldest:
+			// Calls of a higher priority than the submitted one are never dropped for it
+			victim := q.oldestLowest()
+			if victim.Value.(queuedCall).priority > call.priority {
+				q.mu.Unlock()
+				call.drop(errQueueFull)
+				return
+			}
+			oldest := q.calls.Remove(victim).(queuedCall)
+			q.depthChanged(oldest.rule, -1)
+			q.push(call)
+			q.mu.Unlock()
+			oldest.drop(errQueueFull)
+			return
+		}
+
+		freed := q.freed
+		q.mu.Unlock()
+		select {
+		case <-freed:
+		case <-timeout:
+			call.drop(fmt.Errorf("%w after waiting %s", errQueueFull, q.maxWait))
+			return
+		case <-ctx.Done():
+			call.drop(fmt.Errorf("%w: %w", errQueueFull, ctx.Err()))
+			return
+		}
+	}
+}
+
+// oldestLowest returns the longest waiting call of the lowest priority. The
+// queue must not be empty, and the caller must hold q.mu.
+func (q *inferenceQueue) oldestLowest() *list.Element {
+	oldest := q.calls.Back()
+	lowest := oldest.Value.(queuedCall).priority
+	for e := oldest.Prev(); e != nil && e.Value.(queuedCall).priority == lowest; e = e.Prev() {
+		oldest = e
+	}
+	return oldest
+}
+
+// push inserts a call behind the waiting calls of the same or a higher priority,
+// and starts a worker when fewer than the limit are running. The caller must
+// hold q.mu.
+func (q *inferenceQueue) push(call queuedCall) {
+	e := q.calls.Back()
+	for e != nil && e.Value.(queuedCall).priority < call.priority {
+		e = e.Prev()
+	}
+	if e == nil {
+		q.calls.PushFront(call)
+	} else {
+		q.calls.InsertAfter(call, e)
+	}
+	q.depthChanged(call.rule, 1)
+	if q.running < q.workers {
+		q.running++
+		go q.work()
+	}
+}
+
+// work runs waiting calls until the queue is empty
+func (q *inferenceQueue) work() {
+	for {
+		q.mu.Lock()
+		front := q.calls.Front()
+		if front == nil {
+			q.running--
+			q.mu.Unlock()
+			return
+		}
+		call := q.calls.Remove(front).(queuedCall)
+		q.depthChanged(call.rule, -1)
+		close(q.freed)
+		q.freed = make(chan struct{})
+		q.mu.Unlock()
+
+		call.run()
+	}
+}
+
+// depth returns the number of waiting calls
+func (q *inferenceQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.calls.Len()
+}
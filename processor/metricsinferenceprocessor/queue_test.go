@@ -0,0 +1,306 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// queueRecorder submits calls that block until released and records how each ended
+type queueRecorder struct {
+	mu      sync.Mutex
+	ran     []int
+	dropped []int
+	release chan struct{}
+}
+
+func (r *queueRecorder) call(id int) queuedCall {
+	return queuedCall{
+		run: func() {
+			<-r.release
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.ran = append(r.ran, id)
+		},
+		drop: func(err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dropped = append(r.dropped, id)
+		},
+	}
+}
+
+func (r *queueRecorder) results() (ran, dropped []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.ran...), append([]int(nil), r.dropped...)
+}
+
+func TestInferenceQueueDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		ran     []int
+		dropped []int
+	}{
+		// Call 0 occupies the single worker, calls 1 and 2 fill the queue
+		{policy: queueDropPolicyDropNewest, ran: []int{0, 1, 2}, dropped: []int{3}},
+		{policy: queueDropPolicyDropOldest, ran: []int{0, 2, 3}, dropped: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			recorder := &queueRecorder{release: make(chan struct{})}
+			var depth int64
+			var depthMu sync.Mutex
+			queue := newInferenceQueue(QueueConfig{QueueSize: 2, Workers: 1, DropPolicy: tt.policy}, func(_ string, delta int64) {
+				depthMu.Lock()
+				defer depthMu.Unlock()
+				depth += delta
+			})
+
+			queue.submit(context.Background(), recorder.call(0))
+			require.Eventually(t, func() bool { return queue.depth() == 0 }, time.Second, time.Millisecond)
+			for id := 1; id <= 3; id++ {
+				queue.submit(context.Background(), recorder.call(id))
+			}
+			assert.Equal(t, 2, queue.depth())
+			_, dropped := recorder.results()
+			assert.Equal(t, tt.dropped, dropped)
+
+			close(recorder.release)
+			require.Eventually(t, func() bool {
+				ran, _ := recorder.results()
+				return len(ran) == len(tt.ran)
+			}, time.Second, time.Millisecond)
+			ran, _ := recorder.results()
+			assert.Equal(t, tt.ran, ran)
+			depthMu.Lock()
+			assert.Equal(t, int64(0), depth)
+			depthMu.Unlock()
+		})
+	}
+}
+
+func TestInferenceQueueBlock(t *testing.T) {
+	recorder := &queueRecorder{release: make(chan struct{})}
+	queue := newInferenceQueue(QueueConfig{QueueSize: 1, Workers: 1, MaxWait: 20 * time.Millisecond}, func(string, int64) {})
+
+	queue.submit(context.Background(), recorder.call(0))
+	require.Eventually(t, func() bool { return queue.depth() == 0 }, time.Second, time.Millisecond)
+	queue.submit(context.Background(), recorder.call(1))
+
+	// The queue is full, so the next call waits until the maximum wait and is dropped
+	started := time.Now()
+	queue.submit(context.Background(), recorder.call(2))
+	assert.GreaterOrEqual(t, time.Since(started), 20*time.Millisecond)
+	_, dropped := recorder.results()
+	assert.Equal(t, []int{2}, dropped)
+
+	// Once a worker frees room, a blocked call is queued
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.submit(context.Background(), recorder.call(3))
+	}()
+	close(recorder.release)
+	<-done
+	require.Eventually(t, func() bool {
+		ran, _ := recorder.results()
+		return len(ran) == 3
+	}, time.Second, time.Millisecond)
+	ran, dropped := recorder.results()
+	assert.Equal(t, []int{0, 1, 3}, ran)
+	assert.Equal(t, []int{2}, dropped)
+}
+
+func TestInferenceQueuePriority(t *testing.T) {
+	recorder := &queueRecorder{release: make(chan struct{})}
+	queue := newInferenceQueue(QueueConfig{QueueSize: 3, Workers: 1, DropPolicy: queueDropPolicyDropOldest}, func(string, int64) {})
+	submit := func(id, priority int) {
+		call := recorder.call(id)
+		call.priority = priority
+		queue.submit(context.Background(), call)
+	}
+
+	submit(0, 0)
+	require.Eventually(t, func() bool { return queue.depth() == 0 }, time.Second, time.Millisecond)
+	submit(1, 0)
+	submit(2, 5)
+	submit(3, 0)
+
+	// A full queue drops its oldest call of the lowest priority
+	submit(4, 1)
+	// and never drops calls of a higher priority than the submitted one
+	submit(5, -1)
+	_, dropped := recorder.results()
+	assert.Equal(t, []int{1, 5}, dropped)
+
+	// Calls of a higher priority run first, in submission order within a priority
+	close(recorder.release)
+	require.Eventually(t, func() bool {
+		ran, _ := recorder.results()
+		return len(ran) == 4
+	}, time.Second, time.Millisecond)
+	ran, _ := recorder.results()
+	assert.Equal(t, []int{0, 2, 4, 3}, ran)
+}
+
+func TestRuleQueue(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Queue:              QueueConfig{QueueSize: 4, Workers: 2},
+		Rules: []Rule{
+			{
+				ID:        "slow",
+				ModelName: "scorer",
+				Inputs:    []string{"metric_1"},
+				Outputs:   []OutputSpec{{Name: "score"}},
+				Queue:     &QueueConfig{QueueSize: 2, Workers: 1},
+			},
+			{
+				ID:        "fast",
+				ModelName: "scorer",
+				Inputs:    []string{"metric_2"},
+				Outputs:   []OutputSpec{{Name: "score"}},
+				Priority:  1,
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	reader := sdkmetric.NewManualReader()
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NotNil(t, processor.rules[0].queue, "the rule has a queue of its own")
+	assert.Nil(t, processor.rules[1].queue)
+	assert.Equal(t, 1, processor.rules[1].priority)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1", "metric_2"},
+		MetricValues: [][]float64{{1}, {2}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	assert.Len(t, mockServer.GetRequests(), 2)
+
+	// Queue depth and latency are reported per rule
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	depths := make(map[string]int64)
+	latencies := make(map[string]uint64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case "otelcol_processor_metricsinference_queue_depth":
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					rule, _ := dp.Attributes.Value(telemetryAttrRule)
+					depths[rule.AsString()] = dp.Value
+				}
+			case "otelcol_processor_metricsinference_rule_latency":
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					rule, _ := dp.Attributes.Value(telemetryAttrRule)
+					latencies[rule.AsString()] = dp.Count
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"slow": 0, "fast": 0}, depths)
+	assert.Equal(t, map[string]uint64{"slow": 1, "fast": 1}, latencies)
+}
+
+func TestInferenceQueueDisabled(t *testing.T) {
+	assert.Nil(t, newInferenceQueue(QueueConfig{}, func(string, int64) {}))
+}
+
+func TestQueuedInference(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Queue:              QueueConfig{QueueSize: 4, Workers: 2},
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "scorer", Inputs: []string{"metric_2"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	reader := sdkmetric.NewManualReader()
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1", "metric_2"},
+		MetricValues: [][]float64{{1}, {2}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Len(t, mockServer.GetRequests(), 2)
+	assert.Equal(t, 4, sink.AllMetrics()[0].MetricCount())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	found := false
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "otelcol_processor_metricsinference_queue_depth" {
+				found = true
+				assert.Equal(t, int64(0), m.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+			}
+		}
+	}
+	assert.True(t, found, "queue depth should be reported")
+}
+
+func TestSkipReasonQueueFull(t *testing.T) {
+	mp := &metricsinferenceprocessor{config: &Config{}}
+	call := &ruleCall{ctx: &modelContext{}}
+	assert.Equal(t, skipReasonQueueFull, mp.skipReason(context.Background(), call, errQueueFull))
+}
+
+func TestValidateQueueConfig(t *testing.T) {
+	for _, policy := range []string{"", "block", "drop_oldest", "drop_newest"} {
+		assert.NoError(t, validateQueueConfig(QueueConfig{QueueSize: 10, DropPolicy: policy}))
+	}
+	assert.ErrorContains(t, validateQueueConfig(QueueConfig{DropPolicy: "drop_random"}), "invalid drop_policy")
+	assert.ErrorContains(t, validateQueueConfig(QueueConfig{QueueSize: -1}), "queue_size")
+	assert.ErrorContains(t, validateQueueConfig(QueueConfig{Workers: -1}), "workers")
+	assert.ErrorContains(t, validateQueueConfig(QueueConfig{MaxWait: -time.Second}), "max_wait")
+
+	// A rule's own queue must hold calls
+	assert.NoError(t, validateScheduling(Rule{Queue: &QueueConfig{QueueSize: 1}, Priority: -1}))
+	assert.ErrorContains(t, validateScheduling(Rule{Queue: &QueueConfig{}}), "queue.queue_size must be positive")
+	assert.ErrorContains(t, validateScheduling(Rule{Queue: &QueueConfig{QueueSize: 1, DropPolicy: "lifo"}}), "invalid queue: invalid drop_policy")
+}
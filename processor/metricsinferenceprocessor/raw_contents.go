@@ -0,0 +1,187 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// encodeRawContents encodes the typed contents of a tensor as raw contents: its
+// elements in row-major order, little-endian, with every BYTES element prefixed
+// by its 4-byte length. FP16 and BF16 tensors take their values from the float
+// contents.
+func encodeRawContents(datatype string, contents *pb.InferTensorContents) ([]byte, error) {
+	if contents == nil {
+		return []byte{}, nil
+	}
+
+	var raw []byte
+	switch datatype {
+	case "BOOL":
+		for _, v := range contents.BoolContents {
+			if v {
+				raw = append(raw, 1)
+			} else {
+				raw = append(raw, 0)
+			}
+		}
+	case "INT8":
+		for _, v := range contents.IntContents {
+			raw = append(raw, byte(int8(v)))
+		}
+	case "INT16":
+		for _, v := range contents.IntContents {
+			raw = binary.LittleEndian.AppendUint16(raw, uint16(int16(v)))
+		}
+	case "INT32":
+		for _, v := range contents.IntContents {
+			raw = binary.LittleEndian.AppendUint32(raw, uint32(v))
+		}
+	case "INT64":
+		for _, v := range contents.Int64Contents {
+			raw = binary.LittleEndian.AppendUint64(raw, uint64(v))
+		}
+	case "UINT8":
+		for _, v := range contents.UintContents {
+			raw = append(raw, byte(v))
+		}
+	case "UINT16":
+		for _, v := range contents.UintContents {
+			raw = binary.LittleEndian.AppendUint16(raw, uint16(v))
+		}
+	case "UINT32":
+		for _, v := range contents.UintContents {
+			raw = binary.LittleEndian.AppendUint32(raw, v)
+		}
+	case "UINT64":
+		for _, v := range contents.Uint64Contents {
+			raw = binary.LittleEndian.AppendUint64(raw, v)
+		}
+	case dataTypeFP16, dataTypeBF16:
+		convert := float32ToFP16
+		if datatype == dataTypeBF16 {
+			convert = float32ToBF16
+		}
+		for _, v := range contents.Fp64Contents {
+			raw = binary.LittleEndian.AppendUint16(raw, convert(float32(v)))
+		}
+		for _, v := range contents.Fp32Contents {
+			raw = binary.LittleEndian.AppendUint16(raw, convert(v))
+		}
+	case "FP32":
+		for _, v := range contents.Fp32Contents {
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+		}
+	case "FP64":
+		for _, v := range contents.Fp64Contents {
+			raw = binary.LittleEndian.AppendUint64(raw, math.Float64bits(v))
+		}
+	case "BYTES":
+		for _, v := range contents.BytesContents {
+			raw = binary.LittleEndian.AppendUint32(raw, uint32(len(v)))
+			raw = append(raw, v...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported datatype %q", datatype)
+	}
+	if raw == nil {
+		raw = []byte{}
+	}
+	return raw, nil
+}
+
+// rawElementSizes are the sizes in bytes of the elements of fixed-size datatypes
+var rawElementSizes = map[string]int{
+	"BOOL": 1, "INT8": 1, "UINT8": 1,
+	"INT16": 2, "UINT16": 2, dataTypeFP16: 2, dataTypeBF16: 2,
+	"INT32": 4, "UINT32": 4, "FP32": 4,
+	"INT64": 8, "UINT64": 8, "FP64": 8,
+}
+
+// decodeRawContents decodes the raw contents of a tensor to typed contents.
+// FP16 and BF16 elements are widened to FP32 contents, which hold them exactly.
+func decodeRawContents(datatype string, raw []byte) (*pb.InferTensorContents, error) {
+	contents := &pb.InferTensorContents{}
+	if datatype == "BYTES" {
+		for len(raw) > 0 {
+			if len(raw) < 4 {
+				return nil, fmt.Errorf("truncated BYTES element length")
+			}
+			n := binary.LittleEndian.Uint32(raw)
+			raw = raw[4:]
+			if uint64(n) > uint64(len(raw)) {
+				return nil, fmt.Errorf("BYTES element of %d bytes exceeds the remaining %d bytes", n, len(raw))
+			}
+			contents.BytesContents = append(contents.BytesContents, raw[:n])
+			raw = raw[n:]
+		}
+		return contents, nil
+	}
+
+	size, ok := rawElementSizes[datatype]
+	if !ok {
+		return nil, fmt.Errorf("unsupported datatype %q", datatype)
+	}
+	if len(raw)%size != 0 {
+		return nil, fmt.Errorf("%d bytes of raw contents is not a whole number of %s elements", len(raw), datatype)
+	}
+
+	for i := 0; i < len(raw); i += size {
+		element := raw[i : i+size]
+		switch datatype {
+		case "BOOL":
+			contents.BoolContents = append(contents.BoolContents, element[0] != 0)
+		case "INT8":
+			contents.IntContents = append(contents.IntContents, int32(int8(element[0])))
+		case "INT16":
+			contents.IntContents = append(contents.IntContents, int32(int16(binary.LittleEndian.Uint16(element))))
+		case "INT32":
+			contents.IntContents = append(contents.IntContents, int32(binary.LittleEndian.Uint32(element)))
+		case "INT64":
+			contents.Int64Contents = append(contents.Int64Contents, int64(binary.LittleEndian.Uint64(element)))
+		case "UINT8":
+			contents.UintContents = append(contents.UintContents, uint32(element[0]))
+		case "UINT16":
+			contents.UintContents = append(contents.UintContents, uint32(binary.LittleEndian.Uint16(element)))
+		case "UINT32":
+			contents.UintContents = append(contents.UintContents, binary.LittleEndian.Uint32(element))
+		case "UINT64":
+			contents.Uint64Contents = append(contents.Uint64Contents, binary.LittleEndian.Uint64(element))
+		case dataTypeFP16:
+			contents.Fp32Contents = append(contents.Fp32Contents, fp16ToFloat32(binary.LittleEndian.Uint16(element)))
+		case dataTypeBF16:
+			contents.Fp32Contents = append(contents.Fp32Contents, bf16ToFloat32(binary.LittleEndian.Uint16(element)))
+		case "FP32":
+			contents.Fp32Contents = append(contents.Fp32Contents, math.Float32frombits(binary.LittleEndian.Uint32(element)))
+		case "FP64":
+			contents.Fp64Contents = append(contents.Fp64Contents, math.Float64frombits(binary.LittleEndian.Uint64(element)))
+		}
+	}
+	return contents, nil
+}
+
+// decodeRawOutputs moves the raw output contents of a response, which Triton
+// uses for all outputs and servers use for half precision, into the typed
+// contents of its outputs, so outputs decode the same whichever the server sent
+func decodeRawOutputs(response *pb.ModelInferResponse) error {
+	if len(response.RawOutputContents) == 0 {
+		return nil
+	}
+	if len(response.RawOutputContents) != len(response.Outputs) {
+		return fmt.Errorf("response has %d raw output contents for %d outputs", len(response.RawOutputContents), len(response.Outputs))
+	}
+	for i, output := range response.Outputs {
+		contents, err := decodeRawContents(output.Datatype, response.RawOutputContents[i])
+		if err != nil {
+			return fmt.Errorf("failed to decode raw contents of output %q: %w", output.Name, err)
+		}
+		output.Contents = contents
+	}
+	response.RawOutputContents = nil
+	return nil
+}
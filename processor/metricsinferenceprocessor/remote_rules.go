@@ -0,0 +1,384 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/opampcustommessages"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// defaultRemoteRulesCapability is the custom capability remote rules are
+// exchanged under when none is configured
+const defaultRemoteRulesCapability = "io.opentelemetry.inference.rules"
+
+// Types of the custom messages of the remote rules capability
+const (
+	remoteRulesMessageType       = "rules"        // From the server: the remote rules, as YAML or JSON under a "rules" key
+	remoteRulesStatusMessageType = "rules_status" // To the server: the outcome of applying them
+)
+
+// Statuses of received remote rules, named like OpAMP's remote config statuses
+const (
+	remoteRulesApplied = "APPLIED"
+	remoteRulesFailed  = "FAILED"
+)
+
+// remoteRulesStatus is the outcome of applying received remote rules, sent back
+// to the server
+type remoteRulesStatus struct {
+	Hash         string `json:"hash"` // SHA-256 of the message the rules were received in
+	Status       string `json:"status"`
+	Rules        int    `json:"rules"` // Remote rules in use
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// remoteRules tracks the custom capability rules are received through
+type remoteRules struct {
+	handler opampcustommessages.CustomCapabilityHandler
+	local   int    // Number of local rules, the remote rules follow them
+	applied string // Hash of the applied remote rules, empty before any were applied
+	count   int    // Number of remote rules in use
+
+	cancel context.CancelFunc // Stops receiving messages
+	done   chan struct{}      // Closed when receiving messages stopped
+}
+
+// validateRemoteRules checks the remote_rules settings
+func validateRemoteRules(cfg *RemoteRulesConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Extension == (component.ID{}) {
+		return errors.New("extension is required")
+	}
+	return nil
+}
+
+// startRemoteRules registers the remote rules capability with the configured
+// OpAMP extension and applies the rules the server sends, one message at a time
+func (mp *metricsinferenceprocessor) startRemoteRules(host component.Host) error {
+	cfg := mp.config.RemoteRules
+	if cfg == nil {
+		return nil
+	}
+	if host == nil {
+		return fmt.Errorf("opamp extension %s not found", cfg.Extension)
+	}
+	ext, found := host.GetExtensions()[cfg.Extension]
+	if !found {
+		return fmt.Errorf("opamp extension %s not found", cfg.Extension)
+	}
+	registry, ok := ext.(opampcustommessages.CustomCapabilityRegistry)
+	if !ok {
+		return fmt.Errorf("extension %s does not support OpAMP custom capabilities", cfg.Extension)
+	}
+	capability := cfg.Capability
+	if capability == "" {
+		capability = defaultRemoteRulesCapability
+	}
+	handler, err := registry.Register(capability)
+	if err != nil {
+		return fmt.Errorf("failed to register capability %s: %w", capability, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	remote := &remoteRules{
+		handler: handler,
+		local:   len(mp.config.Rules),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	mp.remote = remote
+	mp.logger.Info("Receiving remote rules", zap.String("extension", cfg.Extension.String()), zap.String("capability", capability))
+
+	go func() {
+		defer close(remote.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-handler.Message():
+				if !ok {
+					return
+				}
+				if msg.Type != remoteRulesMessageType {
+					mp.logger.Debug("Ignoring custom message of unknown type", zap.String("type", msg.Type))
+					continue
+				}
+				mp.receiveRemoteRules(ctx, msg.Data)
+			}
+		}
+	}()
+	return nil
+}
+
+// stopRemoteRules stops receiving remote rules and unregisters the capability.
+// The remote rules in use keep running.
+func (mp *metricsinferenceprocessor) stopRemoteRules() {
+	if mp.remote == nil {
+		return
+	}
+	mp.remote.cancel()
+	<-mp.remote.done
+	mp.remote.handler.Unregister()
+	mp.remote = nil
+}
+
+// receiveRemoteRules applies the remote rules of a message and reports the
+// outcome to the server. Rules failing validation are rejected as a whole and
+// the rules in use are kept. A message identical to the applied one is
+// acknowledged without applying it again, so its rules keep their state.
+func (mp *metricsinferenceprocessor) receiveRemoteRules(ctx context.Context, data []byte) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	status := remoteRulesStatus{Hash: hash, Status: remoteRulesApplied}
+
+	if hash != mp.remote.applied {
+		rules, err := decodeRules(data, "remote rules")
+		if err == nil {
+			err = mp.applyRemoteRules(ctx, rules)
+		}
+		if err != nil {
+			mp.logger.Error("Rejected remote rules, keeping the rules in use", zap.String("hash", hash), zap.Error(err))
+			status.Status = remoteRulesFailed
+			status.ErrorMessage = err.Error()
+		} else {
+			mp.logger.Info("Applied remote rules", zap.String("hash", hash), zap.Int("rules", len(rules)))
+			mp.remote.applied = hash
+			mp.remote.count = len(rules)
+		}
+	}
+	status.Rules = mp.remote.count
+	mp.sendRemoteRulesStatus(ctx, status)
+}
+
+// sendRemoteRulesStatus sends the outcome of applying remote rules to the
+// server, waiting for custom messages pending before it to be sent
+func (mp *metricsinferenceprocessor) sendRemoteRulesStatus(ctx context.Context, status remoteRulesStatus) {
+	payload, err := json.Marshal(status)
+	if err != nil {
+		mp.logger.Warn("Failed to encode remote rules status", zap.Error(err))
+		return
+	}
+	for {
+		sent, err := mp.remote.handler.SendMessage(remoteRulesStatusMessageType, payload)
+		if errors.Is(err, types.ErrCustomMessagePending) {
+			select {
+			case <-sent:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			mp.logger.Warn("Failed to send remote rules status", zap.Error(err))
+		}
+		return
+	}
+}
+
+// remoteRulesConfig returns the configuration with the local rules followed by
+// remote rules, after checking that the remote rules conflict with no other rule
+// and that the configuration is valid
+func (mp *metricsinferenceprocessor) remoteRulesConfig(remote []Rule) (*Config, error) {
+	rules := slices.Clip(mp.config.Rules[:mp.remote.local])
+	sources := make([]ruleSource, len(rules))
+	for i := range rules {
+		sources[i] = ruleSource{file: "the local rules", index: i}
+	}
+	for i, rule := range (&Config{Rules: remote}).withPresets().Rules {
+		source := ruleSource{file: "the remote rules", index: i}
+		if err := checkRuleConflicts(rules, sources, rule, source); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+		sources = append(sources, source)
+	}
+
+	candidate := *mp.config
+	candidate.Rules = rules
+	if err := candidate.validate(); err != nil {
+		return nil, err
+	}
+	return &candidate, nil
+}
+
+// applyRemoteRules replaces the remote rules in use. The new rules are built
+// and validated first, then completed with the metadata of their models on
+// copies of the local rules while no batch is processed, and swapped in only
+// once their graph is built; when the rules cannot run together, as when their
+// dependencies form a cycle, the previous rules are kept untouched. Replaced
+// remote rules lose their state, while local rules keep theirs.
+func (mp *metricsinferenceprocessor) applyRemoteRules(ctx context.Context, remote []Rule) error {
+	local := mp.remote.local
+	candidate, err := mp.remoteRulesConfig(remote)
+	if err != nil {
+		return err
+	}
+	rules := buildInternalConfig(candidate)
+	for ruleIdx, rule := range candidate.Rules[local:] {
+		if rule.Queue != nil {
+			rules[local+ruleIdx].queue = newInferenceQueue(*rule.Queue, mp.recordQueueDepth)
+		}
+	}
+	if err := resolveRuleGates(candidate, rules); err != nil {
+		return err
+	}
+
+	// Triggers read the client under mp.lock, so they are stopped before taking it
+	mp.stopTriggers()
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	defer mp.startTriggers()
+
+	// Processors without a client read the rules outside the metadata lock
+	client := mp.grpcClient
+	if client == nil {
+		return errors.New("remote rules need a connection to an inference server, which the processor does not have")
+	}
+
+	// The refresh resolves model selectors outside the metadata lock
+	if mp.refreshCancel != nil {
+		mp.stopMetadataRefresh()
+		defer mp.startMetadataRefresh(client)
+	}
+
+	mp.metadataLock.Lock()
+	defer mp.metadataLock.Unlock()
+
+	for ruleIdx := range local {
+		rules[ruleIdx] = mp.rules[ruleIdx].clone()
+	}
+	previous := mp.rules
+	mp.rules = rules
+
+	// Until a lazy connection succeeds, completing it discovers the metadata
+	if mp.lazyConnection == nil || mp.lazyConnection.connected.Load() {
+		metadataCtx, cancel := context.WithTimeout(ctx, mp.requestTimeout())
+		mp.applySelectedModels(mp.selectModels(metadataCtx, client))
+		mp.queryMissingMetadata(metadataCtx, client)
+		cancel()
+	}
+	mp.mergeDiscoveredInputs()
+	mp.checkInputMaps()
+	mp.mergeDiscoveredOutputs()
+
+	if err := mp.updateRuleGraph(); err != nil {
+		mp.rules = previous
+		return err
+	}
+	mp.config.Rules = candidate.Rules
+
+	mp.forgetRuleState(ctx, local, previous[local:])
+	triggers := newIntervalTriggers(mp.rules)
+	for ruleIdx, trigger := range mp.triggers {
+		if ruleIdx < local {
+			triggers[ruleIdx] = trigger
+		}
+	}
+	mp.triggers = triggers
+	return nil
+}
+
+// clone returns a copy of a rule whose inputs and outputs can be completed from
+// metadata without changing the rule. State such as transforms and backends is
+// shared, so the copy carries on where the rule left off.
+func (r internalRule) clone() internalRule {
+	r.inputs = slices.Clone(r.inputs)
+	r.inputSelectors = slices.Clone(r.inputSelectors)
+	r.inputTensors = maps.Clone(r.inputTensors)
+	r.outputs = slices.Clone(r.outputs)
+	return r
+}
+
+// queryMissingMetadata queries and caches the metadata of the server models of
+// the rules that is not cached yet. The caller must hold mp.metadataLock for
+// writing.
+func (mp *metricsinferenceprocessor) queryMissingMetadata(ctx context.Context, client pb.GRPCInferenceServiceClient) {
+	models := mp.serverModels()
+	for modelName := range models {
+		if _, cached := mp.modelMetadata[modelName]; cached {
+			delete(models, modelName)
+		}
+	}
+	if len(models) == 0 {
+		return
+	}
+
+	fetched, failed := mp.fetchModelsMetadata(ctx, client, models)
+	for modelName, err := range failed {
+		mp.logger.Warn("Failed to query metadata for model of remote rules",
+			zap.String("model", modelName),
+			zap.Error(err))
+	}
+	for modelName, resp := range fetched {
+		mp.modelMetadata[modelName] = newModelMetadata(resp)
+	}
+}
+
+// forgetRuleState drops the state kept for the rules from index from on, which
+// were replaced, so that rules taking their indexes start without it. Sequences
+// of replaced rules are not ended on the server, whose slots expire. The caller
+// must hold mp.metadataLock for writing.
+func (mp *metricsinferenceprocessor) forgetRuleState(ctx context.Context, from int, replaced []internalRule) {
+	for _, rule := range replaced {
+		if rule.autoDisable == nil || !rule.autoDisable.disabled() {
+			continue
+		}
+		mp.telemetry.recordAutoDisabledRule(ctx, rule.id, -1)
+		if mp.autoDisabledRules.Add(-1) == 0 {
+			mp.reportStatus(componentstatus.NewEvent(componentstatus.StatusOK))
+		}
+	}
+
+	mp.activationLock.Lock()
+	for ruleIdx := range mp.activations {
+		if ruleIdx >= from {
+			delete(mp.activations, ruleIdx)
+		}
+	}
+	mp.activationLock.Unlock()
+
+	mp.expansionLock.Lock()
+	for ruleIdx := range mp.expansions {
+		if ruleIdx >= from {
+			delete(mp.expansions, ruleIdx)
+		}
+	}
+	mp.expansionLock.Unlock()
+
+	mp.sequenceLock.Lock()
+	for ruleIdx := range mp.sequences {
+		if ruleIdx >= from {
+			delete(mp.sequences, ruleIdx)
+		}
+	}
+	mp.sequenceLock.Unlock()
+
+	mp.attributeIndexLock.Lock()
+	for key := range mp.attributeIndexes {
+		if key.ruleIdx >= from {
+			delete(mp.attributeIndexes, key)
+		}
+	}
+	mp.attributeIndexLock.Unlock()
+
+	mp.lastValues.forgetRules(from)
+	mp.cumulative.forgetRules(from)
+	mp.seriesLimiter.forgetRules(from)
+}
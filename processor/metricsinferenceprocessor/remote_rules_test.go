@@ -0,0 +1,330 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/opampcustommessages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// customMessages is an OpAMP extension relaying custom messages of a single
+// capability between a test and the processor
+type customMessages struct {
+	component.StartFunc
+	component.ShutdownFunc
+
+	capability   string
+	received     chan *protobufs.CustomMessage // Messages from the server
+	sent         chan *protobufs.CustomMessage // Messages to the server
+	unregistered bool
+}
+
+func newCustomMessages() *customMessages {
+	return &customMessages{
+		received: make(chan *protobufs.CustomMessage),
+		sent:     make(chan *protobufs.CustomMessage, 10),
+	}
+}
+
+func (m *customMessages) Register(capability string, _ ...opampcustommessages.CustomCapabilityRegisterOption) (opampcustommessages.CustomCapabilityHandler, error) {
+	m.capability = capability
+	return m, nil
+}
+
+func (m *customMessages) Message() <-chan *protobufs.CustomMessage {
+	return m.received
+}
+
+func (m *customMessages) SendMessage(messageType string, message []byte) (chan struct{}, error) {
+	m.sent <- &protobufs.CustomMessage{Capability: m.capability, Type: messageType, Data: message}
+	done := make(chan struct{})
+	close(done)
+	return done, nil
+}
+
+func (m *customMessages) Unregister() {
+	m.unregistered = true
+}
+
+// push sends rules from the server and returns the status the processor reports
+func (m *customMessages) push(t *testing.T, rules string) remoteRulesStatus {
+	m.received <- &protobufs.CustomMessage{Capability: m.capability, Type: remoteRulesMessageType, Data: []byte(rules)}
+	select {
+	case msg := <-m.sent:
+		require.Equal(t, remoteRulesStatusMessageType, msg.Type)
+		var status remoteRulesStatus
+		require.NoError(t, json.Unmarshal(msg.Data, &status))
+		return status
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no remote rules status reported")
+		return remoteRulesStatus{}
+	}
+}
+
+func TestValidateRemoteRules(t *testing.T) {
+	assert.NoError(t, validateRemoteRules(nil))
+	assert.NoError(t, validateRemoteRules(&RemoteRulesConfig{Extension: component.MustNewID("opamp")}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		RemoteRules:        &RemoteRulesConfig{},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid remote_rules: extension is required")
+}
+
+func TestRemoteRules(t *testing.T) {
+	opampID := component.MustNewID("opamp")
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		RemoteRules:        &RemoteRulesConfig{Extension: opampID},
+		Rules: []Rule{{
+			ModelName:     "adder",
+			Inputs:        []string{"cpu", "memory"},
+			OutputPattern: "local.{output}",
+			Outputs:       []OutputSpec{{Name: "sum"}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	extension := newCustomMessages()
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.injectedClient = &rowSumClient{}
+	require.NoError(t, processor.Start(context.Background(), extensionsHost{opampID: extension}))
+	assert.Equal(t, defaultRemoteRulesCapability, extension.capability)
+
+	// consume returns the names of the metrics produced from a batch
+	consume := func() []string {
+		sink.Reset()
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"cpu", "memory"},
+			MetricValues: [][]float64{{1}, {2}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+		var names []string
+		metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			names = append(names, metrics.At(i).Name())
+		}
+		return names
+	}
+
+	remote := `
+rules:
+  - model_name: doubler
+    inputs: [local.sum]
+    output_pattern: remote.{output}
+    outputs:
+      - name: sum
+`
+	status := extension.push(t, remote)
+	assert.Equal(t, remoteRulesApplied, status.Status)
+	assert.Equal(t, 1, status.Rules)
+	assert.Len(t, status.Hash, 64)
+	assert.Contains(t, consume(), "remote.sum", "remote rules run after the local rules they consume")
+	remoteSum := findMetricByName(sink.AllMetrics()[0], "remote.sum")
+	require.Equal(t, pmetric.MetricTypeGauge, remoteSum.Type())
+	assert.Equal(t, 3.0, remoteSum.Gauge().DataPoints().At(0).DoubleValue())
+
+	// Invalid rules are rejected as a whole and the rules in use are kept
+	status = extension.push(t, `
+rules:
+  - model_name: scorer
+    inputs: [cpu]
+  - model_name: adder
+    inputs: [cpu, memory]
+`)
+	assert.Equal(t, remoteRulesFailed, status.Status)
+	assert.Contains(t, status.ErrorMessage, "rule 1 of the remote rules duplicates rule 0 of the local rules")
+	assert.Equal(t, 1, status.Rules)
+	assert.Contains(t, consume(), "remote.sum")
+
+	status = extension.push(t, `rules: [{model_name: scorer, unknown: true}]`)
+	assert.Equal(t, remoteRulesFailed, status.Status)
+	assert.Contains(t, status.ErrorMessage, "invalid remote rules")
+
+	status = extension.push(t, `rules: [{model_name: scorer, inputs: [cpu], on_error: ignore}]`)
+	assert.Equal(t, remoteRulesFailed, status.Status)
+	assert.Contains(t, status.ErrorMessage, "rule 1")
+
+	// The applied rules are acknowledged again without being replaced
+	processor.activations[1] = 42
+	status = extension.push(t, remote)
+	assert.Equal(t, remoteRulesApplied, status.Status)
+	assert.Equal(t, 1, status.Rules)
+	assert.Contains(t, processor.activations, 1)
+
+	// An empty set removes the remote rules and forgets their state
+	status = extension.push(t, `rules: []`)
+	assert.Equal(t, remoteRulesApplied, status.Status)
+	assert.Equal(t, 0, status.Rules)
+	assert.NotContains(t, processor.activations, 1)
+	assert.ElementsMatch(t, []string{"cpu", "memory", "local.sum"}, consume())
+
+	require.NoError(t, processor.Shutdown(context.Background()))
+	assert.True(t, extension.unregistered)
+}
+
+func TestRemoteRulesAppliedAtomically(t *testing.T) {
+	opampID := component.MustNewID("opamp")
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		RemoteRules:        &RemoteRulesConfig{Extension: opampID},
+		Rules: []Rule{{
+			ModelName:     "adder",
+			Inputs:        []string{"cpu", "memory"},
+			OutputPattern: "local.{output}",
+			Outputs:       []OutputSpec{{Name: "sum"}},
+		}},
+	}
+
+	extension := newCustomMessages()
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.injectedClient = &rowSumClient{}
+	require.NoError(t, processor.Start(context.Background(), extensionsHost{opampID: extension}))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Metadata arriving with the update describes the local rule's output
+	processor.metadataLock.Lock()
+	processor.modelMetadata["adder"] = newModelMetadata(&pb.ModelMetadataResponse{
+		Name: "adder",
+		Outputs: []*pb.ModelMetadataResponse_TensorMetadata{{
+			Name:       "sum",
+			Datatype:   "FP64",
+			Parameters: map[string]*pb.InferParameter{metadataParamDescription: {ParameterChoice: &pb.InferParameter_StringParam{StringParam: "Sum of the inputs"}}},
+		}},
+	})
+	processor.metadataLock.Unlock()
+
+	// Rules consuming each other's outputs are rejected, leaving the rules in use as they were
+	status := extension.push(t, `
+rules:
+  - model_name: doubler
+    inputs: [remote.b.sum]
+    output_pattern: remote.a.{output}
+    outputs: [{name: sum}]
+  - model_name: tripler
+    inputs: [remote.a.sum]
+    output_pattern: remote.b.{output}
+    outputs: [{name: sum}]
+`)
+	assert.Equal(t, remoteRulesFailed, status.Status)
+	assert.Contains(t, status.ErrorMessage, "dependency cycle")
+	processor.metadataLock.RLock()
+	require.Len(t, processor.rules, 1)
+	assert.Empty(t, processor.rules[0].outputs[0].published.description)
+	assert.Len(t, processor.config.Rules, 1)
+	processor.metadataLock.RUnlock()
+
+	// Applied rules replace the processor's configuration, not the collector's
+	status = extension.push(t, `
+rules:
+  - model_name: doubler
+    inputs: [local.sum]
+    output_pattern: remote.{output}
+    outputs: [{name: sum}]
+`)
+	assert.Equal(t, remoteRulesApplied, status.Status)
+	processor.metadataLock.RLock()
+	assert.Len(t, processor.config.Rules, 2)
+	assert.Equal(t, "Sum of the inputs", processor.rules[0].outputs[0].published.description)
+	processor.metadataLock.RUnlock()
+	assert.Len(t, cfg.Rules, 1)
+}
+
+func TestRemoteRulesAppliedWhileConsuming(t *testing.T) {
+	opampID := component.MustNewID("opamp")
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		RemoteRules:        &RemoteRulesConfig{Extension: opampID},
+		Rules: []Rule{{
+			ModelName:     "adder",
+			Inputs:        []string{"cpu", "memory"},
+			OutputPattern: "local.{output}",
+			Outputs:       []OutputSpec{{Name: "sum"}},
+			Trigger:       TriggerConfig{Mode: "interval", Every: time.Millisecond},
+		}},
+	}
+
+	extension := newCustomMessages()
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.injectedClient = &rowSumClient{}
+	require.NoError(t, processor.Start(context.Background(), extensionsHost{opampID: extension}))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Batches keep flowing, with interval outputs, while the triggers are
+	// replaced; run with -race to check they are not read unguarded
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			input := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"cpu", "memory"},
+				MetricValues: [][]float64{{1}, {2}},
+			})
+			assert.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		status := extension.push(t, `
+rules:
+  - model_name: doubler
+    inputs: [cpu]
+    output_pattern: remote.{output}
+    outputs: [{name: sum}]
+    trigger: {mode: interval, every: 1ms}
+`)
+		assert.Equal(t, remoteRulesApplied, status.Status)
+		status = extension.push(t, `rules: []`)
+		assert.Equal(t, remoteRulesApplied, status.Status)
+	}
+	close(stop)
+	<-done
+}
+
+func TestRemoteRulesMissingExtension(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		RemoteRules:        &RemoteRulesConfig{Extension: component.MustNewID("opamp")},
+		Rules:              []Rule{{ModelName: "adder", Inputs: []string{"cpu"}}},
+	}
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.injectedClient = &rowSumClient{}
+	assert.ErrorContains(t, processor.Start(context.Background(), extensionsHost{}), "opamp extension opamp not found")
+	assert.NoError(t, processor.Shutdown(context.Background()))
+
+	processor, err = newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	host := extensionsHost{component.MustNewID("opamp"): &memoryStorage{}}
+	assert.ErrorContains(t, processor.Start(context.Background(), host), "does not support OpAMP custom capabilities")
+}
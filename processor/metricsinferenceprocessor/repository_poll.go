@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// startRepositoryPoll polls the inference server's model repository in the
+// background at the configured interval to detect models being unloaded and
+// loaded again. It is a no-op when polling is disabled or the server does not
+// support the model repository extension.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) startRepositoryPoll(client pb.GRPCInferenceServiceClient) {
+	interval := mp.config.Metadata.RepositoryPollInterval
+	if interval <= 0 || client == nil {
+		return
+	}
+	if !mp.supportsExtension(extensionModelRepository) {
+		mp.logger.Warn("Inference server does not support the model repository extension, model unloads are not detected")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	mp.pollCancel = cancel
+	mp.pollDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mp.pollModelRepository(ctx, client)
+			}
+		}
+	}()
+}
+
+// stopRepositoryPoll stops the background repository poll and waits for it to exit.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) stopRepositoryPoll() {
+	if mp.pollCancel == nil {
+		return
+	}
+	mp.pollCancel()
+	<-mp.pollDone
+	mp.pollCancel = nil
+	mp.pollDone = nil
+}
+
+// modelReady reports whether a model is among the ready models of a repository
+// index, in the pinned version when one is configured
+func modelReady(models []*pb.RepositoryIndexResponse_ModelIndex, modelName, modelVersion string) bool {
+	for _, entry := range models {
+		if entry.Name == modelName && (modelVersion == "" || entry.Version == "" || entry.Version == modelVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// pollModelRepository lists the ready models of the server's repository. Server
+// models missing from it were unloaded: their cached metadata is invalidated and
+// their rules skip inference until they are ready again, when their metadata is
+// fetched anew. A failed listing changes nothing.
+func (mp *metricsinferenceprocessor) pollModelRepository(ctx context.Context, client pb.GRPCInferenceServiceClient) {
+	resp, err := mp.fetchRepositoryIndex(ctx, client, "")
+	if err != nil {
+		mp.logger.Debug("Failed to list the models of the repository", zap.Error(err))
+		return
+	}
+
+	mp.metadataLock.RLock()
+	models := mp.serverModels()
+	var unloaded []string
+	reloaded := make(map[string]string) // model name -> version
+	for modelName, modelVersion := range models {
+		ready := modelReady(resp.Models, modelName, modelVersion)
+		switch {
+		case !ready && !mp.unloadedModels[modelName]:
+			unloaded = append(unloaded, modelName)
+		case ready && mp.unloadedModels[modelName]:
+			reloaded[modelName] = modelVersion
+		}
+	}
+	mp.metadataLock.RUnlock()
+	if len(unloaded) == 0 && len(reloaded) == 0 {
+		return
+	}
+
+	// Metadata of reloaded models is fetched again, as the model may have changed
+	fetched, failed := mp.fetchModelsMetadata(ctx, client, reloaded)
+	for modelName, err := range failed {
+		mp.logger.Debug("Failed to fetch metadata of reloaded model, retrying at the next poll",
+			zap.String("model", modelName),
+			zap.Error(err))
+	}
+
+	mp.metadataLock.Lock()
+	defer mp.metadataLock.Unlock()
+
+	for _, modelName := range unloaded {
+		mp.unloadedModels[modelName] = true
+		delete(mp.modelMetadata, modelName)
+		mp.logger.Info("Model unloaded from the inference server, skipping its rules until it is loaded again",
+			zap.String("model", modelName))
+	}
+	for modelName := range fetched {
+		delete(mp.unloadedModels, modelName)
+		mp.logger.Info("Model loaded again on the inference server, resuming its rules",
+			zap.String("model", modelName))
+	}
+	if len(fetched) > 0 {
+		mp.applyModelMetadata(ctx, fetched)
+	}
+}
+
+// modelUnloaded reports whether the model of a rule was unloaded from the server.
+// The caller must hold mp.metadataLock.
+func (mp *metricsinferenceprocessor) modelUnloaded(rule internalRule) bool {
+	return !rule.inProcess() && mp.unloadedModels[rule.modelName]
+}
@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestPollModelRepository(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", scorerMetadata("1", "calculated_output")),
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 0.5)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{output}"},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	consume := func() float64 {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"metric_1"}, MetricValues: [][]float64{{1}}})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+		output := findMetricByName(sink.AllMetrics()[len(sink.AllMetrics())-1], "calculated_output")
+		if output.Name() == "" {
+			return -1
+		}
+		return output.Gauge().DataPoints().At(0).DoubleValue()
+	}
+	require.Equal(t, 0.5, consume())
+
+	// An unloaded model's metadata is invalidated and its rules stop calling the server
+	mockServer.SetModelUnloaded("scorer", true)
+	processor.pollModelRepository(context.Background(), processor.grpcClient)
+	assert.True(t, processor.unloadedModels["scorer"])
+	assert.NotContains(t, processor.modelMetadata, "scorer")
+	requests := len(mockServer.GetRequests())
+	assert.Equal(t, -1.0, consume())
+	assert.Len(t, mockServer.GetRequests(), requests, "no inference while the model is unloaded")
+
+	// Once loaded again, the model's metadata is fetched anew and its rules resume
+	mockServer.SetModelMetadata("scorer", scorerMetadata("2", "calculated_output"))
+	mockServer.SetModelUnloaded("scorer", false)
+	processor.pollModelRepository(context.Background(), processor.grpcClient)
+	assert.Empty(t, processor.unloadedModels)
+	require.Contains(t, processor.modelMetadata, "scorer")
+	assert.Equal(t, []string{"2"}, processor.modelMetadata["scorer"].versions)
+	assert.Equal(t, 0.5, consume())
+}
+
+func TestRepositoryPollLoop(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		polls      bool
+	}{
+		{name: "polls", extensions: []string{"health_check", "model_repository"}, polls: true},
+		{name: "without model repository extension", extensions: []string{"health_check"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelMetadata("scorer", scorerMetadata("1", "score")),
+				testutil.WithServerExtensions(tt.extensions...))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+				Timeout:            5,
+				Metadata:           MetadataConfig{RepositoryPollInterval: 10 * time.Millisecond},
+				Rules: []Rule{
+					{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{output}"},
+				},
+			}
+
+			processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			if !tt.polls {
+				assert.Nil(t, processor.pollCancel, "servers without the extension are not polled")
+				require.NoError(t, processor.Shutdown(context.Background()))
+				return
+			}
+
+			mockServer.SetModelUnloaded("scorer", true)
+			require.Eventually(t, func() bool {
+				processor.metadataLock.RLock()
+				defer processor.metadataLock.RUnlock()
+				return processor.unloadedModels["scorer"]
+			}, 5*time.Second, 10*time.Millisecond)
+
+			require.NoError(t, processor.Shutdown(context.Background()))
+			assert.Nil(t, processor.pollCancel, "shutdown should stop the poll")
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerPlaceholderResource prefixes placeholders resolved from resource attributes
+const headerPlaceholderResource = "resource:"
+
+// What happens to an inference call whose resource lacks an attribute of a
+// templated header
+const (
+	missingHeaderAttributesSkip = "skip" // the call is skipped for the batch
+	missingHeaderAttributesOmit = "omit" // the call is sent without the header
+)
+
+// skipReasonMissingHeaderAttributes is the reason calls are skipped for when
+// their resource lacks an attribute of a templated header
+const skipReasonMissingHeaderAttributes = "missing_header_attributes"
+
+// errMissingHeaderAttributes is returned for inference calls whose resource lacks
+// an attribute of a templated header
+var errMissingHeaderAttributes = errors.New("resource attributes of templated gRPC headers not found")
+
+// validateMissingHeaderAttributes checks the missing_header_attributes setting
+func validateMissingHeaderAttributes(value string) error {
+	switch value {
+	case "", missingHeaderAttributesSkip, missingHeaderAttributesOmit:
+		return nil
+	default:
+		return fmt.Errorf("invalid missing_header_attributes %q (must be 'skip' or 'omit')", value)
+	}
+}
+
+// headerTemplate is a header value with {resource:<attribute>} placeholders
+type headerTemplate struct {
+	literals   []string // Text around the placeholders, one more than attributes
+	attributes []string // Resource attribute of each placeholder
+}
+
+// parseHeaderTemplate parses a header value. It returns nil for values without placeholders.
+func parseHeaderTemplate(value string) (*headerTemplate, error) {
+	if !strings.Contains(value, "{") {
+		return nil, nil
+	}
+	template := &headerTemplate{}
+	rest := value
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			template.literals = append(template.literals, rest)
+			return template, nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", value)
+		}
+		placeholder := rest[start+1 : start+end]
+		attribute, ok := strings.CutPrefix(placeholder, headerPlaceholderResource)
+		if !ok || attribute == "" {
+			return nil, fmt.Errorf("invalid placeholder {%s} in %q (must be {resource:<attribute>})", placeholder, value)
+		}
+		template.literals = append(template.literals, rest[:start])
+		template.attributes = append(template.attributes, attribute)
+		rest = rest[start+end+1:]
+	}
+}
+
+// resolve renders the template with the attributes of a resource, hashed or
+// redacted by privacy. When the resource lacks one of them, it returns that
+// attribute instead.
+func (t *headerTemplate) resolve(resource pcommon.Resource, privacy *attributePrivacy) (value, missing string) {
+	var b strings.Builder
+	for i, attribute := range t.attributes {
+		attributeValue, ok := resource.Attributes().Get(attribute)
+		if !ok {
+			return "", attribute
+		}
+		b.WriteString(t.literals[i])
+		b.WriteString(privacy.value(attribute, attributeValue, true))
+	}
+	b.WriteString(t.literals[len(t.literals)-1])
+	return b.String(), ""
+}
+
+// requestHeaders holds the configured gRPC headers, split into static headers and
+// headers templated on the resource of the metrics being processed
+type requestHeaders struct {
+	static    map[string]string
+	templates map[string]*headerTemplate
+}
+
+// newRequestHeaders parses the configured headers
+func newRequestHeaders(headers map[string]string) (requestHeaders, error) {
+	h := requestHeaders{}
+	for name, value := range headers {
+		template, err := parseHeaderTemplate(value)
+		if err != nil {
+			return requestHeaders{}, fmt.Errorf("header %q: %w", name, err)
+		}
+		if template == nil {
+			if h.static == nil {
+				h.static = make(map[string]string)
+			}
+			h.static[name] = value
+			continue
+		}
+		if h.templates == nil {
+			h.templates = make(map[string]*headerTemplate)
+		}
+		h.templates[name] = template
+	}
+	return h, nil
+}
+
+// withStatic adds the static headers to an outgoing context. Templated headers are
+// left out of calls, such as health checks and metadata queries, that are not made
+// for the metrics of a resource.
+func (h requestHeaders) withStatic(ctx context.Context) context.Context {
+	if len(h.static) == 0 {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, metadata.New(h.static))
+}
+
+// withResource adds the static headers and the templated headers resolved from a
+// resource, with the privacy of the rule making the call, to an outgoing context.
+// Templated headers whose attributes the resource lacks are left out, and the
+// missing attributes are returned.
+func (h requestHeaders) withResource(ctx context.Context, resource pcommon.Resource, privacy *attributePrivacy) (context.Context, []string) {
+	if len(h.templates) == 0 {
+		return h.withStatic(ctx), nil
+	}
+	md := metadata.New(h.static)
+	var missing []string
+	for name, template := range h.templates {
+		value, attribute := template.resolve(resource, privacy)
+		if attribute != "" {
+			missing = append(missing, attribute)
+			continue
+		}
+		md.Set(name, value)
+	}
+	return metadata.NewOutgoingContext(ctx, md), missing
+}
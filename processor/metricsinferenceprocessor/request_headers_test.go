@@ -0,0 +1,172 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestParseHeaderTemplate(t *testing.T) {
+	template, err := parseHeaderTemplate("static-value")
+	require.NoError(t, err)
+	assert.Nil(t, template, "values without placeholders are static")
+
+	template, err = parseHeaderTemplate("org-{resource:tenant.id}/{resource:region}")
+	require.NoError(t, err)
+	require.NotNil(t, template)
+
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr("tenant.id", "acme")
+	resource.Attributes().PutStr("region", "eu")
+	value, missing := template.resolve(resource, nil)
+	assert.Equal(t, "org-acme/eu", value)
+	assert.Empty(t, missing)
+
+	resource.Attributes().Remove("region")
+	_, missing = template.resolve(resource, nil)
+	assert.Equal(t, "region", missing)
+
+	for _, invalid := range []string{"{resource:tenant.id", "{tenant.id}", "{resource:}"} {
+		_, err := parseHeaderTemplate(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRequestHeadersWithResource(t *testing.T) {
+	headers, err := newRequestHeaders(map[string]string{
+		"authorization": "Bearer token",
+		"x-scope-orgid": "{resource:tenant.id}",
+		"x-region":      "{resource:region}",
+	})
+	require.NoError(t, err)
+
+	// Only static headers are sent on calls not made for a resource
+	md, _ := metadata.FromOutgoingContext(headers.withStatic(context.Background()))
+	assert.Equal(t, metadata.Pairs("authorization", "Bearer token"), md)
+
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr("tenant.id", "acme")
+	ctx, missing := headers.withResource(context.Background(), resource, nil)
+	assert.Equal(t, []string{"region"}, missing)
+	md, _ = metadata.FromOutgoingContext(ctx)
+	assert.Equal(t, []string{"Bearer token"}, md.Get("authorization"))
+	assert.Equal(t, []string{"acme"}, md.Get("x-scope-orgid"))
+	assert.Empty(t, md.Get("x-region"), "headers with missing attributes are left out")
+
+	_, err = newRequestHeaders(map[string]string{"x-scope-orgid": "{tenant.id}"})
+	assert.ErrorContains(t, err, `header "x-scope-orgid"`)
+}
+
+func TestTemplatedHeadersPerTenant(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{
+			Endpoint: mockServer.Endpoint(),
+			Headers:  map[string]string{"x-scope-orgid": "{resource:tenant.id}"},
+		},
+		Timeout: 5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for _, tenant := range []string{"acme", "globex"} {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})
+		input.ResourceMetrics().At(0).Resource().Attributes().PutStr("tenant.id", tenant)
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+
+	md := mockServer.GetRequestMetadata()
+	require.Len(t, md, 2)
+	assert.Equal(t, []string{"acme"}, md[0].Get("x-scope-orgid"))
+	assert.Equal(t, []string{"globex"}, md[1].Get("x-scope-orgid"))
+}
+
+func TestTemplatedHeadersMissingAttribute(t *testing.T) {
+	for _, mode := range []string{"", missingHeaderAttributesOmit} {
+		t.Run("mode="+mode, func(t *testing.T) {
+			mockServer := testutil.StartMockServer(t,
+				testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{
+					Endpoint:                mockServer.Endpoint(),
+					Headers:                 map[string]string{"x-scope-orgid": "{resource:tenant.id}"},
+					MissingHeaderAttributes: mode,
+				},
+				Timeout: 5,
+				Rules: []Rule{{
+					ModelName:     "scorer",
+					Inputs:        []string{"metric_1"},
+					Outputs:       []OutputSpec{{Name: "score", Fallback: FallbackConfig{Policy: fallbackPolicyConstant, Value: -1}}},
+					OutputPattern: "{output}",
+				}},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := &consumertest.MetricsSink{}
+			processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			require.NoError(t, processor.Start(context.Background(), nil))
+			defer func() {
+				assert.NoError(t, processor.Shutdown(context.Background()))
+			}()
+
+			// The resource has no tenant.id
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"metric_1"},
+				MetricValues: [][]float64{{1}},
+			})))
+			score := findMetricByName(sink.AllMetrics()[0], "score")
+
+			if mode == missingHeaderAttributesOmit {
+				md := mockServer.GetRequestMetadata()
+				require.Len(t, md, 1)
+				assert.Empty(t, md[0].Get("x-scope-orgid"))
+				assert.Equal(t, 1.0, score.Gauge().DataPoints().At(0).DoubleValue())
+				return
+			}
+			assert.Empty(t, mockServer.GetRequests(), "the call is skipped rather than sent without the tenant")
+			assert.Equal(t, -1.0, score.Gauge().DataPoints().At(0).DoubleValue())
+		})
+	}
+}
+
+func TestValidateTemplatedHeaders(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{
+			Endpoint: "localhost:8001",
+			Headers:  map[string]string{"x-scope-orgid": "{tenant.id}"},
+		},
+		Rules: []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid headers")
+
+	cfg.GRPCClientSettings.Headers = map[string]string{"x-scope-orgid": "{resource:tenant.id}"}
+	cfg.GRPCClientSettings.MissingHeaderAttributes = "drop"
+	assert.ErrorContains(t, cfg.Validate(), "invalid missing_header_attributes")
+}
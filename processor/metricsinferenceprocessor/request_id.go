@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"sort"
+	"strconv"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// requestID returns the ID of a rule's inference request. It is derived from
+// the rule, its model, and the attribute sets and timestamps of its input data
+// points, so a request retried for the same data, by the processor or by a
+// collector replaying a batch, carries the same ID.
+func requestID(ctx *modelContext) string {
+	rule := ctx.rule
+	h := fnvAddString(fnvOffset64, strconv.Itoa(ctx.ruleIndex))
+	h = fnvAddString(h, "\x00"+rule.modelName+"\x00"+rule.modelVersion)
+	if ctx.expansion != nil {
+		h = fnvAddString(h, "\x00"+ctx.expansion.value)
+	}
+	if ctx.hasContext {
+		h = fnvAddUint64(h, attributeSetHash(ctx.resourceMetrics.Resource().Attributes()))
+	}
+
+	names := make([]string, 0, len(ctx.inputs))
+	for name := range ctx.inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h = fnvAddString(h, "\x00"+name)
+		for _, dp := range extractDataPoints(ctx.inputs[name]) {
+			h = fnvAddDataPoint(h, dp)
+		}
+	}
+
+	// Requests built from matched groups alone have no input metrics
+	if len(ctx.inputs) == 0 {
+		for _, group := range ctx.matchedDataPoints {
+			names = names[:0]
+			for name := range group.dataPoints {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				h = fnvAddString(h, "\x00"+name)
+				h = fnvAddDataPoint(h, group.dataPoints[name])
+			}
+		}
+	}
+
+	return rule.modelName + "-" + strconv.FormatUint(h, 16)
+}
+
+// fnvAddDataPoint folds the attribute set and timestamp of a data point into an FNV-1a hash
+func fnvAddDataPoint(h uint64, dp dataPoint) uint64 {
+	h = fnvAddUint64(h, attributeSetHash(dp.Attributes()))
+	return fnvAddUint64(h, uint64(dp.Timestamp()))
+}
+
+// fnvAddUint64 folds the bytes of v into an FNV-1a hash
+func fnvAddUint64(h uint64, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime64
+		v >>= 8
+	}
+	return h
+}
+
+// setIdempotencyKey sends the ID of a request as its idempotency parameter,
+// unless disabled
+func (mp *metricsinferenceprocessor) setIdempotencyKey(request *pb.ModelInferRequest) {
+	name := mp.config.IdempotencyParameter
+	if name == "" {
+		return
+	}
+	if request.Parameters == nil {
+		request.Parameters = make(map[string]*pb.InferParameter)
+	}
+	request.Parameters[name] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_StringParam{StringParam: request.Id},
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestDeterministicRequestIDs(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings:   GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:              5,
+		IdempotencyParameter: "idempotency_key",
+		Rules: []Rule{{
+			ModelName: "scorer",
+			Inputs:    []string{"cpu"},
+			Outputs:   []OutputSpec{{Name: "score"}},
+		}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	batch := func(timestamp pcommon.Timestamp, host string) pmetric.Metrics {
+		md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{{
+			MetricName: "cpu",
+			DataPoints: []testutil.TestDataPoint{{Value: 0.5, Attributes: map[string]string{"host.name": host}}},
+		}})
+		md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).SetTimestamp(timestamp)
+		return md
+	}
+	for _, md := range []pmetric.Metrics{batch(1000, "a"), batch(1000, "a"), batch(2000, "a"), batch(1000, "b")} {
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+	}
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 4)
+	for _, request := range requests {
+		assert.Equal(t, request.Id, request.Parameters["idempotency_key"].GetStringParam())
+	}
+
+	// A replayed batch carries the same ID, and other timestamps or attributes do not
+	assert.Equal(t, requests[0].Id, requests[1].Id)
+	assert.NotEqual(t, requests[0].Id, requests[2].Id)
+	assert.NotEqual(t, requests[0].Id, requests[3].Id)
+
+	// The idempotency parameter does not keep identical inputs from sharing cached results
+	key1, err := processor.requestCacheKey(requests[0], nil)
+	require.NoError(t, err)
+	key2, err := processor.requestCacheKey(requests[2], nil)
+	require.NoError(t, err)
+	assert.Equal(t, key1, key2)
+}
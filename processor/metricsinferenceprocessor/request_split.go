@@ -0,0 +1,255 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// resolveMaxGroups returns the maximum number of attribute groups in a request of
+// a rule: the rule's max_groups_per_request, or the processor's. Zero is unlimited.
+func resolveMaxGroups(config *Config, rule Rule) int {
+	if rule.MaxGroupsPerRequest > 0 {
+		return rule.MaxGroupsPerRequest
+	}
+	return config.MaxGroupsPerRequest
+}
+
+// splitRuleRequest returns the requests a rule call is sent as: its request, or,
+// when the call has more attribute groups than the rule allows in a request,
+// parts of the request holding at most that many groups each. Rules inferring
+// in-process and stateful sequences are never split.
+func (mp *metricsinferenceprocessor) splitRuleRequest(call *ruleCall) ([]*pb.ModelInferRequest, error) {
+	rule := call.ctx.rule
+	rows := len(call.ctx.matchedDataPoints)
+	if rule.maxGroups <= 0 || rows <= rule.maxGroups || rule.backend != nil || rule.sequenceEnabled {
+		return []*pb.ModelInferRequest{call.request}, nil
+	}
+	parts, err := splitRequest(call.request, rows, rule.maxGroups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split request of %d attribute groups: %w", rows, err)
+	}
+	for _, part := range parts {
+		mp.setIdempotencyKey(part)
+	}
+	return parts, nil
+}
+
+// splitRequest splits a request of rows attribute groups into parts of at most
+// size groups. Input tensors with a row per group are split by rows, other
+// tensors are sent whole with every part. Parts get IDs of their own, suffixed
+// with their index.
+func splitRequest(request *pb.ModelInferRequest, rows, size int) ([]*pb.ModelInferRequest, error) {
+	inputs := make([]*pb.InferTensorContents, len(request.Inputs))
+	for i, tensor := range request.Inputs {
+		inputs[i] = tensor.Contents
+		if len(request.RawInputContents) > 0 {
+			contents, err := decodeRawContents(tensor.Datatype, request.RawInputContents[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode raw contents of input %q: %w", tensor.Name, err)
+			}
+			inputs[i] = contents
+		}
+	}
+
+	var parts []*pb.ModelInferRequest
+	for from := 0; from < rows; from += size {
+		to := min(from+size, rows)
+		part := &pb.ModelInferRequest{
+			ModelName:    request.ModelName,
+			ModelVersion: request.ModelVersion,
+			Id:           fmt.Sprintf("%s-%d", request.Id, len(parts)),
+			Parameters:   maps.Clone(request.Parameters),
+			Outputs:      request.Outputs,
+		}
+		for i, tensor := range request.Inputs {
+			sliced := &pb.ModelInferRequest_InferInputTensor{
+				Name:       tensor.Name,
+				Datatype:   tensor.Datatype,
+				Shape:      tensor.Shape,
+				Parameters: tensor.Parameters,
+				Contents:   inputs[i],
+			}
+			if len(tensor.Shape) > 0 && tensor.Shape[0] == int64(rows) {
+				sliced.Shape = append([]int64{int64(to - from)}, tensor.Shape[1:]...)
+				perRow := contentsLength(inputs[i]) / rows
+				sliced.Contents = sliceContents(inputs[i], from*perRow, to*perRow)
+			}
+			if len(request.RawInputContents) > 0 {
+				raw, err := encodeRawContents(sliced.Datatype, sliced.Contents)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode input %q as raw contents: %w", tensor.Name, err)
+				}
+				part.RawInputContents = append(part.RawInputContents, raw)
+				sliced.Contents = nil
+			}
+			part.Inputs = append(part.Inputs, sliced)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// selectRows returns a request holding the given rows, in ascending order, of a
+// request of rows attribute groups. Input tensors with a row per group keep the
+// selected rows, other tensors are sent whole.
+func selectRows(request *pb.ModelInferRequest, rows int, selected []int) (*pb.ModelInferRequest, error) {
+	parts, err := splitRequest(request, rows, 1)
+	if err != nil {
+		return nil, err
+	}
+	joined := proto.Clone(parts[selected[0]]).(*pb.ModelInferRequest)
+	joined.Id = request.Id
+	for i, tensor := range request.Inputs {
+		if len(tensor.Shape) == 0 || tensor.Shape[0] != int64(rows) {
+			continue
+		}
+		joined.Inputs[i].Shape = append([]int64{int64(len(selected))}, tensor.Shape[1:]...)
+		if len(request.RawInputContents) > 0 {
+			var raw []byte
+			for _, row := range selected {
+				raw = append(raw, parts[row].RawInputContents[i]...)
+			}
+			joined.RawInputContents[i] = raw
+			continue
+		}
+		joined.Inputs[i].Contents = &pb.InferTensorContents{}
+		for _, row := range selected {
+			appendContents(joined.Inputs[i].Contents, parts[row].Inputs[i].Contents)
+		}
+	}
+	return joined, nil
+}
+
+// inferParts sends the parts of a split request concurrently and merges their
+// responses into the response of the whole request. The call fails with the
+// error of its first failed part.
+func (mp *metricsinferenceprocessor) inferParts(ctx context.Context, client InferenceClient, call *ruleCall, parts []*pb.ModelInferRequest) (*pb.ModelInferResponse, error) {
+	responses := make([]*pb.ModelInferResponse, len(parts))
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i], errs[i] = mp.inferWithCache(ctx, client, call.ruleIdx, part)
+		}()
+	}
+	wg.Wait()
+
+	groups, size := len(call.ctx.matchedDataPoints), call.ctx.rule.maxGroups
+	rows := make([]int, len(parts))
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = min(size, groups-i*size)
+	}
+	response, err := mergeResponses(responses, rows)
+	if err != nil {
+		return nil, err
+	}
+	response.Id = call.request.Id
+	return response, nil
+}
+
+// mergeResponses merges the responses to the parts of a split request, whose
+// parts had the given numbers of rows, by concatenating the rows of every
+// output. Outputs without a row per attribute group of their part cannot be
+// merged. The merged response keeps the parameters of the first.
+func mergeResponses(responses []*pb.ModelInferResponse, rows []int) (*pb.ModelInferResponse, error) {
+	first := responses[0]
+	merged := &pb.ModelInferResponse{
+		ModelName:    first.ModelName,
+		ModelVersion: first.ModelVersion,
+		Parameters:   first.Parameters,
+	}
+	total := 0
+	for _, n := range rows {
+		total += n
+	}
+	for i, output := range first.Outputs {
+		tensor := &pb.ModelInferResponse_InferOutputTensor{
+			Name:       output.Name,
+			Datatype:   output.Datatype,
+			Shape:      append([]int64{int64(total)}, output.Shape[min(1, len(output.Shape)):]...),
+			Parameters: output.Parameters,
+			Contents:   &pb.InferTensorContents{},
+		}
+		for p, response := range responses {
+			if len(response.Outputs) != len(first.Outputs) {
+				return nil, fmt.Errorf("responses to the parts of a split request have %d and %d outputs", len(first.Outputs), len(response.Outputs))
+			}
+			part := response.Outputs[i]
+			if part.Name != output.Name || part.Datatype != output.Datatype {
+				return nil, fmt.Errorf("output %d is %s %q in one part of a split request and %s %q in another", i, output.Datatype, output.Name, part.Datatype, part.Name)
+			}
+			if len(part.Shape) == 0 || part.Shape[0] != int64(rows[p]) {
+				return nil, fmt.Errorf("output %q of shape %v does not have a row per attribute group of its request part, so the parts of a split request cannot be merged", part.Name, part.Shape)
+			}
+			appendContents(tensor.Contents, part.Contents)
+		}
+		merged.Outputs = append(merged.Outputs, tensor)
+	}
+	return merged, nil
+}
+
+// contentsLength returns the number of elements of typed tensor contents
+func contentsLength(contents *pb.InferTensorContents) int {
+	if contents == nil {
+		return 0
+	}
+	return len(contents.BoolContents) + len(contents.IntContents) + len(contents.Int64Contents) +
+		len(contents.UintContents) + len(contents.Uint64Contents) + len(contents.Fp32Contents) +
+		len(contents.Fp64Contents) + len(contents.BytesContents)
+}
+
+// sliceContents returns the elements from index from up to to of typed tensor
+// contents, which hold their elements in a single field
+func sliceContents(contents *pb.InferTensorContents, from, to int) *pb.InferTensorContents {
+	if contents == nil {
+		return nil
+	}
+	return &pb.InferTensorContents{
+		BoolContents:   sliceElements(contents.BoolContents, from, to),
+		IntContents:    sliceElements(contents.IntContents, from, to),
+		Int64Contents:  sliceElements(contents.Int64Contents, from, to),
+		UintContents:   sliceElements(contents.UintContents, from, to),
+		Uint64Contents: sliceElements(contents.Uint64Contents, from, to),
+		Fp32Contents:   sliceElements(contents.Fp32Contents, from, to),
+		Fp64Contents:   sliceElements(contents.Fp64Contents, from, to),
+		BytesContents:  sliceElements(contents.BytesContents, from, to),
+	}
+}
+
+// sliceElements returns the elements from index from up to to of a contents
+// field, nil when the field is not the one holding the elements
+func sliceElements[T any](elements []T, from, to int) []T {
+	if len(elements) < to {
+		return nil
+	}
+	return elements[from:to]
+}
+
+// appendContents appends the elements of typed tensor contents to dst
+func appendContents(dst, src *pb.InferTensorContents) {
+	if src == nil {
+		return
+	}
+	dst.BoolContents = append(dst.BoolContents, src.BoolContents...)
+	dst.IntContents = append(dst.IntContents, src.IntContents...)
+	dst.Int64Contents = append(dst.Int64Contents, src.Int64Contents...)
+	dst.UintContents = append(dst.UintContents, src.UintContents...)
+	dst.Uint64Contents = append(dst.Uint64Contents, src.Uint64Contents...)
+	dst.Fp32Contents = append(dst.Fp32Contents, src.Fp32Contents...)
+	dst.Fp64Contents = append(dst.Fp64Contents, src.Fp64Contents...)
+	dst.BytesContents = append(dst.BytesContents, src.BytesContents...)
+}
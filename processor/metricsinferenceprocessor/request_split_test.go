@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// rowSumClient is an in-process inference client answering with a row per
+// attribute group, the sum of the group's inputs, and recording the number of
+// groups of every request
+type rowSumClient struct {
+	stubClient
+	mu   sync.Mutex
+	rows []int64
+}
+
+func (c *rowSumClient) ModelInfer(_ context.Context, req *pb.ModelInferRequest, _ ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	rows := req.Inputs[0].Shape[0]
+	c.mu.Lock()
+	c.rows = append(c.rows, rows)
+	c.mu.Unlock()
+
+	sums := make([]float64, rows)
+	for _, input := range req.Inputs {
+		for i, value := range input.Contents.Fp64Contents {
+			sums[i] += value
+		}
+	}
+	return &pb.ModelInferResponse{
+		ModelName: req.ModelName,
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+			Name:     "sum",
+			Datatype: "FP64",
+			Shape:    []int64{rows},
+			Contents: &pb.InferTensorContents{Fp64Contents: sums},
+		}},
+	}, nil
+}
+
+func TestSplitRequest(t *testing.T) {
+	request := &pb.ModelInferRequest{
+		ModelName:  "scorer",
+		Id:         "req",
+		Parameters: map[string]*pb.InferParameter{"mode": {ParameterChoice: &pb.InferParameter_StringParam{StringParam: "fast"}}},
+		Inputs: []*pb.ModelInferRequest_InferInputTensor{
+			{Name: "cpu", Datatype: "FP64", Shape: []int64{5}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3, 4, 5}}},
+			{Name: "window", Datatype: "FP32", Shape: []int64{5, 2}, Contents: &pb.InferTensorContents{Fp32Contents: []float32{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}}},
+			{Name: "threshold", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.5}}},
+		},
+	}
+
+	parts, err := splitRequest(request, 5, 2)
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	assert.Equal(t, []string{"req-0", "req-1", "req-2"}, []string{parts[0].Id, parts[1].Id, parts[2].Id})
+	assert.Equal(t, "fast", parts[2].Parameters["mode"].GetStringParam())
+
+	assert.Equal(t, []int64{2}, parts[1].Inputs[0].Shape)
+	assert.Equal(t, []float64{3, 4}, parts[1].Inputs[0].Contents.Fp64Contents)
+	assert.Equal(t, []int64{1, 2}, parts[2].Inputs[1].Shape)
+	assert.Equal(t, []float32{5, 5}, parts[2].Inputs[1].Contents.Fp32Contents)
+	assert.Equal(t, []float64{0.5}, parts[2].Inputs[2].Contents.Fp64Contents, "tensors without a row per group are sent whole")
+
+	// Raw contents are split the same
+	raw := make([][]byte, len(request.Inputs))
+	for i, tensor := range request.Inputs {
+		raw[i], err = encodeRawContents(tensor.Datatype, tensor.Contents)
+		require.NoError(t, err)
+	}
+	request.RawInputContents = raw
+	parts, err = splitRequest(request, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	contents, err := decodeRawContents("FP64", parts[1].RawInputContents[0])
+	require.NoError(t, err)
+	assert.Equal(t, []float64{4, 5}, contents.Fp64Contents)
+	assert.Nil(t, parts[1].Inputs[0].Contents)
+}
+
+func TestSelectRows(t *testing.T) {
+	request := &pb.ModelInferRequest{
+		ModelName: "rnn_model",
+		Id:        "req",
+		Inputs: []*pb.ModelInferRequest_InferInputTensor{
+			{Name: "cpu", Datatype: "FP64", Shape: []int64{3}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3}}},
+			{Name: "CORRID", Datatype: "UINT64", Shape: []int64{3}, Contents: &pb.InferTensorContents{Uint64Contents: []uint64{7, 8, 9}}},
+			{Name: "threshold", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.5}}},
+		},
+	}
+
+	selected, err := selectRows(request, 3, []int{0, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "req", selected.Id)
+	assert.Equal(t, []int64{2}, selected.Inputs[0].Shape)
+	assert.Equal(t, []float64{1, 3}, selected.Inputs[0].Contents.Fp64Contents)
+	assert.Equal(t, []uint64{7, 9}, selected.Inputs[1].Contents.Uint64Contents)
+	assert.Equal(t, []float64{0.5}, selected.Inputs[2].Contents.Fp64Contents, "tensors without a row per group are sent whole")
+	assert.Equal(t, []float64{1, 2, 3}, request.Inputs[0].Contents.Fp64Contents, "the request is left unchanged")
+
+	// Raw contents are selected the same
+	raw := make([][]byte, len(request.Inputs))
+	for i, tensor := range request.Inputs {
+		raw[i], err = encodeRawContents(tensor.Datatype, tensor.Contents)
+		require.NoError(t, err)
+	}
+	request.RawInputContents = raw
+	selected, err = selectRows(request, 3, []int{1, 2})
+	require.NoError(t, err)
+	contents, err := decodeRawContents("UINT64", selected.RawInputContents[1])
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{8, 9}, contents.Uint64Contents)
+}
+
+func TestMergeResponses(t *testing.T) {
+	response := func(shape []int64, values ...float64) *pb.ModelInferResponse {
+		return &pb.ModelInferResponse{ModelName: "scorer", Outputs: []*pb.ModelInferResponse_InferOutputTensor{
+			{Name: "score", Datatype: "FP64", Shape: shape, Contents: &pb.InferTensorContents{Fp64Contents: values}},
+		}}
+	}
+
+	merged, err := mergeResponses([]*pb.ModelInferResponse{response([]int64{2, 2}, 1, 2, 3, 4), response([]int64{1, 2}, 5, 6)}, []int{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, merged.Outputs[0].Shape)
+	assert.Equal(t, []float64{1, 2, 3, 4, 5, 6}, merged.Outputs[0].Contents.Fp64Contents)
+
+	_, err = mergeResponses([]*pb.ModelInferResponse{response([]int64{1}, 1), response([]int64{1}, 2)}, []int{2, 1})
+	assert.ErrorContains(t, err, "cannot be merged")
+
+	renamed := response([]int64{1}, 2)
+	renamed.Outputs[0].Name = "other"
+	_, err = mergeResponses([]*pb.ModelInferResponse{response([]int64{1}, 1), renamed}, []int{1, 1})
+	assert.ErrorContains(t, err, `"other"`)
+}
+
+func TestMaxGroupsPerRequest(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings:  GRPCClientSettings{Endpoint: "localhost:8001"},
+		MaxGroupsPerRequest: 2,
+		Rules: []Rule{{
+			ModelName:     "adder",
+			Inputs:        []string{"cpu", "memory"},
+			OutputPattern: "{model}.{output}",
+			Outputs:       []OutputSpec{{Name: "sum"}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	client := &rowSumClient{}
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.injectedClient = client
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	var cpu, memory []testutil.TestDataPoint
+	for i := 0; i < 5; i++ {
+		attrs := map[string]string{"host": fmt.Sprintf("node-%d", i)}
+		cpu = append(cpu, testutil.TestDataPoint{Value: float64(i), Attributes: attrs})
+		memory = append(memory, testutil.TestDataPoint{Value: float64(10 * i), Attributes: attrs})
+	}
+	input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+		{MetricName: "cpu", DataPoints: cpu},
+		{MetricName: "memory", DataPoints: memory},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	assert.ElementsMatch(t, []int64{2, 2, 1}, client.rows)
+	sum := findMetricByName(sink.AllMetrics()[0], "adder.sum")
+	require.Equal(t, 5, sum.Gauge().DataPoints().Len())
+	for i := 0; i < 5; i++ {
+		dp := sum.Gauge().DataPoints().At(i)
+		host, _ := dp.Attributes().Get("cpu.host")
+		assert.Equal(t, fmt.Sprintf("node-%d", i), host.Str())
+		assert.Equal(t, float64(11*i), dp.DoubleValue())
+	}
+
+	cfg.Rules[0].MaxGroupsPerRequest = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_groups_per_request must not be negative")
+	cfg.Rules[0].MaxGroupsPerRequest = 0
+	cfg.MaxGroupsPerRequest = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_groups_per_request must not be negative")
+}
@@ -0,0 +1,205 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// resultCacheEntry is a cached inference response
+type resultCacheEntry struct {
+	key      string
+	response *pb.ModelInferResponse
+	expires  time.Time
+}
+
+// resultCache is an LRU cache of inference responses with per-entry expiry
+type resultCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // Front is most recently used
+	now        func() time.Time
+}
+
+// newResultCache creates a result cache with the given TTL and capacity
+func newResultCache(ttl time.Duration, maxEntries int) *resultCache {
+	return &resultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// get returns the cached response for key if present and not expired
+func (c *resultCache) get(key string) (*pb.ModelInferResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	entry := elem.Value.(*resultCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.response, true
+}
+
+// put stores a response, evicting the least recently used entry when full
+func (c *resultCache) put(key string, response *pb.ModelInferResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if elem, exists := c.entries[key]; exists {
+		entry := elem.Value.(*resultCacheEntry)
+		entry.response = response
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&resultCacheEntry{
+		key:      key,
+		response: response,
+		expires:  expires,
+	})
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// purge removes the entries of a model, whatever their version
+func (c *resultCache) purge(modelName string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := modelName + "/"
+	purged := 0
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// len returns the number of cached entries
+func (c *resultCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// resultCacheKey builds a cache key from the model, version, input tensors and
+// parameters of a request, and the gRPC headers it is sent with, so tenants with
+// identical inputs do not share results. The request ID is excluded since it
+// changes with input timestamps.
+func resultCacheKey(request *pb.ModelInferRequest, headers metadata.MD) (string, error) {
+	keyed := proto.Clone(request).(*pb.ModelInferRequest)
+	keyed.Id = ""
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(keyed)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data = append(data, 0)
+		data = append(data, name...)
+		for _, value := range headers[name] {
+			data = append(data, 0)
+			data = append(data, value...)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return request.ModelName + "/" + request.ModelVersion + "/" + hex.EncodeToString(sum[:]), nil
+}
+
+// requestCacheKey builds the result cache key of a request without its
+// idempotency parameter, which carries the request ID
+func (mp *metricsinferenceprocessor) requestCacheKey(request *pb.ModelInferRequest, headers metadata.MD) (string, error) {
+	if name := mp.config.IdempotencyParameter; name != "" && request.Parameters[name] != nil {
+		request = proto.Clone(request).(*pb.ModelInferRequest)
+		delete(request.Parameters, name)
+	}
+	return resultCacheKey(request, headers)
+}
+
+// inferWithCache answers a request from the result cache when possible and
+// otherwise calls the inference server, caching successful responses with raw
+// output contents decoded.
+// Stateful sequence rules always bypass the cache.
+func (mp *metricsinferenceprocessor) inferWithCache(ctx context.Context, client InferenceClient, ruleIdx int, request *pb.ModelInferRequest) (*pb.ModelInferResponse, error) {
+	opts := mp.rules[ruleIdx].callOptions
+	infer := func() (*pb.ModelInferResponse, error) {
+		response, err := client.ModelInfer(ctx, request, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return response, decodeRawOutputs(response)
+	}
+	if mp.resultCache == nil || mp.rules[ruleIdx].sequenceEnabled {
+		return infer()
+	}
+
+	headers, _ := metadata.FromOutgoingContext(ctx)
+	key, err := mp.requestCacheKey(request, headers)
+	if err != nil {
+		mp.logger.Debug("Failed to build result cache key, bypassing cache",
+			zap.String("model", request.ModelName),
+			zap.Error(err))
+		return infer()
+	}
+
+	if response, ok := mp.resultCache.get(key); ok {
+		mp.telemetry.recordCacheHit(ctx, request.ModelName)
+		mp.logger.Debug("Reusing cached inference result",
+			zap.String("model", request.ModelName),
+			zap.Int("rule_index", ruleIdx))
+		return response, nil
+	}
+	mp.telemetry.recordCacheMiss(ctx, request.ModelName)
+
+	response, err := infer()
+	if err != nil {
+		return nil, err
+	}
+	mp.resultCache.put(key, response)
+	return response, nil
+}
@@ -0,0 +1,180 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestResultCacheExpiryAndEviction(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newResultCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", &pb.ModelInferResponse{Id: "a"})
+	cache.put("b", &pb.ModelInferResponse{Id: "b"})
+
+	// Touch "a" so "b" becomes the least recently used entry
+	resp, ok := cache.get("a")
+	require.True(t, ok)
+	assert.Equal(t, "a", resp.Id)
+
+	cache.put("c", &pb.ModelInferResponse{Id: "c"})
+	assert.Equal(t, 2, cache.len())
+	_, ok = cache.get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.get("a")
+	assert.False(t, ok, "expired entries should not be returned")
+	assert.Equal(t, 1, cache.len())
+}
+
+func TestResultCacheKey(t *testing.T) {
+	request := func(id string, value float64) *pb.ModelInferRequest {
+		return &pb.ModelInferRequest{
+			ModelName: "model",
+			Id:        id,
+			Inputs: []*pb.ModelInferRequest_InferInputTensor{
+				{Name: "x", Datatype: "FP64", Shape: []int64{1}, Contents: &pb.InferTensorContents{Fp64Contents: []float64{value}}},
+			},
+		}
+	}
+
+	key1, err := resultCacheKey(request("1", 1.0), nil)
+	require.NoError(t, err)
+	key2, err := resultCacheKey(request("2", 1.0), nil)
+	require.NoError(t, err)
+	key3, err := resultCacheKey(request("3", 2.0), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, key1, key2, "request IDs must not affect the cache key")
+	assert.NotEqual(t, key1, key3, "different input values must produce different keys")
+
+	key4, err := resultCacheKey(request("4", 1.0), metadata.Pairs("x-scope-orgid", "tenant-a"))
+	require.NoError(t, err)
+	key5, err := resultCacheKey(request("5", 1.0), metadata.Pairs("x-scope-orgid", "tenant-b"))
+	require.NoError(t, err)
+	assert.NotEqual(t, key1, key4, "request headers must be part of the cache key")
+	assert.NotEqual(t, key4, key5, "different header values must produce different keys")
+}
+
+func TestResultCacheSkipsRepeatedRequests(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("slow_gauge_model",
+		testutil.CreateMockResponseForCalculation("slow_gauge_model", 7.0))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Cache:              CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 10},
+		Rules: []Rule{
+			{
+				ModelName:     "slow_gauge_model",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "{output}",
+				Outputs:       []OutputSpec{{Name: "prediction"}},
+			},
+		},
+	}
+
+	reader := sdkmetric.NewManualReader()
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for _, value := range []float64{10, 10, 20} {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{value}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+
+	assert.Len(t, mockServer.GetRequests(), 2, "the repeated batch should be served from the cache")
+	require.Len(t, sink.AllMetrics(), 3)
+	for _, md := range sink.AllMetrics() {
+		output := findMetricByName(md, "prediction")
+		require.Equal(t, 1, output.Gauge().DataPoints().Len(), "cached results must still produce outputs")
+		assert.Equal(t, 7.0, output.Gauge().DataPoints().At(0).DoubleValue())
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					counts[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	assert.Equal(t, int64(1), counts["otelcol_processor_metricsinference_cache_hits"])
+	assert.Equal(t, int64(2), counts["otelcol_processor_metricsinference_cache_misses"])
+}
+
+func TestResultCachePurgedOnMetadataChange(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelMetadata("scorer", scorerMetadata("1", "score")),
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 7.0)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Cache:              CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 10},
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "{output}"},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	consume := func() {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{10}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	consume()
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 1)
+	assert.Equal(t, 1, processor.resultCache.len())
+
+	// A redeployed model answers the same request anew
+	mockServer.SetModelMetadata("scorer", scorerMetadata("2", "score"))
+	processor.refreshModelMetadata(context.Background(), processor.grpcClient)
+	assert.Equal(t, 0, processor.resultCache.len())
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 2)
+}
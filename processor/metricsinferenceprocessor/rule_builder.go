@@ -0,0 +1,151 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor // import "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor"
+
+import "time"
+
+// RuleOption sets a field of a rule built with NewRule
+type RuleOption func(*Rule)
+
+// NewRule builds an inference rule for a model, for programs embedding the
+// collector that configure the processor in Go rather than YAML. The rule is
+// checked with the rest of the configuration by Config.Validate. Options cover
+// the commonly used fields; the others, such as Backend, Encoders, Challenger or
+// AutoDisable, are set on the returned Rule directly. Data handling applies to
+// every rule and is set on the Config.
+func NewRule(modelName string, opts ...RuleOption) Rule {
+	rule := Rule{ModelName: modelName}
+	for _, opt := range opts {
+		opt(&rule)
+	}
+	return rule
+}
+
+// WithModelVersion pins the rule to a version of its model
+func WithModelVersion(version string) RuleOption {
+	return func(r *Rule) {
+		r.ModelVersion = version
+	}
+}
+
+// WithInputs adds input metrics to the rule, which may use label selectors
+// such as `cpu{state="busy"}`
+func WithInputs(inputs ...string) RuleOption {
+	return func(r *Rule) {
+		r.Inputs = append(r.Inputs, inputs...)
+	}
+}
+
+// WithOutputs adds output specifications to the rule
+func WithOutputs(outputs ...OutputSpec) RuleOption {
+	return func(r *Rule) {
+		r.Outputs = append(r.Outputs, outputs...)
+	}
+}
+
+// WithOutputPattern sets the pattern output metrics are named with, such as
+// "{input}.{output}"
+func WithOutputPattern(pattern string) RuleOption {
+	return func(r *Rule) {
+		r.OutputPattern = pattern
+	}
+}
+
+// WithParameters adds parameters sent with every inference request of the rule
+func WithParameters(parameters map[string]any) RuleOption {
+	return func(r *Rule) {
+		if r.Parameters == nil {
+			r.Parameters = make(map[string]any, len(parameters))
+		}
+		for key, value := range parameters {
+			r.Parameters[key] = value
+		}
+	}
+}
+
+// WithRuleOnError sets what happens to a batch when the rule's inference fails:
+// "pass", "drop_inputs", "drop_batch", or "retry"
+func WithRuleOnError(policy string) RuleOption {
+	return func(r *Rule) {
+		r.OnError = policy
+	}
+}
+
+// WithInputMap adds model input names mapped to the metric selectors feeding them
+func WithInputMap(inputMap map[string]string) RuleOption {
+	return func(r *Rule) {
+		if r.InputMap == nil {
+			r.InputMap = make(map[string]string, len(inputMap))
+		}
+		for input, selector := range inputMap {
+			r.InputMap[input] = selector
+		}
+	}
+}
+
+// WithTransforms adds delta, rate, aggregation or scaling transforms of the
+// rule's inputs, by input name
+func WithTransforms(transforms map[string]InputTransformConfig) RuleOption {
+	return func(r *Rule) {
+		if r.Transforms == nil {
+			r.Transforms = make(map[string]InputTransformConfig, len(transforms))
+		}
+		for input, transform := range transforms {
+			r.Transforms[input] = transform
+		}
+	}
+}
+
+// WithCombineInputs sends the rule's inputs as one feature matrix
+func WithCombineInputs(combine CombineInputsConfig) RuleOption {
+	return func(r *Rule) {
+		r.CombineInputs = &combine
+	}
+}
+
+// WithOutputAttributes sets how input data point attributes are copied onto the
+// rule's output data points
+func WithOutputAttributes(attributes OutputAttributesConfig) RuleOption {
+	return func(r *Rule) {
+		r.OutputAttributes = attributes
+	}
+}
+
+// WithTrigger sets when the rule infers, on every batch or on a timer
+func WithTrigger(trigger TriggerConfig) RuleOption {
+	return func(r *Rule) {
+		r.Trigger = trigger
+	}
+}
+
+// WithExpandBy makes the rule infer once per value of a resource or data point
+// attribute, such as host.name
+func WithExpandBy(attribute string) RuleOption {
+	return func(r *Rule) {
+		r.ExpandBy = attribute
+	}
+}
+
+// WithDeadline sets the time budget of the rule's inference in a batch
+func WithDeadline(deadline time.Duration) RuleOption {
+	return func(r *Rule) {
+		r.Deadline = deadline
+	}
+}
+
+// WithPriority sets the order of the rule's calls in the inference queue
+func WithPriority(priority int) RuleOption {
+	return func(r *Rule) {
+		r.Priority = priority
+	}
+}
+
+// WithShadow runs the rule in shadow mode, reporting its results without adding
+// its outputs to the batch
+func WithShadow(shadow ShadowConfig) RuleOption {
+	return func(r *Rule) {
+		r.Mode = ruleModeShadow
+		r.Shadow = shadow
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+	"google.golang.org/grpc"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/metadata"
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// stubClient is an in-process inference client answering every model with a
+// constant, as a program embedding the collector could inject
+type stubClient struct {
+	pb.GRPCInferenceServiceClient // Calls not overridden are not expected
+	infers                        atomic.Int32
+}
+
+func (c *stubClient) ServerLive(context.Context, *pb.ServerLiveRequest, ...grpc.CallOption) (*pb.ServerLiveResponse, error) {
+	return &pb.ServerLiveResponse{Live: true}, nil
+}
+
+func (c *stubClient) ServerMetadata(context.Context, *pb.ServerMetadataRequest, ...grpc.CallOption) (*pb.ServerMetadataResponse, error) {
+	return &pb.ServerMetadataResponse{Name: "stub"}, nil
+}
+
+func (c *stubClient) ModelMetadata(_ context.Context, req *pb.ModelMetadataRequest, _ ...grpc.CallOption) (*pb.ModelMetadataResponse, error) {
+	return &pb.ModelMetadataResponse{Name: req.Name}, nil
+}
+
+func (c *stubClient) ModelInfer(_ context.Context, req *pb.ModelInferRequest, _ ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	c.infers.Add(1)
+	return testutil.CreateMockResponseForCalculation(req.ModelName, 42), nil
+}
+
+func TestNewRule(t *testing.T) {
+	rule := NewRule("scorer",
+		WithModelVersion("2"),
+		WithInputs("cpu", `memory{state="used"}`),
+		WithOutputs(OutputSpec{Name: "score"}),
+		WithOutputPattern("{output}"),
+		WithParameters(map[string]any{"threshold": 0.5}),
+		WithParameters(map[string]any{"mode": "fast"}),
+		WithRuleOnError(onErrorDropInputs))
+
+	assert.Equal(t, Rule{
+		ModelName:     "scorer",
+		ModelVersion:  "2",
+		Inputs:        []string{"cpu", `memory{state="used"}`},
+		Outputs:       []OutputSpec{{Name: "score"}},
+		OutputPattern: "{output}",
+		Parameters:    map[string]any{"threshold": 0.5, "mode": "fast"},
+		OnError:       onErrorDropInputs,
+	}, rule)
+}
+
+func TestNewRuleOptions(t *testing.T) {
+	rule := NewRule("forecaster",
+		WithInputMap(map[string]string{"load": "cpu"}),
+		WithTransforms(map[string]InputTransformConfig{"load": {As: inputAsRate}}),
+		WithCombineInputs(CombineInputsConfig{Layout: combineLayoutRows}),
+		WithOutputAttributes(OutputAttributesConfig{Mode: outputAttributesAsIs}),
+		WithTrigger(TriggerConfig{Mode: triggerModeInterval, Every: time.Minute}),
+		WithExpandBy("host.name"),
+		WithDeadline(time.Second),
+		WithPriority(2),
+		WithShadow(ShadowConfig{SampleRate: 0.1}),
+		WithRuleOnError(onErrorRetry))
+
+	assert.Equal(t, Rule{
+		ModelName:        "forecaster",
+		InputMap:         map[string]string{"load": "cpu"},
+		Transforms:       map[string]InputTransformConfig{"load": {As: inputAsRate}},
+		CombineInputs:    &CombineInputsConfig{Layout: combineLayoutRows},
+		OutputAttributes: OutputAttributesConfig{Mode: outputAttributesAsIs},
+		Trigger:          TriggerConfig{Mode: triggerModeInterval, Every: time.Minute},
+		ExpandBy:         "host.name",
+		Deadline:         time.Second,
+		Priority:         2,
+		Mode:             ruleModeShadow,
+		Shadow:           ShadowConfig{SampleRate: 0.1},
+		OnError:          onErrorRetry,
+	}, rule)
+}
+
+func TestNewFactoryWithClient(t *testing.T) {
+	client := &stubClient{}
+	factory := NewFactoryWithClient(client)
+
+	// No endpoint is needed with an injected client
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Rules = []Rule{NewRule("scorer", WithInputs("metric_1"), WithOutputs(OutputSpec{Name: "score"}))}
+	require.NoError(t, cfg.Validate())
+	assert.ErrorContains(t, NewFactory().CreateDefaultConfig().(*Config).Validate(), "endpoint")
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := factory.CreateMetrics(context.Background(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	assert.Equal(t, int32(1), client.infers.Load())
+	metric := findMetricByName(sink.AllMetrics()[0], "metric_1.score")
+	require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+	assert.Equal(t, 42.0, metric.Gauge().DataPoints().At(0).DoubleValue())
+}
@@ -0,0 +1,211 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// expansionIdleTTL is how long the state of an expanded rule for a value that is
+// no longer seen is kept. A value seen again after that starts over.
+const expansionIdleTTL = time.Hour
+
+// ruleExpansion is the state an expanded rule keeps for one value of the attribute
+// it is expanded over in one resource, so every value is matched and transformed
+// independently, and a value reported by several resources infers once per resource
+type ruleExpansion struct {
+	value      string
+	index      *attributeGroupIndex       // Attribute groups of the value's inputs
+	transforms map[string]*inputTransform // Delta or rate transforms of the value's inputs, by input name
+	lastSeen   time.Time                  // Last batch holding the value
+}
+
+// validateExpansion checks the attribute a rule is expanded over
+func validateExpansion(rule Rule) error {
+	if rule.ExpandBy == "" {
+		return nil
+	}
+	if rule.Sequence.Enabled {
+		return errors.New("expand_by cannot be combined with sequence")
+	}
+	return nil
+}
+
+// prepareRuleCalls builds the inference calls of a rule for a batch: one call per
+// resource holding the rule's inputs, in batch order, or one call per value of
+// the attribute the rule is expanded over and resource holding it, in value order
+func (mp *metricsinferenceprocessor) prepareRuleCalls(resources []resourceMetricIndex, ruleIdx int) []*ruleCall {
+	rule := mp.rules[ruleIdx]
+	// Rules discovering their inputs wait for their model's metadata
+	if len(rule.inputs) == 0 {
+		mp.logLimiter.Warn(ruleIdx, "Inputs of inference rule not discovered yet, skipping",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.String("suggestion", "Check that the model's metadata lists its inputs, or configure inputs"))
+		return nil
+	}
+	// Rules of models unloaded from the server wait until they are loaded again
+	if mp.modelUnloaded(rule) {
+		mp.logger.Debug("Model of inference rule is unloaded, skipping",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", ruleIdx))
+		return nil
+	}
+	if rule.expandBy == "" {
+		var calls []*ruleCall
+		for _, part := range splitResources(resources, rule) {
+			if call := mp.prepareRuleCall(part, ruleIdx, nil); call != nil {
+				calls = append(calls, call)
+			}
+		}
+		return calls
+	}
+
+	partitions := expandResources(resources, rule)
+	values := make([]string, 0, len(partitions))
+	for value := range partitions {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	calls := make([]*ruleCall, 0, len(values))
+	for _, value := range values {
+		for _, part := range partitions[value] {
+			expansion := mp.ruleExpansion(ruleIdx, value, part.rm.Resource().Attributes())
+			if call := mp.prepareRuleCall([]resourceMetricIndex{part}, ruleIdx, expansion); call != nil {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+// ruleExpansion returns the state of an expanded rule for a value of its
+// attribute in a resource, creating it the first time the value is seen there,
+// and forgets the values of every rule not seen within expansionIdleTTL
+func (mp *metricsinferenceprocessor) ruleExpansion(ruleIdx int, value string, resourceAttrs pcommon.Map) *ruleExpansion {
+	mp.expansionLock.Lock()
+	defer mp.expansionLock.Unlock()
+
+	now := time.Now()
+	mp.pruneExpansions(now)
+
+	byValue, exists := mp.expansions[ruleIdx]
+	if !exists {
+		byValue = make(map[string]*ruleExpansion)
+		mp.expansions[ruleIdx] = byValue
+	}
+	key := value + "\x00" + attributeSetKey(resourceAttrs)
+	expansion, exists := byValue[key]
+	if !exists {
+		expansion = &ruleExpansion{
+			value:      value,
+			index:      newAttributeGroupIndex(),
+			transforms: newInputTransforms(mp.config.Rules[ruleIdx].Transforms),
+		}
+		byValue[key] = expansion
+	}
+	expansion.lastSeen = now
+	return expansion
+}
+
+// pruneExpansions forgets the state of values not seen within expansionIdleTTL,
+// checking at most once per TTL. The caller must hold mp.expansionLock.
+func (mp *metricsinferenceprocessor) pruneExpansions(now time.Time) {
+	if now.Sub(mp.lastExpansionPrune) < expansionIdleTTL {
+		return
+	}
+	mp.lastExpansionPrune = now
+	for ruleIdx, byValue := range mp.expansions {
+		for key, expansion := range byValue {
+			if now.Sub(expansion.lastSeen) > expansionIdleTTL {
+				delete(byValue, key)
+			}
+		}
+		if len(byValue) == 0 {
+			delete(mp.expansions, ruleIdx)
+		}
+	}
+}
+
+// splitResources splits the resources of a batch so that a rule runs on each
+// resource holding metrics it selects independently, and its outputs join the
+// resource of their inputs. When no resource holds such metrics, the batch is
+// returned whole so the missing inputs are reported once.
+func splitResources(resources []resourceMetricIndex, rule internalRule) [][]resourceMetricIndex {
+	var parts [][]resourceMetricIndex
+	for _, resource := range resources {
+		for name := range resource.metrics {
+			if selectsMetric(rule, name) {
+				parts = append(parts, []resourceMetricIndex{resource})
+				break
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return [][]resourceMetricIndex{resources}
+	}
+	return parts
+}
+
+// expandResources splits the resources of a batch by the value of the attribute a
+// rule is expanded over, with a part per resource holding the value. A resource
+// carrying the attribute belongs to its value as a whole; otherwise the data points
+// of the metrics the rule selects are split by their own attribute. Data points
+// without the attribute are left out.
+func expandResources(resources []resourceMetricIndex, rule internalRule) map[string][]resourceMetricIndex {
+	partitions := make(map[string][]resourceMetricIndex)
+	for _, resource := range resources {
+		if value, exists := resource.rm.Resource().Attributes().Get(rule.expandBy); exists {
+			partitions[value.AsString()] = append(partitions[value.AsString()], resource)
+			continue
+		}
+
+		split := make(map[string]resourceMetricIndex)
+		for name, metric := range resource.metrics {
+			if !selectsMetric(rule, name) {
+				continue
+			}
+			for _, value := range attributeValues(metric, rule.expandBy) {
+				part, exists := split[value]
+				if !exists {
+					part = resourceMetricIndex{
+						rm:      resource.rm,
+						metrics: make(map[string]pmetric.Metric),
+						scopes:  make(map[string]pmetric.ScopeMetrics),
+					}
+					split[value] = part
+				}
+				selector := &labelSelector{labels: map[string]string{rule.expandBy: value}}
+				part.metrics[name] = filterMetricByLabels(metric, selector)
+				part.scopes[name] = resource.scopes[name]
+			}
+		}
+		for value, part := range split {
+			partitions[value] = append(partitions[value], part)
+		}
+	}
+	return partitions
+}
+
+// attributeValues returns the distinct values of a data point attribute in a metric
+func attributeValues(metric pmetric.Metric, key string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, dp := range extractDataPoints(metric) {
+		value, exists := dp.Attributes().Get(key)
+		if !exists || seen[value.AsString()] {
+			continue
+		}
+		seen[value.AsString()] = true
+		values = append(values, value.AsString())
+	}
+	return values
+}
@@ -0,0 +1,261 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// expandedOutputValues returns the host.name label of every data point of the
+// metrics named name in md, in order
+func expandedOutputValues(md pmetric.Metrics, name string) []string {
+	var values []string
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		sms := md.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				if metrics.At(k).Name() != name {
+					continue
+				}
+				dps := metrics.At(k).Gauge().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					value, _ := dps.At(l).Attributes().Get("host.name")
+					values = append(values, value.AsString())
+				}
+			}
+		}
+	}
+	return values
+}
+
+func startExpandedProcessor(t *testing.T) (*metricsinferenceprocessor, *testutil.MockInferenceServer, *consumertest.MetricsSink) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"cpu"}, OutputPattern: "expanded.{output}", Outputs: []OutputSpec{{Name: "score"}}, ExpandBy: "host.name"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor, mockServer, sink
+}
+
+func TestRuleExpansionByResourceAttribute(t *testing.T) {
+	processor, mockServer, sink := startExpandedProcessor(t)
+
+	md := pmetric.NewMetrics()
+	for _, host := range []string{"b", "a"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host.name", host)
+		metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName("cpu")
+		metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(0.5)
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// One inference per host, rather than the last resource winning
+	assert.Len(t, mockServer.GetRequests(), 2)
+	assert.Equal(t, []string{"b", "a"}, expandedOutputValues(sink.AllMetrics()[0], "expanded.score"))
+	assert.Len(t, processor.expansions[0], 2)
+}
+
+func TestRuleExpansionByDataPointAttribute(t *testing.T) {
+	processor, mockServer, sink := startExpandedProcessor(t)
+
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu")
+	gauge := metric.SetEmptyGauge()
+	for _, host := range []string{"b", "a", ""} {
+		dp := gauge.DataPoints().AppendEmpty()
+		if host != "" {
+			dp.Attributes().PutStr("host.name", host)
+		}
+		dp.SetDoubleValue(0.5)
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// Data points without the attribute are left out, values infer in order
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	for _, request := range requests {
+		assert.Equal(t, []int64{1}, request.Inputs[0].Shape)
+	}
+	assert.Equal(t, []string{"a", "b"}, expandedOutputValues(sink.AllMetrics()[0], "expanded.score"))
+}
+
+func TestRuleExpansionAcrossResources(t *testing.T) {
+	processor, mockServer, sink := startExpandedProcessor(t)
+
+	// Host a reports through two resources, host b through one
+	md := pmetric.NewMetrics()
+	for _, resource := range []struct {
+		host   string
+		source string
+		value  float64
+	}{{"a", "agent", 0.25}, {"b", "agent", 0.5}, {"a", "gateway", 0.75}} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host.name", resource.host)
+		rm.Resource().Attributes().PutStr("source", resource.source)
+		metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName("cpu")
+		metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(resource.value)
+	}
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// Host a infers once per resource rather than the last resource winning
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 3)
+	var values []float64
+	for _, request := range requests {
+		values = append(values, request.Inputs[0].Contents.Fp64Contents...)
+	}
+	assert.ElementsMatch(t, []float64{0.25, 0.5, 0.75}, values)
+	assert.Equal(t, []string{"a", "b", "a"}, expandedOutputValues(sink.AllMetrics()[0], "expanded.score"))
+	assert.Len(t, processor.expansions[0], 3)
+}
+
+func TestRulePerResource(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"cpu", "memory"}, OutputPattern: "host.{output}", Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Every host reports the same data point attributes
+	md := pmetric.NewMetrics()
+	hosts := map[string]float64{"a": 0.25, "b": 0.75, "c": 0.5}
+	for _, host := range []string{"a", "b", "c"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host.name", host)
+		metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+		for _, name := range []string{"cpu", "memory"} {
+			if host == "c" && name == "memory" {
+				continue
+			}
+			metric := metrics.AppendEmpty()
+			metric.SetName(name)
+			dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+			dp.Attributes().PutStr("core", "0")
+			dp.SetDoubleValue(hosts[host])
+		}
+	}
+	// A resource without the rule's inputs is left alone
+	other := md.ResourceMetrics().AppendEmpty()
+	other.Resource().Attributes().PutStr("host.name", "d")
+	other.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("disk")
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// One inference per host carrying the inputs, each fed only its own values
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 3)
+	var cpuValues []float64
+	for _, request := range requests {
+		assert.Equal(t, []int64{1}, request.Inputs[0].Shape)
+		cpuValues = append(cpuValues, request.Inputs[0].Contents.Fp64Contents...)
+	}
+	assert.ElementsMatch(t, []float64{0.25, 0.75, 0.5}, cpuValues)
+
+	// Outputs join the resource of their inputs
+	out := sink.AllMetrics()[0]
+	require.Equal(t, 4, out.ResourceMetrics().Len())
+	for i := 0; i < out.ResourceMetrics().Len(); i++ {
+		rm := out.ResourceMetrics().At(i)
+		host, _ := rm.Resource().Attributes().Get("host.name")
+		scores := 0
+		metrics := rm.ScopeMetrics().At(0).Metrics()
+		for j := 0; j < metrics.Len(); j++ {
+			if metrics.At(j).Name() == "host.score" {
+				scores += metrics.At(j).Gauge().DataPoints().Len()
+			}
+		}
+		if host.AsString() == "d" {
+			assert.Zero(t, scores)
+		} else {
+			assert.Equal(t, 1, scores, "host %s", host.AsString())
+		}
+	}
+	// Hosts with several inputs match their attribute groups apart
+	assert.Len(t, processor.attributeIndexes, 2)
+
+	// and the indexes of hosts gone for longer than the TTL are forgotten
+	processor.attributeIndexLock.Lock()
+	for key, idx := range processor.attributeIndexes {
+		if key.resource == attributeSetKey(md.ResourceMetrics().At(0).Resource().Attributes()) {
+			idx.lastUsed = time.Now().Add(-2 * attributeIndexTTL)
+		}
+	}
+	processor.lastAttributeIndexPrune = time.Now().Add(-2 * attributeIndexTTL)
+	processor.attributeIndexLock.Unlock()
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		host, _ := rm.Resource().Attributes().Get("host.name")
+		return host.AsString() == "a"
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+	assert.Len(t, processor.attributeIndexes, 1)
+}
+
+func TestRuleExpansionEviction(t *testing.T) {
+	processor, err := newMetricsProcessor(&Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"cpu"}, ExpandBy: "pod"}},
+	}, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	idle := processor.ruleExpansion(0, "a", pcommon.NewMap())
+	active := processor.ruleExpansion(0, "b", pcommon.NewMap())
+	assert.Same(t, idle, processor.ruleExpansion(0, "a", pcommon.NewMap()))
+
+	// Values not seen within the TTL are forgotten, and start over when seen again
+	idle.lastSeen = time.Now().Add(-2 * expansionIdleTTL)
+	processor.lastExpansionPrune = time.Now().Add(-2 * expansionIdleTTL)
+	assert.Same(t, active, processor.ruleExpansion(0, "b", pcommon.NewMap()))
+	assert.Len(t, processor.expansions[0], 1)
+	assert.NotSame(t, idle, processor.ruleExpansion(0, "a", pcommon.NewMap()))
+}
+
+func TestValidateExpansion(t *testing.T) {
+	assert.NoError(t, validateExpansion(Rule{ExpandBy: "host.name"}))
+
+	rule := Rule{ExpandBy: "host.name"}
+	rule.Sequence.Enabled = true
+	assert.ErrorContains(t, validateExpansion(rule), "cannot be combined with sequence")
+}
@@ -0,0 +1,256 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// resourceMetricIndex maps metric names to metrics within one ResourceMetrics
+type resourceMetricIndex struct {
+	rm      pmetric.ResourceMetrics
+	metrics map[string]pmetric.Metric       // Metric name -> metric
+	scopes  map[string]pmetric.ScopeMetrics // Metric name -> ScopeMetrics the metric comes from
+}
+
+// indexBatchMetrics builds a name index for every ResourceMetrics in a batch
+func indexBatchMetrics(md pmetric.Metrics) []resourceMetricIndex {
+	resources := make([]resourceMetricIndex, 0, md.ResourceMetrics().Len())
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		resource := resourceMetricIndex{
+			rm:      rm,
+			metrics: make(map[string]pmetric.Metric),
+			scopes:  make(map[string]pmetric.ScopeMetrics),
+		}
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				resource.metrics[metric.Name()] = metric
+				resource.scopes[metric.Name()] = sm
+			}
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+// collectRuleInputs gathers the input metrics of a rule from an indexed batch.
+// When an input appears in several ResourceMetrics, as the resources of an expanded
+// rule's value may, the last one wins.
+// expansion is the attribute value an expanded rule infers for, nil otherwise.
+func (mp *metricsinferenceprocessor) collectRuleInputs(resources []resourceMetricIndex, ruleIdx int, expansion *ruleExpansion) *modelContext {
+	rule := mp.rules[ruleIdx]
+	ruleCtx := &modelContext{
+		inputs:          make(map[string]pmetric.Metric),
+		rule:            rule,
+		inputDataPoints: make(map[string][]dataPoint),
+		ruleIndex:       ruleIdx,
+		expansion:       expansion,
+	}
+
+	for _, resource := range resources {
+		// Collect metrics for this rule based on the inputs specified
+		for inputIdx, inputName := range rule.inputs {
+			selector := rule.inputSelectors[inputIdx]
+			if selector == nil {
+				// Invalid selector, skip this input
+				continue
+			}
+
+			// For backward compatibility, check if this is a simple metric name
+			if !selector.hasFilters() {
+				// No label filters, use simple name matching
+				if metric, exists := resource.metrics[selector.metricName]; exists {
+					metric, ok := mp.deriveSelectorInput(metric, selector, ruleIdx)
+					if !ok {
+						continue
+					}
+					metric, ok = mp.transformInput(ruleCtx, metric, resource.rm, inputName)
+					if !ok {
+						continue
+					}
+					ruleCtx.inputs[inputName] = metric
+
+					// Set ResourceMetrics context for this rule (use first input's context)
+					if !ruleCtx.hasContext {
+						ruleCtx.resourceMetrics = resource.rm
+						ruleCtx.scopeMetrics = resource.scopes[selector.metricName]
+						ruleCtx.hasContext = true
+					}
+					ruleCtx.addInputScope(resource.scopes[selector.metricName])
+
+					// Collect data points for attribute copying
+					dataPoints := extractDataPoints(metric)
+					ruleCtx.inputDataPoints[inputName] = dataPoints
+				}
+			} else {
+				// Label filters specified, need to search through all metrics
+				matched, scopeName, found := selectMetrics(resource, selector)
+				if !found {
+					continue
+				}
+				filteredMetric, ok := mp.deriveSelectorInput(matched, selector, ruleIdx)
+				if !ok {
+					continue
+				}
+				filteredMetric, ok = mp.transformInput(ruleCtx, filteredMetric, resource.rm, inputName)
+				if !ok {
+					continue
+				}
+				ruleCtx.inputs[inputName] = filteredMetric
+
+				// Set ResourceMetrics context for this rule (use first input's context)
+				if !ruleCtx.hasContext {
+					ruleCtx.resourceMetrics = resource.rm
+					ruleCtx.scopeMetrics = resource.scopes[scopeName]
+					ruleCtx.hasContext = true
+				}
+				ruleCtx.addInputScope(resource.scopes[scopeName])
+
+				// Collect data points for attribute copying
+				dataPoints := extractDataPoints(filteredMetric)
+				ruleCtx.inputDataPoints[inputName] = dataPoints
+			}
+		}
+	}
+
+	return ruleCtx
+}
+
+// selectMetrics returns the data points of a resource selected by a selector with
+// label filters, along with the name of the metric whose scope the input belongs
+// to. A name pattern may select several metrics, which are merged in name order.
+func selectMetrics(resource resourceMetricIndex, selector *labelSelector) (pmetric.Metric, string, bool) {
+	var names []string
+	for name, metric := range resource.metrics {
+		if matchesSelector(metric, selector) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return pmetric.Metric{}, "", false
+	}
+	sort.Strings(names)
+
+	if selector.namePattern == nil {
+		return filterMetricByLabels(resource.metrics[names[0]], selector), names[0], true
+	}
+	metrics := make([]pmetric.Metric, len(names))
+	for i, name := range names {
+		metrics[i] = filterMetricByLabels(resource.metrics[name], selector)
+	}
+	return mergeMatchedMetrics(metrics), names[0], true
+}
+
+// buildRuleGraph orders rules so that every rule runs after the rules producing its
+// inputs, keeping configuration order among independent rules. Rules are also grouped
+// into stages, where every rule only consumes outputs of earlier stages, so the rules
+// of a stage can run concurrently. It also reports which rules feed other rules.
+// Output names must be final, so the graph is rebuilt after outputs are discovered
+// from model metadata.
+func (mp *metricsinferenceprocessor) buildRuleGraph() (order []int, stages [][]int, hasDependents []bool, err error) {
+	// Map each output metric name to the rule producing it
+	producers := make(map[string]int)
+	for ruleIdx := range mp.rules {
+		rule := &mp.rules[ruleIdx]
+		for outputIdx, output := range rule.outputs {
+			name := mp.outputMetricName(rule, outputIdx, output, "")
+			producers[name] = ruleIdx
+			// Split shaped outputs produce one metric per named column
+			if output.columns == columnsModeSplit {
+				for _, column := range output.columnNames {
+					producers[name+"."+column] = ruleIdx
+				}
+			}
+		}
+	}
+
+	// dependents[a] lists the rules consuming outputs of rule a
+	dependents := make([][]int, len(mp.rules))
+	inDegree := make([]int, len(mp.rules))
+	hasDependents = make([]bool, len(mp.rules))
+	for ruleIdx, rule := range mp.rules {
+		upstream := make(map[int]bool)
+		for _, selector := range rule.inputSelectors {
+			if selector == nil {
+				continue
+			}
+			producer, exists := producers[selector.metricName]
+			if !exists || upstream[producer] {
+				continue
+			}
+			if producer == ruleIdx {
+				return nil, nil, nil, fmt.Errorf("rule %d (model %s) consumes its own output %q", ruleIdx, rule.modelName, selector.metricName)
+			}
+			if mp.rules[producer].shadow != nil {
+				return nil, nil, nil, fmt.Errorf("rule %d (model %s) consumes output %q of shadow rule %d, which is not added to the batch",
+					ruleIdx, rule.modelName, selector.metricName, producer)
+			}
+			upstream[producer] = true
+			dependents[producer] = append(dependents[producer], ruleIdx)
+			hasDependents[producer] = true
+			inDegree[ruleIdx]++
+		}
+	}
+
+	// Kahn's algorithm, always taking the lowest ready rule index. A rule's stage
+	// is one past the latest stage of the rules it consumes.
+	level := make([]int, len(mp.rules))
+	var ready []int
+	for ruleIdx, degree := range inDegree {
+		if degree == 0 {
+			ready = append(ready, ruleIdx)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		ruleIdx := ready[0]
+		ready = ready[1:]
+		order = append(order, ruleIdx)
+		for _, dependent := range dependents[ruleIdx] {
+			level[dependent] = max(level[dependent], level[ruleIdx]+1)
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) < len(mp.rules) {
+		var cyclic []string
+		for ruleIdx, degree := range inDegree {
+			if degree > 0 {
+				cyclic = append(cyclic, fmt.Sprintf("%d (%s)", ruleIdx, mp.rules[ruleIdx].modelName))
+			}
+		}
+		return nil, nil, nil, fmt.Errorf("rules form a dependency cycle: %s", strings.Join(cyclic, ", "))
+	}
+
+	for _, ruleIdx := range order {
+		for len(stages) <= level[ruleIdx] {
+			stages = append(stages, nil)
+		}
+		stages[level[ruleIdx]] = append(stages[level[ruleIdx]], ruleIdx)
+	}
+
+	return order, stages, hasDependents, nil
+}
+
+// updateRuleGraph rebuilds the rule execution order from the current rule outputs
+func (mp *metricsinferenceprocessor) updateRuleGraph() error {
+	order, stages, hasDependents, err := mp.buildRuleGraph()
+	if err != nil {
+		return fmt.Errorf("invalid rule dependencies: %w", err)
+	}
+	mp.ruleOrder = order
+	mp.ruleStages = stages
+	mp.ruleHasDependents = hasDependents
+	return nil
+}
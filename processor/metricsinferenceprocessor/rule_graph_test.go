@@ -0,0 +1,154 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// chainedRules returns a downstream rule configured before the rule producing its input
+func chainedRules() []Rule {
+	return []Rule{
+		{
+			ModelName:     "anomaly_model",
+			Inputs:        []string{"features.embedding", "metric_2"},
+			Outputs:       []OutputSpec{{Name: "score"}},
+			OutputPattern: "anomaly.{output}",
+		},
+		{
+			ModelName:     "feature_model",
+			Inputs:        []string{"metric_1"},
+			Outputs:       []OutputSpec{{Name: "embedding"}},
+			OutputPattern: "features.{output}",
+		},
+		{
+			ModelName:     "independent_model",
+			Inputs:        []string{"metric_2"},
+			Outputs:       []OutputSpec{{Name: "plain"}},
+			OutputPattern: "independent.{output}",
+		},
+	}
+}
+
+func TestRuleGraphOrder(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              chainedRules(),
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 0, 2}, processor.ruleOrder, "producers run before their consumers")
+	assert.Equal(t, [][]int{{1, 2}, {0}}, processor.ruleStages, "independent rules share a stage")
+	assert.Equal(t, []bool{false, true, false}, processor.ruleHasDependents)
+}
+
+func TestRuleGraphCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   string
+	}{
+		{
+			name: "self reference",
+			rules: []Rule{
+				{ModelName: "loop", Inputs: []string{"loop.out"}, Outputs: []OutputSpec{{Name: "out"}}, OutputPattern: "loop.{output}"},
+			},
+			err: "consumes its own output",
+		},
+		{
+			name: "two rule cycle",
+			rules: []Rule{
+				{ModelName: "a", Inputs: []string{"b.out"}, Outputs: []OutputSpec{{Name: "out"}}, OutputPattern: "a.{output}"},
+				{ModelName: "b", Inputs: []string{"a.out"}, Outputs: []OutputSpec{{Name: "out"}}, OutputPattern: "b.{output}"},
+			},
+			err: "dependency cycle: 0 (a), 1 (b)",
+		},
+		{
+			name: "shadow producer",
+			rules: []Rule{
+				{ModelName: "a", Inputs: []string{"b.out"}, Outputs: []OutputSpec{{Name: "out"}}, OutputPattern: "a.{output}"},
+				{ModelName: "b", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "out"}}, OutputPattern: "b.{output}", Mode: "shadow"},
+			},
+			err: "consumes output \"b.out\" of shadow rule 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+				Rules:              tt.rules,
+			}
+			_, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestChainedRulesWithinBatch(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("feature_model",
+		testutil.CreateMockResponseForCalculation("feature_model", 7.5))
+	mockServer.SetModelResponse("anomaly_model",
+		testutil.CreateMockResponseForCalculation("anomaly_model", 0.9))
+	mockServer.SetModelResponse("independent_model",
+		testutil.CreateMockResponseForCalculation("independent_model", 1.0))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules:              chainedRules(),
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1", "metric_2"},
+		MetricValues: [][]float64{{1}, {2}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Independent rules infer concurrently, so only producers are ordered before consumers
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 3)
+	order := make(map[string]int)
+	for i, request := range requests {
+		order[request.ModelName] = i
+	}
+	require.Len(t, order, 3)
+	assert.Less(t, order["feature_model"], order["anomaly_model"])
+
+	// The downstream request carries the upstream prediction as an input
+	var embedding []float64
+	for _, tensor := range requests[order["anomaly_model"]].Inputs {
+		if tensor.Name == "features.embedding" {
+			embedding = tensor.Contents.Fp64Contents
+		}
+	}
+	assert.Equal(t, []float64{7.5}, embedding)
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 7.5, findMetricByName(output, "features.embedding").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, 0.9, findMetricByName(output, "anomaly.score").Gauge().DataPoints().At(0).DoubleValue())
+}
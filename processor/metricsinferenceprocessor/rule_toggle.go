@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/featuregate"
+)
+
+// ruleEnabled reports whether a rule is enabled in the configuration, which it
+// is unless enabled is set to false
+func ruleEnabled(rule Rule) bool {
+	return rule.Enabled == nil || *rule.Enabled
+}
+
+// RegisterRuleGate registers a feature gate that rules can reference with
+// feature_gate, as a beta gate, enabled until it is switched off. Gates are set
+// by --feature-gates when the collector starts, after every package is
+// initialized, so distributions call it from an init function. Registering a
+// gate again returns the registered one.
+func RegisterRuleGate(id string) (*featuregate.Gate, error) {
+	return registerRuleGate(featuregate.GlobalRegistry(), id)
+}
+
+// registerRuleGate registers a rule gate with a registry, or returns the gate
+// already registered with the ID
+func registerRuleGate(registry *featuregate.Registry, id string) (*featuregate.Gate, error) {
+	gate, err := registry.Register(id, featuregate.StageBeta,
+		featuregate.WithRegisterDescription("When disabled, the metrics inference rules toggled by this gate do not infer"))
+	if !errors.Is(err, featuregate.ErrAlreadyRegistered) {
+		return gate, err
+	}
+	return lookupGate(registry, id), nil
+}
+
+// lookupGate returns the gate of a registry with the ID, nil when none is registered
+func lookupGate(registry *featuregate.Registry, id string) *featuregate.Gate {
+	var gate *featuregate.Gate
+	registry.VisitAll(func(g *featuregate.Gate) {
+		if g.ID() == id {
+			gate = g
+		}
+	})
+	return gate
+}
+
+// ruleGate returns the feature gate toggling a rule, which must have been
+// registered, by RegisterRuleGate or another component, when the collector
+// started: a gate registered later could never be set by --feature-gates.
+func ruleGate(registry *featuregate.Registry, id string) (*featuregate.Gate, error) {
+	gate := lookupGate(registry, id)
+	if gate == nil {
+		return nil, fmt.Errorf("feature gate %q is not registered; register it with RegisterRuleGate when the collector starts", id)
+	}
+	return gate, nil
+}
+
+// resolveRuleGates looks up the feature gates toggling rules, by rule index
+func resolveRuleGates(cfg *Config, rules []internalRule) error {
+	for i, rule := range cfg.Rules {
+		if rule.FeatureGate == "" {
+			continue
+		}
+		gate, err := ruleGate(featuregate.GlobalRegistry(), rule.FeatureGate)
+		if err != nil {
+			return fmt.Errorf("invalid feature_gate in rule %d: %w", i, err)
+		}
+		rules[i].gate = gate
+	}
+	return nil
+}
+
+// active reports whether a rule infers on the current batch: it must be
+// enabled, its feature gate, when it has one, must be enabled now, and its
+// model selector, when it has one, must have resolved to a model. Aggregation
+// rules need no model.
+func (rule internalRule) active() bool {
+	if rule.modelName == "" && rule.aggregate == nil {
+		return false
+	}
+	return rule.enabled && (rule.gate == nil || rule.gate.IsEnabled())
+}
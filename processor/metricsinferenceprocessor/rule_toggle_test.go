@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/featuregate"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestRuleToggles(t *testing.T) {
+	const gateID = "metricsinference.test.ruleToggles"
+	_, err := RegisterRuleGate(gateID)
+	require.NoError(t, err)
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)))
+
+	disabled := false
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "disabled.{output}", Outputs: []OutputSpec{{Name: "score"}}, Enabled: &disabled},
+			{ModelName: "scorer", Inputs: []string{"metric_1"}, OutputPattern: "gated.{output}", Outputs: []OutputSpec{{Name: "score"}}, FeatureGate: gateID},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	// Unregistered gates could never be set, and are rejected
+	cfg.Rules[1].FeatureGate = "metricsinference.test.unregistered"
+	_, err = newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, `feature gate "metricsinference.test.unregistered" is not registered`)
+	cfg.Rules[1].FeatureGate = gateID
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	consume := func() {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+
+	// The gate is registered enabled, the disabled rule never infers
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Empty(t, findMetricByName(output, "disabled.score").Name())
+	assert.Equal(t, "gated.score", findMetricByName(output, "gated.score").Name())
+
+	// Switching the gate off at runtime stops the rule from the next batch on
+	require.NoError(t, featuregate.GlobalRegistry().Set(gateID, false))
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 1)
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[1], "gated.score").Name())
+
+	require.NoError(t, featuregate.GlobalRegistry().Set(gateID, true))
+	consume()
+	assert.Len(t, mockServer.GetRequests(), 2)
+	assert.Equal(t, "gated.score", findMetricByName(sink.AllMetrics()[2], "gated.score").Name())
+}
+
+func TestRuleGate(t *testing.T) {
+	registry := featuregate.NewRegistry()
+	registered := registry.MustRegister("metricsinference.test.registered", featuregate.StageAlpha)
+
+	// Gates registered by other components are reused, keeping their stage
+	gate, err := ruleGate(registry, "metricsinference.test.registered")
+	require.NoError(t, err)
+	assert.Same(t, registered, gate)
+	assert.False(t, gate.IsEnabled())
+
+	_, err = ruleGate(registry, "metricsinference.test.new")
+	assert.ErrorContains(t, err, "is not registered")
+
+	gate, err = registerRuleGate(registry, "metricsinference.test.new")
+	require.NoError(t, err)
+	assert.True(t, gate.IsEnabled())
+	again, err := registerRuleGate(registry, "metricsinference.test.new")
+	require.NoError(t, err)
+	assert.Same(t, gate, again)
+	found, err := ruleGate(registry, "metricsinference.test.new")
+	require.NoError(t, err)
+	assert.Same(t, gate, found)
+
+	_, err = registerRuleGate(registry, "not a gate")
+	assert.ErrorContains(t, err, "invalid ID")
+}
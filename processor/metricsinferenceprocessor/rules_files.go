@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/collector/confmap"
+	"gopkg.in/yaml.v3"
+)
+
+// rulesFile is the layout of a file listed in rules_files
+type rulesFile struct {
+	Rules []Rule `mapstructure:"rules"`
+}
+
+// ruleSource identifies where a rule was defined, for conflict messages
+type ruleSource struct {
+	file  string // Empty for inline rules
+	index int
+}
+
+func (s ruleSource) String() string {
+	if s.file == "" {
+		return fmt.Sprintf("inline rule %d", s.index)
+	}
+	return fmt.Sprintf("rule %d of %s", s.index, s.file)
+}
+
+// withRuleFiles returns the configuration with the rules of its rules files
+// appended to the inline rules. The configuration itself is returned when no
+// rules files are configured.
+func (cfg *Config) withRuleFiles() (*Config, error) {
+	if len(cfg.RulesFiles) == 0 {
+		return cfg, nil
+	}
+
+	files, err := expandRulesFiles(cfg.RulesFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, len(cfg.Rules), len(cfg.Rules)+len(files))
+	copy(rules, cfg.Rules)
+	sources := make([]ruleSource, len(cfg.Rules))
+	for i := range cfg.Rules {
+		sources[i] = ruleSource{index: i}
+	}
+
+	for _, file := range files {
+		fileRules, err := loadRulesFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for i, rule := range fileRules {
+			source := ruleSource{file: file, index: i}
+			if err := checkRuleConflicts(rules, sources, rule, source); err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+			sources = append(sources, source)
+		}
+	}
+
+	expanded := *cfg
+	expanded.Rules = rules
+	expanded.RulesFiles = nil
+	return &expanded, nil
+}
+
+// expandRulesFiles resolves the configured paths and glob patterns to files.
+// Every entry must match at least one file, and files matched by several
+// entries are loaded once.
+func expandRulesFiles(patterns []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules_files pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("rules_files entry %q matches no files", pattern)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// loadRulesFile reads the rules of one rules file. Rules are decoded like the
+// inline rules of the collector configuration, so unknown keys are rejected.
+func loadRulesFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	return decodeRules(data, "rules file "+path)
+}
+
+// decodeRules decodes a YAML or JSON document with rules under a top-level
+// "rules" key, like rules files and remote rules are. Errors name the document
+// as source.
+func decodeRules(data []byte, source string) ([]Rule, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+
+	var file rulesFile
+	if err := confmap.NewFromStringMap(raw).Unmarshal(&file); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	return file.Rules, nil
+}
+
+// checkRuleConflicts reports a rule that duplicates an earlier rule (same model,
+// version and inputs) or that declares an output name already declared for the
+// same model, since both would produce the same output metrics
+func checkRuleConflicts(rules []Rule, sources []ruleSource, rule Rule, source ruleSource) error {
+	for i, existing := range rules {
+		if existing.ModelName != rule.ModelName || existing.ModelVersion != rule.ModelVersion {
+			continue
+		}
+		if strings.Join(existing.configuredInputs(), "\x00") == strings.Join(rule.configuredInputs(), "\x00") {
+			return fmt.Errorf("%s duplicates %s (model %q with the same inputs)", source, sources[i], rule.ModelName)
+		}
+		for _, output := range rule.Outputs {
+			if output.Name == "" {
+				continue
+			}
+			for _, existingOutput := range existing.Outputs {
+				if existingOutput.Name == output.Name {
+					return fmt.Errorf("%s conflicts with %s: both declare output %q of model %q", source, sources[i], output.Name, rule.ModelName)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+)
+
+// writeRulesFile writes a rules file into dir and returns its path
+func writeRulesFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRulesFilesMerge(t *testing.T) {
+	dir := t.TempDir()
+	writeRulesFile(t, dir, "10-capacity.yaml", `
+rules:
+  - model_name: capacity
+    inputs: [cpu.utilization]
+    outputs:
+      - name: headroom
+`)
+	writeRulesFile(t, dir, "20-anomaly.yaml", `
+rules:
+  - model_name: anomaly
+    inputs: [http.requests]
+    transforms:
+      http.requests:
+        as: rate
+        stale_after: 2m
+`)
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "inline", Inputs: []string{"memory.usage"}}},
+		RulesFiles:         []string{filepath.Join(dir, "*.yaml"), filepath.Join(dir, "10-capacity.yaml")},
+	}
+	require.NoError(t, cfg.Validate())
+
+	expanded, err := cfg.withRuleFiles()
+	require.NoError(t, err)
+	require.Len(t, expanded.Rules, 3)
+	assert.Equal(t, "inline", expanded.Rules[0].ModelName)
+	assert.Equal(t, "capacity", expanded.Rules[1].ModelName)
+	assert.Equal(t, "headroom", expanded.Rules[1].Outputs[0].Name)
+	assert.Equal(t, "anomaly", expanded.Rules[2].ModelName)
+	assert.Equal(t, inputAsRate, expanded.Rules[2].Transforms["http.requests"].As)
+	assert.Len(t, cfg.Rules, 1, "the configuration itself must not change")
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Len(t, processor.rules, 3)
+}
+
+func TestRulesFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	capacity := writeRulesFile(t, dir, "capacity.yaml", `
+rules:
+  - model_name: capacity
+    inputs: [cpu.utilization]
+    outputs:
+      - name: headroom
+`)
+
+	tests := []struct {
+		name    string
+		inline  []Rule
+		pattern string // Used instead of the capacity file when set
+		file    string // Written and used instead of the capacity file when set
+		wantErr string
+	}{
+		{
+			name:    "no matching files",
+			pattern: filepath.Join(dir, "missing", "*.yaml"),
+			wantErr: "matches no files",
+		},
+		{
+			name:    "unknown key",
+			file:    "rules:\n  - model_name: m\n    inputz: [x]\n",
+			wantErr: "invalid rules file",
+		},
+		{
+			name:    "malformed yaml",
+			file:    "rules: [",
+			wantErr: "failed to parse rules file",
+		},
+		{
+			name:    "invalid rule",
+			file:    "rules:\n  - model_name: m\n    inputs: [x]\n    input_prefix: system.\n",
+			wantErr: "input_prefix in rule 0 requires inputs to be omitted",
+		},
+		{
+			name:    "duplicate rule",
+			inline:  []Rule{{ModelName: "capacity", Inputs: []string{"cpu.utilization"}}},
+			wantErr: "rule 0 of " + capacity + " duplicates inline rule 0",
+		},
+		{
+			name:    "conflicting output",
+			inline:  []Rule{{ModelName: "capacity", Inputs: []string{"cpu.limit"}, Outputs: []OutputSpec{{Name: "headroom"}}}},
+			wantErr: "both declare output \"headroom\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := []string{capacity}
+			switch {
+			case tt.pattern != "":
+				files = []string{tt.pattern}
+			case tt.file != "":
+				files = []string{writeRulesFile(t, t.TempDir(), "rules.yaml", tt.file)}
+			}
+
+			cfg := &Config{
+				GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+				Rules:              tt.inline,
+				RulesFiles:         files,
+			}
+			assert.ErrorContains(t, cfg.Validate(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	// defaultSamplingHintAttribute marks anomalous output data points when no
+	// attribute is configured
+	defaultSamplingHintAttribute = "otel.inference.sampling.boost"
+
+	// labelOutputName identifies the output a sampling marker data point refers to
+	labelOutputName = "otel.inference.output.name"
+
+	// serviceNameAttr is the resource attribute sampling markers are keyed by
+	serviceNameAttr = "service.name"
+)
+
+// samplingHint is the internal form of an output's sampling hint configuration
+type samplingHint struct {
+	threshold float64
+	attribute string
+	metric    string
+}
+
+// newSamplingHint converts a sampling hint configuration, applying defaults.
+// It returns nil when the output has no sampling hint.
+func newSamplingHint(cfg *SamplingHintConfig) *samplingHint {
+	if cfg == nil {
+		return nil
+	}
+	hint := &samplingHint{threshold: cfg.Threshold, attribute: cfg.Attribute, metric: cfg.Metric}
+	if hint.attribute == "" {
+		hint.attribute = defaultSamplingHintAttribute
+	}
+	return hint
+}
+
+// validateSamplingHintConfig checks an output's sampling hint configuration
+func validateSamplingHintConfig(cfg *SamplingHintConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Metric != "" && cfg.Metric == cfg.Attribute {
+		return fmt.Errorf("metric and attribute must differ")
+	}
+	return nil
+}
+
+// apply flags the data points of the output metrics from firstMetric on whose
+// value exceeds the threshold, and emits the marker metric when configured.
+// The marker has one data point for the service of the resource, with value 1
+// when any output data point is anomalous and 0 otherwise.
+func (h *samplingHint) apply(sm pmetric.ScopeMetrics, firstMetric int, outputName, modelName string, resource pcommon.Resource) {
+	anomalous := false
+	for i := firstMetric; i < sm.Metrics().Len(); i++ {
+		var dps pmetric.NumberDataPointSlice
+		metric := sm.Metrics().At(i)
+		switch metric.Type() {
+		case pmetric.MetricTypeGauge:
+			dps = metric.Gauge().DataPoints()
+		case pmetric.MetricTypeSum:
+			dps = metric.Sum().DataPoints()
+		default:
+			continue
+		}
+		for j := 0; j < dps.Len(); j++ {
+			if dataPointValue(dps.At(j)) > h.threshold {
+				dps.At(j).Attributes().PutBool(h.attribute, true)
+				anomalous = true
+			}
+		}
+	}
+
+	if h.metric == "" {
+		return
+	}
+	marker := sm.Metrics().AppendEmpty()
+	marker.SetName(h.metric)
+	marker.SetUnit("1")
+	marker.SetDescription(fmt.Sprintf("1 while %s exceeds %g, for boosting trace sampling", outputName, h.threshold))
+	dp := marker.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	if service, ok := resource.Attributes().Get(serviceNameAttr); ok {
+		dp.Attributes().PutStr(serviceNameAttr, service.AsString())
+	}
+	nameKey, _ := modelLabelKeys()
+	dp.Attributes().PutStr(nameKey, modelName)
+	dp.Attributes().PutStr(labelOutputName, outputName)
+	if anomalous {
+		dp.SetIntValue(1)
+	} else {
+		dp.SetIntValue(0)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestSamplingHint(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+	mockServer.SetModelResponse("anomaly", &pb.ModelInferResponse{
+		ModelName: "anomaly",
+		Outputs: []*pb.ModelInferResponse_InferOutputTensor{{
+			Name:     "score",
+			Datatype: "FP64",
+			Shape:    []int64{2},
+			Contents: &pb.InferTensorContents{Fp64Contents: []float64{0.2, 0.9}},
+		}},
+	})
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "anomaly",
+				Inputs:        []string{"latency"},
+				OutputPattern: "{output}",
+				Outputs: []OutputSpec{{
+					Name:         "anomaly.score",
+					SamplingHint: &SamplingHintConfig{Threshold: 0.5, Metric: "anomaly.sampling.boost"},
+				}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{{
+		MetricName: "latency",
+		DataPoints: []testutil.TestDataPoint{
+			{Value: 10, Attributes: map[string]string{"route": "/a"}},
+			{Value: 90, Attributes: map[string]string{"route": "/b"}},
+		},
+	}})
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr("service.name", "checkout")
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	out := sink.AllMetrics()[0]
+	scores := findMetricByName(out, "anomaly.score").Gauge().DataPoints()
+	require.Equal(t, 2, scores.Len())
+	boosted := 0
+	for i := 0; i < scores.Len(); i++ {
+		flag, ok := scores.At(i).Attributes().Get(defaultSamplingHintAttribute)
+		if scores.At(i).DoubleValue() > 0.5 {
+			require.True(t, ok)
+			assert.True(t, flag.Bool())
+			boosted++
+		} else {
+			assert.False(t, ok)
+		}
+	}
+	assert.Equal(t, 1, boosted)
+
+	marker := findMetricByName(out, "anomaly.sampling.boost").Gauge().DataPoints()
+	require.Equal(t, 1, marker.Len())
+	assert.Equal(t, int64(1), marker.At(0).IntValue())
+	service, ok := marker.At(0).Attributes().Get("service.name")
+	require.True(t, ok)
+	assert.Equal(t, "checkout", service.Str())
+	output, ok := marker.At(0).Attributes().Get(labelOutputName)
+	require.True(t, ok)
+	assert.Equal(t, "anomaly.score", output.Str())
+}
+
+func TestValidateSamplingHintConfig(t *testing.T) {
+	assert.NoError(t, validateSamplingHintConfig(nil))
+	assert.NoError(t, validateSamplingHintConfig(&SamplingHintConfig{Threshold: 3, Metric: "boost"}))
+	assert.ErrorContains(t, validateSamplingHintConfig(&SamplingHintConfig{Metric: "boost", Attribute: "boost"}), "must differ")
+	assert.Nil(t, newSamplingHint(nil))
+	assert.Equal(t, defaultSamplingHintAttribute, newSamplingHint(&SamplingHintConfig{}).attribute)
+}
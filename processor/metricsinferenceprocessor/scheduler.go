@@ -0,0 +1,326 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Reasons a rule's inference is skipped for a batch
+const (
+	skipReasonDeadline         = "deadline"
+	skipReasonMaxBatchDelay    = "max_batch_delay"
+	skipReasonPipelineDeadline = "pipeline_deadline"
+	skipReasonBudget           = "budget"
+	skipReasonQueueFull        = "queue_full"
+)
+
+// defaultInferTimeout is the request timeout when none is configured
+const defaultInferTimeout = 10 * time.Second
+
+// validateScheduling checks a rule's concurrency limit and deadline
+func validateScheduling(rule Rule) error {
+	if rule.MaxInFlight < 0 {
+		return fmt.Errorf("max_in_flight must not be negative")
+	}
+	if rule.Deadline < 0 {
+		return fmt.Errorf("deadline must not be negative")
+	}
+	if rule.MaxGroupsPerRequest < 0 {
+		return fmt.Errorf("max_groups_per_request must not be negative")
+	}
+	if rule.Queue != nil {
+		if rule.Queue.QueueSize <= 0 {
+			return fmt.Errorf("queue.queue_size must be positive")
+		}
+		if err := validateQueueConfig(*rule.Queue); err != nil {
+			return fmt.Errorf("invalid queue: %w", err)
+		}
+	}
+	return nil
+}
+
+// newInFlightSlots creates the slots limiting a rule's concurrent requests, or
+// returns nil when the rule is not limited
+func newInFlightSlots(maxInFlight int) chan struct{} {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return make(chan struct{}, maxInFlight)
+}
+
+// ruleCall is the inference of one rule within a batch
+type ruleCall struct {
+	ruleIdx    int
+	ctx        *modelContext
+	request    *pb.ModelInferRequest
+	response   *pb.ModelInferResponse
+	err        error
+	latency    time.Duration     // Duration of the inference call, excluding the wait for a slot
+	span       trace.SpanContext // Span of the inference call, invalid when it was not sent
+	skipped    string            // Why the inference was skipped, empty when it completed or failed
+	challenger *ruleCall         // The same request sent to the rule's challenger, nil when none
+	abandoned  bool              // Whether the batch gave up on the call while it was still running
+}
+
+// ruleCallResult is the outcome of a rule call, delivered by its goroutine
+type ruleCallResult struct {
+	index    int
+	response *pb.ModelInferResponse
+	err      error
+	latency  time.Duration
+	span     trace.SpanContext
+}
+
+// batchContext returns the context bounding a batch's inference by the maximum
+// batch delay, or by the deadline of ctx when that is earlier
+func (mp *metricsinferenceprocessor) batchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && (mp.config.MaxBatchDelay <= 0 || time.Until(deadline) < mp.config.MaxBatchDelay) {
+		return context.WithCancel(context.WithValue(ctx, pipelineDeadlineKey{}, true))
+	}
+	if mp.config.MaxBatchDelay <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, mp.config.MaxBatchDelay)
+}
+
+// inferStage runs the inference calls of one rule stage, including challenger
+// calls, concurrently, on the inference queue when one is configured. It
+// returns once every call has finished or the batch is out of time; calls
+// still running then are skipped, and their results are dropped when they
+// arrive.
+func (mp *metricsinferenceprocessor) inferStage(batchCtx context.Context, client InferenceClient, stage []*ruleCall) {
+	calls := make([]*ruleCall, 0, len(stage))
+	for _, call := range stage {
+		// Calls whose request could not be built are never sent
+		if call.request == nil {
+			continue
+		}
+		calls = append(calls, call)
+		if call.challenger != nil {
+			calls = append(calls, call.challenger)
+		}
+	}
+	if len(calls) == 0 {
+		return
+	}
+	if batchCtx.Err() != nil {
+		for _, call := range calls {
+			call.skipped = mp.skipReason(batchCtx, call, batchCtx.Err())
+			call.err = batchCtx.Err()
+		}
+		return
+	}
+
+	// Calls only hand results back, so abandoned calls never touch the batch
+	results := make(chan ruleCallResult, len(calls))
+	for i, call := range calls {
+		rule := call.ctx.rule
+		submitted := time.Now()
+		run := func() {
+			if err := batchCtx.Err(); err != nil {
+				results <- ruleCallResult{index: i, err: err}
+				return
+			}
+			result := mp.inferRule(batchCtx, client, call)
+			mp.telemetry.recordRuleLatency(batchCtx, rule.id, call.request.ModelName, time.Since(submitted))
+			result.index = i
+			results <- result
+		}
+
+		// Rules with a queue of their own do not wait behind the calls of other rules
+		queue := mp.queue
+		if rule.queue != nil {
+			queue = rule.queue
+		}
+		if queue == nil {
+			go run()
+			continue
+		}
+		queue.submit(batchCtx, queuedCall{
+			run:      run,
+			drop:     func(err error) { results <- ruleCallResult{index: i, err: err} },
+			rule:     rule.id,
+			priority: rule.priority,
+		})
+	}
+
+	done := make([]bool, len(calls))
+	for pending := len(calls); pending > 0; pending-- {
+		select {
+		case result := <-results:
+			call := calls[result.index]
+			call.response, call.err, call.latency, call.span = result.response, result.err, result.latency, result.span
+			call.skipped = mp.skipReason(batchCtx, call, result.err)
+			done[result.index] = true
+		case <-batchCtx.Done():
+			for i, call := range calls {
+				if !done[i] {
+					call.abandoned = true
+					call.err = batchCtx.Err()
+					call.skipped = mp.skipReason(batchCtx, call, batchCtx.Err())
+				}
+			}
+			return
+		}
+	}
+}
+
+// inferRule sends a rule's request within the rule's time budget, once a slot is
+// free when the rule limits its in-flight requests. Synthetic and local rules
+// generate their predictions in-process; other rules go to the inference server,
+// reusing cached results when possible. The result also holds how long the call
+// took and its span.
+func (mp *metricsinferenceprocessor) inferRule(batchCtx context.Context, client InferenceClient, call *ruleCall) ruleCallResult {
+	rule := call.ctx.rule
+	inferCtx, cancel := context.WithTimeout(batchCtx, mp.ruleTimeout(rule))
+	defer cancel()
+
+	if rule.inFlight != nil {
+		select {
+		case rule.inFlight <- struct{}{}:
+			defer func() { <-rule.inFlight }()
+		case <-inferCtx.Done():
+			return ruleCallResult{err: fmt.Errorf("no in-flight slot became free: %w", inferCtx.Err())}
+		}
+	}
+
+	// Add headers, resolving templated ones from the resource of the rule's inputs
+	resource := pcommon.NewResource()
+	if call.ctx.hasContext {
+		resource = call.ctx.resourceMetrics.Resource()
+	}
+	inferCtx, missing := mp.headers.withResource(inferCtx, resource, rule.privacy)
+	if len(missing) > 0 {
+		if mp.config.GRPCClientSettings.MissingHeaderAttributes != missingHeaderAttributesOmit {
+			return ruleCallResult{err: fmt.Errorf("%w: %v", errMissingHeaderAttributes, missing)}
+		}
+		mp.logLimiter.Warn(call.ruleIdx, "Resource attributes of templated gRPC headers not found, sending the request without those headers",
+			zap.String("model", call.request.ModelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Strings("attributes", missing))
+	}
+
+	// Requests with more attribute groups than the rule allows are sent in parts
+	requests, err := mp.splitRuleRequest(call)
+	if err != nil {
+		return ruleCallResult{err: err}
+	}
+
+	// Requests over the send limit would be rejected by the client, fail them with their size
+	if rule.backend == nil {
+		for _, request := range requests {
+			if err := mp.checkRequestSize(request); err != nil {
+				return ruleCallResult{err: err}
+			}
+		}
+	}
+
+	// Calls that would outlast the batch's remaining time are not sent
+	if err := mp.checkBudget(inferCtx, call.request.ModelName); err != nil {
+		return ruleCallResult{err: err}
+	}
+
+	var response *pb.ModelInferResponse
+	started := time.Now()
+	inferCtx, span := mp.telemetry.startInferSpan(inferCtx, call.request)
+	switch {
+	case rule.backend != nil:
+		response, err = rule.backend.ModelInfer(withSeriesKeys(inferCtx, resource.Attributes(), call.ctx.matchedDataPoints, call.ctx.expansion), call.request)
+	case len(requests) > 1:
+		response, err = mp.inferParts(inferCtx, client, call, requests)
+	default:
+		response, err = mp.inferWithCache(inferCtx, client, call.ruleIdx, call.request)
+	}
+	mp.telemetry.endInferSpan(span, err)
+	latency := time.Since(started)
+	if err == nil {
+		mp.latencies.record(call.request.ModelName, latency)
+	}
+	return ruleCallResult{response: response, err: err, latency: latency, span: span.SpanContext()}
+}
+
+// checkRequestSize fails a request larger than the configured max_send_message_size,
+// such as one carrying a long window of data points
+func (mp *metricsinferenceprocessor) checkRequestSize(request *pb.ModelInferRequest) error {
+	limit := mp.config.GRPCClientSettings.MaxSendMessageSize
+	if limit <= 0 {
+		return nil
+	}
+	if size := proto.Size(request); size > limit {
+		return fmt.Errorf("request of %d bytes exceeds max_send_message_size of %d bytes", size, limit)
+	}
+	return nil
+}
+
+// ruleTimeout returns the timeout of a rule's request: the configured request
+// timeout, shortened to the rule's deadline
+func (mp *metricsinferenceprocessor) ruleTimeout(rule internalRule) time.Duration {
+	timeout := defaultInferTimeout
+	if mp.config.Timeout > 0 {
+		timeout = time.Duration(mp.config.Timeout) * time.Second
+	}
+	if rule.deadline > 0 && rule.deadline < timeout {
+		return rule.deadline
+	}
+	return timeout
+}
+
+// skipReason classifies a failed call as skipped when it was dropped by the
+// inference queue, was not sent for lack of time, or ran out of the pipeline's,
+// the batch's or the rule's time. Other failures, including the plain request
+// timeout of rules without a deadline, are ordinary inference errors.
+func (mp *metricsinferenceprocessor) skipReason(batchCtx context.Context, call *ruleCall, err error) string {
+	if errors.Is(err, errQueueFull) {
+		return skipReasonQueueFull
+	}
+	if errors.Is(err, errInsufficientBudget) {
+		return skipReasonBudget
+	}
+	if errors.Is(err, errMissingHeaderAttributes) {
+		return skipReasonMissingHeaderAttributes
+	}
+	if err == nil || !isDeadlineError(err) {
+		return ""
+	}
+	if errors.Is(batchCtx.Err(), context.DeadlineExceeded) {
+		if boundedByPipeline(batchCtx) {
+			return skipReasonPipelineDeadline
+		}
+		if mp.config.MaxBatchDelay > 0 {
+			return skipReasonMaxBatchDelay
+		}
+	}
+	if call.ctx.rule.deadline > 0 {
+		return skipReasonDeadline
+	}
+	return ""
+}
+
+// isDeadlineError reports whether an error is an expired deadline, locally or as a gRPC status
+func isDeadlineError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
+
+// logSkippedCall reports a rule skipped for the batch and counts it in telemetry
+func (mp *metricsinferenceprocessor) logSkippedCall(ctx context.Context, call *ruleCall) {
+	mp.telemetry.recordSkippedInference(ctx, call.request.ModelName, call.skipped)
+	mp.logLimiter.Warn(call.ruleIdx, "Skipped inference for the batch",
+		zap.String("model", call.request.ModelName),
+		zap.Int("rule_index", call.ruleIdx),
+		zap.String("reason", call.skipped),
+		zap.Error(call.err))
+}
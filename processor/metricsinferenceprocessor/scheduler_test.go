@@ -0,0 +1,222 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// blockingBackend answers once released, or fails when its context ends first
+type blockingBackend struct {
+	release chan struct{}
+	next    InferenceClient
+}
+
+func (b *blockingBackend) ModelInfer(ctx context.Context, request *pb.ModelInferRequest, opts ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	select {
+	case <-b.release:
+		return b.next.ModelInfer(ctx, request, opts...)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newSchedulingProcessor creates a processor with a fast and a slow synthetic
+// rule, and returns it with the slow rule's release channel and a metric reader
+func newSchedulingProcessor(t *testing.T, cfg *Config) (*metricsinferenceprocessor, *consumertest.MetricsSink, chan struct{}, *sdkmetric.ManualReader) {
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	processor.rules[1].backend = &blockingBackend{release: release, next: processor.rules[1].backend}
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor, sink, release, reader
+}
+
+func schedulingRules() []Rule {
+	return []Rule{
+		{
+			ModelName:     "fast",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "fast.{output}",
+			Synthetic:     &SyntheticConfig{Function: "constant", Offset: 1},
+		},
+		{
+			ModelName:     "slow",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "slow.{output}",
+			Synthetic:     &SyntheticConfig{Function: "constant", Offset: 2},
+		},
+	}
+}
+
+func schedulingInput() testutil.TestMetric {
+	return testutil.TestMetric{MetricNames: []string{"metric_1"}, MetricValues: [][]float64{{1}}}
+}
+
+// skippedInferences returns the skipped inference counts by model and reason
+func skippedInferences(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otelcol_processor_metricsinference_skipped_inferences" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				model, _ := dp.Attributes.Value(telemetryAttrModel)
+				reason, _ := dp.Attributes.Value(telemetryAttrReason)
+				counts[model.AsString()+"/"+reason.AsString()] += dp.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestMaxBatchDelay(t *testing.T) {
+	cfg := &Config{Rules: schedulingRules(), MaxBatchDelay: 50 * time.Millisecond}
+	processor, sink, _, reader := newSchedulingProcessor(t, cfg)
+
+	started := time.Now()
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(schedulingInput())))
+	assert.Less(t, time.Since(started), 5*time.Second, "the batch must not wait for the slow rule")
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 1.0, findMetricByName(output, "fast.prediction").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, "", findMetricByName(output, "slow.prediction").Name(), "the unfinished rule is skipped")
+	assert.Equal(t, map[string]int64{"slow/max_batch_delay": 1}, skippedInferences(t, reader))
+}
+
+func TestRuleDeadline(t *testing.T) {
+	rules := schedulingRules()
+	rules[1].Deadline = 20 * time.Millisecond
+	rules[1].Outputs = []OutputSpec{{Name: "prediction", Fallback: FallbackConfig{Policy: fallbackPolicyConstant, Value: -1}}}
+	processor, sink, _, reader := newSchedulingProcessor(t, &Config{Rules: rules})
+
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(schedulingInput())))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 1.0, findMetricByName(output, "fast.prediction").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, -1.0, findMetricByName(output, "slow.prediction").Gauge().DataPoints().At(0).DoubleValue(),
+		"skipped rules emit their fallback values")
+	assert.Equal(t, map[string]int64{"slow/deadline": 1}, skippedInferences(t, reader))
+}
+
+func TestPipelineDeadline(t *testing.T) {
+	// The deadline of the consuming context is earlier than the maximum batch delay
+	processor, sink, _, reader := newSchedulingProcessor(t, &Config{Rules: schedulingRules(), MaxBatchDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, processor.ConsumeMetrics(ctx, testutil.GenerateTestMetrics(schedulingInput())))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 1.0, findMetricByName(output, "fast.prediction").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, "", findMetricByName(output, "slow.prediction").Name())
+	assert.Equal(t, map[string]int64{"slow/pipeline_deadline": 1}, skippedInferences(t, reader))
+}
+
+func TestInsufficientBudget(t *testing.T) {
+	processor, sink, release, reader := newSchedulingProcessor(t, &Config{Rules: schedulingRules()})
+
+	// The slow model is expected to take longer than the pipeline leaves, so it is
+	// skipped without waiting for its deadline
+	processor.latencies.record("slow", time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	started := time.Now()
+	require.NoError(t, processor.ConsumeMetrics(ctx, testutil.GenerateTestMetrics(schedulingInput())))
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 1.0, findMetricByName(output, "fast.prediction").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, "", findMetricByName(output, "slow.prediction").Name())
+	assert.Equal(t, map[string]int64{"slow/budget": 1}, skippedInferences(t, reader))
+
+	// Completed calls move the expected latency towards their own, and skipped
+	// calls decay it, until the slow model fits the budget again and is measured anew
+	assert.Greater(t, processor.latencies.expected("fast"), time.Duration(0))
+	assert.Equal(t, 48*time.Second, processor.latencies.expected("slow"))
+	processor.latencies.record("slow", 0)
+	assert.Equal(t, 38400*time.Millisecond, processor.latencies.expected("slow"))
+	for processor.latencies.expected("slow") > 5*time.Second {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		require.NoError(t, processor.ConsumeMetrics(ctx, testutil.GenerateTestMetrics(schedulingInput())))
+		cancel()
+	}
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, processor.ConsumeMetrics(ctx, testutil.GenerateTestMetrics(schedulingInput())))
+	output = sink.AllMetrics()[len(sink.AllMetrics())-1]
+	assert.Equal(t, "slow.prediction", findMetricByName(output, "slow.prediction").Name())
+	assert.Less(t, processor.latencies.expected("slow"), 5*time.Second)
+}
+
+func TestMaxInFlight(t *testing.T) {
+	rules := schedulingRules()
+	rules[1].MaxInFlight = 1
+	rules[1].Deadline = 10 * time.Second
+	processor, sink, release, reader := newSchedulingProcessor(t, &Config{Rules: rules})
+
+	// The first batch holds the only slot until the slow model is released
+	first := make(chan error)
+	go func() {
+		first <- processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(schedulingInput()))
+	}()
+	require.Eventually(t, func() bool { return len(processor.rules[1].inFlight) == 1 }, 5*time.Second, time.Millisecond)
+
+	// A concurrent batch running out of time cannot get a slot
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, processor.ConsumeMetrics(ctx, testutil.GenerateTestMetrics(schedulingInput())))
+	assert.Equal(t, map[string]int64{"slow/pipeline_deadline": 1}, skippedInferences(t, reader))
+
+	close(release)
+	require.NoError(t, <-first)
+	require.Len(t, sink.AllMetrics(), 2)
+	var slowOutputs int
+	for _, md := range sink.AllMetrics() {
+		if findMetricByName(md, "slow.prediction").Name() != "" {
+			slowOutputs++
+		}
+	}
+	assert.Equal(t, 1, slowOutputs, "only the batch holding the slot has the slow output")
+	assert.Empty(t, processor.rules[1].inFlight, "slots are released when requests finish")
+}
+
+func TestValidateScheduling(t *testing.T) {
+	assert.NoError(t, validateScheduling(Rule{MaxInFlight: 2, Deadline: time.Second}))
+	assert.ErrorContains(t, validateScheduling(Rule{MaxInFlight: -1}), "max_in_flight must not be negative")
+	assert.ErrorContains(t, validateScheduling(Rule{Deadline: -time.Second}), "deadline must not be negative")
+
+	cfg := &Config{Rules: schedulingRules(), MaxBatchDelay: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "max_batch_delay must not be negative")
+}
@@ -0,0 +1,457 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+const (
+	// Triton sequence batcher control parameters
+	paramSequenceID    = "sequence_id"
+	paramSequenceStart = "sequence_start"
+	paramSequenceEnd   = "sequence_end"
+)
+
+// Datatypes accepted for the start, end and ready control flags
+const (
+	controlDataTypeInt32 = "INT32"
+	controlDataTypeBool  = "BOOL"
+	controlDataTypeFP32  = "FP32"
+)
+
+// controlDataTypeUint64 is the datatype of correlation ID control tensors
+const controlDataTypeUint64 = "UINT64"
+
+// sequenceSeriesIdleTTL is how long a per-series sequence that is no longer seen
+// is tracked. Inference servers release the slots of idle sequences themselves,
+// so a series seen again after that starts a new sequence.
+const sequenceSeriesIdleTTL = time.Hour
+
+// sequenceState tracks an active sequence for a stateful model rule
+type sequenceState struct {
+	correlationID uint64
+	started       bool
+	lastRequest   *pb.ModelInferRequest      // Replayed with sequence_end on shutdown
+	series        map[uint64]*sequenceSeries // Per-series sequences already started, by correlation ID
+	requests      uint64                     // Requests recorded, ordering the last requests of series
+	lastPrune     time.Time
+}
+
+// sequenceSeries is a started per-series sequence. Its row of the last request
+// carrying it is replayed with sequence_end on shutdown.
+type sequenceSeries struct {
+	request  *pb.ModelInferRequest
+	rows     int    // Rows of request
+	row      int    // Row of the series in request
+	order    uint64 // Order of request among the requests recorded
+	lastSeen time.Time
+}
+
+// defaultCorrelationID derives a stable, non-zero correlation ID for a rule
+func defaultCorrelationID(modelName string, ruleIndex int) uint64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s/%d", modelName, ruleIndex)
+	id := h.Sum64() >> 1 // Keep the ID within the positive int64 range
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// seriesCorrelationID derives a stable, non-zero correlation ID for one series of a
+// rule from its attribute set and the attributes of its resource. Series of
+// resources without attributes keep the ID of their attribute set alone.
+func seriesCorrelationID(ruleCorrelationID uint64, resource, attrs pcommon.Map) uint64 {
+	h := fnvAddString(fnvOffset64, strconv.FormatUint(ruleCorrelationID, 10))
+	id := (h ^ (attributeSetHash(resource)*31 + attributeSetHash(attrs))) >> 1 // Keep the ID within the positive int64 range
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// validateControlInputs checks a rule's sequence control input configuration
+func validateControlInputs(rule Rule) error {
+	controls := rule.Sequence.ControlInputs
+	names := controls.names()
+	if len(names) == 0 && !rule.Sequence.PerSeries {
+		return nil
+	}
+	if !rule.Sequence.Enabled {
+		return errors.New("sequence.enabled must be true to send control inputs")
+	}
+	if rule.Sequence.PerSeries && controls.CorrelationID == "" {
+		return errors.New("per_series requires control_inputs.correlation_id")
+	}
+
+	switch controls.DataType {
+	case "", controlDataTypeInt32, controlDataTypeBool, controlDataTypeFP32:
+	default:
+		return fmt.Errorf("invalid data_type %q (must be 'INT32', 'BOOL', or 'FP32')", controls.DataType)
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("control input %q is used more than once", name)
+		}
+		seen[name] = true
+		for _, input := range rule.Inputs {
+			if input == name {
+				return fmt.Errorf("control input %q conflicts with a data input", name)
+			}
+		}
+	}
+	return nil
+}
+
+// controlFlagTensor builds a CONTROL flag tensor with one element per series
+func controlFlagTensor(name, dataType string, flags []bool) *pb.ModelInferRequest_InferInputTensor {
+	if dataType == "" {
+		dataType = controlDataTypeInt32
+	}
+	contents := &pb.InferTensorContents{}
+	for _, flag := range flags {
+		var value int32
+		if flag {
+			value = 1
+		}
+		switch dataType {
+		case controlDataTypeBool:
+			contents.BoolContents = append(contents.BoolContents, flag)
+		case controlDataTypeFP32:
+			contents.Fp32Contents = append(contents.Fp32Contents, float32(value))
+		default:
+			contents.IntContents = append(contents.IntContents, value)
+		}
+	}
+	return &pb.ModelInferRequest_InferInputTensor{
+		Name:     name,
+		Datatype: dataType,
+		Shape:    []int64{int64(len(flags))},
+		Contents: contents,
+	}
+}
+
+// setControlFlags replaces the contents of a flag tensor in a request, if present.
+// Requests sending raw contents get the flags as raw contents too.
+func setControlFlags(request *pb.ModelInferRequest, name, dataType string, flag bool) error {
+	if name == "" {
+		return nil
+	}
+	for i, input := range request.Inputs {
+		if input.Name == name {
+			flags := make([]bool, input.Shape[0])
+			for j := range flags {
+				flags[j] = flag
+			}
+			tensor := controlFlagTensor(name, dataType, flags)
+			if len(request.RawInputContents) > 0 {
+				raw, err := encodeRawContents(tensor.Datatype, tensor.Contents)
+				if err != nil {
+					return fmt.Errorf("failed to encode control input %q as raw contents: %w", name, err)
+				}
+				request.RawInputContents[i] = raw
+				tensor.Contents = nil
+			}
+			request.Inputs[i] = tensor
+			return nil
+		}
+	}
+	return nil
+}
+
+// requestCorrelationIDs returns the per-series correlation IDs carried by a request
+func requestCorrelationIDs(request *pb.ModelInferRequest, name string) []uint64 {
+	for i, input := range request.Inputs {
+		if input.Name != name {
+			continue
+		}
+		if len(request.RawInputContents) > 0 {
+			contents, err := decodeRawContents(input.Datatype, request.RawInputContents[i])
+			if err != nil {
+				return nil
+			}
+			return contents.Uint64Contents
+		}
+		if input.Contents != nil {
+			return input.Contents.Uint64Contents
+		}
+	}
+	return nil
+}
+
+// checkControlInputs reports the sequence control tensors of a rule whose datatype
+// differs from the model input of the same name. Control inputs the model does
+// not list in its metadata are not checked.
+func checkControlInputs(rule internalRule, modelInputs []*pb.ModelMetadataResponse_TensorMetadata) error {
+	controls := rule.controlInputs
+	flagType := controls.DataType
+	if flagType == "" {
+		flagType = controlDataTypeInt32
+	}
+	sent := make(map[string]string, 4)
+	for _, name := range []string{controls.Start, controls.End, controls.Ready} {
+		if name != "" {
+			sent[name] = flagType
+		}
+	}
+	if controls.CorrelationID != "" {
+		sent[controls.CorrelationID] = controlDataTypeUint64
+	}
+
+	var errs []error
+	for _, input := range modelInputs {
+		if datatype, ok := sent[input.Name]; ok && input.Datatype != datatype {
+			errs = append(errs, fmt.Errorf("model %s control input %q takes %s, but the rule sends %s",
+				rule.modelName, input.Name, input.Datatype, datatype))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applySequenceControls adds sequence control parameters to a request for a
+// stateful model rule, and CONTROL input tensors when configured. Each matched
+// group is one series of the resource; without matched groups the request is a
+// single series. It is a no-op for rules without sequence support.
+func (mp *metricsinferenceprocessor) applySequenceControls(ruleIdx int, request *pb.ModelInferRequest, resource pcommon.Map, groups []dataPointGroup) {
+	rule := mp.rules[ruleIdx]
+	if !rule.sequenceEnabled {
+		return
+	}
+
+	mp.sequenceLock.Lock()
+	defer mp.sequenceLock.Unlock()
+
+	state, exists := mp.sequences[ruleIdx]
+	if !exists {
+		state = &sequenceState{correlationID: rule.correlationID}
+		mp.sequences[ruleIdx] = state
+	}
+
+	if request.Parameters == nil {
+		request.Parameters = make(map[string]*pb.InferParameter)
+	}
+	request.Parameters[paramSequenceID] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_Int64Param{Int64Param: int64(state.correlationID)},
+	}
+	request.Parameters[paramSequenceStart] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: !state.started},
+	}
+	request.Parameters[paramSequenceEnd] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: false},
+	}
+
+	controls := rule.controlInputs
+	if len(controls.names()) == 0 {
+		return
+	}
+
+	// Correlation ID and start flag for every series in the request
+	rows := len(groups)
+	if rows == 0 {
+		rows = 1
+	}
+	ids := make([]uint64, rows)
+	starts := make([]bool, rows)
+	for i := range ids {
+		switch {
+		case rule.perSeries && len(groups) > 0:
+			ids[i] = seriesCorrelationID(state.correlationID, resource, groups[i].attributes)
+			starts[i] = state.series[ids[i]] == nil
+		case rule.perSeries:
+			ids[i] = seriesCorrelationID(state.correlationID, resource, pcommon.NewMap())
+			starts[i] = state.series[ids[i]] == nil
+		default:
+			ids[i] = state.correlationID
+			starts[i] = !state.started
+		}
+	}
+
+	if controls.Start != "" {
+		request.Inputs = append(request.Inputs, controlFlagTensor(controls.Start, controls.DataType, starts))
+	}
+	if controls.End != "" {
+		request.Inputs = append(request.Inputs, controlFlagTensor(controls.End, controls.DataType, make([]bool, rows)))
+	}
+	if controls.Ready != "" {
+		ready := make([]bool, rows)
+		for i := range ready {
+			ready[i] = true
+		}
+		request.Inputs = append(request.Inputs, controlFlagTensor(controls.Ready, controls.DataType, ready))
+	}
+	if controls.CorrelationID != "" {
+		request.Inputs = append(request.Inputs, &pb.ModelInferRequest_InferInputTensor{
+			Name:     controls.CorrelationID,
+			Datatype: controlDataTypeUint64,
+			Shape:    []int64{int64(rows)},
+			Contents: &pb.InferTensorContents{Uint64Contents: ids},
+		})
+	}
+}
+
+// recordSequenceRequest marks a rule's sequence as active after a successful request
+func (mp *metricsinferenceprocessor) recordSequenceRequest(ruleIdx int, request *pb.ModelInferRequest) {
+	if !mp.rules[ruleIdx].sequenceEnabled {
+		return
+	}
+
+	mp.sequenceLock.Lock()
+	defer mp.sequenceLock.Unlock()
+
+	if state, exists := mp.sequences[ruleIdx]; exists {
+		state.started = true
+		state.lastRequest = request
+
+		if mp.rules[ruleIdx].perSeries {
+			state.recordSeries(request, requestCorrelationIDs(request, mp.rules[ruleIdx].controlInputs.CorrelationID), time.Now())
+		}
+	}
+}
+
+// recordSeries marks the series of a request, by correlation ID in row order, as
+// started, and forgets the series not seen within sequenceSeriesIdleTTL
+func (s *sequenceState) recordSeries(request *pb.ModelInferRequest, ids []uint64, now time.Time) {
+	if s.series == nil {
+		s.series = make(map[uint64]*sequenceSeries)
+	}
+	s.requests++
+	for row, id := range ids {
+		s.series[id] = &sequenceSeries{request: request, rows: len(ids), row: row, order: s.requests, lastSeen: now}
+	}
+
+	if now.Sub(s.lastPrune) < sequenceSeriesIdleTTL {
+		return
+	}
+	s.lastPrune = now
+	for id, series := range s.series {
+		if now.Sub(series.lastSeen) > sequenceSeriesIdleTTL {
+			delete(s.series, id)
+		}
+	}
+}
+
+// endRequests returns the requests replayed with sequence_end to end a sequence:
+// its last request or, for per-series sequences, the rows of every started series
+// in the last request carrying it, most recent request first
+func (s *sequenceState) endRequests() ([]*pb.ModelInferRequest, error) {
+	if len(s.series) == 0 {
+		return []*pb.ModelInferRequest{proto.Clone(s.lastRequest).(*pb.ModelInferRequest)}, nil
+	}
+
+	byRequest := make(map[*pb.ModelInferRequest][]*sequenceSeries)
+	for _, series := range s.series {
+		byRequest[series.request] = append(byRequest[series.request], series)
+	}
+	requests := slices.Collect(maps.Keys(byRequest))
+	slices.SortFunc(requests, func(a, b *pb.ModelInferRequest) int {
+		return cmp.Compare(byRequest[b][0].order, byRequest[a][0].order)
+	})
+
+	ends := make([]*pb.ModelInferRequest, 0, len(requests))
+	for _, request := range requests {
+		series := byRequest[request]
+		rows := make([]int, 0, len(series))
+		for _, s := range series {
+			rows = append(rows, s.row)
+		}
+		slices.Sort(rows)
+		end, err := selectRows(request, series[0].rows, rows)
+		if err != nil {
+			return nil, err
+		}
+		ends = append(ends, end)
+	}
+	return ends, nil
+}
+
+// endSequences sends a sequence end signal for every active sequence, and every
+// started series of per-series sequences, so the server can release its sequence
+// slots. Errors are logged but do not fail shutdown. The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) endSequences(ctx context.Context) {
+	mp.sequenceLock.Lock()
+	defer mp.sequenceLock.Unlock()
+
+	if mp.grpcClient == nil {
+		return
+	}
+
+	for ruleIdx, state := range mp.sequences {
+		if !state.started || state.lastRequest == nil {
+			continue
+		}
+
+		// Triton requires inputs on every sequence request, so replay the last ones
+		endRequests, err := state.endRequests()
+		if err != nil {
+			mp.logger.Warn("Failed to end inference sequence",
+				zap.String("model", mp.rules[ruleIdx].modelName),
+				zap.Int("rule_index", ruleIdx),
+				zap.Uint64("correlation_id", state.correlationID),
+				zap.Error(err))
+			continue
+		}
+		for _, endRequest := range endRequests {
+			mp.endSequence(ctx, ruleIdx, state.correlationID, endRequest)
+		}
+	}
+
+	mp.sequences = make(map[int]*sequenceState)
+}
+
+// endSequence sends a request replayed from a sequence with its end flags set
+func (mp *metricsinferenceprocessor) endSequence(ctx context.Context, ruleIdx int, correlationID uint64, endRequest *pb.ModelInferRequest) {
+	endRequest.Id = fmt.Sprintf("%s-end", endRequest.Id)
+	mp.setIdempotencyKey(endRequest)
+	endRequest.Parameters[paramSequenceStart] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: false},
+	}
+	endRequest.Parameters[paramSequenceEnd] = &pb.InferParameter{
+		ParameterChoice: &pb.InferParameter_BoolParam{BoolParam: true},
+	}
+	controls := mp.rules[ruleIdx].controlInputs
+	err := errors.Join(
+		setControlFlags(endRequest, controls.Start, controls.DataType, false),
+		setControlFlags(endRequest, controls.End, controls.DataType, true))
+
+	if err == nil {
+		timeoutDuration := 5 * time.Second
+		if mp.config.Timeout > 0 {
+			timeoutDuration = time.Duration(mp.config.Timeout) * time.Second
+		}
+		endCtx, cancel := context.WithTimeout(ctx, timeoutDuration)
+		endCtx = mp.headers.withStatic(endCtx)
+		_, err = mp.grpcClient.ModelInfer(endCtx, endRequest)
+		cancel()
+	}
+
+	if err != nil {
+		mp.logger.Warn("Failed to end inference sequence",
+			zap.String("model", mp.rules[ruleIdx].modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Uint64("correlation_id", correlationID),
+			zap.Error(err))
+	} else {
+		mp.logger.Debug("Ended inference sequence",
+			zap.String("model", mp.rules[ruleIdx].modelName),
+			zap.Int("rule_index", ruleIdx),
+			zap.Uint64("correlation_id", correlationID))
+	}
+}
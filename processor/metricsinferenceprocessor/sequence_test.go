@@ -0,0 +1,288 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestSequenceControlsAndShutdownEnd(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("stateful_model",
+		testutil.CreateMockResponseForCalculation("stateful_model", 1.0))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "stateful_model",
+				Inputs:    []string{"metric_1"},
+				Outputs:   []OutputSpec{{Name: "state"}},
+				Sequence:  SequenceConfig{Enabled: true, CorrelationID: 42},
+			},
+			{
+				ModelName: "stateless_model",
+				Inputs:    []string{"metric_1"},
+				Outputs:   []OutputSpec{{Name: "plain"}},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+
+	for i := 0; i < 2; i++ {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{float64(i)}},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	require.NoError(t, processor.Shutdown(context.Background()))
+
+	var sequenceRequests []map[string]interface{}
+	for _, req := range mockServer.GetRequests() {
+		if req.ModelName != "stateful_model" {
+			assert.NotContains(t, req.Parameters, paramSequenceID, "stateless rules must not send sequence controls")
+			continue
+		}
+		sequenceRequests = append(sequenceRequests, map[string]interface{}{
+			paramSequenceID:    req.Parameters[paramSequenceID].GetInt64Param(),
+			paramSequenceStart: req.Parameters[paramSequenceStart].GetBoolParam(),
+			paramSequenceEnd:   req.Parameters[paramSequenceEnd].GetBoolParam(),
+		})
+	}
+
+	require.Len(t, sequenceRequests, 3, "two batches plus the sequence end on shutdown")
+	assert.Equal(t, map[string]interface{}{paramSequenceID: int64(42), paramSequenceStart: true, paramSequenceEnd: false}, sequenceRequests[0])
+	assert.Equal(t, map[string]interface{}{paramSequenceID: int64(42), paramSequenceStart: false, paramSequenceEnd: false}, sequenceRequests[1])
+	assert.Equal(t, map[string]interface{}{paramSequenceID: int64(42), paramSequenceStart: false, paramSequenceEnd: true}, sequenceRequests[2])
+}
+
+func TestShutdownWithoutActiveSequences(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Rules: []Rule{
+			{
+				ModelName: "stateful_model",
+				Inputs:    []string{"metric_1"},
+				Sequence:  SequenceConfig{Enabled: true},
+			},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	require.NoError(t, processor.Shutdown(context.Background()))
+
+	assert.Empty(t, mockServer.GetRequests(), "no sequence end should be sent for sequences that never started")
+}
+
+func TestDefaultCorrelationID(t *testing.T) {
+	first := defaultCorrelationID("model", 0)
+	assert.NotZero(t, first)
+	assert.Equal(t, first, defaultCorrelationID("model", 0), "IDs must be stable across restarts")
+	assert.NotEqual(t, first, defaultCorrelationID("model", 1), "rules sharing a model need distinct sequences")
+	assert.LessOrEqual(t, first, uint64(1<<63-1))
+}
+
+func TestSequenceControlInputsPerSeries(t *testing.T) {
+	mockServer := testutil.NewMockInferenceServer()
+	mockServer.Start(t)
+	defer mockServer.Stop()
+
+	mockServer.SetModelResponse("rnn_model",
+		testutil.CreateMockResponseForCalculation("rnn_model", 1.0))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.GetAddress()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName: "rnn_model",
+				Inputs:    []string{"cpu_usage"},
+				Outputs:   []OutputSpec{{Name: "state"}},
+				Sequence: SequenceConfig{
+					Enabled:   true,
+					PerSeries: true,
+					ControlInputs: ControlInputsConfig{
+						Start:         "START",
+						End:           "END",
+						Ready:         "READY",
+						CorrelationID: "CORRID",
+					},
+				},
+			},
+		},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+
+	batch := func(cpus ...string) {
+		var dataPoints []testutil.TestDataPoint
+		for _, cpu := range cpus {
+			dataPoints = append(dataPoints, testutil.TestDataPoint{Value: 0.5, Attributes: map[string]string{"cpu": cpu}})
+		}
+		input := testutil.GenerateTestMetricsMultiDataPoints([]testutil.TestMetricWithAttributes{
+			{MetricName: "cpu_usage", DataPoints: dataPoints},
+		})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	batch("0", "1")
+	batch("1", "2")
+	require.NoError(t, processor.Shutdown(context.Background()))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 4, "two batches plus the sequence ends on shutdown")
+
+	controls := func(index int) map[string]*pb.ModelInferRequest_InferInputTensor {
+		tensors := make(map[string]*pb.ModelInferRequest_InferInputTensor)
+		for _, input := range requests[index].Inputs {
+			tensors[input.Name] = input
+		}
+		return tensors
+	}
+
+	first, second, end, firstEnd := controls(0), controls(1), controls(2), controls(3)
+	assert.Equal(t, []int32{1, 1}, first["START"].Contents.IntContents)
+	assert.Equal(t, []int32{0, 0}, first["END"].Contents.IntContents)
+	assert.Equal(t, []int32{1, 1}, first["READY"].Contents.IntContents)
+	assert.Equal(t, "UINT64", first["CORRID"].Datatype)
+	assert.Equal(t, []int64{2}, first["CORRID"].Shape)
+
+	// cpu=1 keeps its correlation ID and is no longer starting; cpu=2 is new
+	firstIDs, secondIDs := first["CORRID"].Contents.Uint64Contents, second["CORRID"].Contents.Uint64Contents
+	assert.NotEqual(t, firstIDs[0], firstIDs[1])
+	assert.Equal(t, firstIDs[1], secondIDs[0])
+	assert.NotContains(t, firstIDs, secondIDs[1])
+	assert.Equal(t, []int32{0, 1}, second["START"].Contents.IntContents)
+
+	assert.Equal(t, []int32{0, 0}, end["START"].Contents.IntContents)
+	assert.Equal(t, []int32{1, 1}, end["END"].Contents.IntContents)
+	assert.Equal(t, secondIDs, end["CORRID"].Contents.Uint64Contents)
+
+	// cpu=0 is ended with its row of the first batch
+	assert.Equal(t, []int64{1}, firstEnd["CORRID"].Shape)
+	assert.Equal(t, []int32{0}, firstEnd["START"].Contents.IntContents)
+	assert.Equal(t, []int32{1}, firstEnd["END"].Contents.IntContents)
+	assert.Equal(t, firstIDs[:1], firstEnd["CORRID"].Contents.Uint64Contents)
+	assert.Equal(t, []float64{0.5}, firstEnd["cpu_usage"].Contents.Fp64Contents)
+}
+
+func TestSequenceSeriesEviction(t *testing.T) {
+	state := &sequenceState{}
+	start := time.Now()
+	state.recordSeries(&pb.ModelInferRequest{Id: "first"}, []uint64{1, 2}, start)
+	state.recordSeries(&pb.ModelInferRequest{Id: "second"}, []uint64{2}, start.Add(30*time.Minute))
+	assert.Len(t, state.series, 2)
+
+	// Series 1 has been idle for over an hour, series 2 has not
+	state.recordSeries(&pb.ModelInferRequest{Id: "third"}, []uint64{3}, start.Add(61*time.Minute))
+	assert.NotContains(t, state.series, uint64(1))
+	assert.Contains(t, state.series, uint64(2))
+	assert.Contains(t, state.series, uint64(3))
+}
+
+func TestCheckControlInputs(t *testing.T) {
+	rule := internalRule{
+		modelName:     "rnn_model",
+		controlInputs: ControlInputsConfig{Start: "START", CorrelationID: "CORRID"},
+	}
+	inputs := []*pb.ModelMetadataResponse_TensorMetadata{
+		{Name: "cpu_usage", Datatype: "FP64"},
+		{Name: "START", Datatype: "INT32"},
+		{Name: "CORRID", Datatype: "UINT64"},
+	}
+	assert.NoError(t, checkControlInputs(rule, inputs))
+	assert.NoError(t, checkControlInputs(rule, inputs[:1]), "control inputs the model does not list are not checked")
+
+	inputs[1].Datatype = "BOOL"
+	inputs[2].Datatype = "STRING"
+	err := checkControlInputs(rule, inputs)
+	assert.ErrorContains(t, err, `model rnn_model control input "START" takes BOOL, but the rule sends INT32`)
+	assert.ErrorContains(t, err, `model rnn_model control input "CORRID" takes STRING, but the rule sends UINT64`)
+
+	rule.controlInputs.DataType = controlDataTypeBool
+	assert.ErrorContains(t, checkControlInputs(rule, inputs), "CORRID")
+	assert.NotContains(t, checkControlInputs(rule, inputs).Error(), "START")
+}
+
+func TestControlFlagTensorDataTypes(t *testing.T) {
+	flags := []bool{true, false}
+
+	assert.Equal(t, []int32{1, 0}, controlFlagTensor("START", "", flags).Contents.IntContents)
+	assert.Equal(t, "INT32", controlFlagTensor("START", "", flags).Datatype)
+	assert.Equal(t, []bool{true, false}, controlFlagTensor("START", "BOOL", flags).Contents.BoolContents)
+	assert.Equal(t, []float32{1, 0}, controlFlagTensor("START", "FP32", flags).Contents.Fp32Contents)
+}
+
+func TestValidateControlInputs(t *testing.T) {
+	rule := func(seq SequenceConfig) Rule {
+		return Rule{ModelName: "m", Inputs: []string{"metric_1"}, Sequence: seq}
+	}
+
+	assert.NoError(t, validateControlInputs(rule(SequenceConfig{})))
+	assert.NoError(t, validateControlInputs(rule(SequenceConfig{
+		Enabled: true, PerSeries: true, ControlInputs: ControlInputsConfig{CorrelationID: "CORRID", DataType: "BOOL"},
+	})))
+
+	assert.ErrorContains(t, validateControlInputs(rule(SequenceConfig{
+		ControlInputs: ControlInputsConfig{Start: "START"},
+	})), "sequence.enabled must be true")
+	assert.ErrorContains(t, validateControlInputs(rule(SequenceConfig{
+		Enabled: true, PerSeries: true,
+	})), "per_series requires")
+	assert.ErrorContains(t, validateControlInputs(rule(SequenceConfig{
+		Enabled: true, ControlInputs: ControlInputsConfig{Start: "FLAG", End: "FLAG"},
+	})), "used more than once")
+	assert.ErrorContains(t, validateControlInputs(rule(SequenceConfig{
+		Enabled: true, ControlInputs: ControlInputsConfig{Ready: "metric_1"},
+	})), "conflicts with a data input")
+	assert.ErrorContains(t, validateControlInputs(rule(SequenceConfig{
+		Enabled: true, ControlInputs: ControlInputsConfig{Start: "START", DataType: "INT8"},
+	})), "invalid data_type")
+}
+
+func TestSeriesCorrelationID(t *testing.T) {
+	a := pcommon.NewMap()
+	a.PutStr("cpu", "0")
+	b := pcommon.NewMap()
+	b.PutStr("cpu", "1")
+
+	host := pcommon.NewMap()
+	host.PutStr("host.name", "a")
+
+	none := pcommon.NewMap()
+	id := seriesCorrelationID(42, none, a)
+	assert.NotZero(t, id)
+	assert.Equal(t, id, seriesCorrelationID(42, none, a))
+	assert.NotEqual(t, id, seriesCorrelationID(42, none, b))
+	assert.NotEqual(t, id, seriesCorrelationID(43, none, a), "rules sharing a model need distinct series IDs")
+	assert.NotEqual(t, id, seriesCorrelationID(42, host, a), "series of different resources need distinct IDs")
+	assert.LessOrEqual(t, id, uint64(1<<63-1))
+}
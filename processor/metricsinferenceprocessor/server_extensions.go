@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// KServe v2 extensions the processor adapts to
+const (
+	extensionModelRepository  = "model_repository"
+	extensionBinaryTensorData = "binary_tensor_data"
+)
+
+// serverInfo is what the inference server reported about itself when the
+// processor connected
+type serverInfo struct {
+	name       string
+	version    string
+	extensions map[string]bool // Reported extensions, without their parenthesized options
+}
+
+// negotiateExtensions queries the server's metadata for the extensions it
+// supports. A server that cannot be queried, or reports no extensions at all,
+// is assumed to support every extension, as before extensions were negotiated.
+// The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) negotiateExtensions(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(mp.headers.withStatic(ctx), mp.requestTimeout())
+	defer cancel()
+
+	resp, err := mp.grpcClient.ServerMetadata(ctx, &pb.ServerMetadataRequest{})
+	if err != nil {
+		mp.logger.Debug("Failed to query server metadata, assuming all extensions are supported", zap.Error(err))
+		mp.server = nil
+		return
+	}
+
+	info := &serverInfo{name: resp.Name, version: resp.Version}
+	if len(resp.Extensions) > 0 {
+		info.extensions = make(map[string]bool, len(resp.Extensions))
+		for _, extension := range resp.Extensions {
+			// Triton reports options of an extension as in "model_repository(unload_dependents)"
+			name, _, _ := strings.Cut(extension, "(")
+			info.extensions[strings.TrimSpace(name)] = true
+		}
+	}
+	mp.server = info
+
+	mp.logger.Debug("Negotiated inference server extensions",
+		zap.String("server", resp.Name),
+		zap.String("version", resp.Version),
+		zap.Strings("extensions", resp.Extensions),
+		zap.Bool(extensionModelRepository, mp.supportsExtension(extensionModelRepository)),
+		zap.Bool(extensionBinaryTensorData, mp.supportsExtension(extensionBinaryTensorData)))
+	mp.telemetry.recordServerInfo(ctx, resp.Name, resp.Version, resp.Extensions)
+}
+
+// supportsExtension reports whether the inference server supports an
+// extension, which is assumed when it did not report its extensions
+func (mp *metricsinferenceprocessor) supportsExtension(extension string) bool {
+	if mp.server == nil || mp.server.extensions == nil {
+		return true
+	}
+	return mp.server.extensions[extension]
+}
@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestNegotiateExtensions(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithServerExtensions("health_check", "model_repository(unload_dependents)"))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName: "scorer",
+			Inputs:    []string{"metric_1"},
+			Outputs:   []OutputSpec{{Name: "score"}},
+		}},
+	}
+
+	reader := sdkmetric.NewManualReader()
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Extension options are ignored, and unreported extensions are unsupported
+	assert.True(t, processor.supportsExtension(extensionModelRepository))
+	assert.False(t, processor.supportsExtension(extensionBinaryTensorData))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var info *metricdata.Gauge[int64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "otelcol_processor_metricsinference_server_info" {
+				gauge := m.Data.(metricdata.Gauge[int64])
+				info = &gauge
+			}
+		}
+	}
+	require.NotNil(t, info, "server info should be reported")
+	require.Len(t, info.DataPoints, 1)
+	assert.Equal(t, int64(1), info.DataPoints[0].Value)
+	server, _ := info.DataPoints[0].Attributes.Value(attribute.Key(telemetryAttrServer))
+	assert.Equal(t, "mock-inference-server", server.AsString())
+	extensions, _ := info.DataPoints[0].Attributes.Value(attribute.Key(telemetryAttrExtensions))
+	assert.Equal(t, []string{"health_check", "model_repository(unload_dependents)"}, extensions.AsStringSlice())
+}
+
+func TestSupportsExtensionUnknown(t *testing.T) {
+	// Servers that were not queried, or report no extensions, support them all
+	mp := &metricsinferenceprocessor{}
+	assert.True(t, mp.supportsExtension(extensionBinaryTensorData))
+	mp.server = &serverInfo{name: "kserve"}
+	assert.True(t, mp.supportsExtension(extensionBinaryTensorData))
+}
+
+func TestModelSelectorWithoutModelRepository(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithServerExtensions("health_check"),
+		testutil.WithModelMetadata("cpu_forecaster", labeledMetadata("cpu_forecaster", "1", map[string]string{"metric": "cpu"})))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelSelector: &ModelSelectorConfig{Labels: map[string]string{"metric": "cpu"}},
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "cpu.{output}",
+			Outputs:       []OutputSpec{{Name: "forecast"}},
+		}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// The repository is not listed, so the selector does not resolve
+	assert.Empty(t, processor.rules[0].modelName)
+}
+
+func TestHalfPrecisionWithoutBinaryTensorData(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithServerExtensions("health_check", "model_repository"),
+		testutil.WithModelMetadata("scorer", &pb.ModelMetadataResponse{
+			Name:     "scorer",
+			Versions: []string{"1"},
+			Inputs:   []*pb.ModelMetadataResponse_TensorMetadata{{Name: "cpu", Datatype: "FP16", Shape: []int64{-1}}},
+			Outputs:  []*pb.ModelMetadataResponse_TensorMetadata{{Name: "score", Datatype: "FP32", Shape: []int64{1}}},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			Inputs:        []string{"cpu"},
+			OutputPattern: "cpu.{output}",
+		}},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"cpu"},
+		MetricValues: [][]float64{{0.5}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Half-precision inputs cannot be sent, so the rule does not infer
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[0], "cpu.score").Name())
+}
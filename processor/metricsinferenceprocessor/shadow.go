@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Rule modes
+const (
+	ruleModeActive = "active"
+	ruleModeShadow = "shadow"
+)
+
+// labelShadow marks sampled outputs of shadow rules
+const labelShadow = "shadow"
+
+// shadowMode is the internal form of a shadow rule's configuration
+type shadowMode struct {
+	sampleRate float64
+	sample     func() float64 // Returns a value in [0, 1), replaced in tests
+}
+
+// newShadowMode converts a rule's mode configuration. It returns nil for active rules.
+func newShadowMode(rule Rule) *shadowMode {
+	if rule.Mode != ruleModeShadow {
+		return nil
+	}
+	return &shadowMode{sampleRate: rule.Shadow.SampleRate, sample: rand.Float64}
+}
+
+// validateRuleMode checks a rule's mode and shadow configuration
+func validateRuleMode(rule Rule) error {
+	switch rule.Mode {
+	case "", ruleModeActive, ruleModeShadow:
+	default:
+		return fmt.Errorf("invalid mode %q (must be 'active' or 'shadow')", rule.Mode)
+	}
+	if rule.Shadow.SampleRate < 0 || rule.Shadow.SampleRate > 1 {
+		return fmt.Errorf("shadow.sample_rate must be between 0 and 1, got %g", rule.Shadow.SampleRate)
+	}
+	if rule.Shadow.SampleRate > 0 && rule.Mode != ruleModeShadow {
+		return fmt.Errorf("shadow.sample_rate requires mode 'shadow'")
+	}
+	return nil
+}
+
+// applyShadowCall reports the results of a shadow rule without adding them to
+// the batch: latency is recorded in telemetry and output values are logged at
+// debug level. For the sampled fraction of batches the outputs are kept, marked
+// with the shadow attribute, so they can be compared with the active model's.
+func (mp *metricsinferenceprocessor) applyShadowCall(ctx context.Context, md pmetric.Metrics, call *ruleCall) {
+	rule := call.ctx.rule
+	mp.telemetry.recordShadowLatency(ctx, rule.modelName, call.latency)
+
+	sm, err := mp.outputScopeMetrics(md, call.ctx)
+	if err != nil {
+		mp.logLimiter.Error(call.ruleIdx, "Failed to process shadow inference response",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Error(err))
+		return
+	}
+	first := sm.Metrics().Len()
+	if _, err := mp.processInferenceResponse(md, rule, call.response, call.ctx); err != nil {
+		mp.logLimiter.Error(call.ruleIdx, "Failed to process shadow inference response",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.Error(err))
+	}
+
+	keep := rule.shadow.sampleRate > 0 && rule.shadow.sample() < rule.shadow.sampleRate
+	for i := first; i < sm.Metrics().Len(); i++ {
+		metric := sm.Metrics().At(i)
+		dps := extractNumberDataPoints(metric)
+		values := make([]float64, len(dps))
+		for j, dp := range dps {
+			values[j] = dataPointValue(dp)
+			if keep {
+				dp.Attributes().PutBool(labelShadow, true)
+			}
+		}
+		mp.logger.Debug("Shadow inference result",
+			zap.String("model", rule.modelName),
+			zap.Int("rule_index", call.ruleIdx),
+			zap.String("metric", metric.Name()),
+			zap.Float64s("values", values),
+			zap.Duration("latency", call.latency))
+	}
+
+	if !keep {
+		index := 0
+		sm.Metrics().RemoveIf(func(pmetric.Metric) bool {
+			index++
+			return index > first
+		})
+		return
+	}
+	duplicateOutputs(call.ctx, sm, first)
+}
@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func shadowRules(sampleRate float64) []Rule {
+	return []Rule{
+		{
+			ModelName:     "champion",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "champion.{output}",
+			Synthetic:     &SyntheticConfig{Function: "constant", Offset: 1},
+		},
+		{
+			ModelName:     "candidate",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "candidate.{output}",
+			Synthetic:     &SyntheticConfig{Function: "constant", Offset: 2},
+			Mode:          "shadow",
+			Shadow:        ShadowConfig{SampleRate: sampleRate},
+		},
+	}
+}
+
+func TestShadowMode(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	reader := sdkmetric.NewManualReader()
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(&Config{Rules: shadowRules(0)}, sink, zap.New(core))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"metric_1"}, MetricValues: [][]float64{{5}}})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Only the active rule's output reaches the batch
+	require.Len(t, sink.AllMetrics(), 1)
+	output := sink.AllMetrics()[0]
+	assert.Equal(t, 2, output.MetricCount())
+	assert.Equal(t, 1.0, findMetricByName(output, "champion.prediction").Gauge().DataPoints().At(0).DoubleValue())
+	assert.Equal(t, "", findMetricByName(output, "candidate.prediction").Name())
+
+	// The shadow result is logged
+	results := logs.FilterMessage("Shadow inference result").All()
+	require.Len(t, results, 1)
+	assert.Equal(t, "candidate.prediction", results[0].ContextMap()["metric"])
+	assert.Equal(t, []any{2.0}, results[0].ContextMap()["values"])
+
+	// And its latency recorded
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var latencies uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "otelcol_processor_metricsinference_shadow_latency" {
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					model, _ := dp.Attributes.Value(telemetryAttrModel)
+					assert.Equal(t, "candidate", model.AsString())
+					latencies += dp.Count
+				}
+			}
+		}
+	}
+	assert.Equal(t, uint64(1), latencies)
+}
+
+func TestShadowModeSampledOutputs(t *testing.T) {
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(&Config{Rules: shadowRules(0.5)}, sink, zap.NewNop())
+	require.NoError(t, err)
+	samples := []float64{0.2, 0.7}
+	processor.rules[1].shadow.sample = func() float64 {
+		sample := samples[0]
+		samples = samples[1:]
+		return sample
+	}
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	for range 2 {
+		input := testutil.GenerateTestMetrics(testutil.TestMetric{MetricNames: []string{"metric_1"}, MetricValues: [][]float64{{5}}})
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	}
+	require.Len(t, sink.AllMetrics(), 2)
+
+	// The sampled batch carries the shadow output, marked as such
+	sampled := findMetricByName(sink.AllMetrics()[0], "candidate.prediction")
+	require.Equal(t, 1, sampled.Gauge().DataPoints().Len())
+	assert.Equal(t, 2.0, sampled.Gauge().DataPoints().At(0).DoubleValue())
+	shadow, ok := sampled.Gauge().DataPoints().At(0).Attributes().Get(labelShadow)
+	require.True(t, ok)
+	assert.True(t, shadow.Bool())
+	_, ok = findMetricByName(sink.AllMetrics()[0], "champion.prediction").Gauge().DataPoints().At(0).Attributes().Get(labelShadow)
+	assert.False(t, ok, "active outputs are not marked")
+
+	assert.Equal(t, "", findMetricByName(sink.AllMetrics()[1], "candidate.prediction").Name())
+}
+
+func TestValidateRuleMode(t *testing.T) {
+	assert.NoError(t, validateRuleMode(Rule{}))
+	assert.NoError(t, validateRuleMode(Rule{Mode: "active"}))
+	assert.NoError(t, validateRuleMode(Rule{Mode: "shadow", Shadow: ShadowConfig{SampleRate: 0.1}}))
+
+	assert.ErrorContains(t, validateRuleMode(Rule{Mode: "dry_run"}), "invalid mode")
+	assert.ErrorContains(t, validateRuleMode(Rule{Mode: "shadow", Shadow: ShadowConfig{SampleRate: 1.5}}), "between 0 and 1")
+	assert.ErrorContains(t, validateRuleMode(Rule{Shadow: ShadowConfig{SampleRate: 0.1}}), "requires mode 'shadow'")
+
+	rules := shadowRules(0)
+	rules[1].Outputs = []OutputSpec{{Name: "prediction", Fallback: FallbackConfig{Policy: fallbackPolicyConstant}}}
+	cfg := &Config{Rules: rules}
+	assert.ErrorContains(t, cfg.Validate(), "not supported in shadow mode")
+}
@@ -0,0 +1,184 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// staleSeries is an output series that was produced recently
+type staleSeries struct {
+	resource   pcommon.Resource
+	scope      pcommon.InstrumentationScope
+	metric     pmetric.Metric // Name, unit, description and type, without data points
+	attributes pcommon.Map
+	metricKey  string // Resource, scope and metric the series belongs to
+	lastSeen   time.Time
+}
+
+// stalenessTracker remembers when each output series was last produced, so series
+// that stop being produced can be marked stale instead of flat-lining downstream
+type stalenessTracker struct {
+	mu     sync.Mutex
+	period time.Duration
+	series map[string]*staleSeries // Resource, scope, metric and attribute set key -> series
+	now    func() time.Time
+}
+
+// newStalenessTracker creates a tracker, or returns nil when staleness markers are disabled
+func newStalenessTracker(period time.Duration) *stalenessTracker {
+	if period <= 0 {
+		return nil
+	}
+	return &stalenessTracker{
+		period: period,
+		series: make(map[string]*staleSeries),
+		now:    time.Now,
+	}
+}
+
+// record marks the series of the gauges and sums in metrics[first:] as produced
+// now, within the given resource and scope
+func (t *stalenessTracker) record(resource pcommon.Resource, scope pcommon.InstrumentationScope, metrics pmetric.MetricSlice, first int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	prefix := attributeSetKey(resource.Attributes()) + "\x00" + scope.Name() + "\x00" + scope.Version() + "\x00"
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		dps, ok := numberDataPoints(metric)
+		if !ok {
+			continue
+		}
+		for j := 0; j < dps.Len(); j++ {
+			dp := dps.At(j)
+			if dp.Flags().NoRecordedValue() {
+				continue
+			}
+			metricKey := prefix + metric.Name() + "\x00"
+			key := metricKey + attributeSetKey(dp.Attributes())
+			if series, exists := t.series[key]; exists {
+				series.lastSeen = now
+				continue
+			}
+			series := &staleSeries{
+				resource:   pcommon.NewResource(),
+				scope:      pcommon.NewInstrumentationScope(),
+				metric:     pmetric.NewMetric(),
+				attributes: pcommon.NewMap(),
+				metricKey:  metricKey,
+				lastSeen:   now,
+			}
+			resource.CopyTo(series.resource)
+			scope.CopyTo(series.scope)
+			copyMetricDescriptor(metric, series.metric)
+			dp.Attributes().CopyTo(series.attributes)
+			t.series[key] = series
+		}
+	}
+}
+
+// emitMarkers appends a data point flagged as having no recorded value for every
+// series not produced within the staleness period, and forgets those series.
+// Markers are added as new metrics, in the resource and scope the series was
+// produced in. It returns the number of markers added.
+func (t *stalenessTracker) emitMarkers(md pmetric.Metrics) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var keys []string
+	for key, series := range t.series {
+		if now.Sub(series.lastSeen) > t.period {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	timestamp := pcommon.NewTimestampFromTime(now)
+	markerMetrics := make(map[string]pmetric.Metric) // Resource, scope and metric -> marker metric
+	for _, key := range keys {
+		series := t.series[key]
+		delete(t.series, key)
+
+		metric, exists := markerMetrics[series.metricKey]
+		if !exists {
+			sm := findOrAppendScope(findOrAppendResource(md, series.resource), series.scope)
+			metric = sm.Metrics().AppendEmpty()
+			series.metric.CopyTo(metric)
+			markerMetrics[series.metricKey] = metric
+		}
+		dps, _ := numberDataPoints(metric)
+		dp := dps.AppendEmpty()
+		series.attributes.CopyTo(dp.Attributes())
+		dp.SetTimestamp(timestamp)
+		dp.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+	}
+	return len(keys)
+}
+
+// recordOutputSeries tracks the series of the outputs a rule added to sm from index first on
+func (mp *metricsinferenceprocessor) recordOutputSeries(md pmetric.Metrics, context *modelContext, sm pmetric.ScopeMetrics, first int) {
+	if mp.staleness == nil {
+		return
+	}
+	mp.staleness.record(outputResource(md, context), sm.Scope(), sm.Metrics(), first)
+}
+
+// copyMetricDescriptor copies the name, unit, description and type of a gauge or
+// sum to dest, without its data points
+func copyMetricDescriptor(metric, dest pmetric.Metric) {
+	dest.SetName(metric.Name())
+	dest.SetUnit(metric.Unit())
+	dest.SetDescription(metric.Description())
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dest.SetEmptyGauge()
+	case pmetric.MetricTypeSum:
+		sum := dest.SetEmptySum()
+		sum.SetAggregationTemporality(metric.Sum().AggregationTemporality())
+		sum.SetIsMonotonic(metric.Sum().IsMonotonic())
+	}
+}
+
+// findOrAppendResource returns the ResourceMetrics of md with the attributes of
+// resource, appending one when there is none
+func findOrAppendResource(md pmetric.Metrics, resource pcommon.Resource) pmetric.ResourceMetrics {
+	key := attributeSetKey(resource.Attributes())
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		if attributeSetKey(rm.Resource().Attributes()) == key {
+			return rm
+		}
+	}
+	rm := md.ResourceMetrics().AppendEmpty()
+	resource.CopyTo(rm.Resource())
+	return rm
+}
+
+// findOrAppendScope returns the ScopeMetrics of rm with the name and version of
+// scope, appending one when there is none
+func findOrAppendScope(rm pmetric.ResourceMetrics, scope pcommon.InstrumentationScope) pmetric.ScopeMetrics {
+	for i := 0; i < rm.ScopeMetrics().Len(); i++ {
+		sm := rm.ScopeMetrics().At(i)
+		if sm.Scope().Name() == scope.Name() && sm.Scope().Version() == scope.Version() {
+			return sm
+		}
+	}
+	sm := rm.ScopeMetrics().AppendEmpty()
+	scope.CopyTo(sm.Scope())
+	return sm
+}
@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// stalenessBatch returns a batch of one resource holding the given gauges, each with
+// one data point per host
+func stalenessBatch(metrics map[string][]string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "api")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("scraper")
+	for name, hosts := range metrics {
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		dps := metric.SetEmptyGauge().DataPoints()
+		for _, host := range hosts {
+			dp := dps.AppendEmpty()
+			dp.SetDoubleValue(1)
+			dp.Attributes().PutStr("host", host)
+		}
+	}
+	return md
+}
+
+func TestStalenessMarkers(t *testing.T) {
+	sink := &consumertest.MetricsSink{}
+	cfg := &Config{
+		Rules: []Rule{{
+			ModelName:     "forecaster",
+			Inputs:        []string{"cpu.usage"},
+			OutputPattern: "forecast.{output}",
+			Synthetic:     &SyntheticConfig{Function: "constant", Offset: 1},
+		}},
+		Staleness: StalenessConfig{Period: time.Minute},
+	}
+	processor, err := newMetricsProcessor(cfg, sink, zap.NewNop())
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	processor.staleness.now = func() time.Time { return now }
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	consume := func(md pmetric.Metrics) pmetric.Metrics {
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+		return sink.AllMetrics()[len(sink.AllMetrics())-1]
+	}
+
+	// Both hosts produce a forecast
+	output := consume(stalenessBatch(map[string][]string{"cpu.usage": {"a", "b"}}))
+	assert.Equal(t, 2, findMetricByName(output, "forecast.prediction").Gauge().DataPoints().Len())
+
+	// Host b disappears, but is not stale yet
+	now = now.Add(30 * time.Second)
+	output = consume(stalenessBatch(map[string][]string{"cpu.usage": {"a"}}))
+	assert.Equal(t, 2, output.MetricCount(), "no markers within the staleness period")
+
+	// Past the period, host b is marked stale once, in the resource and scope it was produced in
+	now = now.Add(45 * time.Second)
+	output = consume(stalenessBatch(map[string][]string{"cpu.usage": {"a"}, "memory.usage": {"a"}}))
+	rm := output.ResourceMetrics().At(0)
+	require.Equal(t, 1, output.ResourceMetrics().Len())
+	require.Equal(t, 1, rm.ScopeMetrics().Len())
+	metrics := rm.ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 4, metrics.Len())
+	marker := metrics.At(3)
+	assert.Equal(t, "forecast.prediction", marker.Name())
+	require.Equal(t, 1, marker.Gauge().DataPoints().Len())
+	dp := marker.Gauge().DataPoints().At(0)
+	assert.True(t, dp.Flags().NoRecordedValue())
+	host, _ := dp.Attributes().Get("cpu.usage.host")
+	assert.Equal(t, "b", host.Str())
+
+	now = now.Add(2 * time.Minute)
+	output = consume(stalenessBatch(map[string][]string{"cpu.usage": {"a"}}))
+	assert.Equal(t, 2, output.MetricCount(), "stale series are marked only once")
+
+	// Once the rule stops producing output altogether, host a is marked stale too,
+	// in the resource it was produced in
+	now = now.Add(2 * time.Minute)
+	output = consume(stalenessBatch(map[string][]string{"memory.usage": {"a"}}))
+	require.Equal(t, 2, output.MetricCount())
+	marker = findMetricByName(output, "forecast.prediction")
+	require.Equal(t, 1, marker.Gauge().DataPoints().Len())
+	assert.True(t, marker.Gauge().DataPoints().At(0).Flags().NoRecordedValue())
+	service, _ := output.ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+	assert.Equal(t, "api", service.Str())
+}
+
+func TestStalenessMarkersNewResource(t *testing.T) {
+	tracker := newStalenessTracker(time.Minute)
+	now := time.Unix(1700000000, 0)
+	tracker.now = func() time.Time { return now }
+
+	rm := stalenessBatch(map[string][]string{"forecast": {"a"}}).ResourceMetrics().At(0)
+	sum := rm.ScopeMetrics().At(0).Metrics().At(0).SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.DataPoints().AppendEmpty().Attributes().PutStr("host", "a")
+	tracker.record(rm.Resource(), rm.ScopeMetrics().At(0).Scope(), rm.ScopeMetrics().At(0).Metrics(), 0)
+
+	// A batch without the series' resource gets the resource and scope appended
+	now = now.Add(2 * time.Minute)
+	md := pmetric.NewMetrics()
+	require.Equal(t, 1, tracker.emitMarkers(md))
+	require.Equal(t, 1, md.ResourceMetrics().Len())
+	service, _ := md.ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+	assert.Equal(t, "api", service.Str())
+	sm := md.ResourceMetrics().At(0).ScopeMetrics().At(0)
+	assert.Equal(t, "scraper", sm.Scope().Name())
+	marker := sm.Metrics().At(0)
+	require.Equal(t, pmetric.MetricTypeSum, marker.Type())
+	assert.True(t, marker.Sum().IsMonotonic())
+	assert.True(t, marker.Sum().DataPoints().At(0).Flags().NoRecordedValue())
+
+	assert.Equal(t, 0, tracker.emitMarkers(pmetric.NewMetrics()))
+}
+
+func TestStalenessDisabled(t *testing.T) {
+	assert.Nil(t, newStalenessTracker(0))
+
+	var tracker *stalenessTracker
+	md := stalenessBatch(map[string][]string{"forecast": {"a"}})
+	rm := md.ResourceMetrics().At(0)
+	tracker.record(rm.Resource(), rm.ScopeMetrics().At(0).Scope(), rm.ScopeMetrics().At(0).Metrics(), 0)
+	assert.Equal(t, 0, tracker.emitMarkers(md))
+}
@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Start timestamps of output data points
+const (
+	startTimestampNone       = "none"       // left zero (default)
+	startTimestampInput      = "input"      // the earliest start of the input data points inferred from
+	startTimestampActivation = "activation" // the time the rule became active
+)
+
+// validateStartTimestamp checks the output_start_timestamp setting
+func validateStartTimestamp(source string) error {
+	switch source {
+	case "", startTimestampNone, startTimestampInput, startTimestampActivation:
+		return nil
+	}
+	return fmt.Errorf("invalid output_start_timestamp %q (must be 'none', 'input', or 'activation')", source)
+}
+
+// trackActivation records when a rule becomes active, forgetting it when the
+// rule is inactive so it is recorded again once the rule is toggled back on.
+// Nothing is tracked unless start timestamps are the rules' activation.
+func (mp *metricsinferenceprocessor) trackActivation(ruleIdx int, active bool) {
+	if mp.config.OutputStartTimestamp != startTimestampActivation {
+		return
+	}
+	mp.activationLock.Lock()
+	defer mp.activationLock.Unlock()
+	if !active {
+		delete(mp.activations, ruleIdx)
+		return
+	}
+	if _, ok := mp.activations[ruleIdx]; !ok {
+		mp.activations[ruleIdx] = pcommon.NewTimestampFromTime(time.Now())
+	}
+}
+
+// outputStartTimestamp returns the start timestamp of the outputs of a rule's
+// inference, or zero when they have none
+func (mp *metricsinferenceprocessor) outputStartTimestamp(context *modelContext) pcommon.Timestamp {
+	switch mp.config.OutputStartTimestamp {
+	case startTimestampActivation:
+		mp.activationLock.Lock()
+		defer mp.activationLock.Unlock()
+		return mp.activations[context.ruleIndex]
+	case startTimestampInput:
+		return earliestInputStart(context)
+	}
+	return 0
+}
+
+// earliestInputStart returns the earliest start of the input data points of an
+// inference: their start timestamp, or their timestamp when they have none.
+// Only the data points of matched groups count when the inputs were matched.
+func earliestInputStart(context *modelContext) pcommon.Timestamp {
+	var earliest pcommon.Timestamp
+	observe := func(dp dataPoint) {
+		start := dp.StartTimestamp()
+		if start == 0 {
+			start = dp.Timestamp()
+		}
+		if start != 0 && (earliest == 0 || start < earliest) {
+			earliest = start
+		}
+	}
+	if len(context.matchedDataPoints) > 0 {
+		for _, group := range context.matchedDataPoints {
+			for _, dp := range group.dataPoints {
+				observe(dp)
+			}
+		}
+		return earliest
+	}
+	for _, dps := range context.inputDataPoints {
+		for _, dp := range dps {
+			observe(dp)
+		}
+	}
+	return earliest
+}
+
+// setStartTimestamps sets the start timestamp of the data points of the metrics
+// an output added from index first on. Data points that already have a start,
+// or whose timestamp precedes it, are left as they are.
+func setStartTimestamps(metrics pmetric.MetricSlice, first int, start pcommon.Timestamp) {
+	if start == 0 {
+		return
+	}
+	for i := first; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if dps, ok := numberDataPoints(metric); ok {
+			for j := 0; j < dps.Len(); j++ {
+				if dp := dps.At(j); dp.StartTimestamp() == 0 && start <= dp.Timestamp() {
+					dp.SetStartTimestamp(start)
+				}
+			}
+			continue
+		}
+		if metric.Type() == pmetric.MetricTypeHistogram {
+			dps := metric.Histogram().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				if dp := dps.At(j); dp.StartTimestamp() == 0 && start <= dp.Timestamp() {
+					dp.SetStartTimestamp(start)
+				}
+			}
+		}
+	}
+}
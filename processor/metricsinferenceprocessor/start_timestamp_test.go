@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestSetStartTimestamps(t *testing.T) {
+	metrics := pmetric.NewMetricSlice()
+	metrics.AppendEmpty().SetName("input")
+	gauge := metrics.AppendEmpty().SetEmptyGauge().DataPoints()
+	gauge.AppendEmpty().SetTimestamp(100)
+	gauge.AppendEmpty().SetTimestamp(40)
+	started := gauge.AppendEmpty()
+	started.SetTimestamp(100)
+	started.SetStartTimestamp(20)
+	histogram := metrics.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty()
+	histogram.SetTimestamp(100)
+
+	setStartTimestamps(metrics, 1, 50)
+	assert.Equal(t, pcommon.Timestamp(50), gauge.At(0).StartTimestamp())
+	assert.Zero(t, gauge.At(1).StartTimestamp(), "starts never follow timestamps")
+	assert.Equal(t, pcommon.Timestamp(20), started.StartTimestamp(), "existing starts are kept")
+	assert.Equal(t, pcommon.Timestamp(50), histogram.StartTimestamp())
+}
+
+func TestEarliestInputStart(t *testing.T) {
+	dps := pmetric.NewNumberDataPointSlice()
+	cumulative := dps.AppendEmpty()
+	cumulative.SetStartTimestamp(30)
+	cumulative.SetTimestamp(90)
+	gauge := dps.AppendEmpty()
+	gauge.SetTimestamp(60)
+	unrelated := dps.AppendEmpty()
+	unrelated.SetTimestamp(10)
+
+	context := &modelContext{inputDataPoints: map[string][]dataPoint{"requests": {cumulative, gauge}}}
+	assert.Equal(t, pcommon.Timestamp(30), earliestInputStart(context))
+
+	// Only the data points of matched groups contribute
+	context.inputDataPoints["other"] = []dataPoint{unrelated}
+	context.matchedDataPoints = []dataPointGroup{{dataPoints: map[string]dataPoint{"latency": gauge}}}
+	assert.Equal(t, pcommon.Timestamp(60), earliestInputStart(context))
+}
+
+func TestValidateStartTimestamp(t *testing.T) {
+	for _, source := range []string{"", startTimestampNone, startTimestampInput, startTimestampActivation} {
+		assert.NoError(t, validateStartTimestamp(source))
+	}
+	cfg := &Config{
+		GRPCClientSettings:   GRPCClientSettings{Endpoint: "localhost:8001"},
+		OutputStartTimestamp: "batch",
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid output_start_timestamp")
+}
+
+func TestOutputStartTimestamps(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scaler", testutil.CreateMockResponseForCalculation("scaler", 2)))
+
+	run := func(t *testing.T, source string) (*metricsinferenceprocessor, func(start pcommon.Timestamp) pmetric.NumberDataPoint) {
+		cfg := &Config{
+			GRPCClientSettings:   GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+			Timeout:              5,
+			OutputStartTimestamp: source,
+			Rules: []Rule{{
+				ModelName:     "scaler",
+				Inputs:        []string{"requests"},
+				OutputPattern: "{input}.{output}",
+				Outputs:       []OutputSpec{{Name: "scaled"}},
+			}},
+		}
+		require.NoError(t, cfg.Validate())
+
+		sink := &consumertest.MetricsSink{}
+		processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+		require.NoError(t, err)
+		require.NoError(t, processor.Start(context.Background(), nil))
+		t.Cleanup(func() {
+			assert.NoError(t, processor.Shutdown(context.Background()))
+		})
+
+		consume := func(start pcommon.Timestamp) pmetric.NumberDataPoint {
+			md := testutil.GenerateTestMetrics(testutil.TestMetric{
+				MetricNames:  []string{"requests"},
+				MetricValues: [][]float64{{10}},
+			})
+			md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).SetStartTimestamp(start)
+			require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+			output := sink.AllMetrics()[len(sink.AllMetrics())-1]
+			return findMetricByName(output, "requests.scaled").Gauge().DataPoints().At(0)
+		}
+		return processor, consume
+	}
+
+	inputStart := pcommon.NewTimestampFromTime(time.Now().Add(-time.Minute))
+
+	t.Run("none", func(t *testing.T) {
+		_, consume := run(t, "")
+		assert.Zero(t, consume(inputStart).StartTimestamp())
+	})
+
+	t.Run("input", func(t *testing.T) {
+		_, consume := run(t, startTimestampInput)
+		assert.Equal(t, inputStart, consume(inputStart).StartTimestamp())
+	})
+
+	t.Run("activation", func(t *testing.T) {
+		processor, consume := run(t, startTimestampActivation)
+		activated := consume(inputStart).StartTimestamp()
+		assert.NotZero(t, activated)
+		assert.Greater(t, activated, inputStart)
+		assert.Equal(t, activated, consume(inputStart).StartTimestamp(), "the start stays while the rule is active")
+
+		// A rule toggled off and on again starts anew
+		processor.trackActivation(0, false)
+		assert.Greater(t, consume(inputStart).StartTimestamp(), activated)
+	})
+}
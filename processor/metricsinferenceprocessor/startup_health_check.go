@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// What an unreachable inference server does at startup
+const (
+	startupHealthCheckRequired = "required"
+	startupHealthCheckWarn     = "warn"
+	startupHealthCheckSkip     = "skip"
+)
+
+// Backoff between retries of the startup health check
+const (
+	defaultStartupRetryBackoff = time.Second
+	startupMaxRetryBackoff     = 30 * time.Second
+)
+
+// validateStartupHealthCheck checks the startup health check settings
+func validateStartupHealthCheck(s GRPCClientSettings) error {
+	switch s.StartupHealthCheck {
+	case "", startupHealthCheckRequired:
+	case startupHealthCheckWarn, startupHealthCheckSkip:
+		if s.LazyConnect {
+			return fmt.Errorf("lazy_connect requires startup_health_check 'required'")
+		}
+	default:
+		return fmt.Errorf("invalid startup_health_check %q (must be 'required', 'warn', or 'skip')", s.StartupHealthCheck)
+	}
+	if s.StartupRetries < 0 {
+		return fmt.Errorf("startup_retries must not be negative")
+	}
+	if s.StartupRetryBackoff < 0 {
+		return fmt.Errorf("startup_retry_backoff must not be negative")
+	}
+	return nil
+}
+
+// checkServerAtStartup performs the startup health check, retrying a failed
+// check as configured with a backoff that doubles up to startupMaxRetryBackoff.
+// It returns the error of the last attempt.
+func (mp *metricsinferenceprocessor) checkServerAtStartup(ctx context.Context) error {
+	settings := mp.config.GRPCClientSettings
+	backoff := settings.StartupRetryBackoff
+	if backoff == 0 {
+		backoff = defaultStartupRetryBackoff
+	}
+
+	err := mp.checkServer(ctx)
+	for attempt := 1; err != nil && attempt <= settings.StartupRetries; attempt++ {
+		mp.logger.Warn("Inference server health check failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Int("retries", settings.StartupRetries),
+			zap.Duration("retry_in", backoff),
+			zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff = min(2*backoff, startupMaxRetryBackoff)
+		err = mp.checkServer(ctx)
+	}
+	return err
+}
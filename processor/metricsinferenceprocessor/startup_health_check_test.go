@@ -0,0 +1,126 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// unusedAddress reserves an address nothing listens on yet
+func unusedAddress(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	return address
+}
+
+func TestStartupHealthCheckWarn(t *testing.T) {
+	address := unusedAddress(t)
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: address, StartupHealthCheck: startupHealthCheckWarn},
+		Timeout:            1,
+		Rules: []Rule{{
+			ModelName:     "scorer",
+			Inputs:        []string{"metric_1"},
+			OutputPattern: "scorer.{output}",
+			Outputs:       []OutputSpec{{Name: "score"}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil), "an unreachable server does not fail startup")
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Once the server is up, batches infer without a lazy connection
+	assert.Nil(t, processor.lazyConnection)
+	server := testutil.NewMockInferenceServer()
+	server.SetModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1))
+	serveMockServer(t, address, server)
+	require.Eventually(t, func() bool {
+		require.NoError(t, processor.ConsumeMetrics(context.Background(), testutil.GenerateTestMetrics(testutil.TestMetric{
+			MetricNames:  []string{"metric_1"},
+			MetricValues: [][]float64{{1}},
+		})))
+		last := sink.AllMetrics()[len(sink.AllMetrics())-1]
+		return findMetricByName(last, "scorer.score").Name() != ""
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestStartupHealthCheckSkip(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: unusedAddress(t), StartupHealthCheck: startupHealthCheckSkip},
+		Timeout:            1,
+		Rules:              []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.NoError(t, processor.Start(context.Background(), nil))
+	assert.NoError(t, processor.Shutdown(context.Background()))
+}
+
+func TestStartupHealthCheckRetries(t *testing.T) {
+	address := unusedAddress(t)
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{
+			Endpoint:            address,
+			StartupRetries:      10,
+			StartupRetryBackoff: 50 * time.Millisecond,
+		},
+		Timeout: 1,
+		Rules:   []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}}},
+	}
+
+	// The server comes up while startup retries the health check
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		serveMockServer(t, address, testutil.NewMockInferenceServer())
+	}()
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.NoError(t, processor.Start(context.Background(), nil))
+	assert.NoError(t, processor.Shutdown(context.Background()))
+}
+
+func TestStartupHealthCheckRetriesExhausted(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{
+			Endpoint:            unusedAddress(t),
+			StartupRetries:      2,
+			StartupRetryBackoff: time.Millisecond,
+		},
+		Timeout: 1,
+		Rules:   []Rule{{ModelName: "scorer", Inputs: []string{"metric_1"}}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.ErrorContains(t, processor.Start(context.Background(), nil), "health check failed")
+	assert.NoError(t, processor.Shutdown(context.Background()))
+}
+
+func TestValidateStartupHealthCheck(t *testing.T) {
+	assert.NoError(t, validateStartupHealthCheck(GRPCClientSettings{StartupHealthCheck: startupHealthCheckWarn, StartupRetries: 3}))
+	assert.NoError(t, validateStartupHealthCheck(GRPCClientSettings{LazyConnect: true, StartupRetries: 3}))
+	assert.ErrorContains(t, validateStartupHealthCheck(GRPCClientSettings{StartupHealthCheck: "strict"}), "invalid startup_health_check")
+	assert.ErrorContains(t, validateStartupHealthCheck(GRPCClientSettings{StartupHealthCheck: startupHealthCheckSkip, LazyConnect: true}), "lazy_connect")
+	assert.ErrorContains(t, validateStartupHealthCheck(GRPCClientSettings{StartupRetries: -1}), "must not be negative")
+}
@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Synthetic signal functions
+const (
+	syntheticFunctionSine     = "sine"
+	syntheticFunctionStep     = "step"
+	syntheticFunctionConstant = "constant"
+)
+
+// syntheticBackend produces deterministic, time-varying predictions locally
+// instead of calling the inference server
+type syntheticBackend struct {
+	function  string
+	offset    float64
+	amplitude float64
+	period    time.Duration
+	seed      int64
+	outputs   int
+	now       func() time.Time
+}
+
+// newSyntheticBackend creates a synthetic backend from a rule's configuration
+func newSyntheticBackend(cfg *SyntheticConfig, outputs int) *syntheticBackend {
+	if outputs <= 0 {
+		outputs = 1
+	}
+	return &syntheticBackend{
+		function:  cfg.Function,
+		offset:    cfg.Offset,
+		amplitude: cfg.Amplitude,
+		period:    cfg.Period,
+		seed:      cfg.Seed,
+		outputs:   outputs,
+		now:       time.Now,
+	}
+}
+
+// validateSyntheticConfig checks a rule's synthetic configuration
+func validateSyntheticConfig(cfg *SyntheticConfig) error {
+	switch cfg.Function {
+	case syntheticFunctionSine, syntheticFunctionStep:
+		if cfg.Period <= 0 {
+			return fmt.Errorf("period must be positive for function %q", cfg.Function)
+		}
+	case syntheticFunctionConstant:
+	default:
+		return fmt.Errorf("invalid function %q (must be 'sine', 'step', or 'constant')", cfg.Function)
+	}
+	return nil
+}
+
+// infer generates one value per input element for each output. Each element gets a
+// phase shift derived from the seed and its position, so series are distinguishable
+// but reproducible for the same seed and time.
+func (b *syntheticBackend) infer(request *pb.ModelInferRequest) *pb.ModelInferResponse {
+	elements := 1
+	if len(request.Inputs) > 0 && request.Inputs[0].Contents != nil {
+		if n := len(request.Inputs[0].Contents.Fp64Contents); n > 0 {
+			elements = n
+		}
+	}
+
+	now := b.now()
+	response := &pb.ModelInferResponse{
+		ModelName:    request.ModelName,
+		ModelVersion: request.ModelVersion,
+		Id:           request.Id,
+	}
+
+	for o := 0; o < b.outputs; o++ {
+		values := make([]float64, elements)
+		for i := range values {
+			values[i] = b.value(now, o*elements+i)
+		}
+		response.Outputs = append(response.Outputs, &pb.ModelInferResponse_InferOutputTensor{
+			Name:     fmt.Sprintf("output_%d", o),
+			Datatype: "FP64",
+			Shape:    []int64{int64(elements)},
+			Contents: &pb.InferTensorContents{Fp64Contents: values},
+		})
+	}
+
+	return response
+}
+
+// ModelInfer implements InferenceClient for synthetic rules
+func (b *syntheticBackend) ModelInfer(_ context.Context, request *pb.ModelInferRequest, _ ...grpc.CallOption) (*pb.ModelInferResponse, error) {
+	return b.infer(request), nil
+}
+
+// value evaluates the signal function at the given time for one element
+func (b *syntheticBackend) value(now time.Time, element int) float64 {
+	switch b.function {
+	case syntheticFunctionSine:
+		return b.offset + b.amplitude*math.Sin(2*math.Pi*(b.cycles(now)+b.phase(element)))
+	case syntheticFunctionStep:
+		cycles := b.cycles(now) + b.phase(element)
+		if cycles-math.Floor(cycles) < 0.5 {
+			return b.offset
+		}
+		return b.offset + b.amplitude
+	default:
+		return b.offset
+	}
+}
+
+// cycles returns the number of periods elapsed since the Unix epoch
+func (b *syntheticBackend) cycles(now time.Time) float64 {
+	return float64(now.UnixNano()) / float64(b.period.Nanoseconds())
+}
+
+// phase returns a deterministic phase shift in [0, 1) for an element
+func (b *syntheticBackend) phase(element int) float64 {
+	// SplitMix64 finalizer gives well-distributed phases from sequential inputs
+	z := uint64(b.seed) + uint64(element)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return float64(z>>11) / float64(1<<53)
+}
@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestSyntheticBackendFunctions(t *testing.T) {
+	epoch := time.Unix(0, 0)
+	request := &pb.ModelInferRequest{
+		ModelName: "forecaster",
+		Inputs: []*pb.ModelInferRequest_InferInputTensor{
+			{Name: "x", Contents: &pb.InferTensorContents{Fp64Contents: []float64{1, 2, 3}}},
+		},
+	}
+
+	constant := newSyntheticBackend(&SyntheticConfig{Function: "constant", Offset: 42}, 2)
+	resp := constant.infer(request)
+	require.Len(t, resp.Outputs, 2)
+	assert.Equal(t, []float64{42, 42, 42}, resp.Outputs[0].Contents.Fp64Contents)
+	assert.Equal(t, []int64{3}, resp.Outputs[1].Shape)
+
+	sine := newSyntheticBackend(&SyntheticConfig{Function: "sine", Offset: 10, Amplitude: 5, Period: time.Minute, Seed: 7}, 1)
+	sine.now = func() time.Time { return epoch.Add(15 * time.Second) }
+	first := sine.infer(request).Outputs[0].Contents.Fp64Contents
+	again := sine.infer(request).Outputs[0].Contents.Fp64Contents
+	assert.Equal(t, first, again, "the same seed and time must produce the same values")
+	for _, v := range first {
+		assert.True(t, v >= 5 && v <= 15, "sine value %v out of range", v)
+	}
+	assert.NotEqual(t, first[0], first[1], "series should be phase shifted")
+
+	sine.now = func() time.Time { return epoch.Add(45 * time.Second) }
+	later := sine.infer(request).Outputs[0].Contents.Fp64Contents
+	assert.NotEqual(t, first, later, "values should vary over time")
+
+	step := newSyntheticBackend(&SyntheticConfig{Function: "step", Offset: 1, Amplitude: 9, Period: time.Minute}, 1)
+	step.seed = 0
+	step.now = func() time.Time { return epoch.Add(10 * time.Second) }
+	assert.Equal(t, 1.0, step.value(step.now(), 0))
+	step.now = func() time.Time { return epoch.Add(40 * time.Second) }
+	assert.Equal(t, 10.0, step.value(step.now(), 0))
+}
+
+func TestValidateSyntheticConfig(t *testing.T) {
+	assert.NoError(t, validateSyntheticConfig(&SyntheticConfig{Function: "constant"}))
+	assert.NoError(t, validateSyntheticConfig(&SyntheticConfig{Function: "sine", Period: time.Second}))
+	assert.ErrorContains(t, validateSyntheticConfig(&SyntheticConfig{Function: "step"}), "period must be positive")
+	assert.ErrorContains(t, validateSyntheticConfig(&SyntheticConfig{Function: "random"}), "invalid function")
+
+	// Synthetic-only configurations do not need an inference server
+	cfg := &Config{Rules: []Rule{{
+		ModelName: "forecaster",
+		Inputs:    []string{"metric_1"},
+		Synthetic: &SyntheticConfig{Function: "constant"},
+	}}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Rules = append(cfg.Rules, Rule{ModelName: "real", Inputs: []string{"metric_1"}})
+	assert.ErrorContains(t, cfg.Validate(), "gRPC endpoint must be specified")
+}
+
+func TestSyntheticRuleWithoutServer(t *testing.T) {
+	cfg := &Config{
+		Timeout: 5,
+		Rules: []Rule{
+			{
+				ModelName:     "forecaster",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "demo.{output}",
+				Synthetic:     &SyntheticConfig{Function: "sine", Offset: 50, Amplitude: 10, Period: time.Hour},
+			},
+		},
+	}
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+	require.Len(t, sink.AllMetrics(), 1)
+
+	output := findMetricByName(sink.AllMetrics()[0], "demo.prediction")
+	require.Equal(t, 1, output.Gauge().DataPoints().Len())
+	value := output.Gauge().DataPoints().At(0).DoubleValue()
+	assert.False(t, math.IsNaN(value))
+	assert.True(t, value >= 40 && value <= 60, "synthetic value %v out of range", value)
+}
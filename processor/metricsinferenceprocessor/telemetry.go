@@ -0,0 +1,290 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+const (
+	// scopeName is the instrumentation scope for the processor's own telemetry
+	scopeName = "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor"
+
+	// telemetryAttrModel is the attribute key identifying the model on internal telemetry
+	telemetryAttrModel = "model"
+
+	// telemetryAttrRule is the attribute key identifying the rule, by its ID, on internal telemetry
+	telemetryAttrRule = "rule"
+
+	// telemetryAttrReason is the attribute key giving why a rule's inference was skipped
+	telemetryAttrReason = "reason"
+
+	// Attribute keys describing the inference server on the server info metric
+	telemetryAttrServer     = "server"
+	telemetryAttrVersion    = "version"
+	telemetryAttrExtensions = "extensions"
+
+	// Attribute keys identifying a gRPC channel of an inference endpoint
+	telemetryAttrEndpoint = "endpoint"
+	telemetryAttrChannel  = "channel"
+
+	// Span attribute keys for inference calls
+	spanAttrModelName    = labelInferenceModelName
+	spanAttrModelVersion = labelInferenceModelVersion
+	spanAttrRequestID    = "otel.inference.request.id"
+)
+
+// processorTelemetry holds the instruments used to report the processor's own behavior
+type processorTelemetry struct {
+	cacheHits   metric.Int64Counter
+	cacheMisses metric.Int64Counter
+
+	metadataChanges metric.Int64Counter
+
+	skippedInferences metric.Int64Counter
+
+	autoDisabledRules metric.Int64UpDownCounter
+
+	shadowLatency metric.Float64Histogram
+
+	warmUpLatency metric.Float64Histogram
+
+	sanitizedValues metric.Int64Counter
+
+	truncatedDataPoints metric.Int64Counter
+
+	queueDepth metric.Int64UpDownCounter
+
+	ruleLatency metric.Float64Histogram
+
+	channelInFlight metric.Int64UpDownCounter
+
+	serverInfo metric.Int64Gauge
+
+	tracerProvider trace.TracerProvider
+	tracer         trace.Tracer
+}
+
+// newProcessorTelemetry creates the processor's instruments and tracer from the given providers
+func newProcessorTelemetry(provider metric.MeterProvider, tracerProvider trace.TracerProvider) (*processorTelemetry, error) {
+	if provider == nil {
+		provider = noop.NewMeterProvider()
+	}
+	if tracerProvider == nil {
+		tracerProvider = tracenoop.NewTracerProvider()
+	}
+	meter := provider.Meter(scopeName)
+
+	var errs, err error
+	t := &processorTelemetry{
+		tracerProvider: tracerProvider,
+		tracer:         tracerProvider.Tracer(scopeName),
+	}
+
+	t.cacheHits, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_cache_hits",
+		metric.WithDescription("Number of inference requests served from the result cache"),
+		metric.WithUnit("{requests}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.cacheMisses, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_cache_misses",
+		metric.WithDescription("Number of cacheable inference requests sent to the inference server"),
+		metric.WithUnit("{requests}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.metadataChanges, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_model_metadata_changes",
+		metric.WithDescription("Number of times a refresh found a changed model version or signature"),
+		metric.WithUnit("{changes}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.skippedInferences, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_skipped_inferences",
+		metric.WithDescription("Number of rule inferences skipped for exceeding the rule deadline, the maximum batch delay or the pipeline deadline, not sent for lack of time, dropped by the inference queue, or left out while the rule is auto-disabled"),
+		metric.WithUnit("{inferences}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.autoDisabledRules, err = meter.Int64UpDownCounter(
+		"otelcol_processor_metricsinference_auto_disabled_rules",
+		metric.WithDescription("Number of rules disabled for failing too many of their inference calls, by rule"),
+		metric.WithUnit("{rules}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.shadowLatency, err = meter.Float64Histogram(
+		"otelcol_processor_metricsinference_shadow_latency",
+		metric.WithDescription("Duration of inference calls of rules in shadow mode"),
+		metric.WithUnit("ms"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.warmUpLatency, err = meter.Float64Histogram(
+		"otelcol_processor_metricsinference_warm_up_latency",
+		metric.WithDescription("Duration of the warm-up requests sent to models at startup"),
+		metric.WithUnit("ms"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.sanitizedValues, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_sanitized_values",
+		metric.WithDescription("Number of NaN or infinite model output values dropped or replaced"),
+		metric.WithUnit("{values}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.truncatedDataPoints, err = meter.Int64Counter(
+		"otelcol_processor_metricsinference_truncated_data_points",
+		metric.WithDescription("Number of output data points dropped for exceeding a cardinality limit"),
+		metric.WithUnit("{data_points}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.queueDepth, err = meter.Int64UpDownCounter(
+		"otelcol_processor_metricsinference_queue_depth",
+		metric.WithDescription("Number of inference calls waiting in the inference queues, by rule"),
+		metric.WithUnit("{calls}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.ruleLatency, err = meter.Float64Histogram(
+		"otelcol_processor_metricsinference_rule_latency",
+		metric.WithDescription("Duration of rule inference calls, from their submission, including the wait in the inference queue, to their result"),
+		metric.WithUnit("ms"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.channelInFlight, err = meter.Int64UpDownCounter(
+		"otelcol_processor_metricsinference_channel_in_flight",
+		metric.WithDescription("Number of calls in flight on each gRPC channel to an inference endpoint"),
+		metric.WithUnit("{calls}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.serverInfo, err = meter.Int64Gauge(
+		"otelcol_processor_metricsinference_server_info",
+		metric.WithDescription("Inference server the processor is connected to and the extensions it supports, always 1"),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
+
+	return t, errs
+}
+
+// recordCacheHit records an inference request served from the result cache
+func (t *processorTelemetry) recordCacheHit(ctx context.Context, modelName string) {
+	t.cacheHits.Add(ctx, 1, metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordCacheMiss records a cacheable inference request that required a server call
+func (t *processorTelemetry) recordCacheMiss(ctx context.Context, modelName string) {
+	t.cacheMisses.Add(ctx, 1, metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordModelMetadataChange records a model whose metadata changed on refresh
+func (t *processorTelemetry) recordModelMetadataChange(ctx context.Context, modelName string) {
+	t.metadataChanges.Add(ctx, 1, metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordSkippedInference records a rule inference skipped by the scheduler
+func (t *processorTelemetry) recordSkippedInference(ctx context.Context, modelName, reason string) {
+	t.skippedInferences.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(telemetryAttrModel, modelName),
+		attribute.String(telemetryAttrReason, reason)))
+}
+
+// recordAutoDisabledRule records a rule being disabled (positive delta) or enabled again after failing too often
+func (t *processorTelemetry) recordAutoDisabledRule(ctx context.Context, rule string, delta int64) {
+	t.autoDisabledRules.Add(ctx, delta, metric.WithAttributes(attribute.String(telemetryAttrRule, rule)))
+}
+
+// recordShadowLatency records the duration of a shadow rule's inference call
+func (t *processorTelemetry) recordShadowLatency(ctx context.Context, modelName string, latency time.Duration) {
+	t.shadowLatency.Record(ctx, float64(latency)/float64(time.Millisecond),
+		metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordWarmUpLatency records the duration of a model's warm-up request
+func (t *processorTelemetry) recordWarmUpLatency(ctx context.Context, modelName string, latency time.Duration) {
+	t.warmUpLatency.Record(ctx, float64(latency)/float64(time.Millisecond),
+		metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordSanitizedValues records non-finite output values handled by the non_finite policy
+func (t *processorTelemetry) recordSanitizedValues(ctx context.Context, modelName string, count int) {
+	t.sanitizedValues.Add(ctx, int64(count), metric.WithAttributes(attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordTruncatedDataPoints records output data points dropped by a cardinality limit
+func (t *processorTelemetry) recordTruncatedDataPoints(ctx context.Context, modelName, limit string, count int) {
+	t.truncatedDataPoints.Add(ctx, int64(count), metric.WithAttributes(
+		attribute.String(telemetryAttrModel, modelName),
+		attribute.String(telemetryAttrReason, limit)))
+}
+
+// recordQueueDepth records calls of a rule entering (positive delta) or leaving an inference queue
+func (t *processorTelemetry) recordQueueDepth(ctx context.Context, rule string, delta int64) {
+	t.queueDepth.Add(ctx, delta, metric.WithAttributes(attribute.String(telemetryAttrRule, rule)))
+}
+
+// recordRuleLatency records the duration of a rule's inference call, including its wait in the queue
+func (t *processorTelemetry) recordRuleLatency(ctx context.Context, rule, modelName string, latency time.Duration) {
+	t.ruleLatency.Record(ctx, float64(latency)/float64(time.Millisecond), metric.WithAttributes(
+		attribute.String(telemetryAttrRule, rule),
+		attribute.String(telemetryAttrModel, modelName)))
+}
+
+// recordChannelInFlight records calls starting (positive delta) or finishing on a channel of an endpoint
+func (t *processorTelemetry) recordChannelInFlight(ctx context.Context, endpoint string, channel int, delta int64) {
+	t.channelInFlight.Add(ctx, delta, metric.WithAttributes(
+		attribute.String(telemetryAttrEndpoint, endpoint),
+		attribute.Int(telemetryAttrChannel, channel)))
+}
+
+// recordServerInfo records the name, version and extensions of the inference server
+func (t *processorTelemetry) recordServerInfo(ctx context.Context, name, version string, extensions []string) {
+	t.serverInfo.Record(ctx, 1, metric.WithAttributes(
+		attribute.String(telemetryAttrServer, name),
+		attribute.String(telemetryAttrVersion, version),
+		attribute.StringSlice(telemetryAttrExtensions, extensions)))
+}
+
+// startInferSpan starts a span covering one inference request, whether it is
+// answered by the server, the result cache, or an in-process backend
+func (t *processorTelemetry) startInferSpan(ctx context.Context, request *pb.ModelInferRequest) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String(spanAttrModelName, request.ModelName),
+		attribute.String(spanAttrRequestID, request.Id),
+	}
+	if request.ModelVersion != "" {
+		attrs = append(attrs, attribute.String(spanAttrModelVersion, request.ModelVersion))
+	}
+	return t.tracer.Start(ctx, "ModelInfer",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...))
+}
+
+// endInferSpan ends an inference span, recording the error of a failed request
+func (t *processorTelemetry) endInferSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
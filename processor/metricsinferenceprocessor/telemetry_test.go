@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zaptest"
+	grpccodes "google.golang.org/grpc/codes"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestInferenceTracing(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("scorer", testutil.CreateMockResponseForCalculation("scorer", 1)),
+		testutil.WithModelError("broken", testutil.CreateMockErrorResponse(grpccodes.Unavailable, "model unavailable")))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{ModelName: "scorer", ModelVersion: "3", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "broken", Inputs: []string{"metric_1"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(nil, tracerProvider)
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// The batch arrives with the collector's active trace context
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "pipeline")
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(ctx, input))
+	parent.End()
+
+	inferSpans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.Name() == "ModelInfer" {
+			model, ok := spanAttribute(span, spanAttrModelName)
+			require.True(t, ok)
+			inferSpans[model.AsString()] = span
+		}
+	}
+	require.Len(t, inferSpans, 2)
+
+	scorer := inferSpans["scorer"]
+	assert.Equal(t, parent.SpanContext().TraceID(), scorer.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), scorer.Parent().SpanID())
+	version, ok := spanAttribute(scorer, spanAttrModelVersion)
+	require.True(t, ok)
+	assert.Equal(t, "3", version.AsString())
+	assert.Equal(t, codes.Unset, scorer.Status().Code)
+
+	broken := inferSpans["broken"]
+	assert.Equal(t, codes.Error, broken.Status().Code)
+
+	// Request IDs are recorded on the spans
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	ids := make(map[string]string)
+	for _, request := range requests {
+		ids[request.ModelName] = request.Id
+	}
+	assert.True(t, strings.HasPrefix(ids["scorer"], "scorer-"))
+	assert.True(t, strings.HasPrefix(ids["broken"], "broken-"))
+	requestID, ok := spanAttribute(scorer, spanAttrRequestID)
+	require.True(t, ok)
+	assert.Equal(t, ids["scorer"], requestID.AsString())
+
+	// The W3C trace context reaches the inference server
+	md := mockServer.GetRequestMetadata()
+	require.Len(t, md, 2)
+	traceparent := md[0].Get("traceparent")
+	require.Len(t, traceparent, 1)
+	assert.Contains(t, traceparent[0], parent.SpanContext().TraceID().String())
+
+	// The gRPC client span is a child of the inference span
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindClient && span.Parent().SpanID() == scorer.SpanContext().SpanID() {
+			return
+		}
+	}
+	t.Error("no gRPC client span found under the inference span")
+}
@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// ensembleResponse returns FP64 output tensors in the order given, as an
+// ensemble may return them in any order
+func ensembleResponse(names []string, values []float64) *pb.ModelInferResponse {
+	response := &pb.ModelInferResponse{ModelName: "ensemble"}
+	for i, name := range names {
+		response.Outputs = append(response.Outputs, &pb.ModelInferResponse_InferOutputTensor{
+			Name:     name,
+			Datatype: "FP64",
+			Shape:    []int64{1},
+			Contents: &pb.InferTensorContents{Fp64Contents: []float64{values[i]}},
+		})
+	}
+	return response
+}
+
+func TestOutputsByTensorName(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("ensemble", ensembleResponse([]string{"upper", "forecast", "lower"}, []float64{3.5, 1.5, 2.5})),
+		testutil.WithModelMetadata("ensemble", &pb.ModelMetadataResponse{
+			Name: "ensemble",
+			Outputs: []*pb.ModelMetadataResponse_TensorMetadata{
+				{Name: "lower", Datatype: "FP32", Shape: []int64{1}},
+				{Name: "upper", Datatype: "FP32", Shape: []int64{1}},
+				{Name: "forecast", Datatype: "INT64", Shape: []int64{1}},
+			},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{
+			{
+				ModelName:     "ensemble",
+				Inputs:        []string{"metric_1"},
+				OutputPattern: "ensemble.{output}",
+				Outputs: []OutputSpec{
+					{Name: "prediction", TensorName: "forecast", DataType: "float"},
+					{TensorName: "lower"},
+					{TensorName: "missing"},
+				},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Metadata is matched by tensor name, without overriding a configured data type
+	assert.Equal(t, "float", processor.rules[0].outputs[0].dataType)
+	assert.Equal(t, convertKServeDataType("FP32"), processor.rules[0].outputs[1].dataType)
+
+	input := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"metric_1"},
+		MetricValues: [][]float64{{1}},
+	})
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), input))
+
+	// Outputs take the tensor with their name, whatever its position
+	output := sink.AllMetrics()[0]
+	prediction := findMetricByName(output, "ensemble.prediction")
+	require.Equal(t, 1, prediction.Gauge().DataPoints().Len())
+	assert.Equal(t, 1.5, prediction.Gauge().DataPoints().At(0).DoubleValue())
+	lower := findMetricByName(output, "ensemble.lower")
+	require.Equal(t, 1, lower.Gauge().DataPoints().Len())
+	assert.Equal(t, 2.5, lower.Gauge().DataPoints().At(0).DoubleValue())
+
+	// An output whose tensor is missing from the response is not produced
+	assert.Empty(t, findMetricByName(output, "ensemble.missing").Name())
+}
+
+func TestValidateTensorName(t *testing.T) {
+	index := 0
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{{
+			ModelName: "m",
+			Inputs:    []string{"x"},
+			Outputs:   []OutputSpec{{TensorName: "forecast", OutputIndex: &index}},
+		}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "tensor_name and output_index")
+}
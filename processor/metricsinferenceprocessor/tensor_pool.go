@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"sync"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// maxPooledValues bounds the FP64 buffers kept for reuse, so one unusually
+// large request does not pin its buffer for the life of the process
+const maxPooledValues = 1 << 16
+
+var (
+	// fp64ContentsPool holds tensor contents whose FP64 buffers are reused
+	// across requests instead of being allocated per input per rule
+	fp64ContentsPool = sync.Pool{New: func() any { return &pb.InferTensorContents{} }}
+
+	// inferRequestPool holds inference requests, with their inputs slice, for reuse
+	inferRequestPool = sync.Pool{New: func() any { return &pb.ModelInferRequest{} }}
+)
+
+// tensorBuffers tracks the pooled tensor contents of one request, so they are
+// returned to the pool together once the request's call has been applied
+type tensorBuffers struct {
+	contents []*pb.InferTensorContents
+}
+
+// fp64 returns empty pooled tensor contents with room for n FP64 values
+func (b *tensorBuffers) fp64(n int) *pb.InferTensorContents {
+	contents := fp64ContentsPool.Get().(*pb.InferTensorContents)
+	if cap(contents.Fp64Contents) < n {
+		contents.Fp64Contents = make([]float64, 0, n)
+	}
+	b.contents = append(b.contents, contents)
+	return contents
+}
+
+// release returns the tracked contents to the pool. The tensors holding them
+// must no longer be used.
+func (b *tensorBuffers) release() {
+	if b == nil {
+		return
+	}
+	for _, contents := range b.contents {
+		values := contents.Fp64Contents
+		if cap(values) > maxPooledValues {
+			values = nil
+		}
+		*contents = pb.InferTensorContents{Fp64Contents: values[:0]}
+		fp64ContentsPool.Put(contents)
+	}
+	clear(b.contents)
+	b.contents = b.contents[:0]
+}
+
+// fp64Contents returns empty tensor contents with room for n FP64 values, drawn
+// from the pool when the settings build a pooled request
+func (s EncoderSettings) fp64Contents(n int) *pb.InferTensorContents {
+	if s.buffers == nil {
+		return &pb.InferTensorContents{Fp64Contents: make([]float64, 0, n)}
+	}
+	return s.buffers.fp64(n)
+}
+
+// requestEncoderSettings returns the settings the builtin encoders build a
+// context's request with, drawing their buffers from the pool
+func (mp *metricsinferenceprocessor) requestEncoderSettings(context *modelContext) EncoderSettings {
+	settings := mp.encoderSettings()
+	if context != nil {
+		if context.buffers == nil {
+			context.buffers = &tensorBuffers{}
+		}
+		settings.buffers = context.buffers
+	}
+	return settings
+}
+
+// newInferRequest returns an empty pooled request with room for n inputs
+func newInferRequest(n int) *pb.ModelInferRequest {
+	request := inferRequestPool.Get().(*pb.ModelInferRequest)
+	if cap(request.Inputs) < n {
+		request.Inputs = make([]*pb.ModelInferRequest_InferInputTensor, 0, n)
+	}
+	return request
+}
+
+// releaseInferRequest returns a request to the pool. The request must no
+// longer be used.
+func releaseInferRequest(request *pb.ModelInferRequest) {
+	inputs := request.Inputs
+	clear(inputs)
+	*request = pb.ModelInferRequest{Inputs: inputs[:0]}
+	inferRequestPool.Put(request)
+}
+
+// releaseRuleCalls returns the requests of applied calls, and the buffers of
+// their tensors, to the pools. Calls the batch gave up on may still be sending
+// their request and are left to the garbage collector, as are the requests of
+// sequence rules, which are kept to end their sequence on shutdown.
+func releaseRuleCalls(calls []*ruleCall) {
+	for _, call := range calls {
+		if call.abandoned || call.request == nil || call.ctx.rule.sequenceEnabled {
+			continue
+		}
+		if challenger := call.challenger; challenger != nil {
+			if challenger.abandoned {
+				continue
+			}
+			releaseInferRequest(challenger.request)
+			challenger.request = nil
+		}
+		releaseInferRequest(call.request)
+		call.request = nil
+		call.ctx.buffers.release()
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestTensorBuffers(t *testing.T) {
+	buffers := &tensorBuffers{}
+	settings := EncoderSettings{buffers: buffers}
+
+	contents := settings.fp64Contents(3)
+	assert.Empty(t, contents.Fp64Contents)
+	assert.GreaterOrEqual(t, cap(contents.Fp64Contents), 3)
+	contents.Fp64Contents = append(contents.Fp64Contents, 1, 2, 3)
+	contents.BytesContents = [][]byte{{1}}
+	require.Len(t, buffers.contents, 1)
+
+	// Released contents are emptied, keeping their buffer
+	buffers.release()
+	assert.Empty(t, buffers.contents)
+	assert.Empty(t, contents.Fp64Contents)
+	assert.Nil(t, contents.BytesContents)
+
+	// Without buffers, contents are allocated
+	unpooled := EncoderSettings{}.fp64Contents(2)
+	assert.Equal(t, 2, cap(unpooled.Fp64Contents))
+}
+
+func TestReleaseRuleCalls(t *testing.T) {
+	newCall := func(abandoned bool, sequence bool) *ruleCall {
+		ctx := &modelContext{rule: internalRule{sequenceEnabled: sequence}, buffers: &tensorBuffers{}}
+		request := newInferRequest(1)
+		request.ModelName = "scorer"
+		request.Inputs = append(request.Inputs, &pb.ModelInferRequest_InferInputTensor{
+			Name:     "cpu",
+			Contents: EncoderSettings{buffers: ctx.buffers}.fp64Contents(1),
+		})
+		return &ruleCall{ctx: ctx, request: request, abandoned: abandoned}
+	}
+
+	applied := newCall(false, false)
+	abandoned := newCall(true, false)
+	sequence := newCall(false, true)
+	releaseRuleCalls([]*ruleCall{applied, abandoned, sequence})
+
+	assert.Nil(t, applied.request, "applied calls return their request")
+	assert.Empty(t, applied.ctx.buffers.contents)
+
+	// A call still running keeps its request and buffers
+	require.NotNil(t, abandoned.request)
+	assert.Equal(t, "scorer", abandoned.request.ModelName)
+	assert.Len(t, abandoned.ctx.buffers.contents, 1)
+
+	// Sequence rules keep their last request to end the sequence
+	require.NotNil(t, sequence.request)
+	assert.Len(t, sequence.request.Inputs, 1)
+}
+
+func TestNumberTensorLength(t *testing.T) {
+	assert.Equal(t, 1, numberTensorLength(10, DataHandlingConfig{}))
+	assert.Equal(t, 1, numberTensorLength(10, DataHandlingConfig{Mode: "latest"}))
+	assert.Equal(t, 5, numberTensorLength(10, DataHandlingConfig{Mode: "window", WindowSize: 5}))
+	assert.Equal(t, 3, numberTensorLength(3, DataHandlingConfig{Mode: "window", WindowSize: 5}))
+	assert.Equal(t, 10, numberTensorLength(10, DataHandlingConfig{Mode: "all"}))
+}
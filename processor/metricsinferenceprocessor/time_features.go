@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// timeFeaturesInput is the synthetic input a rule declares, in inputs or as a
+// metric selector of input_map, to be sent calendar features of its inputs' time
+const timeFeaturesInput = "__time_features__"
+
+// Calendar features
+const (
+	featureHourOfDay    = "hour_of_day"     // 0 to 23
+	featureDayOfWeek    = "day_of_week"     // 0 (Monday) to 6 (Sunday)
+	featureDayOfMonth   = "day_of_month"    // 1 to 31
+	featureMonth        = "month"           // 1 to 12
+	featureHourSin      = "hour_sin"        // sin(2π·h/24) of the fractional hour of day
+	featureHourCos      = "hour_cos"        // cos(2π·h/24) of the fractional hour of day
+	featureDayOfWeekSin = "day_of_week_sin" // sin(2π·d/7) of the fractional day of week
+	featureDayOfWeekCos = "day_of_week_cos" // cos(2π·d/7) of the fractional day of week
+)
+
+// defaultTimeFeatures are the features sent when a rule does not select them
+var defaultTimeFeatures = []string{
+	featureHourOfDay, featureDayOfWeek,
+	featureHourSin, featureHourCos, featureDayOfWeekSin, featureDayOfWeekCos,
+}
+
+// timeFeatures is the internal form of a rule's calendar features
+type timeFeatures struct {
+	tensor   string         // Name of the tensor the features are sent as
+	features []string       // Features of every row, in order
+	location *time.Location // Time zone the calendar is read in
+}
+
+// newTimeFeatures returns the calendar features a rule declares, or nil when it
+// declares none. Features are sent as the __time_features__ tensor, or under the
+// model input __time_features__ is mapped to.
+func newTimeFeatures(rule Rule) *timeFeatures {
+	tensor := ""
+	if slices.Contains(rule.Inputs, timeFeaturesInput) {
+		tensor = timeFeaturesInput
+	}
+	for modelInput, selector := range rule.InputMap {
+		if selector == timeFeaturesInput {
+			tensor = modelInput
+		}
+	}
+	if tensor == "" {
+		return nil
+	}
+
+	features := &timeFeatures{tensor: tensor, features: defaultTimeFeatures, location: time.UTC}
+	if cfg := rule.TimeFeatures; cfg != nil {
+		if len(cfg.Features) > 0 {
+			features.features = cfg.Features
+		}
+		if location, err := time.LoadLocation(cfg.Timezone); err == nil && cfg.Timezone != "" {
+			features.location = location
+		}
+	}
+	return features
+}
+
+// validateTimeFeatures checks the calendar features of a rule
+func validateTimeFeatures(rule Rule) error {
+	declared := newTimeFeatures(rule) != nil
+	if !declared {
+		if rule.TimeFeatures != nil {
+			return fmt.Errorf("time_features requires %s in inputs or input_map", timeFeaturesInput)
+		}
+		return nil
+	}
+	if rule.Aggregate != nil || rule.inProcess() {
+		return errors.New("time features are only sent to inference servers")
+	}
+	if len(withoutTimeFeatures(rule.configuredInputs())) == 0 {
+		return fmt.Errorf("%s requires at least one metric input", timeFeaturesInput)
+	}
+	if rule.TimeFeatures == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(rule.TimeFeatures.Features))
+	for _, feature := range rule.TimeFeatures.Features {
+		switch feature {
+		case featureHourOfDay, featureDayOfWeek, featureDayOfMonth, featureMonth,
+			featureHourSin, featureHourCos, featureDayOfWeekSin, featureDayOfWeekCos:
+		default:
+			return fmt.Errorf("unknown feature %q", feature)
+		}
+		if seen[feature] {
+			return fmt.Errorf("feature %q is listed more than once", feature)
+		}
+		seen[feature] = true
+	}
+	if _, err := time.LoadLocation(rule.TimeFeatures.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", rule.TimeFeatures.Timezone, err)
+	}
+	return nil
+}
+
+// withoutTimeFeatures returns the metric inputs of a list of inputs, leaving
+// out the synthetic __time_features__ input
+func withoutTimeFeatures(inputs []string) []string {
+	if !slices.Contains(inputs, timeFeaturesInput) {
+		return inputs
+	}
+	return slices.DeleteFunc(slices.Clone(inputs), func(input string) bool {
+		return input == timeFeaturesInput
+	})
+}
+
+// definedInputs returns the number of tensors a rule sends the model for its
+// inputs, counting the calendar features, which the model takes last
+func (r internalRule) definedInputs() int {
+	if r.timeFeatures != nil {
+		return len(r.inputs) + 1
+	}
+	return len(r.inputs)
+}
+
+// values returns the features of a point in time
+func (f *timeFeatures) values(t time.Time) []float64 {
+	t = t.In(f.location)
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	// Weeks start on Monday
+	weekday := float64((int(t.Weekday()) + 6) % 7)
+	day := weekday + hour/24
+
+	values := make([]float64, len(f.features))
+	for i, feature := range f.features {
+		switch feature {
+		case featureHourOfDay:
+			values[i] = float64(t.Hour())
+		case featureDayOfWeek:
+			values[i] = weekday
+		case featureDayOfMonth:
+			values[i] = float64(t.Day())
+		case featureMonth:
+			values[i] = float64(t.Month())
+		case featureHourSin:
+			values[i] = math.Sin(2 * math.Pi * hour / 24)
+		case featureHourCos:
+			values[i] = math.Cos(2 * math.Pi * hour / 24)
+		case featureDayOfWeekSin:
+			values[i] = math.Sin(2 * math.Pi * day / 7)
+		case featureDayOfWeekCos:
+			values[i] = math.Cos(2 * math.Pi * day / 7)
+		}
+	}
+	return values
+}
+
+// applyTimeFeatures adds the calendar features a rule declares to its request, as
+// a [rows, features] tensor with a row per matched group, computed from the
+// latest timestamp of the group's data points. Requests without matched groups
+// have one row, for the latest timestamp of the rule's inputs. The batch's
+// arrival time is used when the data points carry no timestamps.
+func (mp *metricsinferenceprocessor) applyTimeFeatures(ruleIdx int, request *pb.ModelInferRequest, context *modelContext) {
+	features := mp.rules[ruleIdx].timeFeatures
+	if features == nil {
+		return
+	}
+
+	var times []pcommon.Timestamp
+	for _, group := range context.matchedDataPoints {
+		var latest pcommon.Timestamp
+		for _, dp := range group.dataPoints {
+			latest = max(latest, dp.Timestamp())
+		}
+		times = append(times, latest)
+	}
+	if len(times) == 0 {
+		var latest pcommon.Timestamp
+		for _, dps := range context.inputDataPoints {
+			for _, dp := range dps {
+				latest = max(latest, dp.Timestamp())
+			}
+		}
+		times = append(times, latest)
+	}
+
+	now := time.Now()
+	contents := make([]float64, 0, len(times)*len(features.features))
+	for _, timestamp := range times {
+		t := now
+		if timestamp != 0 {
+			t = timestamp.AsTime()
+		}
+		contents = append(contents, features.values(t)...)
+	}
+	request.Inputs = append(request.Inputs, &pb.ModelInferRequest_InferInputTensor{
+		Name:     features.tensor,
+		Datatype: "FP64",
+		Shape:    []int64{int64(len(times)), int64(len(features.features))},
+		Contents: &pb.InferTensorContents{Fp64Contents: contents},
+	})
+}
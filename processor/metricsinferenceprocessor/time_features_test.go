@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+func TestTimeFeatureValues(t *testing.T) {
+	// Monday 1 January 2024, 06:00 UTC
+	monday := time.Date(2024, time.January, 1, 6, 0, 0, 0, time.UTC)
+
+	features := newTimeFeatures(Rule{Inputs: []string{"load", timeFeaturesInput}})
+	require.NotNil(t, features)
+	assert.Equal(t, timeFeaturesInput, features.tensor)
+	values := features.values(monday)
+	require.Len(t, values, len(defaultTimeFeatures))
+	assert.Equal(t, 6.0, values[0])
+	assert.Equal(t, 0.0, values[1])
+	assert.InDelta(t, 1, values[2], 1e-9, "hour_sin peaks at 06:00")
+	assert.InDelta(t, 0, values[3], 1e-9)
+
+	// Calendars are read in the configured time zone, where it is Sunday evening
+	features = newTimeFeatures(Rule{
+		Inputs:       []string{"load", timeFeaturesInput},
+		TimeFeatures: &TimeFeaturesConfig{Features: []string{featureDayOfWeek, featureDayOfMonth, featureMonth, featureHourOfDay}, Timezone: "America/Los_Angeles"},
+	})
+	assert.Equal(t, []float64{6, 31, 12, 22}, features.values(monday))
+
+	// Mapped features are sent under the model input they are mapped to
+	features = newTimeFeatures(Rule{InputMap: map[string]string{"load": "load", "calendar": timeFeaturesInput}})
+	assert.Equal(t, "calendar", features.tensor)
+	assert.Nil(t, newTimeFeatures(Rule{Inputs: []string{"load"}}))
+	assert.Equal(t, []string{"load"}, Rule{InputMap: map[string]string{"load": "load", "calendar": timeFeaturesInput}}.configuredInputs())
+}
+
+func TestValidateTimeFeatures(t *testing.T) {
+	declared := []string{"load", timeFeaturesInput}
+	assert.NoError(t, validateTimeFeatures(Rule{Inputs: []string{"load"}}))
+	assert.NoError(t, validateTimeFeatures(Rule{Inputs: declared}))
+	assert.NoError(t, validateTimeFeatures(Rule{Inputs: declared, TimeFeatures: &TimeFeaturesConfig{Timezone: "Asia/Tokyo"}}))
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		errMsg string
+	}{
+		{"not declared", Rule{Inputs: []string{"load"}, TimeFeatures: &TimeFeaturesConfig{}}, "requires __time_features__"},
+		{"no metric inputs", Rule{Inputs: []string{timeFeaturesInput}}, "at least one metric input"},
+		{"in-process backend", Rule{Inputs: declared, Backend: backendLocal}, "only sent to inference servers"},
+		{"unknown feature", Rule{Inputs: declared, TimeFeatures: &TimeFeaturesConfig{Features: []string{"week_of_year"}}}, "unknown feature"},
+		{"duplicate feature", Rule{Inputs: declared, TimeFeatures: &TimeFeaturesConfig{Features: []string{featureMonth, featureMonth}}}, "more than once"},
+		{"invalid timezone", Rule{Inputs: declared, TimeFeatures: &TimeFeaturesConfig{Timezone: "Mars/Olympus"}}, "invalid timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateTimeFeatures(tt.rule), tt.errMsg)
+		})
+	}
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "forecaster", Inputs: []string{timeFeaturesInput}}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "invalid time_features in rule 0")
+}
+
+func TestTimeFeaturesRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 1)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		Rules: []Rule{{
+			ModelName:    "forecaster",
+			Inputs:       []string{"load", timeFeaturesInput},
+			Outputs:      []OutputSpec{{Name: "forecast"}},
+			TimeFeatures: &TimeFeaturesConfig{Features: []string{featureHourOfDay, featureDayOfWeek}},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := testutil.GenerateTestMetrics(testutil.TestMetric{
+		MetricNames:  []string{"load"},
+		MetricValues: [][]float64{{0.4}},
+	})
+	// Wednesday 3 January 2024, 14:30 UTC
+	timestamp := pcommon.NewTimestampFromTime(time.Date(2024, time.January, 3, 14, 30, 0, 0, time.UTC))
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).SetTimestamp(timestamp)
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 2)
+	assert.Equal(t, "load", requests[0].Inputs[0].Name)
+	calendar := requests[0].Inputs[1]
+	assert.Equal(t, timeFeaturesInput, calendar.Name)
+	assert.Equal(t, []int64{1, 2}, calendar.Shape)
+	assert.Equal(t, []float64{14, 2}, calendar.Contents.Fp64Contents)
+}
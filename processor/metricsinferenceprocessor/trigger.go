@@ -0,0 +1,289 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Trigger modes of rules
+const (
+	triggerModeArrival  = "arrival"
+	triggerModeInterval = "interval"
+)
+
+// validateTrigger checks when a rule infers
+func validateTrigger(trigger TriggerConfig) error {
+	switch trigger.Mode {
+	case "", triggerModeArrival:
+		if trigger.Every != 0 {
+			return fmt.Errorf("every requires mode 'interval'")
+		}
+	case triggerModeInterval:
+		if trigger.Every <= 0 {
+			return fmt.Errorf("every must be positive when mode is 'interval'")
+		}
+	default:
+		return fmt.Errorf("invalid mode %q (must be 'arrival' or 'interval')", trigger.Mode)
+	}
+	return nil
+}
+
+// triggerInterval returns the interval between a rule's inferences, zero when it
+// infers on every batch
+func triggerInterval(trigger TriggerConfig) time.Duration {
+	if trigger.Mode != triggerModeInterval {
+		return 0
+	}
+	return trigger.Every
+}
+
+// intervalTrigger accumulates the inputs of an interval-triggered rule between its
+// inferences
+type intervalTrigger struct {
+	mu     sync.Mutex
+	inputs pmetric.Metrics // Input metrics of the batches since the last inference
+}
+
+// newIntervalTriggers creates the input buffers of interval-triggered rules, by rule index
+func newIntervalTriggers(rules []internalRule) map[int]*intervalTrigger {
+	triggers := make(map[int]*intervalTrigger)
+	for i, rule := range rules {
+		if rule.every > 0 {
+			triggers[i] = &intervalTrigger{inputs: pmetric.NewMetrics()}
+		}
+	}
+	return triggers
+}
+
+// buffer copies the metrics of a batch that a rule's inputs select, appending
+// their data points to those of earlier batches in the same resource and scope
+func (t *intervalTrigger) buffer(resources []resourceMetricIndex, rule internalRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, resource := range resources {
+		for name, metric := range resource.metrics {
+			if !selectsMetric(rule, name) {
+				continue
+			}
+			rm := findOrAppendResource(t.inputs, resource.rm.Resource())
+			sm := findOrAppendScope(rm, resource.scopes[name].Scope())
+			appendMetricDataPoints(sm.Metrics(), metric)
+		}
+	}
+}
+
+// take returns the buffered inputs and starts a new buffer
+func (t *intervalTrigger) take() pmetric.Metrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	inputs := t.inputs
+	t.inputs = pmetric.NewMetrics()
+	return inputs
+}
+
+// selectsMetric reports whether any input of a rule selects a metric by name
+func selectsMetric(rule internalRule, name string) bool {
+	for _, selector := range rule.inputSelectors {
+		if selector != nil && selector.matchesName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMetricDataPoints appends the data points of metric to the metric of the
+// same name and type in metrics, or appends a copy of metric when there is none
+func appendMetricDataPoints(metrics pmetric.MetricSlice, metric pmetric.Metric) {
+	for i := 0; i < metrics.Len(); i++ {
+		dest := metrics.At(i)
+		if dest.Name() != metric.Name() || dest.Type() != metric.Type() {
+			continue
+		}
+		switch metric.Type() {
+		case pmetric.MetricTypeGauge:
+			for j := 0; j < metric.Gauge().DataPoints().Len(); j++ {
+				metric.Gauge().DataPoints().At(j).CopyTo(dest.Gauge().DataPoints().AppendEmpty())
+			}
+		case pmetric.MetricTypeSum:
+			for j := 0; j < metric.Sum().DataPoints().Len(); j++ {
+				metric.Sum().DataPoints().At(j).CopyTo(dest.Sum().DataPoints().AppendEmpty())
+			}
+		case pmetric.MetricTypeHistogram:
+			for j := 0; j < metric.Histogram().DataPoints().Len(); j++ {
+				metric.Histogram().DataPoints().At(j).CopyTo(dest.Histogram().DataPoints().AppendEmpty())
+			}
+		case pmetric.MetricTypeExponentialHistogram:
+			for j := 0; j < metric.ExponentialHistogram().DataPoints().Len(); j++ {
+				metric.ExponentialHistogram().DataPoints().At(j).CopyTo(dest.ExponentialHistogram().DataPoints().AppendEmpty())
+			}
+		case pmetric.MetricTypeSummary:
+			for j := 0; j < metric.Summary().DataPoints().Len(); j++ {
+				metric.Summary().DataPoints().At(j).CopyTo(dest.Summary().DataPoints().AppendEmpty())
+			}
+		}
+		return
+	}
+	metric.CopyTo(metrics.AppendEmpty())
+}
+
+// startTriggers runs a timer per interval-triggered rule, inferring from the
+// inputs the rule accumulated. It is a no-op when no rule infers on an interval.
+func (mp *metricsinferenceprocessor) startTriggers() {
+	if len(mp.triggers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mp.triggerCancel = cancel
+	for ruleIdx := range mp.triggers {
+		mp.triggerDone.Add(1)
+		go func() {
+			defer mp.triggerDone.Done()
+			ticker := time.NewTicker(mp.rules[ruleIdx].every)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					mp.inferInterval(ctx, ruleIdx)
+				}
+			}
+		}()
+	}
+}
+
+// stopTriggers stops the interval triggers and waits for them to exit. Inputs
+// still buffered and outputs not yet emitted are dropped.
+func (mp *metricsinferenceprocessor) stopTriggers() {
+	if mp.triggerCancel == nil {
+		return
+	}
+	mp.triggerCancel()
+	mp.triggerDone.Wait()
+	mp.triggerCancel = nil
+}
+
+// inferInterval runs an interval-triggered rule on the inputs buffered since its
+// last inference. Its outputs, timestamped now, are held until the next batch.
+func (mp *metricsinferenceprocessor) inferInterval(ctx context.Context, ruleIdx int) {
+	inputs := mp.triggers[ruleIdx].take()
+	if inputs.DataPointCount() == 0 || !mp.rules[ruleIdx].active() || mp.autoDisabled(ctx, ruleIdx) {
+		return
+	}
+
+	mp.lock.Lock()
+	client := mp.grpcClient
+	mp.lock.Unlock()
+
+	if client == nil && mp.config.requiresInferenceServer() {
+		mp.logLimiter.Error(ruleIdx, "gRPC client not initialized, dropping buffered inputs")
+		return
+	}
+	if !mp.lazyConnected(ctx) {
+		return
+	}
+
+	mp.metadataLock.RLock()
+	defer mp.metadataLock.RUnlock()
+
+	calls := mp.prepareRuleCalls(indexBatchMetrics(inputs), ruleIdx)
+	if len(calls) == 0 {
+		return
+	}
+	mp.inferStage(ctx, client, calls)
+
+	// Outputs are added after the buffered inputs of each scope, or in new scopes
+	counts := scopeMetricCounts(inputs)
+	budget := newOutputBudget(mp.config.Cardinality.MaxDataPoints)
+	for _, call := range calls {
+		call.ctx.budget = budget
+		mp.applyRuleCall(ctx, inputs, call)
+	}
+	releaseRuleCalls(calls)
+
+	mp.pendingLock.Lock()
+	defer mp.pendingLock.Unlock()
+	copyAddedMetrics(inputs, counts, mp.pendingOutputs)
+
+	mp.logger.Debug("Ran interval inference",
+		zap.String("model", mp.rules[ruleIdx].modelName),
+		zap.Int("rule_index", ruleIdx),
+		zap.Int("call_count", len(calls)))
+}
+
+// emitPendingOutputs adds the outputs of interval inferences to a batch, in the
+// resource and scope they were produced in, and returns them. The caller must
+// hold mp.metadataLock, under which remote rules replace the triggers.
+func (mp *metricsinferenceprocessor) emitPendingOutputs(md pmetric.Metrics) pmetric.Metrics {
+	if len(mp.triggers) == 0 {
+		return pmetric.NewMetrics()
+	}
+	mp.pendingLock.Lock()
+	pending := mp.pendingOutputs
+	mp.pendingOutputs = pmetric.NewMetrics()
+	mp.pendingLock.Unlock()
+
+	copyAddedMetrics(pending, nil, md)
+	return pending
+}
+
+// restorePendingOutputs puts back outputs of interval inferences a batch took,
+// ahead of those produced since, so they join the next batch
+func (mp *metricsinferenceprocessor) restorePendingOutputs(pending pmetric.Metrics) {
+	if pending.ResourceMetrics().Len() == 0 {
+		return
+	}
+	mp.pendingLock.Lock()
+	defer mp.pendingLock.Unlock()
+	copyAddedMetrics(mp.pendingOutputs, nil, pending)
+	mp.pendingOutputs = pending
+}
+
+// scopeMetricCounts returns the number of metrics in every scope of md, by
+// resource and scope index
+func scopeMetricCounts(md pmetric.Metrics) [][]int {
+	counts := make([][]int, md.ResourceMetrics().Len())
+	for i := range counts {
+		sms := md.ResourceMetrics().At(i).ScopeMetrics()
+		counts[i] = make([]int, sms.Len())
+		for j := range counts[i] {
+			counts[i][j] = sms.At(j).Metrics().Len()
+		}
+	}
+	return counts
+}
+
+// copyAddedMetrics copies the metrics added to md since counts were taken with
+// scopeMetricCounts to dest, matching resources by attributes and scopes by
+// name and version. A nil counts copies every metric.
+func copyAddedMetrics(md pmetric.Metrics, counts [][]int, dest pmetric.Metrics) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			first := 0
+			if i < len(counts) && j < len(counts[i]) {
+				first = counts[i][j]
+			}
+			if sm.Metrics().Len() <= first {
+				continue
+			}
+			destMetrics := findOrAppendScope(findOrAppendResource(dest, rm.Resource()), sm.Scope()).Metrics()
+			for k := first; k < sm.Metrics().Len(); k++ {
+				sm.Metrics().At(k).CopyTo(destMetrics.AppendEmpty())
+			}
+		}
+	}
+}
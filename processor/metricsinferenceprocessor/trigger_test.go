@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// startIntervalProcessor starts a processor with an interval-triggered rule on cpu
+func startIntervalProcessor(t *testing.T, every time.Duration) (*metricsinferenceprocessor, *consumertest.MetricsSink, *testutil.MockInferenceServer) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 0.5)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		DataHandling:       DataHandlingConfig{Mode: "window", WindowSize: 10},
+		Rules: []Rule{
+			{
+				ModelName:     "forecaster",
+				Inputs:        []string{"cpu"},
+				OutputPattern: "forecaster.{output}",
+				Outputs:       []OutputSpec{{Name: "forecast"}},
+				Trigger:       TriggerConfig{Mode: "interval", Every: every},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	t.Cleanup(func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	})
+	return processor, sink, mockServer
+}
+
+// cpuBatch returns a batch with a cpu gauge of one data point per value
+func cpuBatch(start time.Time, values ...float64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	windowSeries(metrics, "cpu", start, values...)
+	return md
+}
+
+func TestIntervalTriggerAccumulatesInputs(t *testing.T) {
+	processor, sink, mockServer := startIntervalProcessor(t, time.Hour)
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+
+	// Batches pass through without inference while inputs accumulate
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start, 1, 2)))
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(2*time.Second), 3)))
+	assert.Empty(t, mockServer.GetRequests())
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[1], "forecaster.forecast").Name())
+
+	// The timer infers from the data points of every batch since the last inference
+	before := time.Now()
+	processor.inferInterval(ctx, 0)
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, []float64{1, 2, 3}, requests[0].Inputs[0].Contents.Fp64Contents)
+
+	// Nothing was buffered since, so the next tick does not infer
+	processor.inferInterval(ctx, 0)
+	assert.Len(t, mockServer.GetRequests(), 1)
+
+	// The outputs join the next batch, timestamped when inference ran
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(3*time.Second), 4)))
+	forecast := findMetricByName(sink.AllMetrics()[2], "forecaster.forecast")
+	require.Equal(t, "forecaster.forecast", forecast.Name())
+	require.Equal(t, 1, forecast.Gauge().DataPoints().Len())
+	assert.False(t, forecast.Gauge().DataPoints().At(0).Timestamp().AsTime().Before(before))
+
+	// and only that batch
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(4*time.Second), 5)))
+	assert.Empty(t, findMetricByName(sink.AllMetrics()[3], "forecaster.forecast").Name())
+}
+
+func TestIntervalTriggerTimer(t *testing.T) {
+	processor, sink, mockServer := startIntervalProcessor(t, 20*time.Millisecond)
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start, 1)))
+	require.Eventually(t, func() bool {
+		return len(mockServer.GetRequests()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		processor.pendingLock.Lock()
+		defer processor.pendingLock.Unlock()
+		return processor.pendingOutputs.MetricCount() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, processor.ConsumeMetrics(ctx, cpuBatch(start.Add(time.Second), 2)))
+	assert.Equal(t, "forecaster.forecast", findMetricByName(sink.AllMetrics()[1], "forecaster.forecast").Name())
+}
+
+func TestValidateTrigger(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x"}}},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Rules[0].Trigger = TriggerConfig{Mode: "interval", Every: 30 * time.Second}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Rules[0].Trigger = TriggerConfig{Mode: "interval"}
+	assert.ErrorContains(t, cfg.Validate(), "every must be positive")
+
+	cfg.Rules[0].Trigger = TriggerConfig{Every: time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "every requires mode 'interval'")
+
+	cfg.Rules[0].Trigger = TriggerConfig{Mode: "cron"}
+	assert.ErrorContains(t, cfg.Validate(), `invalid trigger in rule 0: invalid mode "cron"`)
+}
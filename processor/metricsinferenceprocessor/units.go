@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Unit validation modes
+const (
+	unitValidationWarn   = "warn"
+	unitValidationStrict = "strict"
+	unitValidationNone   = "none"
+)
+
+// ucumAtoms are the UCUM unit atoms accepted in output units. This covers the units
+// used by OpenTelemetry semantic conventions rather than the full UCUM table.
+var ucumAtoms = map[string]bool{
+	"1": true, "%": true,
+	// Time
+	"s": true, "min": true, "h": true, "d": true, "wk": true, "mo": true, "a": true,
+	// Information
+	"By": true, "bit": true, "Bd": true,
+	// SI base and derived units
+	"m": true, "g": true, "l": true, "L": true, "K": true, "Cel": true, "A": true,
+	"V": true, "W": true, "J": true, "Hz": true, "Pa": true, "N": true, "Ohm": true,
+	"mol": true, "cd": true, "rad": true, "deg": true, "bar": true, "cal": true,
+	"[degF]": true,
+}
+
+// ucumPrefixes are the metric and binary prefixes that may precede a unit atom
+var ucumPrefixes = []string{
+	"Ki", "Mi", "Gi", "Ti", "Pi", "Ei",
+	"da", "k", "M", "G", "T", "P", "E", "h", "c", "d", "m", "u", "n", "p", "f",
+}
+
+// unitCorrections maps common non-UCUM spellings to their UCUM equivalent
+var unitCorrections = map[string]string{
+	"byte": "By", "bytes": "By", "B": "By",
+	"kb": "kBy", "KB": "kBy", "kB": "kBy", "MB": "MBy", "GB": "GBy", "TB": "TBy",
+	"KiB": "KiBy", "MiB": "MiBy", "GiB": "GiBy", "TiB": "TiBy",
+	"bits": "bit",
+	"sec":  "s", "secs": "s", "second": "s", "seconds": "s",
+	"msec": "ms", "millisecond": "ms", "milliseconds": "ms",
+	"usec": "us", "microsecond": "us", "microseconds": "us", "μs": "us",
+	"nsec": "ns", "nanosecond": "ns", "nanoseconds": "ns",
+	"mins": "min", "minute": "min", "minutes": "min",
+	"hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+	"day": "d", "days": "d",
+	"percent": "%", "percentage": "%", "pct": "%",
+	"ratio": "1", "fraction": "1",
+	"celsius": "Cel", "degC": "Cel", "°C": "Cel",
+	"count": "{count}", "requests": "{request}", "errors": "{error}",
+}
+
+// normalizeUnit returns the UCUM spelling of a unit, correcting common mistakes.
+// Units without a known correction are returned unchanged.
+func normalizeUnit(unit string) string {
+	if corrected, ok := unitCorrections[unit]; ok {
+		return corrected
+	}
+	return unit
+}
+
+// validateUnit checks a unit against the UCUM subset, or the allowed list. The
+// error suggests the UCUM spelling when the unit is a known mistake.
+func validateUnit(unit string, allowed []string) error {
+	if unit == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if unit == a {
+			return nil
+		}
+	}
+	if err := parseUCUM(unit); err != nil {
+		if corrected, ok := unitCorrections[unit]; ok {
+			return fmt.Errorf("unit %q is not valid UCUM, did you mean %q", unit, corrected)
+		}
+		return fmt.Errorf("unit %q is not valid UCUM: %w", unit, err)
+	}
+	return nil
+}
+
+// parseUCUM checks that a unit is a product or quotient of UCUM terms, where each
+// term is an annotation like "{request}" or a prefixed atom with optional exponent
+func parseUCUM(unit string) error {
+	// A leading "/" means the numerator is 1, as in "/s"
+	unit = strings.TrimPrefix(unit, "/")
+	for _, term := range strings.FieldsFunc(unit, func(r rune) bool { return r == '/' || r == '.' }) {
+		if err := parseUCUMTerm(term); err != nil {
+			return err
+		}
+	}
+	if strings.Contains(unit, "//") || strings.Contains(unit, "..") ||
+		strings.HasSuffix(unit, "/") || strings.HasSuffix(unit, ".") || unit == "" {
+		return fmt.Errorf("malformed expression")
+	}
+	return nil
+}
+
+// parseUCUMTerm validates a single term of a unit expression
+func parseUCUMTerm(term string) error {
+	if term == "1" {
+		return nil
+	}
+
+	// Annotations may stand alone or follow an atom, e.g. "{packet}" or "By{sent}"
+	if start := strings.IndexByte(term, '{'); start >= 0 {
+		if !strings.HasSuffix(term, "}") || strings.Count(term, "{") != 1 || strings.Count(term, "}") != 1 {
+			return fmt.Errorf("malformed annotation in %q", term)
+		}
+		term = term[:start]
+		if term == "" {
+			return nil
+		}
+	}
+
+	// Strip an integer exponent such as "m2" or "s-1"
+	atom := strings.TrimRight(term, "0123456789")
+	if atom != term {
+		atom = strings.TrimSuffix(atom, "-")
+		atom = strings.TrimSuffix(atom, "+")
+		if _, err := strconv.Atoi(term[len(atom):]); err != nil || atom == "" {
+			return fmt.Errorf("invalid exponent in %q", term)
+		}
+		if atom == "1" || atom == "%" {
+			return fmt.Errorf("unit %q cannot have an exponent", atom)
+		}
+	}
+
+	if ucumAtoms[atom] {
+		return nil
+	}
+	for _, prefix := range ucumPrefixes {
+		if rest, ok := strings.CutPrefix(atom, prefix); ok && ucumAtoms[rest] && rest != "1" && rest != "%" {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown unit %q", atom)
+}
+
+// warnInvalidUnits logs configured output units that are not valid UCUM. Invalid
+// units are rejected by Config.Validate in strict mode, so this only reports them.
+func (mp *metricsinferenceprocessor) warnInvalidUnits() {
+	if mp.config.Units.Validation == unitValidationNone {
+		return
+	}
+	for ruleIdx, rule := range mp.rules {
+		for _, output := range rule.outputs {
+			if err := validateUnit(output.unit, mp.config.Units.Allowed); err != nil {
+				mp.logger.Warn("Output unit is not valid UCUM",
+					zap.String("model", rule.modelName),
+					zap.Int("rule_index", ruleIdx),
+					zap.String("output", output.name),
+					zap.Error(err))
+			}
+		}
+	}
+}
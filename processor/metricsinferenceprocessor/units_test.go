@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValidateUnit(t *testing.T) {
+	valid := []string{
+		"", "1", "%", "s", "ms", "us", "min", "h", "By", "KiBy", "MBy", "bit/s", "By/s",
+		"{request}", "{request}/s", "1/s", "/s", "m2", "m.s-2", "Cel", "By{sent}", "kPa",
+	}
+	for _, unit := range valid {
+		assert.NoError(t, validateUnit(unit, nil), "unit %q", unit)
+	}
+
+	invalid := map[string]string{
+		"bytes":   `did you mean "By"`,
+		"seconds": `did you mean "s"`,
+		"percent": `did you mean "%"`,
+		"MB":      `did you mean "MBy"`,
+		"widgets": "unknown unit",
+		"s//m":    "malformed expression",
+		"{open":   "malformed annotation",
+		"%2":      "cannot have an exponent",
+	}
+	for unit, message := range invalid {
+		assert.ErrorContains(t, validateUnit(unit, nil), message, "unit %q", unit)
+	}
+
+	assert.NoError(t, validateUnit("widgets", []string{"widgets"}), "allowed units are accepted")
+}
+
+func TestNormalizeUnit(t *testing.T) {
+	assert.Equal(t, "By", normalizeUnit("bytes"))
+	assert.Equal(t, "ms", normalizeUnit("milliseconds"))
+	assert.Equal(t, "{count}", normalizeUnit("count"))
+	assert.Equal(t, "KiBy", normalizeUnit("KiBy"), "valid units are unchanged")
+	assert.Equal(t, "widgets", normalizeUnit("widgets"), "unknown units are unchanged")
+}
+
+func TestUnitsConfigValidation(t *testing.T) {
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules: []Rule{{
+			ModelName: "model",
+			Inputs:    []string{"metric_1"},
+			Outputs:   []OutputSpec{{Name: "out", Unit: "bytes"}},
+		}},
+	}
+	assert.NoError(t, cfg.Validate(), "warn mode does not reject units")
+
+	cfg.Units.Validation = "strict"
+	assert.ErrorContains(t, cfg.Validate(), `invalid unit for output 0 in rule 0: unit "bytes" is not valid UCUM, did you mean "By"`)
+
+	cfg.Units.Normalize = true
+	assert.NoError(t, cfg.Validate(), "normalized units pass strict validation")
+
+	cfg.Units.Validation = "loud"
+	assert.ErrorContains(t, cfg.Validate(), "invalid units.validation")
+}
+
+func TestUnitsNormalizedAndWarned(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Units:              UnitsConfig{Validation: "warn", Normalize: true},
+		Rules: []Rule{{
+			ModelName: "model",
+			Inputs:    []string{"metric_1"},
+			Outputs: []OutputSpec{
+				{Name: "memory", Unit: "bytes"},
+				{Name: "load", Unit: "widgets"},
+			},
+		}},
+	}
+
+	processor, err := newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zap.New(core))
+	assert.NoError(t, err)
+	assert.Equal(t, "By", processor.rules[0].outputs[0].unit)
+	assert.Equal(t, "widgets", processor.rules[0].outputs[1].unit)
+
+	warnings := logs.FilterMessage("Output unit is not valid UCUM").All()
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, "load", warnings[0].ContextMap()["output"])
+	}
+
+	cfg.Units.Validation = "none"
+	logs.TakeAll()
+	_, err = newMetricsProcessor(cfg, &consumertest.MetricsSink{}, zap.New(core))
+	assert.NoError(t, err)
+	assert.Empty(t, logs.FilterMessage("Output unit is not valid UCUM").All())
+}
@@ -0,0 +1,154 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// validateWarmUp checks the warm-up settings
+func validateWarmUp(cfg WarmUpConfig) error {
+	if cfg.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// warmUpModels sends a request of zeros to every model served by the inference
+// server, so the first batch does not pay for the model being loaded or
+// compiled lazily. Models are warmed up concurrently. Failures are logged and do
+// not fail the startup. The caller must hold mp.lock.
+func (mp *metricsinferenceprocessor) warmUpModels(ctx context.Context) {
+	if !mp.config.WarmUp.Enabled {
+		return
+	}
+	timeout := mp.config.WarmUp.Timeout
+	if timeout == 0 {
+		timeout = mp.requestTimeout()
+	}
+	ctx, cancel := context.WithTimeout(mp.headers.withStatic(ctx), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for modelName, modelVersion := range mp.serverModels() {
+		metadata, exists := mp.modelMetadata[modelName]
+		if !exists || len(metadata.inputs) == 0 {
+			mp.logger.Debug("No input signature to warm up model with",
+				zap.String("model", modelName))
+			continue
+		}
+		request, err := mp.warmUpRequest(modelName, modelVersion, metadata.inputs)
+		if err != nil {
+			mp.logger.Warn("Cannot warm up model",
+				zap.String("model", modelName),
+				zap.Error(err))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			if _, err := mp.grpcClient.ModelInfer(ctx, request); err != nil {
+				mp.logger.Warn("Failed to warm up model",
+					zap.String("model", modelName),
+					zap.Error(err))
+				return
+			}
+			latency := time.Since(start)
+			mp.telemetry.recordWarmUpLatency(ctx, modelName, latency)
+			mp.logger.Info("Warmed up model",
+				zap.String("model", modelName),
+				zap.Duration("latency", latency))
+		}()
+	}
+	wg.Wait()
+}
+
+// warmUpRequest builds a request with an input of zeros for every input of a
+// model's signature. Variable dimensions are given a size of 1. Half-precision
+// inputs require the request to be sent as raw contents.
+func (mp *metricsinferenceprocessor) warmUpRequest(modelName, modelVersion string, inputs []*pb.ModelMetadataResponse_TensorMetadata) (*pb.ModelInferRequest, error) {
+	request := &pb.ModelInferRequest{
+		ModelName:    modelName,
+		ModelVersion: modelVersion,
+		Id:           modelName + "-warm-up",
+	}
+
+	half := false
+	for _, input := range inputs {
+		shape := make([]int64, len(input.Shape))
+		elements := 1
+		for i, dim := range input.Shape {
+			shape[i] = max(dim, 1)
+			elements *= int(shape[i])
+		}
+		contents, err := zeroContents(input.Datatype, elements)
+		if err != nil {
+			return nil, fmt.Errorf("input %q: %w", input.Name, err)
+		}
+		half = half || isHalfPrecision(input.Datatype)
+		request.Inputs = append(request.Inputs, &pb.ModelInferRequest_InferInputTensor{
+			Name:     input.Name,
+			Datatype: input.Datatype,
+			Shape:    shape,
+			Contents: contents,
+		})
+	}
+	if !half {
+		return request, nil
+	}
+
+	if !mp.supportsExtension(extensionBinaryTensorData) {
+		return nil, fmt.Errorf("model takes half-precision inputs, but the inference server does not support the %s extension", extensionBinaryTensorData)
+	}
+	for _, tensor := range request.Inputs {
+		raw, err := encodeRawContents(tensor.Datatype, tensor.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("input %q: %w", tensor.Name, err)
+		}
+		request.RawInputContents = append(request.RawInputContents, raw)
+		tensor.Contents = nil
+	}
+	return request, nil
+}
+
+// zeroContents returns the typed contents of a tensor of zeros, or empty
+// strings for BYTES. FP16 and BF16 zeros are held in the float contents, as
+// encodeRawContents expects them.
+func zeroContents(datatype string, elements int) (*pb.InferTensorContents, error) {
+	contents := &pb.InferTensorContents{}
+	switch datatype {
+	case "BOOL":
+		contents.BoolContents = make([]bool, elements)
+	case "INT8", "INT16", "INT32":
+		contents.IntContents = make([]int32, elements)
+	case "INT64":
+		contents.Int64Contents = make([]int64, elements)
+	case "UINT8", "UINT16", "UINT32":
+		contents.UintContents = make([]uint32, elements)
+	case "UINT64":
+		contents.Uint64Contents = make([]uint64, elements)
+	case "FP32", dataTypeFP16, dataTypeBF16:
+		contents.Fp32Contents = make([]float32, elements)
+	case "FP64":
+		contents.Fp64Contents = make([]float64, elements)
+	case "BYTES":
+		contents.BytesContents = make([][]byte, elements)
+		for i := range contents.BytesContents {
+			contents.BytesContents[i] = []byte{}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported datatype %q", datatype)
+	}
+	return contents, nil
+}
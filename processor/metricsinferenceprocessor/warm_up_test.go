@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+func TestWarmUpModels(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("forecaster", testutil.CreateMockResponseForCalculation("forecaster", 1)),
+		testutil.WithModelMetadata("forecaster", &pb.ModelMetadataResponse{
+			Name: "forecaster",
+			Inputs: []*pb.ModelMetadataResponse_TensorMetadata{
+				{Name: "history", Datatype: "FP32", Shape: []int64{-1, 24}},
+				{Name: "horizon", Datatype: "INT64", Shape: []int64{1}},
+			},
+		}),
+		testutil.WithModelError("broken", status.Error(codes.Unavailable, "model not loaded")),
+		testutil.WithModelMetadata("broken", &pb.ModelMetadataResponse{
+			Name:   "broken",
+			Inputs: []*pb.ModelMetadataResponse_TensorMetadata{{Name: "x", Datatype: "FP64", Shape: []int64{1}}},
+		}))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		WarmUp:             WarmUpConfig{Enabled: true},
+		Rules: []Rule{
+			{ModelName: "forecaster", Inputs: []string{"requests"}, Outputs: []OutputSpec{{Name: "predicted"}}},
+			{ModelName: "broken", Inputs: []string{"requests"}, Outputs: []OutputSpec{{Name: "score"}}},
+			{ModelName: "undiscovered", Inputs: []string{"requests"}, Outputs: []OutputSpec{{Name: "score"}}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	reader := sdkmetric.NewManualReader()
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	processor.telemetry, err = newProcessorTelemetry(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), nil)
+	require.NoError(t, err)
+
+	// A failed warm-up does not fail the startup
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Models without input metadata are not warmed up
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	var warmUp *pb.ModelInferRequest
+	for _, request := range requests {
+		if request.ModelName == "forecaster" {
+			warmUp = request
+		}
+	}
+	require.NotNil(t, warmUp)
+	require.Len(t, warmUp.Inputs, 2)
+	assert.Equal(t, []int64{1, 24}, warmUp.Inputs[0].Shape)
+	assert.Equal(t, make([]float32, 24), warmUp.Inputs[0].Contents.Fp32Contents)
+	assert.Equal(t, []int64{0}, warmUp.Inputs[1].Contents.Int64Contents)
+
+	// Only the successful warm-up records its latency
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var models []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "otelcol_processor_metricsinference_warm_up_latency" {
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					model, _ := dp.Attributes.Value(telemetryAttrModel)
+					models = append(models, model.AsString())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{"forecaster"}, models)
+}
+
+func TestWarmUpRequestHalfPrecision(t *testing.T) {
+	processor, err := newMetricsProcessor(&Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+		Rules:              []Rule{{ModelName: "m", Inputs: []string{"x"}}},
+	}, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	inputs := []*pb.ModelMetadataResponse_TensorMetadata{
+		{Name: "x", Datatype: dataTypeFP16, Shape: []int64{2}},
+		{Name: "label", Datatype: "BYTES", Shape: []int64{1}},
+	}
+	request, err := processor.warmUpRequest("m", "", inputs)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{{0, 0, 0, 0}, {0, 0, 0, 0}}, request.RawInputContents)
+	assert.Nil(t, request.Inputs[0].Contents)
+
+	processor.server = &serverInfo{extensions: map[string]bool{extensionModelRepository: true}}
+	_, err = processor.warmUpRequest("m", "", inputs)
+	assert.ErrorContains(t, err, extensionBinaryTensorData)
+
+	_, err = processor.warmUpRequest("m", "", []*pb.ModelMetadataResponse_TensorMetadata{{Name: "x", Datatype: "COMPLEX64"}})
+	assert.ErrorContains(t, err, "unsupported datatype")
+}
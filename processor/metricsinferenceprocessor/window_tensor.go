@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	pb "github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/proto/v2"
+)
+
+// Layouts of the 2D window tensor of multivariate models
+const (
+	windowLayoutTime     = "time"     // [window, n_inputs]: a row per timestamp
+	windowLayoutFeatures = "features" // [n_inputs, window]: a row per input
+)
+
+// defaultWindowTensorName is the name of the 2D window tensor when none is configured
+const defaultWindowTensorName = "input"
+
+// validateWindowLayout checks the 2D window tensor configuration against the data
+// handling mode and the rules it applies to
+func validateWindowLayout(cfg *Config) error {
+	switch cfg.DataHandling.WindowLayout {
+	case "":
+		return nil
+	case windowLayoutTime, windowLayoutFeatures:
+	default:
+		return fmt.Errorf("invalid window_layout %q (must be 'time' or 'features')", cfg.DataHandling.WindowLayout)
+	}
+	if cfg.DataHandling.Mode != "window" {
+		return errors.New("window_layout requires mode 'window'")
+	}
+	if !cfg.DataHandling.AlignTimestamps {
+		return errors.New("window_layout requires align_timestamps, so the rows of all inputs share timestamps")
+	}
+	for i, rule := range cfg.Rules {
+		if len(rule.Inputs) > 1 && len(rule.Encoders) > 0 {
+			return fmt.Errorf("window_layout cannot be combined with the encoders of rule %d", i)
+		}
+	}
+	return nil
+}
+
+// combinesInputWindows reports whether a rule's inputs are sent as one 2D window
+// tensor. Rules with a single input keep their own tensor, and rules with
+// combine_inputs their own layout.
+func (mp *metricsinferenceprocessor) combinesInputWindows(rule internalRule) bool {
+	return mp.config.DataHandling.WindowLayout != "" && len(rule.inputs) > 1 && rule.combine == nil
+}
+
+// combinesInputs reports whether a rule's inputs are sent as one tensor, either
+// a window tensor or the rule's combined inputs
+func (mp *metricsinferenceprocessor) combinesInputs(rule internalRule) bool {
+	return rule.combine != nil || mp.combinesInputWindows(rule)
+}
+
+// windowTensor encodes the timestamp-aligned windows of a rule's inputs, oldest
+// first, as one 2D tensor in the given layout. Columns of the "time" layout and
+// rows of the "features" layout follow the order of the rule's inputs.
+func windowTensor(name string, inputs []string, aligned map[string][]dataPoint, layout string) (*pb.ModelInferRequest_InferInputTensor, error) {
+	window := len(aligned[inputs[0]])
+	values := make([][]float64, len(inputs))
+	for i, input := range inputs {
+		dataPoints := aligned[input]
+		if len(dataPoints) != window {
+			return nil, fmt.Errorf("input %s has %d aligned data points, %s has %d", input, len(dataPoints), inputs[0], window)
+		}
+		values[i] = make([]float64, window)
+		for t, dp := range dataPoints {
+			numberDataPoint, ok := dp.(pmetric.NumberDataPoint)
+			if !ok {
+				return nil, fmt.Errorf("input %s is not a gauge or sum, as window tensors require", input)
+			}
+			values[i][t] = dataPointValue(numberDataPoint)
+		}
+	}
+
+	contents := make([]float64, 0, window*len(inputs))
+	shape := []int64{int64(window), int64(len(inputs))}
+	if layout == windowLayoutFeatures {
+		shape = []int64{int64(len(inputs)), int64(window)}
+		for i := range inputs {
+			contents = append(contents, values[i]...)
+		}
+	} else {
+		for t := 0; t < window; t++ {
+			for i := range inputs {
+				contents = append(contents, values[i][t])
+			}
+		}
+	}
+
+	return &pb.ModelInferRequest_InferInputTensor{
+		Name:     name,
+		Datatype: "FP64",
+		Shape:    shape,
+		Contents: &pb.InferTensorContents{Fp64Contents: contents},
+	}, nil
+}
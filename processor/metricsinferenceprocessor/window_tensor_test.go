@@ -0,0 +1,194 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metricsinferenceprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rbellamy/opentelemetry-inference/processor/metricsinferenceprocessor/internal/testutil"
+)
+
+// windowSeries appends a gauge with one data point per value, a second apart
+func windowSeries(metrics pmetric.MetricSlice, name string, start time.Time, values ...float64) {
+	metric := metrics.AppendEmpty()
+	metric.SetName(name)
+	dps := metric.SetEmptyGauge().DataPoints()
+	for i, value := range values {
+		dp := dps.AppendEmpty()
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(i) * time.Second)))
+		dp.SetDoubleValue(value)
+	}
+}
+
+func TestWindowTensorLayouts(t *testing.T) {
+	metrics := pmetric.NewMetricSlice()
+	start := time.Unix(1700000000, 0)
+	windowSeries(metrics, "cpu", start, 1, 2, 3)
+	windowSeries(metrics, "memory", start, 10, 20, 30)
+	aligned := map[string][]dataPoint{
+		"cpu":    extractDataPoints(metrics.At(0)),
+		"memory": extractDataPoints(metrics.At(1)),
+	}
+
+	tensor, err := windowTensor("input", []string{"cpu", "memory"}, aligned, windowLayoutTime)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, tensor.Shape)
+	assert.Equal(t, []float64{1, 10, 2, 20, 3, 30}, tensor.Contents.Fp64Contents)
+
+	tensor, err = windowTensor("input", []string{"cpu", "memory"}, aligned, windowLayoutFeatures)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, tensor.Shape)
+	assert.Equal(t, []float64{1, 2, 3, 10, 20, 30}, tensor.Contents.Fp64Contents)
+
+	aligned["memory"] = aligned["memory"][1:]
+	_, err = windowTensor("input", []string{"cpu", "memory"}, aligned, windowLayoutTime)
+	assert.ErrorContains(t, err, "aligned data points")
+}
+
+func TestMultivariateWindowRequest(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("autoencoder", testutil.CreateMockResponseForCalculation("autoencoder", 0.2)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		DataHandling: DataHandlingConfig{
+			Mode:               "window",
+			WindowSize:         3,
+			AlignTimestamps:    true,
+			TimestampTolerance: 100,
+			WindowLayout:       windowLayoutTime,
+			WindowTensorName:   "INPUT__0",
+		},
+		Rules: []Rule{
+			{
+				ModelName:     "autoencoder",
+				Inputs:        []string{"cpu", "memory"},
+				OutputPattern: "autoencoder.{output}",
+				Outputs:       []OutputSpec{{Name: "reconstruction_error"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := &consumertest.MetricsSink{}
+	processor, err := newMetricsProcessor(cfg, sink, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	// Four timestamps, of which the window keeps the last three
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	start := time.Unix(1700000000, 0)
+	windowSeries(metrics, "cpu", start, 0.1, 0.2, 0.3, 0.4)
+	windowSeries(metrics, "memory", start.Add(10*time.Millisecond), 1, 2, 3, 4)
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].Inputs, 1)
+	input := requests[0].Inputs[0]
+	assert.Equal(t, "INPUT__0", input.Name)
+	assert.Equal(t, []int64{3, 2}, input.Shape)
+	assert.Equal(t, []float64{0.2, 2, 0.3, 3, 0.4, 4}, input.Contents.Fp64Contents)
+	assert.Equal(t, "autoencoder.reconstruction_error", findMetricByName(sink.AllMetrics()[0], "autoencoder.reconstruction_error").Name())
+}
+
+func TestMultivariateWindowSharedModel(t *testing.T) {
+	mockServer := testutil.StartMockServer(t,
+		testutil.WithModelResponse("autoencoder", testutil.CreateMockResponseForCalculation("autoencoder", 0.2)))
+
+	cfg := &Config{
+		GRPCClientSettings: GRPCClientSettings{Endpoint: mockServer.Endpoint()},
+		Timeout:            5,
+		DataHandling: DataHandlingConfig{
+			Mode:               "window",
+			WindowSize:         2,
+			AlignTimestamps:    true,
+			TimestampTolerance: 100,
+			WindowLayout:       windowLayoutFeatures,
+		},
+		Rules: []Rule{
+			{
+				ModelName:     "autoencoder",
+				Inputs:        []string{"cpu", "memory"},
+				OutputPattern: "host.{output}",
+				Outputs:       []OutputSpec{{Name: "reconstruction_error"}},
+			},
+			{
+				ModelName:     "autoencoder",
+				Inputs:        []string{"disk", "network"},
+				OutputPattern: "io.{output}",
+				Outputs:       []OutputSpec{{Name: "reconstruction_error"}},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	processor, err := newMetricsProcessor(cfg, consumertest.NewNop(), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, processor.Start(context.Background(), nil))
+	defer func() {
+		assert.NoError(t, processor.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	start := time.Unix(1700000000, 0)
+	windowSeries(metrics, "cpu", start, 0.1, 0.2)
+	windowSeries(metrics, "memory", start, 1, 2)
+	windowSeries(metrics, "disk", start, 10, 20)
+	windowSeries(metrics, "network", start, 100, 200)
+	require.NoError(t, processor.ConsumeMetrics(context.Background(), md))
+
+	// Each rule's window tensor holds its own inputs, a row per input
+	requests := mockServer.GetRequests()
+	require.Len(t, requests, 2)
+	var contents [][]float64
+	for _, request := range requests {
+		require.Len(t, request.Inputs, 1)
+		assert.Equal(t, []int64{2, 2}, request.Inputs[0].Shape)
+		contents = append(contents, request.Inputs[0].Contents.Fp64Contents)
+	}
+	assert.ElementsMatch(t, [][]float64{{0.1, 0.2, 1, 2}, {10, 20, 100, 200}}, contents)
+}
+
+func TestValidateWindowLayout(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			GRPCClientSettings: GRPCClientSettings{Endpoint: "localhost:8001"},
+			DataHandling:       DataHandlingConfig{Mode: "window", WindowSize: 4, AlignTimestamps: true, WindowLayout: windowLayoutFeatures},
+			Rules:              []Rule{{ModelName: "m", Inputs: []string{"x", "y"}}},
+		}
+	}
+	assert.NoError(t, newConfig().Validate())
+
+	cfg := newConfig()
+	cfg.DataHandling.WindowLayout = "columns"
+	assert.ErrorContains(t, cfg.Validate(), `invalid window_layout "columns"`)
+
+	cfg = newConfig()
+	cfg.DataHandling.Mode = "latest"
+	assert.ErrorContains(t, cfg.Validate(), "requires mode 'window'")
+
+	cfg = newConfig()
+	cfg.DataHandling.AlignTimestamps = false
+	assert.ErrorContains(t, cfg.Validate(), "requires align_timestamps")
+
+	cfg = newConfig()
+	cfg.Rules[0].Encoders = map[string]string{"x": "gauge"}
+	assert.ErrorContains(t, cfg.Validate(), "encoders of rule 0")
+}